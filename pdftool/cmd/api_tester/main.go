@@ -14,6 +14,8 @@ import (
 	"path/filepath"
 	"strings"
 	"time"
+
+	"pdftool/internal/respcache"
 )
 
 type chatRequest struct {
@@ -67,6 +69,7 @@ func main() {
 		detail    = flag.String("detail", "", "图像 detail 级别，可选 high/low/auto")
 		maxTokens = flag.Int("max_tokens", 800, "最大返回 token 数")
 		outDir    = flag.String("out", "logs", "日志输出目录")
+		cacheDir  = flag.String("cache", os.Getenv("PDFTOOL_RESPONSE_CACHE_DIR"), "响应缓存目录，相同请求直接返回缓存 (留空关闭)")
 	)
 	flag.Parse()
 
@@ -113,6 +116,17 @@ func main() {
 	}
 
 	endpoint := strings.TrimRight(*baseURL, "/") + "/chat/completions"
+	cache, err := respcache.New(*cacheDir)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	cacheKey := respcache.Key([]byte("api_tester"), []byte(endpoint), bodyBytes)
+	if cached, ok := cache.Get(cacheKey); ok {
+		fmt.Printf("命中响应缓存，未发送请求\n")
+		fmt.Printf("响应内容：\n%s\n", string(cached))
+		return
+	}
+
 	req, err := http.NewRequest("POST", endpoint, bytes.NewReader(bodyBytes))
 	if err != nil {
 		log.Fatalf("构造请求失败: %v", err)
@@ -132,6 +146,12 @@ func main() {
 		log.Fatalf("读取响应失败: %v", err)
 	}
 
+	if resp.StatusCode < 400 {
+		if err := cache.Put(cacheKey, respBody); err != nil {
+			log.Printf("写入响应缓存失败: %v", err)
+		}
+	}
+
 	entry := logEntry{
 		Timestamp: time.Now().Format(time.RFC3339),
 		Request: requestEntry{
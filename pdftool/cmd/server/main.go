@@ -1,35 +1,164 @@
 package main
 
 import (
+	"context"
 	"log"
 
+	"pdftool/internal/avscan"
 	"pdftool/internal/config"
+	"pdftool/internal/eventbus"
+	"pdftool/internal/hooks"
 	"pdftool/internal/httpserver"
+	"pdftool/internal/notify"
+	"pdftool/internal/pdfutil"
+	"pdftool/internal/publish"
+	"pdftool/internal/respcache"
 	"pdftool/internal/service"
+	"pdftool/internal/source"
 	"pdftool/internal/translator"
 )
 
 func main() {
+	pdfutil.RunWorker()
+
 	cfg, err := config.Load()
 	if err != nil {
 		log.Fatalf("加载配置失败: %v", err)
 	}
 
 	defaultProvider := translator.ProviderConfig{
-		Type:           translator.ProviderTypeOpenAI,
+		Type:           translator.NormalizeProviderType(cfg.Provider),
 		BaseURL:        cfg.OpenAIBaseURL,
 		APIKey:         cfg.OpenAIAPIKey,
 		Model:          cfg.OpenAIModel,
 		Timeout:        cfg.RequestTimeout,
+		ConnectTimeout: cfg.ConnectTimeout,
 		MaxTokens:      translator.SanitizeMaxTokens(0),
 		OptimizeLayout: true,
+		FixtureDir:     cfg.MockFixturesDir,
+	}
+
+	var refusalFallback *translator.ProviderConfig
+	if cfg.RefusalFallbackType != "" {
+		refusalFallback = &translator.ProviderConfig{
+			Type:      translator.NormalizeProviderType(cfg.RefusalFallbackType),
+			BaseURL:   cfg.RefusalFallbackBaseURL,
+			APIKey:    cfg.RefusalFallbackAPIKey,
+			Model:     cfg.RefusalFallbackModel,
+			Timeout:   cfg.RequestTimeout,
+			MaxTokens: translator.SanitizeMaxTokens(0),
+		}
 	}
 
-	taskSvc, err := service.NewTaskService(cfg.StorageDir, cfg.StaticPrefix, cfg.PDFFontPath, defaultProvider, cfg.MaxWorkers)
+	responseCache, err := respcache.New(cfg.ResponseCacheDir)
+	if err != nil {
+		log.Fatalf("初始化响应缓存失败: %v", err)
+	}
+
+	pipelineHooks := hooks.New()
+	pipelineHooks.AddWebhooks(hooks.WebhookConfig{
+		PreRenderURL:     cfg.HookPreRenderURL,
+		PreTranslateURL:  cfg.HookPreTranslateURL,
+		PostTranslateURL: cfg.HookPostTranslateURL,
+		Timeout:          cfg.HookTimeout,
+	})
+
+	opts := service.Options{
+		InstanceID: cfg.InstanceID,
+		Budget: service.BudgetLimits{
+			DailyTokens:           cfg.BudgetDailyTokens,
+			MonthlyTokens:         cfg.BudgetMonthlyTokens,
+			DailyCost:             cfg.BudgetDailyCost,
+			MonthlyCost:           cfg.BudgetMonthlyCost,
+			PricePerMillionTokens: cfg.PricePerMillionTokens,
+		},
+		Notifier: notify.New(notify.Config{
+			SlackWebhookURL:  cfg.SlackWebhookURL,
+			TelegramBotToken: cfg.TelegramBotToken,
+			TelegramChatID:   cfg.TelegramChatID,
+			WebhookURL:       cfg.NotifyWebhookURL,
+		}),
+		FailureAlertThreshold: cfg.FailureAlertThreshold,
+		StorageAlertBytes:     cfg.StorageAlertMB << 20,
+		Sources: source.NewRegistry(source.Config{
+			WebDAVURL:      cfg.WebDAVURL,
+			WebDAVUser:     cfg.WebDAVUser,
+			WebDAVPassword: cfg.WebDAVPassword,
+			DropboxToken:   cfg.DropboxToken,
+			GDriveToken:    cfg.GDriveToken,
+		}),
+		Publisher: publish.New(publish.Config{
+			S3Endpoint:          cfg.S3Endpoint,
+			S3Region:            cfg.S3Region,
+			S3AccessKey:         cfg.S3AccessKey,
+			S3SecretKey:         cfg.S3SecretKey,
+			S3Buckets:           cfg.S3Buckets,
+			WebDAVURL:           cfg.WebDAVURL,
+			WebDAVUser:          cfg.WebDAVUser,
+			WebDAVPassword:      cfg.WebDAVPassword,
+			AllowedDestinations: cfg.OutputDestinations,
+		}),
+		PandocPath: cfg.PandocPath,
+		Events: eventbus.New(eventbus.Config{
+			MQTTURL:     cfg.MQTTURL,
+			MQTTTopic:   cfg.MQTTTopic,
+			NATSURL:     cfg.NATSURL,
+			NATSSubject: cfg.NATSSubject,
+		}),
+		BatchLimits: service.BatchLimits{
+			MaxURLs:          cfg.BatchMaxURLs,
+			MaxDownloadBytes: cfg.DownloadMaxMB << 20,
+		},
+		SplitMinPages:   cfg.SplitMinPages,
+		AutoPauseStreak: cfg.AutoPauseStreak,
+		ProviderWorkers: cfg.ProviderWorkers,
+		Retry: service.RetryPolicy{
+			MaxAttempts: cfg.RetryMaxAttempts,
+			BaseDelay:   cfg.RetryBaseDelay,
+		},
+		ResponseCache:     responseCache,
+		TrashRetention:    cfg.TrashRetention,
+		RefusalFallback:   refusalFallback,
+		AutoExport:        cfg.AutoExport,
+		PromptsFile:       cfg.PromptsFile,
+		QuotasFile:        cfg.QuotasFile,
+		MaxTasksPerClient: cfg.MaxTasksPerClient,
+		Hooks:             pipelineHooks,
+		SanitizeMode:      cfg.PDFSanitize,
+		VirusScanner:      avscan.New(cfg.ClamdAddr, cfg.ClamdTimeout),
+		VirusScanFailOpen: cfg.ClamdFailOpen,
+		StorageKey:        cfg.StorageKey,
+		StorageKeyMode:    cfg.StorageKeyMode,
+		Deterministic:     cfg.Deterministic,
+		TaskStore:         cfg.TaskStore,
+		Render:            cfg.Render,
+		Renderer: pdfutil.Renderer{
+			Isolated:  cfg.RenderIsolation,
+			Timeout:   cfg.RenderTimeout,
+			MaxMemory: cfg.RenderMaxMemory,
+		},
+		Timeouts: service.ProviderTimeouts{
+			Connect:    cfg.ConnectTimeout,
+			Request:    cfg.RequestTimeout,
+			PageBudget: cfg.PageBudget,
+		},
+		ProviderTimeouts:   make(map[string]service.ProviderTimeouts, len(cfg.ProviderTimeouts)),
+		ProviderImageTypes: cfg.ProviderImageTypes,
+
+		PDFFonts: cfg.PDFFonts,
+	}
+	for name, t := range cfg.ProviderTimeouts {
+		opts.ProviderTimeouts[name] = service.ProviderTimeouts{Connect: t.Connect, Request: t.Request, PageBudget: t.PageBudget}
+	}
+	taskSvc, err := service.NewTaskService(cfg.StorageDir, cfg.StaticPrefix, cfg.PDFFontPath, defaultProvider, cfg.MaxWorkers, opts)
 	if err != nil {
 		log.Fatalf("初始化任务服务失败: %v", err)
 	}
 
+	go taskSvc.RecoverTasks(cfg.AutoResume)
+	go taskSvc.RunRetryScheduler(context.Background())
+	go taskSvc.RunTrashPurger(context.Background())
+
 	server := httpserver.New(cfg, taskSvc)
 	log.Printf("PDF tool service listening on %s", cfg.ListenAddr)
 	if err := server.Run(); err != nil {
@@ -1,6 +1,10 @@
 package assets
 
-import _ "embed"
+import (
+	_ "embed"
+
+	"golang.org/x/image/font/gofont/goregular"
+)
 
 // DefaultChineseFont holds an embedded CJK font for PDF export.
 //
@@ -11,3 +15,9 @@ var defaultChineseFont []byte
 func DefaultChineseFont() []byte {
 	return defaultChineseFont
 }
+
+// DefaultLatinFont returns the embedded Go Regular font, which covers Latin,
+// Greek and Cyrillic text.
+func DefaultLatinFont() []byte {
+	return goregular.TTF
+}
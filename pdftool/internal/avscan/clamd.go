@@ -0,0 +1,139 @@
+// Package avscan scans uploaded files with ClamAV through clamd's INSTREAM
+// command, over a unix socket or TCP.
+package avscan
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	defaultTimeout = 60 * time.Second
+	chunkSize      = 64 << 10
+)
+
+// Result is clamd's verdict on one file.
+type Result struct {
+	Infected bool
+	// Signature names the detected malware when Infected is set.
+	Signature string
+}
+
+// Scanner talks to one clamd daemon. A nil Scanner is disabled.
+type Scanner struct {
+	network string
+	address string
+	timeout time.Duration
+}
+
+// New returns a scanner for addr, which is a socket path ("/run/clamav/clamd.ctl"
+// or "unix:///run/clamav/clamd.ctl") or a TCP address ("127.0.0.1:3310" or
+// "tcp://clamd:3310"). An empty addr returns nil.
+func New(addr string, timeout time.Duration) *Scanner {
+	addr = strings.TrimSpace(addr)
+	if addr == "" {
+		return nil
+	}
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	network := "tcp"
+	switch {
+	case strings.HasPrefix(addr, "unix://"):
+		network, addr = "unix", strings.TrimPrefix(addr, "unix://")
+	case strings.HasPrefix(addr, "unix:"):
+		network, addr = "unix", strings.TrimPrefix(addr, "unix:")
+	case strings.HasPrefix(addr, "tcp://"):
+		addr = strings.TrimPrefix(addr, "tcp://")
+	case strings.HasPrefix(addr, "/"):
+		network = "unix"
+	}
+	return &Scanner{network: network, address: addr, timeout: timeout}
+}
+
+// Enabled reports whether uploads are scanned.
+func (s *Scanner) Enabled() bool {
+	return s != nil
+}
+
+// ScanFile streams the file at path to clamd and returns its verdict.
+func (s *Scanner) ScanFile(ctx context.Context, path string) (Result, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Result{}, err
+	}
+	defer f.Close()
+	return s.Scan(ctx, f)
+}
+
+// Scan streams r to clamd and returns its verdict.
+func (s *Scanner) Scan(ctx context.Context, r io.Reader) (Result, error) {
+	dialer := net.Dialer{Timeout: s.timeout}
+	conn, err := dialer.DialContext(ctx, s.network, s.address)
+	if err != nil {
+		return Result{}, fmt.Errorf("连接 clamd 失败: %w", err)
+	}
+	defer conn.Close()
+	deadline := time.Now().Add(s.timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetDeadline(deadline)
+
+	w := bufio.NewWriterSize(conn, chunkSize+4)
+	if _, err := w.WriteString("zINSTREAM\x00"); err != nil {
+		return Result{}, fmt.Errorf("发送扫描请求失败: %w", err)
+	}
+	buf := make([]byte, chunkSize)
+	var size [4]byte
+	for {
+		n, readErr := r.Read(buf)
+		if n > 0 {
+			binary.BigEndian.PutUint32(size[:], uint32(n))
+			w.Write(size[:])
+			if _, err := w.Write(buf[:n]); err != nil {
+				return Result{}, fmt.Errorf("发送扫描数据失败: %w", err)
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return Result{}, readErr
+		}
+	}
+	binary.BigEndian.PutUint32(size[:], 0)
+	w.Write(size[:])
+	if err := w.Flush(); err != nil {
+		return Result{}, fmt.Errorf("发送扫描数据失败: %w", err)
+	}
+
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && reply == "" {
+		return Result{}, fmt.Errorf("读取 clamd 响应失败: %w", err)
+	}
+	return parseReply(reply)
+}
+
+// parseReply decodes "stream: OK", "stream: <signature> FOUND" and
+// "<message> ERROR" replies.
+func parseReply(reply string) (Result, error) {
+	reply = strings.TrimSpace(strings.TrimRight(reply, "\x00"))
+	body := strings.TrimSpace(strings.TrimPrefix(reply, "stream:"))
+	switch {
+	case body == "OK":
+		return Result{}, nil
+	case strings.HasSuffix(body, " FOUND"):
+		return Result{Infected: true, Signature: strings.TrimSuffix(body, " FOUND")}, nil
+	case strings.HasSuffix(body, " ERROR"):
+		return Result{}, fmt.Errorf("clamd 扫描出错: %s", strings.TrimSuffix(body, " ERROR"))
+	}
+	return Result{}, fmt.Errorf("无法识别的 clamd 响应: %q", reply)
+}
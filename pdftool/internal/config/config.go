@@ -2,11 +2,19 @@ package config
 
 import (
 	"fmt"
+	"log"
+	"net"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
+
+	"pdftool/internal/cryptfile"
+	"pdftool/internal/pdfutil"
+	"pdftool/internal/sysmem"
+	"pdftool/internal/translator"
 )
 
 // Config aggregates runtime settings for the PDF tool service.
@@ -19,16 +27,179 @@ type Config struct {
 	OpenAIAPIKey   string
 	OpenAIModel    string
 	RequestTimeout time.Duration
-	PDFFontPath    string
+	// ConnectTimeout bounds dialing a provider; PageBudget bounds all attempts on a page.
+	ConnectTimeout time.Duration
+	PageBudget     time.Duration
+	// ProviderTimeouts overrides the timeouts per provider type.
+	ProviderTimeouts map[string]Timeouts
+	PDFFontPath      string
+	InstanceID       string
+
+	// ProviderImageTypes lists the image MIME types each provider type accepts.
+	ProviderImageTypes map[string][]string
+
+	// PDFFonts maps export languages (zh, ja, ko, ar, latin) to TTF fonts.
+	PDFFonts map[string]string
+
+	BudgetDailyTokens     int64
+	BudgetMonthlyTokens   int64
+	BudgetDailyCost       float64
+	BudgetMonthlyCost     float64
+	PricePerMillionTokens float64
+
+	SlackWebhookURL       string
+	TelegramBotToken      string
+	TelegramChatID        string
+	NotifyWebhookURL      string
+	FailureAlertThreshold int
+	StorageAlertMB        int64
+
+	WebDAVURL      string
+	WebDAVUser     string
+	WebDAVPassword string
+	DropboxToken   string
+	GDriveToken    string
+
+	S3Endpoint  string
+	S3Region    string
+	S3AccessKey string
+	S3SecretKey string
+	// S3Buckets and OutputDestinations restrict where tasks may export;
+	// WebDAV destinations below WebDAVURL are always allowed.
+	S3Buckets          []string
+	OutputDestinations []string
+
+	PandocPath string
+
+	MQTTURL     string
+	MQTTTopic   string
+	NATSURL     string
+	NATSSubject string
+
+	BatchMaxURLs  int
+	DownloadMaxMB int64
+	// SplitMinPages is the page count from which uploads asking for it are
+	// split into one task per group of chapters.
+	SplitMinPages int
+
+	AutoPauseStreak int
+	AutoResume      bool
+	// AutoExport generates each task's preferred exports once all pages are translated.
+	AutoExport bool
+	// ProviderWorkers caps concurrent page requests per provider type, e.g. openai=8,gemini=4.
+	ProviderWorkers map[string]int
+	// MaxTasksPerClient caps concurrently processing tasks per API key user
+	// or IP; zero is unlimited.
+	MaxTasksPerClient int
+
+	RetryMaxAttempts int
+	RetryBaseDelay   time.Duration
+
+	// ResponseCacheDir enables the development response cache for the formatter.
+	ResponseCacheDir string
+
+	// Provider is the default provider type: "openai" uses the OPENAI_*
+	// settings, "ollama" a local Ollama server at OPENAI_BASE_URL, "mock"
+	// answers offline, optionally from MockFixturesDir.
+	Provider        string
+	MockFixturesDir string
+
+	// TrashRetention keeps deleted tasks restorable; zero deletes them immediately.
+	TrashRetention time.Duration
+
+	// RefusalFallback* configure the provider that retries pages refused by the
+	// primary provider's content policy; an empty type disables the fallback.
+	RefusalFallbackType    string
+	RefusalFallbackBaseURL string
+	RefusalFallbackAPIKey  string
+	RefusalFallbackModel   string
+
+	// PromptsFile overrides the built-in provider prompts (JSON).
+	PromptsFile string
+	// QuotasFile assigns API keys to users with page/token quotas (JSON).
+	QuotasFile string
+	// AdminToken guards the admin API; empty disables it.
+	AdminToken string
+	// AuthTokens are the API tokens required for /api/pdf and the static
+	// files; empty leaves the API open.
+	AuthTokens []string
+	// TrustedProxies lists the proxy IPs or CIDRs whose X-Forwarded-For and
+	// X-Real-IP headers are believed; empty keys anonymous clients on the
+	// connection's remote address.
+	TrustedProxies []string
+
+	// Hook* are webhook URLs transforming the PDF before rendering, page
+	// images before translation and page text after it.
+	HookPreRenderURL     string
+	HookPreTranslateURL  string
+	HookPostTranslateURL string
+	HookTimeout          time.Duration
+
+	// RenderIsolation rasterizes uploads in a worker subprocess so a PDF
+	// crashing MuPDF only fails its own task.
+	RenderIsolation bool
+	// RenderTimeout kills a render worker running longer than this.
+	RenderTimeout time.Duration
+	// RenderMaxMemory recycles a render worker growing beyond this many
+	// bytes; zero disables the watchdog. Defaults to half the container
+	// memory limit when one is detected.
+	RenderMaxMemory int64
+	// Render is the default resolution, image format and size limit of
+	// page images; tasks may override it.
+	Render pdfutil.RenderOptions
+	// PDFSanitize is "disarm", "reject" or "off" for uploads carrying
+	// JavaScript, embedded files or launch actions.
+	PDFSanitize string
+
+	// ClamdAddr is the clamd socket path or TCP address scanning uploads;
+	// empty disables virus scanning.
+	ClamdAddr     string
+	ClamdTimeout  time.Duration
+	ClamdFailOpen bool
+
+	// StorageKey encrypts stored task data at rest; nil disables encryption.
+	StorageKey *cryptfile.Key
+	// StorageKeyMode is "server" (StorageKey encrypts everything) or "task"
+	// (per-task keys wrapped by StorageKey).
+	StorageKeyMode string
+
+	// StaticAccess is "open" or "token"; in token mode task files are only
+	// served with one of the task's access tokens.
+	StaticAccess string
+
+	// Deterministic fixes export timestamps and derives task IDs from a
+	// sequence, for tests and reproducible output.
+	Deterministic bool
+
+	// TaskStore is "sqlite" (tasks.db in the storage dir) or "json" (one
+	// meta.json per task directory).
+	TaskStore string
+}
+
+// Timeouts holds one provider type's limits; zero fields use the defaults.
+type Timeouts struct {
+	Connect    time.Duration
+	Request    time.Duration
+	PageBudget time.Duration
 }
 
 const (
-	defaultListenAddr   = ":8090"
-	defaultStorageDir   = "storage/pdf_tool"
-	defaultStaticPrefix = "/pdf-data"
-	defaultBaseURL      = "https://api.openai.com/v1"
-	defaultWorkers      = 4
-	defaultTimeoutSec   = 300
+	defaultListenAddr    = ":8090"
+	defaultStorageDir    = "storage/pdf_tool"
+	defaultStaticPrefix  = "/pdf-data"
+	defaultBaseURL       = "https://api.openai.com/v1"
+	defaultOllamaBaseURL = "http://localhost:11434"
+	defaultWorkers       = 4
+	defaultTimeoutSec    = 300
+	defaultConnectSec    = 10
+	defaultPauseStreak   = 5
+
+	defaultRetryAttempts  = 3
+	defaultRetryDelaySec  = 30
+	defaultTrashDays      = 7
+	defaultHookTimeoutSec = 30
+	defaultRenderTimeout  = 600
+	defaultClamdTimeout   = 60
 )
 
 // Load builds the Config from environment variables.
@@ -41,6 +212,52 @@ func Load() (Config, error) {
 		OpenAIAPIKey:  strings.TrimSpace(os.Getenv("OPENAI_API_KEY")),
 		OpenAIModel:   strings.TrimSpace(getEnv("OPENAI_MODEL", os.Getenv("OPENAI_MODEL_ID"))),
 		PDFFontPath:   strings.TrimSpace(os.Getenv("PDFTOOL_FONT_PATH")),
+		InstanceID:    strings.TrimSpace(os.Getenv("PDFTOOL_INSTANCE_ID")),
+
+		SlackWebhookURL:  strings.TrimSpace(os.Getenv("PDFTOOL_SLACK_WEBHOOK_URL")),
+		TelegramBotToken: strings.TrimSpace(os.Getenv("PDFTOOL_TELEGRAM_BOT_TOKEN")),
+		TelegramChatID:   strings.TrimSpace(os.Getenv("PDFTOOL_TELEGRAM_CHAT_ID")),
+		NotifyWebhookURL: strings.TrimSpace(os.Getenv("PDFTOOL_NOTIFY_WEBHOOK_URL")),
+
+		WebDAVURL:      strings.TrimSpace(os.Getenv("PDFTOOL_WEBDAV_URL")),
+		WebDAVUser:     strings.TrimSpace(os.Getenv("PDFTOOL_WEBDAV_USER")),
+		WebDAVPassword: os.Getenv("PDFTOOL_WEBDAV_PASSWORD"),
+		DropboxToken:   strings.TrimSpace(os.Getenv("PDFTOOL_DROPBOX_TOKEN")),
+		GDriveToken:    strings.TrimSpace(os.Getenv("PDFTOOL_GDRIVE_TOKEN")),
+
+		S3Endpoint:  strings.TrimSpace(os.Getenv("PDFTOOL_S3_ENDPOINT")),
+		S3Region:    strings.TrimSpace(os.Getenv("PDFTOOL_S3_REGION")),
+		S3AccessKey: strings.TrimSpace(os.Getenv("PDFTOOL_S3_ACCESS_KEY")),
+		S3SecretKey: strings.TrimSpace(os.Getenv("PDFTOOL_S3_SECRET_KEY")),
+
+		S3Buckets:          parseList(os.Getenv("PDFTOOL_S3_BUCKETS")),
+		OutputDestinations: parseList(os.Getenv("PDFTOOL_OUTPUT_DESTINATIONS")),
+
+		PandocPath: strings.TrimSpace(os.Getenv("PDFTOOL_PANDOC_PATH")),
+
+		ResponseCacheDir: strings.TrimSpace(os.Getenv("PDFTOOL_RESPONSE_CACHE_DIR")),
+
+		Provider:        strings.ToLower(strings.TrimSpace(getEnv("PDFTOOL_PROVIDER", "openai"))),
+		MockFixturesDir: strings.TrimSpace(os.Getenv("PDFTOOL_MOCK_FIXTURES_DIR")),
+
+		RefusalFallbackType:    strings.ToLower(strings.TrimSpace(os.Getenv("PDFTOOL_REFUSAL_FALLBACK_PROVIDER"))),
+		RefusalFallbackBaseURL: strings.TrimSpace(os.Getenv("PDFTOOL_REFUSAL_FALLBACK_BASE_URL")),
+		RefusalFallbackAPIKey:  strings.TrimSpace(os.Getenv("PDFTOOL_REFUSAL_FALLBACK_API_KEY")),
+		RefusalFallbackModel:   strings.TrimSpace(os.Getenv("PDFTOOL_REFUSAL_FALLBACK_MODEL")),
+
+		PromptsFile: strings.TrimSpace(os.Getenv("PDFTOOL_PROMPTS_FILE")),
+		QuotasFile:  strings.TrimSpace(os.Getenv("PDFTOOL_QUOTAS_FILE")),
+		AdminToken:  strings.TrimSpace(os.Getenv("PDFTOOL_ADMIN_TOKEN")),
+		AuthTokens:  parseList(os.Getenv("PDFTOOL_AUTH_TOKENS")),
+
+		HookPreRenderURL:     strings.TrimSpace(os.Getenv("PDFTOOL_HOOK_PRE_RENDER_URL")),
+		HookPreTranslateURL:  strings.TrimSpace(os.Getenv("PDFTOOL_HOOK_PRE_TRANSLATE_URL")),
+		HookPostTranslateURL: strings.TrimSpace(os.Getenv("PDFTOOL_HOOK_POST_TRANSLATE_URL")),
+
+		MQTTURL:     strings.TrimSpace(os.Getenv("PDFTOOL_MQTT_URL")),
+		MQTTTopic:   strings.TrimSpace(os.Getenv("PDFTOOL_MQTT_TOPIC")),
+		NATSURL:     strings.TrimSpace(os.Getenv("PDFTOOL_NATS_URL")),
+		NATSSubject: strings.TrimSpace(os.Getenv("PDFTOOL_NATS_SUBJECT")),
 	}
 
 	if workersStr := strings.TrimSpace(os.Getenv("PDFTOOL_MAX_WORKERS")); workersStr != "" {
@@ -63,10 +280,288 @@ func Load() (Config, error) {
 		}
 	}
 
+	cfg.ConnectTimeout = defaultConnectSec * time.Second
+	if raw := strings.TrimSpace(os.Getenv("PDFTOOL_CONNECT_TIMEOUT")); raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil || v < 0 {
+			return Config{}, fmt.Errorf("invalid PDFTOOL_CONNECT_TIMEOUT: %q", raw)
+		}
+		cfg.ConnectTimeout = time.Duration(v) * time.Second
+	}
+	if raw := strings.TrimSpace(os.Getenv("PDFTOOL_PAGE_TIME_BUDGET")); raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil || v < 0 {
+			return Config{}, fmt.Errorf("invalid PDFTOOL_PAGE_TIME_BUDGET: %q", raw)
+		}
+		cfg.PageBudget = time.Duration(v) * time.Second
+	}
+
+	var err error
+	if cfg.ProviderTimeouts, err = parseProviderTimeouts(os.Getenv("PDFTOOL_PROVIDER_TIMEOUTS")); err != nil {
+		return Config{}, err
+	}
+	if cfg.ProviderImageTypes, err = parseProviderImageTypes(os.Getenv("PDFTOOL_PROVIDER_IMAGE_TYPES")); err != nil {
+		return Config{}, err
+	}
+	if cfg.PDFFonts, err = parsePDFFonts(os.Getenv("PDFTOOL_FONTS")); err != nil {
+		return Config{}, err
+	}
+	if cfg.BudgetDailyTokens, err = getEnvInt64("PDFTOOL_BUDGET_DAILY_TOKENS"); err != nil {
+		return Config{}, err
+	}
+	if cfg.BudgetMonthlyTokens, err = getEnvInt64("PDFTOOL_BUDGET_MONTHLY_TOKENS"); err != nil {
+		return Config{}, err
+	}
+	if cfg.BudgetDailyCost, err = getEnvFloat("PDFTOOL_BUDGET_DAILY_COST"); err != nil {
+		return Config{}, err
+	}
+	if cfg.BudgetMonthlyCost, err = getEnvFloat("PDFTOOL_BUDGET_MONTHLY_COST"); err != nil {
+		return Config{}, err
+	}
+	if cfg.PricePerMillionTokens, err = getEnvFloat("PDFTOOL_PRICE_PER_MILLION_TOKENS"); err != nil {
+		return Config{}, err
+	}
+
+	threshold, err := getEnvInt64("PDFTOOL_FAILURE_ALERT_THRESHOLD")
+	if err != nil {
+		return Config{}, err
+	}
+	cfg.FailureAlertThreshold = int(threshold)
+	if cfg.StorageAlertMB, err = getEnvInt64("PDFTOOL_STORAGE_ALERT_MB"); err != nil {
+		return Config{}, err
+	}
+
+	maxURLs, err := getEnvInt64("PDFTOOL_BATCH_MAX_URLS")
+	if err != nil {
+		return Config{}, err
+	}
+	cfg.BatchMaxURLs = int(maxURLs)
+	splitMin, err := getEnvInt64("PDFTOOL_SPLIT_MIN_PAGES")
+	if err != nil {
+		return Config{}, err
+	}
+	cfg.SplitMinPages = int(splitMin)
+	if cfg.DownloadMaxMB, err = getEnvInt64("PDFTOOL_DOWNLOAD_MAX_MB"); err != nil {
+		return Config{}, err
+	}
+	if cfg.TrustedProxies, err = parseTrustedProxies(os.Getenv("PDFTOOL_TRUSTED_PROXIES")); err != nil {
+		return Config{}, err
+	}
+
+	cfg.AutoPauseStreak = defaultPauseStreak
+	if raw := strings.TrimSpace(os.Getenv("PDFTOOL_AUTO_PAUSE_STREAK")); raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil || v < 0 {
+			return Config{}, fmt.Errorf("invalid PDFTOOL_AUTO_PAUSE_STREAK: %q", raw)
+		}
+		cfg.AutoPauseStreak = v
+	}
+
+	if raw := strings.TrimSpace(os.Getenv("PDFTOOL_MAX_TASKS_PER_CLIENT")); raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil || v < 0 {
+			return Config{}, fmt.Errorf("invalid PDFTOOL_MAX_TASKS_PER_CLIENT: %q", raw)
+		}
+		cfg.MaxTasksPerClient = v
+	}
+
+	if cfg.ProviderWorkers, err = parseProviderWorkers(os.Getenv("PDFTOOL_PROVIDER_WORKERS")); err != nil {
+		return Config{}, err
+	}
+
+	cfg.RetryMaxAttempts = defaultRetryAttempts
+	if raw := strings.TrimSpace(os.Getenv("PDFTOOL_RETRY_MAX_ATTEMPTS")); raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil || v < 0 {
+			return Config{}, fmt.Errorf("invalid PDFTOOL_RETRY_MAX_ATTEMPTS: %q", raw)
+		}
+		cfg.RetryMaxAttempts = v
+	}
+	cfg.RetryBaseDelay = defaultRetryDelaySec * time.Second
+	if raw := strings.TrimSpace(os.Getenv("PDFTOOL_RETRY_BASE_DELAY_SEC")); raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil || v <= 0 {
+			return Config{}, fmt.Errorf("invalid PDFTOOL_RETRY_BASE_DELAY_SEC: %q", raw)
+		}
+		cfg.RetryBaseDelay = time.Duration(v) * time.Second
+	}
+
+	cfg.TrashRetention = defaultTrashDays * 24 * time.Hour
+	if raw := strings.TrimSpace(os.Getenv("PDFTOOL_TRASH_RETENTION_DAYS")); raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil || v < 0 {
+			return Config{}, fmt.Errorf("invalid PDFTOOL_TRASH_RETENTION_DAYS: %q", raw)
+		}
+		cfg.TrashRetention = time.Duration(v) * 24 * time.Hour
+	}
+
+	switch cfg.Provider {
+	case "openai", "mock":
+	case "ollama":
+		if strings.TrimSpace(os.Getenv("OPENAI_BASE_URL")) == "" {
+			cfg.OpenAIBaseURL = defaultOllamaBaseURL
+		}
+	default:
+		return Config{}, fmt.Errorf("invalid PDFTOOL_PROVIDER: %q", cfg.Provider)
+	}
+
+	switch cfg.RefusalFallbackType {
+	case "", "openai", "gemini", "anthropic", "ollama":
+	default:
+		return Config{}, fmt.Errorf("invalid PDFTOOL_REFUSAL_FALLBACK_PROVIDER: %q", cfg.RefusalFallbackType)
+	}
+	if cfg.RefusalFallbackType != "" && (cfg.RefusalFallbackBaseURL == "" || cfg.RefusalFallbackModel == "") {
+		return Config{}, fmt.Errorf("PDFTOOL_REFUSAL_FALLBACK_PROVIDER requires PDFTOOL_REFUSAL_FALLBACK_BASE_URL and PDFTOOL_REFUSAL_FALLBACK_MODEL")
+	}
+
+	cfg.AutoResume = true
+	if raw := strings.TrimSpace(os.Getenv("PDFTOOL_AUTO_RESUME")); raw != "" {
+		v, err := strconv.ParseBool(raw)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid PDFTOOL_AUTO_RESUME: %q", raw)
+		}
+		cfg.AutoResume = v
+	}
+
+	if raw := strings.TrimSpace(os.Getenv("PDFTOOL_AUTO_EXPORT")); raw != "" {
+		v, err := strconv.ParseBool(raw)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid PDFTOOL_AUTO_EXPORT: %q", raw)
+		}
+		cfg.AutoExport = v
+	}
+
+	cfg.HookTimeout = defaultHookTimeoutSec * time.Second
+	if raw := strings.TrimSpace(os.Getenv("PDFTOOL_HOOK_TIMEOUT")); raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil || v <= 0 {
+			return Config{}, fmt.Errorf("invalid PDFTOOL_HOOK_TIMEOUT: %q", raw)
+		}
+		cfg.HookTimeout = time.Duration(v) * time.Second
+	}
+
+	cfg.RenderIsolation = true
+	if raw := strings.TrimSpace(os.Getenv("PDFTOOL_RENDER_ISOLATION")); raw != "" {
+		v, err := strconv.ParseBool(raw)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid PDFTOOL_RENDER_ISOLATION: %q", raw)
+		}
+		cfg.RenderIsolation = v
+	}
+
+	cfg.RenderTimeout = defaultRenderTimeout * time.Second
+	if raw := strings.TrimSpace(os.Getenv("PDFTOOL_RENDER_TIMEOUT")); raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil || v <= 0 {
+			return Config{}, fmt.Errorf("invalid PDFTOOL_RENDER_TIMEOUT: %q", raw)
+		}
+		cfg.RenderTimeout = time.Duration(v) * time.Second
+	}
+
+	cfg.RenderMaxMemory = sysmem.ContainerLimit() / 2
+	if raw := strings.TrimSpace(os.Getenv("PDFTOOL_RENDER_MAX_MEMORY_MB")); raw != "" {
+		v, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || v < 0 {
+			return Config{}, fmt.Errorf("invalid PDFTOOL_RENDER_MAX_MEMORY_MB: %q", raw)
+		}
+		cfg.RenderMaxMemory = v << 20
+	}
+
+	for name, field := range map[string]*int{
+		"PDFTOOL_RENDER_DPI":           &cfg.Render.DPI,
+		"PDFTOOL_RENDER_JPEG_QUALITY":  &cfg.Render.JPEGQuality,
+		"PDFTOOL_RENDER_MAX_DIMENSION": &cfg.Render.MaxDimension,
+	} {
+		if raw := strings.TrimSpace(os.Getenv(name)); raw != "" {
+			v, err := strconv.Atoi(raw)
+			if err != nil {
+				return Config{}, fmt.Errorf("invalid %s: %q", name, raw)
+			}
+			*field = v
+		}
+	}
+	cfg.Render.Format = os.Getenv("PDFTOOL_RENDER_FORMAT")
+	if cfg.Render, err = cfg.Render.Normalize(); err != nil {
+		return Config{}, fmt.Errorf("invalid PDFTOOL_RENDER_*: %v", err)
+	}
+
+	cfg.PDFSanitize = strings.ToLower(getEnv("PDFTOOL_PDF_SANITIZE", "disarm"))
+	switch cfg.PDFSanitize {
+	case "disarm", "reject", "off":
+	default:
+		return Config{}, fmt.Errorf("invalid PDFTOOL_PDF_SANITIZE: %q", cfg.PDFSanitize)
+	}
+
+	cfg.ClamdAddr = strings.TrimSpace(os.Getenv("PDFTOOL_CLAMD_ADDR"))
+	cfg.ClamdTimeout = defaultClamdTimeout * time.Second
+	if raw := strings.TrimSpace(os.Getenv("PDFTOOL_CLAMD_TIMEOUT")); raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil || v <= 0 {
+			return Config{}, fmt.Errorf("invalid PDFTOOL_CLAMD_TIMEOUT: %q", raw)
+		}
+		cfg.ClamdTimeout = time.Duration(v) * time.Second
+	}
+	if raw := strings.TrimSpace(os.Getenv("PDFTOOL_CLAMD_FAIL_OPEN")); raw != "" {
+		v, err := strconv.ParseBool(raw)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid PDFTOOL_CLAMD_FAIL_OPEN: %q", raw)
+		}
+		cfg.ClamdFailOpen = v
+	}
+
+	if raw := strings.TrimSpace(os.Getenv("PDFTOOL_STORAGE_KEY")); raw != "" {
+		key, err := cryptfile.ParseKey(raw)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid PDFTOOL_STORAGE_KEY: %v", err)
+		}
+		cfg.StorageKey = key
+	}
+	cfg.StorageKeyMode = strings.ToLower(getEnv("PDFTOOL_STORAGE_KEY_MODE", "server"))
+	if cfg.StorageKeyMode != "server" && cfg.StorageKeyMode != "task" {
+		return Config{}, fmt.Errorf("invalid PDFTOOL_STORAGE_KEY_MODE: %q", cfg.StorageKeyMode)
+	}
+
+	cfg.TaskStore = strings.ToLower(getEnv("PDFTOOL_TASK_STORE", "sqlite"))
+	if cfg.TaskStore != "sqlite" && cfg.TaskStore != "json" {
+		return Config{}, fmt.Errorf("invalid PDFTOOL_TASK_STORE: %q", cfg.TaskStore)
+	}
+
+	cfg.StaticAccess = strings.ToLower(getEnv("PDFTOOL_STATIC_ACCESS", "open"))
+	if cfg.StaticAccess != "open" && cfg.StaticAccess != "token" {
+		return Config{}, fmt.Errorf("invalid PDFTOOL_STATIC_ACCESS: %q", cfg.StaticAccess)
+	}
+
+	if raw := strings.TrimSpace(os.Getenv("PDFTOOL_DETERMINISTIC")); raw != "" {
+		v, err := strconv.ParseBool(raw)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid PDFTOOL_DETERMINISTIC: %q", raw)
+		}
+		cfg.Deterministic = v
+	}
+
 	if !strings.HasPrefix(cfg.StaticPrefix, "/") {
 		cfg.StaticPrefix = "/" + cfg.StaticPrefix
 	}
 	cfg.StorageDir = filepath.Clean(cfg.StorageDir)
+	if cfg.PandocPath != "" {
+		resolved, err := exec.LookPath(cfg.PandocPath)
+		if err == nil {
+			err = checkPandocVersion(resolved)
+		}
+		if err == nil {
+			cfg.PandocPath = resolved
+		} else {
+			log.Printf("pandoc 不可用，已禁用扩展导出格式: %v", err)
+			cfg.PandocPath = ""
+		}
+	}
+	if cfg.InstanceID == "" {
+		host, _ := os.Hostname()
+		if host == "" {
+			host = "pdftool"
+		}
+		cfg.InstanceID = fmt.Sprintf("%s-%d", host, os.Getpid())
+	}
 
 	return cfg, nil
 }
@@ -78,3 +573,201 @@ func getEnv(key, fallback string) string {
 	}
 	return fallback
 }
+
+func getEnvInt64(key string) (int64, error) {
+	raw := strings.TrimSpace(os.Getenv(key))
+	if raw == "" {
+		return 0, nil
+	}
+	v, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || v < 0 {
+		return 0, fmt.Errorf("invalid %s: %q", key, raw)
+	}
+	return v, nil
+}
+
+func getEnvFloat(key string) (float64, error) {
+	raw := strings.TrimSpace(os.Getenv(key))
+	if raw == "" {
+		return 0, nil
+	}
+	v, err := strconv.ParseFloat(raw, 64)
+	if err != nil || v < 0 {
+		return 0, fmt.Errorf("invalid %s: %q", key, raw)
+	}
+	return v, nil
+}
+
+// checkPandocVersion requires pandoc 2.15 or later, the first release with
+// --sandbox, which exports rely on to keep pandoc from reading local files
+// and URLs named in the converted text.
+func checkPandocVersion(path string) error {
+	output, err := exec.Command(path, "--version").Output()
+	if err != nil {
+		return fmt.Errorf("读取 pandoc 版本失败: %w", err)
+	}
+	line, _, _ := strings.Cut(string(output), "\n")
+	fields := strings.Fields(line)
+	if len(fields) < 2 {
+		return fmt.Errorf("无法识别 pandoc 版本: %q", line)
+	}
+	parts := strings.Split(fields[1], ".")
+	major, err := strconv.Atoi(parts[0])
+	minor := 0
+	if err == nil && len(parts) > 1 {
+		minor, err = strconv.Atoi(parts[1])
+	}
+	if err != nil {
+		return fmt.Errorf("无法识别 pandoc 版本: %q", line)
+	}
+	if major < 2 || major == 2 && minor < 15 {
+		return fmt.Errorf("pandoc %s 不支持 --sandbox，需要 2.15 或更高版本", fields[1])
+	}
+	return nil
+}
+
+// parseList splits a comma-separated list, dropping empty entries.
+func parseList(raw string) []string {
+	var items []string
+	for _, part := range strings.Split(raw, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			items = append(items, part)
+		}
+	}
+	return items
+}
+
+// parseTrustedProxies parses a comma-separated list of IPs and CIDRs.
+func parseTrustedProxies(raw string) ([]string, error) {
+	proxies := parseList(raw)
+	for _, proxy := range proxies {
+		if strings.Contains(proxy, "/") {
+			if _, _, err := net.ParseCIDR(proxy); err != nil {
+				return nil, fmt.Errorf("invalid PDFTOOL_TRUSTED_PROXIES entry: %q", proxy)
+			}
+		} else if net.ParseIP(proxy) == nil {
+			return nil, fmt.Errorf("invalid PDFTOOL_TRUSTED_PROXIES entry: %q", proxy)
+		}
+	}
+	return proxies, nil
+}
+
+// unknownProvider reports a per-provider setting naming a provider type that
+// is not registered; silently treating it as openai would apply the setting
+// to the wrong provider.
+func unknownProvider(env, name string) error {
+	var names []string
+	for _, provider := range translator.Providers() {
+		names = append(names, string(provider))
+	}
+	return fmt.Errorf("%s 中的模型类型 %q 未注册，可用类型: %s", env, name, strings.Join(names, ", "))
+}
+
+// parseProviderWorkers parses "openai=8,gemini=4,anthropic=2".
+func parseProviderWorkers(raw string) (map[string]int, error) {
+	limits := make(map[string]int)
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(part, "=")
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		if !ok || err != nil || n < 0 || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("invalid PDFTOOL_PROVIDER_WORKERS entry: %q", part)
+		}
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !translator.Registered(name) {
+			return nil, unknownProvider("PDFTOOL_PROVIDER_WORKERS", name)
+		}
+		limits[name] = n
+	}
+	return limits, nil
+}
+
+// parseProviderImageTypes parses "anthropic:image/png,image/jpeg;gemini:image/png".
+func parseProviderImageTypes(raw string) (map[string][]string, error) {
+	types := make(map[string][]string)
+	for _, part := range strings.Split(raw, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, list, ok := strings.Cut(part, ":")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid PDFTOOL_PROVIDER_IMAGE_TYPES entry: %q", part)
+		}
+		if !translator.Registered(name) {
+			return nil, unknownProvider("PDFTOOL_PROVIDER_IMAGE_TYPES", name)
+		}
+		for _, typ := range strings.Split(list, ",") {
+			typ = strings.ToLower(strings.TrimSpace(typ))
+			if !strings.HasPrefix(typ, "image/") || len(typ) == len("image/") {
+				return nil, fmt.Errorf("invalid PDFTOOL_PROVIDER_IMAGE_TYPES entry: %q", part)
+			}
+			types[name] = append(types[name], typ)
+		}
+	}
+	return types, nil
+}
+
+// parsePDFFonts parses "ja=/fonts/NotoSansJP.ttf,ar=/fonts/NotoNaskhArabic.ttf".
+func parsePDFFonts(raw string) (map[string]string, error) {
+	fonts := make(map[string]string)
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		lang, path, ok := strings.Cut(part, "=")
+		lang = strings.ToLower(strings.TrimSpace(lang))
+		path = strings.TrimSpace(path)
+		if !ok || lang == "" || path == "" {
+			return nil, fmt.Errorf("invalid PDFTOOL_FONTS entry: %q", part)
+		}
+		fonts[lang] = path
+	}
+	return fonts, nil
+}
+
+// parseProviderTimeouts parses "gemini:request=600,connect=5;openai:page=900"
+// with values in seconds.
+func parseProviderTimeouts(raw string) (map[string]Timeouts, error) {
+	overrides := make(map[string]Timeouts)
+	for _, part := range strings.Split(raw, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, fields, ok := strings.Cut(part, ":")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid PDFTOOL_PROVIDER_TIMEOUTS entry: %q", part)
+		}
+		if !translator.Registered(name) {
+			return nil, unknownProvider("PDFTOOL_PROVIDER_TIMEOUTS", name)
+		}
+		timeouts := overrides[name]
+		for _, field := range strings.Split(fields, ",") {
+			key, value, ok := strings.Cut(strings.TrimSpace(field), "=")
+			seconds, err := strconv.Atoi(strings.TrimSpace(value))
+			if !ok || err != nil || seconds < 0 {
+				return nil, fmt.Errorf("invalid PDFTOOL_PROVIDER_TIMEOUTS entry: %q", part)
+			}
+			d := time.Duration(seconds) * time.Second
+			switch strings.ToLower(strings.TrimSpace(key)) {
+			case "connect":
+				timeouts.Connect = d
+			case "request":
+				timeouts.Request = d
+			case "page":
+				timeouts.PageBudget = d
+			default:
+				return nil, fmt.Errorf("invalid PDFTOOL_PROVIDER_TIMEOUTS entry: %q", part)
+			}
+		}
+		overrides[name] = timeouts
+	}
+	return overrides, nil
+}
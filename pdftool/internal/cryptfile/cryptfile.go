@@ -0,0 +1,456 @@
+// Package cryptfile stores files encrypted with AES-256-GCM. Files are split
+// into 64 KiB segments sealed independently, so they can be written as a
+// stream and read back with random access (HTTP range requests) without
+// decrypting the whole file. Files without the format header are read as
+// plaintext, which keeps data written before encryption was enabled usable.
+package cryptfile
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	magic       = "PDTENC1\n"
+	prefixSize  = 8
+	headerSize  = len(magic) + prefixSize
+	segmentSize = 64 << 10
+	tagSize     = 16
+	sealedSize  = segmentSize + tagSize
+)
+
+// ErrNoKey is returned when opening an encrypted file without a key.
+var ErrNoKey = errors.New("文件已加密，但未配置存储密钥")
+
+// Key is an AES-256 key.
+type Key [32]byte
+
+// ParseKey decodes a 32-byte key given as base64 or hex.
+func ParseKey(raw string) (*Key, error) {
+	raw = strings.TrimSpace(raw)
+	var data []byte
+	if decoded, err := hex.DecodeString(raw); err == nil && len(decoded) == len(Key{}) {
+		data = decoded
+	} else if decoded, err := base64.StdEncoding.DecodeString(raw); err == nil {
+		data = decoded
+	}
+	if len(data) != len(Key{}) {
+		return nil, fmt.Errorf("storage key must be 32 bytes encoded as base64 or hex")
+	}
+	var key Key
+	copy(key[:], data)
+	return &key, nil
+}
+
+// NewKey returns a random key.
+func NewKey() (*Key, error) {
+	var key Key
+	if _, err := rand.Read(key[:]); err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (k *Key) aead() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(k[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// nonce derives a segment nonce from the file's random prefix and the index.
+func nonce(prefix []byte, index uint32) []byte {
+	n := make([]byte, 12)
+	copy(n, prefix)
+	binary.BigEndian.PutUint32(n[prefixSize:], index)
+	return n
+}
+
+// additionalData binds the final flag into each segment so a truncated file
+// fails to decrypt instead of silently losing its tail.
+func additionalData(final bool) []byte {
+	if final {
+		return []byte{1}
+	}
+	return []byte{0}
+}
+
+// IsEncrypted reports whether data starts with the format header.
+func IsEncrypted(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(magic))
+}
+
+// IsEncryptedFile reports whether the file at path is stored encrypted.
+func IsEncryptedFile(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	header := make([]byte, headerSize)
+	n, _ := f.ReadAt(header, 0)
+	return IsEncrypted(header[:n])
+}
+
+// PlainSize returns the plaintext length of an encrypted file of the given size.
+func PlainSize(size int64) int64 {
+	body := size - int64(headerSize)
+	if body <= 0 {
+		return 0
+	}
+	segments := (body + sealedSize - 1) / sealedSize
+	if plain := body - segments*tagSize; plain > 0 {
+		return plain
+	}
+	return 0
+}
+
+// Encrypt streams src to dst in the encrypted format.
+func Encrypt(key *Key, dst io.Writer, src io.Reader) error {
+	aead, err := key.aead()
+	if err != nil {
+		return err
+	}
+	prefix := make([]byte, prefixSize)
+	if _, err := rand.Read(prefix); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(dst, magic); err != nil {
+		return err
+	}
+	if _, err := dst.Write(prefix); err != nil {
+		return err
+	}
+	// Read one segment ahead so the last one can be flagged final.
+	cur := make([]byte, segmentSize)
+	next := make([]byte, segmentSize)
+	n, err := readSegment(src, cur)
+	if err != nil {
+		return err
+	}
+	sealed := make([]byte, 0, sealedSize)
+	for index := uint32(0); ; index++ {
+		m := 0
+		if n == segmentSize {
+			if m, err = readSegment(src, next); err != nil {
+				return err
+			}
+		}
+		final := m == 0
+		sealed = aead.Seal(sealed[:0], nonce(prefix, index), cur[:n], additionalData(final))
+		if _, err := dst.Write(sealed); err != nil {
+			return err
+		}
+		if final {
+			return nil
+		}
+		cur, next, n = next, cur, m
+	}
+}
+
+func readSegment(r io.Reader, buf []byte) (int, error) {
+	n, err := io.ReadFull(r, buf)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		err = nil
+	}
+	return n, err
+}
+
+// Reader decrypts an encrypted file with random access.
+type Reader struct {
+	aead     cipher.AEAD
+	src      io.ReaderAt
+	prefix   []byte
+	size     int64
+	plain    int64
+	pos      int64
+	segment  int64
+	buf      []byte
+	sealed   []byte
+	verified bool
+}
+
+// NewReader returns a reader over the encrypted file src of the given size.
+func NewReader(key *Key, src io.ReaderAt, size int64) (*Reader, error) {
+	aead, err := key.aead()
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, headerSize)
+	if _, err := src.ReadAt(header, 0); err != nil {
+		return nil, fmt.Errorf("read encryption header: %w", err)
+	}
+	if !IsEncrypted(header) {
+		return nil, fmt.Errorf("not an encrypted file")
+	}
+	return &Reader{
+		aead:    aead,
+		src:     src,
+		prefix:  header[len(magic):],
+		size:    size,
+		plain:   PlainSize(size),
+		segment: -1,
+		sealed:  make([]byte, sealedSize),
+	}, nil
+}
+
+// Size returns the plaintext length.
+func (r *Reader) Size() int64 {
+	return r.plain
+}
+
+func (r *Reader) load(index int64) error {
+	if index == r.segment {
+		return nil
+	}
+	offset := int64(headerSize) + index*sealedSize
+	length := r.size - offset
+	if length > sealedSize {
+		length = sealedSize
+	}
+	if length < tagSize {
+		return io.ErrUnexpectedEOF
+	}
+	sealed := r.sealed[:length]
+	if _, err := r.src.ReadAt(sealed, offset); err != nil && err != io.EOF {
+		return err
+	}
+	final := offset+length == r.size
+	plain, err := r.aead.Open(r.buf[:0], nonce(r.prefix, uint32(index)), sealed, additionalData(final))
+	if err != nil {
+		r.segment = -1
+		return fmt.Errorf("decrypt segment %d: %w", index, err)
+	}
+	r.buf, r.segment = plain, index
+	return nil
+}
+
+func (r *Reader) lastSegment() int64 {
+	body := r.size - int64(headerSize)
+	if body <= sealedSize {
+		return 0
+	}
+	return (body+sealedSize-1)/sealedSize - 1
+}
+
+// Read implements io.Reader.
+func (r *Reader) Read(p []byte) (int, error) {
+	if r.pos >= r.plain {
+		// Reaching the end authenticates the final segment, so files cut at a
+		// segment boundary are detected too.
+		if !r.verified {
+			if err := r.load(r.lastSegment()); err != nil {
+				return 0, err
+			}
+			r.verified = true
+		}
+		return 0, io.EOF
+	}
+	if err := r.load(r.pos / segmentSize); err != nil {
+		return 0, err
+	}
+	n := copy(p, r.buf[r.pos%segmentSize:])
+	r.pos += int64(n)
+	return n, nil
+}
+
+// Seek implements io.Seeker over the plaintext.
+func (r *Reader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.pos
+	case io.SeekEnd:
+		offset += r.plain
+	default:
+		return 0, fmt.Errorf("invalid whence %d", whence)
+	}
+	if offset < 0 {
+		return 0, fmt.Errorf("negative position")
+	}
+	r.pos = offset
+	return offset, nil
+}
+
+// File is a stored file opened for reading, decrypted when needed.
+type File struct {
+	io.ReadSeeker
+	file    *os.File
+	size    int64
+	modTime time.Time
+	name    string
+}
+
+// Seal returns data in the encrypted format, or data itself when key is nil.
+func Seal(key *Key, data []byte) ([]byte, error) {
+	if key == nil {
+		return data, nil
+	}
+	var sealed bytes.Buffer
+	if err := Encrypt(key, &sealed, bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+	return sealed.Bytes(), nil
+}
+
+// Unseal returns the plaintext of data, which may be stored unencrypted.
+func Unseal(key *Key, data []byte) ([]byte, error) {
+	if !IsEncrypted(data) {
+		return data, nil
+	}
+	if key == nil {
+		return nil, ErrNoKey
+	}
+	r, err := NewReader(key, bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+// Open opens path, decrypting it with key when it is encrypted. key may be
+// nil for plaintext files.
+func Open(key *Key, path string) (*File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if info.IsDir() {
+		f.Close()
+		return nil, fmt.Errorf("%s is a directory", path)
+	}
+	file := &File{ReadSeeker: f, file: f, size: info.Size(), modTime: info.ModTime(), name: info.Name()}
+	header := make([]byte, headerSize)
+	if n, _ := f.ReadAt(header, 0); !IsEncrypted(header[:n]) {
+		return file, nil
+	}
+	if key == nil {
+		f.Close()
+		return nil, ErrNoKey
+	}
+	r, err := NewReader(key, f, info.Size())
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	file.ReadSeeker, file.size = r, r.Size()
+	return file, nil
+}
+
+// Size returns the plaintext length.
+func (f *File) Size() int64 { return f.size }
+
+// ModTime returns the file's modification time.
+func (f *File) ModTime() time.Time { return f.modTime }
+
+// Name returns the base name of the file.
+func (f *File) Name() string { return f.name }
+
+// Close closes the underlying file.
+func (f *File) Close() error { return f.file.Close() }
+
+// ReadFile returns the plaintext of path.
+func ReadFile(key *Key, path string) ([]byte, error) {
+	f, err := Open(key, path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+// WriteFile writes data to path, encrypted when key is set.
+func WriteFile(key *Key, path string, data []byte, perm os.FileMode) error {
+	if key == nil {
+		return os.WriteFile(path, data, perm)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if err := Encrypt(key, f, bytes.NewReader(data)); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// EncryptFile encrypts a plaintext file in place, keeping its mode and
+// modification time. Already encrypted files are left alone.
+func EncryptFile(key *Key, path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+	header := make([]byte, headerSize)
+	if n, _ := src.ReadAt(header, 0); IsEncrypted(header[:n]) {
+		return nil
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".enc-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := Encrypt(key, tmp, src); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(info.Mode().Perm()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chtimes(tmp.Name(), info.ModTime(), info.ModTime()); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// DecryptToTemp writes the plaintext of path to a new file in dir (the
+// system temp dir when empty) with the same extension and returns its path.
+func DecryptToTemp(key *Key, path, dir string) (string, error) {
+	src, err := Open(key, path)
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+	tmp, err := os.CreateTemp(dir, "pdftool-*"+filepath.Ext(path))
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(tmp, src); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return tmp.Name(), nil
+}
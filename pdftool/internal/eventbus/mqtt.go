@@ -0,0 +1,134 @@
+package eventbus
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"time"
+)
+
+const dialTimeout = 10 * time.Second
+
+// mqttTransport speaks just enough MQTT 3.1.1 for QoS 0 publishing.
+type mqttTransport struct {
+	addr     string
+	user     string
+	password string
+	hasUser  bool
+	hasPass  bool
+	clientID string
+	conn     net.Conn
+}
+
+func newMQTTTransport(raw string) *mqttTransport {
+	t := &mqttTransport{clientID: fmt.Sprintf("pdftool-%d", os.Getpid())}
+	if u, err := url.Parse(raw); err == nil && u.Host != "" {
+		t.addr = u.Host
+		if u.User != nil {
+			t.user = u.User.Username()
+			t.hasUser = t.user != ""
+			t.password, t.hasPass = u.User.Password()
+		}
+	} else {
+		t.addr = raw
+	}
+	if _, _, err := net.SplitHostPort(t.addr); err != nil {
+		t.addr = net.JoinHostPort(t.addr, "1883")
+	}
+	return t
+}
+
+func (t *mqttTransport) name() string { return "mqtt" }
+
+func (t *mqttTransport) connect() error {
+	conn, err := net.DialTimeout("tcp", t.addr, dialTimeout)
+	if err != nil {
+		return err
+	}
+	var body bytes.Buffer
+	writeMQTTString(&body, "MQTT")
+	body.WriteByte(4) // protocol level 3.1.1
+	flags := byte(0x02)
+	if t.hasUser {
+		flags |= 0x80
+	}
+	if t.hasPass {
+		flags |= 0x40
+	}
+	body.WriteByte(flags)
+	binary.Write(&body, binary.BigEndian, uint16(0)) // keep-alive disabled
+	writeMQTTString(&body, t.clientID)
+	if t.hasUser {
+		writeMQTTString(&body, t.user)
+	}
+	if t.hasPass {
+		writeMQTTString(&body, t.password)
+	}
+	conn.SetDeadline(time.Now().Add(dialTimeout))
+	if _, err := conn.Write(mqttPacket(0x10, body.Bytes())); err != nil {
+		conn.Close()
+		return err
+	}
+	ack := make([]byte, 4)
+	if _, err := io.ReadFull(conn, ack); err != nil {
+		conn.Close()
+		return fmt.Errorf("读取 CONNACK 失败: %w", err)
+	}
+	if ack[0] != 0x20 || ack[3] != 0 {
+		conn.Close()
+		return fmt.Errorf("MQTT 连接被拒绝 (code %d)", ack[3])
+	}
+	conn.SetDeadline(time.Time{})
+	t.conn = conn
+	return nil
+}
+
+func (t *mqttTransport) publish(topic string, payload []byte) error {
+	if t.conn == nil {
+		if err := t.connect(); err != nil {
+			return err
+		}
+	}
+	var body bytes.Buffer
+	writeMQTTString(&body, topic)
+	body.Write(payload)
+	t.conn.SetWriteDeadline(time.Now().Add(dialTimeout))
+	_, err := t.conn.Write(mqttPacket(0x30, body.Bytes()))
+	return err
+}
+
+func (t *mqttTransport) close() {
+	if t.conn != nil {
+		t.conn.Write([]byte{0xe0, 0x00}) // DISCONNECT
+		t.conn.Close()
+		t.conn = nil
+	}
+}
+
+func mqttPacket(header byte, body []byte) []byte {
+	var buf bytes.Buffer
+	buf.WriteByte(header)
+	length := len(body)
+	for {
+		b := byte(length % 128)
+		length /= 128
+		if length > 0 {
+			b |= 0x80
+		}
+		buf.WriteByte(b)
+		if length == 0 {
+			break
+		}
+	}
+	buf.Write(body)
+	return buf.Bytes()
+}
+
+func writeMQTTString(buf *bytes.Buffer, s string) {
+	binary.Write(buf, binary.BigEndian, uint16(len(s)))
+	buf.WriteString(s)
+}
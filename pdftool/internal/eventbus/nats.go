@@ -0,0 +1,116 @@
+package eventbus
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// natsTransport implements the NATS text protocol for fire-and-forget PUB.
+type natsTransport struct {
+	addr     string
+	user     string
+	password string
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+func newNATSTransport(raw string) *natsTransport {
+	t := &natsTransport{}
+	if u, err := url.Parse(raw); err == nil && u.Host != "" {
+		t.addr = u.Host
+		if u.User != nil {
+			t.user = u.User.Username()
+			t.password, _ = u.User.Password()
+		}
+	} else {
+		t.addr = raw
+	}
+	if _, _, err := net.SplitHostPort(t.addr); err != nil {
+		t.addr = net.JoinHostPort(t.addr, "4222")
+	}
+	return t
+}
+
+func (t *natsTransport) name() string { return "nats" }
+
+func (t *natsTransport) connect() error {
+	conn, err := net.DialTimeout("tcp", t.addr, dialTimeout)
+	if err != nil {
+		return err
+	}
+	reader := bufio.NewReader(conn)
+	conn.SetReadDeadline(time.Now().Add(dialTimeout))
+	info, err := reader.ReadString('\n')
+	if err != nil || !strings.HasPrefix(info, "INFO") {
+		conn.Close()
+		return fmt.Errorf("读取 NATS INFO 失败: %v", err)
+	}
+	conn.SetReadDeadline(time.Time{})
+	opts := map[string]interface{}{"verbose": false, "pedantic": false, "name": "pdftool"}
+	if t.user != "" {
+		opts["user"] = t.user
+		opts["pass"] = t.password
+	}
+	connectJSON, _ := json.Marshal(opts)
+	if _, err := fmt.Fprintf(conn, "CONNECT %s\r\n", connectJSON); err != nil {
+		conn.Close()
+		return err
+	}
+	t.conn = conn
+	go t.readLoop(conn, reader)
+	return nil
+}
+
+// readLoop answers server PINGs so the connection is not considered stale.
+func (t *natsTransport) readLoop(conn net.Conn, reader *bufio.Reader) {
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		switch {
+		case strings.HasPrefix(line, "PING"):
+			t.mu.Lock()
+			conn.Write([]byte("PONG\r\n"))
+			t.mu.Unlock()
+		case strings.HasPrefix(line, "-ERR"):
+			t.mu.Lock()
+			if t.conn == conn {
+				t.conn = nil
+			}
+			t.mu.Unlock()
+			conn.Close()
+			return
+		}
+	}
+}
+
+func (t *natsTransport) publish(subject string, payload []byte) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.conn == nil {
+		if err := t.connect(); err != nil {
+			return err
+		}
+	}
+	t.conn.SetWriteDeadline(time.Now().Add(dialTimeout))
+	msg := fmt.Sprintf("PUB %s %d\r\n%s\r\n", subject, len(payload), payload)
+	_, err := t.conn.Write([]byte(msg))
+	return err
+}
+
+func (t *natsTransport) close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.conn != nil {
+		t.conn.Close()
+		t.conn = nil
+	}
+}
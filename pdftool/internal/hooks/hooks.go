@@ -0,0 +1,142 @@
+// Package hooks lets external code transform documents around translation:
+// the uploaded PDF before its pages are rendered, each page image before it
+// is sent to the provider, and each page's text after translation. Hooks are
+// Go functions registered on a Chain, or webhook URLs (see AddWebhooks).
+package hooks
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Hook stages.
+const (
+	StagePreRender     = "pre-render"
+	StagePreTranslate  = "pre-translate"
+	StagePostTranslate = "post-translate"
+)
+
+// Info identifies what a hook runs for. PageNumber is zero before rendering.
+type Info struct {
+	Stage      string
+	TaskID     string
+	PageNumber int
+}
+
+// Text is a page's recognized and translated text.
+type Text struct {
+	SourceText  string
+	Translation string
+}
+
+// FileFunc transforms the file at path in place.
+type FileFunc func(ctx context.Context, info Info, path string) error
+
+// TextFunc post-processes a page's text in place.
+type TextFunc func(ctx context.Context, info Info, text *Text) error
+
+// Chain runs the registered hooks of each stage in registration order. A nil
+// Chain runs nothing. Register hooks before the chain is in use.
+type Chain struct {
+	preRender     []FileFunc
+	preTranslate  []FileFunc
+	postTranslate []TextFunc
+}
+
+// New returns an empty chain.
+func New() *Chain {
+	return &Chain{}
+}
+
+// OnPreRender registers fn to rewrite the uploaded PDF before rendering, e.g.
+// to stamp a watermark that then appears on every page image and export.
+func (c *Chain) OnPreRender(fn FileFunc) {
+	c.preRender = append(c.preRender, fn)
+}
+
+// OnPreTranslate registers fn to rewrite the image sent to the provider, e.g.
+// to remove stamps. The stored page image is left untouched.
+func (c *Chain) OnPreTranslate(fn FileFunc) {
+	c.preTranslate = append(c.preTranslate, fn)
+}
+
+// OnPostTranslate registers fn to clean up a page's text before it is stored.
+func (c *Chain) OnPostTranslate(fn TextFunc) {
+	c.postTranslate = append(c.postTranslate, fn)
+}
+
+// Empty reports whether no hooks are registered.
+func (c *Chain) Empty() bool {
+	return c == nil || len(c.preRender)+len(c.preTranslate)+len(c.postTranslate) == 0
+}
+
+// PreRender runs the pre-render hooks on the PDF at path.
+func (c *Chain) PreRender(ctx context.Context, taskID, path string) error {
+	if c == nil {
+		return nil
+	}
+	info := Info{Stage: StagePreRender, TaskID: taskID}
+	for _, fn := range c.preRender {
+		if err := fn(ctx, info, path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PreTranslate runs the pre-translate hooks on a copy of the page image and
+// returns the path to translate with a cleanup func removing the copy. Without
+// hooks it returns imagePath itself.
+func (c *Chain) PreTranslate(ctx context.Context, taskID string, pageNumber int, imagePath string) (string, func(), error) {
+	if c == nil || len(c.preTranslate) == 0 {
+		return imagePath, func() {}, nil
+	}
+	ext := filepath.Ext(imagePath)
+	work := strings.TrimSuffix(imagePath, ext) + ".hook" + ext
+	cleanup := func() { os.Remove(work) }
+	if err := copyFile(imagePath, work); err != nil {
+		return "", cleanup, err
+	}
+	info := Info{Stage: StagePreTranslate, TaskID: taskID, PageNumber: pageNumber}
+	for _, fn := range c.preTranslate {
+		if err := fn(ctx, info, work); err != nil {
+			return "", cleanup, err
+		}
+	}
+	return work, cleanup, nil
+}
+
+// PostTranslate runs the post-translate hooks on text.
+func (c *Chain) PostTranslate(ctx context.Context, taskID string, pageNumber int, text *Text) error {
+	if c == nil {
+		return nil
+	}
+	info := Info{Stage: StagePostTranslate, TaskID: taskID, PageNumber: pageNumber}
+	for _, fn := range c.postTranslate {
+		if err := fn(ctx, info, text); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("open %s: %w", src, err)
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return fmt.Errorf("create %s: %w", dst, err)
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return fmt.Errorf("copy %s: %w", src, err)
+	}
+	return out.Close()
+}
@@ -0,0 +1,151 @@
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultWebhookTimeout = 30 * time.Second
+
+// WebhookConfig lists hook URLs; empty URLs register nothing.
+//
+// File stages POST the file as the request body with X-Pdftool-Stage,
+// X-Pdftool-Task and X-Pdftool-Page headers; a 200 response body replaces the
+// file and 204 keeps it. The post-translate stage POSTs the page's text as
+// JSON ({stage, taskId, pageNumber, sourceText, translation}); a 200 JSON
+// response replaces the fields it contains and 204 keeps the text.
+type WebhookConfig struct {
+	PreRenderURL     string
+	PreTranslateURL  string
+	PostTranslateURL string
+	Timeout          time.Duration
+}
+
+// AddWebhooks registers the configured webhook URLs on c.
+func (c *Chain) AddWebhooks(cfg WebhookConfig) {
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultWebhookTimeout
+	}
+	client := &http.Client{Timeout: timeout}
+	if url := strings.TrimSpace(cfg.PreRenderURL); url != "" {
+		c.OnPreRender(fileWebhook(client, url))
+	}
+	if url := strings.TrimSpace(cfg.PreTranslateURL); url != "" {
+		c.OnPreTranslate(fileWebhook(client, url))
+	}
+	if url := strings.TrimSpace(cfg.PostTranslateURL); url != "" {
+		c.OnPostTranslate(textWebhook(client, url))
+	}
+}
+
+func fileWebhook(client *http.Client, url string) FileFunc {
+	return func(ctx context.Context, info Info, path string) error {
+		in, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, in)
+		if err != nil {
+			return err
+		}
+		contentType := mime.TypeByExtension(filepath.Ext(path))
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		req.Header.Set("Content-Type", contentType)
+		setHookHeaders(req, info)
+		resp, err := client.Do(req)
+		if err != nil {
+			return fmt.Errorf("%s 钩子调用失败: %w", info.Stage, err)
+		}
+		defer resp.Body.Close()
+		switch resp.StatusCode {
+		case http.StatusNoContent:
+			return nil
+		case http.StatusOK:
+		default:
+			return fmt.Errorf("%s 钩子返回 %s", info.Stage, resp.Status)
+		}
+		tmp := path + ".tmp"
+		out, err := os.Create(tmp)
+		if err != nil {
+			return err
+		}
+		n, err := io.Copy(out, resp.Body)
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
+		}
+		if err == nil && n == 0 {
+			err = fmt.Errorf("%s 钩子返回空内容", info.Stage)
+		}
+		if err != nil {
+			os.Remove(tmp)
+			return err
+		}
+		return os.Rename(tmp, path)
+	}
+}
+
+func textWebhook(client *http.Client, url string) TextFunc {
+	return func(ctx context.Context, info Info, text *Text) error {
+		body, _ := json.Marshal(map[string]any{
+			"stage":       info.Stage,
+			"taskId":      info.TaskID,
+			"pageNumber":  info.PageNumber,
+			"sourceText":  text.SourceText,
+			"translation": text.Translation,
+		})
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		setHookHeaders(req, info)
+		resp, err := client.Do(req)
+		if err != nil {
+			return fmt.Errorf("%s 钩子调用失败: %w", info.Stage, err)
+		}
+		defer resp.Body.Close()
+		switch resp.StatusCode {
+		case http.StatusNoContent:
+			return nil
+		case http.StatusOK:
+		default:
+			return fmt.Errorf("%s 钩子返回 %s", info.Stage, resp.Status)
+		}
+		var reply struct {
+			SourceText  *string `json:"sourceText"`
+			Translation *string `json:"translation"`
+		}
+		if err := json.NewDecoder(io.LimitReader(resp.Body, 16<<20)).Decode(&reply); err != nil {
+			return fmt.Errorf("解析 %s 钩子响应失败: %w", info.Stage, err)
+		}
+		if reply.SourceText != nil {
+			text.SourceText = *reply.SourceText
+		}
+		if reply.Translation != nil {
+			text.Translation = *reply.Translation
+		}
+		return nil
+	}
+}
+
+func setHookHeaders(req *http.Request, info Info) {
+	req.Header.Set("X-Pdftool-Stage", info.Stage)
+	req.Header.Set("X-Pdftool-Task", info.TaskID)
+	if info.PageNumber > 0 {
+		req.Header.Set("X-Pdftool-Page", strconv.Itoa(info.PageNumber))
+	}
+}
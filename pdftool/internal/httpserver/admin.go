@@ -0,0 +1,62 @@
+package httpserver
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"pdftool/internal/model"
+	"pdftool/internal/service"
+)
+
+const adminTokenHeader = "X-Admin-Token"
+
+// requireAdmin admits requests carrying PDFTOOL_ADMIN_TOKEN as a bearer token
+// or in X-Admin-Token. Without a configured token the admin API is disabled.
+func (s *Server) requireAdmin(c *gin.Context) {
+	if s.cfg.AdminToken == "" {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "管理接口未启用，请配置 PDFTOOL_ADMIN_TOKEN"})
+		return
+	}
+	token := strings.TrimSpace(c.GetHeader(adminTokenHeader))
+	if token == "" {
+		token = strings.TrimSpace(strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer "))
+	}
+	if subtle.ConstantTimeCompare([]byte(token), []byte(s.cfg.AdminToken)) != 1 {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "管理令牌无效"})
+		return
+	}
+	c.Set(adminContextKey, true)
+	c.Next()
+}
+
+func (s *Server) handleGetPrompts(c *gin.Context) {
+	c.JSON(http.StatusOK, s.taskSvc.PromptSettings())
+}
+
+func (s *Server) handleSavePrompts(c *gin.Context) {
+	var req model.PromptSettings
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求体格式错误"})
+		return
+	}
+	resp, err := s.taskSvc.SavePrompts(req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	s.audit(c, service.AuditPromptsUpdate, "", nil)
+	c.JSON(http.StatusOK, resp)
+}
+
+func (s *Server) handleResetPrompts(c *gin.Context) {
+	resp, err := s.taskSvc.ResetPrompts()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	s.audit(c, service.AuditPromptsReset, "", nil)
+	c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,98 @@
+package httpserver
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"pdftool/internal/service"
+)
+
+// authQueryParam carries the API token for clients that cannot set headers,
+// such as EventSource and <img> links.
+const authQueryParam = "api_key"
+
+// redactedLogFormatter is gin's default access log line with the values of
+// token query parameters replaced, so API and task tokens passed in links
+// never reach the log.
+func redactedLogFormatter(param gin.LogFormatterParams) string {
+	var statusColor, methodColor, resetColor string
+	if param.IsOutputColor() {
+		statusColor = param.StatusCodeColor()
+		methodColor = param.MethodColor()
+		resetColor = param.ResetColor()
+	}
+	if param.Latency > time.Minute {
+		param.Latency = param.Latency.Truncate(time.Second)
+	}
+	return fmt.Sprintf("[GIN] %v |%s %3d %s| %13v | %15s |%s %-7s %s %#v\n%s",
+		param.TimeStamp.Format("2006/01/02 - 15:04:05"),
+		statusColor, param.StatusCode, resetColor,
+		param.Latency,
+		param.ClientIP,
+		methodColor, param.Method, resetColor,
+		redactTokenQuery(param.Path),
+		param.ErrorMessage,
+	)
+}
+
+// redactTokenQuery replaces the values of the api_key and token query
+// parameters in a request path, keeping the other parameters as sent.
+func redactTokenQuery(p string) string {
+	base, query, ok := strings.Cut(p, "?")
+	if !ok {
+		return p
+	}
+	params := strings.Split(query, "&")
+	for i, param := range params {
+		key, _, _ := strings.Cut(param, "=")
+		if name, err := url.QueryUnescape(key); err == nil && (name == authQueryParam || name == taskTokenQueryParam) {
+			params[i] = key + "=REDACTED"
+		}
+	}
+	return base + "?" + strings.Join(params, "&")
+}
+
+// requireAuth admits requests carrying one of PDFTOOL_AUTH_TOKENS as a
+// bearer token, in X-API-Key or in ?api_key=, or an X-API-Key assigned to a
+// user in the quotas file. Without configured tokens every request passes.
+func (s *Server) requireAuth(c *gin.Context) {
+	if !s.authenticated(c) {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "缺少或无效的访问令牌"})
+		return
+	}
+	c.Next()
+}
+
+func (s *Server) authenticated(c *gin.Context) bool {
+	if len(s.cfg.AuthTokens) == 0 {
+		return true
+	}
+	key := strings.TrimSpace(c.GetHeader(apiKeyHeader))
+	for _, token := range []string{
+		strings.TrimSpace(strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")),
+		key,
+		strings.TrimSpace(c.Query(authQueryParam)),
+	} {
+		if token != "" && s.validAuthToken(token) {
+			return true
+		}
+	}
+	user := s.taskSvc.ResolveAPIKey(key)
+	return key != "" && user != "" && user != service.AnonymousUser
+}
+
+// validAuthToken compares token against every configured token in constant
+// time.
+func (s *Server) validAuthToken(token string) bool {
+	valid := 0
+	for _, want := range s.cfg.AuthTokens {
+		valid |= subtle.ConstantTimeCompare([]byte(token), []byte(want))
+	}
+	return valid == 1
+}
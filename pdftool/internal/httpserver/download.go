@@ -0,0 +1,149 @@
+package httpserver
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"pdftool/internal/service"
+)
+
+// compressibleExts are sent gzip-compressed to clients that accept it.
+var compressibleExts = map[string]bool{
+	".txt":  true,
+	".md":   true,
+	".json": true,
+	".tex":  true,
+	".rtf":  true,
+	".html": true,
+}
+
+// taskTokenQueryParam carries a task access or share token on static file
+// links; taskTokenHeader is the alternative for clients that can set headers.
+const (
+	taskTokenQueryParam = "token"
+	taskTokenHeader     = "X-Task-Token"
+)
+
+// handleStaticFile serves the files of live tasks below the storage dir.
+// Root-level files, dot directories, directory listings and a task's
+// metadata, key, access tokens and event feed are not served. In token
+// access mode, or when API tokens are configured and the request carries
+// none, a file is only served with an access token of the task owning it,
+// or with its share token for the files the share link shows. Downloads of
+// a task's exports and source file are audited; page images are not.
+func (s *Server) handleStaticFile(c *gin.Context) {
+	rel := path.Clean("/" + c.Param("filepath"))
+	taskID, ok := s.taskSvc.StaticTaskFile(rel)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "文件不存在"})
+		return
+	}
+	// Without an API token, a task access token still opens its own files
+	// and a share token the files of its share link.
+	if s.cfg.StaticAccess == "token" || !s.authenticated(c) {
+		token := c.Query(taskTokenQueryParam)
+		if token == "" {
+			token = c.GetHeader(taskTokenHeader)
+		}
+		if !s.taskSvc.CheckAccessToken(taskID, token) && !s.taskSvc.CheckShareFile(taskID, token, rel) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "缺少或无效的任务访问令牌"})
+			return
+		}
+	}
+	full := filepath.Join(s.cfg.StorageDir, filepath.FromSlash(rel))
+	if dir, name := path.Split(strings.TrimPrefix(rel, "/")); dir != "" && strings.Count(dir, "/") == 1 &&
+		c.Request.Method == http.MethodGet && isFirstRange(c.Request) && fileExists(full) {
+		s.audit(c, service.AuditFileDownload, strings.TrimSuffix(dir, "/"), map[string]string{"file": name})
+	}
+	s.serveDownload(c, full, "")
+}
+
+// serveDownload streams the file at full with Range, conditional request and
+// HEAD support, so large combined texts never have to be buffered and clients
+// can read the size up front. Whole-file GETs of text formats are gzipped on
+// the fly when accepted; range requests always address the stored bytes.
+// Files encrypted at rest are decrypted while streaming. attachment, when
+// set, is the download file name.
+func (s *Server) serveDownload(c *gin.Context, full, attachment string) {
+	f, err := s.taskSvc.OpenStoredFile(full)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("open %s failed: %v", full, err)
+		}
+		c.JSON(http.StatusNotFound, gin.H{"error": "文件不存在"})
+		return
+	}
+	defer f.Close()
+	if attachment != "" {
+		c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": attachment}))
+	}
+	etag := fmt.Sprintf(`"%x-%x"`, f.ModTime().UnixNano(), f.Size())
+	ext := strings.ToLower(filepath.Ext(full))
+	if !compressibleExts[ext] {
+		c.Header("ETag", etag)
+		http.ServeContent(c.Writer, c.Request, f.Name(), f.ModTime(), f)
+		return
+	}
+	c.Header("Vary", "Accept-Encoding")
+	if !acceptsGzip(c.Request) {
+		c.Header("ETag", etag)
+		http.ServeContent(c.Writer, c.Request, f.Name(), f.ModTime(), f)
+		return
+	}
+	gzipTag := strings.TrimSuffix(etag, `"`) + `-gz"`
+	c.Header("ETag", gzipTag)
+	c.Header("Last-Modified", f.ModTime().UTC().Format(http.TimeFormat))
+	if c.GetHeader("If-None-Match") == gzipTag {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	contentType := mime.TypeByExtension(ext)
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Encoding", "gzip")
+	c.Status(http.StatusOK)
+	gz := gzip.NewWriter(c.Writer)
+	if _, err := io.Copy(gz, f); err != nil {
+		log.Printf("stream %s failed: %v", full, err)
+	}
+	gz.Close()
+}
+
+// acceptsGzip reports whether the request is a whole-file GET from a client
+// that accepts gzip.
+func acceptsGzip(r *http.Request) bool {
+	if r.Method != http.MethodGet || r.Header.Get("Range") != "" {
+		return false
+	}
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if strings.EqualFold(strings.TrimSpace(name), "gzip") && strings.ReplaceAll(strings.TrimSpace(params), " ", "") != "q=0" {
+			return true
+		}
+	}
+	return false
+}
+
+// isFirstRange reports whether a request fetches a file from its start, so
+// a download resumed or streamed in ranges is audited once.
+func isFirstRange(r *http.Request) bool {
+	header := strings.TrimSpace(r.Header.Get("Range"))
+	return header == "" || strings.HasPrefix(header, "bytes=0-")
+}
+
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
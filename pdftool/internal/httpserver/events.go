@@ -0,0 +1,124 @@
+package httpserver
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+
+	"pdftool/internal/eventbus"
+)
+
+const eventsHeartbeat = 15 * time.Second
+
+// handleTaskEvents serves the task's event feed: page status changes, AI
+// formatting progress and generated exports, numbered by seq. Clients asking
+// for text/event-stream (EventSource) get a live stream; other requests get
+// the recorded events after ?since= as JSON.
+func (s *Server) handleTaskEvents(c *gin.Context) {
+	if strings.Contains(c.GetHeader("Accept"), "text/event-stream") {
+		s.streamTaskEvents(c)
+		return
+	}
+	since, err := parseEventSeq(c.Query("since"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "since 参数格式错误"})
+		return
+	}
+	events, more, err := s.taskSvc.EventsSince(c.Param("taskID"), since)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	last := since
+	if len(events) > 0 {
+		last = events[len(events)-1].Seq
+	}
+	c.JSON(http.StatusOK, gin.H{"events": events, "lastSeq": last, "more": more})
+}
+
+// streamTaskEvents streams the task's events as Server-Sent Events with the
+// sequence number as event ID. A fresh stream opens with a "snapshot" event
+// holding the same body as GET /tasks/:taskID; a reconnect carrying
+// Last-Event-ID (or ?since=) replays the events missed instead.
+func (s *Server) streamTaskEvents(c *gin.Context) {
+	taskID := c.Param("taskID")
+	raw := c.GetHeader("Last-Event-ID")
+	if raw == "" {
+		raw = c.Query("since")
+	}
+	since, err := parseEventSeq(raw)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "since 参数格式错误"})
+		return
+	}
+	// Subscribe first so nothing published while the snapshot or the missed
+	// events load is lost.
+	events, cancel := s.taskSvc.SubscribeEvents(taskID)
+	defer cancel()
+	task, err := s.taskSvc.GetTask(taskID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	var missed []eventbus.Event
+	if raw != "" {
+		// Replay is bounded; a client that is further behind reconnects again.
+		if missed, _, err = s.taskSvc.EventsSince(taskID, since); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	if raw == "" {
+		c.SSEvent("snapshot", s.taskSvc.ToResponse(task))
+	}
+	last := since
+	send := func(event eventbus.Event) {
+		if event.Seq != 0 && event.Seq <= last {
+			return
+		}
+		msg := sse.Event{Event: string(event.Kind), Data: event}
+		if event.Seq != 0 {
+			msg.Id = strconv.FormatInt(event.Seq, 10)
+			last = event.Seq
+		}
+		c.Render(-1, msg)
+	}
+	for _, event := range missed {
+		send(event)
+	}
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(eventsHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case event := <-events:
+			send(event)
+		case <-heartbeat.C:
+			io.WriteString(c.Writer, ": ping\n\n")
+		}
+		c.Writer.Flush()
+	}
+}
+
+func parseEventSeq(raw string) (int64, error) {
+	if raw = strings.TrimSpace(raw); raw == "" {
+		return 0, nil
+	}
+	seq, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || seq < 0 {
+		return 0, strconv.ErrSyntax
+	}
+	return seq, nil
+}
@@ -0,0 +1,121 @@
+package httpserver
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"pdftool/internal/service"
+)
+
+const idempotencyHeader = "Idempotency-Key"
+
+// bodyRecorder tees the response body so it can be stored for replays.
+type bodyRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *bodyRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *bodyRecorder) WriteString(data string) (int, error) {
+	w.body.WriteString(data)
+	return w.ResponseWriter.WriteString(data)
+}
+
+// idempotency makes POST requests carrying an Idempotency-Key safe to retry:
+// the first successful response is stored and replayed for the same key, so
+// a retried upload or export does not create a second task or spend tokens twice.
+// Keys are scoped to the calling client, and reusing one with a different
+// method, path or body is rejected with 422.
+func (s *Server) idempotency() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := strings.TrimSpace(c.GetHeader(idempotencyHeader))
+		if key == "" || c.Request.Method != http.MethodPost {
+			c.Next()
+			return
+		}
+		bodyHash, cleanup, err := spoolBody(c.Request)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "读取请求体失败: " + err.Error()})
+			return
+		}
+		defer cleanup()
+		stored, release, err := s.taskSvc.BeginIdempotent(c.Request.Context(), key, service.IdempotentRequest{
+			Method:   c.Request.Method,
+			Path:     c.Request.URL.Path,
+			BodyHash: bodyHash,
+		})
+		if err != nil {
+			status := http.StatusBadRequest
+			switch {
+			case errors.Is(err, service.ErrIdempotencyInFlight):
+				status = http.StatusConflict
+			case errors.Is(err, service.ErrIdempotencyMismatch):
+				status = http.StatusUnprocessableEntity
+			}
+			c.AbortWithStatusJSON(status, gin.H{"error": err.Error()})
+			return
+		}
+		if stored != nil {
+			c.Header("Idempotent-Replayed", "true")
+			c.Data(stored.Status, stored.ContentType, stored.Body)
+			c.Abort()
+			return
+		}
+		// Only successful responses are remembered; failures (and panics) free
+		// the key so the client can retry.
+		var result *service.IdempotentResponse
+		defer func() { release(result) }()
+		recorder := &bodyRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+		status := recorder.Status()
+		if status < 200 || status >= 300 {
+			return
+		}
+		result = &service.IdempotentResponse{
+			Status:      status,
+			ContentType: recorder.Header().Get("Content-Type"),
+			Body:        recorder.body.Bytes(),
+		}
+	}
+}
+
+// spoolBody copies the request body to a temp file while hashing it and
+// replaces the body with the file, so uploads are not held in memory.
+// cleanup removes the file.
+func spoolBody(r *http.Request) (string, func(), error) {
+	file, err := os.CreateTemp("", "pdftool-idem-*")
+	if err != nil {
+		return "", func() {}, err
+	}
+	cleanup := func() {
+		file.Close()
+		os.Remove(file.Name())
+	}
+	hash := sha256.New()
+	if r.Body != nil {
+		_, err = io.Copy(io.MultiWriter(file, hash), r.Body)
+		r.Body.Close()
+	}
+	if err == nil {
+		_, err = file.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		cleanup()
+		return "", func() {}, err
+	}
+	r.Body = io.NopCloser(file)
+	return hex.EncodeToString(hash.Sum(nil)), cleanup, nil
+}
@@ -0,0 +1,93 @@
+package httpserver
+
+import (
+	"net/http"
+	"path"
+	"path/filepath"
+
+	"github.com/gin-gonic/gin"
+
+	"pdftool/internal/model"
+	"pdftool/internal/service"
+)
+
+func (s *Server) handleListProjects(c *gin.Context) {
+	projects, err := s.taskSvc.ListProjects()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"projects": projects})
+}
+
+func (s *Server) handleGetProject(c *gin.Context) {
+	project, err := s.taskSvc.GetProject(c.Param("projectID"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, project)
+}
+
+func (s *Server) handleCreateProject(c *gin.Context) {
+	var req model.Project
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求体格式错误"})
+		return
+	}
+	project, err := s.taskSvc.CreateProject(req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	s.audit(c, service.AuditProjectSave, "", map[string]string{"project": project.ID, "name": project.Name})
+	c.JSON(http.StatusOK, project)
+}
+
+func (s *Server) handleUpdateProject(c *gin.Context) {
+	var req model.Project
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求体格式错误"})
+		return
+	}
+	project, err := s.taskSvc.UpdateProject(c.Param("projectID"), req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	s.audit(c, service.AuditProjectSave, "", map[string]string{"project": project.ID, "name": project.Name})
+	c.JSON(http.StatusOK, project)
+}
+
+func (s *Server) handleDeleteProject(c *gin.Context) {
+	if err := s.taskSvc.DeleteProject(c.Param("projectID")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	s.audit(c, service.AuditProjectDelete, "", map[string]string{"project": c.Param("projectID")})
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+func (s *Server) handleExportProject(c *gin.Context) {
+	project, name, err := s.taskSvc.ExportProject(c.Request.Context(), c.Param("projectID"), c.Param("format"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"project": project,
+		"url":     path.Join("/api/pdf/projects", project.ID, "exports", name),
+	})
+}
+
+func (s *Server) handleDownloadProjectExport(c *gin.Context) {
+	full, err := s.taskSvc.ProjectExportFile(c.Param("projectID"), c.Param("name"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if c.Request.Method == http.MethodGet {
+		s.audit(c, service.AuditExportDownload, "", map[string]string{"project": c.Param("projectID"), "export": c.Param("name")})
+	}
+	s.serveDownload(c, full, filepath.Base(full))
+}
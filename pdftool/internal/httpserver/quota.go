@@ -0,0 +1,51 @@
+package httpserver
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"pdftool/internal/service"
+)
+
+const apiKeyHeader = "X-API-Key"
+
+// identify attaches the user owning the request's API key to its context so
+// the task service can attribute and limit usage per user. Requests are also
+// tagged with a client identity, the known user or else the caller's IP,
+// which the per-client task limit counts against.
+func (s *Server) identify(c *gin.Context) {
+	ctx := c.Request.Context()
+	client := "ip:" + c.ClientIP()
+	if user := s.taskSvc.ResolveAPIKey(c.GetHeader(apiKeyHeader)); user != "" {
+		ctx = service.WithPrincipal(ctx, user)
+		if user != service.AnonymousUser {
+			client = "user:" + user
+		}
+	}
+	c.Request = c.Request.WithContext(service.WithClient(ctx, client))
+	c.Next()
+}
+
+func (s *Server) handleGetQuota(c *gin.Context) {
+	status := s.taskSvc.QuotaStatus(service.PrincipalFrom(c.Request.Context()))
+	if status == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "未启用用户配额，请配置 PDFTOOL_QUOTAS_FILE"})
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+func (s *Server) handleListQuotas(c *gin.Context) {
+	if user := strings.TrimSpace(c.Query("user")); user != "" {
+		status := s.taskSvc.QuotaStatus(user)
+		if status == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "未启用用户配额，请配置 PDFTOOL_QUOTAS_FILE"})
+			return
+		}
+		c.JSON(http.StatusOK, status)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"quotas": s.taskSvc.QuotaStatuses()})
+}
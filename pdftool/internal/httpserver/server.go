@@ -1,18 +1,27 @@
 package httpserver
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"path"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 
 	"pdftool/internal/config"
+	"pdftool/internal/locale"
+	"pdftool/internal/metrics"
+	"pdftool/internal/model"
+	"pdftool/internal/ocrimport"
+	"pdftool/internal/pdfutil"
 	"pdftool/internal/service"
 	"pdftool/internal/translator"
 )
@@ -29,34 +38,119 @@ func New(cfg config.Config, taskSvc *service.TaskService) *Server {
 	gin.SetMode(gin.ReleaseMode)
 
 	router := gin.New()
-	router.Use(gin.Logger(), gin.Recovery())
+	router.Use(gin.LoggerWithConfig(gin.LoggerConfig{Formatter: redactedLogFormatter}), gin.Recovery())
 	router.MaxMultipartMemory = 128 << 20 // 128MB
+	// Only configured proxies may set the client IP through forwarding
+	// headers; otherwise anyone could pick the identity the per-client
+	// limits count against.
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Printf("invalid trusted proxies, trusting none: %v", err)
+		_ = router.SetTrustedProxies(nil)
+	}
 
 	corsCfg := cors.DefaultConfig()
 	corsCfg.AllowAllOrigins = true
-	corsCfg.AllowHeaders = []string{"Origin", "Content-Type", "Authorization"}
+	corsCfg.AllowHeaders = []string{"Origin", "Content-Type", "Authorization", "Range", idempotencyHeader, adminTokenHeader, apiKeyHeader, taskTokenHeader}
+	corsCfg.ExposeHeaders = []string{"Content-Length", "Content-Range", "Accept-Ranges", "Content-Disposition"}
 	router.Use(cors.New(corsCfg))
 
-	router.StaticFS(cfg.StaticPrefix, http.Dir(cfg.StorageDir))
-
 	s := &Server{
 		cfg:     cfg,
 		engine:  router,
 		taskSvc: taskSvc,
 	}
+	staticPattern := strings.TrimRight(cfg.StaticPrefix, "/") + "/*filepath"
+	router.GET(staticPattern, s.handleStaticFile)
+	router.HEAD(staticPattern, s.handleStaticFile)
+	router.GET("/metrics", s.handleMetrics)
+	router.GET("/healthz", s.handleHealth)
 
-	api := router.Group("/api/pdf")
+	// Share links are public; everything else below /api/pdf needs an API
+	// token once PDFTOOL_AUTH_TOKENS is set. The admin API has its own token.
+	router.GET("/api/pdf/shared/:token", s.identify, s.handleGetSharedTask)
+
+	api := router.Group("/api/pdf", s.requireAuth, s.identify, s.idempotency())
 	{
 		api.GET("/tasks", s.handleListTasks)
 		api.POST("/tasks", s.handleCreateTask)
+		api.POST("/tasks/import", s.handleImportTask)
+		api.POST("/tasks/batch", s.handleCreateBatch)
+		api.GET("/batches/:batchID", s.handleGetBatch)
+		api.GET("/splits/:splitID", s.handleGetSplit)
+		api.POST("/splits/:splitID/export", s.handleExportSplit)
+		api.GET("/sources", s.handleListSources)
 		api.GET("/tasks/:taskID", s.handleGetTask)
+		api.GET("/tasks/:taskID/events", s.handleTaskEvents)
 		api.DELETE("/tasks/:taskID", s.handleDeleteTask)
+		api.POST("/tasks/:taskID/restore", s.handleRestoreTask)
+		api.GET("/trash", s.handleListTrash)
+		api.DELETE("/trash", s.handlePurgeTrash)
+		api.DELETE("/trash/:taskID", s.handlePurgeTrash)
 		api.POST("/tasks/:taskID/pages/:pageNumber/retranslate", s.handleRetranslatePage)
+		api.POST("/tasks/:taskID/retranslate", s.handleRetranslatePages)
+		api.POST("/tasks/:taskID/retranslate-failed", s.handleRetranslateFailed)
+		api.GET("/jobs/:jobID", s.handleGetJob)
+		api.POST("/tasks/:taskID/pages/:pageNumber/compare", s.handleComparePage)
+		api.GET("/tasks/:taskID/pages/:pageNumber/regions", s.handlePageRegions)
+		api.GET("/tasks/:taskID/pages/:pageNumber/image", s.handlePageImage)
+		api.POST("/tasks/:taskID/pages/:pageNumber/rerender", s.handleRerenderPage)
+		api.GET("/tasks/:taskID/image-check", s.handleCheckPageImages)
+		api.PUT("/tasks/:taskID/pages/:pageNumber/review", s.handleSetPageReview)
+		api.GET("/tasks/:taskID/pages/:pageNumber/diff", s.handlePageDiff)
+		api.POST("/tasks/:taskID/pages/:pageNumber/revert", s.handleRevertPage)
+		api.POST("/tasks/:taskID/resume", s.handleResumeTask)
+		api.POST("/tasks/:taskID/cancel", s.handleCancelTask)
+		api.POST("/tasks/:taskID/start", s.handleStartTask)
 		api.POST("/tasks/:taskID/layout", s.handleFormatTaskLayout)
+		api.POST("/tasks/:taskID/consistency", s.handleConsistencyPass)
+		api.POST("/tasks/:taskID/ocr", s.handleImportOCR)
+		api.POST("/tasks/:taskID/export", s.handleExportPreferred)
 		api.POST("/tasks/:taskID/export/txt", s.handleExportTxt)
 		api.POST("/tasks/:taskID/export/pdf", s.handleExportPdf)
+		api.POST("/tasks/:taskID/export/md", s.handleExportMarkdown)
+		api.POST("/tasks/:taskID/export/docx", s.handleExportDocx)
+		api.POST("/tasks/:taskID/export/epub", s.handleExportEpub)
+		api.POST("/tasks/:taskID/export/pandoc", s.handleExportPandoc)
+		api.POST("/tasks/:taskID/export/contact-sheet", s.handleExportContactSheet)
+		api.GET("/tasks/:taskID/exports/:name", s.handleDownloadExport)
+		api.HEAD("/tasks/:taskID/exports/:name", s.handleDownloadExport)
+		api.POST("/exports/combined", s.handleExportCombined)
+		api.GET("/exports/combined/:exportID/:name", s.handleDownloadCombinedExport)
+		api.HEAD("/exports/combined/:exportID/:name", s.handleDownloadCombinedExport)
+		api.GET("/export-formats", s.handleListExportFormats)
+		api.GET("/profiles", s.handleListProfiles)
+		api.PUT("/profiles/:name", s.handleSaveProfile)
+		api.DELETE("/profiles/:name", s.handleDeleteProfile)
+		api.GET("/projects", s.handleListProjects)
+		api.POST("/projects", s.handleCreateProject)
+		api.GET("/projects/:projectID", s.handleGetProject)
+		api.PUT("/projects/:projectID", s.handleUpdateProject)
+		api.DELETE("/projects/:projectID", s.handleDeleteProject)
+		api.POST("/projects/:projectID/export/:format", s.handleExportProject)
+		api.GET("/projects/:projectID/exports/:name", s.handleDownloadProjectExport)
+		api.HEAD("/projects/:projectID/exports/:name", s.handleDownloadProjectExport)
 		api.POST("/providers/test", s.handleTestProvider)
 		api.POST("/providers/models", s.handleFetchProviderModels)
+		api.GET("/budget", s.handleGetBudget)
+		api.GET("/quota", s.handleGetQuota)
+		api.GET("/providers/stats", s.handleProviderStats)
+		api.PUT("/tasks/:taskID/destination", s.handleSetDestination)
+		api.PUT("/tasks/:taskID/metadata", s.handleUpdateMetadata)
+		api.PUT("/tasks/:taskID/export-settings", s.handleSetExportSettings)
+		api.POST("/tasks/:taskID/share", s.handleCreateShare)
+		api.DELETE("/tasks/:taskID/share", s.handleRevokeShare)
+		api.GET("/tasks/:taskID/tokens", s.handleListAccessTokens)
+		api.POST("/tasks/:taskID/tokens", s.handleCreateAccessToken)
+		api.DELETE("/tasks/:taskID/tokens/:tokenID", s.handleRevokeAccessToken)
+	}
+
+	admin := router.Group("/api/pdf/admin", s.requireAdmin)
+	{
+		admin.GET("/prompts", s.handleGetPrompts)
+		admin.PUT("/prompts", s.handleSavePrompts)
+		admin.DELETE("/prompts", s.handleResetPrompts)
+		admin.GET("/quotas", s.handleListQuotas)
+		admin.GET("/audit", s.handleAuditLog)
 	}
 
 	return s
@@ -99,24 +193,252 @@ func (s *Server) handleCreateTask(c *gin.Context) {
 	}
 
 	settings := service.TranslationSettings{
-		RangeMode:   strings.TrimSpace(c.PostForm("initial_range_mode")),
-		RangeCustom: parseOptionalInt(c.PostForm("initial_range_custom")),
-		RangeStart:  parseOptionalInt(c.PostForm("initial_range_start")),
-		RangeEnd:    parseOptionalInt(c.PostForm("initial_range_end")),
-		BatchLimit:  parseOptionalInt(c.PostForm("initial_batch_limit")),
+		RangeMode:    strings.TrimSpace(c.PostForm("initial_range_mode")),
+		RangeCustom:  parseOptionalInt(c.PostForm("initial_range_custom")),
+		RangeStart:   parseOptionalInt(c.PostForm("initial_range_start")),
+		RangeEnd:     parseOptionalInt(c.PostForm("initial_range_end")),
+		RangePages:   strings.TrimSpace(c.PostForm("initial_range_pages")),
+		RangeExclude: strings.TrimSpace(c.PostForm("initial_range_exclude")),
+		BatchLimit:   parseOptionalInt(c.PostForm("initial_batch_limit")),
+
+		OutputDestination: strings.TrimSpace(c.PostForm("output_destination")),
+		LayoutMode:        strings.TrimSpace(c.PostForm("layout_mode")),
+		WritingMode:       strings.TrimSpace(c.PostForm("writing_mode")),
+		TargetLanguage:    strings.TrimSpace(c.PostForm("target_language")),
+		DryRun:            parseOptionalBool(c.PostForm("dry_run")),
+		Sample:            parseOptionalInt(c.PostForm("sample")),
+		PreferTextLayer:   parseOptionalBool(c.PostForm("prefer_text_layer")),
+		ExtractFigures:    parseOptionalBool(c.PostForm("extract_figures")),
+		Profile:           strings.TrimSpace(c.PostForm("profile")),
+		Project:           strings.TrimSpace(c.PostForm("project")),
+		Render: pdfutil.RenderOptions{
+			DPI:          parseOptionalInt(c.PostForm("render_dpi")),
+			Format:       strings.TrimSpace(c.PostForm("render_format")),
+			JPEGQuality:  parseOptionalInt(c.PostForm("render_jpeg_quality")),
+			MaxDimension: parseOptionalInt(c.PostForm("render_max_dimension")),
+		},
 	}
 	if settings.BatchLimit < 0 {
 		settings.BatchLimit = 0
 	}
 
+	if parseOptionalBool(c.PostForm("split_chapters")) {
+		report, err := s.taskSvc.CreateSplitTasks(c.Request.Context(), file, fileHeader.Filename, provider, settings)
+		if err != nil {
+			c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusAccepted, report)
+		return
+	}
 	task, err := s.taskSvc.CreateTask(c.Request.Context(), file, fileHeader.Filename, provider, settings)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, s.taskSvc.ToResponse(task))
+}
+
+func (s *Server) handleImportTask(c *gin.Context) {
+	var req struct {
+		Source              string `json:"source"`
+		Path                string `json:"path"`
+		WriteBack           bool   `json:"write_back"`
+		ProviderType        string `json:"provider_type"`
+		ProviderAPIType     string `json:"provider_api_type"`
+		ProviderBase        string `json:"provider_base"`
+		ProviderKey         string `json:"provider_key"`
+		ProviderModel       string `json:"provider_model"`
+		ProviderMaxTokens   int    `json:"provider_max_tokens"`
+		InitialRangeMode    string `json:"initial_range_mode"`
+		InitialRangeCustom  int    `json:"initial_range_custom"`
+		InitialRangeStart   int    `json:"initial_range_start"`
+		InitialRangeEnd     int    `json:"initial_range_end"`
+		InitialRangePages   string `json:"initial_range_pages"`
+		InitialRangeExclude string `json:"initial_range_exclude"`
+		InitialBatchLimit   int    `json:"initial_batch_limit"`
+		OutputDestination   string `json:"output_destination"`
+		LayoutMode          string `json:"layout_mode"`
+		WritingMode         string `json:"writing_mode"`
+		TargetLanguage      string `json:"target_language"`
+		DryRun              bool   `json:"dry_run"`
+		Sample              int    `json:"sample"`
+		PreferTextLayer     bool   `json:"prefer_text_layer"`
+		ExtractFigures      bool   `json:"extract_figures"`
+		RenderDPI           int    `json:"render_dpi"`
+		RenderFormat        string `json:"render_format"`
+		RenderJPEGQuality   int    `json:"render_jpeg_quality"`
+		RenderMaxDimension  int    `json:"render_max_dimension"`
+		Profile             string `json:"profile"`
+		Project             string `json:"project"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求体格式错误"})
+		return
+	}
+	apiType := req.ProviderAPIType
+	if strings.TrimSpace(apiType) == "" {
+		apiType = req.ProviderType
+	}
+	provider := translator.ProviderConfig{
+		Type:           translator.ProviderType(apiType),
+		BaseURL:        strings.TrimSpace(req.ProviderBase),
+		APIKey:         strings.TrimSpace(req.ProviderKey),
+		Model:          strings.TrimSpace(req.ProviderModel),
+		MaxTokens:      req.ProviderMaxTokens,
+		OptimizeLayout: true,
+	}
+	settings := service.TranslationSettings{
+		RangeMode:    strings.TrimSpace(req.InitialRangeMode),
+		RangeCustom:  req.InitialRangeCustom,
+		RangeStart:   req.InitialRangeStart,
+		RangeEnd:     req.InitialRangeEnd,
+		RangePages:   strings.TrimSpace(req.InitialRangePages),
+		RangeExclude: strings.TrimSpace(req.InitialRangeExclude),
+		BatchLimit:   req.InitialBatchLimit,
+
+		OutputDestination: strings.TrimSpace(req.OutputDestination),
+		LayoutMode:        strings.TrimSpace(req.LayoutMode),
+		WritingMode:       strings.TrimSpace(req.WritingMode),
+		TargetLanguage:    strings.TrimSpace(req.TargetLanguage),
+		DryRun:            req.DryRun,
+		Sample:            req.Sample,
+		PreferTextLayer:   req.PreferTextLayer,
+		ExtractFigures:    req.ExtractFigures,
+		Profile:           strings.TrimSpace(req.Profile),
+		Project:           strings.TrimSpace(req.Project),
+		Render: pdfutil.RenderOptions{
+			DPI:          req.RenderDPI,
+			Format:       strings.TrimSpace(req.RenderFormat),
+			JPEGQuality:  req.RenderJPEGQuality,
+			MaxDimension: req.RenderMaxDimension,
+		},
+	}
+	if settings.BatchLimit < 0 {
+		settings.BatchLimit = 0
+	}
+
+	task, err := s.taskSvc.CreateTaskFromSource(c.Request.Context(), req.Source, req.Path, req.WriteBack, provider, settings)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
 		return
 	}
 	c.JSON(http.StatusOK, s.taskSvc.ToResponse(task))
 }
 
+// handleCreateBatch accepts either a JSON body with a "urls" array or a plain-text
+// body with one URL per line (provider settings then come from the query string).
+func (s *Server) handleCreateBatch(c *gin.Context) {
+	var req struct {
+		URLs                []string `json:"urls"`
+		ProviderType        string   `json:"provider_type" form:"provider_type"`
+		ProviderAPIType     string   `json:"provider_api_type" form:"provider_api_type"`
+		ProviderBase        string   `json:"provider_base" form:"provider_base"`
+		ProviderKey         string   `json:"provider_key" form:"provider_key"`
+		ProviderModel       string   `json:"provider_model" form:"provider_model"`
+		ProviderMaxTokens   int      `json:"provider_max_tokens" form:"provider_max_tokens"`
+		InitialRangeMode    string   `json:"initial_range_mode" form:"initial_range_mode"`
+		InitialRangeCustom  int      `json:"initial_range_custom" form:"initial_range_custom"`
+		InitialRangeStart   int      `json:"initial_range_start" form:"initial_range_start"`
+		InitialRangeEnd     int      `json:"initial_range_end" form:"initial_range_end"`
+		InitialRangePages   string   `json:"initial_range_pages" form:"initial_range_pages"`
+		InitialRangeExclude string   `json:"initial_range_exclude" form:"initial_range_exclude"`
+		InitialBatchLimit   int      `json:"initial_batch_limit" form:"initial_batch_limit"`
+		OutputDestination   string   `json:"output_destination" form:"output_destination"`
+		LayoutMode          string   `json:"layout_mode" form:"layout_mode"`
+		WritingMode         string   `json:"writing_mode" form:"writing_mode"`
+		TargetLanguage      string   `json:"target_language" form:"target_language"`
+		DryRun              bool     `json:"dry_run" form:"dry_run"`
+		Sample              int      `json:"sample" form:"sample"`
+		PreferTextLayer     bool     `json:"prefer_text_layer" form:"prefer_text_layer"`
+		ExtractFigures      bool     `json:"extract_figures" form:"extract_figures"`
+		RenderDPI           int      `json:"render_dpi" form:"render_dpi"`
+		RenderFormat        string   `json:"render_format" form:"render_format"`
+		RenderJPEGQuality   int      `json:"render_jpeg_quality" form:"render_jpeg_quality"`
+		RenderMaxDimension  int      `json:"render_max_dimension" form:"render_max_dimension"`
+		Profile             string   `json:"profile" form:"profile"`
+		Project             string   `json:"project" form:"project"`
+	}
+	if strings.HasPrefix(c.ContentType(), "application/json") {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "请求体格式错误"})
+			return
+		}
+	} else {
+		if err := c.ShouldBindQuery(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数格式错误"})
+			return
+		}
+		body, err := io.ReadAll(io.LimitReader(c.Request.Body, 1<<20))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "读取请求体失败"})
+			return
+		}
+		req.URLs = strings.Split(string(body), "\n")
+	}
+	apiType := req.ProviderAPIType
+	if strings.TrimSpace(apiType) == "" {
+		apiType = req.ProviderType
+	}
+	provider := translator.ProviderConfig{
+		Type:           translator.ProviderType(apiType),
+		BaseURL:        strings.TrimSpace(req.ProviderBase),
+		APIKey:         strings.TrimSpace(req.ProviderKey),
+		Model:          strings.TrimSpace(req.ProviderModel),
+		MaxTokens:      req.ProviderMaxTokens,
+		OptimizeLayout: true,
+	}
+	settings := service.TranslationSettings{
+		RangeMode:    strings.TrimSpace(req.InitialRangeMode),
+		RangeCustom:  req.InitialRangeCustom,
+		RangeStart:   req.InitialRangeStart,
+		RangeEnd:     req.InitialRangeEnd,
+		RangePages:   strings.TrimSpace(req.InitialRangePages),
+		RangeExclude: strings.TrimSpace(req.InitialRangeExclude),
+		BatchLimit:   req.InitialBatchLimit,
+
+		OutputDestination: strings.TrimSpace(req.OutputDestination),
+		LayoutMode:        strings.TrimSpace(req.LayoutMode),
+		WritingMode:       strings.TrimSpace(req.WritingMode),
+		TargetLanguage:    strings.TrimSpace(req.TargetLanguage),
+		DryRun:            req.DryRun,
+		Sample:            req.Sample,
+		PreferTextLayer:   req.PreferTextLayer,
+		ExtractFigures:    req.ExtractFigures,
+		Profile:           strings.TrimSpace(req.Profile),
+		Project:           strings.TrimSpace(req.Project),
+		Render: pdfutil.RenderOptions{
+			DPI:          req.RenderDPI,
+			Format:       strings.TrimSpace(req.RenderFormat),
+			JPEGQuality:  req.RenderJPEGQuality,
+			MaxDimension: req.RenderMaxDimension,
+		},
+	}
+	if settings.BatchLimit < 0 {
+		settings.BatchLimit = 0
+	}
+
+	report, err := s.taskSvc.CreateBatch(c.Request.Context(), req.URLs, provider, settings)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusAccepted, report)
+}
+
+func (s *Server) handleGetBatch(c *gin.Context) {
+	report, err := s.taskSvc.GetBatch(c.Param("batchID"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+func (s *Server) handleListSources(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"sources": s.taskSvc.CloudSources()})
+}
+
 func (s *Server) handleListTasks(c *gin.Context) {
 	tasks, err := s.taskSvc.ListTasks()
 	if err != nil {
@@ -133,7 +455,12 @@ func (s *Server) handleGetTask(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, s.taskSvc.ToResponse(task))
+	resp := s.taskSvc.ToResponse(task)
+	if err := service.FilterPagesByReview(resp, c.Query("review")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, resp)
 }
 
 func (s *Server) handleDeleteTask(c *gin.Context) {
@@ -142,6 +469,45 @@ func (s *Server) handleDeleteTask(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	s.audit(c, service.AuditTaskDelete, taskID, nil)
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+func (s *Server) handleRestoreTask(c *gin.Context) {
+	task, err := s.taskSvc.RestoreTask(c.Param("taskID"))
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, service.ErrTaskNotInTrash) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	s.audit(c, service.AuditTaskRestore, task.ID, nil)
+	c.JSON(http.StatusOK, s.taskSvc.ToResponse(task))
+}
+
+func (s *Server) handleListTrash(c *gin.Context) {
+	entries, err := s.taskSvc.ListTrash()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"tasks": entries})
+}
+
+// handlePurgeTrash permanently deletes one trashed task, or all of them
+// when no task ID is given.
+func (s *Server) handlePurgeTrash(c *gin.Context) {
+	if err := s.taskSvc.PurgeTrash(c.Param("taskID")); err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, service.ErrTaskNotInTrash) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	s.audit(c, service.AuditTrashPurge, c.Param("taskID"), nil)
 	c.JSON(http.StatusOK, gin.H{"success": true})
 }
 
@@ -177,145 +543,865 @@ func (s *Server) handleRetranslatePage(c *gin.Context) {
 		OptimizeLayout: true,
 	}
 
+	// async=true runs the page as a background job, for slow models behind
+	// proxies with short timeouts; the default reply stays the updated task.
+	if parseOptionalBool(c.Query("async")) {
+		job, err := s.taskSvc.RetranslatePageAsync(taskID, pageNumber, provider)
+		if err != nil {
+			c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+			return
+		}
+		s.auditProviderChange(c, taskID, "retranslate", strconv.Itoa(pageNumber), provider)
+		c.JSON(http.StatusAccepted, gin.H{"jobId": job.ID, "job": job})
+		return
+	}
 	task, _, err := s.taskSvc.RetranslatePage(c.Request.Context(), taskID, pageNumber, provider)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
 		return
 	}
+	s.auditProviderChange(c, taskID, "retranslate", strconv.Itoa(pageNumber), provider)
 	c.JSON(http.StatusOK, s.taskSvc.ToResponse(task))
 }
 
-func (s *Server) handleFormatTaskLayout(c *gin.Context) {
-	taskID := c.Param("taskID")
+func (s *Server) handleGetJob(c *gin.Context) {
+	job, err := s.taskSvc.GetJob(c.Param("jobID"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+// handleRetranslatePages re-queues a page list such as "1-3,7,20-25".
+func (s *Server) handleRetranslatePages(c *gin.Context) {
 	var req struct {
-		ProviderType      string `json:"provider_type"`
-		ProviderAPIType   string `json:"provider_api_type"`
-		ProviderBase      string `json:"provider_base"`
-		ProviderKey       string `json:"provider_key"`
-		ProviderModel     string `json:"provider_model"`
-		ProviderMaxTokens int    `json:"provider_max_tokens"`
+		providerRequest
+		Pages string `json:"pages"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求体格式错误"})
+		return
+	}
+	task, err := s.taskSvc.RetranslatePages(c.Request.Context(), c.Param("taskID"), req.Pages, req.config())
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
 	}
+	s.auditProviderChange(c, task.ID, "retranslate", req.Pages, req.config())
+	c.JSON(http.StatusAccepted, s.taskSvc.ToResponse(task))
+}
+
+// handleRetranslateFailed re-queues all pages whose translation failed.
+func (s *Server) handleRetranslateFailed(c *gin.Context) {
+	var req providerRequest
 	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "请求体格式错误"})
 		return
 	}
-	apiType := req.ProviderAPIType
+	task, err := s.taskSvc.RetranslateFailed(c.Request.Context(), c.Param("taskID"), req.config())
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+	s.auditProviderChange(c, task.ID, "retranslate-failed", "", req.config())
+	c.JSON(http.StatusAccepted, s.taskSvc.ToResponse(task))
+}
+
+type providerRequest struct {
+	ProviderType      string `json:"provider_type"`
+	ProviderAPIType   string `json:"provider_api_type"`
+	ProviderBase      string `json:"provider_base"`
+	ProviderKey       string `json:"provider_key"`
+	ProviderModel     string `json:"provider_model"`
+	ProviderMaxTokens int    `json:"provider_max_tokens"`
+}
+
+func (r providerRequest) config() translator.ProviderConfig {
+	apiType := r.ProviderAPIType
 	if strings.TrimSpace(apiType) == "" {
-		apiType = req.ProviderType
+		apiType = r.ProviderType
 	}
-	provider := translator.ProviderConfig{
+	return translator.ProviderConfig{
 		Type:           translator.ProviderType(apiType),
-		BaseURL:        strings.TrimSpace(req.ProviderBase),
-		APIKey:         strings.TrimSpace(req.ProviderKey),
-		Model:          strings.TrimSpace(req.ProviderModel),
-		MaxTokens:      req.ProviderMaxTokens,
+		BaseURL:        strings.TrimSpace(r.ProviderBase),
+		APIKey:         strings.TrimSpace(r.ProviderKey),
+		Model:          strings.TrimSpace(r.ProviderModel),
+		MaxTokens:      r.ProviderMaxTokens,
 		OptimizeLayout: true,
 	}
-	task, url, err := s.taskSvc.FormatTaskLayout(c.Request.Context(), taskID, provider)
-	if err != nil {
-		log.Printf("format task %s failed: %v", taskID, err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-	c.JSON(http.StatusOK, gin.H{
-		"task": s.taskSvc.ToResponse(task),
-		"url":  url,
-	})
 }
 
-func (s *Server) handleExportTxt(c *gin.Context) {
-	taskID := c.Param("taskID")
-	variant := strings.ToLower(strings.TrimSpace(c.Query("variant")))
-	if variant == "" {
-		variant = "original"
+func (s *Server) handleComparePage(c *gin.Context) {
+	pageNumber, err := strconv.Atoi(c.Param("pageNumber"))
+	if err != nil || pageNumber <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "页码格式错误"})
+		return
 	}
-	if variant == "formatted" {
-		task, err := s.taskSvc.GetTask(taskID)
-		if err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
-			return
-		}
-		if !task.FormattedByAI || strings.TrimSpace(task.FormattedTxtURL) == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "尚未生成 AI 排版版本"})
-			return
-		}
-		c.JSON(http.StatusOK, gin.H{
-			"task": s.taskSvc.ToResponse(task),
-			"url":  task.FormattedTxtURL,
-		})
+	var req struct {
+		Providers []providerRequest `json:"providers"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求体格式错误"})
 		return
 	}
-	task, url, err := s.taskSvc.MergeText(taskID)
+	providers := make([]translator.ProviderConfig, 0, len(req.Providers))
+	for _, p := range req.Providers {
+		providers = append(providers, p.config())
+	}
+	resp, err := s.taskSvc.ComparePage(c.Request.Context(), c.Param("taskID"), pageNumber, providers)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{
-		"task": s.taskSvc.ToResponse(task),
-		"url":  url,
-	})
+	c.JSON(http.StatusOK, resp)
 }
 
-func (s *Server) handleExportPdf(c *gin.Context) {
-	taskID := c.Param("taskID")
-	task, url, err := s.taskSvc.MergePDF(taskID)
+func (s *Server) handlePageRegions(c *gin.Context) {
+	pageNumber, err := strconv.Atoi(c.Param("pageNumber"))
+	if err != nil || pageNumber <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "页码格式错误"})
+		return
+	}
+	resp, err := s.taskSvc.PageRegions(c.Param("taskID"), pageNumber)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{
-		"task": s.taskSvc.ToResponse(task),
-		"url":  url,
-	})
+	c.JSON(http.StatusOK, resp)
 }
 
-func (s *Server) handleTestProvider(c *gin.Context) {
+func (s *Server) handleSetPageReview(c *gin.Context) {
+	pageNumber, err := strconv.Atoi(c.Param("pageNumber"))
+	if err != nil || pageNumber <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "页码格式错误"})
+		return
+	}
 	var req struct {
-		Name    string `json:"name"`
-		Type    string `json:"type"`
-		BaseURL string `json:"baseUrl"`
-		APIKey  string `json:"apiKey"`
-		Model   string `json:"model"`
+		Flag string `json:"flag"`
+		Note string `json:"note"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "参数格式错误"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求体格式错误"})
 		return
 	}
-	if strings.TrimSpace(req.BaseURL) == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Base URL 不能为空"})
+	flag, err := service.ParseReviewFlag(req.Flag)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{
-		"success": true,
-		"message": "连接测试成功（模拟）",
-	})
+	task, err := s.taskSvc.SetPageReview(c.Param("taskID"), pageNumber, flag, req.Note)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, s.taskSvc.ToResponse(task))
 }
 
-func (s *Server) handleFetchProviderModels(c *gin.Context) {
-	var req struct {
-		Type string `json:"type"`
+func (s *Server) handlePageDiff(c *gin.Context) {
+	pageNumber, err := strconv.Atoi(c.Param("pageNumber"))
+	if err != nil || pageNumber <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "页码格式错误"})
+		return
 	}
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "参数格式错误"})
+	resp, err := s.taskSvc.PageDiff(c.Param("taskID"), pageNumber, c.Query("granularity"))
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, service.ErrNoPreviousTranslation) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
 		return
 	}
-	models := sampleModels(strings.ToLower(strings.TrimSpace(req.Type)))
-	c.JSON(http.StatusOK, gin.H{
-		"models": models,
-	})
+	c.JSON(http.StatusOK, resp)
 }
 
-func sampleModels(providerType string) []map[string]string {
-	switch providerType {
-	case "gemini":
-		return []map[string]string{
-			{"id": "gemini-1.5-flash", "name": "Gemini Flash", "apiType": "gemini"},
-			{"id": "gemini-1.5-pro", "name": "Gemini Pro", "apiType": "gemini"},
-		}
-	case "anthropic":
-		return []map[string]string{
-			{"id": "claude-3-5-sonnet", "name": "Claude 3.5 Sonnet", "apiType": "anthropic"},
-			{"id": "claude-3-opus", "name": "Claude 3 Opus", "apiType": "anthropic"},
+func (s *Server) handleRevertPage(c *gin.Context) {
+	pageNumber, err := strconv.Atoi(c.Param("pageNumber"))
+	if err != nil || pageNumber <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "页码格式错误"})
+		return
+	}
+	task, err := s.taskSvc.RevertPage(c.Param("taskID"), pageNumber)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, service.ErrNoPreviousTranslation) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	s.audit(c, service.AuditTranslationRevert, task.ID, pageNumberDetail(pageNumber))
+	c.JSON(http.StatusOK, s.taskSvc.ToResponse(task))
+}
+
+func (s *Server) handlePageImage(c *gin.Context) {
+	pageNumber, err := strconv.Atoi(c.Param("pageNumber"))
+	if err != nil || pageNumber <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "页码格式错误"})
+		return
+	}
+	path, err := s.taskSvc.PageImage(c.Param("taskID"), pageNumber, parseOptionalInt(c.Query("w")))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.Header("Cache-Control", "private, max-age=3600")
+	c.File(path)
+}
+
+// handleRerenderPage regenerates a page image from the source PDF, e.g. after
+// the image check reported it damaged. The page translation is kept.
+func (s *Server) handleRerenderPage(c *gin.Context) {
+	pageNumber, err := strconv.Atoi(c.Param("pageNumber"))
+	if err != nil || pageNumber <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "页码格式错误"})
+		return
+	}
+	task, err := s.taskSvc.RerenderPage(c.Request.Context(), c.Param("taskID"), pageNumber)
+	if err != nil {
+		status := http.StatusBadRequest
+		if service.IsStorageError(err) {
+			status = http.StatusInternalServerError
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, s.taskSvc.ToResponse(task))
+}
+
+// handleCheckPageImages lists the pages whose stored image is missing, empty
+// or undecodable.
+func (s *Server) handleCheckPageImages(c *gin.Context) {
+	problems, err := s.taskSvc.CheckPageImages(c.Request.Context(), c.Param("taskID"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"damaged": problems})
+}
+
+func (s *Server) handleResumeTask(c *gin.Context) {
+	var req providerRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求体格式错误"})
+		return
+	}
+
+	task, err := s.taskSvc.ResumeTask(c.Request.Context(), c.Param("taskID"), req.config())
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+	s.auditProviderChange(c, task.ID, "resume", "", req.config())
+	c.JSON(http.StatusOK, s.taskSvc.ToResponse(task))
+}
+
+func (s *Server) handleCancelTask(c *gin.Context) {
+	task, err := s.taskSvc.CancelTask(c.Param("taskID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, s.taskSvc.ToResponse(task))
+}
+
+func (s *Server) handleStartTask(c *gin.Context) {
+	var req providerRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求体格式错误"})
+		return
+	}
+
+	task, err := s.taskSvc.StartTask(c.Request.Context(), c.Param("taskID"), req.config())
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+	s.auditProviderChange(c, task.ID, "start", "", req.config())
+	c.JSON(http.StatusOK, s.taskSvc.ToResponse(task))
+}
+
+func (s *Server) handleImportOCR(c *gin.Context) {
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请上传 OCR 文件或 ZIP 包"})
+		return
+	}
+	if fileHeader.Size > ocrimport.MaxUploadBytes {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("文件过大，OCR 文件最多 %d MB", ocrimport.MaxUploadBytes>>20)})
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("读取上传文件失败: %v", err)})
+		return
+	}
+	data, err := io.ReadAll(file)
+	file.Close()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("读取上传文件失败: %v", err)})
+		return
+	}
+
+	var pages []ocrimport.PageText
+	if strings.HasSuffix(strings.ToLower(fileHeader.Filename), ".zip") {
+		pages, err = ocrimport.ParseZip(data)
+	} else {
+		var page ocrimport.PageText
+		page, err = ocrimport.Parse(fileHeader.Filename, data)
+		page.PageNumber = parseOptionalInt(c.PostForm("page"))
+		if page.PageNumber <= 0 {
+			page.PageNumber = ocrimport.PageNumberFromName(fileHeader.Filename)
+		}
+		if err == nil && page.PageNumber <= 0 {
+			err = fmt.Errorf("请通过 page 参数指定页码")
+		}
+		pages = []ocrimport.PageText{page}
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	apiType := c.PostForm("provider_api_type")
+	if strings.TrimSpace(apiType) == "" {
+		apiType = c.PostForm("provider_type")
+	}
+	provider := translator.ProviderConfig{
+		Type:           translator.ProviderType(apiType),
+		BaseURL:        strings.TrimSpace(c.PostForm("provider_base")),
+		APIKey:         strings.TrimSpace(c.PostForm("provider_key")),
+		Model:          strings.TrimSpace(c.PostForm("provider_model")),
+		MaxTokens:      parseOptionalInt(c.PostForm("provider_max_tokens")),
+		OptimizeLayout: true,
+	}
+	task, err := s.taskSvc.ImportOCR(c.Request.Context(), c.Param("taskID"), pages, provider)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+	numbers := make([]string, 0, len(pages))
+	for _, page := range pages {
+		numbers = append(numbers, strconv.Itoa(page.PageNumber))
+	}
+	s.audit(c, service.AuditTranslationImport, task.ID, map[string]string{"pages": strings.Join(numbers, ",")})
+	s.auditProviderChange(c, task.ID, "ocr_import", "", provider)
+	c.JSON(http.StatusOK, s.taskSvc.ToResponse(task))
+}
+
+func (s *Server) handleFormatTaskLayout(c *gin.Context) {
+	s.runTextPass(c, "format", s.taskSvc.FormatTaskLayout)
+}
+
+func (s *Server) handleConsistencyPass(c *gin.Context) {
+	s.runTextPass(c, "consistency pass of", s.taskSvc.ConsistencyPass)
+}
+
+// runTextPass runs an AI pass over the task's combined translation with the
+// provider given in the request body, which may override the task's.
+func (s *Server) runTextPass(c *gin.Context, name string, pass func(context.Context, string, translator.ProviderConfig) (*model.Task, string, error)) {
+	taskID := c.Param("taskID")
+	var req struct {
+		ProviderType      string `json:"provider_type"`
+		ProviderAPIType   string `json:"provider_api_type"`
+		ProviderBase      string `json:"provider_base"`
+		ProviderKey       string `json:"provider_key"`
+		ProviderModel     string `json:"provider_model"`
+		ProviderMaxTokens int    `json:"provider_max_tokens"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求体格式错误"})
+		return
+	}
+	apiType := req.ProviderAPIType
+	if strings.TrimSpace(apiType) == "" {
+		apiType = req.ProviderType
+	}
+	provider := translator.ProviderConfig{
+		Type:           translator.ProviderType(apiType),
+		BaseURL:        strings.TrimSpace(req.ProviderBase),
+		APIKey:         strings.TrimSpace(req.ProviderKey),
+		Model:          strings.TrimSpace(req.ProviderModel),
+		MaxTokens:      req.ProviderMaxTokens,
+		OptimizeLayout: true,
+	}
+	task, url, err := pass(c.Request.Context(), taskID, provider)
+	if err != nil {
+		log.Printf("%s task %s failed: %v", name, taskID, err)
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"task": s.taskSvc.ToResponse(task),
+		"url":  url,
+	})
+}
+
+func (s *Server) handleExportTxt(c *gin.Context) {
+	taskID := c.Param("taskID")
+	variant := strings.ToLower(strings.TrimSpace(c.Query("variant")))
+	if variant == "" {
+		variant = service.TxtVariantOriginal
+		if task, err := s.taskSvc.GetTask(taskID); err == nil {
+			variant = service.PreferredTxtVariant(task)
+		}
+	}
+	if variant == service.TxtVariantFormatted {
+		task, err := s.taskSvc.GetTask(taskID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if !task.FormattedByAI || strings.TrimSpace(task.FormattedTxtURL) == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "尚未生成 AI 排版版本"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"task": s.taskSvc.ToResponse(task),
+			"url":  task.FormattedTxtURL,
+		})
+		return
+	}
+	if variant == service.TxtVariantConsistent {
+		task, err := s.taskSvc.GetTask(taskID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if strings.TrimSpace(task.ConsistentTxtURL) == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "尚未生成一致性校对版本"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"task": s.taskSvc.ToResponse(task),
+			"url":  task.ConsistentTxtURL,
+		})
+		return
+	}
+	task, url, err := s.taskSvc.MergeText(c.Request.Context(), taskID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, s.exportResponse(task, service.ExportTxt, url))
+}
+
+// handleExportPdf generates the merged PDF in the request; async=true runs it
+// as a background job instead, since large tasks take longer than proxies allow.
+func (s *Server) handleExportPdf(c *gin.Context) {
+	taskID := c.Param("taskID")
+	if parseOptionalBool(c.Query("async")) {
+		job, err := s.taskSvc.MergePDFAsync(taskID, c.Query("layout"))
+		if err != nil {
+			c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusAccepted, gin.H{"jobId": job.ID, "job": job})
+		return
+	}
+	task, url, err := s.taskSvc.MergePDF(c.Request.Context(), taskID, c.Query("layout"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, s.exportResponse(task, service.ExportPDF, url))
+}
+
+func (s *Server) handleExportPreferred(c *gin.Context) {
+	task, urls, err := s.taskSvc.ExportPreferred(c.Request.Context(), c.Param("taskID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"task": s.taskSvc.ToResponse(task),
+		"urls": urls,
+	})
+}
+
+func (s *Server) handleExportMarkdown(c *gin.Context) {
+	task, url, err := s.taskSvc.MergeMarkdown(c.Request.Context(), c.Param("taskID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, s.exportResponse(task, service.ExportMarkdown, url))
+}
+
+func (s *Server) handleExportDocx(c *gin.Context) {
+	task, url, err := s.taskSvc.MergeDocx(c.Request.Context(), c.Param("taskID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, s.exportResponse(task, service.ExportDocx, url))
+}
+
+func (s *Server) handleExportEpub(c *gin.Context) {
+	task, url, err := s.taskSvc.MergeEpub(c.Request.Context(), c.Param("taskID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, s.exportResponse(task, service.ExportEPUB, url))
+}
+
+func (s *Server) handleExportPandoc(c *gin.Context) {
+	task, url, err := s.taskSvc.ExportPandoc(c.Request.Context(), c.Param("taskID"), c.Query("format"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, s.exportResponse(task, strings.ToLower(strings.TrimSpace(c.Query("format"))), url))
+}
+
+func (s *Server) handleExportContactSheet(c *gin.Context) {
+	task, url, err := s.taskSvc.ExportContactSheet(c.Request.Context(), c.Param("taskID"), parseOptionalInt(c.Query("per_page")))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"task": s.taskSvc.ToResponse(task),
+		"url":  url,
+	})
+}
+
+// exportResponse adds the export's partial flag and included page range.
+func (s *Server) exportResponse(task *model.Task, name, url string) gin.H {
+	resp := gin.H{
+		"task": s.taskSvc.ToResponse(task),
+		"url":  url,
+	}
+	if progress := task.Exports[name]; progress != nil {
+		resp["partial"] = progress.Partial
+		resp["includedPages"] = progress.IncludedPages
+	}
+	return resp
+}
+
+func (s *Server) handleDownloadExport(c *gin.Context) {
+	path, err := s.taskSvc.ExportFile(c.Request.Context(), c.Param("taskID"), c.Param("name"))
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, service.ErrExportStale) {
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	if c.Request.Method == http.MethodGet {
+		s.audit(c, service.AuditExportDownload, c.Param("taskID"), map[string]string{"export": c.Param("name")})
+	}
+	s.serveDownload(c, path, filepath.Base(path))
+}
+
+// handleExportCombined merges several tasks, in the given order, into one
+// TXT, Markdown, PDF or EPUB file.
+func (s *Server) handleExportCombined(c *gin.Context) {
+	var req struct {
+		TaskIDs []string `json:"taskIds"`
+		Format  string   `json:"format"`
+		Title   string   `json:"title"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求体格式错误"})
+		return
+	}
+	exportID, name, err := s.taskSvc.ExportCombined(c.Request.Context(), req.TaskIDs, req.Format, req.Title)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"id":  exportID,
+		"url": path.Join("/api/pdf/exports/combined", exportID, name),
+	})
+}
+
+func (s *Server) handleGetSplit(c *gin.Context) {
+	report, err := s.taskSvc.GetSplit(c.Param("splitID"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// handleExportSplit stitches the parts of a split document into one file,
+// like handleExportCombined.
+func (s *Server) handleExportSplit(c *gin.Context) {
+	var req struct {
+		Format string `json:"format"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求体格式错误"})
+		return
+	}
+	exportID, name, err := s.taskSvc.ExportSplit(c.Request.Context(), c.Param("splitID"), req.Format)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"id":  exportID,
+		"url": path.Join("/api/pdf/exports/combined", exportID, name),
+	})
+}
+
+func (s *Server) handleDownloadCombinedExport(c *gin.Context) {
+	full, err := s.taskSvc.CombinedExportFile(c.Param("exportID"), c.Param("name"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if c.Request.Method == http.MethodGet {
+		s.audit(c, service.AuditExportDownload, "", map[string]string{"combined": c.Param("exportID"), "export": c.Param("name")})
+	}
+	s.serveDownload(c, full, filepath.Base(full))
+}
+
+func (s *Server) handleListProfiles(c *gin.Context) {
+	profiles, err := s.taskSvc.ListProfiles()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"profiles": profiles})
+}
+
+func (s *Server) handleSaveProfile(c *gin.Context) {
+	var req model.SettingsProfile
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求体格式错误"})
+		return
+	}
+	req.Name = c.Param("name")
+	profile, err := s.taskSvc.SaveProfile(req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	s.audit(c, service.AuditProfileSave, "", map[string]string{"profile": profile.Name})
+	c.JSON(http.StatusOK, profile)
+}
+
+func (s *Server) handleDeleteProfile(c *gin.Context) {
+	if err := s.taskSvc.DeleteProfile(c.Param("name")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	s.audit(c, service.AuditProfileDelete, "", map[string]string{"profile": c.Param("name")})
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+func (s *Server) handleListExportFormats(c *gin.Context) {
+	formats := []string{"txt", "pdf", "md", "docx", "epub"}
+	c.JSON(http.StatusOK, gin.H{
+		"formats": append(formats, s.taskSvc.PandocFormats()...),
+		"pandoc":  s.taskSvc.PandocFormats(),
+		"locales": locale.Codes(),
+	})
+}
+
+func (s *Server) handleTestProvider(c *gin.Context) {
+	var req struct {
+		Name    string `json:"name"`
+		Type    string `json:"type"`
+		BaseURL string `json:"baseUrl"`
+		APIKey  string `json:"apiKey"`
+		Model   string `json:"model"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数格式错误"})
+		return
+	}
+	if strings.TrimSpace(req.BaseURL) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Base URL 不能为空"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "连接测试成功（模拟）",
+	})
+}
+
+func (s *Server) handleFetchProviderModels(c *gin.Context) {
+	var req struct {
+		Type string `json:"type"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数格式错误"})
+		return
+	}
+	models := sampleModels(strings.ToLower(strings.TrimSpace(req.Type)))
+	c.JSON(http.StatusOK, gin.H{
+		"models": models,
+	})
+}
+
+func (s *Server) handleSetDestination(c *gin.Context) {
+	var req struct {
+		Destination string `json:"destination"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求体格式错误"})
+		return
+	}
+	task, err := s.taskSvc.SetOutputDestination(c.Param("taskID"), req.Destination)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, s.taskSvc.ToResponse(task))
+}
+
+func (s *Server) handleSetExportSettings(c *gin.Context) {
+	var req model.ExportSettings
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求体格式错误"})
+		return
+	}
+	task, err := s.taskSvc.SetExportSettings(c.Param("taskID"), req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, s.taskSvc.ToResponse(task))
+}
+
+func (s *Server) handleUpdateMetadata(c *gin.Context) {
+	var req model.DocumentMetadata
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求体格式错误"})
+		return
+	}
+	task, err := s.taskSvc.UpdateMetadata(c.Param("taskID"), req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, s.taskSvc.ToResponse(task))
+}
+
+func (s *Server) handleCreateShare(c *gin.Context) {
+	task, err := s.taskSvc.CreateShare(c.Param("taskID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"task":  s.taskSvc.ToResponse(task),
+		"token": task.ShareToken,
+		"url":   "/api/pdf/shared/" + task.ShareToken,
+	})
+}
+
+func (s *Server) handleRevokeShare(c *gin.Context) {
+	task, err := s.taskSvc.RevokeShare(c.Param("taskID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, s.taskSvc.ToResponse(task))
+}
+
+// handleCreateAccessToken issues a read-only token for the task's files;
+// ttlHours of zero never expires.
+func (s *Server) handleCreateAccessToken(c *gin.Context) {
+	var req struct {
+		Label    string `json:"label"`
+		TTLHours int    `json:"ttlHours"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求体格式错误"})
+		return
+	}
+	token, err := s.taskSvc.CreateAccessToken(c.Param("taskID"), req.Label, time.Duration(req.TTLHours)*time.Hour)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	s.audit(c, service.AuditTokenCreate, c.Param("taskID"), map[string]string{"tokenId": token.ID})
+	c.JSON(http.StatusOK, token)
+}
+
+func (s *Server) handleListAccessTokens(c *gin.Context) {
+	tokens, err := s.taskSvc.ListAccessTokens(c.Param("taskID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"tokens": tokens})
+}
+
+func (s *Server) handleRevokeAccessToken(c *gin.Context) {
+	if err := s.taskSvc.RevokeAccessToken(c.Param("taskID"), c.Param("tokenID")); err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, service.ErrAccessTokenNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	s.audit(c, service.AuditTokenRevoke, c.Param("taskID"), map[string]string{"tokenId": c.Param("tokenID")})
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+func (s *Server) handleGetSharedTask(c *gin.Context) {
+	task, err := s.taskSvc.GetSharedTask(c.Param("token"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, s.taskSvc.ToSharedResponse(task))
+}
+
+func (s *Server) handleGetBudget(c *gin.Context) {
+	c.JSON(http.StatusOK, s.taskSvc.BudgetStatus())
+}
+
+func (s *Server) handleProviderStats(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"providers": s.taskSvc.ProviderStats()})
+}
+
+// handleHealth answers container health checks: 200 while the render
+// subsystem is healthy, 503 once it is degraded.
+func (s *Server) handleHealth(c *gin.Context) {
+	render := s.taskSvc.RenderHealth()
+	status := http.StatusOK
+	if render.Status != "ok" {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, gin.H{"status": render.Status, "render": render})
+}
+
+func (s *Server) handleMetrics(c *gin.Context) {
+	c.Header("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	c.Status(http.StatusOK)
+	if err := metrics.WriteText(c.Writer, s.taskSvc.Metrics()); err != nil {
+		log.Printf("write metrics failed: %v", err)
+	}
+}
+
+func sampleModels(providerType string) []map[string]string {
+	switch providerType {
+	case "gemini":
+		return []map[string]string{
+			{"id": "gemini-1.5-flash", "name": "Gemini Flash", "apiType": "gemini"},
+			{"id": "gemini-1.5-pro", "name": "Gemini Pro", "apiType": "gemini"},
+		}
+	case "anthropic":
+		return []map[string]string{
+			{"id": "claude-3-5-sonnet", "name": "Claude 3.5 Sonnet", "apiType": "anthropic"},
+			{"id": "claude-3-opus", "name": "Claude 3 Opus", "apiType": "anthropic"},
+		}
+	case "ollama":
+		return []map[string]string{
+			{"id": "qwen2.5vl", "name": "Qwen2.5-VL", "apiType": "ollama"},
+			{"id": "llama3.2-vision", "name": "Llama 3.2 Vision", "apiType": "ollama"},
+			{"id": "llava", "name": "LLaVA", "apiType": "ollama"},
 		}
 	default:
 		return []map[string]string{
@@ -326,6 +1412,23 @@ func sampleModels(providerType string) []map[string]string {
 	}
 }
 
+// errorStatus maps well-known service errors to HTTP status codes.
+func errorStatus(err error, fallback int) int {
+	if errors.Is(err, service.ErrBudgetExceeded) || errors.Is(err, service.ErrQuotaExceeded) {
+		return http.StatusTooManyRequests
+	}
+	if errors.Is(err, service.ErrPageConflict) {
+		return http.StatusConflict
+	}
+	if errors.Is(err, service.ErrUnsafePDF) || errors.Is(err, service.ErrInfectedUpload) {
+		return http.StatusUnprocessableEntity
+	}
+	if errors.Is(err, service.ErrVirusScanFailed) {
+		return http.StatusServiceUnavailable
+	}
+	return fallback
+}
+
 func parseOptionalInt(value string) int {
 	v, err := strconv.Atoi(strings.TrimSpace(value))
 	if err != nil {
@@ -333,3 +1436,8 @@ func parseOptionalInt(value string) int {
 	}
 	return v
 }
+
+func parseOptionalBool(value string) bool {
+	v, err := strconv.ParseBool(strings.TrimSpace(value))
+	return err == nil && v
+}
@@ -0,0 +1,118 @@
+// Package locale holds the fixed texts written into exports (page headers,
+// notices, contact sheet labels) in each supported language.
+package locale
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Supported export languages. The empty code means Chinese.
+const (
+	Chinese  = "zh"
+	English  = "en"
+	Japanese = "ja"
+)
+
+// Strings are the export texts of one language.
+type Strings struct {
+	// PageHeader is the default header template; it supports the same
+	// placeholders as ExportSettings.HeaderTemplate.
+	PageHeader string
+	// Notes introduces the footnotes block of a page in TXT exports.
+	Notes string
+	// Notice wraps a notice line at the top of TXT exports.
+	Notice string
+	// PartialNotice takes the included page ranges, the pending count and the total.
+	PartialNotice string
+	// NoPages replaces the page ranges when nothing is translated yet.
+	NoPages string
+	// ImageUnavailable stands in for a page image that could not be embedded.
+	ImageUnavailable string
+	// OriginalImage prefixes the header of original image pages.
+	OriginalImage string
+	// ContactTitle takes the document title, first and last page and the total.
+	ContactTitle string
+
+	// Contact sheet status labels.
+	Translated string
+	Blank      string
+	Pending    string
+	Failed     string
+	Blocked    string
+}
+
+var catalog = map[string]Strings{
+	Chinese: {
+		PageHeader:       "第{page}页",
+		Notes:            "注释：",
+		Notice:           "【%s】",
+		PartialNotice:    "未完成的译文：仅包含第 %s 页，仍有 %d 页（共 %d 页）尚未翻译，翻译完成后将自动重新生成。",
+		NoPages:          "无",
+		ImageUnavailable: "【无法插入原图】",
+		OriginalImage:    "原图 · ",
+		ContactTitle:     "%s · 第 %d-%d 页 / 共 %d 页",
+		Translated:       "已翻译",
+		Blank:            "无文本",
+		Pending:          "待翻译",
+		Failed:           "失败",
+		Blocked:          "已拦截",
+	},
+	English: {
+		PageHeader:       "Page {page}",
+		Notes:            "Notes:",
+		Notice:           "[%s]",
+		PartialNotice:    "Incomplete translation: only pages %s are included; %d of %d pages are not translated yet. This export is regenerated automatically when translation finishes.",
+		NoPages:          "none",
+		ImageUnavailable: "[Original image unavailable]",
+		OriginalImage:    "Original · ",
+		ContactTitle:     "%s · pages %d-%d of %d",
+		Translated:       "Translated",
+		Blank:            "No text",
+		Pending:          "Pending",
+		Failed:           "Failed",
+		Blocked:          "Blocked",
+	},
+	Japanese: {
+		PageHeader:       "{page}ページ",
+		Notes:            "注釈：",
+		Notice:           "【%s】",
+		PartialNotice:    "未完成の翻訳：%s ページのみ収録しています。残り %d ページ（全 %d ページ）は未翻訳で、翻訳完了後に自動で再生成されます。",
+		NoPages:          "なし",
+		ImageUnavailable: "【原画像を挿入できません】",
+		OriginalImage:    "原画像 · ",
+		ContactTitle:     "%s · %d-%d ページ / 全 %d ページ",
+		Translated:       "翻訳済み",
+		Blank:            "テキストなし",
+		Pending:          "未翻訳",
+		Failed:           "失敗",
+		Blocked:          "ブロック",
+	},
+}
+
+// Normalize maps inputs such as "en-US", "ja_JP" or "zh-Hans" to a supported
+// code. The empty string stays empty and selects Chinese.
+func Normalize(code string) (string, error) {
+	code = strings.ToLower(strings.TrimSpace(code))
+	if code == "" {
+		return "", nil
+	}
+	base, _, _ := strings.Cut(strings.ReplaceAll(code, "_", "-"), "-")
+	if _, ok := catalog[base]; !ok {
+		return "", fmt.Errorf("不支持的导出语言: %s", code)
+	}
+	return base, nil
+}
+
+// For returns the strings of code, falling back to Chinese.
+func For(code string) Strings {
+	if strs, ok := catalog[code]; ok {
+		return strs
+	}
+	return catalog[Chinese]
+}
+
+// Codes lists the supported language codes.
+func Codes() []string {
+	return []string{Chinese, English, Japanese}
+}
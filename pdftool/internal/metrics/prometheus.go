@@ -0,0 +1,56 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Sample is a single Prometheus metric value.
+type Sample struct {
+	Name   string
+	Help   string
+	Type   string // gauge or counter
+	Labels map[string]string
+	Value  float64
+}
+
+// WriteText renders samples in the Prometheus text exposition format.
+// Samples sharing a name must be adjacent; they share one HELP/TYPE header.
+func WriteText(w io.Writer, samples []Sample) error {
+	written := make(map[string]bool)
+	for _, sample := range samples {
+		if !written[sample.Name] {
+			written[sample.Name] = true
+			metricType := sample.Type
+			if metricType == "" {
+				metricType = "gauge"
+			}
+			if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", sample.Name, sample.Help, sample.Name, metricType); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintf(w, "%s%s %s\n", sample.Name, formatLabels(sample.Labels), strconv.FormatFloat(sample.Value, 'g', -1, 64)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func formatLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, fmt.Sprintf("%s=%q", k, labels[k]))
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
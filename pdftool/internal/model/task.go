@@ -9,24 +9,95 @@ const (
 	PageStatusPending   PageStatus = "pending"
 	PageStatusCompleted PageStatus = "completed"
 	PageStatusError     PageStatus = "error"
+	// PageStatusBlocked marks a page the provider refused to process.
+	PageStatusBlocked   PageStatus = "blocked"
 )
 
+// ReviewFlag marks a page in the human proofreading workflow.
+type ReviewFlag string
+
+const (
+	ReviewNeedsReview ReviewFlag = "needs_review"
+	ReviewApproved    ReviewFlag = "approved"
+)
+
+// TaskState is the top-level lifecycle state of a task.
+type TaskState string
+
+const (
+	TaskStateRendering   TaskState = "rendering"
+	TaskStateQueued      TaskState = "queued"
+	TaskStateTranslating TaskState = "translating"
+	TaskStateFormatting  TaskState = "formatting"
+	TaskStatePaused      TaskState = "paused"
+	TaskStateCompleted   TaskState = "completed"
+	TaskStateFailed      TaskState = "failed"
+	TaskStateCanceled    TaskState = "canceled"
+)
+
+// StateTransition records one change of a task's state.
+type StateTransition struct {
+	From   TaskState `json:"from,omitempty"`
+	To     TaskState `json:"to"`
+	At     time.Time `json:"at"`
+	Reason string    `json:"reason,omitempty"`
+}
+
 // PageResult tracks outputs for a rendered PDF page.
 type PageResult struct {
 	ID          string     `json:"id"`
 	PageNumber  int        `json:"page_number"`
 	ImagePath   string     `json:"image_path"`
 	ImageURL    string     `json:"image_url"`
+	ImageWidth  int        `json:"image_width,omitempty"`
+	ImageHeight int        `json:"image_height,omitempty"`
 	TextPath    string     `json:"text_path"`
 	TextURL     string     `json:"text_url"`
 	HasText     bool       `json:"has_text"`
 	SourceText  string     `json:"source_text"`
+	OCRSource   string     `json:"ocr_source,omitempty"`
+	Provider    *ProviderInfo `json:"provider,omitempty"`
+	Regions     []Region   `json:"regions,omitempty"`
 	Translation string     `json:"translation"`
+	Footnotes   []Footnote `json:"footnotes,omitempty"`
+	Elements    *PageElements `json:"elements,omitempty"`
+	// Blocks place the translation on the page, for the "blocks" layout mode.
+	Blocks      []TextBlock `json:"blocks,omitempty"`
+	// Figures are the images embedded in the source page, when extracted.
+	Figures     []Figure   `json:"figures,omitempty"`
 	Status      PageStatus `json:"status"`
 	Error       string     `json:"error"`
+	BlockReason string     `json:"block_reason,omitempty"`
+	// StorageError is set while the page TXT could not be written; the
+	// translation itself is kept in the task metadata.
+	StorageError string    `json:"storage_error,omitempty"`
+	RetryAttempts int      `json:"retry_attempts,omitempty"`
+	RetryAt     time.Time  `json:"retry_at,omitempty"`
+	FirstAttemptAt time.Time `json:"first_attempt_at,omitempty"`
+	DurationMs  int64      `json:"duration_ms,omitempty"`
+	StartedAt   time.Time  `json:"started_at,omitempty"`
+	FinishedAt  time.Time  `json:"finished_at,omitempty"`
+	Review      ReviewFlag `json:"review,omitempty"`
+	ReviewNote  string     `json:"review_note,omitempty"`
+	ReviewedAt  time.Time  `json:"reviewed_at,omitempty"`
+	// Previous is the translation the last retranslation replaced.
+	Previous    *PageRevision `json:"previous,omitempty"`
 	UpdatedAt   time.Time  `json:"updated_at"`
 }
 
+// PageRevision is a page translation kept so it can be compared with, or
+// restored over, the retranslation that replaced it.
+type PageRevision struct {
+	HasText     bool         `json:"has_text"`
+	SourceText  string       `json:"source_text"`
+	Translation string       `json:"translation"`
+	Footnotes   []Footnote   `json:"footnotes,omitempty"`
+	Elements    *PageElements `json:"elements,omitempty"`
+	Blocks      []TextBlock  `json:"blocks,omitempty"`
+	Provider    ProviderInfo `json:"provider"`
+	ReplacedAt  time.Time    `json:"replaced_at"`
+}
+
 // Task aggregates all processing artifacts for a PDF.
 type Task struct {
 	ID                  string        `json:"id"`
@@ -50,6 +121,225 @@ type Task struct {
 	FormattingInProgress bool         `json:"formatting_in_progress"`
 	FormattingTotalChunks int         `json:"formatting_total_chunks"`
 	FormattingCompletedChunks int     `json:"formatting_completed_chunks"`
+	FormattingStartedAt time.Time     `json:"formatting_started_at,omitempty"`
+	// ConsistentTxt* locate the TXT after the consistency pass, which
+	// harmonized names and terms using ConsistencyGlossary.
+	ConsistentTxtPath   string        `json:"consistent_txt_path,omitempty"`
+	ConsistentTxtURL    string        `json:"consistent_txt_url,omitempty"`
+	ConsistencyGlossary []GlossaryEntry `json:"consistency_glossary,omitempty"`
+	ShareToken          string        `json:"share_token,omitempty"`
+	SharedAt            time.Time     `json:"shared_at,omitempty"`
+	Source              *SourceInfo   `json:"source,omitempty"`
+	CombinedMarkdownPath string       `json:"combined_markdown_path,omitempty"`
+	CombinedMarkdownURL string        `json:"combined_markdown_url,omitempty"`
+	CombinedDocxPath    string        `json:"combined_docx_path,omitempty"`
+	CombinedDocxURL     string        `json:"combined_docx_url,omitempty"`
+	CombinedEpubPath    string        `json:"combined_epub_path,omitempty"`
+	CombinedEpubURL     string        `json:"combined_epub_url,omitempty"`
+	PandocExports       map[string]string `json:"pandoc_exports,omitempty"`
+	ContactSheetPath    string        `json:"contact_sheet_path,omitempty"`
+	ContactSheetURL     string        `json:"contact_sheet_url,omitempty"`
+	StaleExports        []string      `json:"stale_exports,omitempty"`
+	Exports             map[string]*ExportProgress `json:"exports,omitempty"`
+	OutputDestination   string        `json:"output_destination,omitempty"`
+	RemoteExports       []*RemoteExport `json:"remote_exports,omitempty"`
+	FailureStreak       int           `json:"failure_streak,omitempty"`
+	Paused              bool          `json:"paused,omitempty"`
+	PauseReason         string        `json:"pause_reason,omitempty"`
+	PausedAt            time.Time     `json:"paused_at,omitempty"`
+	Metadata            *DocumentMetadata `json:"metadata,omitempty"`
+	SanitizedFeatures   []string      `json:"sanitized_features,omitempty"`
+	VirusScan           *VirusScan    `json:"virus_scan,omitempty"`
+	QuarantinePath      string        `json:"quarantine_path,omitempty"`
+	LayoutMode          string        `json:"layout_mode,omitempty"`
+	WritingMode         string        `json:"writing_mode,omitempty"`
+	TargetLanguage      string        `json:"target_language,omitempty"`
+	ExportSettings      *ExportSettings `json:"export_settings,omitempty"`
+	DryRun              bool          `json:"dry_run,omitempty"`
+	Quote               *TaskQuote    `json:"quote,omitempty"`
+	SamplePages         []int         `json:"sample_pages,omitempty"`
+	Render              *RenderSettings `json:"render,omitempty"`
+	Profile             string        `json:"profile,omitempty"`
+	Project             string        `json:"project,omitempty"`
+	Part                *TaskPart     `json:"part,omitempty"`
+	Outline             []OutlineEntry `json:"outline,omitempty"`
+	State               TaskState     `json:"state,omitempty"`
+	StateHistory        []StateTransition `json:"state_history,omitempty"`
+	DeletedAt           time.Time     `json:"deleted_at,omitempty"`
+	Owner               string        `json:"owner,omitempty"`
+	Client              string        `json:"client,omitempty"`
+}
+
+// RenderSettings record how a task's page images were rendered, so pages
+// re-rendered later match the others.
+type RenderSettings struct {
+	DPI          int    `json:"dpi"`
+	Format       string `json:"format"`
+	JPEGQuality  int    `json:"jpeg_quality,omitempty"`
+	MaxDimension int    `json:"max_dimension,omitempty"`
+}
+
+// ExportSettings controls page headers in merged outputs and the export
+// options used when a request does not specify them.
+type ExportSettings struct {
+	// HeaderTemplate supports {page}, {pdf_page} and {total}; empty means "第{page}页".
+	HeaderTemplate string `json:"headerTemplate,omitempty"`
+	// PageOffset is subtracted from the PDF page index, e.g. 14 when book page 1 is PDF page 15.
+	PageOffset  int  `json:"pageOffset,omitempty"`
+	HideHeaders bool `json:"hideHeaders,omitempty"`
+	// TxtTemplate is an optional Go text/template for the TXT export.
+	TxtTemplate string `json:"txtTemplate,omitempty"`
+	// Variant is the default TXT export: "original", "formatted" (AI
+	// formatted) or "consistent" (after the consistency pass).
+	Variant string `json:"variant,omitempty"`
+	// PDFLayout is the default PDF layout: "text" is monolingual, "stacked",
+	// "facing" and "appendix" are bilingual, "blocks" places the translation
+	// over the original page.
+	PDFLayout string `json:"pdfLayout,omitempty"`
+	// Formats lists the exports generated together, e.g. ["txt", "pdf", "md"].
+	Formats []string `json:"formats,omitempty"`
+	// AutoExport generates Formats as soon as every page is translated.
+	AutoExport bool `json:"autoExport,omitempty"`
+	// AutoFormat runs AI formatting before the automatic export.
+	AutoFormat bool `json:"autoFormat,omitempty"`
+	// AutoConsistency runs the consistency pass before the automatic export.
+	AutoConsistency bool `json:"autoConsistency,omitempty"`
+	// Locale selects the language of headers and notices: "zh" (default), "en" or "ja".
+	Locale string `json:"locale,omitempty"`
+	// Rewrap joins line breaks OCR left inside paragraphs when merging pages.
+	Rewrap bool `json:"rewrap,omitempty"`
+}
+
+// Footnote is a note kept apart from the page body; the body references it as [^Marker].
+type Footnote struct {
+	Marker      string `json:"marker"`
+	SourceText  string `json:"sourceText"`
+	Translation string `json:"translation"`
+}
+
+// PageElements are the structured parts of a page the model listed next to
+// the flat translation, in page order. Footnotes stay in the page's
+// Footnotes.
+type PageElements struct {
+	Title    []Element `json:"title,omitempty"`
+	Headers  []Element `json:"headers,omitempty"`
+	Body     []Element `json:"body,omitempty"`
+	Captions []Element `json:"captions,omitempty"`
+}
+
+// TextBlock is a block of page text with its translation and where it sits
+// on the page image: BBox is x0, y0, x1, y1 from the top left corner, each
+// scaled to 0-1000 of the image width or height.
+type TextBlock struct {
+	Text        string     `json:"text"`
+	Translation string     `json:"translation"`
+	BBox        [4]float64 `json:"bbox"`
+}
+
+// Figure is an image embedded in a source page, stored at its native
+// resolution: JPEG data as .jpg, anything else as .png. X, Y, W and H place
+// it on the page, in points from the top left corner.
+type Figure struct {
+	Path   string  `json:"path"`
+	URL    string  `json:"url"`
+	Width  int     `json:"width"`
+	Height int     `json:"height"`
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	W      float64 `json:"w"`
+	H      float64 `json:"h"`
+}
+
+// FigureResponse exposes a figure without its storage path.
+type FigureResponse struct {
+	URL    string  `json:"url"`
+	Width  int     `json:"width"`
+	Height int     `json:"height"`
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	W      float64 `json:"w"`
+	H      float64 `json:"h"`
+}
+
+// Element is one title, header, body paragraph or caption of a page.
+type Element struct {
+	SourceText  string `json:"sourceText"`
+	Translation string `json:"translation"`
+}
+
+// Region is a reading-ordered block of a page image, in pixels. Text fields are
+// filled when the region was recognized or translated on its own.
+type Region struct {
+	X           int    `json:"x"`
+	Y           int    `json:"y"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+	Source      string `json:"source,omitempty"`
+	SourceText  string `json:"sourceText,omitempty"`
+	Translation string `json:"translation,omitempty"`
+}
+
+// PageRegionsResponse backs the click-to-translate page viewer.
+type PageRegionsResponse struct {
+	PageNumber  int      `json:"pageNumber"`
+	ImageURL    string   `json:"imageUrl"`
+	ImageWidth  int      `json:"imageWidth"`
+	ImageHeight int      `json:"imageHeight"`
+	Regions     []Region `json:"regions"`
+}
+
+// DocumentMetadata is embedded into exports so library managers show proper titles.
+type DocumentMetadata struct {
+	Title    string `json:"title,omitempty"`
+	Author   string `json:"author,omitempty"`
+	Subject  string `json:"subject,omitempty"`
+	Keywords string `json:"keywords,omitempty"`
+	// Source is "pdf" (info dictionary), "page" (first transcribed line) or "manual".
+	Source string `json:"source,omitempty"`
+}
+
+// ScanStatus is the antivirus verdict on an upload.
+type ScanStatus string
+
+const (
+	ScanStatusClean    ScanStatus = "clean"
+	ScanStatusInfected ScanStatus = "infected"
+	// ScanStatusError marks an upload accepted although the scan failed.
+	ScanStatusError ScanStatus = "error"
+)
+
+// VirusScan records the ClamAV scan of the uploaded PDF.
+type VirusScan struct {
+	Status    ScanStatus `json:"status"`
+	Signature string     `json:"signature,omitempty"`
+	Error     string     `json:"error,omitempty"`
+	ScannedAt time.Time  `json:"scannedAt"`
+}
+
+// AccessToken grants read access to one task's files. Only the SHA-256 of
+// the token is stored; Token is set once, in the response issuing it.
+type AccessToken struct {
+	ID        string    `json:"id"`
+	Label     string    `json:"label,omitempty"`
+	Hash      string    `json:"hash,omitempty"`
+	Token     string    `json:"token,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	ExpiresAt time.Time `json:"expiresAt,omitempty"`
+}
+
+// RemoteExport records an export file uploaded to a remote location.
+type RemoteExport struct {
+	File        string    `json:"file"`
+	Destination string    `json:"destination"`
+	URL         string    `json:"url"`
+	UploadedAt  time.Time `json:"uploadedAt"`
+}
+
+// SourceInfo records the cloud location a task was imported from.
+type SourceInfo struct {
+	Type      string `json:"type"`
+	Path      string `json:"path"`
+	WriteBack bool   `json:"writeBack"`
 }
 
 // ProviderInfo keeps track of non-sensitive provider data.
@@ -68,9 +358,37 @@ type PageResponse struct {
 	TextURL     string     `json:"textUrl,omitempty"`
 	HasText     bool       `json:"hasText"`
 	SourceText  string     `json:"sourceText"`
+	OCRSource   string     `json:"ocrSource,omitempty"`
+	// Provider is set when the page was produced by a different provider than the task.
+	Provider    *ProviderInfo `json:"provider,omitempty"`
+	Regions     []Region   `json:"regions,omitempty"`
 	Translation string     `json:"translation"`
+	Footnotes   []Footnote `json:"footnotes,omitempty"`
+	Elements    *PageElements `json:"elements,omitempty"`
+	// Outline lists the bookmarks of the source PDF pointing to the page.
+	Outline     []OutlineEntry `json:"outline,omitempty"`
+	Blocks      []TextBlock `json:"blocks,omitempty"`
+	Figures     []FigureResponse `json:"figures,omitempty"`
 	Status      PageStatus `json:"status"`
 	Error       string     `json:"error,omitempty"`
+	// BlockReason is the provider's refusal reason for blocked pages.
+	BlockReason string     `json:"blockReason,omitempty"`
+	// StorageError reports that the page TXT is not written yet; the
+	// translation is complete and included in exports.
+	StorageError string    `json:"storageError,omitempty"`
+	RetryAttempts int      `json:"retryAttempts,omitempty"`
+	// RetryAt is set while the page waits in the automatic retry queue.
+	RetryAt     *time.Time `json:"retryAt,omitempty"`
+	// DurationMs, StartedAt and FinishedAt time the last translation attempt.
+	DurationMs  int64      `json:"durationMs,omitempty"`
+	StartedAt   *time.Time `json:"startedAt,omitempty"`
+	FinishedAt  *time.Time `json:"finishedAt,omitempty"`
+	// Review, ReviewNote and ReviewedAt carry the proofreading flag.
+	Review      ReviewFlag `json:"review,omitempty"`
+	ReviewNote  string     `json:"reviewNote,omitempty"`
+	ReviewedAt  *time.Time `json:"reviewedAt,omitempty"`
+	// HasPrevious reports that a replaced translation can be diffed or restored.
+	HasPrevious bool       `json:"hasPrevious,omitempty"`
 	UpdatedAt   time.Time  `json:"updatedAt"`
 }
 
@@ -91,6 +409,189 @@ type TaskResponse struct {
 	FormattingInProgress bool           `json:"formattingInProgress"`
 	FormattingTotalChunks int           `json:"formattingTotalChunks"`
 	FormattingCompletedChunks int       `json:"formattingCompletedChunks"`
+	ConsistentTxtURL    string          `json:"consistentTxtUrl,omitempty"`
+	ConsistencyGlossary []GlossaryEntry `json:"consistencyGlossary,omitempty"`
+	ShareToken          string          `json:"shareToken,omitempty"`
+	Source              *SourceInfo     `json:"source,omitempty"`
+	CombinedMarkdownURL string          `json:"combinedMarkdownUrl,omitempty"`
+	CombinedDocxURL     string          `json:"combinedDocxUrl,omitempty"`
+	CombinedEpubURL     string          `json:"combinedEpubUrl,omitempty"`
+	PandocExports       map[string]string `json:"pandocExports,omitempty"`
+	ContactSheetURL     string          `json:"contactSheetUrl,omitempty"`
+	StaleExports        []string        `json:"staleExports,omitempty"`
+	Exports             map[string]*ExportProgress `json:"exports,omitempty"`
+	OutputDestination   string          `json:"outputDestination,omitempty"`
+	RemoteExports       []*RemoteExport `json:"remoteExports,omitempty"`
+	FailureStreak       int             `json:"failureStreak,omitempty"`
+	Paused              bool            `json:"paused,omitempty"`
+	PauseReason         string          `json:"pauseReason,omitempty"`
+	Metadata            *DocumentMetadata `json:"metadata,omitempty"`
+	SanitizedFeatures   []string        `json:"sanitizedFeatures,omitempty"`
+	VirusScan           *VirusScan      `json:"virusScan,omitempty"`
+	Owner               string          `json:"owner,omitempty"`
+	LayoutMode          string          `json:"layoutMode,omitempty"`
+	WritingMode         string          `json:"writingMode,omitempty"`
+	TargetLanguage      string          `json:"targetLanguage,omitempty"`
+	ExportSettings      *ExportSettings `json:"exportSettings,omitempty"`
+	ETA                 *TaskETA        `json:"eta,omitempty"`
+	Timing              *TaskTiming     `json:"timing,omitempty"`
+	DryRun              bool            `json:"dryRun,omitempty"`
+	Quote               *TaskQuote      `json:"quote,omitempty"`
+	SamplePages         []int           `json:"samplePages,omitempty"`
+	Profile             string          `json:"profile,omitempty"`
+	Project             string          `json:"project,omitempty"`
+	Part                *TaskPart       `json:"part,omitempty"`
+	Outline             []OutlineEntry  `json:"outline,omitempty"`
+	State               TaskState       `json:"state"`
+	StateHistory        []StateTransition `json:"stateHistory,omitempty"`
+}
+
+// SharedTaskResponse is the read-only view behind a share link. It lists
+// its fields explicitly so new task fields stay private unless added here.
+type SharedTaskResponse struct {
+	FileName            string                `json:"fileName"`
+	TotalPages          int                   `json:"totalPages"`
+	CreatedAt           time.Time             `json:"createdAt"`
+	UpdatedAt           time.Time             `json:"updatedAt"`
+	CombinedTxtURL      string                `json:"combinedTxtUrl,omitempty"`
+	CombinedPDFURL      string                `json:"combinedPdfUrl,omitempty"`
+	FormattedTxtURL     string                `json:"formattedTxtUrl,omitempty"`
+	ConsistentTxtURL    string                `json:"consistentTxtUrl,omitempty"`
+	CombinedMarkdownURL string                `json:"combinedMarkdownUrl,omitempty"`
+	CombinedDocxURL     string                `json:"combinedDocxUrl,omitempty"`
+	CombinedEpubURL     string                `json:"combinedEpubUrl,omitempty"`
+	PandocExports       map[string]string     `json:"pandocExports,omitempty"`
+	Metadata            *DocumentMetadata     `json:"metadata,omitempty"`
+	LayoutMode          string                `json:"layoutMode,omitempty"`
+	WritingMode         string                `json:"writingMode,omitempty"`
+	TargetLanguage      string                `json:"targetLanguage,omitempty"`
+	Outline             []OutlineEntry        `json:"outline,omitempty"`
+	Pages               []*SharedPageResponse `json:"pages"`
+}
+
+// SharedPageResponse is one page of a SharedTaskResponse.
+type SharedPageResponse struct {
+	PageNumber  int              `json:"pageNumber"`
+	ImageURL    string           `json:"imageUrl"`
+	TextURL     string           `json:"textUrl,omitempty"`
+	SourceText  string           `json:"sourceText"`
+	Translation string           `json:"translation"`
+	Footnotes   []Footnote       `json:"footnotes,omitempty"`
+	Elements    *PageElements    `json:"elements,omitempty"`
+	Outline     []OutlineEntry   `json:"outline,omitempty"`
+	Blocks      []TextBlock      `json:"blocks,omitempty"`
+	Figures     []FigureResponse `json:"figures,omitempty"`
+	Status      PageStatus       `json:"status"`
+}
+
+// SettingsProfile is a named, reusable set of task creation settings. API keys
+// are never stored; they still come from the request or the server default.
+type SettingsProfile struct {
+	Name              string          `json:"name"`
+	ProviderType      string          `json:"providerType,omitempty"`
+	ProviderBase      string          `json:"providerBase,omitempty"`
+	ProviderModel     string          `json:"providerModel,omitempty"`
+	ProviderMaxTokens int             `json:"providerMaxTokens,omitempty"`
+	RangeMode         string          `json:"rangeMode,omitempty"`
+	RangeCustom       int             `json:"rangeCustom,omitempty"`
+	RangePages        string          `json:"rangePages,omitempty"`
+	RangeExclude      string          `json:"rangeExclude,omitempty"`
+	BatchLimit        int             `json:"batchLimit,omitempty"`
+	OutputDestination string          `json:"outputDestination,omitempty"`
+	LayoutMode        string          `json:"layoutMode,omitempty"`
+	WritingMode       string          `json:"writingMode,omitempty"`
+	TargetLanguage    string          `json:"targetLanguage,omitempty"`
+	PreferTextLayer   bool            `json:"preferTextLayer,omitempty"`
+	ExtractFigures    bool            `json:"extractFigures,omitempty"`
+	ExportSettings    *ExportSettings `json:"exportSettings,omitempty"`
+	UpdatedAt         time.Time       `json:"updatedAt"`
+}
+
+// GlossaryEntry fixes how a term is translated across a project.
+type GlossaryEntry struct {
+	Term        string `json:"term"`
+	Translation string `json:"translation"`
+	Note        string `json:"note,omitempty"`
+}
+
+// Project groups related tasks, such as the volumes of a series, in reading
+// order. Tasks created in a project use its settings profile and glossary.
+type Project struct {
+	ID          string          `json:"id"`
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	TaskIDs     []string        `json:"taskIds"`
+	Profile     string          `json:"profile,omitempty"`
+	Glossary    []GlossaryEntry `json:"glossary,omitempty"`
+	CreatedAt   time.Time       `json:"createdAt"`
+	UpdatedAt   time.Time       `json:"updatedAt"`
+}
+
+// ProjectResponse is a project with the progress of its tasks.
+type ProjectResponse struct {
+	*Project
+	Tasks          []*TaskSummary    `json:"tasks"`
+	MissingTasks   []string          `json:"missingTasks,omitempty"`
+	TotalPages     int               `json:"totalPages"`
+	CompletedPages int               `json:"completedPages"`
+	PendingPages   int               `json:"pendingPages"`
+	ErrorPages     int               `json:"errorPages"`
+	// Progress is the share of pages that are completed, from 0 to 1.
+	Progress       float64           `json:"progress"`
+	// Exports maps export formats to the generated file names.
+	Exports        map[string]string `json:"exports,omitempty"`
+}
+
+// ExportProgress records how complete the task was when an export was generated.
+type ExportProgress struct {
+	Partial       bool      `json:"partial"`
+	IncludedPages string    `json:"includedPages,omitempty"`
+	PendingPages  int       `json:"pendingPages,omitempty"`
+	Layout        string    `json:"layout,omitempty"`
+	GeneratedAt   time.Time `json:"generatedAt"`
+}
+
+// TaskQuote is the pre-flight estimate returned by a dry run.
+type TaskQuote struct {
+	Pages                 int     `json:"pages"`
+	BlankPages            []int   `json:"blankPages,omitempty"`
+	EstimatedInputTokens  int64   `json:"estimatedInputTokens"`
+	EstimatedOutputTokens int64   `json:"estimatedOutputTokens"`
+	EstimatedTokens       int64   `json:"estimatedTokens"`
+	EstimatedCost         float64 `json:"estimatedCost"`
+	PricePerMillionTokens float64 `json:"pricePerMillionTokens"`
+	WithinBudget          bool    `json:"withinBudget"`
+}
+
+// TaskETA estimates the remaining work from the latency observed so far.
+type TaskETA struct {
+	RemainingSeconds    int64   `json:"remainingSeconds"`
+	PendingPages        int     `json:"pendingPages"`
+	AvgPageMs           int64   `json:"avgPageMs"`
+	PagesPerMinute      float64 `json:"pagesPerMinute"`
+	FormattingSeconds   int64   `json:"formattingSeconds,omitempty"`
+}
+
+// TaskTiming aggregates the recorded page durations of a task.
+type TaskTiming struct {
+	TimedPages   int     `json:"timedPages"`
+	TotalPageMs  int64   `json:"totalPageMs"`
+	AvgPageMs    int64   `json:"avgPageMs"`
+	MedianPageMs int64   `json:"medianPageMs"`
+	MaxPageMs    int64   `json:"maxPageMs"`
+	SlowestPage  int     `json:"slowestPage"`
+	// SlowPages took more than three times the median.
+	SlowPages    []int   `json:"slowPages,omitempty"`
+	// WallMs spans the first page start to the last page finish.
+	WallMs       int64   `json:"wallMs,omitempty"`
+	Providers    []*ProviderTiming `json:"providers,omitempty"`
+}
+
+// ProviderTiming compares page durations per provider within a task.
+type ProviderTiming struct {
+	Provider  ProviderInfo `json:"provider"`
+	Pages     int          `json:"pages"`
+	AvgPageMs int64        `json:"avgPageMs"`
 }
 
 // TaskSummary is a lightweight representation used for listings.
@@ -101,6 +602,266 @@ type TaskSummary struct {
 	CompletedPages int       `json:"completedPages"`
 	PendingPages   int       `json:"pendingPages"`
 	ErrorPages     int       `json:"errorPages"`
+	BlockedPages   int       `json:"blockedPages,omitempty"`
+	NeedsReviewPages int     `json:"needsReviewPages,omitempty"`
+	ApprovedPages  int       `json:"approvedPages,omitempty"`
+	State          TaskState `json:"state"`
+	FailureStreak  int       `json:"failureStreak,omitempty"`
+	Paused         bool      `json:"paused,omitempty"`
+	DryRun         bool      `json:"dryRun,omitempty"`
+	Project        string    `json:"project,omitempty"`
 	CreatedAt      time.Time `json:"createdAt"`
 	UpdatedAt      time.Time `json:"updatedAt"`
 }
+
+// JobStatus enumerates background job states.
+type JobStatus string
+
+const (
+	JobStatusQueued    JobStatus = "queued"
+	JobStatusRunning   JobStatus = "running"
+	JobStatusCompleted JobStatus = "completed"
+	JobStatusFailed    JobStatus = "failed"
+)
+
+// Job tracks a background operation started by an API call, such as a page
+// retranslation or a PDF export, so clients can poll for its outcome.
+// Completed and Total report progress for jobs that have steps, and URL the
+// file a finished export job produced.
+type Job struct {
+	ID         string    `json:"id"`
+	Kind       string    `json:"kind"`
+	TaskID     string    `json:"taskId"`
+	PageNumber int       `json:"pageNumber,omitempty"`
+	Status     JobStatus `json:"status"`
+	Completed  int       `json:"completed,omitempty"`
+	Total      int       `json:"total,omitempty"`
+	URL        string    `json:"url,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	CreatedAt  time.Time `json:"createdAt"`
+	FinishedAt time.Time `json:"finishedAt,omitempty"`
+}
+
+// TrashEntry is a deleted task that can still be restored.
+type TrashEntry struct {
+	ID         string    `json:"id"`
+	FileName   string    `json:"fileName"`
+	TotalPages int       `json:"totalPages"`
+	DeletedAt  time.Time `json:"deletedAt"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+}
+
+// AuditEntry records one sensitive operation in the audit log.
+type AuditEntry struct {
+	Time      time.Time         `json:"time"`
+	Action    string            `json:"action"`
+	Principal string            `json:"principal,omitempty"`
+	IP        string            `json:"ip,omitempty"`
+	TaskID    string            `json:"taskId,omitempty"`
+	Detail    map[string]string `json:"detail,omitempty"`
+}
+
+// PageImageProblem names a page whose stored image is missing, empty or
+// cannot be decoded.
+type PageImageProblem struct {
+	PageNumber int    `json:"pageNumber"`
+	Reason     string `json:"reason"`
+}
+
+// RenderHealth reports the state of the PDF render subsystem; Status is
+// "ok" or "degraded" with Reason explaining why. Memory values are bytes,
+// zero when unknown or unlimited.
+type RenderHealth struct {
+	Status               string           `json:"status"`
+	Reason               string           `json:"reason,omitempty"`
+	Isolated             bool             `json:"isolated"`
+	WorkerMemoryLimit    int64            `json:"workerMemoryLimit"`
+	ContainerMemoryLimit int64            `json:"containerMemoryLimit"`
+	ProcessMemory        int64            `json:"processMemory"`
+	LastWorkerPeak       int64            `json:"lastWorkerPeak"`
+	MaxWorkerPeak        int64            `json:"maxWorkerPeak"`
+	Completed            map[string]int64 `json:"completed"`
+	Failures             map[string]int64 `json:"failures"`
+	Recycles             int64            `json:"recycles"`
+	ConsecutiveFailures  int              `json:"consecutiveFailures"`
+	LastFailure          string           `json:"lastFailure,omitempty"`
+	LastFailureAt        time.Time        `json:"lastFailureAt,omitempty"`
+}
+
+// BudgetStatus reports provider spend against configured caps.
+type BudgetStatus struct {
+	Day                   string  `json:"day"`
+	DayTokens             int64   `json:"dayTokens"`
+	DayCost               float64 `json:"dayCost"`
+	Month                 string  `json:"month"`
+	MonthTokens           int64   `json:"monthTokens"`
+	MonthCost             float64 `json:"monthCost"`
+	TotalTokens           int64   `json:"totalTokens"`
+	DailyTokenLimit       int64   `json:"dailyTokenLimit"`
+	MonthlyTokenLimit     int64   `json:"monthlyTokenLimit"`
+	DailyCostLimit        float64 `json:"dailyCostLimit"`
+	MonthlyCostLimit      float64 `json:"monthlyCostLimit"`
+	PricePerMillionTokens float64 `json:"pricePerMillionTokens"`
+	Exceeded              bool    `json:"exceeded"`
+}
+
+// QuotaStatus reports one user's usage against their quota; zero limits
+// are unlimited.
+type QuotaStatus struct {
+	User           string `json:"user"`
+	Day            string `json:"day"`
+	DayPages       int    `json:"dayPages"`
+	PagesPerDay    int    `json:"pagesPerDay"`
+	Month          string `json:"month"`
+	MonthTokens    int64  `json:"monthTokens"`
+	TokensPerMonth int64  `json:"tokensPerMonth"`
+	Exceeded       bool   `json:"exceeded"`
+}
+
+// ProviderStats aggregates historical calls for one provider type and model.
+type ProviderStats struct {
+	Type          string    `json:"type"`
+	Model         string    `json:"model"`
+	Requests      int64     `json:"requests"`
+	Errors        int64     `json:"errors"`
+	ErrorRate     float64   `json:"errorRate"`
+	InputTokens   int64     `json:"inputTokens"`
+	OutputTokens  int64     `json:"outputTokens"`
+	TotalTokens   int64     `json:"totalTokens"`
+	EstimatedCost float64   `json:"estimatedCost"`
+	MeanLatencyMs int64     `json:"meanLatencyMs"`
+	LastUsedAt    time.Time `json:"lastUsedAt"`
+}
+
+// BatchStatus tracks URL-list ingestion progress.
+type BatchStatus string
+
+const (
+	BatchStatusPending   BatchStatus = "pending"
+	BatchStatusRunning   BatchStatus = "running"
+	BatchStatusCompleted BatchStatus = "completed"
+	BatchStatusFailed    BatchStatus = "failed"
+)
+
+// BatchItem is the outcome for one URL of a batch.
+type BatchItem struct {
+	URL        string      `json:"url"`
+	Status     BatchStatus `json:"status"`
+	TaskID     string      `json:"taskId,omitempty"`
+	FileName   string      `json:"fileName,omitempty"`
+	TotalPages int         `json:"totalPages,omitempty"`
+	Error      string      `json:"error,omitempty"`
+}
+
+// BatchReport summarizes a URL-list ingestion request.
+type BatchReport struct {
+	ID         string       `json:"id"`
+	Status     BatchStatus  `json:"status"`
+	CreatedAt  time.Time    `json:"createdAt"`
+	FinishedAt time.Time    `json:"finishedAt,omitempty"`
+	Items      []*BatchItem `json:"items"`
+}
+
+// OutlineEntry is a bookmark of the source PDF. Page is the task page it
+// points to.
+type OutlineEntry struct {
+	Level int    `json:"level"`
+	Title string `json:"title"`
+	Page  int    `json:"page"`
+}
+
+// TaskPart places a task created from a range of chapters of a split
+// document. Page N of the task is page FirstPage+N-1 of the document.
+type TaskPart struct {
+	Split       string `json:"split"`
+	Title       string `json:"title"`
+	FirstPage   int    `json:"firstPage"`
+	LastPage    int    `json:"lastPage"`
+	SourcePages int    `json:"sourcePages"`
+}
+
+// SplitPart is one task of a split document.
+type SplitPart struct {
+	Title     string      `json:"title"`
+	FirstPage int         `json:"firstPage"`
+	LastPage  int         `json:"lastPage"`
+	Status    BatchStatus `json:"status"`
+	TaskID    string      `json:"taskId,omitempty"`
+	Error     string      `json:"error,omitempty"`
+}
+
+// SplitReport lists the tasks a large document was split into, in
+// document order.
+type SplitReport struct {
+	ID         string       `json:"id"`
+	FileName   string       `json:"fileName"`
+	Title      string       `json:"title"`
+	TotalPages int          `json:"totalPages"`
+	Status     BatchStatus  `json:"status"`
+	CreatedAt  time.Time    `json:"createdAt"`
+	FinishedAt time.Time    `json:"finishedAt,omitempty"`
+	Parts      []*SplitPart `json:"parts"`
+}
+
+// CompareResult is one provider's output for a page comparison.
+type CompareResult struct {
+	Provider    ProviderInfo `json:"provider"`
+	HasText     bool         `json:"hasText"`
+	SourceText  string       `json:"sourceText"`
+	Translation string       `json:"translation"`
+	Error       string       `json:"error,omitempty"`
+	DurationMs  int64        `json:"durationMs"`
+}
+
+// CompareResponse shows several providers' results for the same page side by side.
+type CompareResponse struct {
+	TaskID     string           `json:"taskId"`
+	PageNumber int              `json:"pageNumber"`
+	ImageURL   string           `json:"imageUrl"`
+	Results    []*CompareResult `json:"results"`
+}
+
+// DiffOp is one run of a translation diff: "equal", "insert" or "delete".
+type DiffOp struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+// RevisionInfo describes one side of a page diff.
+type RevisionInfo struct {
+	Provider    ProviderInfo `json:"provider"`
+	HasText     bool         `json:"hasText"`
+	Translation string       `json:"translation"`
+	At          time.Time    `json:"at"`
+}
+
+// PageDiffResponse compares a page's translation with the one it replaced.
+type PageDiffResponse struct {
+	TaskID      string        `json:"taskId"`
+	PageNumber  int           `json:"pageNumber"`
+	Granularity string        `json:"granularity"`
+	Previous    *RevisionInfo `json:"previous"`
+	Current     *RevisionInfo `json:"current"`
+	Ops         []DiffOp      `json:"ops"`
+	Inserted    int           `json:"inserted"`
+	Deleted     int           `json:"deleted"`
+}
+
+// PromptSettings overrides the provider prompts server-wide. Empty fields
+// keep the built-in prompts; Extra is appended to every system prompt.
+type PromptSettings struct {
+	OCRSystem       string `json:"ocrSystem,omitempty"`
+	OCRUser         string `json:"ocrUser,omitempty"`
+	TextSystem      string `json:"textSystem,omitempty"`
+	FormatterSystem string `json:"formatterSystem,omitempty"`
+	Extra           string `json:"extra,omitempty"`
+}
+
+// PromptSettingsResponse shows where the active prompts come from.
+type PromptSettingsResponse struct {
+	// Overrides are saved via the admin API, File comes from PDFTOOL_PROMPTS_FILE.
+	Overrides PromptSettings `json:"overrides"`
+	File      PromptSettings `json:"file"`
+	// Effective are the prompts sent to providers, defaults filled in.
+	Effective PromptSettings `json:"effective"`
+}
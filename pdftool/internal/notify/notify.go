@@ -0,0 +1,177 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// EventKind enumerates notification triggers.
+type EventKind string
+
+const (
+	EventTaskCompleted    EventKind = "task_completed"
+	EventProviderFailures EventKind = "provider_failures"
+	EventBudgetThreshold  EventKind = "budget_threshold"
+	EventStorageThreshold EventKind = "storage_threshold"
+	EventTaskPaused       EventKind = "task_paused"
+)
+
+// Event is delivered to every configured sink.
+type Event struct {
+	Kind     EventKind `json:"kind"`
+	TaskID   string    `json:"taskId,omitempty"`
+	FileName string    `json:"fileName,omitempty"`
+	Message  string    `json:"message"`
+	Time     time.Time `json:"time"`
+}
+
+// Text renders a human readable single-line message.
+func (e Event) Text() string {
+	var b strings.Builder
+	b.WriteString("[pdftool] ")
+	b.WriteString(e.Message)
+	if e.FileName != "" {
+		fmt.Fprintf(&b, "（%s）", e.FileName)
+	}
+	if e.TaskID != "" {
+		fmt.Fprintf(&b, " task=%s", e.TaskID)
+	}
+	return b.String()
+}
+
+// Sink delivers events to one channel.
+type Sink interface {
+	Name() string
+	Send(ctx context.Context, event Event) error
+}
+
+// Config lists the server-wide notification channels; empty values disable a sink.
+type Config struct {
+	SlackWebhookURL  string
+	TelegramBotToken string
+	TelegramChatID   string
+	WebhookURL       string
+	Timeout          time.Duration
+}
+
+// Dispatcher fans events out to all sinks asynchronously.
+type Dispatcher struct {
+	sinks   []Sink
+	timeout time.Duration
+}
+
+// New builds a dispatcher from the configuration. A dispatcher without sinks is a no-op.
+func New(cfg Config) *Dispatcher {
+	client := &http.Client{}
+	d := &Dispatcher{timeout: cfg.Timeout}
+	if d.timeout <= 0 {
+		d.timeout = 10 * time.Second
+	}
+	if url := strings.TrimSpace(cfg.SlackWebhookURL); url != "" {
+		d.sinks = append(d.sinks, &slackSink{client: client, url: url})
+	}
+	if token := strings.TrimSpace(cfg.TelegramBotToken); token != "" && strings.TrimSpace(cfg.TelegramChatID) != "" {
+		d.sinks = append(d.sinks, &telegramSink{client: client, token: token, chatID: strings.TrimSpace(cfg.TelegramChatID)})
+	}
+	if url := strings.TrimSpace(cfg.WebhookURL); url != "" {
+		d.sinks = append(d.sinks, &webhookSink{client: client, url: url})
+	}
+	return d
+}
+
+// AddSink registers an additional sink.
+func (d *Dispatcher) AddSink(sink Sink) {
+	if d == nil || sink == nil {
+		return
+	}
+	d.sinks = append(d.sinks, sink)
+}
+
+// Enabled reports whether any sink is configured.
+func (d *Dispatcher) Enabled() bool {
+	return d != nil && len(d.sinks) > 0
+}
+
+// Notify sends the event to all sinks in the background.
+func (d *Dispatcher) Notify(event Event) {
+	if !d.Enabled() {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	for _, sink := range d.sinks {
+		go func(sink Sink) {
+			ctx, cancel := context.WithTimeout(context.Background(), d.timeout)
+			defer cancel()
+			if err := sink.Send(ctx, event); err != nil {
+				log.Printf("[notify] %s 发送 %s 失败: %v", sink.Name(), event.Kind, err)
+			}
+		}(sink)
+	}
+}
+
+func postJSON(ctx context.Context, client *http.Client, url string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("HTTP %s", resp.Status)
+	}
+	return nil
+}
+
+type slackSink struct {
+	client *http.Client
+	url    string
+}
+
+func (s *slackSink) Name() string { return "slack" }
+
+func (s *slackSink) Send(ctx context.Context, event Event) error {
+	return postJSON(ctx, s.client, s.url, map[string]string{"text": event.Text()})
+}
+
+type telegramSink struct {
+	client *http.Client
+	token  string
+	chatID string
+}
+
+func (s *telegramSink) Name() string { return "telegram" }
+
+func (s *telegramSink) Send(ctx context.Context, event Event) error {
+	url := fmt.Sprintf("https://api.telegram.org/bot%s/sendMessage", s.token)
+	return postJSON(ctx, s.client, url, map[string]string{
+		"chat_id": s.chatID,
+		"text":    event.Text(),
+	})
+}
+
+type webhookSink struct {
+	client *http.Client
+	url    string
+}
+
+func (s *webhookSink) Name() string { return "webhook" }
+
+func (s *webhookSink) Send(ctx context.Context, event Event) error {
+	return postJSON(ctx, s.client, s.url, event)
+}
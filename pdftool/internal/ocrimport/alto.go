@@ -0,0 +1,114 @@
+package ocrimport
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// parseALTO streams String/SP/HYP elements, one line per TextLine and a blank
+// line per TextBlock. Block boxes are kept only for pixel measurement units.
+func parseALTO(data []byte) (parsedLayout, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	var out parsedLayout
+	var blocks []string
+	var lines []string
+	var line strings.Builder
+	pixels := true
+	var box []int
+	var unit strings.Builder
+	inUnit := false
+	flushBlock := func() {
+		if len(lines) > 0 {
+			text := strings.Join(lines, "\n")
+			blocks = append(blocks, text)
+			if pixels && len(box) == 4 {
+				out.blocks = append(out.blocks, Block{Text: text, X0: box[0], Y0: box[1], X1: box[0] + box[2], Y1: box[1] + box[3]})
+			}
+			lines = nil
+		}
+	}
+	for {
+		tok, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return parsedLayout{}, err
+		}
+		switch el := tok.(type) {
+		case xml.StartElement:
+			switch el.Name.Local {
+			case "MeasurementUnit":
+				inUnit = true
+			case "Page":
+				out.width = atoi(attr(el, "WIDTH"))
+				out.height = atoi(attr(el, "HEIGHT"))
+			case "TextBlock":
+				box = altoBox(el)
+			case "String":
+				if line.Len() > 0 && !strings.HasSuffix(line.String(), " ") {
+					line.WriteByte(' ')
+				}
+				line.WriteString(attr(el, "CONTENT"))
+			case "SP":
+				line.WriteByte(' ')
+			case "HYP":
+				line.WriteString(attr(el, "CONTENT"))
+			}
+		case xml.CharData:
+			if inUnit {
+				unit.Write(el)
+			}
+		case xml.EndElement:
+			switch el.Name.Local {
+			case "MeasurementUnit":
+				inUnit = false
+				pixels = strings.EqualFold(strings.TrimSpace(unit.String()), "pixel")
+			case "TextLine":
+				if text := strings.TrimSpace(line.String()); text != "" {
+					lines = append(lines, text)
+				}
+				line.Reset()
+			case "TextBlock":
+				flushBlock()
+				box = nil
+			}
+		}
+	}
+	flushBlock()
+	if !pixels {
+		out.blocks, out.width, out.height = nil, 0, 0
+	}
+	out.text = strings.Join(blocks, "\n\n")
+	return out, nil
+}
+
+// altoBox returns HPOS, VPOS, WIDTH, HEIGHT of an element, or nil when incomplete.
+func altoBox(el xml.StartElement) []int {
+	box := make([]int, 4)
+	for i, name := range []string{"HPOS", "VPOS", "WIDTH", "HEIGHT"} {
+		v, err := strconv.ParseFloat(attr(el, name), 64)
+		if err != nil {
+			return nil
+		}
+		box[i] = int(v)
+	}
+	return box
+}
+
+func atoi(value string) int {
+	v, _ := strconv.ParseFloat(value, 64)
+	return int(v)
+}
+
+func attr(el xml.StartElement, name string) string {
+	for _, a := range el.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,121 @@
+package ocrimport
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// parseHOCR joins ocr_line elements into lines and separates ocr_par blocks with
+// blank lines. Paragraph bounding boxes come from the title="bbox ..." property.
+func parseHOCR(data []byte) (parsedLayout, error) {
+	doc, err := html.Parse(bytes.NewReader(data))
+	if err != nil {
+		return parsedLayout{}, err
+	}
+	var out parsedLayout
+	var paragraphs []string
+	var current []string
+	var box []int
+	flush := func() {
+		if len(current) > 0 {
+			text := strings.Join(current, "\n")
+			paragraphs = append(paragraphs, text)
+			if len(box) == 4 {
+				out.blocks = append(out.blocks, Block{Text: text, X0: box[0], Y0: box[1], X1: box[2], Y1: box[3]})
+			}
+			current = nil
+		}
+	}
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			classes := classList(n)
+			switch {
+			case classes["ocr_page"]:
+				if b := hocrBBox(n); len(b) == 4 {
+					out.width, out.height = b[2]-b[0], b[3]-b[1]
+				}
+			case classes["ocr_par"] || classes["ocr_carea"]:
+				flush()
+				outer := box
+				if b := hocrBBox(n); len(b) == 4 {
+					box = b
+				}
+				for c := n.FirstChild; c != nil; c = c.NextSibling {
+					walk(c)
+				}
+				flush()
+				box = outer
+				return
+			case classes["ocr_line"] || classes["ocrx_line"] || classes["ocr_caption"] || classes["ocr_header"] || classes["ocr_textfloat"]:
+				if line := strings.Join(strings.Fields(textContent(n)), " "); line != "" {
+					current = append(current, line)
+				}
+				return
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+	flush()
+	out.text = strings.Join(paragraphs, "\n\n")
+	return out, nil
+}
+
+// hocrBBox reads "bbox x0 y0 x1 y1" from the element's title properties.
+func hocrBBox(n *html.Node) []int {
+	for _, attr := range n.Attr {
+		if attr.Key != "title" {
+			continue
+		}
+		for _, prop := range strings.Split(attr.Val, ";") {
+			fields := strings.Fields(prop)
+			if len(fields) != 5 || fields[0] != "bbox" {
+				continue
+			}
+			box := make([]int, 4)
+			for i, f := range fields[1:] {
+				v, err := strconv.Atoi(f)
+				if err != nil {
+					return nil
+				}
+				box[i] = v
+			}
+			return box
+		}
+	}
+	return nil
+}
+
+func classList(n *html.Node) map[string]bool {
+	classes := make(map[string]bool)
+	for _, attr := range n.Attr {
+		if attr.Key == "class" {
+			for _, c := range strings.Fields(attr.Val) {
+				classes[c] = true
+			}
+		}
+	}
+	return classes
+}
+
+func textContent(n *html.Node) string {
+	var b strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+			b.WriteByte(' ')
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return b.String()
+}
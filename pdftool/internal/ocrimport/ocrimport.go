@@ -0,0 +1,165 @@
+package ocrimport
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Format identifies the OCR file flavor.
+type Format string
+
+const (
+	FormatHOCR Format = "hocr"
+	FormatALTO Format = "alto"
+	FormatText Format = "txt"
+)
+
+// PageText is OCR output for one page.
+type PageText struct {
+	PageNumber int
+	Format     Format
+	Text       string
+	// Blocks carry paragraph bounding boxes in the OCR page's pixel space
+	// (Width x Height) when the format provides them.
+	Blocks []Block
+	Width  int
+	Height int
+}
+
+// Block is a paragraph of recognized text with its bounding box.
+type Block struct {
+	Text           string
+	X0, Y0, X1, Y1 int
+}
+
+type parsedLayout struct {
+	text          string
+	blocks        []Block
+	width, height int
+}
+
+// MaxUploadBytes caps an uploaded OCR file or archive.
+const MaxUploadBytes = 64 << 20
+
+// Limits guarding against decompression bombs inside uploaded archives.
+const (
+	maxEntryBytes = 16 << 20
+	maxZipEntries = 10000
+	maxZipBytes   = 256 << 20
+)
+
+var pageNumberPattern = regexp.MustCompile(`\d+`)
+
+// DetectFormat infers the OCR flavor from the file name and content.
+func DetectFormat(name string, data []byte) Format {
+	ext := strings.ToLower(path.Ext(name))
+	switch ext {
+	case ".hocr", ".html", ".htm", ".xhtml":
+		return FormatHOCR
+	case ".txt":
+		return FormatText
+	}
+	head := strings.ToLower(string(data[:min(len(data), 2048)]))
+	switch {
+	case strings.Contains(head, "<alto"):
+		return FormatALTO
+	case strings.Contains(head, "ocr_page") || strings.Contains(head, "<html"):
+		return FormatHOCR
+	case ext == ".xml":
+		return FormatALTO
+	}
+	return FormatText
+}
+
+// Parse extracts plain text from a single OCR file.
+func Parse(name string, data []byte) (PageText, error) {
+	format := DetectFormat(name, data)
+	var (
+		parsed parsedLayout
+		err    error
+	)
+	switch format {
+	case FormatHOCR:
+		parsed, err = parseHOCR(data)
+	case FormatALTO:
+		parsed, err = parseALTO(data)
+	default:
+		parsed.text = strings.ReplaceAll(string(data), "\r\n", "\n")
+	}
+	if err != nil {
+		return PageText{}, fmt.Errorf("解析 %s 失败: %w", name, err)
+	}
+	return PageText{
+		Format: format,
+		Text:   strings.TrimSpace(parsed.text),
+		Blocks: parsed.blocks,
+		Width:  parsed.width,
+		Height: parsed.height,
+	}, nil
+}
+
+// PageNumberFromName takes the last number in the file name, e.g. page-012.hocr -> 12.
+func PageNumberFromName(name string) int {
+	matches := pageNumberPattern.FindAllString(path.Base(name), -1)
+	if len(matches) == 0 {
+		return 0
+	}
+	n, err := strconv.Atoi(matches[len(matches)-1])
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+// ParseZip reads every OCR file in the archive, keyed by the page number in its name.
+func ParseZip(data []byte) ([]PageText, error) {
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("读取 ZIP 失败: %w", err)
+	}
+	if len(reader.File) > maxZipEntries {
+		return nil, fmt.Errorf("ZIP 文件过多，最多 %d 个", maxZipEntries)
+	}
+	var pages []PageText
+	var total int64
+	for _, file := range reader.File {
+		if file.FileInfo().IsDir() || strings.HasPrefix(path.Base(file.Name), ".") {
+			continue
+		}
+		pageNumber := PageNumberFromName(file.Name)
+		if pageNumber <= 0 {
+			continue
+		}
+		rc, err := file.Open()
+		if err != nil {
+			return nil, fmt.Errorf("读取 %s 失败: %w", file.Name, err)
+		}
+		content, err := io.ReadAll(io.LimitReader(rc, maxEntryBytes+1))
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("读取 %s 失败: %w", file.Name, err)
+		}
+		if len(content) > maxEntryBytes {
+			return nil, fmt.Errorf("%s 过大，单个文件最多 %d MB", file.Name, maxEntryBytes>>20)
+		}
+		if total += int64(len(content)); total > maxZipBytes {
+			return nil, fmt.Errorf("ZIP 解压后过大，最多 %d MB", maxZipBytes>>20)
+		}
+		page, err := Parse(file.Name, content)
+		if err != nil {
+			return nil, err
+		}
+		page.PageNumber = pageNumber
+		pages = append(pages, page)
+	}
+	if len(pages) == 0 {
+		return nil, fmt.Errorf("ZIP 中没有可识别页码的 OCR 文件")
+	}
+	return pages, nil
+}
@@ -0,0 +1,31 @@
+package pdfutil
+
+import (
+	"fmt"
+	"image"
+	"os"
+)
+
+// blankInkRatio is the share of ink cells below which a page counts as blank.
+const blankInkRatio = 0.0005
+
+// IsBlankImage reports whether a rendered page has (almost) no ink.
+func IsBlankImage(imagePath string) (bool, error) {
+	file, err := os.Open(imagePath)
+	if err != nil {
+		return false, fmt.Errorf("open image: %w", err)
+	}
+	defer file.Close()
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return false, fmt.Errorf("decode image: %w", err)
+	}
+	grid := newInkGrid(img)
+	ink := 0
+	for _, v := range grid.ink {
+		if v {
+			ink++
+		}
+	}
+	return float64(ink) < float64(len(grid.ink))*blankInkRatio, nil
+}
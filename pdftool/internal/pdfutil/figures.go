@@ -0,0 +1,141 @@
+package pdfutil
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gen2brain/go-fitz"
+)
+
+// Figure limits. Smaller images are rules, bullets and ornaments; larger ones
+// are the scan of the whole page, which the page image already shows.
+const (
+	minFigurePixels = 48
+	minFigureArea   = 0.01
+	maxFigureArea   = 0.85
+)
+
+// Figure is an image embedded in a page, stored as the PDF holds it: JPEG
+// data stays JPEG, anything else becomes PNG.
+type Figure struct {
+	// Page is the 1-based page in the document.
+	Page int    `json:"page"`
+	Path string `json:"path"`
+	// Width and Height are the native size in pixels.
+	Width  int `json:"width"`
+	Height int `json:"height"`
+	// X, Y, W and H place the image on the page, in points from the top
+	// left corner.
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+	W float64 `json:"w"`
+	H float64 `json:"h"`
+}
+
+var (
+	figureImage  = regexp.MustCompile(`<img style="([^"]*)" src="data:image/(png|jpeg);base64,([^"]*)"`)
+	figureMatrix = regexp.MustCompile(`matrix\(([^)]*)\)`)
+)
+
+// ExtractFigures writes the images embedded in pages start to end (0-based,
+// end exclusive; 0 means the last page) to destDir, named after the page
+// like rendered pages: page-002-fig-1.png.
+func ExtractFigures(pdfPath, destDir string, start, end int) ([]Figure, error) {
+	doc, err := fitz.New(pdfPath)
+	if err != nil {
+		return nil, fmt.Errorf("open pdf: %w", err)
+	}
+	defer doc.Close()
+
+	if end <= 0 || end > doc.NumPage() {
+		end = doc.NumPage()
+	}
+	if err := os.MkdirAll(destDir, 0o755); err != nil {
+		return nil, fmt.Errorf("create figure dir: %w", err)
+	}
+	var figures []Figure
+	for i := start; i < end; i++ {
+		html, err := doc.HTML(i, false)
+		if err != nil {
+			return nil, fmt.Errorf("extract figures of page %d: %w", i+1, err)
+		}
+		bound, err := doc.Bound(i)
+		if err != nil {
+			return nil, fmt.Errorf("measure page %d: %w", i+1, err)
+		}
+		pageArea := float64(bound.Dx() * bound.Dy())
+		for _, match := range figureImage.FindAllStringSubmatch(html, -1) {
+			data, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(match[3]), ""))
+			if err != nil {
+				continue
+			}
+			cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+			if err != nil || cfg.Width < minFigurePixels || cfg.Height < minFigurePixels {
+				continue
+			}
+			figure := Figure{Page: i + 1, Width: cfg.Width, Height: cfg.Height}
+			figure.X, figure.Y, figure.W, figure.H = figurePlacement(match[1], cfg.Width, cfg.Height)
+			if area := figure.W * figure.H; pageArea > 0 && (area < minFigureArea*pageArea || area > maxFigureArea*pageArea) {
+				continue
+			}
+			ext := ".png"
+			if match[2] == "jpeg" {
+				ext = ".jpg"
+			}
+			figure.Path = filepath.Join(destDir, fmt.Sprintf("page-%03d-fig-%d%s", i+1, countPage(figures, i+1)+1, ext))
+			if err := os.WriteFile(figure.Path, data, 0o644); err != nil {
+				return nil, fmt.Errorf("write figure: %w", err)
+			}
+			figures = append(figures, figure)
+		}
+	}
+	return figures, nil
+}
+
+// figurePlacement reads the box of an image from the CSS transform MuPDF
+// places it with: the matrix applies, in CSS pixels (0.75 pt), around the
+// center of the image at its pixel size.
+func figurePlacement(style string, width, height int) (x, y, w, h float64) {
+	const ptPerPx = 0.75
+	w, h = float64(width)*ptPerPx, float64(height)*ptPerPx
+	match := figureMatrix.FindStringSubmatch(style)
+	if match == nil {
+		return 0, 0, w, h
+	}
+	var m [6]float64
+	fields := strings.Split(match[1], ",")
+	if len(fields) != len(m) {
+		return 0, 0, w, h
+	}
+	for i, field := range fields {
+		m[i], _ = strconv.ParseFloat(strings.TrimSpace(field), 64)
+	}
+	cx, cy := float64(width)/2, float64(height)/2
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, corner := range [][2]float64{{-cx, -cy}, {cx, -cy}, {-cx, cy}, {cx, cy}} {
+		px := m[0]*corner[0] + m[2]*corner[1] + m[4] + cx
+		py := m[1]*corner[0] + m[3]*corner[1] + m[5] + cy
+		minX, maxX = math.Min(minX, px), math.Max(maxX, px)
+		minY, maxY = math.Min(minY, py), math.Max(maxY, py)
+	}
+	return minX * ptPerPx, minY * ptPerPx, (maxX - minX) * ptPerPx, (maxY - minY) * ptPerPx
+}
+
+func countPage(figures []Figure, page int) int {
+	n := 0
+	for _, figure := range figures {
+		if figure.Page == page {
+			n++
+		}
+	}
+	return n
+}
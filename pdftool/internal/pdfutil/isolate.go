@@ -0,0 +1,373 @@
+package pdfutil
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"pdftool/internal/sysmem"
+)
+
+// workerEnv selects the operation of a process started as a render worker.
+const workerEnv = "PDFTOOL_RENDER_WORKER"
+
+const (
+	workerOpRender     = "render"
+	workerOpRenderPage = "render-page"
+	workerOpMetadata   = "metadata"
+	workerOpText       = "text"
+	workerOpOutline    = "outline"
+	workerOpFigures    = "figures"
+)
+
+// workerReply is written to a worker's stdout; stderr carries MuPDF warnings.
+// A worker exiting without a reply was taken down by MuPDF.
+type workerReply struct {
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  string          `json:"error,omitempty"`
+}
+
+// RunWorker serves a render request and exits when the process was started
+// by an isolated Renderer; otherwise it returns immediately. Binaries using
+// isolated rendering must call it first thing in main.
+func RunWorker() {
+	op := os.Getenv(workerEnv)
+	if op == "" {
+		return
+	}
+	var (
+		out   interface{}
+		err   error
+		reply workerReply
+	)
+	switch {
+	case op == workerOpRender && len(os.Args) == 6:
+		var start, end int
+		var opts RenderOptions
+		if start, err = strconv.Atoi(os.Args[3]); err == nil {
+			if end, err = strconv.Atoi(os.Args[4]); err == nil {
+				if err = json.Unmarshal([]byte(os.Args[5]), &opts); err == nil {
+					out, err = renderPagesFrom(context.Background(), os.Args[1], os.Args[2], start, end, opts)
+				}
+			}
+		}
+	case op == workerOpRenderPage && len(os.Args) == 5:
+		var index int
+		var opts RenderOptions
+		if index, err = strconv.Atoi(os.Args[3]); err == nil {
+			if err = json.Unmarshal([]byte(os.Args[4]), &opts); err == nil {
+				out, err = RenderPage(os.Args[1], os.Args[2], index, opts)
+			}
+		}
+	case op == workerOpMetadata && len(os.Args) == 2:
+		out, err = ReadMetadata(os.Args[1])
+	case op == workerOpText && len(os.Args) == 2:
+		out, err = ExtractText(os.Args[1])
+	case op == workerOpOutline && len(os.Args) == 2:
+		out, err = ReadOutline(os.Args[1])
+	case op == workerOpFigures && len(os.Args) == 5:
+		var start, end int
+		if start, err = strconv.Atoi(os.Args[3]); err == nil {
+			if end, err = strconv.Atoi(os.Args[4]); err == nil {
+				out, err = ExtractFigures(os.Args[1], os.Args[2], start, end)
+			}
+		}
+	default:
+		err = fmt.Errorf("unknown render worker request %q", op)
+	}
+	if err == nil {
+		reply.Result, err = json.Marshal(out)
+	}
+	if err != nil {
+		reply.Error = err.Error()
+	}
+	if err := json.NewEncoder(os.Stdout).Encode(reply); err != nil {
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// memoryPoll is how often an isolated worker's resident memory is sampled.
+const memoryPoll = 250 * time.Millisecond
+
+// Renderer rasterizes PDFs either in a worker subprocess, so a document
+// crashing MuPDF only fails its own task, or in-process with panic recovery.
+type Renderer struct {
+	// Isolated runs MuPDF in a re-executed copy of the current binary.
+	Isolated bool
+	// Timeout kills an isolated worker running longer than this; zero waits.
+	Timeout time.Duration
+	// MaxMemory recycles an isolated worker whose resident memory grows
+	// beyond this many bytes: it is killed and rendering resumes in a fresh
+	// worker from the first page not yet written. Zero disables the watchdog.
+	MaxMemory int64
+}
+
+// renderFailure is an isolated worker failure with its RenderStats reason.
+type renderFailure struct {
+	reason string
+	err    error
+}
+
+func (f *renderFailure) Error() string { return f.err.Error() }
+func (f *renderFailure) Unwrap() error { return f.err }
+
+// RenderPages is RenderPagesContext run under the renderer's isolation.
+func (r Renderer) RenderPages(ctx context.Context, pdfPath, destDir string, opts RenderOptions) ([]RenderedPage, error) {
+	return r.RenderPageRange(ctx, pdfPath, destDir, 0, 0, opts)
+}
+
+// RenderPageRange is the package-level RenderPageRange run under the
+// renderer's isolation.
+func (r Renderer) RenderPageRange(ctx context.Context, pdfPath, destDir string, start, end int, opts RenderOptions) ([]RenderedPage, error) {
+	var pages []RenderedPage
+	if !r.Isolated {
+		err := recoverPanic(func() (err error) {
+			pages, err = RenderPageRange(ctx, pdfPath, destDir, start, end, opts)
+			return err
+		})
+		return pages, r.record(workerOpRender, err)
+	}
+	opts, err := opts.Normalize()
+	if err != nil {
+		return nil, err
+	}
+	encoded, err := json.Marshal(opts)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	for {
+		var batch []RenderedPage
+		err := r.runWorker(ctx, workerOpRender, &batch, pdfPath, destDir, strconv.Itoa(start+len(pages)), strconv.Itoa(end), string(encoded))
+		if err == nil {
+			return append(pages, batch...), r.record(workerOpRender, nil)
+		}
+		var failure *renderFailure
+		if !errors.As(err, &failure) || failure.reason != FailureMemory {
+			return nil, r.record(workerOpRender, err)
+		}
+		done := renderedPagesFrom(destDir, start+len(pages), opts)
+		if len(done) == 0 {
+			// Not even one page fits under the limit; a fresh worker would
+			// only run into it again.
+			return nil, r.record(workerOpRender, err)
+		}
+		pages = append(pages, done...)
+		stats.recycle()
+		log.Printf("render worker for %s exceeded %d MB after page %d, continuing in a new worker", pdfPath, r.MaxMemory>>20, len(pages))
+	}
+}
+
+// RenderPage is the package-level RenderPage run under the renderer's isolation.
+func (r Renderer) RenderPage(ctx context.Context, pdfPath, outPath string, index int, opts RenderOptions) (RenderedPage, error) {
+	var page RenderedPage
+	if !r.Isolated {
+		err := recoverPanic(func() (err error) {
+			page, err = RenderPage(pdfPath, outPath, index, opts)
+			return err
+		})
+		return page, r.record(workerOpRender, err)
+	}
+	encoded, err := json.Marshal(opts)
+	if err != nil {
+		return page, err
+	}
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	err = r.runWorker(ctx, workerOpRenderPage, &page, pdfPath, outPath, strconv.Itoa(index), string(encoded))
+	return page, r.record(workerOpRender, err)
+}
+
+// ReadMetadata is the package-level ReadMetadata run under the renderer's isolation.
+func (r Renderer) ReadMetadata(ctx context.Context, pdfPath string) (Metadata, error) {
+	var info Metadata
+	if !r.Isolated {
+		err := recoverPanic(func() (err error) {
+			info, err = ReadMetadata(pdfPath)
+			return err
+		})
+		return info, r.record(workerOpMetadata, err)
+	}
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	err := r.runWorker(ctx, workerOpMetadata, &info, pdfPath)
+	return info, r.record(workerOpMetadata, err)
+}
+
+// ReadOutline is the package-level ReadOutline run under the renderer's isolation.
+func (r Renderer) ReadOutline(ctx context.Context, pdfPath string) (Outline, error) {
+	var outline Outline
+	if !r.Isolated {
+		err := recoverPanic(func() (err error) {
+			outline, err = ReadOutline(pdfPath)
+			return err
+		})
+		return outline, r.record(workerOpOutline, err)
+	}
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	err := r.runWorker(ctx, workerOpOutline, &outline, pdfPath)
+	return outline, r.record(workerOpOutline, err)
+}
+
+// ExtractText is the package-level ExtractText run under the renderer's isolation.
+func (r Renderer) ExtractText(ctx context.Context, pdfPath string) ([]string, error) {
+	var texts []string
+	if !r.Isolated {
+		err := recoverPanic(func() (err error) {
+			texts, err = ExtractText(pdfPath)
+			return err
+		})
+		return texts, r.record(workerOpText, err)
+	}
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	err := r.runWorker(ctx, workerOpText, &texts, pdfPath)
+	return texts, r.record(workerOpText, err)
+}
+
+// ExtractFigures is the package-level ExtractFigures run under the
+// renderer's isolation.
+func (r Renderer) ExtractFigures(ctx context.Context, pdfPath, destDir string, start, end int) ([]Figure, error) {
+	var figures []Figure
+	if !r.Isolated {
+		err := recoverPanic(func() (err error) {
+			figures, err = ExtractFigures(pdfPath, destDir, start, end)
+			return err
+		})
+		return figures, r.record(workerOpFigures, err)
+	}
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	err := r.runWorker(ctx, workerOpFigures, &figures, pdfPath, destDir, strconv.Itoa(start), strconv.Itoa(end))
+	return figures, r.record(workerOpFigures, err)
+}
+
+// record counts the outcome of an operation in the render stats.
+func (r Renderer) record(op string, err error) error {
+	if err == nil {
+		stats.success(op)
+		return nil
+	}
+	reason := FailureError
+	var failure *renderFailure
+	if errors.As(err, &failure) {
+		reason = failure.reason
+	} else if errors.Is(err, errPanic) {
+		reason = FailureCrash
+	}
+	if !errors.Is(err, context.Canceled) {
+		stats.failure(reason, err)
+	}
+	return err
+}
+
+func (r Renderer) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if r.Timeout > 0 {
+		return context.WithTimeout(ctx, r.Timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+func (r Renderer) runWorker(ctx context.Context, op string, out interface{}, args ...string) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("locate render worker: %w", err)
+	}
+	cmd := exec.CommandContext(ctx, exe, args...)
+	cmd.Env = append(os.Environ(), workerEnv+"="+op)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("start render worker: %w", err)
+	}
+	overLimit := make(chan bool, 1)
+	stop := make(chan struct{})
+	go func() {
+		overLimit <- r.watchMemory(cmd.Process, stop)
+	}()
+	err = cmd.Wait()
+	close(stop)
+	if <-overLimit {
+		return &renderFailure{FailureMemory, fmt.Errorf("PDF 渲染内存超出上限（%d MB）", r.MaxMemory>>20)}
+	}
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			if errors.Is(ctxErr, context.DeadlineExceeded) {
+				return &renderFailure{FailureTimeout, fmt.Errorf("PDF 渲染超时（%s）", r.Timeout)}
+			}
+			return ctxErr
+		}
+		log.Printf("render worker for %s exited: %v\n%s", args[0], err, tailString(stderr.String(), 2048))
+		return &renderFailure{FailureCrash, fmt.Errorf("PDF 渲染进程异常退出（文件可能已损坏）: %v", err)}
+	}
+	var reply workerReply
+	if err := json.Unmarshal(stdout.Bytes(), &reply); err != nil {
+		return fmt.Errorf("decode render worker output: %w", err)
+	}
+	if reply.Error != "" {
+		return errors.New(reply.Error)
+	}
+	return json.Unmarshal(reply.Result, out)
+}
+
+// watchMemory samples the worker's resident memory until stop is closed,
+// records its peak and kills it once it exceeds MaxMemory. It reports
+// whether the worker was killed.
+func (r Renderer) watchMemory(proc *os.Process, stop <-chan struct{}) bool {
+	var peak int64
+	defer func() { stats.observePeak(peak) }()
+	ticker := time.NewTicker(memoryPoll)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return false
+		case <-ticker.C:
+		}
+		rss, err := sysmem.ResidentBytes(proc.Pid)
+		if err != nil {
+			continue
+		}
+		if rss > peak {
+			peak = rss
+		}
+		if r.MaxMemory > 0 && rss > r.MaxMemory {
+			proc.Kill()
+			return true
+		}
+	}
+}
+
+func tailString(s string, n int) string {
+	s = strings.TrimSpace(s)
+	if len(s) > n {
+		s = s[len(s)-n:]
+	}
+	return s
+}
+
+// errPanic marks a MuPDF panic recovered in-process.
+var errPanic = errors.New("PDF 渲染失败")
+
+// recoverPanic turns a panic inside fn into an error so one malformed
+// document cannot unwind the whole server.
+func recoverPanic(fn func() error) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("%w（文件可能已损坏）: %v", errPanic, p)
+		}
+	}()
+	return fn()
+}
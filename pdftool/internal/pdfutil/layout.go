@@ -0,0 +1,270 @@
+package pdfutil
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+	"os"
+)
+
+const (
+	layoutCell       = 4    // pixels per analysis cell
+	inkThreshold     = 150  // luminance below which a pixel counts as ink
+	minGapRatio      = 0.01 // blank rows separating horizontal chunks, relative to height
+	minGutterRatio   = 0.015
+	minColumnRatio   = 0.15 // each column must span at least this share of the chunk width
+	minChunkRatio    = 0.03 // chunks shorter than this never define a gutter on their own
+	regionPadCells   = 2
+	maxLayoutRegions = 12
+)
+
+// AnalyzeLayout splits a rendered page into reading-ordered regions. Columns are
+// detected with a recursive whitespace cut: horizontal chunks that share an empty
+// vertical gutter are grouped and read column by column. A page without columns
+// yields a single region covering its content. With rightToLeft, columns are
+// ordered from the right edge, as in vertical CJK and Arabic/Hebrew layouts.
+func AnalyzeLayout(imagePath string, rightToLeft bool) ([]image.Rectangle, error) {
+	file, err := os.Open(imagePath)
+	if err != nil {
+		return nil, fmt.Errorf("open image: %w", err)
+	}
+	defer file.Close()
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return nil, fmt.Errorf("decode image: %w", err)
+	}
+	grid := newInkGrid(img)
+	grid.rightToLeft = rightToLeft
+	all := cellRect{0, 0, grid.w, grid.h}
+	box, ok := grid.inkBounds(all)
+	if !ok {
+		return nil, nil
+	}
+	var cells []cellRect
+	grid.segment(box, &cells, 0)
+	if len(cells) > maxLayoutRegions {
+		cells = []cellRect{box}
+	}
+	bounds := img.Bounds()
+	regions := make([]image.Rectangle, 0, len(cells))
+	for _, c := range cells {
+		r := image.Rect(
+			bounds.Min.X+(c.x0-regionPadCells)*layoutCell,
+			bounds.Min.Y+(c.y0-regionPadCells)*layoutCell,
+			bounds.Min.X+(c.x1+regionPadCells)*layoutCell,
+			bounds.Min.Y+(c.y1+regionPadCells)*layoutCell,
+		).Intersect(bounds)
+		if !r.Empty() {
+			regions = append(regions, r)
+		}
+	}
+	return regions, nil
+}
+
+// CropRegions writes each region of the image to "<prefix>-rNN.png".
+func CropRegions(imagePath string, regions []image.Rectangle, prefix string) ([]string, error) {
+	file, err := os.Open(imagePath)
+	if err != nil {
+		return nil, fmt.Errorf("open image: %w", err)
+	}
+	img, _, err := image.Decode(file)
+	file.Close()
+	if err != nil {
+		return nil, fmt.Errorf("decode image: %w", err)
+	}
+	paths := make([]string, 0, len(regions))
+	for i, r := range regions {
+		crop := image.NewRGBA(image.Rect(0, 0, r.Dx(), r.Dy()))
+		draw.Draw(crop, crop.Bounds(), img, r.Min, draw.Src)
+		outPath := fmt.Sprintf("%s-r%02d.png", prefix, i+1)
+		out, err := os.Create(outPath)
+		if err != nil {
+			return nil, fmt.Errorf("create region file: %w", err)
+		}
+		if err := png.Encode(out, crop); err != nil {
+			out.Close()
+			return nil, fmt.Errorf("encode region %d: %w", i+1, err)
+		}
+		out.Close()
+		paths = append(paths, outPath)
+	}
+	return paths, nil
+}
+
+type cellRect struct{ x0, y0, x1, y1 int }
+
+func (c cellRect) width() int  { return c.x1 - c.x0 }
+func (c cellRect) height() int { return c.y1 - c.y0 }
+
+// inkGrid is a downsampled ink mask of the page.
+type inkGrid struct {
+	w, h        int
+	ink         []bool
+	rightToLeft bool
+}
+
+func newInkGrid(img image.Image) *inkGrid {
+	b := img.Bounds()
+	g := &inkGrid{w: (b.Dx() + layoutCell - 1) / layoutCell, h: (b.Dy() + layoutCell - 1) / layoutCell}
+	g.ink = make([]bool, g.w*g.h)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r, gg, bb, _ := img.At(x, y).RGBA()
+			lum := (299*r + 587*gg + 114*bb) / 1000 >> 8
+			if lum < inkThreshold {
+				g.ink[((y-b.Min.Y)/layoutCell)*g.w+(x-b.Min.X)/layoutCell] = true
+			}
+		}
+	}
+	return g
+}
+
+func (g *inkGrid) at(x, y int) bool { return g.ink[y*g.w+x] }
+
+func (g *inkGrid) rowHasInk(y, x0, x1 int) bool {
+	for x := x0; x < x1; x++ {
+		if g.at(x, y) {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *inkGrid) colHasInk(x, y0, y1 int) bool {
+	for y := y0; y < y1; y++ {
+		if g.at(x, y) {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *inkGrid) inkBounds(r cellRect) (cellRect, bool) {
+	out := cellRect{r.x1, r.y1, r.x0, r.y0}
+	found := false
+	for y := r.y0; y < r.y1; y++ {
+		for x := r.x0; x < r.x1; x++ {
+			if !g.at(x, y) {
+				continue
+			}
+			found = true
+			out.x0 = min(out.x0, x)
+			out.y0 = min(out.y0, y)
+			out.x1 = max(out.x1, x+1)
+			out.y1 = max(out.y1, y+1)
+		}
+	}
+	return out, found
+}
+
+// gutter finds the widest interior blank column band of r, if wide enough.
+func (g *inkGrid) gutter(r cellRect) (int, int, bool) {
+	minGutter := max(1, int(float64(g.w)*minGutterRatio))
+	minColumn := int(float64(r.width()) * minColumnRatio)
+	bestStart, bestEnd := -1, -1
+	start := -1
+	for x := r.x0; x <= r.x1; x++ {
+		blank := x < r.x1 && !g.colHasInk(x, r.y0, r.y1)
+		if blank && start < 0 {
+			start = x
+		}
+		if !blank && start >= 0 {
+			if start-r.x0 >= minColumn && r.x1-x >= minColumn && x-start >= minGutter && x-start > bestEnd-bestStart {
+				bestStart, bestEnd = start, x
+			}
+			start = -1
+		}
+	}
+	return bestStart, bestEnd, bestStart >= 0
+}
+
+// chunks splits r at blank row bands.
+func (g *inkGrid) chunks(r cellRect) []cellRect {
+	minGap := max(1, int(float64(g.h)*minGapRatio))
+	var out []cellRect
+	start, blankRun := -1, 0
+	for y := r.y0; y < r.y1; y++ {
+		if g.rowHasInk(y, r.x0, r.x1) {
+			if start < 0 {
+				start = y
+			} else if blankRun >= minGap {
+				out = append(out, cellRect{r.x0, start, r.x1, y - blankRun})
+				start = y
+			}
+			blankRun = 0
+			continue
+		}
+		blankRun++
+	}
+	if start >= 0 {
+		out = append(out, cellRect{r.x0, start, r.x1, r.y1 - blankRun})
+	}
+	return out
+}
+
+func (g *inkGrid) segment(r cellRect, out *[]cellRect, depth int) {
+	if depth > 4 {
+		*out = append(*out, r)
+		return
+	}
+	if gs, ge, ok := g.gutter(r); ok {
+		sides := []cellRect{{r.x0, r.y0, gs, r.y1}, {ge, r.y0, r.x1, r.y1}}
+		if g.rightToLeft {
+			sides[0], sides[1] = sides[1], sides[0]
+		}
+		for _, side := range sides {
+			if box, ok := g.inkBounds(side); ok {
+				g.segment(box, out, depth+1)
+			}
+		}
+		return
+	}
+	parts := g.chunks(r)
+	if len(parts) < 2 {
+		*out = append(*out, r)
+		return
+	}
+	// Group consecutive chunks that share a column gutter so a two-column body
+	// is read column by column rather than paragraph row by paragraph row.
+	var groups []cellRect
+	var hasGutter []bool
+	for _, part := range parts {
+		if n := len(groups); n > 0 {
+			merged := cellRect{r.x0, groups[n-1].y0, r.x1, part.y1}
+			_, _, mergedGutter := g.gutter(merged)
+			if mergedGutter || !hasGutter[n-1] && !g.hasGutterAlone(part) {
+				groups[n-1] = merged
+				hasGutter[n-1] = mergedGutter
+				continue
+			}
+		}
+		groups = append(groups, part)
+		hasGutter = append(hasGutter, g.hasGutterAlone(part))
+	}
+	if len(groups) == 1 && !hasGutter[0] {
+		*out = append(*out, r)
+		return
+	}
+	for i, group := range groups {
+		box, ok := g.inkBounds(group)
+		if !ok {
+			continue
+		}
+		if hasGutter[i] {
+			g.segment(box, out, depth+1)
+		} else {
+			*out = append(*out, box)
+		}
+	}
+}
+
+// hasGutterAlone ignores short chunks, where word spacing on a single line
+// could pass for a column gutter.
+func (g *inkGrid) hasGutterAlone(r cellRect) bool {
+	if r.height() < int(float64(g.h)*minChunkRatio) {
+		return false
+	}
+	_, _, ok := g.gutter(r)
+	return ok
+}
@@ -0,0 +1,41 @@
+package pdfutil
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gen2brain/go-fitz"
+)
+
+// Metadata holds the document information dictionary entries we care about.
+type Metadata struct {
+	Title    string
+	Author   string
+	Subject  string
+	Keywords string
+}
+
+// ReadMetadata returns the PDF info dictionary of the file.
+func ReadMetadata(pdfPath string) (Metadata, error) {
+	doc, err := fitz.New(pdfPath)
+	if err != nil {
+		return Metadata{}, fmt.Errorf("open pdf: %w", err)
+	}
+	defer doc.Close()
+
+	info := doc.Metadata()
+	return Metadata{
+		Title:    cleanInfoValue(info["title"]),
+		Author:   cleanInfoValue(info["author"]),
+		Subject:  cleanInfoValue(info["subject"]),
+		Keywords: cleanInfoValue(info["keywords"]),
+	}, nil
+}
+
+// cleanInfoValue strips the NUL padding of MuPDF's fixed-size lookup buffer.
+func cleanInfoValue(value string) string {
+	if idx := strings.IndexByte(value, 0); idx >= 0 {
+		value = value[:idx]
+	}
+	return strings.TrimSpace(value)
+}
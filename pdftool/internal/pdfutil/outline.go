@@ -0,0 +1,53 @@
+package pdfutil
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gen2brain/go-fitz"
+)
+
+// OutlineEntry is a bookmark of the document outline.
+type OutlineEntry struct {
+	// Level is the depth in the outline, starting from 1.
+	Level int    `json:"level"`
+	Title string `json:"title"`
+	// Page is the 1-based page the entry points to.
+	Page int `json:"page"`
+}
+
+// Outline is the page count of a document and its bookmarks in outline
+// order. Entries pointing outside the document, such as web links, are
+// left out.
+type Outline struct {
+	Pages   int            `json:"pages"`
+	Entries []OutlineEntry `json:"entries,omitempty"`
+}
+
+// ReadOutline returns the outline of the PDF. A document without one has no
+// entries.
+func ReadOutline(pdfPath string) (Outline, error) {
+	doc, err := fitz.New(pdfPath)
+	if err != nil {
+		return Outline{}, fmt.Errorf("open pdf: %w", err)
+	}
+	defer doc.Close()
+
+	outline := Outline{Pages: doc.NumPage()}
+	toc, err := doc.ToC()
+	if errors.Is(err, fitz.ErrLoadOutline) {
+		return outline, nil
+	}
+	if err != nil {
+		return Outline{}, fmt.Errorf("read outline: %w", err)
+	}
+	for _, item := range toc {
+		title := strings.Join(strings.Fields(item.Title), " ")
+		if title == "" || item.Page < 0 || item.Page >= outline.Pages {
+			continue
+		}
+		outline.Entries = append(outline.Entries, OutlineEntry{Level: item.Level, Title: title, Page: item.Page + 1})
+	}
+	return outline, nil
+}
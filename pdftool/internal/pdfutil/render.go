@@ -1,16 +1,160 @@
 package pdfutil
 
 import (
+	"context"
 	"fmt"
+	"image"
+	"image/jpeg"
 	"image/png"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
 
+	"github.com/HugoSmits86/nativewebp"
 	"github.com/gen2brain/go-fitz"
+	_ "golang.org/x/image/webp"
 )
 
+// Page image formats.
+const (
+	FormatPNG  = "png"
+	FormatJPEG = "jpeg"
+	// FormatWebP is lossless WebP: smaller than PNG for scanned pages, and
+	// unlike JPEG it keeps small print sharp.
+	FormatWebP = "webp"
+)
+
+// Render option defaults and limits.
+const (
+	DefaultDPI         = 300
+	MinDPI             = 36
+	MaxDPI             = 1200
+	DefaultJPEGQuality = 90
+	// MinDimension is the smallest MaxDimension accepted; below it nothing
+	// on a page stays legible.
+	MinDimension = 256
+)
+
+// RenderOptions controls how pages are rasterized. The zero value renders
+// PNGs at DefaultDPI.
+type RenderOptions struct {
+	// DPI is the render resolution; zero uses DefaultDPI.
+	DPI int `json:"dpi,omitempty"`
+	// Format is FormatPNG (default), FormatJPEG or FormatWebP.
+	Format string `json:"format,omitempty"`
+	// JPEGQuality is 1-100 for FormatJPEG; zero uses DefaultJPEGQuality.
+	JPEGQuality int `json:"jpegQuality,omitempty"`
+	// MaxDimension caps the longer side of a page image in pixels by
+	// lowering the DPI of pages that would exceed it; zero is unlimited.
+	MaxDimension int `json:"maxDimension,omitempty"`
+}
+
+// Normalize validates the options and fills in the defaults.
+func (o RenderOptions) Normalize() (RenderOptions, error) {
+	switch o.Format = strings.ToLower(strings.TrimSpace(o.Format)); o.Format {
+	case "":
+		o.Format = FormatPNG
+	case "jpg":
+		o.Format = FormatJPEG
+	case FormatPNG, FormatJPEG, FormatWebP:
+	default:
+		return o, fmt.Errorf("不支持的页面图片格式: %s（可选 png、jpeg、webp）", o.Format)
+	}
+	if o.DPI == 0 {
+		o.DPI = DefaultDPI
+	}
+	if o.DPI < MinDPI || o.DPI > MaxDPI {
+		return o, fmt.Errorf("渲染 DPI 须在 %d 到 %d 之间", MinDPI, MaxDPI)
+	}
+	if o.JPEGQuality == 0 {
+		o.JPEGQuality = DefaultJPEGQuality
+	}
+	if o.JPEGQuality < 1 || o.JPEGQuality > 100 {
+		return o, fmt.Errorf("JPEG 质量须在 1 到 100 之间")
+	}
+	if o.MaxDimension < 0 || (o.MaxDimension > 0 && o.MaxDimension < MinDimension) {
+		return o, fmt.Errorf("图片最大边长须不小于 %d 像素", MinDimension)
+	}
+	return o, nil
+}
+
+// Ext is the file extension of page images in the options' format.
+func (o RenderOptions) Ext() string {
+	switch o.Format {
+	case FormatJPEG:
+		return ".jpg"
+	case FormatWebP:
+		return ".webp"
+	}
+	return ".png"
+}
+
+// pageDPI is the resolution page i is rendered at: the configured DPI,
+// lowered when the page would come out larger than MaxDimension.
+func (o RenderOptions) pageDPI(doc *fitz.Document, i int) (float64, error) {
+	dpi := float64(o.DPI)
+	if o.MaxDimension <= 0 {
+		return dpi, nil
+	}
+	// Bound is in whole points, 72 per inch; one more point covers the
+	// fraction it drops.
+	bounds, err := doc.Bound(i)
+	if err != nil {
+		return 0, fmt.Errorf("measure page %d: %w", i+1, err)
+	}
+	side := max(bounds.Dx(), bounds.Dy()) + 1
+	return min(dpi, float64(o.MaxDimension)*72/float64(side)), nil
+}
+
+func (o RenderOptions) encode(w io.Writer, img image.Image) error {
+	switch o.Format {
+	case FormatJPEG:
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: o.JPEGQuality})
+	case FormatWebP:
+		return nativewebp.Encode(w, img, nil)
+	}
+	return png.Encode(w, img)
+}
+
+// RenderedPage describes an image produced for a single PDF page.
+type RenderedPage struct {
+	Path   string
+	Width  int
+	Height int
+}
+
 // RenderPages converts every page from the source PDF into a PNG image.
-func RenderPages(pdfPath, destDir string) ([]string, error) {
+func RenderPages(pdfPath, destDir string) ([]RenderedPage, error) {
+	return RenderPagesContext(context.Background(), pdfPath, destDir, RenderOptions{})
+}
+
+// RenderPagesContext renders every page with opts, stopping between pages
+// once ctx is done.
+func RenderPagesContext(ctx context.Context, pdfPath, destDir string, opts RenderOptions) ([]RenderedPage, error) {
+	opts, err := opts.Normalize()
+	if err != nil {
+		return nil, err
+	}
+	return renderPagesFrom(ctx, pdfPath, destDir, 0, 0, opts)
+}
+
+// RenderPageRange renders the pages from index start (0-based) up to but
+// not including end; end 0 renders to the last page. Images keep the names
+// of their page in the whole document.
+func RenderPageRange(ctx context.Context, pdfPath, destDir string, start, end int, opts RenderOptions) ([]RenderedPage, error) {
+	opts, err := opts.Normalize()
+	if err != nil {
+		return nil, err
+	}
+	return renderPagesFrom(ctx, pdfPath, destDir, start, end, opts)
+}
+
+// renderPagesFrom renders the pages from index start up to end, or to the
+// last page when end is 0. Each image is written under a temporary name and
+// renamed once complete, so a render killed midway leaves only whole pages
+// behind. opts must be normalized.
+func renderPagesFrom(ctx context.Context, pdfPath, destDir string, start, end int, opts RenderOptions) ([]RenderedPage, error) {
 	if err := os.MkdirAll(destDir, 0o755); err != nil {
 		return nil, fmt.Errorf("create output dir: %w", err)
 	}
@@ -26,24 +170,91 @@ func RenderPages(pdfPath, destDir string) ([]string, error) {
 		return nil, fmt.Errorf("pdf has no pages")
 	}
 
-	var paths []string
-	for i := 0; i < total; i++ {
-		img, err := doc.Image(i)
-		if err != nil {
-			return nil, fmt.Errorf("render page %d: %w", i+1, err)
+	if end <= 0 || end > total {
+		end = total
+	}
+	var pages []RenderedPage
+	for i := start; i < end; i++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
 		}
-		outPath := filepath.Join(destDir, fmt.Sprintf("page-%03d.png", i+1))
-		outFile, err := os.Create(outPath)
+		page, err := renderPage(doc, i, pagePath(destDir, i, opts), opts)
 		if err != nil {
-			return nil, fmt.Errorf("create image file: %w", err)
-		}
-		if err := png.Encode(outFile, img); err != nil {
-			outFile.Close()
-			return nil, fmt.Errorf("encode page %d: %w", i+1, err)
+			return nil, err
 		}
+		pages = append(pages, page)
+	}
+
+	return pages, nil
+}
+
+// RenderPage renders the page at index (0-based) of the PDF to an image at
+// outPath, replacing it only once the new image is complete.
+func RenderPage(pdfPath, outPath string, index int, opts RenderOptions) (RenderedPage, error) {
+	opts, err := opts.Normalize()
+	if err != nil {
+		return RenderedPage{}, err
+	}
+	doc, err := fitz.New(pdfPath)
+	if err != nil {
+		return RenderedPage{}, fmt.Errorf("open pdf: %w", err)
+	}
+	defer doc.Close()
+	if index < 0 || index >= doc.NumPage() {
+		return RenderedPage{}, fmt.Errorf("pdf has no page %d", index+1)
+	}
+	return renderPage(doc, index, outPath, opts)
+}
+
+func renderPage(doc *fitz.Document, i int, outPath string, opts RenderOptions) (RenderedPage, error) {
+	dpi, err := opts.pageDPI(doc, i)
+	if err != nil {
+		return RenderedPage{}, err
+	}
+	img, err := doc.ImageDPI(i, dpi)
+	if err != nil {
+		return RenderedPage{}, fmt.Errorf("render page %d: %w", i+1, err)
+	}
+	tmpPath := outPath + ".tmp"
+	outFile, err := os.Create(tmpPath)
+	if err != nil {
+		return RenderedPage{}, fmt.Errorf("create image file: %w", err)
+	}
+	if err := opts.encode(outFile, img); err != nil {
 		outFile.Close()
-		paths = append(paths, outPath)
+		os.Remove(tmpPath)
+		return RenderedPage{}, fmt.Errorf("encode page %d: %w", i+1, err)
+	}
+	if err := outFile.Close(); err != nil {
+		os.Remove(tmpPath)
+		return RenderedPage{}, fmt.Errorf("write page %d: %w", i+1, err)
+	}
+	if err := os.Rename(tmpPath, outPath); err != nil {
+		return RenderedPage{}, fmt.Errorf("write page %d: %w", i+1, err)
 	}
+	bounds := img.Bounds()
+	return RenderedPage{Path: outPath, Width: bounds.Dx(), Height: bounds.Dy()}, nil
+}
+
+func pagePath(destDir string, index int, opts RenderOptions) string {
+	return filepath.Join(destDir, fmt.Sprintf("page-%03d%s", index+1, opts.Ext()))
+}
 
-	return paths, nil
+// renderedPagesFrom lists the complete page images already written from
+// index start on, stopping at the first missing page.
+func renderedPagesFrom(destDir string, start int, opts RenderOptions) []RenderedPage {
+	var pages []RenderedPage
+	for i := start; ; i++ {
+		path := pagePath(destDir, i, opts)
+		f, err := os.Open(path)
+		if err != nil {
+			return pages
+		}
+		cfg, _, err := image.DecodeConfig(f)
+		f.Close()
+		if err != nil {
+			return pages
+		}
+		pages = append(pages, RenderedPage{Path: path, Width: cfg.Width, Height: cfg.Height})
+	}
 }
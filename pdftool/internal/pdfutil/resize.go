@@ -0,0 +1,59 @@
+package pdfutil
+
+import (
+	"fmt"
+	"image"
+	"image/jpeg"
+	_ "image/png"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/image/draw"
+)
+
+// ResizeJPEGQuality is the JPEG quality of resized page derivatives.
+const ResizeJPEGQuality = 85
+
+// ResizeImage scales the image to the given width, keeping the aspect ratio,
+// and writes it as JPEG to destPath. The file is replaced atomically so
+// concurrent requests never read a partial derivative.
+func ResizeImage(imagePath, destPath string, width int) error {
+	file, err := os.Open(imagePath)
+	if err != nil {
+		return fmt.Errorf("open image: %w", err)
+	}
+	img, _, err := image.Decode(file)
+	file.Close()
+	if err != nil {
+		return fmt.Errorf("decode image: %w", err)
+	}
+	b := img.Bounds()
+	if width <= 0 || b.Dx() == 0 {
+		return fmt.Errorf("invalid resize width %d", width)
+	}
+	height := b.Dy() * width / b.Dx()
+	if height < 1 {
+		height = 1
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, b, draw.Src, nil)
+
+	if err := os.MkdirAll(filepath.Dir(destPath), 0o755); err != nil {
+		return fmt.Errorf("create resize dir: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(destPath), ".resize-*")
+	if err != nil {
+		return fmt.Errorf("create resized file: %w", err)
+	}
+	if err := jpeg.Encode(tmp, dst, &jpeg.Options{Quality: ResizeJPEGQuality}); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("encode resized image: %w", err)
+	}
+	tmp.Close()
+	if err := os.Rename(tmp.Name(), destPath); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("save resized image: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,238 @@
+package pdfutil
+
+import (
+	"bytes"
+	"compress/zlib"
+	"io"
+	"regexp"
+	"sort"
+)
+
+// Active content features reported by FindActiveContent.
+const (
+	FeatureJavaScript   = "javascript"
+	FeatureEmbeddedFile = "embedded_file"
+	FeatureLaunch       = "launch_action"
+	// FeatureUndecodable marks an object stream that could not be fully
+	// decoded (unsupported filter chains, encryption, corrupt data), so
+	// active content inside it cannot be ruled out.
+	FeatureUndecodable = "undecodable_object_stream"
+)
+
+// activeNames maps the PDF names introducing active content to their feature.
+var activeNames = map[string]string{
+	"JavaScript":    FeatureJavaScript,
+	"JS":            FeatureJavaScript,
+	"EmbeddedFile":  FeatureEmbeddedFile,
+	"EmbeddedFiles": FeatureEmbeddedFile,
+	"Launch":        FeatureLaunch,
+}
+
+// pdfName matches a name token, including #xx escapes used to hide keywords.
+var pdfName = regexp.MustCompile(`/(?:[^\x00\t\n\f\r ()<>\[\]{}/%#]|#[0-9A-Fa-f]{2})+`)
+
+// maxObjectStream bounds how much of a compressed object stream is inflated;
+// larger streams count as undecodable.
+const maxObjectStream = 64 << 20
+
+// streamFilters lists the names of the standard stream filters, full and
+// abbreviated.
+var streamFilters = map[string]bool{
+	"FlateDecode": true, "Fl": true,
+	"ASCIIHexDecode": true, "AHx": true,
+	"ASCII85Decode": true, "A85": true,
+	"LZWDecode": true, "LZW": true,
+	"RunLengthDecode": true, "RL": true,
+	"CCITTFaxDecode": true, "CCF": true,
+	"DCTDecode": true, "DCT": true,
+	"JBIG2Decode": true, "JPXDecode": true, "Crypt": true,
+}
+
+// ActiveContent lists the active content found in a PDF.
+type ActiveContent struct {
+	// Features are the sorted, distinct feature names.
+	Features []string
+	// Hidden is set when some of it sits in compressed object streams,
+	// which Disarm cannot rewrite in place.
+	Hidden bool
+	spans  [][2]int
+}
+
+// FindActiveContent scans the raw PDF for JavaScript, embedded files and
+// launch actions. Stream data is skipped except for compressed object
+// streams, which are inflated and searched.
+func FindActiveContent(data []byte) ActiveContent {
+	var found ActiveContent
+	seen := make(map[string]bool)
+	note := func(feature string) {
+		if !seen[feature] {
+			seen[feature] = true
+			found.Features = append(found.Features, feature)
+		}
+	}
+	pos := 0
+	for pos < len(data) {
+		keyword, start, end := nextStream(data, pos)
+		limit := len(data)
+		if keyword >= 0 {
+			limit = keyword
+		}
+		for _, loc := range pdfName.FindAllIndex(data[pos:limit], -1) {
+			nameStart, nameEnd := pos+loc[0]+1, pos+loc[1]
+			if feature, ok := activeNames[decodeName(data[nameStart:nameEnd])]; ok {
+				note(feature)
+				found.spans = append(found.spans, [2]int{nameStart, nameEnd})
+			}
+		}
+		if keyword < 0 {
+			break
+		}
+		if names := streamDictNames(data[pos:keyword]); names["ObjStm"] > 0 {
+			for _, feature := range objectStreamFeatures(names, data[start:end]) {
+				note(feature)
+				found.Hidden = true
+			}
+		}
+		pos = end
+	}
+	sort.Strings(found.Features)
+	return found
+}
+
+// Disarm neutralizes the found names in place by swapping the case of their
+// letters (/JavaScript becomes /jAVAsCRIPT), which viewers ignore. The file
+// length and xref offsets stay unchanged.
+func (a ActiveContent) Disarm(data []byte) {
+	for _, span := range a.spans {
+		for i := span[0]; i < span[1]; i++ {
+			if data[i] == '#' && i+2 < span[1] {
+				b := unhex(data[i+1])<<4 | unhex(data[i+2])
+				if isLetter(b) {
+					const digits = "0123456789ABCDEF"
+					b ^= 0x20
+					data[i+1], data[i+2] = digits[b>>4], digits[b&0x0f]
+				}
+				i += 2
+				continue
+			}
+			if isLetter(data[i]) {
+				data[i] ^= 0x20
+			}
+		}
+	}
+}
+
+// nextStream finds the next "stream" keyword at or after from and returns its
+// offset, the start of the stream data and the offset after "endstream";
+// keyword is -1 when there is none.
+func nextStream(data []byte, from int) (keyword, start, end int) {
+	for from < len(data) {
+		idx := bytes.Index(data[from:], []byte("stream"))
+		if idx < 0 {
+			return -1, 0, 0
+		}
+		keyword = from + idx
+		start = keyword + len("stream")
+		if keyword >= 3 && string(data[keyword-3:keyword]) == "end" {
+			from = start
+			continue
+		}
+		if start < len(data) && data[start] == '\r' {
+			start++
+		}
+		if start < len(data) && data[start] == '\n' {
+			start++
+		}
+		stop := bytes.Index(data[start:], []byte("endstream"))
+		if stop < 0 {
+			return keyword, start, len(data)
+		}
+		return keyword, start, start + stop + len("endstream")
+	}
+	return -1, 0, 0
+}
+
+// streamDictNames counts the names, with #xx escapes decoded, of the
+// dictionary before a stream keyword.
+func streamDictNames(before []byte) map[string]int {
+	if idx := bytes.LastIndex(before, []byte("obj")); idx >= 0 {
+		before = before[idx:]
+	}
+	names := make(map[string]int)
+	for _, loc := range pdfName.FindAllIndex(before, -1) {
+		names[decodeName(before[loc[0]+1:loc[1]])]++
+	}
+	return names
+}
+
+// objectStreamFeatures decodes an object stream and searches it. Only
+// unfiltered and plain Flate streams are decoded; any other filter chain,
+// decode parameters, an indirect filter or inflate errors report
+// FeatureUndecodable.
+func objectStreamFeatures(dict map[string]int, raw []byte) []string {
+	raw = bytes.TrimSuffix(raw, []byte("endstream"))
+	filters := 0
+	for name, count := range dict {
+		if streamFilters[name] {
+			filters += count
+		}
+	}
+	var plain []byte
+	switch {
+	case dict["DecodeParms"] > 0 || dict["DP"] > 0:
+		return []string{FeatureUndecodable}
+	case filters == 0 && dict["Filter"] == 0:
+		plain = raw
+	case filters == 1 && dict["FlateDecode"]+dict["Fl"] == 1:
+		zr, err := zlib.NewReader(bytes.NewReader(raw))
+		if err != nil {
+			return []string{FeatureUndecodable}
+		}
+		defer zr.Close()
+		plain, err = io.ReadAll(io.LimitReader(zr, maxObjectStream+1))
+		if err != nil || len(plain) > maxObjectStream {
+			return []string{FeatureUndecodable}
+		}
+	default:
+		return []string{FeatureUndecodable}
+	}
+	var features []string
+	for _, loc := range pdfName.FindAllIndex(plain, -1) {
+		if feature, ok := activeNames[decodeName(plain[loc[0]+1:loc[1]])]; ok {
+			features = append(features, feature)
+		}
+	}
+	return features
+}
+
+func decodeName(raw []byte) string {
+	if bytes.IndexByte(raw, '#') < 0 {
+		return string(raw)
+	}
+	out := make([]byte, 0, len(raw))
+	for i := 0; i < len(raw); i++ {
+		if raw[i] == '#' && i+2 < len(raw) {
+			out = append(out, unhex(raw[i+1])<<4|unhex(raw[i+2]))
+			i += 2
+			continue
+		}
+		out = append(out, raw[i])
+	}
+	return string(out)
+}
+
+func unhex(c byte) byte {
+	switch {
+	case c >= '0' && c <= '9':
+		return c - '0'
+	case c >= 'a' && c <= 'f':
+		return c - 'a' + 10
+	case c >= 'A' && c <= 'F':
+		return c - 'A' + 10
+	}
+	return 0
+}
+
+func isLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
@@ -0,0 +1,97 @@
+package pdfutil
+
+import (
+	"sync"
+	"time"
+)
+
+// Render failure reasons reported in RenderStats.
+const (
+	FailureError   = "error"
+	FailureCrash   = "crash"
+	FailureTimeout = "timeout"
+	FailureMemory  = "memory"
+)
+
+// RenderStats summarizes rendering since the process started.
+type RenderStats struct {
+	// Completed counts successful operations by operation ("render",
+	// "metadata", "text", "outline", "figures").
+	Completed map[string]int64
+	// Failures counts failed operations by reason.
+	Failures map[string]int64
+	// Recycles counts workers restarted for exceeding the memory limit
+	// while rendering continued in a fresh one.
+	Recycles int64
+	// LastWorkerPeak and MaxWorkerPeak are resident set sizes of isolated
+	// workers in bytes; zero when memory is not observable.
+	LastWorkerPeak int64
+	MaxWorkerPeak  int64
+	// ConsecutiveFailures counts crashes, timeouts and memory failures since
+	// the last success; plain errors from malformed files do not count.
+	ConsecutiveFailures int
+	LastFailure         string
+	LastFailureAt       time.Time
+}
+
+type renderStats struct {
+	mu    sync.Mutex
+	stats RenderStats
+}
+
+var stats = &renderStats{stats: RenderStats{
+	Completed: make(map[string]int64),
+	Failures:  make(map[string]int64),
+}}
+
+// Stats returns a snapshot of the render counters.
+func Stats() RenderStats {
+	stats.mu.Lock()
+	defer stats.mu.Unlock()
+	out := stats.stats
+	out.Completed = make(map[string]int64, len(stats.stats.Completed))
+	for k, v := range stats.stats.Completed {
+		out.Completed[k] = v
+	}
+	out.Failures = make(map[string]int64, len(stats.stats.Failures))
+	for k, v := range stats.stats.Failures {
+		out.Failures[k] = v
+	}
+	return out
+}
+
+func (s *renderStats) success(op string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats.Completed[op]++
+	s.stats.ConsecutiveFailures = 0
+}
+
+func (s *renderStats) failure(reason string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats.Failures[reason]++
+	if reason != FailureError {
+		s.stats.ConsecutiveFailures++
+	}
+	s.stats.LastFailure = err.Error()
+	s.stats.LastFailureAt = time.Now()
+}
+
+func (s *renderStats) recycle() {
+	s.mu.Lock()
+	s.stats.Recycles++
+	s.mu.Unlock()
+}
+
+func (s *renderStats) observePeak(peak int64) {
+	if peak <= 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats.LastWorkerPeak = peak
+	if peak > s.stats.MaxWorkerPeak {
+		s.stats.MaxWorkerPeak = peak
+	}
+}
@@ -0,0 +1,29 @@
+package pdfutil
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gen2brain/go-fitz"
+)
+
+// ExtractText returns the embedded text layer of every page, in page order.
+// Pages without one, such as scans, give empty strings. The text comes in
+// MuPDF's reading order with one line per text line.
+func ExtractText(pdfPath string) ([]string, error) {
+	doc, err := fitz.New(pdfPath)
+	if err != nil {
+		return nil, fmt.Errorf("open pdf: %w", err)
+	}
+	defer doc.Close()
+
+	texts := make([]string, doc.NumPage())
+	for i := range texts {
+		text, err := doc.Text(i)
+		if err != nil {
+			return nil, fmt.Errorf("extract text of page %d: %w", i+1, err)
+		}
+		texts[i] = strings.TrimSpace(text)
+	}
+	return texts, nil
+}
@@ -0,0 +1,134 @@
+package publish
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Destination receives finished export files.
+type Destination interface {
+	// Upload stores data under name and returns the remote URL.
+	Upload(ctx context.Context, name string, data []byte) (string, error)
+}
+
+// Config holds server-wide credentials used by task destinations.
+type Config struct {
+	S3Endpoint  string
+	S3Region    string
+	S3AccessKey string
+	S3SecretKey string
+	// S3Buckets lists the buckets tasks may export to.
+	S3Buckets []string
+	// WebDAVURL is the configured WebDAV folder; its credentials are only
+	// sent to destinations below it.
+	WebDAVURL      string
+	WebDAVUser     string
+	WebDAVPassword string
+	// AllowedDestinations lists further s3:// or http(s):// prefixes tasks
+	// may export to, without the WebDAV credentials.
+	AllowedDestinations []string
+	Timeout             time.Duration
+}
+
+// Publisher resolves per-task destination strings into uploaders.
+type Publisher struct {
+	cfg    Config
+	client *http.Client
+}
+
+// New builds a publisher.
+func New(cfg Config) *Publisher {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Minute
+	}
+	if strings.TrimSpace(cfg.S3Region) == "" {
+		cfg.S3Region = "us-east-1"
+	}
+	return &Publisher{cfg: cfg, client: &http.Client{Timeout: cfg.Timeout}}
+}
+
+// Validate checks that a destination string is usable without uploading anything.
+func (p *Publisher) Validate(destination string) error {
+	_, err := p.Resolve(destination)
+	return err
+}
+
+// Resolve parses destinations of the form s3://bucket/prefix or
+// http(s)://webdav/folder. Only destinations below the configured WebDAV
+// URL, an allowed S3 bucket or an AllowedDestinations entry are accepted.
+func (p *Publisher) Resolve(destination string) (Destination, error) {
+	destination = strings.TrimSpace(destination)
+	u, err := url.Parse(destination)
+	if err != nil || u.Host == "" || u.User != nil {
+		return nil, fmt.Errorf("无效的导出目标: %q", destination)
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "s3":
+		if p.cfg.S3AccessKey == "" || p.cfg.S3SecretKey == "" {
+			return nil, fmt.Errorf("未配置 S3 访问凭证")
+		}
+		if !containsFold(p.cfg.S3Buckets, u.Host) && !p.allowed(u) {
+			return nil, fmt.Errorf("导出目标不在允许列表中: %s", destination)
+		}
+		return &s3Destination{
+			client: p.client,
+			cfg:    p.cfg,
+			bucket: u.Host,
+			prefix: strings.Trim(u.Path, "/"),
+		}, nil
+	case "http", "https":
+		dest := &webDAVDestination{client: p.client, baseURL: strings.TrimRight(destination, "/")}
+		if base, err := url.Parse(strings.TrimSpace(p.cfg.WebDAVURL)); err == nil && base.Host != "" && underPrefix(u, base) {
+			dest.user, dest.password = p.cfg.WebDAVUser, p.cfg.WebDAVPassword
+			return dest, nil
+		}
+		if !p.allowed(u) {
+			return nil, fmt.Errorf("导出目标不在允许列表中: %s", destination)
+		}
+		return dest, nil
+	default:
+		return nil, fmt.Errorf("不支持的导出目标类型: %s", u.Scheme)
+	}
+}
+
+// allowed reports whether u lies below one of the AllowedDestinations.
+func (p *Publisher) allowed(u *url.URL) bool {
+	for _, raw := range p.cfg.AllowedDestinations {
+		prefix, err := url.Parse(strings.TrimSpace(raw))
+		if err != nil || prefix.Host == "" {
+			continue
+		}
+		if underPrefix(u, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// underPrefix compares scheme and host exactly and requires u's path to be
+// prefix's path or a folder below it; ".." segments never match.
+func underPrefix(u, prefix *url.URL) bool {
+	if !strings.EqualFold(u.Scheme, prefix.Scheme) || !strings.EqualFold(u.Host, prefix.Host) {
+		return false
+	}
+	p := u.EscapedPath()
+	if strings.Contains(p, "..") || strings.Contains(strings.ToLower(p), "%2e%2e") {
+		return false
+	}
+	base := strings.TrimRight(prefix.EscapedPath(), "/")
+	p = strings.TrimRight(p, "/")
+	return p == base || strings.HasPrefix(p, base+"/")
+}
+
+func containsFold(list []string, value string) bool {
+	for _, item := range list {
+		if strings.EqualFold(strings.TrimSpace(item), value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,110 @@
+package publish
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+// s3Destination uploads with path-style URLs and AWS Signature V4, which also
+// works with S3-compatible stores such as MinIO.
+type s3Destination struct {
+	client *http.Client
+	cfg    Config
+	bucket string
+	prefix string
+}
+
+func (d *s3Destination) endpoint() string {
+	if ep := strings.TrimRight(strings.TrimSpace(d.cfg.S3Endpoint), "/"); ep != "" {
+		return ep
+	}
+	return fmt.Sprintf("https://s3.%s.amazonaws.com", d.cfg.S3Region)
+}
+
+func (d *s3Destination) Upload(ctx context.Context, name string, data []byte) (string, error) {
+	key := path.Join(d.prefix, name)
+	canonicalURI := "/" + escapePath(d.bucket) + "/" + escapePath(key)
+	target := d.endpoint() + canonicalURI
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, target, bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	payloadHash := sha256Hex(data)
+	now := time.Now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", payloadHash)
+	req.Header.Set("Authorization", d.authorization(req.URL.Host, canonicalURI, amzDate, payloadHash))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("上传 S3 失败: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return "", fmt.Errorf("上传 S3 失败: %s %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return target, nil
+}
+
+func (d *s3Destination) authorization(host, canonicalURI, amzDate, payloadHash string) string {
+	const signedHeaders = "host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		http.MethodPut,
+		canonicalURI,
+		"",
+		"host:" + host + "\nx-amz-content-sha256:" + payloadHash + "\nx-amz-date:" + amzDate + "\n",
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	day := amzDate[:8]
+	scope := day + "/" + d.cfg.S3Region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+	key := hmacSHA256([]byte("AWS4"+d.cfg.S3SecretKey), day)
+	key = hmacSHA256(key, d.cfg.S3Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	return fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", d.cfg.S3AccessKey, scope, signedHeaders, signature)
+}
+
+// escapePath URI-encodes everything except unreserved characters and '/', as required by SigV4.
+func escapePath(p string) string {
+	var b strings.Builder
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+		if ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' || c == '/' {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,39 @@
+package publish
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+type webDAVDestination struct {
+	client   *http.Client
+	baseURL  string
+	user     string
+	password string
+}
+
+func (d *webDAVDestination) Upload(ctx context.Context, name string, data []byte) (string, error) {
+	target := d.baseURL + "/" + url.PathEscape(name)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, target, bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	if d.user != "" || d.password != "" {
+		req.SetBasicAuth(d.user, d.password)
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("上传 WebDAV 失败: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return "", fmt.Errorf("上传 WebDAV 失败: %s %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return target, nil
+}
@@ -0,0 +1,73 @@
+// Package respcache is an optional on-disk cache of provider responses, used
+// during development so repeated runs with identical inputs do not re-spend tokens.
+package respcache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Cache stores response bodies under dir, one file per key. A nil *Cache is a
+// valid, disabled cache.
+type Cache struct {
+	dir string
+}
+
+// New returns a cache rooted at dir, or nil when dir is empty.
+func New(dir string) (*Cache, error) {
+	dir = strings.TrimSpace(dir)
+	if dir == "" {
+		return nil, nil
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("创建响应缓存目录失败: %w", err)
+	}
+	return &Cache{dir: dir}, nil
+}
+
+// Key hashes the request identity (provider, model, prompts, payload).
+func Key(parts ...[]byte) string {
+	h := sha256.New()
+	for _, part := range parts {
+		fmt.Fprintf(h, "%d:", len(part))
+		h.Write(part)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Get returns the cached response for key.
+func (c *Cache) Get(key string) ([]byte, bool) {
+	if c == nil {
+		return nil, false
+	}
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return nil, false
+	}
+	return data, true
+}
+
+// Put stores a response for key.
+func (c *Cache) Put(key string, data []byte) error {
+	if c == nil {
+		return nil
+	}
+	path := c.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// path shards entries by the first two hex digits to keep directories small.
+func (c *Cache) path(key string) string {
+	return filepath.Join(c.dir, key[:2], key)
+}
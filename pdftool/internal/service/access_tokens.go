@@ -0,0 +1,188 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"pdftool/internal/model"
+)
+
+const (
+	maxAccessTokens       = 20
+	maxAccessTokenLabel   = 100
+	maxAccessTokenTTLDays = 3650
+)
+
+// ErrAccessTokenNotFound is returned when revoking an unknown token.
+var ErrAccessTokenNotFound = errors.New("访问令牌不存在")
+
+func hashAccessToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// AccessTokensFile lists a task's access tokens in its directory; it is
+// never served by the static route.
+const AccessTokensFile = "access_tokens.json"
+
+func (s *TaskService) accessTokensPath(taskID string) string {
+	return filepath.Join(s.taskDir(taskID), AccessTokensFile)
+}
+
+// loadAccessTokensLocked reads the task's tokens; callers hold s.mu.
+func (s *TaskService) loadAccessTokensLocked(taskID string) ([]*model.AccessToken, error) {
+	data, err := s.readStored(s.accessTokensPath(taskID))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("读取访问令牌失败: %w", err)
+	}
+	var tokens []*model.AccessToken
+	if err := json.Unmarshal(data, &tokens); err != nil {
+		return nil, fmt.Errorf("解析访问令牌失败: %w", err)
+	}
+	return tokens, nil
+}
+
+func (s *TaskService) saveAccessTokensLocked(taskID string, tokens []*model.AccessToken) error {
+	data, err := json.MarshalIndent(tokens, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := s.accessTokensPath(taskID)
+	tmp := path + ".tmp"
+	if err := s.writeTaskFile(s.taskDir(taskID), tmp, data); err != nil {
+		return fmt.Errorf("写入访问令牌失败: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// CreateAccessToken issues a token that only grants reading the task's
+// files through the static route; a zero ttl never expires. The returned
+// entry is the only place the token itself appears.
+func (s *TaskService) CreateAccessToken(taskID, label string, ttl time.Duration) (*model.AccessToken, error) {
+	label = strings.TrimSpace(label)
+	if len([]rune(label)) > maxAccessTokenLabel {
+		return nil, fmt.Errorf("令牌备注不能超过 %d 个字符", maxAccessTokenLabel)
+	}
+	if ttl < 0 || ttl > maxAccessTokenTTLDays*24*time.Hour {
+		return nil, fmt.Errorf("令牌有效期须在 0~%d 天之间", maxAccessTokenTTLDays)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.loadTask(taskID); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	tokens, err := s.loadAccessTokensLocked(taskID)
+	if err != nil {
+		return nil, err
+	}
+	tokens = liveAccessTokens(tokens, now)
+	if len(tokens) >= maxAccessTokens {
+		return nil, fmt.Errorf("每个任务最多 %d 个访问令牌，请先撤销不再使用的令牌", maxAccessTokens)
+	}
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, fmt.Errorf("生成访问令牌失败: %w", err)
+	}
+	token := hex.EncodeToString(buf)
+	entry := &model.AccessToken{
+		ID:        uuid.NewString(),
+		Label:     label,
+		Hash:      hashAccessToken(token),
+		CreatedAt: now,
+	}
+	if ttl > 0 {
+		entry.ExpiresAt = now.Add(ttl)
+	}
+	if err := s.saveAccessTokensLocked(taskID, append(tokens, entry)); err != nil {
+		return nil, err
+	}
+	issued := publicAccessToken(entry)
+	issued.Token = token
+	return issued, nil
+}
+
+// ListAccessTokens returns the task's unexpired tokens without their secrets.
+func (s *TaskService) ListAccessTokens(taskID string) ([]*model.AccessToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.loadTask(taskID); err != nil {
+		return nil, err
+	}
+	tokens, err := s.loadAccessTokensLocked(taskID)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]*model.AccessToken, 0, len(tokens))
+	for _, entry := range liveAccessTokens(tokens, time.Now()) {
+		list = append(list, publicAccessToken(entry))
+	}
+	return list, nil
+}
+
+// RevokeAccessToken deletes one of the task's tokens by ID.
+func (s *TaskService) RevokeAccessToken(taskID, tokenID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tokens, err := s.loadAccessTokensLocked(taskID)
+	if err != nil {
+		return err
+	}
+	for i, entry := range tokens {
+		if entry.ID == tokenID {
+			return s.saveAccessTokensLocked(taskID, append(tokens[:i], tokens[i+1:]...))
+		}
+	}
+	return ErrAccessTokenNotFound
+}
+
+// CheckAccessToken reports whether token grants access to the task's files.
+func (s *TaskService) CheckAccessToken(taskID, token string) bool {
+	token = strings.TrimSpace(token)
+	if token == "" || taskID == "" {
+		return false
+	}
+	// The file is replaced atomically, so it can be read without the task
+	// lock on the hot path of serving page images.
+	tokens, err := s.loadAccessTokensLocked(taskID)
+	if err != nil {
+		return false
+	}
+	hash := hashAccessToken(token)
+	for _, entry := range liveAccessTokens(tokens, time.Now()) {
+		if subtle.ConstantTimeCompare([]byte(entry.Hash), []byte(hash)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+func liveAccessTokens(tokens []*model.AccessToken, now time.Time) []*model.AccessToken {
+	live := tokens[:0:0]
+	for _, entry := range tokens {
+		if entry.ExpiresAt.IsZero() || now.Before(entry.ExpiresAt) {
+			live = append(live, entry)
+		}
+	}
+	return live
+}
+
+func publicAccessToken(entry *model.AccessToken) *model.AccessToken {
+	out := *entry
+	out.Hash = ""
+	return &out
+}
@@ -0,0 +1,149 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"pdftool/internal/model"
+	"pdftool/internal/translator"
+)
+
+// aimdLimiter adapts concurrency to provider feedback: the window grows by
+// about one slot per round of fast successes and is cut multiplicatively on
+// rate limits (halved) or latency spikes (by a quarter).
+type aimdLimiter struct {
+	name    string
+	mu      sync.Mutex
+	limit   float64
+	min     float64
+	max     float64
+	active  int
+	changed chan struct{}
+
+	latency      time.Duration // EWMA of successful request latency
+	samples      int
+	lastDecrease time.Time
+}
+
+const (
+	aimdSpikeFactor   = 3
+	aimdWarmupSamples = 5
+
+	formatterInitialWorkers = 3
+	formatterMaxWorkers     = 6
+)
+
+func newAIMDLimiter(name string, initial, max int) *aimdLimiter {
+	if max < 1 {
+		max = 1
+	}
+	if initial < 1 {
+		initial = 1
+	}
+	if initial > max {
+		initial = max
+	}
+	return &aimdLimiter{name: name, limit: float64(initial), min: 1, max: float64(max), changed: make(chan struct{})}
+}
+
+// acquire blocks until the current window has a free slot.
+func (l *aimdLimiter) acquire(ctx context.Context) error {
+	for {
+		l.mu.Lock()
+		if l.active < int(l.limit) {
+			l.active++
+			l.mu.Unlock()
+			return nil
+		}
+		wait := l.changed
+		l.mu.Unlock()
+		select {
+		case <-wait:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// release frees the slot and feeds the request outcome back into the window.
+func (l *aimdLimiter) release(elapsed time.Duration, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.active--
+	before := int(l.limit)
+	switch {
+	case isRateLimitError(err):
+		l.decrease(0.5, "rate limited")
+	case err != nil:
+		// Other failures say nothing about capacity.
+	case l.samples >= aimdWarmupSamples && elapsed > l.latency*aimdSpikeFactor:
+		l.decrease(0.75, "latency spike")
+		l.observeLatency(elapsed)
+	default:
+		l.observeLatency(elapsed)
+		l.limit += 1 / l.limit
+		if l.limit > l.max {
+			l.limit = l.max
+		}
+	}
+	if after := int(l.limit); after > before {
+		log.Printf("%s concurrency raised to %d", l.name, after)
+	}
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
+
+// decrease shrinks the window at most once per typical request duration, so a
+// burst of failures from the same round only counts once.
+func (l *aimdLimiter) decrease(factor float64, reason string) {
+	cooldown := l.latency
+	if cooldown < time.Second {
+		cooldown = time.Second
+	}
+	if time.Since(l.lastDecrease) < cooldown {
+		return
+	}
+	l.lastDecrease = time.Now()
+	l.limit *= factor
+	if l.limit < l.min {
+		l.limit = l.min
+	}
+	log.Printf("%s concurrency lowered to %d (%s)", l.name, int(l.limit), reason)
+}
+
+func (l *aimdLimiter) observeLatency(elapsed time.Duration) {
+	if l.samples == 0 {
+		l.latency = elapsed
+	} else {
+		l.latency = (l.latency*4 + elapsed) / 5
+	}
+	l.samples++
+}
+
+// isRateLimitError reports provider back-pressure: 429/503 responses or
+// rate-limit wording from gateways that do not use proper status codes.
+func isRateLimitError(err error) bool {
+	if err == nil {
+		return false
+	}
+	var httpErr *translator.HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.StatusCode == 429 || httpErr.StatusCode == 503
+	}
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "429") || strings.Contains(msg, "503") ||
+		strings.Contains(msg, "rate limit") || strings.Contains(msg, "concurrency") ||
+		strings.Contains(msg, "provider_error")
+}
+
+// pageFailure turns a failed page back into an error for limiter feedback.
+func pageFailure(page *model.PageResult) error {
+	if page.Status != model.PageStatusError {
+		return nil
+	}
+	return errors.New(page.Error)
+}
@@ -0,0 +1,140 @@
+package service
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"pdftool/internal/model"
+)
+
+// AuditLogFile is the append-only audit log in the storage dir, one JSON
+// entry per line. It is never served by the static file route.
+const AuditLogFile = "audit.log"
+
+// Audited actions.
+const (
+	AuditTaskDelete        = "task.delete"
+	AuditTaskRestore       = "task.restore"
+	AuditTrashPurge        = "trash.purge"
+	AuditProviderChange    = "provider.change"
+	AuditTranslationRevert = "translation.revert"
+	AuditTranslationImport = "translation.ocr_import"
+	AuditExportDownload    = "export.download"
+	AuditFileDownload      = "file.download"
+	AuditPromptsUpdate     = "prompts.update"
+	AuditPromptsReset      = "prompts.reset"
+	AuditProfileSave       = "profile.save"
+	AuditProfileDelete     = "profile.delete"
+	AuditProjectSave       = "project.save"
+	AuditProjectDelete     = "project.delete"
+	AuditTokenCreate       = "token.create"
+	AuditTokenRevoke       = "token.revoke"
+)
+
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+// AuditQuery filters audit entries; zero fields match everything.
+type AuditQuery struct {
+	Action    string
+	Principal string
+	TaskID    string
+	Since     time.Time
+	Until     time.Time
+	Limit     int
+}
+
+func (s *TaskService) auditPath() string {
+	return filepath.Join(s.storageDir, AuditLogFile)
+}
+
+// RecordAudit appends an entry to the audit log. Entries are only ever
+// appended; the log is not rewritten or rotated by the service.
+func (s *TaskService) RecordAudit(entry model.AuditEntry) error {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	s.auditMu.Lock()
+	defer s.auditMu.Unlock()
+	f, err := os.OpenFile(s.auditPath(), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return fmt.Errorf("打开审计日志失败: %w", err)
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return fmt.Errorf("写入审计日志失败: %w", err)
+	}
+	return f.Close()
+}
+
+// AuditLog returns the matching entries, newest first.
+func (s *TaskService) AuditLog(query AuditQuery) ([]*model.AuditEntry, error) {
+	limit := query.Limit
+	if limit <= 0 {
+		limit = defaultAuditLimit
+	}
+	if limit > maxAuditLimit {
+		limit = maxAuditLimit
+	}
+	f, err := os.Open(s.auditPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []*model.AuditEntry{}, nil
+		}
+		return nil, fmt.Errorf("读取审计日志失败: %w", err)
+	}
+	defer f.Close()
+	// The log is in time order; keep a window of the last limit matches.
+	var matches []*model.AuditEntry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	for scanner.Scan() {
+		var entry model.AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		if !query.matches(&entry) {
+			continue
+		}
+		matches = append(matches, &entry)
+		if len(matches) > limit {
+			matches = matches[1:]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取审计日志失败: %w", err)
+	}
+	for i, j := 0, len(matches)-1; i < j; i, j = i+1, j-1 {
+		matches[i], matches[j] = matches[j], matches[i]
+	}
+	if matches == nil {
+		matches = []*model.AuditEntry{}
+	}
+	return matches, nil
+}
+
+func (q AuditQuery) matches(entry *model.AuditEntry) bool {
+	switch {
+	case q.Action != "" && entry.Action != q.Action:
+		return false
+	case q.Principal != "" && entry.Principal != q.Principal:
+		return false
+	case q.TaskID != "" && entry.TaskID != q.TaskID:
+		return false
+	case !q.Since.IsZero() && entry.Time.Before(q.Since):
+		return false
+	case !q.Until.IsZero() && !entry.Time.Before(q.Until):
+		return false
+	}
+	return true
+}
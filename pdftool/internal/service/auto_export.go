@@ -0,0 +1,52 @@
+package service
+
+import (
+	"context"
+	"log"
+
+	"pdftool/internal/model"
+	"pdftool/internal/translator"
+)
+
+func (s *TaskService) autoExportEnabled(task *model.Task) bool {
+	if task.DryRun {
+		return false
+	}
+	return s.autoExport || task.ExportSettings != nil && task.ExportSettings.AutoExport
+}
+
+// runAutoExport generates the task's preferred exports once every page is
+// translated, so the artifacts are ready when the user returns. AI formatting
+// and the consistency pass run first when requested and the task's provider
+// key is available.
+func (s *TaskService) runAutoExport(taskID string) {
+	task, err := s.loadTask(taskID)
+	if err != nil || !s.autoExportEnabled(task) || taskState(task) != model.TaskStateCompleted {
+		return
+	}
+	ctx := context.Background()
+	if settings := task.ExportSettings; settings != nil && settings.AutoFormat && !task.FormattedByAI {
+		if s.usesDefaultProvider(task) {
+			if _, _, err := s.FormatTaskLayout(ctx, taskID, translator.ProviderConfig{}); err != nil {
+				log.Printf("auto format of task %s failed: %v", taskID, err)
+			}
+		} else {
+			log.Printf("skip auto format of task %s: provider key is not stored", taskID)
+		}
+	}
+	if settings := task.ExportSettings; settings != nil && settings.AutoConsistency && task.ConsistentTxtPath == "" {
+		if s.usesDefaultProvider(task) {
+			if _, _, err := s.ConsistencyPass(ctx, taskID, translator.ProviderConfig{}); err != nil {
+				log.Printf("auto consistency pass of task %s failed: %v", taskID, err)
+			}
+		} else {
+			log.Printf("skip auto consistency pass of task %s: provider key is not stored", taskID)
+		}
+	}
+	_, urls, err := s.ExportPreferred(ctx, taskID)
+	if err != nil {
+		log.Printf("auto export of task %s failed: %v", taskID, err)
+		return
+	}
+	log.Printf("auto export of task %s finished: %v", taskID, urls)
+}
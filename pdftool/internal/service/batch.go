@@ -0,0 +1,295 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+
+	"pdftool/internal/model"
+	"pdftool/internal/translator"
+)
+
+const (
+	batchDirName          = ".batches"
+	defaultBatchMaxURLs   = 50
+	defaultDownloadMaxMB  = 200
+	batchDownloadParallel = 2
+)
+
+var (
+	errDownloadTooLarge = errors.New("文件超过下载大小限制")
+	errBlockedAddress   = errors.New("禁止访问内部网络地址")
+)
+
+// downloadClient fetches user-supplied URLs. Its dialer refuses loopback,
+// private, link-local and unspecified addresses after DNS resolution, so
+// redirects and rebinding are checked on every connection; proxies are
+// bypassed so the check sees the real peer.
+var downloadClient = &http.Client{
+	Transport: &http.Transport{
+		Proxy: nil,
+		DialContext: (&net.Dialer{
+			Timeout: 30 * time.Second,
+			Control: rejectInternalAddress,
+		}).DialContext,
+		TLSHandshakeTimeout:   30 * time.Second,
+		ResponseHeaderTimeout: 2 * time.Minute,
+	},
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 10 {
+			return errors.New("重定向次数过多")
+		}
+		if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+			return fmt.Errorf("不支持的重定向地址: %s", req.URL)
+		}
+		return checkHostAddress(req.URL.Hostname())
+	},
+}
+
+// rejectInternalAddress is the dialer Control hook; address is the
+// resolved ip:port about to be connected.
+func rejectInternalAddress(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	return checkHostAddress(host)
+}
+
+// checkHostAddress rejects IP literals in internal ranges; host names pass
+// here and are checked again once resolved.
+func checkHostAddress(host string) error {
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return nil
+	}
+	addr = addr.Unmap()
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsLinkLocalUnicast() ||
+		addr.IsLinkLocalMulticast() || addr.IsUnspecified() || addr.IsMulticast() ||
+		sharedAddressSpace.Contains(addr) {
+		return fmt.Errorf("%w: %s", errBlockedAddress, host)
+	}
+	return nil
+}
+
+// sharedAddressSpace is the carrier-grade NAT range (RFC 6598), which
+// netip does not count as private.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// BatchLimits bounds URL-list ingestion.
+type BatchLimits struct {
+	MaxURLs          int
+	MaxDownloadBytes int64
+}
+
+// CreateBatch queues one task per PDF URL and returns the batch report immediately;
+// the report is updated as downloads finish.
+func (s *TaskService) CreateBatch(ctx context.Context, urls []string, provider translator.ProviderConfig, settings TranslationSettings) (*model.BatchReport, error) {
+	var cleaned []string
+	seen := make(map[string]bool)
+	for _, raw := range urls {
+		raw = strings.TrimSpace(raw)
+		if raw == "" || strings.HasPrefix(raw, "#") || seen[raw] {
+			continue
+		}
+		u, err := url.Parse(raw)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("无效的 URL: %s", raw)
+		}
+		seen[raw] = true
+		cleaned = append(cleaned, raw)
+	}
+	if len(cleaned) == 0 {
+		return nil, fmt.Errorf("URL 列表为空")
+	}
+	if len(cleaned) > s.batchLimits.MaxURLs {
+		return nil, fmt.Errorf("单次最多提交 %d 个 URL", s.batchLimits.MaxURLs)
+	}
+	if err := s.checkBudget(); err != nil {
+		return nil, err
+	}
+	if err := s.checkQuota(PrincipalFrom(ctx)); err != nil {
+		return nil, err
+	}
+	if _, err := validateLayoutMode(settings.LayoutMode); err != nil {
+		return nil, err
+	}
+	if _, err := validateWritingMode(settings.WritingMode); err != nil {
+		return nil, err
+	}
+	// Validate provider settings once so a bad key fails the request instead of every item.
+	if _, err := s.mergeProviderConfig(provider, nil); err != nil && !settings.DryRun {
+		return nil, err
+	}
+
+	report := &model.BatchReport{
+		ID:        uuid.NewString(),
+		Status:    model.BatchStatusRunning,
+		CreatedAt: time.Now(),
+		Items:     make([]*model.BatchItem, 0, len(cleaned)),
+	}
+	for _, u := range cleaned {
+		report.Items = append(report.Items, &model.BatchItem{URL: u, Status: model.BatchStatusPending})
+	}
+	if err := s.saveBatch(report); err != nil {
+		return nil, err
+	}
+	// Downloads outlive the request but keep its principal and client.
+	go s.runBatch(context.WithoutCancel(ctx), report, provider, settings)
+	return report, nil
+}
+
+// GetBatch loads a batch report.
+func (s *TaskService) GetBatch(batchID string) (*model.BatchReport, error) {
+	data, err := os.ReadFile(s.batchPath(batchID))
+	if err != nil {
+		return nil, fmt.Errorf("读取批次失败: %w", err)
+	}
+	var report model.BatchReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("解析批次失败: %w", err)
+	}
+	return &report, nil
+}
+
+func (s *TaskService) runBatch(ctx context.Context, report *model.BatchReport, provider translator.ProviderConfig, settings TranslationSettings) {
+	var mu sync.Mutex
+	sem := make(chan struct{}, batchDownloadParallel)
+	var wg sync.WaitGroup
+	for _, item := range report.Items {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(item *model.BatchItem) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			task, err := s.createTaskFromURL(ctx, item.URL, provider, settings)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				item.Status = model.BatchStatusFailed
+				item.Error = err.Error()
+			} else {
+				item.Status = model.BatchStatusCompleted
+				item.TaskID = task.ID
+				item.FileName = task.FileName
+				item.TotalPages = task.TotalPages
+			}
+			if err := s.saveBatch(report); err != nil {
+				log.Printf("save batch %s failed: %v", report.ID, err)
+			}
+		}(item)
+	}
+	wg.Wait()
+	mu.Lock()
+	report.Status = model.BatchStatusCompleted
+	report.FinishedAt = time.Now()
+	if err := s.saveBatch(report); err != nil {
+		log.Printf("save batch %s failed: %v", report.ID, err)
+	}
+	mu.Unlock()
+}
+
+func (s *TaskService) createTaskFromURL(ctx context.Context, rawURL string, provider translator.ProviderConfig, settings TranslationSettings) (*model.Task, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Minute)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := downloadClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("下载失败: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("下载失败: %s", resp.Status)
+	}
+	if resp.ContentLength > s.batchLimits.MaxDownloadBytes {
+		return nil, errDownloadTooLarge
+	}
+	body := &maxBytesReader{r: resp.Body, remaining: s.batchLimits.MaxDownloadBytes, err: errDownloadTooLarge}
+	return s.createTask(ctx, body, downloadFileName(resp, rawURL), provider, settings, nil)
+}
+
+func (s *TaskService) batchPath(batchID string) string {
+	return filepath.Join(s.storageDir, batchDirName, filepath.Base(batchID)+".json")
+}
+
+func (s *TaskService) saveBatch(report *model.BatchReport) error {
+	dest := s.batchPath(report.ID)
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := dest + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, dest)
+}
+
+// downloadFileName prefers Content-Disposition, then the URL path.
+func downloadFileName(resp *http.Response, rawURL string) string {
+	if _, params, err := mime.ParseMediaType(resp.Header.Get("Content-Disposition")); err == nil {
+		if name := strings.TrimSpace(params["filename"]); name != "" {
+			return ensurePDFExt(name)
+		}
+	}
+	if u, err := url.Parse(rawURL); err == nil {
+		if name, err := url.PathUnescape(path.Base(u.Path)); err == nil && name != "/" && name != "." {
+			return ensurePDFExt(name)
+		}
+	}
+	return "document.pdf"
+}
+
+func ensurePDFExt(name string) string {
+	if strings.HasSuffix(strings.ToLower(name), ".pdf") {
+		return name
+	}
+	return name + ".pdf"
+}
+
+// maxBytesReader fails with err instead of silently truncating oversized
+// downloads.
+type maxBytesReader struct {
+	r         io.Reader
+	remaining int64
+	err       error
+}
+
+func (m *maxBytesReader) Read(p []byte) (int, error) {
+	if m.remaining <= 0 {
+		var probe [1]byte
+		if n, _ := m.r.Read(probe[:]); n > 0 {
+			return 0, m.err
+		}
+		return 0, io.EOF
+	}
+	if int64(len(p)) > m.remaining {
+		p = p[:m.remaining]
+	}
+	n, err := m.r.Read(p)
+	m.remaining -= int64(n)
+	return n, err
+}
@@ -0,0 +1,169 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"pdftool/internal/metrics"
+	"pdftool/internal/model"
+	"pdftool/internal/translator"
+)
+
+const spendLedgerFile = "spend.json"
+
+// ErrBudgetExceeded is returned when a configured spend cap has been reached.
+var ErrBudgetExceeded = errors.New("已超出预算")
+
+// BudgetLimits caps provider spend; zero values disable the respective cap.
+type BudgetLimits struct {
+	DailyTokens   int64
+	MonthlyTokens int64
+	DailyCost     float64
+	MonthlyCost   float64
+	// PricePerMillionTokens converts token usage into currency.
+	PricePerMillionTokens float64
+}
+
+// spendLedger is persisted in the storage dir so spend survives restarts.
+type spendLedger struct {
+	Day          string `json:"day"`
+	DayTokens    int64  `json:"day_tokens"`
+	Month        string `json:"month"`
+	MonthTokens  int64  `json:"month_tokens"`
+	TotalTokens  int64  `json:"total_tokens"`
+	InputTokens  int64  `json:"input_tokens"`
+	OutputTokens int64  `json:"output_tokens"`
+}
+
+func (s *TaskService) ledgerPath() string {
+	return filepath.Join(s.storageDir, spendLedgerFile)
+}
+
+// loadLedgerLocked reads the ledger and rolls day/month counters; callers hold s.budgetMu.
+func (s *TaskService) loadLedgerLocked(now time.Time) spendLedger {
+	var ledger spendLedger
+	if data, err := os.ReadFile(s.ledgerPath()); err == nil {
+		if err := json.Unmarshal(data, &ledger); err != nil {
+			log.Printf("解析用量记录失败，将重新计数: %v", err)
+		}
+	}
+	day, month := now.Format("2006-01-02"), now.Format("2006-01")
+	if ledger.Day != day {
+		ledger.Day = day
+		ledger.DayTokens = 0
+	}
+	if ledger.Month != month {
+		ledger.Month = month
+		ledger.MonthTokens = 0
+	}
+	return ledger
+}
+
+func (s *TaskService) saveLedgerLocked(ledger spendLedger) error {
+	data, err := json.MarshalIndent(ledger, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.ledgerPath() + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.ledgerPath())
+}
+
+// recordUsage is installed as the translator usage recorder.
+func (s *TaskService) recordUsage(usage translator.Usage) {
+	s.budgetMu.Lock()
+	ledger := s.loadLedgerLocked(time.Now())
+	total := int64(usage.Total())
+	ledger.DayTokens += total
+	ledger.MonthTokens += total
+	ledger.TotalTokens += total
+	ledger.InputTokens += int64(usage.InputTokens)
+	ledger.OutputTokens += int64(usage.OutputTokens)
+	if err := s.saveLedgerLocked(ledger); err != nil {
+		log.Printf("写入用量记录失败: %v", err)
+	}
+	s.budgetMu.Unlock()
+	go s.checkBudgetThresholds(s.BudgetStatus())
+}
+
+func (s *TaskService) tokenCost(tokens int64) float64 {
+	return float64(tokens) / 1e6 * s.budget.PricePerMillionTokens
+}
+
+// BudgetStatus reports current spend against the configured caps.
+func (s *TaskService) BudgetStatus() *model.BudgetStatus {
+	s.budgetMu.Lock()
+	ledger := s.loadLedgerLocked(time.Now())
+	s.budgetMu.Unlock()
+	status := &model.BudgetStatus{
+		Day:                   ledger.Day,
+		DayTokens:             ledger.DayTokens,
+		DayCost:               s.tokenCost(ledger.DayTokens),
+		Month:                 ledger.Month,
+		MonthTokens:           ledger.MonthTokens,
+		MonthCost:             s.tokenCost(ledger.MonthTokens),
+		TotalTokens:           ledger.TotalTokens,
+		DailyTokenLimit:       s.budget.DailyTokens,
+		MonthlyTokenLimit:     s.budget.MonthlyTokens,
+		DailyCostLimit:        s.budget.DailyCost,
+		MonthlyCostLimit:      s.budget.MonthlyCost,
+		PricePerMillionTokens: s.budget.PricePerMillionTokens,
+	}
+	status.Exceeded = budgetExceededReason(status) != ""
+	return status
+}
+
+// checkBudget rejects new provider work once any cap is reached.
+func (s *TaskService) checkBudget() error {
+	if reason := budgetExceededReason(s.BudgetStatus()); reason != "" {
+		return fmt.Errorf("%w: %s，暂停新的翻译任务", ErrBudgetExceeded, reason)
+	}
+	return nil
+}
+
+func budgetExceededReason(status *model.BudgetStatus) string {
+	switch {
+	case status.DailyTokenLimit > 0 && status.DayTokens >= status.DailyTokenLimit:
+		return fmt.Sprintf("每日 token 上限 %d", status.DailyTokenLimit)
+	case status.MonthlyTokenLimit > 0 && status.MonthTokens >= status.MonthlyTokenLimit:
+		return fmt.Sprintf("每月 token 上限 %d", status.MonthlyTokenLimit)
+	case status.DailyCostLimit > 0 && status.DayCost >= status.DailyCostLimit:
+		return fmt.Sprintf("每日费用上限 %.2f", status.DailyCostLimit)
+	case status.MonthlyCostLimit > 0 && status.MonthCost >= status.MonthlyCostLimit:
+		return fmt.Sprintf("每月费用上限 %.2f", status.MonthlyCostLimit)
+	}
+	return ""
+}
+
+// Metrics exposes service gauges for the Prometheus endpoint.
+func (s *TaskService) Metrics() []metrics.Sample {
+	status := s.BudgetStatus()
+	exceeded := 0.0
+	if status.Exceeded {
+		exceeded = 1
+	}
+	samples := []metrics.Sample{
+		{Name: "pdftool_provider_tokens", Help: "Provider tokens consumed in the current period.", Labels: map[string]string{"period": "day"}, Value: float64(status.DayTokens)},
+		{Name: "pdftool_provider_tokens", Labels: map[string]string{"period": "month"}, Value: float64(status.MonthTokens)},
+		{Name: "pdftool_provider_tokens_total", Help: "Provider tokens consumed since the ledger was created.", Type: "counter", Value: float64(status.TotalTokens)},
+		{Name: "pdftool_provider_cost", Help: "Estimated provider spend in the current period.", Labels: map[string]string{"period": "day"}, Value: status.DayCost},
+		{Name: "pdftool_provider_cost", Labels: map[string]string{"period": "month"}, Value: status.MonthCost},
+		{Name: "pdftool_budget_token_limit", Help: "Configured token budget (0 = unlimited).", Labels: map[string]string{"period": "day"}, Value: float64(status.DailyTokenLimit)},
+		{Name: "pdftool_budget_token_limit", Labels: map[string]string{"period": "month"}, Value: float64(status.MonthlyTokenLimit)},
+		{Name: "pdftool_budget_cost_limit", Help: "Configured currency budget (0 = unlimited).", Labels: map[string]string{"period": "day"}, Value: status.DailyCostLimit},
+		{Name: "pdftool_budget_cost_limit", Labels: map[string]string{"period": "month"}, Value: status.MonthlyCostLimit},
+		{Name: "pdftool_budget_exceeded", Help: "1 when any spend cap has been reached.", Value: exceeded},
+	}
+	samples = append(samples, s.failureMetrics()...)
+	samples = append(samples, s.retries.metrics()...)
+	samples = append(samples, s.clientSlots.metrics()...)
+	samples = append(samples, s.renderMetrics()...)
+	return append(samples, s.pools.metrics()...)
+}
@@ -0,0 +1,129 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"pdftool/internal/metrics"
+	"pdftool/internal/model"
+)
+
+// clientSlotPoll is how often a queued task rechecks whether it was
+// canceled while waiting for a slot.
+const clientSlotPoll = time.Second
+
+type clientKey struct{}
+
+// WithClient attaches the identity (API key user or IP) whose tasks share
+// one concurrency limit; tasks created under the context inherit it.
+func WithClient(ctx context.Context, client string) context.Context {
+	return context.WithValue(ctx, clientKey{}, client)
+}
+
+func clientFrom(ctx context.Context) string {
+	client, _ := ctx.Value(clientKey{}).(string)
+	return client
+}
+
+// clientSlots caps how many tasks of one client process pages at a time;
+// further tasks wait in the queued state. A task holds at most one slot, so
+// retranslating pages of a running task never waits for itself.
+type clientSlots struct {
+	limit   int
+	mu      sync.Mutex
+	active  map[string]map[string]int
+	waiting int
+	changed chan struct{}
+}
+
+func newClientSlots(limit int) *clientSlots {
+	return &clientSlots{
+		limit:   limit,
+		active:  make(map[string]map[string]int),
+		changed: make(chan struct{}),
+	}
+}
+
+// tryAcquire takes a slot for the task when its client has one free.
+func (c *clientSlots) tryAcquire(client, taskID string) (bool, <-chan struct{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	tasks := c.active[client]
+	if tasks[taskID] == 0 && len(tasks) >= c.limit {
+		return false, c.changed
+	}
+	if tasks == nil {
+		tasks = make(map[string]int)
+		c.active[client] = tasks
+	}
+	tasks[taskID]++
+	return true, nil
+}
+
+func (c *clientSlots) release(client, taskID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	tasks := c.active[client]
+	if tasks[taskID]--; tasks[taskID] <= 0 {
+		delete(tasks, taskID)
+	}
+	if len(tasks) == 0 {
+		delete(c.active, client)
+	}
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
+
+func (c *clientSlots) setWaiting(delta int) {
+	c.mu.Lock()
+	c.waiting += delta
+	c.mu.Unlock()
+}
+
+// acquireClientSlot blocks until the task's client may run another task and
+// returns the release func; ok is false when the task was canceled while
+// waiting. Tasks without a client, or without a configured limit, never wait.
+func (s *TaskService) acquireClientSlot(task *model.Task) (release func(), ok bool) {
+	if s.clientSlots == nil || task.Client == "" {
+		return func() {}, true
+	}
+	release = func() { s.clientSlots.release(task.Client, task.ID) }
+	acquired, changed := s.clientSlots.tryAcquire(task.Client, task.ID)
+	if acquired {
+		return release, true
+	}
+	s.clientSlots.setWaiting(1)
+	defer s.clientSlots.setWaiting(-1)
+	s.transition(task, toState(model.TaskStateQueued), "等待同一客户端的其他任务完成")
+	for {
+		select {
+		case <-changed:
+		case <-time.After(clientSlotPoll):
+		}
+		if s.isCanceled(task.ID) {
+			return nil, false
+		}
+		if acquired, changed = s.clientSlots.tryAcquire(task.Client, task.ID); acquired {
+			return release, true
+		}
+	}
+}
+
+// metrics reports tasks running and queued behind the per-client limit.
+func (c *clientSlots) metrics() []metrics.Sample {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	running := 0
+	for _, tasks := range c.active {
+		running += len(tasks)
+	}
+	return []metrics.Sample{
+		{Name: "pdftool_client_tasks_running", Help: "Tasks processing pages under the per-client limit.", Value: float64(running)},
+		{Name: "pdftool_client_tasks_waiting", Help: "Tasks queued behind their client's concurrency limit.", Value: float64(c.waiting)},
+		{Name: "pdftool_client_task_limit", Help: "Maximum concurrently processing tasks per client.", Value: float64(c.limit)},
+	}
+}
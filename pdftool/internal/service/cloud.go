@@ -0,0 +1,133 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"pdftool/internal/model"
+	"pdftool/internal/translator"
+)
+
+// maxImportBytes bounds cloud downloads so a wrong path cannot fill the disk.
+const maxImportBytes = 512 << 20
+
+var errImportTooLarge = fmt.Errorf("文件过大，云盘导入最大 %d MB", maxImportBytes>>20)
+
+// CreateTaskFromSource downloads a PDF from a configured cloud connector and creates a task.
+func (s *TaskService) CreateTaskFromSource(ctx context.Context, sourceType, remotePath string, writeBack bool, provider translator.ProviderConfig, settings TranslationSettings) (*model.Task, error) {
+	remotePath = strings.TrimSpace(remotePath)
+	if remotePath == "" {
+		return nil, fmt.Errorf("缺少云端文件路径")
+	}
+	connector, err := s.sources.Get(sourceType)
+	if err != nil {
+		return nil, err
+	}
+	body, fileName, err := connector.Fetch(ctx, remotePath)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	if !strings.HasSuffix(strings.ToLower(fileName), ".pdf") {
+		return nil, fmt.Errorf("仅支持PDF文件: %s", fileName)
+	}
+	src := &model.SourceInfo{
+		Type:      connector.Name(),
+		Path:      remotePath,
+		WriteBack: writeBack,
+	}
+	limited := &maxBytesReader{r: body, remaining: maxImportBytes, err: errImportTooLarge}
+	return s.createTask(ctx, limited, fileName, provider, settings, src)
+}
+
+// CloudSources lists the configured connector names.
+func (s *TaskService) CloudSources() []string {
+	return s.sources.Names()
+}
+
+// publishExport uploads a generated export to the task's cloud source folder
+// (when write-back is enabled) and to its output destination, if any.
+func (s *TaskService) publishExport(ctx context.Context, task *model.Task, localPath string) {
+	wantWriteBack := task.Source != nil && task.Source.WriteBack
+	destination := strings.TrimSpace(task.OutputDestination)
+	if !wantWriteBack && destination == "" {
+		return
+	}
+	data, err := s.readStored(localPath)
+	if err != nil {
+		log.Printf("read export %s failed: %v", localPath, err)
+		return
+	}
+	name := remoteExportName(task, localPath)
+	if wantWriteBack {
+		connector, err := s.sources.Get(task.Source.Type)
+		if err != nil {
+			log.Printf("write back export for task %s skipped: %v", task.ID, err)
+		} else if location, err := connector.UploadSibling(ctx, task.Source.Path, name, data); err != nil {
+			log.Printf("write back export %s for task %s failed: %v", name, task.ID, err)
+		} else {
+			recordRemoteExport(task, localPath, connector.Name()+":"+task.Source.Path, location)
+		}
+	}
+	if destination != "" {
+		target, err := s.publisher.Resolve(destination)
+		if err != nil {
+			log.Printf("publish export for task %s skipped: %v", task.ID, err)
+		} else if location, err := target.Upload(ctx, name, data); err != nil {
+			log.Printf("publish export %s for task %s failed: %v", name, task.ID, err)
+		} else {
+			recordRemoteExport(task, localPath, destination, location)
+		}
+	}
+}
+
+// SetOutputDestination changes where future exports of the task are uploaded.
+func (s *TaskService) SetOutputDestination(taskID, destination string) (*model.Task, error) {
+	destination = strings.TrimSpace(destination)
+	if destination != "" {
+		if err := s.publisher.Validate(destination); err != nil {
+			return nil, err
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	task, err := s.loadTask(taskID)
+	if err != nil {
+		return nil, err
+	}
+	task.OutputDestination = destination
+	if err := s.saveTaskLocked(task); err != nil {
+		return nil, err
+	}
+	return task, nil
+}
+
+func recordRemoteExport(task *model.Task, localPath, destination, location string) {
+	file := filepath.Base(localPath)
+	entry := &model.RemoteExport{
+		File:        file,
+		Destination: destination,
+		URL:         location,
+		UploadedAt:  time.Now(),
+	}
+	for idx, existing := range task.RemoteExports {
+		if existing.File == file && existing.Destination == destination {
+			task.RemoteExports[idx] = entry
+			return
+		}
+	}
+	task.RemoteExports = append(task.RemoteExports, entry)
+}
+
+// remoteExportName prefixes the export with the source document name, e.g. book.combined.txt.
+func remoteExportName(task *model.Task, localPath string) string {
+	stem := strings.TrimSuffix(task.FileName, filepath.Ext(task.FileName))
+	if stem == "" {
+		stem = task.ID
+	}
+	return stem + "." + filepath.Base(localPath)
+}
@@ -0,0 +1,282 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"pdftool/internal/model"
+)
+
+const (
+	combinedExportsDir     = ".combined"
+	combinedExportBaseName = "combined"
+	combinedExportTTL      = 24 * time.Hour
+	maxCombinedTasks       = 100
+)
+
+// combinedExportExts maps the formats of multi-task exports to file extensions.
+var combinedExportExts = map[string]string{
+	ExportTxt:      ".txt",
+	ExportMarkdown: ".md",
+	ExportPDF:      ".pdf",
+	ExportEPUB:     ".epub",
+}
+
+var markdownHeading = regexp.MustCompile(`(?m)^(#{1,5}) `)
+
+// ExportCombined merges the translations of several tasks, in the given
+// order, into one file. It returns the export ID and file name; the file is
+// kept for a day.
+func (s *TaskService) ExportCombined(ctx context.Context, taskIDs []string, format, title string) (string, string, error) {
+	if len(taskIDs) == 0 {
+		return "", "", fmt.Errorf("请指定要合并的任务")
+	}
+	if len(taskIDs) > maxCombinedTasks {
+		return "", "", fmt.Errorf("一次最多合并 %d 个任务", maxCombinedTasks)
+	}
+	tasks := make([]*model.Task, 0, len(taskIDs))
+	seen := make(map[string]bool)
+	for _, taskID := range taskIDs {
+		taskID = strings.TrimSpace(taskID)
+		if seen[taskID] {
+			return "", "", fmt.Errorf("任务重复: %s", taskID)
+		}
+		seen[taskID] = true
+		task, err := s.loadTask(taskID)
+		if err != nil {
+			return "", "", fmt.Errorf("任务不存在: %s", taskID)
+		}
+		tasks = append(tasks, task)
+	}
+	if strings.TrimSpace(title) == "" {
+		title = documentTitle(tasks[0])
+	}
+	s.pruneCombinedExports()
+	exportID := uuid.NewString()
+	dir := filepath.Join(s.storageDir, combinedExportsDir, exportID)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", "", fmt.Errorf("创建导出目录失败: %w", err)
+	}
+	name, err := s.writeTasksExport(ctx, dir, title, format, tasks)
+	if err != nil {
+		os.RemoveAll(dir)
+		return "", "", err
+	}
+	return exportID, name, nil
+}
+
+// CombinedExportFile returns the path of a file generated by ExportCombined.
+func (s *TaskService) CombinedExportFile(exportID, name string) (string, error) {
+	if _, err := uuid.Parse(exportID); err != nil {
+		return "", fmt.Errorf("导出不存在: %s", exportID)
+	}
+	return existingCombinedExport(filepath.Join(s.storageDir, combinedExportsDir, exportID), name)
+}
+
+// pruneCombinedExports removes ad-hoc combined exports older than a day.
+func (s *TaskService) pruneCombinedExports() {
+	root := filepath.Join(s.storageDir, combinedExportsDir)
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return
+	}
+	cutoff := time.Now().Add(-combinedExportTTL)
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err == nil && info.ModTime().Before(cutoff) {
+			os.RemoveAll(filepath.Join(root, entry.Name()))
+		}
+	}
+}
+
+// existingCombinedExport resolves name to a generated export file in dir.
+func existingCombinedExport(dir, name string) (string, error) {
+	name = filepath.Base(strings.TrimSpace(name))
+	for _, ext := range combinedExportExts {
+		if name != combinedExportBaseName+ext {
+			continue
+		}
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err != nil {
+			return "", fmt.Errorf("导出文件尚未生成: %s", name)
+		}
+		return path, nil
+	}
+	return "", fmt.Errorf("不支持的导出文件: %s", name)
+}
+
+func normalizeCombinedFormat(format string) (string, error) {
+	format = strings.ToLower(strings.TrimSpace(format))
+	if format == "md" {
+		format = ExportMarkdown
+	}
+	if _, ok := combinedExportExts[format]; !ok {
+		return "", fmt.Errorf("不支持的导出格式: %s", format)
+	}
+	return format, nil
+}
+
+// writeTasksExport renders tasks in order into dir and returns the file name.
+func (s *TaskService) writeTasksExport(ctx context.Context, dir, title, format string, tasks []*model.Task) (string, error) {
+	format, err := normalizeCombinedFormat(format)
+	if err != nil {
+		return "", err
+	}
+	name := combinedExportBaseName + combinedExportExts[format]
+	path := filepath.Join(dir, name)
+	switch format {
+	case ExportPDF:
+		err = s.writeTasksPDF(path, title, tasks)
+	case ExportEPUB:
+		err = s.writeTasksEPUB(ctx, path, title, tasks)
+	case ExportMarkdown:
+		var content string
+		if content, err = buildTasksMarkdown(title, tasks); err == nil {
+			err = writeFileAtomic(s.vault.storageKey(), path, []byte(content))
+		}
+	default:
+		var content string
+		if content, err = s.buildTasksText(title, tasks); err == nil {
+			err = writeFileAtomic(s.vault.storageKey(), path, []byte(content))
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// buildTasksText joins the TXT exports of tasks in order, each under its
+// document title. Tasks without translated text are skipped.
+func (s *TaskService) buildTasksText(title string, tasks []*model.Task) (string, error) {
+	var builder strings.Builder
+	if title = strings.TrimSpace(title); title != "" {
+		builder.WriteString(title + "\n\n")
+	}
+	wrote := false
+	for _, task := range tasks {
+		text, err := s.combinedTextWithNotice(task)
+		if err != nil {
+			continue
+		}
+		builder.WriteString("== " + documentTitle(task) + " ==\n\n")
+		builder.WriteString(strings.TrimSpace(text))
+		builder.WriteString("\n\n")
+		wrote = true
+	}
+	if !wrote {
+		return "", fmt.Errorf("没有可用的翻译文本")
+	}
+	return builder.String(), nil
+}
+
+// buildTasksMarkdown joins the Markdown exports of tasks in order. Each task
+// becomes a second-level section; its headings are demoted one level and its
+// footnote IDs are prefixed with the volume number so they stay unique.
+func buildTasksMarkdown(title string, tasks []*model.Task) (string, error) {
+	var builder strings.Builder
+	if title = strings.TrimSpace(title); title != "" {
+		builder.WriteString("# " + title + "\n\n")
+	}
+	volume := 0
+	for _, task := range tasks {
+		text, err := buildCombinedMarkdown(task)
+		if err != nil {
+			continue
+		}
+		volume++
+		text = markdownHeading.ReplaceAllString(text, "#$1 ")
+		text = strings.ReplaceAll(text, "[^p", fmt.Sprintf("[^v%d-p", volume))
+		builder.WriteString(strings.TrimSpace(text))
+		builder.WriteString("\n\n")
+	}
+	if volume == 0 {
+		return "", fmt.Errorf("没有可用的翻译文本")
+	}
+	return builder.String(), nil
+}
+
+// writeTasksPDF writes the translated pages of tasks in order, each task
+// starting with a title page. Pages without a translation are left out.
+func (s *TaskService) writeTasksPDF(path, title string, tasks []*model.Task) error {
+	pdf := s.newPDF(tasks[0])
+	pdf.SetTitle(title, true)
+	fontFamily := s.prepareFont(pdf, exportLanguage(tasks...))
+	wrote := false
+	for _, task := range tasks {
+		var pages []*model.PageResult
+		for _, page := range task.Pages {
+			if page.HasText && strings.TrimSpace(page.Translation) != "" {
+				pages = append(pages, page)
+			}
+		}
+		if len(pages) == 0 {
+			continue
+		}
+		pdf.AddPage()
+		s.setFont(pdf, fontFamily, 18)
+		pdf.MultiCell(0, 10, s.encodeText(pdf, fontFamily, documentTitle(task)), "", "L", false)
+		if notice, ok := partialNotice(task); ok {
+			pdf.Ln(4)
+			s.setFont(pdf, fontFamily, 12)
+			pdf.MultiCell(0, 6, s.encodeText(pdf, fontFamily, notice), "1", "L", false)
+		}
+		for _, page := range pages {
+			s.writePDFTextPage(pdf, fontFamily, task, page, pageExportText(task, page))
+		}
+		wrote = true
+	}
+	if !wrote {
+		pdf.Close()
+		return fmt.Errorf("没有可用的翻译文本")
+	}
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return fmt.Errorf("生成PDF失败: %w", err)
+	}
+	return writeFileAtomic(s.vault.storageKey(), path, buf.Bytes())
+}
+
+// writeTasksEPUB converts the merged Markdown to EPUB with pandoc.
+func (s *TaskService) writeTasksEPUB(ctx context.Context, path, title string, tasks []*model.Task) error {
+	if s.pandocPath == "" {
+		return fmt.Errorf("未配置 pandoc，无法导出 EPUB")
+	}
+	markdown, err := buildTasksMarkdown(title, tasks)
+	if err != nil {
+		return err
+	}
+	workDir, err := os.MkdirTemp("", "pdftool-pandoc-*")
+	if err != nil {
+		return fmt.Errorf("写入临时文件失败: %w", err)
+	}
+	defer os.RemoveAll(workDir)
+	src := filepath.Join(workDir, "combined.md")
+	if err := os.WriteFile(src, []byte(markdown), 0o600); err != nil {
+		return fmt.Errorf("写入临时文件失败: %w", err)
+	}
+	out := filepath.Join(workDir, "combined.epub")
+	args := []string{"--standalone", "-f", "markdown", "-t", "epub3", "-o", out, "--metadata", "title=" + title}
+	for _, task := range tasks {
+		if translationsRTL(task) {
+			args = append(args, "--metadata", "dir=rtl")
+			break
+		}
+	}
+	if err := s.runPandoc(ctx, append(args, src)...); err != nil {
+		return err
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		return fmt.Errorf("读取 pandoc 输出失败: %w", err)
+	}
+	return writeFileAtomic(s.vault.storageKey(), path, data)
+}
@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"pdftool/internal/model"
+	"pdftool/internal/translator"
+)
+
+const maxCompareProviders = 4
+
+// ComparePage runs one page through several provider configs concurrently and
+// returns the results without touching the stored translation.
+func (s *TaskService) ComparePage(ctx context.Context, taskID string, pageNumber int, providers []translator.ProviderConfig) (*model.CompareResponse, error) {
+	if len(providers) < 2 {
+		return nil, fmt.Errorf("至少需要两个模型配置")
+	}
+	if len(providers) > maxCompareProviders {
+		return nil, fmt.Errorf("最多同时比较 %d 个模型配置", maxCompareProviders)
+	}
+	task, err := s.loadTask(taskID)
+	if err != nil {
+		return nil, err
+	}
+	var target *model.PageResult
+	for _, page := range task.Pages {
+		if page.PageNumber == pageNumber {
+			target = page
+			break
+		}
+	}
+	if target == nil {
+		return nil, fmt.Errorf("page %d not found", pageNumber)
+	}
+	if err := s.checkBudget(); err != nil {
+		return nil, err
+	}
+	if err := s.checkQuota(PrincipalFrom(ctx)); err != nil {
+		return nil, err
+	}
+	clients := make([]translator.Translator, len(providers))
+	configs := make([]translator.ProviderConfig, len(providers))
+	for i, provider := range providers {
+		cfg, err := s.mergeProviderConfig(provider, task)
+		if err != nil {
+			return nil, fmt.Errorf("模型配置 %d: %w", i+1, err)
+		}
+		client, err := translator.NewTranslator(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("模型配置 %d: %w", i+1, err)
+		}
+		configs[i] = cfg
+		clients[i] = client
+	}
+
+	imagePath, cleanup, err := s.plainFile(target.ImagePath)
+	defer cleanup()
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &model.CompareResponse{
+		TaskID:     task.ID,
+		PageNumber: pageNumber,
+		ImageURL:   target.ImageURL,
+		Results:    make([]*model.CompareResult, len(providers)),
+	}
+	var wg sync.WaitGroup
+	for i := range clients {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			entry := &model.CompareResult{Provider: providerInfo(configs[i])}
+			resp.Results[i] = entry
+			release, err := s.pools.acquire(ctx, string(configs[i].Type))
+			if err != nil {
+				entry.Error = err.Error()
+				return
+			}
+			defer release()
+			start := time.Now()
+			pageCtx, finish := s.withProviderStats(translator.WithPageNumber(ctx, pageNumber), entry.Provider)
+			pageCtx = withWritingModeHint(pageCtx, task)
+			pageCtx = s.withGlossaryHint(pageCtx, task)
+			result, err := clients[i].Translate(pageCtx, imagePath)
+			finish(err)
+			entry.DurationMs = time.Since(start).Milliseconds()
+			if err != nil {
+				entry.Error = err.Error()
+			} else {
+				entry.HasText = result.HasText
+				entry.SourceText = normalizeText(result.SourceText)
+				entry.Translation = normalizeText(result.TranslatedText)
+			}
+		}(i)
+	}
+	wg.Wait()
+	return resp, nil
+}
@@ -0,0 +1,251 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"pdftool/internal/model"
+	"pdftool/internal/translator"
+)
+
+// The consistency pass runs after translation. Pages are translated
+// independently, so a name may be rendered differently on different pages;
+// the pass settles one rendering per proper noun in a single request, then
+// has every chunk of the translation rewritten against that fixed glossary.
+
+const (
+	consistentTxtFile   = "consistent.txt"
+	maxConsistencyTerms = 200
+)
+
+// properNounPattern matches a run of capitalized words, such as "Anna" or
+// "New York". detectProperNouns drops a sentence-initial first word.
+var properNounPattern = regexp.MustCompile(`\b[A-Z][a-z]+(?:[ -][A-Z][a-z]+)*\b`)
+
+// honorifics end in a period that does not start a sentence, and are not
+// names on their own.
+var honorifics = map[string]bool{"Mr": true, "Mrs": true, "Ms": true, "Dr": true, "St": true}
+
+// detectProperNouns returns the capitalized names that appear mid-sentence
+// in the source text of at least two pages, most widespread first.
+func detectProperNouns(task *model.Task) []string {
+	pages := make(map[string]int)
+	for _, page := range task.Pages {
+		if !page.HasText || page.SourceText == "" {
+			continue
+		}
+		seen := make(map[string]bool)
+		text := page.SourceText
+		for _, loc := range properNounPattern.FindAllStringIndex(text, -1) {
+			term := text[loc[0]:loc[1]]
+			// A sentence-initial word or honorific is dropped from the
+			// front of the run: "Then Anna Karenina" names Anna Karenina.
+			first, rest, _ := strings.Cut(term, " ")
+			if honorifics[first] || !midSentence(text[:loc[0]]) {
+				term = rest
+			}
+			if term == "" || honorifics[term] || seen[term] {
+				continue
+			}
+			seen[term] = true
+			pages[term]++
+		}
+	}
+	var terms []string
+	for term, count := range pages {
+		if count >= 2 {
+			terms = append(terms, term)
+		}
+	}
+	sort.Slice(terms, func(i, j int) bool {
+		if pages[terms[i]] != pages[terms[j]] {
+			return pages[terms[i]] > pages[terms[j]]
+		}
+		return terms[i] < terms[j]
+	})
+	if len(terms) > maxConsistencyTerms {
+		terms = terms[:maxConsistencyTerms]
+	}
+	return terms
+}
+
+// midSentence reports whether a word following before is inside a sentence,
+// so its capital marks a name rather than the start of the sentence.
+func midSentence(before string) bool {
+	trimmed := strings.TrimRightFunc(before, unicode.IsSpace)
+	if trimmed == "" || strings.ContainsRune(before[len(trimmed):], '\n') {
+		return false
+	}
+	last, _ := utf8.DecodeLastRuneInString(trimmed)
+	switch last {
+	case '.':
+		word := trimmed[:len(trimmed)-1]
+		word = word[strings.LastIndexFunc(word, func(r rune) bool { return !unicode.IsLetter(r) })+1:]
+		return honorifics[word]
+	case '!', '?', ':', '"', '\'', '“', '‘', '(', '[':
+		return false
+	}
+	return true
+}
+
+// ConsistencyPass harmonizes the renderings of names and terms across the
+// whole translation and writes the result to consistent.txt. The project
+// glossary is applied as given; the renderings of the other proper nouns
+// found in the source are settled by the model first.
+func (s *TaskService) ConsistencyPass(ctx context.Context, taskID string, provider translator.ProviderConfig) (*model.Task, string, error) {
+	task, err := s.loadTask(taskID)
+	if err != nil {
+		return nil, "", err
+	}
+	log.Printf("start consistency pass task=%s model=%s", task.ID, provider.Model)
+	if err := s.checkBudget(); err != nil {
+		return nil, "", err
+	}
+	providerCfg, err := s.mergeProviderConfig(provider, task)
+	if err != nil {
+		return nil, "", err
+	}
+	formatter, err := translator.NewFormatter(providerCfg)
+	if err != nil {
+		return nil, "", err
+	}
+	formatter = translator.WithFormatterCache(formatter, s.responseCache, providerCfg)
+	claim, err := s.claimLease(s.taskLeasePath(task.ID, "consistency"))
+	if err != nil {
+		return nil, "", err
+	}
+	if claim == nil {
+		return nil, "", fmt.Errorf("一致性校对正在由其他实例处理")
+	}
+	defer claim.Release()
+	baseText, err := s.buildCombinedText(task)
+	if err != nil {
+		return nil, "", err
+	}
+	chunkCtx := translator.WithUsageRecorder(ctx, func(u translator.Usage) {
+		s.recordUsage(u)
+		s.addQuotaUsage(task.Owner, 0, int64(u.Total()))
+	})
+
+	glossary := s.consistencyGlossary(chunkCtx, task, formatter)
+	instruction := consistencyInstruction(glossary)
+	texts := splitTextChunks(baseText, estimateFormatterChunkSize(providerCfg.Type, providerCfg.MaxTokens))
+	chunks := make([]translator.FormatterChunk, 0, len(texts))
+	for idx, text := range texts {
+		chunks = append(chunks, translator.FormatterChunk{
+			FileName:    fmt.Sprintf("chunk-%03d.txt", idx+1),
+			MimeType:    "text/plain",
+			Data:        []byte(text),
+			Instruction: instruction,
+		})
+	}
+	results, _, err := s.formatChunks(chunkCtx, "consistency "+task.ID, "一致性校对", formatter, chunks, func(completed int) {
+		log.Printf("consistency pass task=%s %d/%d chunks", task.ID, completed, len(chunks))
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	consistent := strings.TrimSpace(strings.Join(results, "\n\n"))
+	if consistent == "" {
+		return nil, "", fmt.Errorf("一致性校对失败，返回内容为空")
+	}
+	consistentPath := filepath.Join(s.taskDir(task.ID), consistentTxtFile)
+	if err := s.writeTaskFileAtomic(task.ID, consistentPath, []byte(consistent)); err != nil {
+		return nil, "", fmt.Errorf("写入一致性校对TXT失败: %w", err)
+	}
+	if task, err = s.loadTask(task.ID); err != nil {
+		return nil, "", err
+	}
+	task.ConsistentTxtPath = consistentPath
+	task.ConsistentTxtURL = s.buildFileURL(task.ID, consistentTxtFile)
+	task.ConsistencyGlossary = glossary
+	clearStaleExport(task, ExportConsistent)
+	s.publishExport(ctx, task, consistentPath)
+	if err := s.saveTask(task); err != nil {
+		return nil, "", err
+	}
+	s.publishExportEvent(task, ExportConsistent, task.ConsistentTxtURL)
+	log.Printf("consistency pass finished task=%s terms=%d consistentTxt=%s", task.ID, len(glossary), task.ConsistentTxtURL)
+	return task, task.ConsistentTxtURL, nil
+}
+
+// consistencyGlossary returns the project glossary followed by the detected
+// proper nouns it does not cover, with the renderings the model chose. A
+// failed or unparsable reply leaves those terms without a rendering, so the
+// chunks still harmonize them, only without a fixed target.
+func (s *TaskService) consistencyGlossary(ctx context.Context, task *model.Task, formatter translator.TextFormatter) []model.GlossaryEntry {
+	var glossary []model.GlossaryEntry
+	fixed := make(map[string]bool)
+	if task.Project != "" {
+		if project, err := s.loadProject(task.Project); err == nil {
+			for _, entry := range project.Glossary {
+				glossary = append(glossary, entry)
+				fixed[entry.Term] = true
+			}
+		}
+	}
+	var terms []string
+	for _, term := range detectProperNouns(task) {
+		if !fixed[term] {
+			terms = append(terms, term)
+		}
+	}
+	if len(terms) == 0 {
+		return glossary
+	}
+	var renderings map[string]string
+	reply, err := formatter.Format(ctx, translator.FormatterChunk{
+		FileName:    "glossary.txt",
+		MimeType:    "text/plain",
+		Data:        []byte(strings.Join(terms, "\n")),
+		Instruction: "以下是一部译稿原文中反复出现的专有名词，每行一个。请为每个名词给出一个标准的中文译名，只输出 JSON 对象，键为原文名词，值为译名，不要输出其他内容。",
+	}, 0)
+	if err != nil {
+		log.Printf("resolve consistency glossary of task %s failed: %v", task.ID, err)
+	} else if err := json.Unmarshal([]byte(jsonObject(reply)), &renderings); err != nil {
+		log.Printf("parse consistency glossary of task %s failed: %v", task.ID, err)
+	}
+	for _, term := range terms {
+		glossary = append(glossary, model.GlossaryEntry{Term: term, Translation: strings.TrimSpace(renderings[term])})
+	}
+	return glossary
+}
+
+// jsonObject extracts the outermost JSON object from a model reply, which
+// may wrap it in a code fence or prose.
+func jsonObject(reply string) string {
+	start := strings.Index(reply, "{")
+	end := strings.LastIndex(reply, "}")
+	if start < 0 || end < start {
+		return reply
+	}
+	return reply[start : end+1]
+}
+
+func consistencyInstruction(glossary []model.GlossaryEntry) string {
+	var b strings.Builder
+	b.WriteString("以下是一部译稿的片段。请统一其中人名、地名、机构名与术语的译法，除此之外不得改写、删减或概括任何内容，段落、页眉与注释保持原样。")
+	if len(glossary) > 0 {
+		b.WriteString("\n统一译法表（未给出译名的，请统一为片段中最常用的译法）：")
+		for _, entry := range glossary {
+			b.WriteString("\n" + entry.Term)
+			if entry.Translation != "" {
+				b.WriteString(" → " + entry.Translation)
+			}
+			if entry.Note != "" {
+				b.WriteString("（" + entry.Note + "）")
+			}
+		}
+	}
+	b.WriteString("\n请输出校对后的全文。")
+	return b.String()
+}
@@ -0,0 +1,23 @@
+package service
+
+import (
+	"slices"
+	"testing"
+
+	"pdftool/internal/model"
+)
+
+// TestDetectProperNouns checks that only names found mid-sentence on at
+// least two pages are kept, and that honorifics do not end a sentence.
+func TestDetectProperNouns(t *testing.T) {
+	task := &model.Task{Pages: []*model.PageResult{
+		{HasText: true, SourceText: "Then Anna Karenina met Mr. Vronsky in New York. Later she left."},
+		{HasText: true, SourceText: "Anna wrote to Vronsky from New York.\nKitty stayed at home with Levin."},
+		{HasText: true, SourceText: "They saw Levin and Anna Karenina."},
+	}}
+	got := detectProperNouns(task)
+	want := []string{"Anna Karenina", "Levin", "New York", "Vronsky"}
+	if !slices.Equal(got, want) {
+		t.Errorf("detectProperNouns = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,149 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"path/filepath"
+
+	"github.com/jung-kurt/gofpdf"
+
+	"pdftool/internal/locale"
+	"pdftool/internal/model"
+)
+
+const (
+	defaultContactSheetPerPage = 12
+	maxContactSheetPerPage     = 48
+	contactSheetThumbWidth     = 400
+)
+
+type contactStatus struct {
+	label   func(locale.Strings) string
+	r, g, b int
+}
+
+// Status colours of the contact sheet frames.
+var (
+	contactTranslated = contactStatus{func(t locale.Strings) string { return t.Translated }, 46, 160, 67}
+	contactBlank      = contactStatus{func(t locale.Strings) string { return t.Blank }, 150, 150, 150}
+	contactPending    = contactStatus{func(t locale.Strings) string { return t.Pending }, 240, 160, 0}
+	contactFailed     = contactStatus{func(t locale.Strings) string { return t.Failed }, 220, 50, 47}
+	contactBlocked    = contactStatus{func(t locale.Strings) string { return t.Blocked }, 142, 68, 173}
+)
+
+func pageContactStatus(page *model.PageResult) contactStatus {
+	switch page.Status {
+	case model.PageStatusError:
+		return contactFailed
+	case model.PageStatusBlocked:
+		return contactBlocked
+	case model.PageStatusPending:
+		return contactPending
+	}
+	if page.HasText {
+		return contactTranslated
+	}
+	return contactBlank
+}
+
+// ExportContactSheet lays out perPage thumbnails per A4 page with page numbers
+// and status-coloured frames, for spotting failed or blank pages at a glance.
+func (s *TaskService) ExportContactSheet(ctx context.Context, taskID string, perPage int) (*model.Task, string, error) {
+	if perPage <= 0 {
+		perPage = defaultContactSheetPerPage
+	}
+	if perPage > maxContactSheetPerPage {
+		return nil, "", fmt.Errorf("每页缩略图数量不能超过 %d", maxContactSheetPerPage)
+	}
+	task, err := s.loadTask(taskID)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(task.Pages) == 0 {
+		return nil, "", fmt.Errorf("任务没有页面")
+	}
+
+	pdf := s.newPDF(task)
+	fontFamily := s.prepareFont(pdf, exportLanguage(task))
+	pageWidth, pageHeight := pdf.GetPageSize()
+	cols := int(math.Ceil(math.Sqrt(float64(perPage) * 0.75)))
+	rows := (perPage + cols - 1) / cols
+	const headerHeight, labelHeight, padding = 12.0, 5.0, 2.0
+	cellW := (pageWidth - pdfMargin*2) / float64(cols)
+	cellH := (pageHeight - pdfMargin*2 - headerHeight) / float64(rows)
+	strs := exportStrings(task)
+
+	for start := 0; start < len(task.Pages); start += perPage {
+		end := start + perPage
+		if end > len(task.Pages) {
+			end = len(task.Pages)
+		}
+		pdf.AddPage()
+		s.setFont(pdf, fontFamily, 10)
+		title := fmt.Sprintf(strs.ContactTitle, documentTitle(task), task.Pages[start].PageNumber, task.Pages[end-1].PageNumber, task.TotalPages)
+		pdf.CellFormat(0, 5, s.encodeText(pdf, fontFamily, title), "", 1, "L", false, 0, "")
+		s.writeContactLegend(pdf, fontFamily, strs)
+
+		for i, page := range task.Pages[start:end] {
+			x := pdfMargin + float64(i%cols)*cellW
+			y := pdfMargin + headerHeight + float64(i/cols)*cellH
+			status := pageContactStatus(page)
+			boxW, boxH := cellW-padding*2, cellH-padding*2-labelHeight
+
+			thumb := *page
+			if path, err := s.resizedPageImage(page, contactSheetThumbWidth); err == nil {
+				thumb.ImagePath = path
+			} else {
+				log.Printf("contact sheet thumbnail of page %d failed: %v", page.PageNumber, err)
+			}
+			drawW, drawH := fitImage(&thumb, boxW, boxH)
+			if drawW == 0 || drawH == 0 {
+				drawW, drawH = boxW, boxH
+			}
+			imgX := x + padding + (boxW-drawW)/2
+			imgY := y + padding
+			s.drawPDFImage(pdf, task, &thumb, imgX, imgY, drawW, drawH)
+
+			pdf.SetDrawColor(status.r, status.g, status.b)
+			pdf.SetLineWidth(0.8)
+			pdf.Rect(imgX, imgY, drawW, drawH, "D")
+			pdf.SetTextColor(status.r, status.g, status.b)
+			s.setFont(pdf, fontFamily, 8)
+			pdf.SetXY(x, imgY+drawH+0.5)
+			label := fmt.Sprintf("%d · %s", page.PageNumber, status.label(strs))
+			pdf.CellFormat(cellW, labelHeight-0.5, s.encodeText(pdf, fontFamily, label), "", 0, "C", false, 0, "")
+			pdf.SetTextColor(0, 0, 0)
+		}
+	}
+	pdf.SetDrawColor(0, 0, 0)
+
+	sheetPath := filepath.Join(s.taskDir(task.ID), "contact_sheet.pdf")
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, "", fmt.Errorf("生成缩略图总览失败: %w", err)
+	}
+	if err := s.writeTaskFileAtomic(task.ID, sheetPath, buf.Bytes()); err != nil {
+		return nil, "", fmt.Errorf("生成缩略图总览失败: %w", err)
+	}
+	task.ContactSheetPath = sheetPath
+	task.ContactSheetURL = s.buildFileURL(task.ID, "contact_sheet.pdf")
+	if err := s.saveTask(task); err != nil {
+		return nil, "", err
+	}
+	return task, task.ContactSheetURL, nil
+}
+
+func (s *TaskService) writeContactLegend(pdf *gofpdf.Fpdf, fontFamily string, strs locale.Strings) {
+	s.setFont(pdf, fontFamily, 8)
+	for _, status := range []contactStatus{contactTranslated, contactBlank, contactPending, contactFailed, contactBlocked} {
+		pdf.SetFillColor(status.r, status.g, status.b)
+		x, y := pdf.GetX(), pdf.GetY()
+		pdf.Rect(x, y+1, 3, 3, "F")
+		pdf.SetX(x + 4)
+		pdf.CellFormat(18, 5, s.encodeText(pdf, fontFamily, status.label(strs)), "", 0, "L", false, 0, "")
+	}
+	pdf.Ln(7)
+}
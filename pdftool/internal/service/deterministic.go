@@ -0,0 +1,79 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jung-kurt/gofpdf"
+
+	"pdftool/internal/model"
+)
+
+// DeterministicEpoch is the creation and modification date written into
+// exports in deterministic mode.
+var DeterministicEpoch = time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// deterministicNamespace seeds the name-based UUIDs handed out in
+// deterministic mode.
+var deterministicNamespace = uuid.MustParse("6f1c2b9e-4d0a-5e8b-9c3f-2a7d1e5b8c40")
+
+// newTaskDir picks the ID of a new task and creates its directory. In
+// deterministic mode IDs are derived from a sequence number, so the n-th task
+// of an empty storage dir always gets the same ID.
+func (s *TaskService) newTaskDir() (string, error) {
+	if !s.deterministic {
+		taskID := uuid.NewString()
+		if err := os.MkdirAll(s.taskDir(taskID), 0o755); err != nil {
+			return "", err
+		}
+		return taskID, nil
+	}
+	if err := os.MkdirAll(s.storageDir, 0o755); err != nil {
+		return "", err
+	}
+	for n := 1; ; n++ {
+		taskID := uuid.NewSHA1(deterministicNamespace, []byte(fmt.Sprintf("task-%d", n))).String()
+		if _, err := os.Stat(s.trashDir(taskID)); err == nil {
+			continue
+		}
+		err := os.Mkdir(s.taskDir(taskID), 0o755)
+		if errors.Is(err, os.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		return taskID, nil
+	}
+}
+
+// newPageID returns a random page ID, or one derived from the task ID and
+// page number in deterministic mode.
+func (s *TaskService) newPageID(taskID string, pageNumber int) string {
+	if !s.deterministic {
+		return uuid.NewString()
+	}
+	namespace, err := uuid.Parse(taskID)
+	if err != nil {
+		namespace = deterministicNamespace
+	}
+	return uuid.NewSHA1(namespace, []byte(fmt.Sprintf("page-%d", pageNumber))).String()
+}
+
+// newPDF starts an A4 export carrying the task's document metadata. In
+// deterministic mode the timestamps are fixed and fonts and the resource
+// dictionary are written in a stable order. gofpdf still emits images of equal
+// width in map order, so their object numbers may differ between runs.
+func (s *TaskService) newPDF(task *model.Task) *gofpdf.Fpdf {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	applyPDFMetadata(pdf, task)
+	if s.deterministic {
+		pdf.SetCreationDate(DeterministicEpoch)
+		pdf.SetModificationDate(DeterministicEpoch)
+		pdf.SetCatalogSort(true)
+	}
+	return pdf
+}
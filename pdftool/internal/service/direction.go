@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/jung-kurt/gofpdf"
+
+	"pdftool/internal/model"
+	"pdftool/internal/translator"
+)
+
+// Writing modes of the source document.
+const (
+	WritingModeHorizontal = ""
+	WritingModeVertical   = "vertical" // vertical CJK, columns read right to left
+	WritingModeRTL        = "rtl"      // Arabic, Hebrew and other right-to-left scripts
+)
+
+func validateWritingMode(mode string) (string, error) {
+	mode = strings.ToLower(strings.TrimSpace(mode))
+	switch mode {
+	case WritingModeHorizontal, WritingModeVertical, WritingModeRTL:
+		return mode, nil
+	case "horizontal", "ltr":
+		return WritingModeHorizontal, nil
+	}
+	return "", fmt.Errorf("不支持的书写方向: %s", mode)
+}
+
+// withWritingModeHint adds reading-direction instructions for the task's writing mode.
+func withWritingModeHint(ctx context.Context, task *model.Task) context.Context {
+	switch task.WritingMode {
+	case WritingModeVertical:
+		return translator.WithPromptHint(ctx, "原文为竖排文字：请按每列从上到下、列与列从右到左的顺序阅读识别，sourceText 以横排输出，注意不要把相邻列的文字拼接错位。")
+	case WritingModeRTL:
+		return translator.WithPromptHint(ctx, "原文为从右向左书写的文字（如阿拉伯语、希伯来语）：请从页面右侧开始阅读，sourceText 按正确的逻辑顺序输出原文，不要反转字符或单词顺序。")
+	}
+	return ctx
+}
+
+// isRTLText reports whether most letters of text belong to right-to-left scripts.
+func isRTLText(text string) bool {
+	var rtl, letters int
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		if unicode.In(r, unicode.Arabic, unicode.Hebrew, unicode.Syriac, unicode.Thaana, unicode.Nko) {
+			rtl++
+		}
+	}
+	return letters > 0 && rtl*2 > letters
+}
+
+// setPDFDirection switches pdf to right-to-left output when text is mostly
+// right-to-left, so gofpdf lays each line out in visual order, and returns the
+// matching alignment. Callers switch back with pdf.LTR once the text is drawn.
+func setPDFDirection(pdf *gofpdf.Fpdf, text string) string {
+	if isRTLText(text) {
+		pdf.RTL()
+		return "R"
+	}
+	pdf.LTR()
+	return "L"
+}
+
+// translationsRTL reports whether the task's translated text is mostly right-to-left.
+func translationsRTL(task *model.Task) bool {
+	var b strings.Builder
+	for _, page := range task.Pages {
+		if page.HasText {
+			b.WriteString(page.Translation)
+		}
+	}
+	return isRTLText(b.String())
+}
@@ -0,0 +1,46 @@
+package service
+
+import (
+	"bytes"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/jung-kurt/gofpdf"
+	"golang.org/x/image/font/gofont/goregular"
+
+	"pdftool/internal/model"
+)
+
+// pdfString encodes text the way gofpdf writes it for UTF-8 fonts.
+func pdfString(text string) []byte {
+	var b []byte
+	for _, unit := range utf16.Encode([]rune(text)) {
+		b = append(b, byte(unit>>8), byte(unit))
+	}
+	return b
+}
+
+// TestPDFBodyRightToLeft checks that right-to-left text is drawn in visual
+// order, and that the next left-to-right text is not.
+func TestPDFBodyRightToLeft(t *testing.T) {
+	s := newDeterministicService(t)
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetCompression(false)
+	pdf.AddUTF8FontFromBytes("go", "", goregular.TTF)
+	pdf.AddPage()
+	s.writePDFBody(pdf, "go", &model.PageResult{}, "שלום")
+	s.writePDFBody(pdf, "go", &model.PageResult{}, "abc")
+	var out bytes.Buffer
+	if err := pdf.Output(&out); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(out.Bytes(), pdfString("םולש")) {
+		t.Fatal("RTL text not drawn in visual order")
+	}
+	if bytes.Contains(out.Bytes(), pdfString("שלום")) {
+		t.Fatal("RTL text drawn in logical order")
+	}
+	if !bytes.Contains(out.Bytes(), pdfString("abc")) {
+		t.Fatal("LTR text after RTL text was reversed")
+	}
+}
@@ -0,0 +1,342 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"pdftool/internal/model"
+)
+
+// DOCX pages are A4 with 2.5 cm margins. Word measures the page in twips
+// (1/20 pt) and drawings in EMU (635 per twip).
+const (
+	docxPageWidth   = 11906
+	docxPageHeight  = 16838
+	docxMargin      = 1417
+	docxEMUPerTwip  = 635
+	docxEMUPerPoint = 20 * docxEMUPerTwip
+	// docxHeaderRoom is kept free above an image for its page heading.
+	docxHeaderRoom = 1440
+)
+
+// docxImageExts maps gofpdf image types, as returned by preparePDFImage, to
+// the extensions of DOCX media parts.
+var docxImageExts = map[string]string{
+	"PNG": "png",
+	"JPG": "jpeg",
+	"GIF": "gif",
+}
+
+// MergeDocx generates a Word document with a heading per page, the
+// translations as editable paragraphs followed by the page's extracted
+// figures, and the original image of every page without a translation.
+func (s *TaskService) MergeDocx(ctx context.Context, taskID string) (*model.Task, string, error) {
+	task, err := s.loadTask(taskID)
+	if err != nil {
+		return nil, "", err
+	}
+	docxPath := filepath.Join(s.taskDir(task.ID), "combined.docx")
+	if err := s.writeDocx(ctx, docxPath, task); err != nil {
+		return nil, "", err
+	}
+	task.CombinedDocxPath = docxPath
+	task.CombinedDocxURL = s.buildFileURL(task.ID, "combined.docx")
+	clearStaleExport(task, ExportDocx)
+	recordExportProgress(task, ExportDocx, "")
+	s.publishExport(ctx, task, docxPath)
+	if err := s.saveTask(task); err != nil {
+		return nil, "", err
+	}
+	s.publishExportEvent(task, ExportDocx, task.CombinedDocxURL)
+	return task, task.CombinedDocxURL, nil
+}
+
+// writeDocx renders task in memory and then replaces path, so a failed
+// export leaves the previous document in place.
+func (s *TaskService) writeDocx(ctx context.Context, path string, task *model.Task) error {
+	translated := false
+	for _, page := range task.Pages {
+		if page.HasText && pageExportText(task, page) != "" {
+			translated = true
+			break
+		}
+	}
+	if !translated {
+		return fmt.Errorf("没有可用的翻译文本")
+	}
+	modified := time.Now().UTC()
+	if s.deterministic {
+		modified = DeterministicEpoch
+	}
+	var buf bytes.Buffer
+	w := &docxWriter{zip: zip.NewWriter(&buf), modified: modified}
+	err := s.writeDocxParts(ctx, w, task)
+	if closeErr := w.zip.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = s.writeTaskFileAtomic(task.ID, path, buf.Bytes())
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return err
+		}
+		return fmt.Errorf("生成DOCX失败: %w", err)
+	}
+	return nil
+}
+
+func (s *TaskService) writeDocxParts(ctx context.Context, w *docxWriter, task *model.Task) error {
+	if err := w.part("[Content_Types].xml", docxContentTypes); err != nil {
+		return err
+	}
+	if err := w.part("_rels/.rels", docxPackageRels); err != nil {
+		return err
+	}
+	if err := w.part("word/styles.xml", docxStyles); err != nil {
+		return err
+	}
+	if err := w.part("docProps/core.xml", docxCoreProperties(task, w.modified)); err != nil {
+		return err
+	}
+
+	strs := exportStrings(task)
+	w.paragraph("Title", documentTitle(task))
+	if notice, ok := partialNotice(task); ok {
+		w.paragraph("Quote", notice)
+	}
+	for _, page := range task.Pages {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if header, ok := pageHeader(task, page); ok {
+			w.paragraph("Heading1", header)
+		}
+		text := pageExportText(task, page)
+		if page.HasText && text != "" {
+			w.text(pageTextWithNotes(page, text, strs.Notes))
+			for _, figure := range page.Figures {
+				img := s.preparePDFImage(figure.Path)
+				if ext, ok := docxImageExts[img.imageType]; ok && img.err == nil {
+					if err := w.figure(img.data, ext, figure.W, figure.H); err != nil {
+						return err
+					}
+				}
+			}
+			continue
+		}
+		img := s.preparePDFImage(page.ImagePath)
+		if img.err == nil && (img.width <= 0 || img.height <= 0) {
+			img.width, img.height = page.ImageWidth, page.ImageHeight
+		}
+		ext, ok := docxImageExts[img.imageType]
+		if img.err != nil || !ok || img.width <= 0 || img.height <= 0 {
+			w.paragraph("", strs.ImageUnavailable)
+			continue
+		}
+		if err := w.image(img.data, ext, img.width, img.height); err != nil {
+			return err
+		}
+	}
+
+	document := xml.Header + `<w:document xmlns:w="` + docxNSMain + `" xmlns:r="` + docxNSRels +
+		`" xmlns:wp="http://schemas.openxmlformats.org/drawingml/2006/wordprocessingDrawing"` +
+		` xmlns:a="http://schemas.openxmlformats.org/drawingml/2006/main"` +
+		` xmlns:pic="http://schemas.openxmlformats.org/drawingml/2006/picture"><w:body>` +
+		w.body.String() +
+		fmt.Sprintf(`<w:sectPr><w:pgSz w:w="%d" w:h="%d"/><w:pgMar w:top="%d" w:right="%d" w:bottom="%d" w:left="%d" w:header="708" w:footer="708" w:gutter="0"/></w:sectPr>`,
+			docxPageWidth, docxPageHeight, docxMargin, docxMargin, docxMargin, docxMargin) +
+		`</w:body></w:document>`
+	if err := w.part("word/document.xml", document); err != nil {
+		return err
+	}
+	rels := xml.Header + `<Relationships xmlns="` + docxNSPackageRels + `">` +
+		`<Relationship Id="rIdStyles" Type="` + docxNSRels + `/styles" Target="styles.xml"/>` +
+		w.rels.String() + `</Relationships>`
+	return w.part("word/_rels/document.xml.rels", rels)
+}
+
+const (
+	docxNSMain        = "http://schemas.openxmlformats.org/wordprocessingml/2006/main"
+	docxNSRels        = "http://schemas.openxmlformats.org/officeDocument/2006/relationships"
+	docxNSPackageRels = "http://schemas.openxmlformats.org/package/2006/relationships"
+)
+
+const docxContentTypes = xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+	`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+	`<Default Extension="xml" ContentType="application/xml"/>` +
+	`<Default Extension="png" ContentType="image/png"/>` +
+	`<Default Extension="jpeg" ContentType="image/jpeg"/>` +
+	`<Default Extension="gif" ContentType="image/gif"/>` +
+	`<Override PartName="/word/document.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.document.main+xml"/>` +
+	`<Override PartName="/word/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.styles+xml"/>` +
+	`<Override PartName="/docProps/core.xml" ContentType="application/vnd.openxmlformats-package.core-properties+xml"/>` +
+	`</Types>`
+
+const docxPackageRels = xml.Header + `<Relationships xmlns="` + docxNSPackageRels + `">` +
+	`<Relationship Id="rId1" Type="` + docxNSRels + `/officeDocument" Target="word/document.xml"/>` +
+	`<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/package/2006/relationships/metadata/core-properties" Target="docProps/core.xml"/>` +
+	`</Relationships>`
+
+// docxStyles defines the paragraph styles the export uses, so headings show
+// up in Word's navigation pane and can be restyled in one place.
+const docxStyles = xml.Header + `<w:styles xmlns:w="` + docxNSMain + `">` +
+	`<w:docDefaults><w:rPrDefault><w:rPr><w:sz w:val="22"/></w:rPr></w:rPrDefault>` +
+	`<w:pPrDefault><w:pPr><w:spacing w:after="160" w:line="300" w:lineRule="auto"/></w:pPr></w:pPrDefault></w:docDefaults>` +
+	`<w:style w:type="paragraph" w:default="1" w:styleId="Normal"><w:name w:val="Normal"/><w:qFormat/></w:style>` +
+	`<w:style w:type="paragraph" w:styleId="Title"><w:name w:val="Title"/><w:basedOn w:val="Normal"/><w:next w:val="Normal"/><w:qFormat/>` +
+	`<w:pPr><w:jc w:val="center"/><w:spacing w:after="480"/></w:pPr><w:rPr><w:b/><w:sz w:val="40"/></w:rPr></w:style>` +
+	`<w:style w:type="paragraph" w:styleId="Heading1"><w:name w:val="heading 1"/><w:basedOn w:val="Normal"/><w:next w:val="Normal"/><w:qFormat/>` +
+	`<w:pPr><w:keepNext/><w:spacing w:before="360" w:after="120"/><w:outlineLvl w:val="0"/></w:pPr><w:rPr><w:b/><w:sz w:val="28"/></w:rPr></w:style>` +
+	`<w:style w:type="paragraph" w:styleId="Quote"><w:name w:val="Quote"/><w:basedOn w:val="Normal"/><w:next w:val="Normal"/><w:qFormat/>` +
+	`<w:pPr><w:pBdr><w:left w:val="single" w:sz="12" w:space="8" w:color="999999"/></w:pBdr><w:ind w:left="284"/></w:pPr><w:rPr><w:i/></w:rPr></w:style>` +
+	`</w:styles>`
+
+func docxCoreProperties(task *model.Task, modified time.Time) string {
+	stamp := modified.Format(time.RFC3339)
+	var b strings.Builder
+	b.WriteString(xml.Header + `<cp:coreProperties xmlns:cp="http://schemas.openxmlformats.org/package/2006/metadata/core-properties"` +
+		` xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:dcterms="http://purl.org/dc/terms/"` +
+		` xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance">`)
+	b.WriteString("<dc:title>" + xmlText(documentTitle(task)) + "</dc:title>")
+	if author := documentAuthor(task); author != "" {
+		b.WriteString("<dc:creator>" + xmlText(author) + "</dc:creator>")
+	}
+	if task.Metadata != nil {
+		if task.Metadata.Subject != "" {
+			b.WriteString("<dc:subject>" + xmlText(task.Metadata.Subject) + "</dc:subject>")
+		}
+		if task.Metadata.Keywords != "" {
+			b.WriteString("<cp:keywords>" + xmlText(task.Metadata.Keywords) + "</cp:keywords>")
+		}
+	}
+	b.WriteString(`<dcterms:created xsi:type="dcterms:W3CDTF">` + stamp + `</dcterms:created>`)
+	b.WriteString(`<dcterms:modified xsi:type="dcterms:W3CDTF">` + stamp + `</dcterms:modified>`)
+	b.WriteString("</cp:coreProperties>")
+	return b.String()
+}
+
+// docxWriter accumulates the document body and its image relationships while
+// media parts are streamed into the package.
+type docxWriter struct {
+	zip      *zip.Writer
+	modified time.Time
+	body     strings.Builder
+	rels     strings.Builder
+	images   int
+}
+
+func (w *docxWriter) part(name, content string) error {
+	out, err := w.create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(out, content)
+	return err
+}
+
+func (w *docxWriter) create(name string) (io.Writer, error) {
+	return w.zip.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: w.modified})
+}
+
+// paragraph adds a single-line paragraph in style ("" for Normal).
+func (w *docxWriter) paragraph(style, text string) {
+	w.body.WriteString("<w:p>")
+	if style != "" {
+		w.body.WriteString(`<w:pPr><w:pStyle w:val="` + style + `"/></w:pPr>`)
+	}
+	w.run(text, false)
+	w.body.WriteString("</w:p>")
+}
+
+// text adds a page translation: blank lines separate paragraphs and single
+// line breaks are kept within a paragraph. Right-to-left text is marked as
+// such so Word aligns and orders it correctly.
+func (w *docxWriter) text(text string) {
+	rtl := isRTLText(text)
+	for _, paragraph := range strings.Split(text, "\n\n") {
+		paragraph = strings.Trim(paragraph, "\n")
+		if paragraph == "" {
+			continue
+		}
+		w.body.WriteString("<w:p>")
+		if rtl {
+			w.body.WriteString("<w:pPr><w:bidi/></w:pPr>")
+		}
+		for i, line := range strings.Split(paragraph, "\n") {
+			if i > 0 {
+				w.body.WriteString("<w:r><w:br/></w:r>")
+			}
+			w.run(line, rtl)
+		}
+		w.body.WriteString("</w:p>")
+	}
+}
+
+func (w *docxWriter) run(text string, rtl bool) {
+	w.body.WriteString("<w:r>")
+	if rtl {
+		w.body.WriteString("<w:rPr><w:rtl/></w:rPr>")
+	}
+	w.body.WriteString(`<w:t xml:space="preserve">` + xmlText(text) + "</w:t></w:r>")
+}
+
+// image stores data as a media part and adds it as a paragraph of its own,
+// scaled to fit the text area.
+func (w *docxWriter) image(data []byte, ext string, width, height int) error {
+	maxW := float64((docxPageWidth - 2*docxMargin) * docxEMUPerTwip)
+	maxH := float64((docxPageHeight - 2*docxMargin - docxHeaderRoom) * docxEMUPerTwip)
+	scale := min(maxW/float64(width), maxH/float64(height))
+	return w.drawing(data, ext, int64(float64(width)*scale), int64(float64(height)*scale))
+}
+
+// figure adds an embedded image of a page at the size it had there, in
+// points, shrunk to the text area when larger.
+func (w *docxWriter) figure(data []byte, ext string, width, height float64) error {
+	if width <= 0 || height <= 0 {
+		return nil
+	}
+	maxW := float64((docxPageWidth - 2*docxMargin) * docxEMUPerTwip)
+	maxH := float64((docxPageHeight - 2*docxMargin - docxHeaderRoom) * docxEMUPerTwip)
+	cx, cy := width*docxEMUPerPoint, height*docxEMUPerPoint
+	scale := min(1, maxW/cx, maxH/cy)
+	return w.drawing(data, ext, int64(cx*scale), int64(cy*scale))
+}
+
+// drawing stores data as a media part and adds it, cx by cy EMU, as a
+// paragraph of its own.
+func (w *docxWriter) drawing(data []byte, ext string, cx, cy int64) error {
+	w.images++
+	n := w.images
+	name := fmt.Sprintf("image%d.%s", n, ext)
+	out, err := w.create("word/media/" + name)
+	if err != nil {
+		return err
+	}
+	if _, err := out.Write(data); err != nil {
+		return err
+	}
+	fmt.Fprintf(&w.rels, `<Relationship Id="rIdImage%d" Type="%s/image" Target="media/%s"/>`, n, docxNSRels, name)
+	fmt.Fprintf(&w.body, `<w:p><w:pPr><w:jc w:val="center"/></w:pPr><w:r><w:drawing>`+
+		`<wp:inline distT="0" distB="0" distL="0" distR="0"><wp:extent cx="%[1]d" cy="%[2]d"/><wp:docPr id="%[3]d" name="%[4]s"/>`+
+		`<a:graphic><a:graphicData uri="http://schemas.openxmlformats.org/drawingml/2006/picture"><pic:pic>`+
+		`<pic:nvPicPr><pic:cNvPr id="%[3]d" name="%[4]s"/><pic:cNvPicPr/></pic:nvPicPr>`+
+		`<pic:blipFill><a:blip r:embed="rIdImage%[3]d"/><a:stretch><a:fillRect/></a:stretch></pic:blipFill>`+
+		`<pic:spPr><a:xfrm><a:off x="0" y="0"/><a:ext cx="%[1]d" cy="%[2]d"/></a:xfrm><a:prstGeom prst="rect"><a:avLst/></a:prstGeom></pic:spPr>`+
+		`</pic:pic></a:graphicData></a:graphic></wp:inline></w:drawing></w:r></w:p>`, cx, cy, n, name)
+	return nil
+}
+
+// xmlText escapes text for XML character data.
+func xmlText(text string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(text))
+	return b.String()
+}
@@ -0,0 +1,144 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"pdftool/internal/model"
+	"pdftool/internal/pdfutil"
+	"pdftool/internal/translator"
+)
+
+// Rough per-page token estimates for quotes. Image input follows the common
+// 512px-tile accounting; output covers both the transcription and translation.
+const (
+	quotePromptTokens    = 300
+	quoteTileTokens      = 170
+	quoteImageBaseTokens = 85
+	quoteOutputTokens    = 1000
+	quoteMaxImageSide    = 2048
+	quoteShortSide       = 768
+	quoteTileSide        = 512
+	// quoteTextBytesPerToken approximates how text-layer pages tokenize; their
+	// output is only the translation, about as long as the input.
+	quoteTextBytesPerToken = 4
+)
+
+// quoteTask marks blank pages as done and estimates the cost of the rest.
+func (s *TaskService) quoteTask(task *model.Task, selected []*model.PageResult) *model.TaskQuote {
+	quote := &model.TaskQuote{PricePerMillionTokens: s.budget.PricePerMillionTokens}
+	now := time.Now()
+	for _, page := range selected {
+		if isTextPage(page) {
+			textTokens := int64(len(page.SourceText)/quoteTextBytesPerToken) + 1
+			quote.Pages++
+			quote.EstimatedInputTokens += quotePromptTokens + textTokens
+			quote.EstimatedOutputTokens += textTokens
+			continue
+		}
+		blank, err := s.isBlankPage(page)
+		if err != nil {
+			log.Printf("blank detection of page %d failed: %v", page.PageNumber, err)
+		}
+		if blank {
+			page.Status = model.PageStatusCompleted
+			page.HasText = false
+			page.UpdatedAt = now
+			quote.BlankPages = append(quote.BlankPages, page.PageNumber)
+			continue
+		}
+		quote.Pages++
+		quote.EstimatedInputTokens += quotePromptTokens + imageTokens(page.ImageWidth, page.ImageHeight)
+		quote.EstimatedOutputTokens += quoteOutputTokens
+	}
+	quote.EstimatedTokens = quote.EstimatedInputTokens + quote.EstimatedOutputTokens
+	quote.EstimatedCost = math.Round(s.tokenCost(quote.EstimatedTokens)*10000) / 10000
+	quote.WithinBudget = s.fitsBudget(quote.EstimatedTokens)
+	return quote
+}
+
+func imageTokens(width, height int) int64 {
+	if width <= 0 || height <= 0 {
+		return quoteImageBaseTokens + 4*quoteTileTokens
+	}
+	w, h := float64(width), float64(height)
+	if scale := quoteMaxImageSide / math.Max(w, h); scale < 1 {
+		w, h = w*scale, h*scale
+	}
+	if scale := quoteShortSide / math.Min(w, h); scale < 1 {
+		w, h = w*scale, h*scale
+	}
+	tiles := math.Ceil(w/quoteTileSide) * math.Ceil(h/quoteTileSide)
+	return quoteImageBaseTokens + int64(tiles)*quoteTileTokens
+}
+
+// fitsBudget reports whether spending tokens more stays within every token and cost cap.
+func (s *TaskService) fitsBudget(tokens int64) bool {
+	status := s.BudgetStatus()
+	cost := s.tokenCost(tokens)
+	switch {
+	case status.DailyTokenLimit > 0 && status.DayTokens+tokens > status.DailyTokenLimit:
+		return false
+	case status.MonthlyTokenLimit > 0 && status.MonthTokens+tokens > status.MonthlyTokenLimit:
+		return false
+	case status.DailyCostLimit > 0 && status.DayCost+cost > status.DailyCostLimit:
+		return false
+	case status.MonthlyCostLimit > 0 && status.MonthCost+cost > status.MonthlyCostLimit:
+		return false
+	}
+	return true
+}
+
+// StartTask launches translation of a dry-run task after the user accepted
+// its quote. The dry-run flag is checked and cleared under the task lock, so
+// of concurrent calls only the one that cleared it dispatches the pages.
+func (s *TaskService) StartTask(ctx context.Context, taskID string, provider translator.ProviderConfig) (*model.Task, error) {
+	task, err := s.loadTask(taskID)
+	if err != nil {
+		return nil, err
+	}
+	if !task.DryRun {
+		return nil, fmt.Errorf("任务已开始翻译")
+	}
+	if err := s.checkBudget(); err != nil {
+		return nil, err
+	}
+	providerCfg, err := s.mergeProviderConfig(provider, task)
+	if err != nil {
+		return nil, err
+	}
+	providerCfg.OptimizeLayout = true
+	if _, err := s.updateTask(taskID, func(current *model.Task) error {
+		if !current.DryRun {
+			return fmt.Errorf("任务已开始翻译")
+		}
+		current.DryRun = false
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	task, err = s.resumeWithProvider(taskID, providerCfg, false)
+	if err != nil {
+		// Nothing was dispatched; restore the flag so the quote can be accepted again.
+		if _, restoreErr := s.updateTask(taskID, func(current *model.Task) error {
+			current.DryRun = true
+			return nil
+		}); restoreErr != nil {
+			log.Printf("restore dry run of task %s failed: %v", taskID, restoreErr)
+		}
+		return nil, err
+	}
+	return task, nil
+}
+
+func (s *TaskService) isBlankPage(page *model.PageResult) (bool, error) {
+	path, cleanup, err := s.plainFile(page.ImagePath)
+	defer cleanup()
+	if err != nil {
+		return false, err
+	}
+	return pdfutil.IsBlankImage(path)
+}
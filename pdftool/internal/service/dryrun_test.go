@@ -0,0 +1,39 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"pdftool/internal/model"
+	"pdftool/internal/translator"
+)
+
+// TestStartTaskOnce checks that concurrent starts of a dry-run task dispatch
+// its pages only once.
+func TestStartTaskOnce(t *testing.T) {
+	ctx := context.Background()
+	provider := translator.ProviderConfig{Type: translator.ProviderTypeMock}
+	s := newDeterministicService(t)
+	task := createSampleTask(t, s, TranslationSettings{DryRun: true})
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	started := 0
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := s.StartTask(ctx, task.ID, provider); err == nil {
+				mu.Lock()
+				started++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if started != 1 {
+		t.Fatalf("task started %d times, want 1", started)
+	}
+	waitForState(t, s, task.ID, model.TaskStateCompleted)
+}
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"strings"
+
+	"pdftool/internal/model"
+	"pdftool/internal/translator"
+)
+
+func convertElements(elements *translator.Elements) *model.PageElements {
+	if elements == nil {
+		return nil
+	}
+	return &model.PageElements{
+		Title:    convertElementList(elements.Title),
+		Headers:  convertElementList(elements.Headers),
+		Body:     convertElementList(elements.Body),
+		Captions: convertElementList(elements.Captions),
+	}
+}
+
+func convertBlocks(blocks []translator.Block) []model.TextBlock {
+	if len(blocks) == 0 {
+		return nil
+	}
+	out := make([]model.TextBlock, 0, len(blocks))
+	for _, block := range blocks {
+		out = append(out, model.TextBlock{
+			Text:        normalizeText(block.Text),
+			Translation: normalizeText(block.Translation),
+			BBox:        block.BBox,
+		})
+	}
+	return out
+}
+
+func convertElementList(list []translator.Element) []model.Element {
+	if len(list) == 0 {
+		return nil
+	}
+	out := make([]model.Element, 0, len(list))
+	for _, element := range list {
+		out = append(out, model.Element{
+			SourceText:  normalizeText(element.SourceText),
+			Translation: normalizeText(element.TranslatedText),
+		})
+	}
+	return out
+}
+
+// appendElements adds the elements of a later region of the page to those
+// of the earlier ones.
+func appendElements(dst, src *translator.Elements) *translator.Elements {
+	if src == nil {
+		return dst
+	}
+	if dst == nil {
+		dst = &translator.Elements{}
+	}
+	dst.Title = append(dst.Title, src.Title...)
+	dst.Headers = append(dst.Headers, src.Headers...)
+	dst.Body = append(dst.Body, src.Body...)
+	dst.Captions = append(dst.Captions, src.Captions...)
+	return dst
+}
+
+// markdownHeadings turns the lines of text that are the page's titles and
+// headers into Markdown headings below the page heading: ### for titles,
+// #### for headers. Lines already marked up are left alone.
+func markdownHeadings(page *model.PageResult, text string) string {
+	if page.Elements == nil || len(page.Elements.Title)+len(page.Elements.Headers) == 0 {
+		return text
+	}
+	levels := make(map[string]string)
+	for _, element := range page.Elements.Headers {
+		addHeadingLine(levels, element, "#### ")
+	}
+	for _, element := range page.Elements.Title {
+		addHeadingLine(levels, element, "### ")
+	}
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if prefix, ok := levels[strings.TrimSpace(line)]; ok && !strings.HasPrefix(line, "#") {
+			lines[i] = prefix + strings.TrimSpace(line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func addHeadingLine(levels map[string]string, element model.Element, prefix string) {
+	for _, text := range []string{element.Translation, element.SourceText} {
+		// Multi-line headings do not map onto single lines of the text.
+		if text != "" && !strings.Contains(text, "\n") {
+			levels[text] = prefix
+		}
+	}
+}
@@ -0,0 +1,20 @@
+package service
+
+import (
+	"testing"
+
+	"pdftool/internal/model"
+)
+
+func TestMarkdownHeadings(t *testing.T) {
+	page := &model.PageResult{Elements: &model.PageElements{
+		Title:    []model.Element{{SourceText: "Part One", Translation: "第一部"}},
+		Headers:  []model.Element{{SourceText: "Chapter 1", Translation: "第一章"}},
+		Captions: []model.Element{{SourceText: "Figure 1", Translation: "图 1"}},
+	}}
+	text := "第一部\n\n第一章\n\n四月里一个晴朗寒冷的日子。\n\n图 1\n\n## 第一章"
+	want := "### 第一部\n\n#### 第一章\n\n四月里一个晴朗寒冷的日子。\n\n图 1\n\n## 第一章"
+	if got := markdownHeadings(page, text); got != want {
+		t.Errorf("markdownHeadings =\n%s\nwant\n%s", got, want)
+	}
+}
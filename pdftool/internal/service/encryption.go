@@ -0,0 +1,211 @@
+package service
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+
+	"pdftool/internal/cryptfile"
+	"pdftool/internal/model"
+)
+
+// Storage key modes.
+const (
+	StorageKeyServer = "server"
+	StorageKeyTask   = "task"
+)
+
+// taskKeyFile holds a task's data key, encrypted with the server key.
+// Deleting it makes the task's files unreadable.
+const taskKeyFile = "data.key"
+
+// vault resolves the keys encrypting stored files. With no server key
+// configured every file is written in plaintext.
+type vault struct {
+	master  *cryptfile.Key
+	perTask bool
+	mu      sync.Mutex
+	keys    map[string]*cryptfile.Key
+}
+
+func newVault(master *cryptfile.Key, mode string) *vault {
+	return &vault{
+		master:  master,
+		perTask: strings.EqualFold(strings.TrimSpace(mode), StorageKeyTask),
+		keys:    make(map[string]*cryptfile.Key),
+	}
+}
+
+func (v *vault) enabled() bool {
+	return v != nil && v.master != nil
+}
+
+// storageKey returns the key of files shared by all tasks, such as the index.
+func (v *vault) storageKey() *cryptfile.Key {
+	if !v.enabled() {
+		return nil
+	}
+	return v.master
+}
+
+// taskKey returns the key of the task stored in dir, creating a per-task key
+// on first use. It returns nil when encryption is disabled.
+func (v *vault) taskKey(dir string) (*cryptfile.Key, error) {
+	if !v.enabled() {
+		return nil, nil
+	}
+	if !v.perTask {
+		return v.master, nil
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if key, ok := v.keys[dir]; ok {
+		return key, nil
+	}
+	path := filepath.Join(dir, taskKeyFile)
+	data, err := cryptfile.ReadFile(v.master, path)
+	var key *cryptfile.Key
+	switch {
+	case err == nil:
+		if len(data) != len(cryptfile.Key{}) {
+			return nil, fmt.Errorf("invalid task key %s", path)
+		}
+		key = new(cryptfile.Key)
+		copy(key[:], data)
+	case os.IsNotExist(err):
+		if key, err = cryptfile.NewKey(); err != nil {
+			return nil, err
+		}
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, err
+		}
+		if err := cryptfile.WriteFile(v.master, path, key[:], 0o600); err != nil {
+			return nil, fmt.Errorf("write task key: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("read task key: %w", err)
+	}
+	v.keys[dir] = key
+	return key, nil
+}
+
+// keyForPath returns the key of a stored file: the key of the task dir
+// (live or trashed) containing it, or the server key for other files.
+func (s *TaskService) keyForPath(path string) (*cryptfile.Key, error) {
+	if !s.vault.enabled() || !s.vault.perTask {
+		return s.vault.storageKey(), nil
+	}
+	root := filepath.Clean(s.storageDir)
+	for dir := filepath.Dir(path); dir != root && strings.HasPrefix(dir, root+string(filepath.Separator)); dir = filepath.Dir(dir) {
+		if _, err := os.Stat(filepath.Join(dir, taskKeyFile)); err == nil {
+			return s.vault.taskKey(dir)
+		}
+	}
+	return s.vault.storageKey(), nil
+}
+
+// readStored returns the plaintext of a stored file.
+func (s *TaskService) readStored(path string) ([]byte, error) {
+	key, err := s.keyForPath(path)
+	if err != nil {
+		return nil, err
+	}
+	return cryptfile.ReadFile(key, path)
+}
+
+// writeTaskFile writes a file of the task stored in dir, encrypted when enabled.
+func (s *TaskService) writeTaskFile(dir, path string, data []byte) error {
+	key, err := s.vault.taskKey(dir)
+	if err != nil {
+		return err
+	}
+	return cryptfile.WriteFile(key, path, data, 0o644)
+}
+
+// writeTaskFileAtomic replaces a file of the task via a uniquely named temp
+// file, encrypted when enabled, so concurrent writers never leave a
+// half-written file behind.
+func (s *TaskService) writeTaskFileAtomic(taskID, path string, data []byte) error {
+	key, err := s.vault.taskKey(s.taskDir(taskID))
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(key, path, data)
+}
+
+// writeFileAtomic replaces path via a uniquely named temp file, encrypting
+// data when key is set.
+func writeFileAtomic(key *cryptfile.Key, path string, data []byte) error {
+	tmp := path + "." + uuid.NewString() + ".tmp"
+	if err := cryptfile.WriteFile(key, tmp, data, 0o644); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// sealFiles encrypts the given task files in place.
+func (s *TaskService) sealFiles(taskID string, paths ...string) error {
+	key, err := s.vault.taskKey(s.taskDir(taskID))
+	if err != nil || key == nil {
+		return err
+	}
+	for _, path := range paths {
+		if err := cryptfile.EncryptFile(key, path); err != nil {
+			return fmt.Errorf("encrypt %s: %w", filepath.Base(path), err)
+		}
+	}
+	return nil
+}
+
+// sealRendered encrypts the uploaded PDF, its page images and figures once
+// rendering no longer needs them in plaintext.
+func (s *TaskService) sealRendered(task *model.Task) error {
+	paths := []string{task.OriginalPath}
+	for _, page := range task.Pages {
+		paths = append(paths, page.ImagePath)
+		for _, figure := range page.Figures {
+			paths = append(paths, figure.Path)
+		}
+	}
+	return s.sealFiles(task.ID, paths...)
+}
+
+// plainFile returns a readable path for a task file: the file itself when it
+// is stored in plaintext, otherwise a decrypted temporary copy. cleanup
+// removes the copy and must always be called.
+func (s *TaskService) plainFile(path string) (string, func(), error) {
+	key, err := s.keyForPath(path)
+	if err != nil {
+		return "", func() {}, err
+	}
+	if key == nil || !cryptfile.IsEncryptedFile(path) {
+		return path, func() {}, nil
+	}
+	plain, err := cryptfile.DecryptToTemp(key, path, "")
+	if err != nil {
+		return "", func() {}, fmt.Errorf("解密文件失败: %w", err)
+	}
+	return plain, func() { os.Remove(plain) }, nil
+}
+
+// OpenStoredFile opens a file below the storage dir for download, decrypting
+// it when it is stored encrypted.
+func (s *TaskService) OpenStoredFile(path string) (*cryptfile.File, error) {
+	if filepath.Base(path) == taskKeyFile {
+		return nil, os.ErrNotExist
+	}
+	key, err := s.keyForPath(path)
+	if err != nil {
+		return nil, err
+	}
+	return cryptfile.Open(key, path)
+}
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"io"
+	"testing"
+
+	"pdftool/internal/cryptfile"
+	"pdftool/internal/translator"
+)
+
+// TestExportsEncryptedAtRest checks that exports are never stored in
+// plaintext with a storage key and still download as the plain document.
+func TestExportsEncryptedAtRest(t *testing.T) {
+	key, err := cryptfile.NewKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewTaskService(t.TempDir(), "", "", translator.ProviderConfig{Type: translator.ProviderTypeMock}, 1,
+		Options{Deterministic: true, StorageKey: key})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	task := writeGoldenTask(t, s, false)
+
+	for name, export := range map[string]goldenExport{
+		"txt":           mergeTextExport,
+		"markdown":      mergeMarkdownExport,
+		"pdf":           mergePDFExport(PDFLayoutText),
+		"docx":          mergeDocxExport,
+		"epub":          mergeEpubExport,
+		"contact_sheet": contactSheetExport,
+	} {
+		path, err := export(s, task.ID)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !cryptfile.IsEncryptedFile(path) {
+			t.Errorf("%s: %s is stored in plaintext", name, path)
+			continue
+		}
+		f, err := s.OpenStoredFile(path)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		data, err := io.ReadAll(f)
+		f.Close()
+		if err != nil || len(data) == 0 {
+			t.Errorf("%s: decrypting export failed: %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,394 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"pdftool/internal/assets"
+	"pdftool/internal/model"
+)
+
+// epubImageTypes maps gofpdf image types, as returned by preparePDFImage, to
+// the extensions and media types of EPUB image resources.
+var epubImageTypes = map[string][2]string{
+	"PNG": {"png", "image/png"},
+	"JPG": {"jpeg", "image/jpeg"},
+	"GIF": {"gif", "image/gif"},
+}
+
+// epubLanguages maps export font languages to the book's dc:language; Latin
+// text may be any of several languages.
+var epubLanguages = map[string]string{
+	FontLangChinese:  "zh",
+	FontLangJapanese: "ja",
+	FontLangKorean:   "ko",
+	FontLangArabic:   "ar",
+	FontLangLatin:    "und",
+}
+
+// MergeEpub generates an EPUB 3 book of the translation with one chapter per
+// detected chapter heading, or per page when none is found. The export font
+// is embedded so CJK text displays on readers without such a font. Translated
+// pages are followed by their extracted figures; pages without a translation
+// show their original image.
+func (s *TaskService) MergeEpub(ctx context.Context, taskID string) (*model.Task, string, error) {
+	task, err := s.loadTask(taskID)
+	if err != nil {
+		return nil, "", err
+	}
+	epubPath := filepath.Join(s.taskDir(task.ID), "combined.epub")
+	if err := s.writeEpub(ctx, epubPath, task); err != nil {
+		return nil, "", err
+	}
+	task.CombinedEpubPath = epubPath
+	task.CombinedEpubURL = s.buildFileURL(task.ID, "combined.epub")
+	clearStaleExport(task, ExportEPUB)
+	recordExportProgress(task, ExportEPUB, "")
+	s.publishExport(ctx, task, epubPath)
+	if err := s.saveTask(task); err != nil {
+		return nil, "", err
+	}
+	s.publishExportEvent(task, ExportEPUB, task.CombinedEpubURL)
+	return task, task.CombinedEpubURL, nil
+}
+
+// epubChapter is a run of pages under one heading.
+type epubChapter struct {
+	title string
+	pages []*model.PageResult
+}
+
+// epubChapters groups the pages into chapters. Chapters start at the
+// top-level bookmarks of the outline or, without one, at the pages whose
+// translation has a title; pages before the first belong to it. Without
+// either, every page is a chapter titled by its page header.
+func epubChapters(task *model.Task) []*epubChapter {
+	starts := make(map[int]string)
+	if len(task.Outline) > 0 {
+		top := task.Outline[0].Level
+		for _, entry := range task.Outline {
+			top = min(top, entry.Level)
+		}
+		for _, entry := range task.Outline {
+			if _, ok := starts[entry.Page]; !ok && entry.Level == top {
+				starts[entry.Page] = entry.Title
+			}
+		}
+	} else {
+		for _, page := range task.Pages {
+			if page.HasText && page.Elements != nil && len(page.Elements.Title) > 0 {
+				title := page.Elements.Title[0]
+				if title.Translation != "" {
+					starts[page.PageNumber] = title.Translation
+				} else if title.SourceText != "" {
+					starts[page.PageNumber] = title.SourceText
+				}
+			}
+		}
+	}
+
+	var chapters []*epubChapter
+	for _, page := range task.Pages {
+		title, start := starts[page.PageNumber]
+		if len(starts) == 0 {
+			title, start = epubPageTitle(task, page), true
+		}
+		switch {
+		case len(chapters) == 0:
+			chapters = append(chapters, &epubChapter{title: title})
+		case start && chapters[len(chapters)-1].title == "":
+			chapters[len(chapters)-1].title = title
+		case start:
+			chapters = append(chapters, &epubChapter{title: title})
+		}
+		chapter := chapters[len(chapters)-1]
+		chapter.pages = append(chapter.pages, page)
+	}
+	for _, chapter := range chapters {
+		if chapter.title == "" {
+			chapter.title = documentTitle(task)
+		}
+	}
+	return chapters
+}
+
+func epubPageTitle(task *model.Task, page *model.PageResult) string {
+	if header, ok := pageHeader(task, page); ok {
+		return header
+	}
+	return strconv.Itoa(page.PageNumber)
+}
+
+// writeEpub renders task in memory and then replaces path, so a failed
+// export leaves the previous book in place.
+func (s *TaskService) writeEpub(ctx context.Context, path string, task *model.Task) error {
+	translated := false
+	for _, page := range task.Pages {
+		if page.HasText && pageExportText(task, page) != "" {
+			translated = true
+			break
+		}
+	}
+	if !translated {
+		return fmt.Errorf("没有可用的翻译文本")
+	}
+	modified := time.Now().UTC()
+	if s.deterministic {
+		modified = DeterministicEpoch
+	}
+	var buf bytes.Buffer
+	w := &epubWriter{zip: zip.NewWriter(&buf), modified: modified}
+	err := s.writeEpubParts(ctx, w, task)
+	if closeErr := w.zip.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = s.writeTaskFileAtomic(task.ID, path, buf.Bytes())
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return err
+		}
+		return fmt.Errorf("生成EPUB失败: %w", err)
+	}
+	return nil
+}
+
+func (s *TaskService) writeEpubParts(ctx context.Context, w *epubWriter, task *model.Task) error {
+	// The mimetype comes first and uncompressed so readers can sniff it.
+	out, err := w.zip.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store, Modified: w.modified})
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(out, "application/epub+zip"); err != nil {
+		return err
+	}
+	if err := w.part("META-INF/container.xml", epubContainer); err != nil {
+		return err
+	}
+
+	lang := exportLanguage(task)
+	css := epubStyles
+	if font := s.epubFont(lang); font != nil {
+		name, mediaType := "fonts/export.ttf", "font/ttf"
+		if bytes.HasPrefix(font, []byte("OTTO")) {
+			name, mediaType = "fonts/export.otf", "font/otf"
+		}
+		if err := w.resource(name, mediaType, font); err != nil {
+			return err
+		}
+		css = `@font-face { font-family: "export"; src: url("` + name + `"); }` + "\n" + css
+	}
+	if err := w.text("style.css", "text/css", css); err != nil {
+		return err
+	}
+
+	strs := exportStrings(task)
+	chapters := epubChapters(task)
+	for i, chapter := range chapters {
+		var body strings.Builder
+		body.WriteString("<h1>" + xmlText(chapter.title) + "</h1>\n")
+		if notice, ok := partialNotice(task); ok && i == 0 {
+			body.WriteString("<blockquote><p>" + xmlText(notice) + "</p></blockquote>\n")
+		}
+		// The chapter heading is not repeated when the first page opens
+		// with it.
+		skip := chapter.title
+		for _, page := range chapter.pages {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if header, ok := pageHeader(task, page); ok && header != skip {
+				body.WriteString(`<h2 class="page">` + xmlText(header) + "</h2>\n")
+			}
+			text := pageExportText(task, page)
+			if page.HasText && text != "" {
+				epubParagraphs(&body, pageTextWithNotes(page, text, strs.Notes), skip)
+				skip = ""
+				for _, figure := range page.Figures {
+					img := s.preparePDFImage(figure.Path)
+					kind, ok := epubImageTypes[img.imageType]
+					if img.err != nil || !ok {
+						continue
+					}
+					name := "images/" + replaceExt(filepath.Base(figure.Path), "."+kind[0])
+					if err := w.resource(name, kind[1], img.data); err != nil {
+						return err
+					}
+					// Figures keep the width they had on the page, up to the
+					// width of the screen.
+					fmt.Fprintf(&body, `<figure><img src="%s" alt="" style="width: %.0fpt"/></figure>`+"\n", name, figure.W)
+				}
+				continue
+			}
+			img := s.preparePDFImage(page.ImagePath)
+			kind, ok := epubImageTypes[img.imageType]
+			if img.err != nil || !ok {
+				body.WriteString("<p>" + xmlText(strs.ImageUnavailable) + "</p>\n")
+				continue
+			}
+			name := fmt.Sprintf("images/page-%03d.%s", page.PageNumber, kind[0])
+			if err := w.resource(name, kind[1], img.data); err != nil {
+				return err
+			}
+			body.WriteString(`<figure><img src="` + name + `" alt="` + xmlText(epubPageTitle(task, page)) + `"/></figure>` + "\n")
+		}
+		name := fmt.Sprintf("chapter-%03d.xhtml", i+1)
+		if err := w.text(name, "application/xhtml+xml", epubDocument(lang, chapter.title, body.String())); err != nil {
+			return err
+		}
+		w.spine = append(w.spine, name)
+	}
+
+	var nav strings.Builder
+	nav.WriteString(`<nav epub:type="toc" id="toc"><h1>` + xmlText(documentTitle(task)) + "</h1>\n<ol>\n")
+	for i, chapter := range chapters {
+		fmt.Fprintf(&nav, `<li><a href="%s">%s</a></li>`+"\n", w.spine[i], xmlText(chapter.title))
+	}
+	nav.WriteString("</ol></nav>\n")
+	if err := w.part("OEBPS/nav.xhtml", epubDocument(lang, documentTitle(task), nav.String())); err != nil {
+		return err
+	}
+	return w.part("OEBPS/content.opf", epubPackage(task, lang, w))
+}
+
+// epubFont returns the export font for lang, chosen as for PDF exports: a
+// font mapped to lang in PDFTOOL_FONTS, then PDFTOOL_FONT_PATH, then the
+// embedded Latin or CJK font.
+func (s *TaskService) epubFont(lang string) []byte {
+	for _, path := range []string{s.pdfFonts[lang], s.fontPath} {
+		if path == "" {
+			continue
+		}
+		if data, err := os.ReadFile(path); err == nil {
+			return data
+		}
+	}
+	if lang == FontLangLatin {
+		return assets.DefaultLatinFont()
+	}
+	return assets.DefaultChineseFont()
+}
+
+// epubParagraphs writes text as paragraphs: blank lines separate them and
+// single line breaks are kept. A first paragraph equal to skip is left out.
+func epubParagraphs(b *strings.Builder, text, skip string) {
+	rtl := isRTLText(text)
+	for _, paragraph := range strings.Split(text, "\n\n") {
+		paragraph = strings.Trim(paragraph, "\n")
+		if paragraph == "" {
+			continue
+		}
+		if skip != "" && strings.TrimSpace(paragraph) == skip {
+			skip = ""
+			continue
+		}
+		skip = ""
+		if rtl {
+			b.WriteString(`<p dir="rtl">`)
+		} else {
+			b.WriteString("<p>")
+		}
+		for i, line := range strings.Split(paragraph, "\n") {
+			if i > 0 {
+				b.WriteString("<br/>")
+			}
+			b.WriteString(xmlText(line))
+		}
+		b.WriteString("</p>\n")
+	}
+}
+
+func epubDocument(lang, title, body string) string {
+	code := epubLanguages[lang]
+	return xml.Header + "<!DOCTYPE html>\n" +
+		`<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" lang="` + code + `" xml:lang="` + code + `">` + "\n" +
+		"<head><title>" + xmlText(title) + `</title><link rel="stylesheet" type="text/css" href="style.css"/></head>` + "\n" +
+		"<body>\n" + body + "</body>\n</html>\n"
+}
+
+func epubPackage(task *model.Task, lang string, w *epubWriter) string {
+	var b strings.Builder
+	b.WriteString(xml.Header + `<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="book-id">` + "\n")
+	b.WriteString(`<metadata xmlns:dc="http://purl.org/dc/elements/1.1/">` + "\n")
+	b.WriteString(`<dc:identifier id="book-id">urn:uuid:` + xmlText(task.ID) + "</dc:identifier>\n")
+	b.WriteString("<dc:title>" + xmlText(documentTitle(task)) + "</dc:title>\n")
+	b.WriteString("<dc:language>" + epubLanguages[lang] + "</dc:language>\n")
+	if author := documentAuthor(task); author != "" {
+		b.WriteString("<dc:creator>" + xmlText(author) + "</dc:creator>\n")
+	}
+	if task.Metadata != nil && task.Metadata.Subject != "" {
+		b.WriteString("<dc:subject>" + xmlText(task.Metadata.Subject) + "</dc:subject>\n")
+	}
+	b.WriteString(`<meta property="dcterms:modified">` + w.modified.Format("2006-01-02T15:04:05Z") + "</meta>\n")
+	b.WriteString("</metadata>\n<manifest>\n")
+	b.WriteString(`<item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>` + "\n")
+	b.WriteString(w.manifest.String())
+	b.WriteString("</manifest>\n<spine>\n")
+	for _, name := range w.spine {
+		b.WriteString(`<itemref idref="` + epubItemID(name) + `"/>` + "\n")
+	}
+	b.WriteString("</spine>\n</package>\n")
+	return b.String()
+}
+
+// epubItemID derives a manifest ID from a resource name.
+func epubItemID(name string) string {
+	return strings.NewReplacer("/", "-", ".", "-").Replace(name)
+}
+
+const epubContainer = xml.Header + `<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">` +
+	`<rootfiles><rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/></rootfiles>` +
+	`</container>`
+
+const epubStyles = `body { font-family: "export", serif; line-height: 1.6; }
+h1 { text-align: center; margin: 1em 0 1.5em; }
+h2.page { font-size: 0.8em; font-weight: normal; color: #888; margin: 2em 0 0.5em; }
+blockquote { font-style: italic; border-left: 3px solid #999; margin-left: 0; padding-left: 1em; }
+figure { margin: 1em 0; text-align: center; }
+figure img { max-width: 100%; }
+`
+
+// epubWriter streams the resources of a book into the package and collects
+// their manifest entries and the reading order.
+type epubWriter struct {
+	zip      *zip.Writer
+	modified time.Time
+	manifest strings.Builder
+	spine    []string
+}
+
+func (w *epubWriter) part(name, content string) error {
+	out, err := w.zip.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: w.modified})
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(out, content)
+	return err
+}
+
+// resource stores data under OEBPS/name and lists it in the manifest.
+func (w *epubWriter) resource(name, mediaType string, data []byte) error {
+	out, err := w.zip.CreateHeader(&zip.FileHeader{Name: "OEBPS/" + name, Method: zip.Deflate, Modified: w.modified})
+	if err != nil {
+		return err
+	}
+	if _, err := out.Write(data); err != nil {
+		return err
+	}
+	fmt.Fprintf(&w.manifest, `<item id="%s" href="%s" media-type="%s"/>`+"\n", epubItemID(name), name, mediaType)
+	return nil
+}
+
+func (w *epubWriter) text(name, mediaType, content string) error {
+	return w.resource(name, mediaType, []byte(content))
+}
@@ -0,0 +1,48 @@
+package service
+
+import (
+	"math"
+	"time"
+
+	"pdftool/internal/model"
+)
+
+// estimateTask derives an ETA from the mean duration of translated pages and the
+// formatter's chunk rate. It returns nil when nothing is left or there is no
+// timing sample yet.
+func (s *TaskService) estimateTask(task *model.Task) *model.TaskETA {
+	if task.Paused || task.DryRun {
+		return nil
+	}
+	var pending int
+	var totalMs, samples int64
+	for _, page := range task.Pages {
+		if page.Status == model.PageStatusPending {
+			pending++
+		}
+		if page.DurationMs > 0 {
+			totalMs += page.DurationMs
+			samples++
+		}
+	}
+	eta := &model.TaskETA{PendingPages: pending}
+	if pending > 0 && samples > 0 {
+		eta.AvgPageMs = totalMs / samples
+		parallel := min(s.maxWorkers, pending)
+		eta.PagesPerMinute = math.Round(float64(parallel)*60000/float64(eta.AvgPageMs)*10) / 10
+		batches := (pending + parallel - 1) / parallel
+		eta.RemainingSeconds = int64(batches) * eta.AvgPageMs / 1000
+	}
+	if task.FormattingInProgress && !task.FormattingStartedAt.IsZero() && task.FormattingCompletedChunks > 0 {
+		perChunk := time.Since(task.FormattingStartedAt) / time.Duration(task.FormattingCompletedChunks)
+		remaining := task.FormattingTotalChunks - task.FormattingCompletedChunks
+		if remaining > 0 {
+			eta.FormattingSeconds = int64((perChunk * time.Duration(remaining)).Seconds())
+			eta.RemainingSeconds += eta.FormattingSeconds
+		}
+	}
+	if eta.RemainingSeconds == 0 && eta.AvgPageMs == 0 {
+		return nil
+	}
+	return eta
+}
@@ -0,0 +1,201 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"pdftool/internal/cryptfile"
+	"pdftool/internal/eventbus"
+)
+
+const (
+	eventFeedFile = "events.jsonl"
+	// maxFeedEvents bounds the events returned by one EventsSince call.
+	maxFeedEvents = 1000
+	// feedTail is how much of the feed is read to find the last sequence
+	// number; longer lines fall back to reading the whole feed.
+	feedTail = 64 * 1024
+)
+
+func (s *TaskService) eventFeedPath(taskID string) string {
+	return filepath.Join(s.taskDir(taskID), eventFeedFile)
+}
+
+// publishEvent numbers event within its task, appends it to the task's event
+// feed and publishes it. Sequence numbers start at 1 and only grow, so
+// clients can catch up with EventsSince after a reconnect. Events of tasks
+// without a directory are published unnumbered.
+func (s *TaskService) publishEvent(event eventbus.Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	s.feedMu.Lock()
+	defer s.feedMu.Unlock()
+	if event.TaskID != "" {
+		if err := s.appendEvent(&event); err != nil {
+			log.Printf("append event %s of task %s failed: %v", event.Kind, event.TaskID, err)
+			event.Seq = 0
+		}
+	}
+	// Publishing under feedMu keeps subscribers in sequence order.
+	s.events.Publish(event)
+}
+
+func (s *TaskService) appendEvent(event *eventbus.Event) error {
+	path := s.eventFeedPath(event.TaskID)
+	if _, err := os.Stat(filepath.Dir(path)); err != nil {
+		return err
+	}
+	key, err := s.keyForPath(path)
+	if err != nil {
+		return err
+	}
+	last, err := lastFeedSeq(key, path)
+	if err != nil {
+		return err
+	}
+	event.Seq = last + 1
+	line, err := encodeFeedLine(key, *event)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(line); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// EventsSince returns up to maxFeedEvents events of the task numbered after
+// since, in order, and whether more follow.
+func (s *TaskService) EventsSince(taskID string, since int64) ([]eventbus.Event, bool, error) {
+	if _, err := s.loadTask(taskID); err != nil {
+		return nil, false, err
+	}
+	path := s.eventFeedPath(taskID)
+	key, err := s.keyForPath(path)
+	if err != nil {
+		return nil, false, err
+	}
+	events := []eventbus.Event{}
+	more := false
+	err = readFeed(key, path, func(event eventbus.Event) bool {
+		if event.Seq <= since {
+			return true
+		}
+		if len(events) == maxFeedEvents {
+			more = true
+			return false
+		}
+		events = append(events, event)
+		return true
+	})
+	return events, more, err
+}
+
+// encodeFeedLine renders one feed line. With encryption at rest each line is
+// sealed on its own and base64-encoded, so the feed can still be appended to.
+func encodeFeedLine(key *cryptfile.Key, event eventbus.Event) ([]byte, error) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+	if key != nil {
+		var sealed bytes.Buffer
+		if err := cryptfile.Encrypt(key, &sealed, bytes.NewReader(data)); err != nil {
+			return nil, err
+		}
+		data = []byte(base64.StdEncoding.EncodeToString(sealed.Bytes()))
+	}
+	return append(data, '\n'), nil
+}
+
+func decodeFeedLine(key *cryptfile.Key, line []byte) (eventbus.Event, error) {
+	var event eventbus.Event
+	if len(line) > 0 && line[0] != '{' {
+		sealed, err := base64.StdEncoding.DecodeString(string(line))
+		if err != nil {
+			return event, err
+		}
+		reader, err := cryptfile.NewReader(key, bytes.NewReader(sealed), int64(len(sealed)))
+		if err != nil {
+			return event, err
+		}
+		if line, err = io.ReadAll(reader); err != nil {
+			return event, err
+		}
+	}
+	err := json.Unmarshal(line, &event)
+	return event, err
+}
+
+// readFeed calls visit for each event of the feed at path until it returns
+// false. A missing feed has no events; unreadable lines are skipped.
+func readFeed(key *cryptfile.Key, path string, visit func(eventbus.Event) bool) error {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16<<20)
+	for scanner.Scan() {
+		event, err := decodeFeedLine(key, scanner.Bytes())
+		if err != nil {
+			continue
+		}
+		if !visit(event) {
+			return nil
+		}
+	}
+	return scanner.Err()
+}
+
+// lastFeedSeq returns the sequence number of the last event in the feed,
+// reading only its tail when the last line fits.
+func lastFeedSeq(key *cryptfile.Key, path string) (int64, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+	offset := max(info.Size()-feedTail, 0)
+	tail := make([]byte, info.Size()-offset)
+	if _, err := file.ReadAt(tail, offset); err != nil && err != io.EOF {
+		return 0, err
+	}
+	tail = bytes.TrimRight(tail, "\n")
+	if start := bytes.LastIndexByte(tail, '\n'); start >= 0 || offset == 0 {
+		if event, err := decodeFeedLine(key, tail[start+1:]); err == nil {
+			return event.Seq, nil
+		}
+	}
+	var last int64
+	err = readFeed(key, path, func(event eventbus.Event) bool {
+		last = max(last, event.Seq)
+		return true
+	})
+	return last, err
+}
@@ -0,0 +1,374 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/gen2brain/go-fitz"
+
+	"pdftool/internal/model"
+	"pdftool/internal/translator"
+)
+
+// Run `go test ./internal/service -run Golden -update` to rewrite the golden
+// files after an intended change to an export, and review the diff.
+//
+// PDF exports are compared through a text dump (metadata, page sizes and
+// page text) rather than byte for byte: gofpdf writes same-width images in
+// map order, so object numbers move between otherwise identical files.
+var updateGolden = flag.Bool("update", false, "rewrite golden export files")
+
+// goldenExport produces one export of a task and returns its path.
+type goldenExport func(s *TaskService, taskID string) (string, error)
+
+func mergeTextExport(s *TaskService, taskID string) (string, error) {
+	task, _, err := s.MergeText(context.Background(), taskID)
+	if err != nil {
+		return "", err
+	}
+	return task.CombinedTxtPath, nil
+}
+
+func mergeMarkdownExport(s *TaskService, taskID string) (string, error) {
+	task, _, err := s.MergeMarkdown(context.Background(), taskID)
+	if err != nil {
+		return "", err
+	}
+	return task.CombinedMarkdownPath, nil
+}
+
+func mergePDFExport(layout string) goldenExport {
+	return func(s *TaskService, taskID string) (string, error) {
+		task, _, err := s.MergePDF(context.Background(), taskID, layout)
+		if err != nil {
+			return "", err
+		}
+		return task.CombinedPDFPath, nil
+	}
+}
+
+func mergeDocxExport(s *TaskService, taskID string) (string, error) {
+	task, _, err := s.MergeDocx(context.Background(), taskID)
+	if err != nil {
+		return "", err
+	}
+	return task.CombinedDocxPath, nil
+}
+
+func mergeEpubExport(s *TaskService, taskID string) (string, error) {
+	task, _, err := s.MergeEpub(context.Background(), taskID)
+	if err != nil {
+		return "", err
+	}
+	return task.CombinedEpubPath, nil
+}
+
+func contactSheetExport(s *TaskService, taskID string) (string, error) {
+	task, _, err := s.ExportContactSheet(context.Background(), taskID, 4)
+	if err != nil {
+		return "", err
+	}
+	return task.ContactSheetPath, nil
+}
+
+// goldenCases lists every export under golden coverage; a new exporter gets
+// a row here and a file under testdata/golden.
+var goldenCases = []struct {
+	name    string
+	golden  string
+	setup   func(task *model.Task)
+	export  goldenExport
+	partial bool
+}{
+	{name: "txt", golden: "combined.txt", export: mergeTextExport},
+	{name: "txt_header_template", golden: "header_template.txt", export: mergeTextExport, setup: func(task *model.Task) {
+		task.ExportSettings = &model.ExportSettings{HeaderTemplate: "— {page} / {total} —", PageOffset: 1}
+	}},
+	{name: "txt_hidden_headers", golden: "hidden_headers.txt", export: mergeTextExport, setup: func(task *model.Task) {
+		task.ExportSettings = &model.ExportSettings{HideHeaders: true}
+	}},
+	{name: "txt_english", golden: "english.txt", export: mergeTextExport, setup: func(task *model.Task) {
+		task.ExportSettings = &model.ExportSettings{Locale: "en"}
+	}},
+	{name: "txt_partial", golden: "partial.txt", export: mergeTextExport, partial: true},
+	{name: "markdown", golden: "combined.md", export: mergeMarkdownExport},
+	{name: "pdf_text", golden: "text.pdf.txt", export: mergePDFExport(PDFLayoutText)},
+	{name: "pdf_facing", golden: "facing.pdf.txt", export: mergePDFExport(PDFLayoutFacing)},
+	{name: "pdf_stacked", golden: "stacked.pdf.txt", export: mergePDFExport(PDFLayoutStacked)},
+	{name: "pdf_appendix", golden: "appendix.pdf.txt", export: mergePDFExport(PDFLayoutAppendix)},
+	{name: "pdf_blocks", golden: "blocks.pdf.txt", export: mergePDFExport(PDFLayoutBlocks), setup: func(task *model.Task) {
+		task.Pages[0].Blocks = []model.TextBlock{
+			{Text: "Chapter One", Translation: "第一章", BBox: [4]float64{100, 80, 900, 160}},
+			{Text: "The clocks were striking thirteen.", Translation: "钟敲了十三下。", BBox: [4]float64{100, 200, 900, 320}},
+		}
+	}},
+	{name: "pdf_partial", golden: "partial.pdf.txt", export: mergePDFExport(PDFLayoutText), partial: true},
+	{name: "docx", golden: "combined.docx.txt", export: mergeDocxExport},
+	{name: "docx_partial", golden: "partial.docx.txt", export: mergeDocxExport, partial: true},
+	{name: "epub", golden: "combined.epub.txt", export: mergeEpubExport},
+	{name: "epub_outline", golden: "outline.epub.txt", export: mergeEpubExport, setup: func(task *model.Task) {
+		task.Outline = []model.OutlineEntry{
+			{Level: 1, Title: "Part One", Page: 2},
+			{Level: 2, Title: "April", Page: 2},
+			{Level: 1, Title: "Part Two", Page: 4},
+		}
+	}},
+	{name: "contact_sheet", golden: "contact_sheet.pdf.txt", export: contactSheetExport},
+}
+
+func TestGoldenExports(t *testing.T) {
+	for _, tc := range goldenCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			s := newDeterministicService(t)
+			task := writeGoldenTask(t, s, tc.partial)
+			if tc.setup != nil {
+				tc.setup(task)
+				if err := s.saveTask(task); err != nil {
+					t.Fatal(err)
+				}
+			}
+			path, err := tc.export(s, task.ID)
+			if err != nil {
+				t.Fatalf("export: %v", err)
+			}
+			got, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			switch strings.ToLower(filepath.Ext(path)) {
+			case ".pdf":
+				got = dumpGoldenPDF(t, got)
+			case ".docx", ".epub":
+				got = dumpGoldenPackage(t, got)
+			}
+
+			goldenPath := filepath.Join("testdata", "golden", tc.golden)
+			if *updateGolden {
+				if err := os.MkdirAll(filepath.Dir(goldenPath), 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(goldenPath, got, 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(goldenPath)
+			if err != nil {
+				t.Fatalf("%v (run with -update to create it)", err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("%s differs from %s (%d vs %d bytes); run with -update if the change is intended", tc.name, goldenPath, len(got), len(want))
+			}
+		})
+	}
+}
+
+func TestDeterministicIDs(t *testing.T) {
+	first, second := newDeterministicService(t), newDeterministicService(t)
+	a, err := first.newTaskDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := second.newTaskDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a != b {
+		t.Errorf("first task IDs differ across storage dirs: %s vs %s", a, b)
+	}
+	next, err := first.newTaskDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next == a {
+		t.Errorf("second task reused ID %s", a)
+	}
+	if first.newPageID(a, 1) != second.newPageID(b, 1) || first.newPageID(a, 1) == first.newPageID(a, 2) {
+		t.Errorf("page IDs are not stable per task and page number")
+	}
+}
+
+func newDeterministicService(t *testing.T) *TaskService {
+	t.Helper()
+	s, err := NewTaskService(t.TempDir(), "", "", translator.ProviderConfig{Type: translator.ProviderTypeMock}, 1, Options{Deterministic: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// createSampleTask creates a task from the sample PDF with the mock provider.
+func createSampleTask(t *testing.T, s *TaskService, settings TranslationSettings) *model.Task {
+	t.Helper()
+	file, err := os.Open(samplePDF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	task, err := s.CreateTask(context.Background(), file, "sample.pdf", translator.ProviderConfig{Type: translator.ProviderTypeMock}, settings)
+	if err != nil {
+		t.Fatalf("create task: %v", err)
+	}
+	return task
+}
+
+// writeGoldenTask stores a four-page task: two translated pages (one with a
+// footnote), a page without text and a last page that is translated, or
+// still pending when partial is set.
+func writeGoldenTask(t *testing.T, s *TaskService, partial bool) *model.Task {
+	t.Helper()
+	taskID, err := s.newTaskDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pagesDir := filepath.Join(s.taskDir(taskID), "pages")
+	if err := os.MkdirAll(pagesDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	created := DeterministicEpoch
+	task := &model.Task{
+		ID:         taskID,
+		FileName:   "golden.pdf",
+		TotalPages: 4,
+		CreatedAt:  created,
+		UpdatedAt:  created,
+		Metadata:   &model.DocumentMetadata{Title: "Golden Sample", Author: "pdftool"},
+		State:      model.TaskStateCompleted,
+	}
+	texts := []struct {
+		source, translation string
+		footnotes           []model.Footnote
+	}{
+		{"Chapter One\n\nThe clocks were striking thirteen.", "第一章\n\n钟敲了十三下。", nil},
+		{"It was a bright cold day.[^1]", "那是四月里一个晴朗寒冷的日子。[^1]", []model.Footnote{{Marker: "1", SourceText: "April 4th, 1984.", Translation: "1984 年 4 月 4 日。"}}},
+		{"", "", nil},
+		{"The end.", "全文完。", nil},
+	}
+	for i, text := range texts {
+		number := i + 1
+		imagePath := filepath.Join(pagesDir, fmt.Sprintf("page-%03d.png", number))
+		writeGoldenImage(t, imagePath, number)
+		page := &model.PageResult{
+			ID:          s.newPageID(taskID, number),
+			PageNumber:  number,
+			ImagePath:   imagePath,
+			ImageWidth:  120,
+			ImageHeight: 160,
+			HasText:     text.source != "",
+			SourceText:  text.source,
+			Translation: text.translation,
+			Footnotes:   text.footnotes,
+			Status:      model.PageStatusCompleted,
+			UpdatedAt:   created,
+		}
+		if partial && number == len(texts) {
+			page.Translation = ""
+			page.Status = model.PageStatusPending
+		}
+		task.Pages = append(task.Pages, page)
+	}
+	if partial {
+		task.State = model.TaskStateTranslating
+	}
+	if err := s.saveTask(task); err != nil {
+		t.Fatal(err)
+	}
+	return task
+}
+
+// writeGoldenImage draws a page-specific pattern so a swapped image shows up
+// as a golden diff.
+func writeGoldenImage(t *testing.T, path string, number int) {
+	t.Helper()
+	img := image.NewGray(image.Rect(0, 0, 120, 160))
+	for y := 0; y < 160; y++ {
+		for x := 0; x < 120; x++ {
+			img.SetGray(x, y, color.Gray{Y: uint8((x*number + y) % 256)})
+		}
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if err := png.Encode(file, img); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// dumpGoldenPDF renders the parts of a PDF that exports control: metadata,
+// page sizes and the extracted text of every page.
+func dumpGoldenPDF(t *testing.T, data []byte) []byte {
+	t.Helper()
+	doc, err := fitz.NewFromMemory(data)
+	if err != nil {
+		t.Fatalf("open exported pdf: %v", err)
+	}
+	defer doc.Close()
+
+	var buf bytes.Buffer
+	meta := doc.Metadata()
+	keys := make([]string, 0, len(meta))
+	for key := range meta {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintln(&buf, strings.TrimSpace(key+": "+strings.TrimRight(meta[key], "\x00")))
+	}
+	for n := 0; n < doc.NumPage(); n++ {
+		bound, err := doc.Bound(n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		text, err := doc.Text(n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fmt.Fprintf(&buf, "\n=== page %d (%dx%d) ===\n%s", n+1, bound.Dx(), bound.Dy(), text)
+	}
+	return buf.Bytes()
+}
+
+// dumpGoldenPackage lists the parts of a DOCX or EPUB package: text parts
+// in full, one paragraph per line, and media and fonts by size.
+func dumpGoldenPackage(t *testing.T, data []byte) []byte {
+	t.Helper()
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("open exported package: %v", err)
+	}
+	var buf bytes.Buffer
+	for _, file := range archive.File {
+		rc, err := file.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		fmt.Fprintf(&buf, "=== %s (%s) ===\n", file.Name, file.Modified.UTC().Format("2006-01-02"))
+		if strings.HasPrefix(file.Name, "word/media/") || strings.HasPrefix(file.Name, "OEBPS/images/") || strings.HasPrefix(file.Name, "OEBPS/fonts/") {
+			fmt.Fprintf(&buf, "%d bytes\n", len(content))
+			continue
+		}
+		buf.WriteString(strings.ReplaceAll(string(content), "<w:p>", "\n<w:p>") + "\n")
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,230 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"pdftool/internal/locale"
+	"pdftool/internal/model"
+)
+
+const maxHeaderTemplateLen = 200
+
+// TXT export variants.
+const (
+	TxtVariantOriginal   = "original"
+	TxtVariantFormatted  = "formatted"
+	TxtVariantConsistent = "consistent"
+)
+
+// defaultExportFormats are generated by ExportPreferred when the task lists none.
+var defaultExportFormats = []string{ExportTxt, ExportPDF}
+
+// validateExportSettings normalizes settings in place.
+func (s *TaskService) validateExportSettings(settings *model.ExportSettings) error {
+	settings.HeaderTemplate = strings.TrimSpace(settings.HeaderTemplate)
+	if utf8.RuneCountInString(settings.HeaderTemplate) > maxHeaderTemplateLen {
+		return fmt.Errorf("页眉模板过长（最多 %d 个字符）", maxHeaderTemplateLen)
+	}
+	if settings.PageOffset < 0 {
+		return fmt.Errorf("页码偏移不能为负数")
+	}
+	if strings.TrimSpace(settings.TxtTemplate) == "" {
+		settings.TxtTemplate = ""
+	} else if _, err := parseTxtTemplate(settings.TxtTemplate); err != nil {
+		return err
+	}
+	code, err := locale.Normalize(settings.Locale)
+	if err != nil {
+		return err
+	}
+	settings.Locale = code
+	settings.Variant = strings.ToLower(strings.TrimSpace(settings.Variant))
+	switch settings.Variant {
+	case "", TxtVariantOriginal, TxtVariantFormatted, TxtVariantConsistent:
+	default:
+		return fmt.Errorf("不支持的 TXT 导出版本: %s", settings.Variant)
+	}
+	if strings.TrimSpace(settings.PDFLayout) == "" {
+		settings.PDFLayout = ""
+	} else {
+		layout, err := validatePDFLayout(settings.PDFLayout)
+		if err != nil {
+			return err
+		}
+		settings.PDFLayout = layout
+	}
+	var formats []string
+	seen := make(map[string]bool)
+	for _, format := range settings.Formats {
+		format = strings.ToLower(strings.TrimSpace(format))
+		if format == "" || seen[format] {
+			continue
+		}
+		switch format {
+		case ExportTxt, ExportPDF, ExportMarkdown, ExportDocx, ExportEPUB:
+		default:
+			if _, ok := pandocFormats[format]; !ok {
+				return fmt.Errorf("不支持的导出格式: %s", format)
+			}
+			if s.pandocPath == "" {
+				return fmt.Errorf("未配置 pandoc，无法导出 %s 格式", format)
+			}
+		}
+		seen[format] = true
+		formats = append(formats, format)
+	}
+	settings.Formats = formats
+	return nil
+}
+
+func exportSettingsEmpty(settings model.ExportSettings) bool {
+	return len(settings.Formats) == 0 &&
+		settings.HeaderTemplate == "" && settings.PageOffset == 0 && !settings.HideHeaders &&
+		settings.TxtTemplate == "" && settings.Variant == "" && settings.PDFLayout == "" &&
+		!settings.AutoExport && !settings.AutoFormat && !settings.AutoConsistency && settings.Locale == "" && !settings.Rewrap
+}
+
+// exportStrings returns the fixed export texts in the task's export language.
+func exportStrings(task *model.Task) locale.Strings {
+	if settings := task.ExportSettings; settings != nil {
+		return locale.For(settings.Locale)
+	}
+	return locale.For("")
+}
+
+// SetExportSettings replaces the task's export presentation settings.
+func (s *TaskService) SetExportSettings(taskID string, settings model.ExportSettings) (*model.Task, error) {
+	if err := s.validateExportSettings(&settings); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	task, err := s.loadTask(taskID)
+	if err != nil {
+		return nil, err
+	}
+	if exportSettingsEmpty(settings) {
+		task.ExportSettings = nil
+	} else {
+		task.ExportSettings = &settings
+	}
+	s.refreshCombinedText(task)
+	if err := s.saveTaskLocked(task); err != nil {
+		return nil, err
+	}
+	return task, nil
+}
+
+// pageHeader renders the export header of a page. Placeholders: {page} is the
+// book page number after the offset (front matter before it uses roman
+// numerals), {pdf_page} the PDF page index and {total} the PDF page count.
+// Parts of a split document number their pages as in the whole document.
+func pageHeader(task *model.Task, page *model.PageResult) (string, bool) {
+	template := exportStrings(task).PageHeader
+	offset := 0
+	if settings := task.ExportSettings; settings != nil {
+		if settings.HideHeaders {
+			return "", false
+		}
+		if settings.HeaderTemplate != "" {
+			template = settings.HeaderTemplate
+		}
+		offset = settings.PageOffset
+	}
+	number, total := page.PageNumber, task.TotalPages
+	if task.Part != nil {
+		number, total = sourcePageIndex(task, page.PageNumber)+1, task.Part.SourcePages
+	}
+	logical := strconv.Itoa(number - offset)
+	if number <= offset {
+		logical = romanNumeral(number)
+	}
+	header := strings.NewReplacer(
+		"{page}", logical,
+		"{pdf_page}", strconv.Itoa(number),
+		"{total}", strconv.Itoa(total),
+	).Replace(template)
+	return header, true
+}
+
+// PreferredTxtVariant returns the task's default TXT export variant.
+func PreferredTxtVariant(task *model.Task) string {
+	if settings := task.ExportSettings; settings != nil && settings.Variant != "" {
+		return settings.Variant
+	}
+	return TxtVariantOriginal
+}
+
+// preferredPDFLayout falls back to the task's default when layout is empty.
+func preferredPDFLayout(task *model.Task, layout string) string {
+	if strings.TrimSpace(layout) == "" && task.ExportSettings != nil {
+		return task.ExportSettings.PDFLayout
+	}
+	return layout
+}
+
+func preferredExportFormats(task *model.Task) []string {
+	if settings := task.ExportSettings; settings != nil && len(settings.Formats) > 0 {
+		return settings.Formats
+	}
+	return defaultExportFormats
+}
+
+// ExportPreferred generates every export listed in the task's preferences
+// with the preferred variant and layout, returning the URL of each. A
+// formatted or consistent TXT variant falls back to the original until AI
+// formatting or the consistency pass has run.
+func (s *TaskService) ExportPreferred(ctx context.Context, taskID string) (*model.Task, map[string]string, error) {
+	task, err := s.loadTask(taskID)
+	if err != nil {
+		return nil, nil, err
+	}
+	urls := make(map[string]string)
+	for _, format := range preferredExportFormats(task) {
+		var url string
+		switch format {
+		case ExportTxt:
+			if PreferredTxtVariant(task) == TxtVariantFormatted && task.FormattedByAI && task.FormattedTxtURL != "" {
+				url = task.FormattedTxtURL
+				break
+			}
+			if PreferredTxtVariant(task) == TxtVariantConsistent && task.ConsistentTxtURL != "" {
+				url = task.ConsistentTxtURL
+				break
+			}
+			task, url, err = s.MergeText(ctx, taskID)
+		case ExportPDF:
+			task, url, err = s.MergePDF(ctx, taskID, "")
+		case ExportMarkdown:
+			task, url, err = s.MergeMarkdown(ctx, taskID)
+		case ExportDocx:
+			task, url, err = s.MergeDocx(ctx, taskID)
+		case ExportEPUB:
+			task, url, err = s.MergeEpub(ctx, taskID)
+		default:
+			task, url, err = s.ExportPandoc(ctx, taskID, format)
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("生成 %s 导出失败: %w", format, err)
+		}
+		urls[format] = url
+	}
+	return task, urls, nil
+}
+
+func romanNumeral(n int) string {
+	values := []int{1000, 900, 500, 400, 100, 90, 50, 40, 10, 9, 5, 4, 1}
+	symbols := []string{"m", "cm", "d", "cd", "c", "xc", "l", "xl", "x", "ix", "v", "iv", "i"}
+	var b strings.Builder
+	for i, v := range values {
+		for n >= v {
+			b.WriteString(symbols[i])
+			n -= v
+		}
+	}
+	return b.String()
+}
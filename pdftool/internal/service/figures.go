@@ -0,0 +1,67 @@
+package service
+
+import (
+	"context"
+	"log"
+	"path/filepath"
+
+	"pdftool/internal/model"
+)
+
+const figuresDirName = "figures"
+
+// extractFigures stores the images embedded in the task's pages next to the
+// page images, for exports to include at their native resolution. It reads
+// the source PDF and must run before it is sealed; a failure only loses the
+// figures.
+func (s *TaskService) extractFigures(ctx context.Context, task *model.Task) {
+	start, end := 0, 0
+	if task.Part != nil {
+		start, end = task.Part.FirstPage-1, task.Part.LastPage
+	}
+	dir := filepath.Join(s.taskDir(task.ID), figuresDirName)
+	figures, err := s.renderer.ExtractFigures(ctx, task.OriginalPath, dir, start, end)
+	if err != nil {
+		log.Printf("extract figures of task %s failed: %v", task.ID, err)
+		return
+	}
+	for _, figure := range figures {
+		number := figure.Page - start
+		if number < 1 || number > len(task.Pages) {
+			continue
+		}
+		page := task.Pages[number-1]
+		page.Figures = append(page.Figures, model.Figure{
+			Path:   figure.Path,
+			URL:    s.buildFileURL(task.ID, figuresDirName, filepath.Base(figure.Path)),
+			Width:  figure.Width,
+			Height: figure.Height,
+			X:      figure.X,
+			Y:      figure.Y,
+			W:      figure.W,
+			H:      figure.H,
+		})
+	}
+	if len(figures) > 0 {
+		log.Printf("task %s: extracted %d figures", task.ID, len(figures))
+	}
+}
+
+func figureResponses(figures []model.Figure) []model.FigureResponse {
+	if len(figures) == 0 {
+		return nil
+	}
+	out := make([]model.FigureResponse, 0, len(figures))
+	for _, figure := range figures {
+		out = append(out, model.FigureResponse{
+			URL:    figure.URL,
+			Width:  figure.Width,
+			Height: figure.Height,
+			X:      figure.X,
+			Y:      figure.Y,
+			W:      figure.W,
+			H:      figure.H,
+		})
+	}
+	return out
+}
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"archive/zip"
+	"context"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jung-kurt/gofpdf"
+
+	"pdftool/internal/model"
+	"pdftool/internal/translator"
+)
+
+// TestExtractFigures checks that an image placed on a page is kept at its
+// native size and included in the DOCX and EPUB exports.
+func TestExtractFigures(t *testing.T) {
+	dir := t.TempDir()
+	img := image.NewRGBA(image.Rect(0, 0, 400, 300))
+	for y := 0; y < 300; y++ {
+		for x := 0; x < 400; x++ {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), 100, 255})
+		}
+	}
+	imagePath := filepath.Join(dir, "figure.png")
+	file, err := os.Create(imagePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := png.Encode(file, img); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	pdfPath := filepath.Join(dir, "figure.pdf")
+	pdf := gofpdf.New("P", "pt", "A4", "")
+	pdf.AddPage()
+	pdf.SetFont("Helvetica", "", 12)
+	pdf.Text(72, 72, "Figure 1 shows the gradient.")
+	pdf.Image(imagePath, 72, 100, 200, 150, false, "", 0, "")
+	if err := pdf.OutputFileAndClose(pdfPath); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	provider := translator.ProviderConfig{Type: translator.ProviderTypeMock}
+	s := newDeterministicService(t)
+	source, err := os.Open(pdfPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer source.Close()
+	created, err := s.createTask(ctx, source, "figure.pdf", provider, TranslationSettings{ExtractFigures: true}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	task := waitForState(t, s, created.ID, model.TaskStateCompleted)
+	figures := task.Pages[0].Figures
+	if len(figures) != 1 {
+		t.Fatalf("extracted %d figures, want 1", len(figures))
+	}
+	if f := figures[0]; f.Width != 400 || f.Height != 300 || f.W < 199 || f.W > 201 || f.X < 71 || f.X > 73 {
+		t.Errorf("figure = %+v, want 400x300 pixels placed 200pt wide at x=72", f)
+	}
+
+	task, _, err = s.MergeDocx(ctx, task.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkPackagePart(t, task.CombinedDocxPath, "word/media/image1.png")
+	task, _, err = s.MergeEpub(ctx, task.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkPackagePart(t, task.CombinedEpubPath, "OEBPS/images/page-001-fig-1.png")
+}
+
+func checkPackagePart(t *testing.T, path, name string) {
+	t.Helper()
+	archive, err := zip.OpenReader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer archive.Close()
+	var names []string
+	for _, file := range archive.File {
+		if file.Name == name {
+			return
+		}
+		names = append(names, file.Name)
+	}
+	t.Errorf("%s lacks %s: %s", filepath.Base(path), name, strings.Join(names, ", "))
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"log"
+	"os"
+	"unicode"
+
+	"github.com/jung-kurt/gofpdf"
+
+	"pdftool/internal/assets"
+	"pdftool/internal/model"
+)
+
+// Export languages that can be mapped to fonts with PDFTOOL_FONTS.
+const (
+	FontLangChinese  = "zh"
+	FontLangJapanese = "ja"
+	FontLangKorean   = "ko"
+	FontLangArabic   = "ar"
+	FontLangLatin    = "latin"
+)
+
+// exportLanguage picks the font language for the translated text of tasks.
+// Any Hangul or kana selects Korean or Japanese; otherwise mostly Arabic or
+// Hebrew letters select Arabic, and any Han characters (including the
+// default page headers) select Chinese.
+func exportLanguage(tasks ...*model.Task) string {
+	var hangul, kana, han, arabic, letters int
+	count := func(text string) {
+		for _, r := range text {
+			if !unicode.IsLetter(r) {
+				continue
+			}
+			letters++
+			switch {
+			case unicode.Is(unicode.Hangul, r):
+				hangul++
+			case unicode.In(r, unicode.Hiragana, unicode.Katakana):
+				kana++
+			case unicode.Is(unicode.Han, r):
+				han++
+			case unicode.In(r, unicode.Arabic, unicode.Hebrew, unicode.Syriac, unicode.Thaana):
+				arabic++
+			}
+		}
+	}
+	for _, task := range tasks {
+		count(exportStrings(task).PageHeader)
+		for _, page := range task.Pages {
+			if page.HasText {
+				count(page.Translation)
+			}
+		}
+	}
+	switch {
+	case hangul > 0:
+		return FontLangKorean
+	case kana > 0:
+		return FontLangJapanese
+	case arabic*2 > letters:
+		return FontLangArabic
+	case han > 0:
+		return FontLangChinese
+	}
+	return FontLangLatin
+}
+
+// prepareFont registers the export font for lang and returns its family, or
+// "" for the Helvetica fallback. A font mapped to lang in PDFTOOL_FONTS wins,
+// then PDFTOOL_FONT_PATH, then the embedded Latin font for Latin text and
+// the embedded CJK font for everything else.
+func (s *TaskService) prepareFont(pdf *gofpdf.Fpdf, lang string) string {
+	if path := s.pdfFonts[lang]; path != "" {
+		if addFontFile(pdf, "font_"+lang, path) {
+			return "font_" + lang
+		}
+	}
+	if s.fontPath != "" && addFontFile(pdf, "custom_cn", s.fontPath) {
+		return "custom_cn"
+	}
+	switch lang {
+	case FontLangLatin:
+		if addFontBytes(pdf, "embedded_latin", assets.DefaultLatinFont()) {
+			return "embedded_latin"
+		}
+	case FontLangKorean, FontLangArabic:
+		log.Printf("没有可用的 %s 字体（可通过 PDFTOOL_FONTS 配置），部分文字可能无法显示", lang)
+	}
+	if addFontBytes(pdf, "embedded_cn", assets.DefaultChineseFont()) {
+		return "embedded_cn"
+	}
+	return ""
+}
+
+// addFontFile reads the font itself: gofpdf resolves file names relative to
+// its font directory, which breaks absolute paths.
+func addFontFile(pdf *gofpdf.Fpdf, family, path string) bool {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		pdf.AddUTF8FontFromBytes(family, "", data)
+		err = pdf.Error()
+		pdf.ClearError()
+	}
+	if err != nil {
+		log.Printf("加载 PDF 字体 %s 失败，将退回默认字体: %v", path, err)
+		return false
+	}
+	return true
+}
+
+func addFontBytes(pdf *gofpdf.Fpdf, family string, data []byte) bool {
+	if len(data) == 0 {
+		return false
+	}
+	pdf.AddUTF8FontFromBytes(family, "", data)
+	if err := pdf.Error(); err != nil {
+		log.Printf("加载内置字体失败，将退回默认字体: %v", err)
+		pdf.ClearError()
+		return false
+	}
+	return true
+}
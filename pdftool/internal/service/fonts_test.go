@@ -0,0 +1,35 @@
+package service
+
+import (
+	"testing"
+
+	"pdftool/internal/model"
+)
+
+func TestExportLanguage(t *testing.T) {
+	english := &model.ExportSettings{Locale: "en"}
+	cases := []struct {
+		name     string
+		text     string
+		settings *model.ExportSettings
+		want     string
+	}{
+		{name: "chinese", text: "那是四月里一个晴朗寒冷的日子。", want: FontLangChinese},
+		{name: "english with chinese headers", text: "It was a bright cold day.", want: FontLangChinese},
+		{name: "english", text: "It was a bright cold day.", settings: english, want: FontLangLatin},
+		{name: "japanese", text: "四月の晴れた寒い日だった。", settings: english, want: FontLangJapanese},
+		{name: "korean", text: "사월의 맑고 추운 날이었다.", want: FontLangKorean},
+		{name: "arabic", text: "كان يوما باردا مشرقا من أيام أبريل", want: FontLangArabic},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			task := &model.Task{
+				ExportSettings: tc.settings,
+				Pages:          []*model.PageResult{{PageNumber: 1, HasText: true, Translation: tc.text}},
+			}
+			if got := exportLanguage(task); got != tc.want {
+				t.Errorf("exportLanguage = %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package service
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/jung-kurt/gofpdf"
+
+	"pdftool/internal/model"
+	"pdftool/internal/translator"
+)
+
+// footnoteRefPattern matches the [^marker] references the model leaves in body text.
+var footnoteRefPattern = regexp.MustCompile(`\[\^([^\]\s]+)\]`)
+
+func convertFootnotes(notes []translator.Footnote) []model.Footnote {
+	if len(notes) == 0 {
+		return nil
+	}
+	out := make([]model.Footnote, 0, len(notes))
+	for _, note := range notes {
+		out = append(out, model.Footnote{
+			Marker:      note.Marker,
+			SourceText:  normalizeText(note.SourceText),
+			Translation: normalizeText(note.TranslatedText),
+		})
+	}
+	return out
+}
+
+// plainFootnoteRefs turns [^1] into [1] for plain-text and PDF output.
+func plainFootnoteRefs(text string) string {
+	return footnoteRefPattern.ReplaceAllString(text, "[$1]")
+}
+
+func footnoteText(note model.Footnote) string {
+	if note.Translation != "" {
+		return note.Translation
+	}
+	return note.SourceText
+}
+
+// pageTextWithNotes renders the page translation followed by its notes block,
+// introduced by notesLabel.
+func pageTextWithNotes(page *model.PageResult, text, notesLabel string) string {
+	text = plainFootnoteRefs(text)
+	if len(page.Footnotes) == 0 {
+		return text
+	}
+	var b strings.Builder
+	b.WriteString(text)
+	b.WriteString("\n\n" + notesLabel)
+	for _, note := range page.Footnotes {
+		fmt.Fprintf(&b, "\n[%s] %s", note.Marker, footnoteText(note))
+	}
+	return b.String()
+}
+
+// markdownWithNotes rewrites references to page-scoped pandoc footnotes so
+// markers repeated on different pages do not collide.
+func markdownWithNotes(page *model.PageResult, text string) string {
+	if len(page.Footnotes) == 0 {
+		return plainFootnoteRefs(text)
+	}
+	known := make(map[string]bool, len(page.Footnotes))
+	for _, note := range page.Footnotes {
+		known[note.Marker] = true
+	}
+	text = footnoteRefPattern.ReplaceAllStringFunc(text, func(ref string) string {
+		marker := footnoteRefPattern.FindStringSubmatch(ref)[1]
+		if !known[marker] {
+			return "[" + marker + "]"
+		}
+		return fmt.Sprintf("[^p%d-%s]", page.PageNumber, marker)
+	})
+	var b strings.Builder
+	b.WriteString(text)
+	for _, note := range page.Footnotes {
+		id := fmt.Sprintf("[^p%d-%s]", page.PageNumber, note.Marker)
+		if !strings.Contains(text, id) {
+			// The model dropped the reference; anchor the note at the end of the page.
+			b.WriteString(id)
+		}
+	}
+	b.WriteString("\n")
+	for _, note := range page.Footnotes {
+		fmt.Fprintf(&b, "\n[^p%d-%s]: %s", page.PageNumber, note.Marker, strings.ReplaceAll(footnoteText(note), "\n", " "))
+	}
+	return b.String()
+}
+
+// writePDFFootnotes draws the page's notes below a short rule in a smaller font.
+func (s *TaskService) writePDFFootnotes(pdf *gofpdf.Fpdf, fontFamily string, page *model.PageResult, align string) {
+	if len(page.Footnotes) == 0 {
+		return
+	}
+	pdf.Ln(3)
+	x, y := pdf.GetX(), pdf.GetY()
+	pdf.Line(x, y, x+50, y)
+	pdf.Ln(2)
+	s.setFont(pdf, fontFamily, 9)
+	for _, note := range page.Footnotes {
+		line := fmt.Sprintf("[%s] %s", note.Marker, footnoteText(note))
+		pdf.MultiCell(0, 5, s.encodeText(pdf, fontFamily, line), "", align, false)
+	}
+}
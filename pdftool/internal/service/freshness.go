@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"pdftool/internal/model"
+)
+
+// ErrExportStale is returned for stale exports that cannot be rebuilt without
+// another provider call (the AI-formatted TXT).
+var ErrExportStale = errors.New("导出文件已过期")
+
+// ExportFile returns the local path of a generated export. Exports older than
+// the latest page change are regenerated first, so a download never serves a
+// translation that was since retranslated or edited.
+func (s *TaskService) ExportFile(ctx context.Context, taskID, name string) (string, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	task, err := s.loadTask(taskID)
+	if err != nil {
+		return "", err
+	}
+	path, err := s.exportPath(task, name)
+	if err != nil {
+		return "", err
+	}
+	if !exportStale(task, name, path) {
+		return path, nil
+	}
+	switch name {
+	case ExportTxt:
+		_, _, err = s.MergeText(ctx, taskID)
+	case ExportPDF:
+		layout := ""
+		if progress := task.Exports[ExportPDF]; progress != nil {
+			layout = progress.Layout
+		}
+		_, _, err = s.MergePDF(ctx, taskID, layout)
+	case ExportMarkdown:
+		_, _, err = s.MergeMarkdown(ctx, taskID)
+	case ExportDocx:
+		_, _, err = s.MergeDocx(ctx, taskID)
+	case ExportEPUB:
+		_, _, err = s.MergeEpub(ctx, taskID)
+	case ExportFormatted:
+		return "", fmt.Errorf("%w: 页面译文已更新，请重新执行 AI 排版", ErrExportStale)
+	case ExportConsistent:
+		return "", fmt.Errorf("%w: 页面译文已更新，请重新执行一致性校对", ErrExportStale)
+	default:
+		_, _, err = s.ExportPandoc(ctx, taskID, name)
+	}
+	if err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+func (s *TaskService) exportPath(task *model.Task, name string) (string, error) {
+	dir := s.taskDir(task.ID)
+	switch name {
+	case ExportTxt:
+		return filepath.Join(dir, "combined.txt"), nil
+	case ExportPDF:
+		return filepath.Join(dir, "combined.pdf"), nil
+	case ExportMarkdown:
+		return filepath.Join(dir, "combined.md"), nil
+	case ExportDocx:
+		return filepath.Join(dir, "combined.docx"), nil
+	case ExportEPUB:
+		return filepath.Join(dir, "combined.epub"), nil
+	case ExportFormatted:
+		if task.FormattedTxtPath == "" {
+			return "", fmt.Errorf("尚未生成 AI 排版版本")
+		}
+		return task.FormattedTxtPath, nil
+	case ExportConsistent:
+		if task.ConsistentTxtPath == "" {
+			return "", fmt.Errorf("尚未生成一致性校对版本")
+		}
+		return task.ConsistentTxtPath, nil
+	}
+	if ext, ok := pandocFormats[name]; ok {
+		return filepath.Join(dir, "combined."+ext), nil
+	}
+	return "", fmt.Errorf("不支持的导出格式: %s", name)
+}
+
+// exportStale reports whether the export is missing, flagged stale, or older
+// than the most recent page update.
+func exportStale(task *model.Task, name, path string) bool {
+	info, err := os.Stat(path)
+	if err != nil {
+		return true
+	}
+	for _, stale := range task.StaleExports {
+		if stale == name {
+			return true
+		}
+	}
+	return info.ModTime().Before(latestPageUpdate(task))
+}
+
+func latestPageUpdate(task *model.Task) time.Time {
+	var latest time.Time
+	for _, page := range task.Pages {
+		if page.UpdatedAt.After(latest) {
+			latest = page.UpdatedAt
+		}
+	}
+	return latest
+}
@@ -0,0 +1,24 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"pdftool/internal/hooks"
+	"pdftool/internal/model"
+	"pdftool/internal/translator"
+)
+
+// postTranslate runs the post-translate hooks over a successful result.
+func (s *TaskService) postTranslate(ctx context.Context, task *model.Task, page *model.PageResult, result translator.Result, err error) (translator.Result, error) {
+	if err != nil || !result.HasText {
+		return result, err
+	}
+	text := hooks.Text{SourceText: result.SourceText, Translation: result.TranslatedText}
+	if err := s.hooks.PostTranslate(ctx, task.ID, page.PageNumber, &text); err != nil {
+		return translator.Result{}, fmt.Errorf("译文后处理钩子失败: %w", err)
+	}
+	result.SourceText = text.SourceText
+	result.TranslatedText = text.Translation
+	return result, nil
+}
@@ -0,0 +1,123 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"path/filepath"
+	"time"
+)
+
+const (
+	idempotencyFile   = "idempotency.json"
+	idempotencyTTL    = 24 * time.Hour
+	maxIdempotencyKey = 255
+)
+
+var (
+	// ErrIdempotencyInFlight is returned while another request with the same key is running.
+	ErrIdempotencyInFlight = errors.New("相同 Idempotency-Key 的请求正在处理中")
+	// ErrIdempotencyKeyInvalid rejects empty or oversized keys.
+	ErrIdempotencyKeyInvalid = errors.New("Idempotency-Key 无效")
+	// ErrIdempotencyMismatch rejects a key reused for a different request.
+	ErrIdempotencyMismatch = errors.New("Idempotency-Key 已用于其他请求")
+)
+
+// IdempotentResponse is the stored outcome of a request made with an
+// Idempotency-Key, replayed verbatim when the request is retried.
+type IdempotentResponse struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	// BodyHash is the hex SHA-256 of the request body.
+	BodyHash    string    `json:"body_hash"`
+	Status      int       `json:"status"`
+	ContentType string    `json:"content_type"`
+	Body        []byte    `json:"body"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// IdempotentRequest identifies the request a key is used for.
+type IdempotentRequest struct {
+	Method   string
+	Path     string
+	BodyHash string
+}
+
+func (s *TaskService) idempotencyPath() string {
+	return filepath.Join(s.storageDir, idempotencyFile)
+}
+
+// loadIdempotencyLocked reads stored responses; callers hold s.idemMu.
+func (s *TaskService) loadIdempotencyLocked() map[string]*IdempotentResponse {
+	records := make(map[string]*IdempotentResponse)
+	if data, err := s.readStored(s.idempotencyPath()); err == nil {
+		if err := json.Unmarshal(data, &records); err != nil {
+			log.Printf("解析幂等记录失败: %v", err)
+		}
+	}
+	return records
+}
+
+func (s *TaskService) saveIdempotencyLocked(records map[string]*IdempotentResponse) error {
+	data, err := json.Marshal(records)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.vault.storageKey(), s.idempotencyPath(), data)
+}
+
+// BeginIdempotent claims key for one request of the calling client. It
+// returns the stored response when the key was already completed for the
+// same request, and ErrIdempotencyMismatch when it was used for another one;
+// otherwise the caller runs the request and must call release, passing the
+// response to remember (nil to forget the key so the client may retry).
+// Keys are scoped per client, so clients never see each other's responses.
+func (s *TaskService) BeginIdempotent(ctx context.Context, key string, req IdempotentRequest) (*IdempotentResponse, func(*IdempotentResponse), error) {
+	if key == "" || len(key) > maxIdempotencyKey {
+		return nil, nil, ErrIdempotencyKeyInvalid
+	}
+	scoped := clientFrom(ctx) + " " + key
+	// The lookup and the in-flight claim happen under one lock, so a retry
+	// racing the completion of the first request either replays its response
+	// or waits for it, never running a second time.
+	s.idemMu.Lock()
+	record := s.loadIdempotencyLocked()[scoped]
+	if record != nil && time.Since(record.CreatedAt) < idempotencyTTL {
+		s.idemMu.Unlock()
+		if record.Method != req.Method || record.Path != req.Path || record.BodyHash != req.BodyHash {
+			return nil, nil, ErrIdempotencyMismatch
+		}
+		return record, nil, nil
+	}
+	if s.idemInFlight[scoped] {
+		s.idemMu.Unlock()
+		return nil, nil, ErrIdempotencyInFlight
+	}
+	if s.idemInFlight == nil {
+		s.idemInFlight = make(map[string]bool)
+	}
+	s.idemInFlight[scoped] = true
+	s.idemMu.Unlock()
+	release := func(resp *IdempotentResponse) {
+		s.idemMu.Lock()
+		defer s.idemMu.Unlock()
+		defer delete(s.idemInFlight, scoped)
+		if resp == nil {
+			return
+		}
+		resp.Method, resp.Path, resp.BodyHash = req.Method, req.Path, req.BodyHash
+		resp.CreatedAt = time.Now()
+		records := s.loadIdempotencyLocked()
+		for k, r := range records {
+			if time.Since(r.CreatedAt) >= idempotencyTTL {
+				delete(records, k)
+			}
+		}
+		records[scoped] = resp
+		if err := s.saveIdempotencyLocked(records); err != nil {
+			log.Printf("写入幂等记录失败: %v", err)
+		}
+	}
+	return nil, release, nil
+}
@@ -0,0 +1,22 @@
+package service
+
+import "pdftool/internal/translator"
+
+// applyImageTypes sets the image types configured for the provider type,
+// unless the caller already chose some.
+func (s *TaskService) applyImageTypes(cfg *translator.ProviderConfig) {
+	if len(cfg.ImageTypes) > 0 {
+		return
+	}
+	cfg.ImageTypes = s.providerImageTypes[cfg.Type]
+}
+
+func normalizeProviderImageTypes(overrides map[string][]string) map[translator.ProviderType][]string {
+	normalized := make(map[translator.ProviderType][]string, len(overrides))
+	for name, types := range overrides {
+		if types = translator.NormalizeImageTypes(types); len(types) > 0 {
+			normalized[translator.NormalizeProviderType(name)] = types
+		}
+	}
+	return normalized
+}
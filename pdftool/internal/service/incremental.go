@@ -0,0 +1,142 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"sync"
+
+	"pdftool/internal/model"
+)
+
+// Names recorded in Task.StaleExports. Pandoc exports use their format name.
+const (
+	ExportTxt        = "txt"
+	ExportPDF        = "pdf"
+	ExportFormatted  = "formatted"
+	ExportConsistent = "consistent"
+	ExportMarkdown   = "markdown"
+	ExportDocx       = "docx"
+	ExportEPUB       = "epub"
+)
+
+// refreshCombinedText rewrites combined.txt from the task's current pages so
+// the download link always reflects progress, and flags exports generated
+// from an earlier snapshot as stale. Failures are only logged: the page result
+// that triggered the refresh is already stored.
+func (s *TaskService) refreshCombinedText(task *model.Task) {
+	if err := s.writeCombinedText(task); err != nil {
+		log.Printf("refresh combined text of task %s failed: %v", task.ID, err)
+		return
+	}
+	markCombinedTextRefreshed(task)
+}
+
+// markCombinedTextRefreshed records that combined.txt follows the task's
+// pages and flags exports generated from an earlier snapshot as stale.
+func markCombinedTextRefreshed(task *model.Task) {
+	if _, ok := task.Exports[ExportTxt]; ok {
+		recordExportProgress(task, ExportTxt, "")
+	}
+	markExportsStale(task)
+}
+
+// flushCombinedText rewrites combined.txt from the latest stored copy of the
+// task. It runs outside s.mu so rendering a large document does not stall
+// other tasks; writers of the same task are serialised and each renders the
+// newest pages, so an older snapshot never overwrites a newer one.
+func (s *TaskService) flushCombinedText(taskID string) {
+	lock, _ := s.combinedWriters.LoadOrStore(taskID, &sync.Mutex{})
+	mu := lock.(*sync.Mutex)
+	mu.Lock()
+	defer mu.Unlock()
+	task, err := s.loadTask(taskID)
+	if err != nil {
+		return
+	}
+	if err := s.writeCombinedFile(task); err != nil {
+		log.Printf("refresh combined text of task %s failed: %v", taskID, err)
+	}
+}
+
+// writeCombinedText renders combined.txt, prefixed with a notice while pages
+// are still pending, and records its location on the task.
+func (s *TaskService) writeCombinedText(task *model.Task) error {
+	if err := s.writeCombinedFile(task); err != nil {
+		return err
+	}
+	task.CombinedTxtPath = filepath.Join(s.taskDir(task.ID), "combined.txt")
+	task.CombinedTxtURL = s.buildFileURL(task.ID, "combined.txt")
+	return nil
+}
+
+func (s *TaskService) writeCombinedFile(task *model.Task) error {
+	text, err := s.combinedTextWithNotice(task)
+	if err != nil {
+		return err
+	}
+	combinedPath := filepath.Join(s.taskDir(task.ID), "combined.txt")
+	if err := s.writeTaskFileAtomic(task.ID, combinedPath, []byte(text)); err != nil {
+		return fmt.Errorf("写入TXT失败: %w", err)
+	}
+	return nil
+}
+
+// combinedTextWithNotice renders the TXT export, prefixed with the partial
+// export notice when pages are still pending.
+func (s *TaskService) combinedTextWithNotice(task *model.Task) (string, error) {
+	text, err := s.renderCombinedText(task)
+	if err != nil {
+		return "", err
+	}
+	if notice, ok := partialNotice(task); ok {
+		text = fmt.Sprintf(exportStrings(task).Notice, notice) + "\n\n" + text
+	}
+	return text, nil
+}
+
+func markExportsStale(task *model.Task) {
+	if task.CombinedPDFPath != "" {
+		addStaleExport(task, ExportPDF)
+	}
+	if task.FormattedTxtPath != "" {
+		addStaleExport(task, ExportFormatted)
+	}
+	if task.ConsistentTxtPath != "" {
+		addStaleExport(task, ExportConsistent)
+	}
+	if task.CombinedMarkdownPath != "" {
+		addStaleExport(task, ExportMarkdown)
+	}
+	if task.CombinedDocxPath != "" {
+		addStaleExport(task, ExportDocx)
+	}
+	if task.CombinedEpubPath != "" {
+		addStaleExport(task, ExportEPUB)
+	}
+	for format := range task.PandocExports {
+		addStaleExport(task, format)
+	}
+}
+
+func addStaleExport(task *model.Task, name string) {
+	for _, existing := range task.StaleExports {
+		if existing == name {
+			return
+		}
+	}
+	task.StaleExports = append(task.StaleExports, name)
+}
+
+func clearStaleExport(task *model.Task, name string) {
+	kept := task.StaleExports[:0]
+	for _, existing := range task.StaleExports {
+		if existing != name {
+			kept = append(kept, existing)
+		}
+	}
+	if len(kept) == 0 {
+		kept = nil
+	}
+	task.StaleExports = kept
+}
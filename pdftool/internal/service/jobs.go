@@ -0,0 +1,170 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+
+	"pdftool/internal/model"
+	"pdftool/internal/translator"
+)
+
+const (
+	jobsFile           = "jobs.json"
+	jobKindRetranslate = "retranslate_page"
+	jobKindMergePDF    = "merge_pdf"
+	jobRetention       = 24 * time.Hour
+)
+
+// ErrJobNotFound is returned for unknown or expired job IDs.
+var ErrJobNotFound = errors.New("任务作业不存在或已过期")
+
+func (s *TaskService) jobsPath() string {
+	return filepath.Join(s.storageDir, jobsFile)
+}
+
+// loadJobsLocked reads the job records; callers hold s.jobsMu.
+func (s *TaskService) loadJobsLocked() map[string]*model.Job {
+	jobs := make(map[string]*model.Job)
+	if data, err := os.ReadFile(s.jobsPath()); err == nil {
+		if err := json.Unmarshal(data, &jobs); err != nil {
+			log.Printf("解析作业记录失败: %v", err)
+		}
+	}
+	return jobs
+}
+
+func (s *TaskService) saveJobsLocked(jobs map[string]*model.Job) error {
+	data, err := json.MarshalIndent(jobs, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.jobsPath() + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.jobsPath())
+}
+
+// putJob stores job, dropping finished jobs past the retention window.
+func (s *TaskService) putJob(job *model.Job) {
+	s.jobsMu.Lock()
+	defer s.jobsMu.Unlock()
+	jobs := s.loadJobsLocked()
+	for id, existing := range jobs {
+		if !existing.FinishedAt.IsZero() && time.Since(existing.FinishedAt) > jobRetention {
+			delete(jobs, id)
+		}
+	}
+	copied := *job
+	jobs[job.ID] = &copied
+	if err := s.saveJobsLocked(jobs); err != nil {
+		log.Printf("写入作业记录失败: %v", err)
+	}
+}
+
+// GetJob returns a background job by ID.
+func (s *TaskService) GetJob(jobID string) (*model.Job, error) {
+	s.jobsMu.Lock()
+	defer s.jobsMu.Unlock()
+	job, ok := s.loadJobsLocked()[jobID]
+	if !ok {
+		return nil, ErrJobNotFound
+	}
+	return job, nil
+}
+
+// jobProgress updates a running job. Progress is saved at most once a second
+// so long exports don't rewrite the job records for every page.
+type jobProgress struct {
+	s     *TaskService
+	job   *model.Job
+	saved time.Time
+}
+
+func (p *jobProgress) set(completed, total int) {
+	p.job.Completed, p.job.Total = completed, total
+	if completed < total && time.Since(p.saved) < time.Second {
+		return
+	}
+	p.saved = time.Now()
+	p.s.putJob(p.job)
+}
+
+// startJob records a queued job and runs it in the background.
+func (s *TaskService) startJob(kind, taskID string, pageNumber int, run func(context.Context, *jobProgress) error) *model.Job {
+	job := &model.Job{
+		ID:         uuid.NewString(),
+		Kind:       kind,
+		TaskID:     taskID,
+		PageNumber: pageNumber,
+		Status:     model.JobStatusQueued,
+		CreatedAt:  time.Now(),
+	}
+	s.putJob(job)
+	queued := *job
+	go func() {
+		job.Status = model.JobStatusRunning
+		s.putJob(job)
+		if err := run(context.Background(), &jobProgress{s: s, job: job}); err != nil {
+			job.Status = model.JobStatusFailed
+			job.Error = err.Error()
+		} else {
+			job.Status = model.JobStatusCompleted
+		}
+		job.FinishedAt = time.Now()
+		s.putJob(job)
+	}()
+	return &queued
+}
+
+// RetranslatePageAsync validates the request, then retranslates the page in
+// the background; the returned job reports the outcome.
+func (s *TaskService) RetranslatePageAsync(taskID string, pageNumber int, provider translator.ProviderConfig) (*model.Job, error) {
+	task, err := s.loadTask(taskID)
+	if err != nil {
+		return nil, err
+	}
+	if pageNumber <= 0 || pageNumber > len(task.Pages) {
+		return nil, fmt.Errorf("page %d not found", pageNumber)
+	}
+	if err := s.checkBudget(); err != nil {
+		return nil, err
+	}
+	providerCfg, err := s.mergeProviderConfig(provider, task)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := translator.NewTranslator(providerCfg); err != nil {
+		return nil, err
+	}
+	return s.startJob(jobKindRetranslate, taskID, pageNumber, func(ctx context.Context, _ *jobProgress) error {
+		_, _, err := s.RetranslatePage(ctx, taskID, pageNumber, provider)
+		return err
+	}), nil
+}
+
+// MergePDFAsync validates the request, then generates the merged PDF in the
+// background. The job reports the pages embedded so far and, once completed,
+// the download URL.
+func (s *TaskService) MergePDFAsync(taskID, layout string) (*model.Job, error) {
+	task, err := s.loadTask(taskID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := validatePDFLayout(preferredPDFLayout(task, layout)); err != nil {
+		return nil, err
+	}
+	return s.startJob(jobKindMergePDF, taskID, 0, func(ctx context.Context, progress *jobProgress) error {
+		_, url, err := s.mergePDF(ctx, taskID, layout, progress.set)
+		progress.job.URL = url
+		return err
+	}), nil
+}
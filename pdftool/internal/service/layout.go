@@ -0,0 +1,132 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"pdftool/internal/model"
+	"pdftool/internal/pdfutil"
+	"pdftool/internal/translator"
+)
+
+// Layout modes for multi-column pages.
+const (
+	LayoutModeNone = ""
+	LayoutModeCrop = "crop" // translate each region as its own cropped image
+	LayoutModeHint = "hint" // send the whole page with a reading-order hint
+	// LayoutModeBlocks asks for the page's text blocks with their bounding
+	// boxes, for the "blocks" PDF layout.
+	LayoutModeBlocks = "blocks"
+)
+
+func validateLayoutMode(mode string) (string, error) {
+	mode = strings.ToLower(strings.TrimSpace(mode))
+	switch mode {
+	case LayoutModeNone, LayoutModeCrop, LayoutModeHint, LayoutModeBlocks:
+		return mode, nil
+	case "off", "none":
+		return LayoutModeNone, nil
+	case "layout", "bbox":
+		return LayoutModeBlocks, nil
+	}
+	return "", fmt.Errorf("不支持的版面分析模式: %s", mode)
+}
+
+// analyzePageLayout detects reading-ordered regions and records them on the page.
+func analyzePageLayout(page *model.PageResult, rightToLeft bool) []image.Rectangle {
+	rects, err := pdfutil.AnalyzeLayout(page.ImagePath, rightToLeft)
+	if err != nil {
+		log.Printf("analyze layout of page %d failed: %v", page.PageNumber, err)
+		return nil
+	}
+	page.Regions = page.Regions[:0]
+	for _, r := range rects {
+		page.Regions = append(page.Regions, model.Region{X: r.Min.X, Y: r.Min.Y, Width: r.Dx(), Height: r.Dy(), Source: RegionSourceLayout})
+	}
+	return rects
+}
+
+// translateWithLayout runs layout analysis before recognition. Pages with a
+// single region go through the normal single-image request. The blocks mode
+// leaves the segmentation to the model.
+func (s *TaskService) translateWithLayout(ctx context.Context, task *model.Task, page *model.PageResult, translatorClient translator.Translator) (translator.Result, error) {
+	if task.LayoutMode == LayoutModeBlocks {
+		return translatorClient.Translate(translator.WithBlocks(ctx), page.ImagePath)
+	}
+	rects := analyzePageLayout(page, task.WritingMode != WritingModeHorizontal)
+	if len(rects) < 2 {
+		return translatorClient.Translate(ctx, page.ImagePath)
+	}
+	if task.LayoutMode == LayoutModeHint {
+		return translatorClient.Translate(translator.WithPromptHint(ctx, readingOrderHint(page)), page.ImagePath)
+	}
+
+	prefix := filepath.Join(filepath.Dir(page.ImagePath), strings.TrimSuffix(filepath.Base(page.ImagePath), filepath.Ext(page.ImagePath)))
+	paths, err := pdfutil.CropRegions(page.ImagePath, rects, prefix)
+	defer func() {
+		for _, p := range paths {
+			os.Remove(p)
+		}
+	}()
+	if err != nil {
+		return translator.Result{}, err
+	}
+	var sources, translations []string
+	var notes []translator.Footnote
+	var elements *translator.Elements
+	for i, p := range paths {
+		regionCtx := translator.WithPromptHint(ctx, fmt.Sprintf("这是本页第 %d/%d 个版面区域的裁剪图。", i+1, len(paths)))
+		result, err := translatorClient.Translate(regionCtx, p)
+		if err != nil {
+			return translator.Result{}, fmt.Errorf("区域 %d 识别失败: %w", i+1, err)
+		}
+		if !result.HasText {
+			continue
+		}
+		notes = append(notes, result.Footnotes...)
+		elements = appendElements(elements, result.Elements)
+		if text := normalizeText(result.SourceText); text != "" {
+			sources = append(sources, text)
+			page.Regions[i].SourceText = text
+		}
+		if text := normalizeText(result.TranslatedText); text != "" {
+			translations = append(translations, text)
+			page.Regions[i].Translation = text
+		}
+	}
+	return translator.Result{
+		HasText:        len(translations) > 0,
+		SourceText:     strings.Join(sources, "\n\n"),
+		TranslatedText: strings.Join(translations, "\n\n"),
+		Footnotes:      notes,
+		Elements:       elements,
+	}, nil
+}
+
+// readingOrderHint describes detected regions as percentages of the page.
+func readingOrderHint(page *model.PageResult) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "本页为多栏/分区排版，已检测到 %d 个区域，请严格按以下顺序逐个区域识别与翻译，不要跨栏混排：", len(page.Regions))
+	for i, r := range page.Regions {
+		x0, y0 := percent(r.X, page.ImageWidth), percent(r.Y, page.ImageHeight)
+		x1, y1 := percent(r.X+r.Width, page.ImageWidth), percent(r.Y+r.Height, page.ImageHeight)
+		fmt.Fprintf(&b, " 区域%d（横向 %d%%-%d%%，纵向 %d%%-%d%%）", i+1, x0, x1, y0, y1)
+		if i < len(page.Regions)-1 {
+			b.WriteString("；")
+		}
+	}
+	b.WriteString("。")
+	return b.String()
+}
+
+func percent(v, total int) int {
+	if total <= 0 {
+		return 0
+	}
+	return v * 100 / total
+}
@@ -0,0 +1,40 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gen2brain/go-fitz"
+
+	"pdftool/internal/model"
+)
+
+// TestBlocksLayout translates the sample in the blocks layout mode and
+// places the blocks over the page images in the PDF export.
+func TestBlocksLayout(t *testing.T) {
+	ctx := context.Background()
+	s := newDeterministicService(t)
+	created := createSampleTask(t, s, TranslationSettings{LayoutMode: "bbox"})
+	task := waitForState(t, s, created.ID, model.TaskStateCompleted)
+	if task.LayoutMode != LayoutModeBlocks {
+		t.Errorf("layout mode = %q, want %q", task.LayoutMode, LayoutModeBlocks)
+	}
+	for _, page := range task.Pages {
+		if len(page.Blocks) == 0 || page.Blocks[0].Translation == "" {
+			t.Errorf("page %d has no translated blocks: %+v", page.PageNumber, page.Blocks)
+		}
+	}
+
+	task, _, err := s.MergePDF(ctx, task.ID, PDFLayoutBlocks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := fitz.New(task.CombinedPDFPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer doc.Close()
+	if doc.NumPage() != len(task.Pages) {
+		t.Errorf("blocks PDF has %d pages, want %d", doc.NumPage(), len(task.Pages))
+	}
+}
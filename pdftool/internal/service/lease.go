@@ -0,0 +1,228 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	leaseTTL       = 2 * time.Minute
+	leaseHeartbeat = 30 * time.Second
+	leaseDirName   = "locks"
+)
+
+// leaseRecord is persisted inside an advisory lock file so replicas sharing
+// the storage dir can tell who owns a task or page and whether it is stale.
+// Owner is the instance ID; Process identifies the service process and Nonce
+// the claim itself, so two claims within one process still exclude each
+// other and a restarted instance can take over the leases it left behind.
+type leaseRecord struct {
+	Owner     string    `json:"owner"`
+	Process   string    `json:"process,omitempty"`
+	Nonce     string    `json:"nonce,omitempty"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// lease is a claimed advisory lock; Release must be called when done.
+type lease struct {
+	svc    *TaskService
+	path   string
+	record leaseRecord
+	stop   chan struct{}
+}
+
+func (s *TaskService) pageLeasePath(taskID string, pageNumber int) string {
+	return filepath.Join(s.taskDir(taskID), leaseDirName, fmt.Sprintf("page-%03d.lock", pageNumber))
+}
+
+func (s *TaskService) taskLeasePath(taskID, name string) string {
+	return filepath.Join(s.taskDir(taskID), leaseDirName, name+".lock")
+}
+
+// claimLease tries to take the advisory lock at path. It returns nil when a
+// live lease is held by another claim, in this or another instance.
+func (s *TaskService) claimLease(path string) (*lease, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("创建锁目录失败: %w", err)
+	}
+	record := leaseRecord{
+		Owner:     s.instanceID,
+		Process:   s.leaseProcess,
+		Nonce:     uuid.NewString(),
+		ExpiresAt: time.Now().Add(leaseTTL),
+	}
+	created, err := createLease(path, record)
+	if err != nil {
+		return nil, err
+	}
+	if created {
+		return s.startLease(path, record), nil
+	}
+	if !s.leaseStale(path) {
+		return nil, nil
+	}
+	return s.takeOverLease(path, record)
+}
+
+// takeOverLease replaces a stale lease. Takeovers are serialized through an
+// exclusively created guard file and the lease is checked again while
+// holding it, so of several claims finding the same stale lease exactly one
+// replaces it; the others see the new live lease.
+func (s *TaskService) takeOverLease(path string, record leaseRecord) (*lease, error) {
+	guard := path + ".takeover"
+	held, err := createLease(guard, record)
+	if err != nil {
+		return nil, err
+	}
+	if !held {
+		// A guard left behind by a crashed process expires like a lease.
+		if info, err := os.Stat(guard); err == nil && time.Since(info.ModTime()) > leaseTTL {
+			os.Remove(guard)
+		}
+		return nil, nil
+	}
+	defer os.Remove(guard)
+	created, err := createLease(path, record)
+	if err != nil {
+		return nil, err
+	}
+	if !created {
+		if !s.leaseStale(path) {
+			return nil, nil
+		}
+		if err := writeLease(path, record); err != nil {
+			return nil, err
+		}
+	}
+	return s.startLease(path, record), nil
+}
+
+// createLease writes record to path unless a lock file already exists.
+func createLease(path string, record leaseRecord) (bool, error) {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return false, err
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if os.IsExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("创建锁文件失败: %w", err)
+	}
+	_, werr := file.Write(data)
+	if cerr := file.Close(); werr == nil {
+		werr = cerr
+	}
+	if werr != nil {
+		os.Remove(path)
+		return false, fmt.Errorf("写入锁文件失败: %w", werr)
+	}
+	return true, nil
+}
+
+// leaseStale reports whether the lease at path may be taken over. A lock
+// file that cannot be parsed is only stale once it is older than the TTL,
+// since a claim may be writing it right now.
+func (s *TaskService) leaseStale(path string) bool {
+	current, err := readLease(path)
+	if err == nil {
+		return s.leaseExpired(current)
+	}
+	info, statErr := os.Stat(path)
+	if os.IsNotExist(statErr) {
+		return true
+	}
+	return statErr == nil && time.Since(info.ModTime()) > leaseTTL
+}
+
+// leaseExpired reports whether record no longer protects anything: it timed
+// out, or it was left by an earlier process of this instance.
+func (s *TaskService) leaseExpired(record leaseRecord) bool {
+	if !time.Now().Before(record.ExpiresAt) {
+		return true
+	}
+	return record.Owner == s.instanceID && record.Process != s.leaseProcess
+}
+
+// pageInFlight reports whether a live claim on the page's lease shows it is
+// being translated right now, by this instance or another one.
+func (s *TaskService) pageInFlight(taskID string, pageNumber int) bool {
+	record, err := readLease(s.pageLeasePath(taskID, pageNumber))
+	return err == nil && !s.leaseExpired(record)
+}
+
+func (s *TaskService) startLease(path string, record leaseRecord) *lease {
+	l := &lease{svc: s, path: path, record: record, stop: make(chan struct{})}
+	go l.heartbeat()
+	return l
+}
+
+func (l *lease) heartbeat() {
+	ticker := time.NewTicker(leaseHeartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+			current, err := readLease(l.path)
+			if err != nil || current.Nonce != l.record.Nonce {
+				return
+			}
+			l.record.ExpiresAt = time.Now().Add(leaseTTL)
+			writeLease(l.path, l.record)
+		}
+	}
+}
+
+// Release stops renewal and removes the lock file if it is still this claim's.
+func (l *lease) Release() {
+	if l == nil {
+		return
+	}
+	close(l.stop)
+	if current, err := readLease(l.path); err == nil && current.Nonce == l.record.Nonce {
+		os.Remove(l.path)
+	}
+}
+
+func readLease(path string) (leaseRecord, error) {
+	var record leaseRecord
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return record, err
+	}
+	err = json.Unmarshal(data, &record)
+	return record, err
+}
+
+func writeLease(path string, record leaseRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	tmp := path + "." + uuid.NewString() + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("写入锁文件失败: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// leasedElsewhere reports whether another instance holds a live lease on the
+// task or one of its pages.
+func (s *TaskService) leasedElsewhere(taskID string) bool {
+	paths, _ := filepath.Glob(filepath.Join(s.taskDir(taskID), leaseDirName, "*.lock"))
+	for _, path := range paths {
+		current, err := readLease(path)
+		if err == nil && current.Owner != s.instanceID && !s.leaseExpired(current) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestClaimLeaseExclusive checks that concurrent claims, in one process or
+// taking over a stale lease, never both succeed.
+func TestClaimLeaseExclusive(t *testing.T) {
+	s := newDeterministicService(t)
+	path := filepath.Join(t.TempDir(), leaseDirName, "page-001.lock")
+	claimAll := func() int {
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		var won []*lease
+		for i := 0; i < 16; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				claim, err := s.claimLease(path)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if claim != nil {
+					mu.Lock()
+					won = append(won, claim)
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+		for _, claim := range won {
+			claim.Release()
+		}
+		return len(won)
+	}
+	if n := claimAll(); n != 1 {
+		t.Fatalf("fresh lease claimed %d times", n)
+	}
+
+	stale := leaseRecord{Owner: "other", Process: "other", Nonce: "old", ExpiresAt: time.Now().Add(-time.Minute)}
+	if err := writeLease(path, stale); err != nil {
+		t.Fatal(err)
+	}
+	if n := claimAll(); n != 1 {
+		t.Fatalf("stale lease taken over %d times", n)
+	}
+}
@@ -0,0 +1,152 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jung-kurt/gofpdf"
+
+	"pdftool/internal/model"
+)
+
+const (
+	metadataSourcePDF    = "pdf"
+	metadataSourcePage   = "page"
+	metadataSourceManual = "manual"
+	maxInferredTitleLen  = 120
+)
+
+// readDocumentMetadata loads title/author from the PDF info dictionary.
+func (s *TaskService) readDocumentMetadata(ctx context.Context, pdfPath string) *model.DocumentMetadata {
+	info, err := s.renderer.ReadMetadata(ctx, pdfPath)
+	if err != nil {
+		log.Printf("read pdf metadata failed: %v", err)
+		return nil
+	}
+	if info.Title == "" && info.Author == "" && info.Subject == "" && info.Keywords == "" {
+		return nil
+	}
+	return &model.DocumentMetadata{
+		Title:    info.Title,
+		Author:   info.Author,
+		Subject:  info.Subject,
+		Keywords: info.Keywords,
+		Source:   metadataSourcePDF,
+	}
+}
+
+// inferTitleFromPages fills a missing title from the first line the model
+// transcribed on the earliest page with text.
+func inferTitleFromPages(task *model.Task) bool {
+	if task.Metadata != nil && task.Metadata.Title != "" {
+		return false
+	}
+	for _, page := range task.Pages {
+		if page.Status != model.PageStatusCompleted || !page.HasText {
+			continue
+		}
+		for _, line := range strings.Split(page.SourceText, "\n") {
+			line = strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(line), "#*>- "))
+			if line == "" {
+				continue
+			}
+			if utf8.RuneCountInString(line) > maxInferredTitleLen {
+				return false
+			}
+			if task.Metadata == nil {
+				task.Metadata = &model.DocumentMetadata{}
+			}
+			task.Metadata.Title = line
+			if task.Metadata.Source == "" {
+				task.Metadata.Source = metadataSourcePage
+			}
+			return true
+		}
+		return false
+	}
+	return false
+}
+
+// documentTitle is the title used in exports: the chapters of a split part,
+// else the metadata title, falling back to the file name.
+func documentTitle(task *model.Task) string {
+	if task.Part != nil && task.Part.Title != "" {
+		return task.Part.Title
+	}
+	if task.Metadata != nil && task.Metadata.Title != "" {
+		return task.Metadata.Title
+	}
+	return strings.TrimSuffix(task.FileName, filepath.Ext(task.FileName))
+}
+
+func documentAuthor(task *model.Task) string {
+	if task.Metadata == nil {
+		return ""
+	}
+	return task.Metadata.Author
+}
+
+// applyPDFMetadata writes the document info dictionary of an exported PDF.
+func applyPDFMetadata(pdf *gofpdf.Fpdf, task *model.Task) {
+	pdf.SetTitle(documentTitle(task), true)
+	if author := documentAuthor(task); author != "" {
+		pdf.SetAuthor(author, true)
+	}
+	if task.Metadata != nil {
+		if task.Metadata.Subject != "" {
+			pdf.SetSubject(task.Metadata.Subject, true)
+		}
+		if task.Metadata.Keywords != "" {
+			pdf.SetKeywords(task.Metadata.Keywords, true)
+		}
+	}
+	pdf.SetCreator("pdftool", false)
+}
+
+// pandocMetadataArgs passes title/author to pandoc writers.
+func pandocMetadataArgs(task *model.Task) []string {
+	args := []string{"--metadata", "title=" + documentTitle(task)}
+	if author := documentAuthor(task); author != "" {
+		args = append(args, "--metadata", "author="+author)
+	}
+	return args
+}
+
+// UpdateMetadata overrides the stored document metadata; empty fields are kept.
+func (s *TaskService) UpdateMetadata(taskID string, input model.DocumentMetadata) (*model.Task, error) {
+	task, err := s.loadTask(taskID)
+	if err != nil {
+		return nil, err
+	}
+	if task.Metadata == nil {
+		task.Metadata = &model.DocumentMetadata{}
+	}
+	changed := false
+	for _, field := range []struct {
+		dst *string
+		src string
+	}{
+		{&task.Metadata.Title, input.Title},
+		{&task.Metadata.Author, input.Author},
+		{&task.Metadata.Subject, input.Subject},
+		{&task.Metadata.Keywords, input.Keywords},
+	} {
+		if value := strings.TrimSpace(field.src); value != "" {
+			*field.dst = value
+			changed = true
+		}
+	}
+	if !changed {
+		return nil, fmt.Errorf("未提供任何元数据字段")
+	}
+	task.Metadata.Source = metadataSourceManual
+	s.refreshCombinedText(task)
+	if err := s.saveTask(task); err != nil {
+		return nil, err
+	}
+	return task, nil
+}
@@ -0,0 +1,212 @@
+package service
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"pdftool/internal/eventbus"
+	"pdftool/internal/model"
+	"pdftool/internal/notify"
+)
+
+const (
+	defaultFailureAlertThreshold = 3
+	budgetAlertRatio             = 0.8
+)
+
+// alertState remembers which alerts already fired so sinks are not flooded.
+type alertState struct {
+	mu            sync.Mutex
+	failureStreak int
+	failureFired  bool
+	budgetFired   map[string]bool
+	storageFired  bool
+}
+
+func (s *TaskService) notify(event notify.Event) {
+	s.notifier.Notify(event)
+}
+
+func (s *TaskService) notifyTaskCompleted(task *model.Task) {
+	summary := summarizeTask(task)
+	s.publishEvent(eventbus.Event{
+		Kind:      eventbus.KindTaskCompleted,
+		TaskID:    task.ID,
+		FileName:  task.FileName,
+		Completed: summary.CompletedPages,
+		Failed:    summary.ErrorPages,
+		Total:     summary.TotalPages,
+	})
+	s.notify(notify.Event{
+		Kind:     notify.EventTaskCompleted,
+		TaskID:   task.ID,
+		FileName: task.FileName,
+		Message:  fmt.Sprintf("任务翻译结束：完成 %d 页，失败 %d 页，共 %d 页", summary.CompletedPages, summary.ErrorPages, summary.TotalPages),
+	})
+}
+
+// publishPageEvent emits page_completed or failed for the page's current status.
+func (s *TaskService) publishPageEvent(task *model.Task, page *model.PageResult) {
+	event := eventbus.Event{
+		Kind:       eventbus.KindPageCompleted,
+		TaskID:     task.ID,
+		FileName:   task.FileName,
+		PageNumber: page.PageNumber,
+		Status:     string(page.Status),
+		Total:      task.TotalPages,
+	}
+	if page.Status == model.PageStatusError || page.Status == model.PageStatusBlocked {
+		event.Kind = eventbus.KindFailed
+		event.Error = page.Error
+	}
+	s.publishEvent(event)
+}
+
+// publishPagesPending emits page_pending for pages queued for translation.
+func (s *TaskService) publishPagesPending(task *model.Task, pages []*model.PageResult) {
+	for _, page := range pages {
+		s.publishEvent(eventbus.Event{
+			Kind:       eventbus.KindPagePending,
+			TaskID:     task.ID,
+			FileName:   task.FileName,
+			PageNumber: page.PageNumber,
+			Status:     string(model.PageStatusPending),
+			Total:      task.TotalPages,
+		})
+	}
+}
+
+// publishFormatting reports AI formatting progress; status is "running",
+// "completed" or "error".
+func (s *TaskService) publishFormatting(task *model.Task, status string, completed, total int, err error) {
+	event := eventbus.Event{
+		Kind:      eventbus.KindFormatting,
+		TaskID:    task.ID,
+		FileName:  task.FileName,
+		Status:    status,
+		Completed: completed,
+		Total:     total,
+	}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	s.publishEvent(event)
+}
+
+// publishExportEvent reports a generated export file by its export name.
+func (s *TaskService) publishExportEvent(task *model.Task, name, url string) {
+	s.publishEvent(eventbus.Event{
+		Kind:     eventbus.KindExport,
+		TaskID:   task.ID,
+		FileName: task.FileName,
+		Status:   name,
+		URL:      url,
+	})
+}
+
+// SubscribeEvents streams the lifecycle events of a task published from now
+// on; call cancel when done.
+func (s *TaskService) SubscribeEvents(taskID string) (<-chan eventbus.Event, func()) {
+	return s.events.Subscribe(taskID)
+}
+
+// recordPageOutcome tracks consecutive provider failures across all tasks.
+func (s *TaskService) recordPageOutcome(task *model.Task, err error) {
+	s.alerts.mu.Lock()
+	defer s.alerts.mu.Unlock()
+	if err == nil {
+		s.alerts.failureStreak = 0
+		s.alerts.failureFired = false
+		return
+	}
+	s.alerts.failureStreak++
+	if s.alerts.failureFired || s.alerts.failureStreak < s.failureAlertThreshold {
+		return
+	}
+	s.alerts.failureFired = true
+	s.notify(notify.Event{
+		Kind:     notify.EventProviderFailures,
+		TaskID:   task.ID,
+		FileName: task.FileName,
+		Message:  fmt.Sprintf("提供商连续失败 %d 次，最近错误: %v", s.alerts.failureStreak, err),
+	})
+}
+
+// checkBudgetThresholds fires once per period when spend crosses 80% or 100%
+// of a cap. Alerts of past periods are forgotten on rollover.
+func (s *TaskService) checkBudgetThresholds(status *model.BudgetStatus) {
+	type usage struct {
+		key   string
+		label string
+		used  float64
+		limit float64
+	}
+	checks := []usage{
+		{"tokens:" + status.Day, "每日 token", float64(status.DayTokens), float64(status.DailyTokenLimit)},
+		{"tokens:" + status.Month, "每月 token", float64(status.MonthTokens), float64(status.MonthlyTokenLimit)},
+		{"cost:" + status.Day, "每日费用", status.DayCost, status.DailyCostLimit},
+		{"cost:" + status.Month, "每月费用", status.MonthCost, status.MonthlyCostLimit},
+	}
+	s.alerts.mu.Lock()
+	defer s.alerts.mu.Unlock()
+	if s.alerts.budgetFired == nil {
+		s.alerts.budgetFired = make(map[string]bool)
+	}
+	for key := range s.alerts.budgetFired {
+		period := key[:strings.LastIndex(key, ":")]
+		if !slices.ContainsFunc(checks, func(c usage) bool { return c.key == period }) {
+			delete(s.alerts.budgetFired, key)
+		}
+	}
+	for _, c := range checks {
+		if c.limit <= 0 {
+			continue
+		}
+		for _, ratio := range []float64{budgetAlertRatio, 1} {
+			key := fmt.Sprintf("%s:%.0f", c.key, ratio*100)
+			if c.used < c.limit*ratio || s.alerts.budgetFired[key] {
+				continue
+			}
+			s.alerts.budgetFired[key] = true
+			s.notify(notify.Event{
+				Kind:    notify.EventBudgetThreshold,
+				Message: fmt.Sprintf("%s用量已达上限的 %.0f%%（%.2f / %.2f）", c.label, ratio*100, c.used, c.limit),
+			})
+		}
+	}
+}
+
+// checkStorageUsage walks the storage dir and alerts when it exceeds the configured size.
+func (s *TaskService) checkStorageUsage() {
+	if s.storageAlertBytes <= 0 || !s.notifier.Enabled() {
+		return
+	}
+	var total int64
+	filepath.WalkDir(s.storageDir, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			total += info.Size()
+		}
+		return nil
+	})
+	s.alerts.mu.Lock()
+	defer s.alerts.mu.Unlock()
+	if total < s.storageAlertBytes {
+		s.alerts.storageFired = false
+		return
+	}
+	if s.alerts.storageFired {
+		return
+	}
+	s.alerts.storageFired = true
+	s.notify(notify.Event{
+		Kind:    notify.EventStorageThreshold,
+		Message: fmt.Sprintf("存储目录占用 %.1f MB，已超过告警阈值 %.1f MB", float64(total)/(1<<20), float64(s.storageAlertBytes)/(1<<20)),
+	})
+}
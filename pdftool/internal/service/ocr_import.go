@@ -0,0 +1,124 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"pdftool/internal/model"
+	"pdftool/internal/ocrimport"
+	"pdftool/internal/translator"
+)
+
+// ImportOCR attaches external OCR text to pages and translates it without image recognition.
+func (s *TaskService) ImportOCR(ctx context.Context, taskID string, pages []ocrimport.PageText, provider translator.ProviderConfig) (*model.Task, error) {
+	if len(pages) == 0 {
+		return nil, fmt.Errorf("没有可导入的 OCR 内容")
+	}
+	if err := s.checkBudget(); err != nil {
+		return nil, err
+	}
+	task, err := s.loadTask(taskID)
+	if err != nil {
+		return nil, err
+	}
+	providerCfg, err := s.mergeProviderConfig(provider, task)
+	if err != nil {
+		return nil, err
+	}
+	textClient, err := translator.NewTextTranslator(providerCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// Apply the import to the stored task under the lock, so pages finishing
+	// meanwhile are neither lost nor overwritten by this stale copy.
+	var toTranslate []*model.PageResult
+	task, err = s.updateTask(taskID, func(current *model.Task) error {
+		toTranslate = nil
+		byNumber := make(map[int]*model.PageResult, len(current.Pages))
+		for _, page := range current.Pages {
+			byNumber[page.PageNumber] = page
+		}
+		now := time.Now()
+		for _, imported := range pages {
+			page, ok := byNumber[imported.PageNumber]
+			if !ok {
+				return fmt.Errorf("OCR 文件页码 %d 超出任务页数", imported.PageNumber)
+			}
+			page.OCRSource = string(imported.Format)
+			page.SourceText = rewrapText(normalizeText(imported.Text))
+			page.Translation = ""
+			page.Error = ""
+			page.BlockReason = ""
+			page.UpdatedAt = now
+			if page.SourceText == "" {
+				page.HasText = false
+				page.Regions = nil
+				page.Status = model.PageStatusCompleted
+				page.TextURL = ""
+				os.Remove(page.TextPath)
+				continue
+			}
+			page.HasText = true
+			page.Status = model.PageStatusPending
+			page.Provider = pageProvider(current, providerCfg)
+			page.Regions = ocrRegions(imported, page)
+			toTranslate = append(toTranslate, page)
+		}
+		s.clearCanceled(current.ID)
+		changeTaskState(current, settledState(current), "导入 OCR 文本")
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.publishPagesPending(task, toTranslate)
+	go s.runPageJobs(task, toTranslate, 0, func(page *model.PageResult) error {
+		return s.translateTextPage(context.Background(), task, page, textClient)
+	})
+	return task, nil
+}
+
+func (s *TaskService) translateTextPage(ctx context.Context, task *model.Task, page *model.PageResult, textClient translator.TextTranslator) error {
+	base := page.UpdatedAt
+	ctx = WithPrincipal(ctx, task.Owner)
+	release, err := s.pools.acquire(ctx, pageProviderType(task, page))
+	if err != nil {
+		return s.applyPageResult(task, page, base, translator.Result{}, err)
+	}
+	defer release()
+	start := time.Now()
+	ctx, budgetDone, err := s.withPageBudget(ctx, task, page, start)
+	if err != nil {
+		s.scheduleRetry(task, page, err, nil)
+		return s.applyPageResult(task, page, base, translator.Result{}, err)
+	}
+	ctxWithPage, finish := s.withProviderStats(translator.WithPageNumber(ctx, page.PageNumber), pageProviderInfo(task, page))
+	var result translator.Result
+	if regionsHaveText(page.Regions) {
+		result, err = translateRegionText(ctxWithPage, page, textClient)
+	} else {
+		result, err = textClient.TranslateText(ctxWithPage, page.SourceText)
+	}
+	finish(err)
+	s.pools.holdFor(pageProviderType(task, page), err)
+	result, err = s.refusalFallback(ctxWithPage, task, page, result, err, func(ctx context.Context, cfg translator.ProviderConfig) (translator.Result, error) {
+		client, err := translator.NewTextTranslator(cfg)
+		if err != nil {
+			return translator.Result{}, err
+		}
+		if regionsHaveText(page.Regions) {
+			return translateRegionText(ctx, page, client)
+		}
+		return client.TranslateText(ctx, page.SourceText)
+	})
+	result, err = s.postTranslate(ctx, task, page, result, err)
+	err = budgetDone(err)
+	markPageTiming(page, start)
+	s.scheduleRetry(task, page, err, func(task *model.Task, page *model.PageResult) error {
+		return s.translateTextPage(context.Background(), task, page, textClient)
+	})
+	return s.applyPageResult(task, page, base, result, err)
+}
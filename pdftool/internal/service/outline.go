@@ -0,0 +1,97 @@
+package service
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	"github.com/jung-kurt/gofpdf"
+
+	"pdftool/internal/model"
+)
+
+// maxOutlineEntries bounds the bookmarks kept per task; generated outlines
+// of reference works can list every page several times over.
+const maxOutlineEntries = 2000
+
+// readDocumentOutline returns the bookmarks of the PDF mapped to task pages.
+// A part of a split document keeps the entries within its pages.
+func (s *TaskService) readDocumentOutline(ctx context.Context, pdfPath string, part *model.TaskPart) []model.OutlineEntry {
+	outline, err := s.renderer.ReadOutline(ctx, pdfPath)
+	if err != nil {
+		log.Printf("read pdf outline failed: %v", err)
+		return nil
+	}
+	var entries []model.OutlineEntry
+	for _, entry := range outline.Entries {
+		page := entry.Page
+		if part != nil {
+			if page < part.FirstPage || page > part.LastPage {
+				continue
+			}
+			page -= part.FirstPage - 1
+		}
+		entries = append(entries, model.OutlineEntry{Level: entry.Level, Title: entry.Title, Page: page})
+		if len(entries) == maxOutlineEntries {
+			break
+		}
+	}
+	return entries
+}
+
+// outlineByPage groups the task's bookmarks by the page they point to, in
+// outline order.
+func outlineByPage(task *model.Task) map[int][]model.OutlineEntry {
+	pages := make(map[int][]model.OutlineEntry)
+	for _, entry := range task.Outline {
+		pages[entry.Page] = append(pages[entry.Page], entry)
+	}
+	return pages
+}
+
+// outlineText labels a page of the TXT export with its bookmarks, one title
+// per line. Exports carry the bookmarks of skipped pages to the next page
+// they write.
+func outlineText(entries []model.OutlineEntry) string {
+	var b strings.Builder
+	for _, entry := range entries {
+		b.WriteString(entry.Title + "\n")
+	}
+	return b.String()
+}
+
+// outlineMarkdown turns bookmarks into headings below the document title:
+// top-level entries become "##" headings.
+func outlineMarkdown(entries []model.OutlineEntry) string {
+	var b strings.Builder
+	for _, entry := range entries {
+		level := min(max(entry.Level, 1)+1, 6)
+		b.WriteString(strings.Repeat("#", level) + " " + entry.Title + "\n\n")
+	}
+	return b.String()
+}
+
+// pdfOutline adds the task's bookmarks to a merged PDF. gofpdf links each
+// bookmark to its parent by level, so a level never exceeds the previous
+// one by more than one.
+type pdfOutline struct {
+	level int
+}
+
+func newPDFOutline() *pdfOutline {
+	return &pdfOutline{level: -1}
+}
+
+// onNextPage bookmarks the entries at the top of the next page added.
+func (o *pdfOutline) onNextPage(pdf *gofpdf.Fpdf, entries []model.OutlineEntry) {
+	if len(entries) == 0 {
+		return
+	}
+	pdf.SetHeaderFunc(func() {
+		pdf.SetHeaderFunc(nil)
+		for _, entry := range entries {
+			o.level = min(max(entry.Level-1, 0), o.level+1)
+			pdf.Bookmark(entry.Title, o.level, 0)
+		}
+	})
+}
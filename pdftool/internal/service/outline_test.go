@@ -0,0 +1,40 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"pdftool/internal/model"
+)
+
+// TestOutlineLabelsExports checks that a bookmark of a page without text
+// labels the next page written.
+func TestOutlineLabelsExports(t *testing.T) {
+	task := &model.Task{
+		Pages: []*model.PageResult{
+			{PageNumber: 1},
+			{PageNumber: 2, HasText: true, Translation: "第一章正文"},
+			{PageNumber: 3, HasText: true, Translation: "第二节正文"},
+		},
+		Outline: []model.OutlineEntry{
+			{Level: 1, Title: "Chapter 1", Page: 1},
+			{Level: 2, Title: "Section 2", Page: 3},
+		},
+	}
+	text, err := (&TaskService{}).buildCombinedText(task)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Chapter 1\n\n第2页\n第一章正文\n\nSection 2\n\n第3页\n第二节正文"; strings.TrimSpace(text) != want {
+		t.Errorf("buildCombinedText = %q, want %q", text, want)
+	}
+	markdown, err := buildCombinedMarkdown(task)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, heading := range []string{"## Chapter 1\n\n## 第2页", "### Section 2\n\n## 第3页"} {
+		if !strings.Contains(markdown, heading) {
+			t.Errorf("markdown export lacks %q:\n%s", heading, markdown)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package service
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"pdftool/internal/cryptfile"
+	"pdftool/internal/model"
+	"pdftool/internal/pdfutil"
+)
+
+// Resized page images are cached per width; widths are rounded up to a step
+// so arbitrary client widths cannot fill the disk with derivatives.
+const (
+	pageImageWidthStep = 100
+	maxPageImageWidth  = 4000
+	pageImageCacheDir  = "resized"
+)
+
+// PageImage returns the path of the page image scaled to width (0 returns the
+// original). Derivatives are cached next to the page images and rebuilt when
+// the page is re-rendered.
+func (s *TaskService) PageImage(taskID string, pageNumber, width int) (string, error) {
+	if width < 0 {
+		return "", fmt.Errorf("图片宽度不能为负数")
+	}
+	task, err := s.loadTask(taskID)
+	if err != nil {
+		return "", err
+	}
+	for _, page := range task.Pages {
+		if page.PageNumber != pageNumber {
+			continue
+		}
+		return s.resizedPageImage(page, width)
+	}
+	return "", fmt.Errorf("页码 %d 不存在", pageNumber)
+}
+
+func (s *TaskService) resizedPageImage(page *model.PageResult, width int) (string, error) {
+	if width == 0 {
+		return page.ImagePath, nil
+	}
+	width = (width + pageImageWidthStep - 1) / pageImageWidthStep * pageImageWidthStep
+	if width > maxPageImageWidth {
+		width = maxPageImageWidth
+	}
+	if page.ImageWidth > 0 && width >= page.ImageWidth {
+		return page.ImagePath, nil
+	}
+	source, err := os.Stat(page.ImagePath)
+	if err != nil {
+		return "", fmt.Errorf("读取页面图片失败: %w", err)
+	}
+	base := strings.TrimSuffix(filepath.Base(page.ImagePath), filepath.Ext(page.ImagePath))
+	cached := filepath.Join(filepath.Dir(page.ImagePath), pageImageCacheDir, fmt.Sprintf("%s-w%d.jpg", base, width))
+	if info, err := os.Stat(cached); err == nil && !info.ModTime().Before(source.ModTime()) {
+		return cached, nil
+	}
+	plain, cleanup, err := s.plainFile(page.ImagePath)
+	defer cleanup()
+	if err != nil {
+		return "", err
+	}
+	if err := pdfutil.ResizeImage(plain, cached, width); err != nil {
+		return "", fmt.Errorf("生成缩放图片失败: %w", err)
+	}
+	if plain != page.ImagePath {
+		// Thumbnails of encrypted pages are encrypted as well.
+		key, err := s.keyForPath(page.ImagePath)
+		if err != nil {
+			return "", err
+		}
+		if err := cryptfile.EncryptFile(key, cached); err != nil {
+			return "", fmt.Errorf("加密缩放图片失败: %w", err)
+		}
+	}
+	return cached, nil
+}
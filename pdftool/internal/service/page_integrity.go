@@ -0,0 +1,136 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+
+	"github.com/google/uuid"
+
+	"pdftool/internal/cryptfile"
+	"pdftool/internal/model"
+)
+
+// errImageDamaged marks a page image that exists but cannot be used.
+var errImageDamaged = errors.New("页面图片已损坏")
+
+// verifyPageImage checks that the image at path is a complete, decodable
+// image, decrypting it first when it is stored encrypted. A failed write or
+// disk problem typically leaves a missing, empty or truncated file.
+func (s *TaskService) verifyPageImage(path string) error {
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("页面图片不存在")
+	}
+	if err != nil {
+		return err
+	}
+	if info.Size() == 0 {
+		return fmt.Errorf("%w: 文件为空", errImageDamaged)
+	}
+	plain, cleanup, err := s.plainFile(path)
+	defer cleanup()
+	if err != nil {
+		return fmt.Errorf("%w: %v", errImageDamaged, err)
+	}
+	return checkImageFile(plain)
+}
+
+// checkImageFile decodes the plaintext image at path. Decoding the whole
+// image also catches files truncated after the header.
+func checkImageFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if _, _, err := image.Decode(file); err != nil {
+		return fmt.Errorf("%w: %v", errImageDamaged, err)
+	}
+	return nil
+}
+
+// CheckPageImages verifies every page image of the task and lists the pages
+// whose image needs to be re-rendered.
+func (s *TaskService) CheckPageImages(ctx context.Context, taskID string) ([]model.PageImageProblem, error) {
+	task, err := s.loadTask(taskID)
+	if err != nil {
+		return nil, err
+	}
+	problems := []model.PageImageProblem{}
+	for _, page := range task.Pages {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if err := s.verifyPageImage(page.ImagePath); err != nil {
+			problems = append(problems, model.PageImageProblem{PageNumber: page.PageNumber, Reason: err.Error()})
+		}
+	}
+	return problems, nil
+}
+
+// RerenderPage regenerates one page image from the task's source PDF. Only
+// the image and its size change; the page's translation and status are kept.
+func (s *TaskService) RerenderPage(ctx context.Context, taskID string, pageNumber int) (*model.Task, error) {
+	task, err := s.loadTask(taskID)
+	if err != nil {
+		return nil, err
+	}
+	var target *model.PageResult
+	for _, page := range task.Pages {
+		if page.PageNumber == pageNumber {
+			target = page
+			break
+		}
+	}
+	if target == nil {
+		return nil, fmt.Errorf("页码 %d 不存在", pageNumber)
+	}
+	if task.OriginalPath == "" {
+		return nil, fmt.Errorf("原始 PDF 不存在，无法重新渲染")
+	}
+	if _, err := os.Stat(task.OriginalPath); err != nil {
+		return nil, fmt.Errorf("原始 PDF 不存在，无法重新渲染")
+	}
+	source, cleanup, err := s.plainFile(task.OriginalPath)
+	defer cleanup()
+	if err != nil {
+		return nil, err
+	}
+
+	// Render next to the page image and swap it in once it is complete and,
+	// with encryption at rest, sealed.
+	tmp := filepath.Join(filepath.Dir(target.ImagePath), "rerender-"+uuid.NewString()+filepath.Ext(target.ImagePath))
+	if err := os.MkdirAll(filepath.Dir(tmp), 0o755); err != nil {
+		return nil, &StorageError{Op: "重新渲染页面失败", Err: err}
+	}
+	rendered, err := s.renderer.RenderPage(ctx, source, tmp, sourcePageIndex(task, pageNumber), taskRenderOptions(task))
+	if err != nil {
+		os.Remove(tmp)
+		return nil, fmt.Errorf("重新渲染第%d页失败: %w", pageNumber, err)
+	}
+	key, err := s.keyForPath(target.ImagePath)
+	if err == nil && key != nil {
+		err = cryptfile.EncryptFile(key, tmp)
+	}
+	if err == nil {
+		err = os.Rename(tmp, target.ImagePath)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return nil, &StorageError{Op: "写入页面图片失败", Err: err}
+	}
+
+	return s.updateTask(taskID, func(current *model.Task) error {
+		for _, page := range current.Pages {
+			if page.PageNumber == pageNumber {
+				page.ImageWidth, page.ImageHeight = rendered.Width, rendered.Height
+				return nil
+			}
+		}
+		return fmt.Errorf("页码 %d 不存在", pageNumber)
+	})
+}
@@ -0,0 +1,58 @@
+package service
+
+import (
+	"context"
+	"os"
+	"testing"
+)
+
+// TestRerenderDamagedPage truncates and empties page images, checks that the
+// image check reports them and that re-rendering restores them without
+// touching the page translation state.
+func TestRerenderDamagedPage(t *testing.T) {
+	ctx := context.Background()
+	s := newDeterministicService(t)
+	task := createSampleTask(t, s, TranslationSettings{DryRun: true})
+	problems, err := s.CheckPageImages(ctx, task.ID)
+	if err != nil || len(problems) != 0 {
+		t.Fatalf("fresh task: problems %v, err %v", problems, err)
+	}
+
+	page2, page3 := task.Pages[1], task.Pages[2]
+	data, err := os.ReadFile(page2.ImagePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(page2.ImagePath, data[:len(data)/2], 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(page3.ImagePath, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	problems, err = s.CheckPageImages(ctx, task.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(problems) != 2 || problems[0].PageNumber != 2 || problems[1].PageNumber != 3 {
+		t.Fatalf("problems = %+v, want pages 2 and 3", problems)
+	}
+
+	before := page2.Status
+	updated, err := s.RerenderPage(ctx, task.ID, 2)
+	if err != nil {
+		t.Fatalf("rerender: %v", err)
+	}
+	if got := updated.Pages[1]; got.Status != before || got.ImageWidth != page2.ImageWidth || got.ImageHeight != page2.ImageHeight {
+		t.Errorf("rerendered page = status %s %dx%d, want status %s %dx%d", got.Status, got.ImageWidth, got.ImageHeight, before, page2.ImageWidth, page2.ImageHeight)
+	}
+	problems, err = s.CheckPageImages(ctx, task.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(problems) != 1 || problems[0].PageNumber != 3 {
+		t.Errorf("after rerender problems = %+v, want only page 3", problems)
+	}
+	if _, err := s.RerenderPage(ctx, task.ID, 9); err == nil {
+		t.Error("rerendering a missing page succeeded")
+	}
+}
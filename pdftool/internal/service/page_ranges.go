@@ -0,0 +1,137 @@
+package service
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/rand/v2"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Range modes beyond the original "custom" (first N) and "range" modes.
+const (
+	// RangeModePages selects an explicit page list such as "1-3,7,20-25".
+	RangeModePages = "pages"
+	// RangeModeOdd and RangeModeEven select one side of a duplex scan.
+	RangeModeOdd  = "odd"
+	RangeModeEven = "even"
+)
+
+// pageSpan is an inclusive page range; end 0 means "to the last page".
+type pageSpan struct {
+	start, end int
+}
+
+// parsePageSpans parses a comma-separated list of page numbers and inclusive
+// ranges, e.g. "1-3,7,20-25" or "300-" for everything from page 300.
+func parsePageSpans(expr string) ([]pageSpan, error) {
+	var spans []pageSpan
+	for _, part := range strings.FieldsFunc(expr, func(r rune) bool { return r == ',' || r == '，' || r == ';' }) {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		startRaw, endRaw, isRange := strings.Cut(part, "-")
+		start, err := strconv.Atoi(strings.TrimSpace(startRaw))
+		if err != nil || start <= 0 {
+			return nil, fmt.Errorf("页码表达式无效: %q", part)
+		}
+		span := pageSpan{start: start, end: start}
+		if isRange {
+			span.end = 0
+			if endRaw = strings.TrimSpace(endRaw); endRaw != "" {
+				if span.end, err = strconv.Atoi(endRaw); err != nil || span.end < start {
+					return nil, fmt.Errorf("页码表达式无效: %q", part)
+				}
+			}
+		}
+		spans = append(spans, span)
+	}
+	if len(spans) == 0 {
+		return nil, fmt.Errorf("页码表达式为空")
+	}
+	return spans, nil
+}
+
+// pageSet expands spans into page numbers, dropping pages beyond total.
+func pageSet(spans []pageSpan, total int) map[int]bool {
+	pages := make(map[int]bool)
+	for _, span := range spans {
+		end := span.end
+		if end == 0 || end > total {
+			end = total
+		}
+		for i := span.start; i <= end; i++ {
+			pages[i] = true
+		}
+	}
+	return pages
+}
+
+// parsePageList parses expr for a document of total pages, rejecting pages
+// that do not exist.
+func parsePageList(expr string, total int) (map[int]bool, error) {
+	spans, err := parsePageSpans(expr)
+	if err != nil {
+		return nil, err
+	}
+	for _, span := range spans {
+		if span.start > total || span.end > total {
+			return nil, fmt.Errorf("页码超出总页数 %d: %q", total, expr)
+		}
+	}
+	return pageSet(spans, total), nil
+}
+
+// excludePages drops the pages listed in expr from selected; invalid
+// expressions are rejected before rendering and ignored here.
+func excludePages(selected map[int]bool, expr string, total int) {
+	if strings.TrimSpace(expr) == "" {
+		return
+	}
+	spans, err := parsePageSpans(expr)
+	if err != nil {
+		return
+	}
+	for page := range pageSet(spans, total) {
+		delete(selected, page)
+	}
+}
+
+// samplePages picks n of the selected pages for a sample translation: the
+// first, middle and last page, then random pages in between. The choice is
+// seeded by seed (the task ID), so a task always samples the same pages.
+// Selections of n pages or fewer are returned whole.
+func samplePages(selected map[int]bool, n int, seed string) []int {
+	pages := make([]int, 0, len(selected))
+	for page := range selected {
+		pages = append(pages, page)
+	}
+	slices.Sort(pages)
+	if n >= len(pages) {
+		return pages
+	}
+	picked := make(map[int]bool, n)
+	for _, i := range []int{0, len(pages) / 2, len(pages) - 1} {
+		if len(picked) < n {
+			picked[pages[i]] = true
+		}
+	}
+	hash := fnv.New64a()
+	hash.Write([]byte(seed))
+	rng := rand.New(rand.NewPCG(hash.Sum64(), 0))
+	for _, i := range rng.Perm(len(pages)) {
+		if len(picked) == n {
+			break
+		}
+		picked[pages[i]] = true
+	}
+	sample := make([]int, 0, n)
+	for _, page := range pages {
+		if picked[page] {
+			sample = append(sample, page)
+		}
+	}
+	return sample
+}
@@ -0,0 +1,33 @@
+package service
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSamplePages(t *testing.T) {
+	selected := make(map[int]bool)
+	for page := 5; page <= 104; page++ {
+		selected[page] = true
+	}
+	sample := samplePages(selected, 10, "task-a")
+	if len(sample) != 10 || !slices.IsSorted(sample) {
+		t.Fatalf("sample %v, want 10 sorted pages", sample)
+	}
+	for _, page := range []int{5, 55, 104} {
+		if !slices.Contains(sample, page) {
+			t.Errorf("sample %v misses page %d", sample, page)
+		}
+	}
+	for _, page := range sample {
+		if !selected[page] {
+			t.Errorf("sample %v has unselected page %d", sample, page)
+		}
+	}
+	if again := samplePages(selected, 10, "task-a"); !slices.Equal(again, sample) {
+		t.Errorf("same seed sampled %v, then %v", sample, again)
+	}
+	if all := samplePages(map[int]bool{3: true, 1: true}, 10, "task-a"); !slices.Equal(all, []int{1, 3}) {
+		t.Errorf("small selection sampled %v, want [1 3]", all)
+	}
+}
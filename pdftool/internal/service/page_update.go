@@ -0,0 +1,113 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"time"
+
+	"pdftool/internal/model"
+)
+
+// ErrPageConflict is returned when a page was changed by another writer (for
+// example a retranslation) after the caller started working on it.
+var ErrPageConflict = errors.New("页面已被其他操作更新，请刷新后重试")
+
+// updateTask reloads the task and applies mutate to the stored copy under the
+// task lock, so concurrent writers never overwrite each other's changes.
+func (s *TaskService) updateTask(taskID string, mutate func(*model.Task) error) (*model.Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	task, err := s.loadTask(taskID)
+	if err != nil {
+		return nil, err
+	}
+	if err := mutate(task); err != nil {
+		return nil, err
+	}
+	if err := s.saveTaskLocked(task); err != nil {
+		return nil, err
+	}
+	return task, nil
+}
+
+// commitPage stores one page atomically. base is the page's UpdatedAt when the
+// caller started; if the stored page has moved on since, the result is stale
+// and ErrPageConflict is returned instead of overwriting it. paused carries an
+// auto-pause decided for this page onto the stored task.
+func (s *TaskService) commitPage(task *model.Task, page *model.PageResult, base time.Time, paused bool) error {
+	_, err := s.updateTask(task.ID, func(current *model.Task) error {
+		idx := -1
+		for i, existing := range current.Pages {
+			if existing.ID == page.ID {
+				idx = i
+				break
+			}
+		}
+		if idx < 0 {
+			return fmt.Errorf("page %d not found", page.PageNumber)
+		}
+		if !current.Pages[idx].UpdatedAt.Equal(base) {
+			log.Printf("drop stale result for page %d of task %s", page.PageNumber, task.ID)
+			return ErrPageConflict
+		}
+		stored := *page
+		keepRevision(current, current.Pages[idx], &stored)
+		carryReview(current.Pages[idx], &stored)
+		current.Pages[idx] = &stored
+		current.FailureStreak = task.FailureStreak
+		if paused {
+			current.Paused = true
+			current.PausedAt = task.PausedAt
+			current.PauseReason = task.PauseReason
+			changeTaskState(current, model.TaskStatePaused, task.PauseReason)
+		}
+		if page.Status == model.PageStatusCompleted {
+			current.CombinedTxtPath = filepath.Join(s.taskDir(current.ID), "combined.txt")
+			current.CombinedTxtURL = s.buildFileURL(current.ID, "combined.txt")
+			markCombinedTextRefreshed(current)
+		}
+		return nil
+	})
+	if err == nil && page.Status == model.PageStatusCompleted {
+		s.flushCombinedText(task.ID)
+	}
+	return err
+}
+
+// withNewerPages returns task with any page that another writer stored more
+// recently taken from stored, so saving a stale copy of the whole task cannot
+// roll back a page.
+func withNewerPages(task, stored *model.Task) *model.Task {
+	newer := make(map[string]*model.PageResult)
+	for _, page := range stored.Pages {
+		newer[page.ID] = page
+	}
+	var merged []*model.PageResult
+	for i, page := range task.Pages {
+		other := newer[page.ID]
+		if other == nil {
+			continue
+		}
+		switch {
+		case other.UpdatedAt.After(page.UpdatedAt):
+		case other.ReviewedAt.After(page.ReviewedAt):
+			reviewed := *page
+			carryReview(other, &reviewed)
+			other = &reviewed
+		default:
+			continue
+		}
+		if merged == nil {
+			merged = append([]*model.PageResult(nil), task.Pages...)
+		}
+		merged[i] = other
+	}
+	if merged == nil {
+		return task
+	}
+	out := *task
+	out.Pages = merged
+	return &out
+}
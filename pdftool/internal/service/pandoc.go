@@ -0,0 +1,169 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"pdftool/internal/cryptfile"
+	"pdftool/internal/model"
+)
+
+// pandocFormats maps supported pandoc writers to output file extensions.
+var pandocFormats = map[string]string{
+	"odt":   "odt",
+	"rtf":   "rtf",
+	"latex": "tex",
+}
+
+// MergeMarkdown generates a Markdown document with one section per translated page.
+func (s *TaskService) MergeMarkdown(ctx context.Context, taskID string) (*model.Task, string, error) {
+	task, err := s.loadTask(taskID)
+	if err != nil {
+		return nil, "", err
+	}
+	markdown, err := buildCombinedMarkdown(task)
+	if err != nil {
+		return nil, "", err
+	}
+	mdPath := filepath.Join(s.taskDir(task.ID), "combined.md")
+	if err := s.writeTaskFileAtomic(task.ID, mdPath, []byte(markdown)); err != nil {
+		return nil, "", fmt.Errorf("写入Markdown失败: %w", err)
+	}
+	task.CombinedMarkdownPath = mdPath
+	task.CombinedMarkdownURL = s.buildFileURL(task.ID, "combined.md")
+	clearStaleExport(task, ExportMarkdown)
+	recordExportProgress(task, ExportMarkdown, "")
+	s.publishExport(ctx, task, mdPath)
+	if err := s.saveTask(task); err != nil {
+		return nil, "", err
+	}
+	s.publishExportEvent(task, ExportMarkdown, task.CombinedMarkdownURL)
+	return task, task.CombinedMarkdownURL, nil
+}
+
+func buildCombinedMarkdown(task *model.Task) (string, error) {
+	var builder strings.Builder
+	if title := documentTitle(task); title != "" {
+		builder.WriteString("# " + title + "\n\n")
+	}
+	if notice, ok := partialNotice(task); ok {
+		builder.WriteString("> " + notice + "\n\n")
+	}
+	wrote := false
+	bookmarks := outlineByPage(task)
+	var pending []model.OutlineEntry
+	for _, page := range task.Pages {
+		pending = append(pending, bookmarks[page.PageNumber]...)
+		text := pageExportText(task, page)
+		if !page.HasText || text == "" {
+			continue
+		}
+		builder.WriteString(outlineMarkdown(pending))
+		pending = nil
+		if header, ok := pageHeader(task, page); ok {
+			builder.WriteString("## " + header + "\n\n")
+		}
+		rtl := isRTLText(text)
+		text = markdownWithNotes(page, markdownHeadings(page, text))
+		if rtl {
+			builder.WriteString("<div dir=\"rtl\">\n\n" + text + "\n\n</div>\n\n")
+		} else {
+			builder.WriteString(text)
+			builder.WriteString("\n\n")
+		}
+		wrote = true
+	}
+	if !wrote {
+		return "", fmt.Errorf("没有可用的翻译文本")
+	}
+	return builder.String(), nil
+}
+
+// PandocFormats lists the extra export formats available with the configured pandoc binary.
+func (s *TaskService) PandocFormats() []string {
+	if s.pandocPath == "" {
+		return nil
+	}
+	formats := make([]string, 0, len(pandocFormats))
+	for format := range pandocFormats {
+		formats = append(formats, format)
+	}
+	sort.Strings(formats)
+	return formats
+}
+
+// ExportPandoc converts the Markdown export into another format with pandoc.
+func (s *TaskService) ExportPandoc(ctx context.Context, taskID, format string) (*model.Task, string, error) {
+	if s.pandocPath == "" {
+		return nil, "", fmt.Errorf("未配置 pandoc，无法导出该格式")
+	}
+	format = strings.ToLower(strings.TrimSpace(format))
+	ext, ok := pandocFormats[format]
+	if !ok {
+		return nil, "", fmt.Errorf("不支持的导出格式: %s", format)
+	}
+	task, _, err := s.MergeMarkdown(ctx, taskID)
+	if err != nil {
+		return nil, "", err
+	}
+	fileName := "combined." + ext
+	outPath := filepath.Join(s.taskDir(task.ID), fileName)
+	// pandoc reads and writes plaintext, so it works on a private temp dir
+	// and the result is stored like every other task file.
+	workDir, err := os.MkdirTemp("", "pdftool-pandoc-*")
+	if err != nil {
+		return nil, "", fmt.Errorf("创建临时目录失败: %w", err)
+	}
+	defer os.RemoveAll(workDir)
+	key, err := s.keyForPath(task.CombinedMarkdownPath)
+	if err != nil {
+		return nil, "", err
+	}
+	mdPath, err := cryptfile.DecryptToTemp(key, task.CombinedMarkdownPath, workDir)
+	if err != nil {
+		return nil, "", fmt.Errorf("解密文件失败: %w", err)
+	}
+	tmpOut := filepath.Join(workDir, fileName)
+	args := append([]string{"--standalone", "-f", "markdown", "-t", format, "-o", tmpOut}, pandocMetadataArgs(task)...)
+	if translationsRTL(task) {
+		args = append(args, "--metadata", "dir=rtl")
+	}
+	if err := s.runPandoc(ctx, append(args, mdPath)...); err != nil {
+		return nil, "", err
+	}
+	data, err := os.ReadFile(tmpOut)
+	if err != nil {
+		return nil, "", fmt.Errorf("读取 pandoc 输出失败: %w", err)
+	}
+	if err := s.writeTaskFileAtomic(task.ID, outPath, data); err != nil {
+		return nil, "", fmt.Errorf("写入导出文件失败: %w", err)
+	}
+	if task.PandocExports == nil {
+		task.PandocExports = make(map[string]string)
+	}
+	task.PandocExports[format] = s.buildFileURL(task.ID, fileName)
+	clearStaleExport(task, format)
+	recordExportProgress(task, format, "")
+	s.publishExport(ctx, task, outPath)
+	if err := s.saveTask(task); err != nil {
+		return nil, "", err
+	}
+	s.publishExportEvent(task, format, task.PandocExports[format])
+	return task, task.PandocExports[format], nil
+}
+
+// runPandoc runs pandoc in its sandbox: the Markdown carries text from
+// uploaded documents, and without it an image link such as ![](/etc/passwd)
+// or one to an internal URL would be read and embedded in the export.
+func (s *TaskService) runPandoc(ctx context.Context, args ...string) error {
+	cmd := exec.CommandContext(ctx, s.pandocPath, append([]string{"--sandbox"}, args...)...)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("pandoc 转换失败: %v %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
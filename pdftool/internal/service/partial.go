@@ -0,0 +1,106 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"pdftool/internal/model"
+)
+
+// exportCoverage returns the translated pages as compact ranges ("1-12,15")
+// and the number of pages still waiting for translation.
+func exportCoverage(task *model.Task) (string, int) {
+	var ranges []string
+	start, prev := 0, 0
+	flush := func() {
+		if start == 0 {
+			return
+		}
+		if start == prev {
+			ranges = append(ranges, strconv.Itoa(start))
+		} else {
+			ranges = append(ranges, fmt.Sprintf("%d-%d", start, prev))
+		}
+	}
+	pending := 0
+	for _, page := range task.Pages {
+		if page.Status == model.PageStatusPending {
+			pending++
+		}
+		if !page.HasText || strings.TrimSpace(page.Translation) == "" {
+			continue
+		}
+		if start != 0 && page.PageNumber == prev+1 {
+			prev = page.PageNumber
+			continue
+		}
+		flush()
+		start, prev = page.PageNumber, page.PageNumber
+	}
+	flush()
+	return strings.Join(ranges, ","), pending
+}
+
+// partialNotice is the warning embedded into exports generated while pages
+// are still pending.
+func partialNotice(task *model.Task) (string, bool) {
+	included, pending := exportCoverage(task)
+	if pending == 0 {
+		return "", false
+	}
+	strs := exportStrings(task)
+	if included == "" {
+		included = strs.NoPages
+	}
+	return fmt.Sprintf(strs.PartialNotice, included, pending, task.TotalPages), true
+}
+
+func recordExportProgress(task *model.Task, name, layout string) {
+	included, pending := exportCoverage(task)
+	if task.Exports == nil {
+		task.Exports = make(map[string]*model.ExportProgress)
+	}
+	task.Exports[name] = &model.ExportProgress{
+		Partial:       pending > 0,
+		IncludedPages: included,
+		PendingPages:  pending,
+		Layout:        layout,
+		GeneratedAt:   time.Now(),
+	}
+}
+
+// regeneratePartialExports rebuilds exports that were generated while pages
+// were pending, once a translation run has finished.
+func (s *TaskService) regeneratePartialExports(taskID string) {
+	task, err := s.loadTask(taskID)
+	if err != nil {
+		return
+	}
+	ctx := context.Background()
+	for name, progress := range task.Exports {
+		if progress == nil || !progress.Partial {
+			continue
+		}
+		switch name {
+		case ExportTxt:
+			_, _, err = s.MergeText(ctx, taskID)
+		case ExportPDF:
+			_, _, err = s.MergePDF(ctx, taskID, progress.Layout)
+		case ExportMarkdown:
+			_, _, err = s.MergeMarkdown(ctx, taskID)
+		case ExportDocx:
+			_, _, err = s.MergeDocx(ctx, taskID)
+		case ExportEPUB:
+			_, _, err = s.MergeEpub(ctx, taskID)
+		default:
+			_, _, err = s.ExportPandoc(ctx, taskID, name)
+		}
+		if err != nil {
+			log.Printf("regenerate %s export of task %s failed: %v", name, taskID, err)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"pdftool/internal/eventbus"
+	"pdftool/internal/metrics"
+	"pdftool/internal/model"
+	"pdftool/internal/notify"
+	"pdftool/internal/translator"
+)
+
+// trackTaskFailures updates the task's consecutive failure counter and pauses the
+// task once the streak reaches the configured limit. It reports whether the task
+// was paused by this call.
+func (s *TaskService) trackTaskFailures(task *model.Task, err error) bool {
+	s.mu.Lock()
+	if err == nil {
+		task.FailureStreak = 0
+		s.mu.Unlock()
+		return false
+	}
+	task.FailureStreak++
+	if task.Paused || s.autoPauseStreak <= 0 || task.FailureStreak < s.autoPauseStreak {
+		s.mu.Unlock()
+		return false
+	}
+	task.Paused = true
+	task.PausedAt = time.Now()
+	task.PauseReason = fmt.Sprintf("连续失败 %d 页，最近错误: %v", task.FailureStreak, err)
+	reason := task.PauseReason
+	changeTaskState(task, model.TaskStatePaused, reason)
+	s.mu.Unlock()
+
+	log.Printf("task %s paused: %s", task.ID, reason)
+	s.publishEvent(eventbus.Event{Kind: eventbus.KindPaused, TaskID: task.ID, FileName: task.FileName, Error: reason, Total: task.TotalPages})
+	s.notify(notify.Event{
+		Kind:     notify.EventTaskPaused,
+		TaskID:   task.ID,
+		FileName: task.FileName,
+		Message:  "任务已自动暂停，请检查密钥或额度后恢复：" + reason,
+	})
+	return true
+}
+
+func (s *TaskService) isPaused(task *model.Task) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return task.Paused
+}
+
+// ResumeTask clears the pause flag and re-queues pending and failed pages,
+// optionally with corrected provider settings.
+func (s *TaskService) ResumeTask(ctx context.Context, taskID string, provider translator.ProviderConfig) (*model.Task, error) {
+	task, err := s.loadTask(taskID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkBudget(); err != nil {
+		return nil, err
+	}
+	providerCfg, err := s.mergeProviderConfig(provider, task)
+	if err != nil {
+		return nil, err
+	}
+	providerCfg.OptimizeLayout = true
+	return s.resumeWithProvider(taskID, providerCfg, true)
+}
+
+// resumeWithProvider clears the pause state and re-dispatches pending (and
+// optionally failed) pages; pages imported from OCR files are re-translated as
+// text. The change is applied to the stored task under the lock, and pages a
+// live lease shows are still being translated are left to their worker.
+func (s *TaskService) resumeWithProvider(taskID string, providerCfg translator.ProviderConfig, includeFailed bool) (*model.Task, error) {
+	translatorClient, err := translator.NewTranslator(providerCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var imagePages, textPages []*model.PageResult
+	var textClient translator.TextTranslator
+	task, err := s.updateTask(taskID, func(task *model.Task) error {
+		imagePages, textPages = nil, nil
+		var pages []*model.PageResult
+		for _, page := range task.Pages {
+			if page.Status != model.PageStatusPending && !(includeFailed && page.Status == model.PageStatusError) {
+				continue
+			}
+			if s.pageInFlight(task.ID, page.PageNumber) {
+				continue
+			}
+			pages = append(pages, page)
+		}
+		for _, page := range pages {
+			if isTextPage(page) {
+				textPages = append(textPages, page)
+			} else {
+				imagePages = append(imagePages, page)
+			}
+		}
+		if len(textPages) > 0 && textClient == nil {
+			client, err := translator.NewTextTranslator(providerCfg)
+			if err != nil {
+				return err
+			}
+			textClient = client
+		}
+		s.clearCanceled(task.ID)
+		task.Paused = false
+		task.PausedAt = time.Time{}
+		task.PauseReason = ""
+		task.FailureStreak = 0
+		changeTaskState(task, settledState(task), "")
+		// Keep the old provider on finished pages before switching the task default.
+		if info := providerInfo(providerCfg); info != task.Provider {
+			previous := task.Provider
+			for _, page := range task.Pages {
+				if page.Provider == nil && page.Status == model.PageStatusCompleted && page.HasText {
+					page.Provider = &previous
+				}
+			}
+			task.Provider = info
+		}
+		for _, page := range pages {
+			page.Provider = nil
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		s.translateTaskPages(context.Background(), task, imagePages, translatorClient, 0)
+		if len(textPages) > 0 {
+			s.runPageJobs(task, textPages, 0, func(page *model.PageResult) error {
+				return s.translateTextPage(context.Background(), task, page, textClient)
+			})
+		}
+	}()
+	return task, nil
+}
+
+// failureMetrics exposes failure streaks and paused tasks for alerting.
+// Per-task streaks are folded into their maximum, so the series count stays
+// fixed and /metrics never lists task IDs.
+func (s *TaskService) failureMetrics() []metrics.Sample {
+	s.alerts.mu.Lock()
+	streak := s.alerts.failureStreak
+	s.alerts.mu.Unlock()
+
+	paused, maxStreak := 0, 0
+	if summaries, err := s.ListTasks(); err == nil {
+		for _, summary := range summaries {
+			if summary.Paused {
+				paused++
+			}
+			maxStreak = max(maxStreak, summary.FailureStreak)
+		}
+	}
+	return []metrics.Sample{
+		{Name: "pdftool_provider_failure_streak", Help: "Consecutive provider failures across all tasks.", Value: float64(streak)},
+		{Name: "pdftool_task_failure_streak_max", Help: "Longest run of consecutive failed pages in any task.", Value: float64(maxStreak)},
+		{Name: "pdftool_tasks_paused", Help: "Tasks auto-paused after a failure streak.", Value: float64(paused)},
+		{Name: "pdftool_auto_pause_streak", Help: "Failure streak that pauses a task (0 = disabled).", Value: float64(s.autoPauseStreak)},
+	}
+}
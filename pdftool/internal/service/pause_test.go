@@ -0,0 +1,59 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"pdftool/internal/eventbus"
+	"pdftool/internal/model"
+	"pdftool/internal/translator"
+)
+
+// TestResumeSkipsPagesInFlight checks that re-dispatching a task's pending
+// pages leaves alone a page another worker holds the lease of.
+func TestResumeSkipsPagesInFlight(t *testing.T) {
+	ctx := context.Background()
+	provider := translator.ProviderConfig{Type: translator.ProviderTypeMock}
+	s := newDeterministicService(t)
+	task := createSampleTask(t, s, TranslationSettings{DryRun: true})
+	// Page 1 is being translated by another worker with its own provider.
+	claim, err := s.claimLease(s.pageLeasePath(task.ID, 1))
+	if err != nil || claim == nil {
+		t.Fatalf("claim page 1: %v", err)
+	}
+	worker := &model.ProviderInfo{Type: "gemini", Model: "worker"}
+	if _, err := s.updateTask(task.ID, func(current *model.Task) error {
+		current.Pages[0].Provider = worker
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	events, unsubscribe := s.SubscribeEvents(task.ID)
+	defer unsubscribe()
+	// Starting a dry run re-dispatches its pending pages like a resume.
+	if _, err := s.StartTask(ctx, task.ID, provider); err != nil {
+		t.Fatal(err)
+	}
+	timeout := time.After(10 * time.Second)
+	for done := false; !done; {
+		select {
+		case event := <-events:
+			done = event.Kind == eventbus.KindTaskCompleted
+		case <-timeout:
+			t.Fatal("task run not finished after 10s")
+		}
+	}
+	if task, err = s.GetTask(task.ID); err != nil {
+		t.Fatal(err)
+	}
+	if task.Pages[1].Status != model.PageStatusCompleted || task.Pages[2].Status != model.PageStatusCompleted {
+		t.Fatalf("pages 2 and 3 not translated: %s, %s", task.Pages[1].Status, task.Pages[2].Status)
+	}
+	claim.Release()
+	page := task.Pages[0]
+	if page.Status != model.PageStatusPending || page.Provider == nil || *page.Provider != *worker {
+		t.Fatalf("page 1 in flight was dispatched again: %s, %+v", page.Status, page.Provider)
+	}
+}
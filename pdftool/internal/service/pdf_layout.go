@@ -0,0 +1,235 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"path/filepath"
+	"strings"
+
+	"github.com/jung-kurt/gofpdf"
+
+	"pdftool/internal/model"
+	"pdftool/internal/translator"
+)
+
+// PDF export layouts for pages that have a translation. Pages without text
+// always show the original image.
+const (
+	PDFLayoutText     = "text"     // translation only (default)
+	PDFLayoutStacked  = "stacked"  // original image above the translation on the same page
+	PDFLayoutFacing   = "facing"   // original image page followed by the translation page
+	PDFLayoutAppendix = "appendix" // translations first, all original images at the end
+	PDFLayoutBlocks   = "blocks"   // translation blocks over the original image where their text was
+)
+
+const pdfMargin = 10.0
+
+func validatePDFLayout(layout string) (string, error) {
+	layout = strings.ToLower(strings.TrimSpace(layout))
+	switch layout {
+	case "", PDFLayoutText:
+		return PDFLayoutText, nil
+	case PDFLayoutStacked, PDFLayoutFacing, PDFLayoutAppendix, PDFLayoutBlocks:
+		return layout, nil
+	}
+	return "", fmt.Errorf("不支持的 PDF 版式: %s", layout)
+}
+
+// pdfEntry is one page of the task as it is written to the merged PDF.
+type pdfEntry struct {
+	page   *model.PageResult
+	text   string // export text; empty for image-only pages
+	layout string
+	prefix string // header prefix of image-only pages
+}
+
+// pdfEntries lists the pages of task in output order for layout. Pages
+// without text show the original image; the appendix layout adds the images
+// of translated pages after all translations.
+func pdfEntries(task *model.Task, layout string) []pdfEntry {
+	entries := make([]pdfEntry, 0, len(task.Pages))
+	var appendix []pdfEntry
+	for _, page := range task.Pages {
+		text := pageExportText(task, page)
+		if !page.HasText || text == "" {
+			entries = append(entries, pdfEntry{page: page})
+			continue
+		}
+		entries = append(entries, pdfEntry{page: page, text: text, layout: layout})
+		if layout == PDFLayoutAppendix {
+			appendix = append(appendix, pdfEntry{page: page, prefix: exportStrings(task).OriginalImage})
+		}
+	}
+	return append(entries, appendix...)
+}
+
+// needsImage reports whether the entry embeds the page image.
+func (e pdfEntry) needsImage() bool {
+	return e.text == "" || e.layout == PDFLayoutStacked || e.layout == PDFLayoutFacing || e.layout == PDFLayoutBlocks
+}
+
+func (s *TaskService) writePDFEntry(pdf *gofpdf.Fpdf, fontFamily string, task *model.Task, entry pdfEntry) {
+	switch {
+	case entry.text == "":
+		s.writePDFImagePage(pdf, fontFamily, task, entry.page, entry.prefix)
+	case entry.layout == PDFLayoutStacked:
+		s.writePDFStackedPage(pdf, fontFamily, task, entry.page, entry.text)
+	case entry.layout == PDFLayoutFacing:
+		s.writePDFImagePage(pdf, fontFamily, task, entry.page, "")
+		s.writePDFTextPage(pdf, fontFamily, task, entry.page, entry.text)
+	case entry.layout == PDFLayoutBlocks:
+		s.writePDFBlocksPage(pdf, fontFamily, task, entry.page, entry.text)
+	default:
+		s.writePDFTextPage(pdf, fontFamily, task, entry.page, entry.text)
+	}
+}
+
+func (s *TaskService) writePDFHeader(pdf *gofpdf.Fpdf, fontFamily string, task *model.Task, page *model.PageResult, prefix string) {
+	header, ok := pageHeader(task, page)
+	if !ok {
+		return
+	}
+	s.setFont(pdf, fontFamily, 12)
+	pdf.MultiCell(0, 6, s.encodeText(pdf, fontFamily, prefix+header), "", "L", false)
+	pdf.Ln(2)
+}
+
+func (s *TaskService) writePDFBody(pdf *gofpdf.Fpdf, fontFamily string, page *model.PageResult, text string) {
+	s.setFont(pdf, fontFamily, 11)
+	align := setPDFDirection(pdf, text)
+	defer pdf.LTR()
+	pdf.MultiCell(0, 6, s.encodeText(pdf, fontFamily, plainFootnoteRefs(text)), "", align, false)
+	s.writePDFFootnotes(pdf, fontFamily, page, align)
+}
+
+func (s *TaskService) writePDFTextPage(pdf *gofpdf.Fpdf, fontFamily string, task *model.Task, page *model.PageResult, text string) {
+	pdf.AddPage()
+	s.writePDFHeader(pdf, fontFamily, task, page, "")
+	s.writePDFBody(pdf, fontFamily, page, text)
+}
+
+// writePDFImagePage fills a page with the original page image.
+func (s *TaskService) writePDFImagePage(pdf *gofpdf.Fpdf, fontFamily string, task *model.Task, page *model.PageResult, headerPrefix string) {
+	pdf.AddPage()
+	s.writePDFHeader(pdf, fontFamily, task, page, headerPrefix)
+	pageWidth, pageHeight := pdf.GetPageSize()
+	top := pdf.GetY()
+	s.drawPDFImage(pdf, task, page, pdfMargin, top, pageWidth-pdfMargin*2, pageHeight-top-pdfMargin)
+}
+
+// writePDFStackedPage puts the image in the upper half and the translation below it.
+func (s *TaskService) writePDFStackedPage(pdf *gofpdf.Fpdf, fontFamily string, task *model.Task, page *model.PageResult, text string) {
+	pdf.AddPage()
+	s.writePDFHeader(pdf, fontFamily, task, page, "")
+	pageWidth, pageHeight := pdf.GetPageSize()
+	top := pdf.GetY()
+	height := s.drawPDFImage(pdf, task, page, pdfMargin, top, pageWidth-pdfMargin*2, (pageHeight-pdfMargin*2)/2)
+	pdf.SetY(top + height + 4)
+	s.writePDFBody(pdf, fontFamily, page, text)
+}
+
+// writePDFBlocksPage draws the original page image and covers each text
+// block with its translation, shrunk to fit the block. Pages translated
+// without blocks get a text page.
+func (s *TaskService) writePDFBlocksPage(pdf *gofpdf.Fpdf, fontFamily string, task *model.Task, page *model.PageResult, text string) {
+	if len(page.Blocks) == 0 {
+		s.writePDFTextPage(pdf, fontFamily, task, page, text)
+		return
+	}
+	pdf.AddPage()
+	pageWidth, pageHeight := pdf.GetPageSize()
+	maxW, maxH := pageWidth-pdfMargin*2, pageHeight-pdfMargin*2
+	width, height := fitImage(page, maxW, maxH)
+	if width == 0 || height == 0 {
+		width, height = maxW, maxH
+	}
+	s.drawPDFImage(pdf, task, page, pdfMargin, pdfMargin, maxW, maxH)
+
+	// Blocks at the foot of the page must not spill onto a new one.
+	auto, margin := pdf.GetAutoPageBreak()
+	pdf.SetAutoPageBreak(false, 0)
+	defer pdf.SetAutoPageBreak(auto, margin)
+	pdf.SetFillColor(255, 255, 255)
+	for _, block := range page.Blocks {
+		x := pdfMargin + block.BBox[0]/translator.BlockScale*width
+		y := pdfMargin + block.BBox[1]/translator.BlockScale*height
+		w := (block.BBox[2] - block.BBox[0]) / translator.BlockScale * width
+		h := (block.BBox[3] - block.BBox[1]) / translator.BlockScale * height
+		pdf.Rect(x, y, w, h, "F")
+		blockText := s.encodeText(pdf, fontFamily, plainFootnoteRefs(block.Translation))
+		lineHeight := s.fitPDFBlock(pdf, fontFamily, blockText, w, h)
+		align := setPDFDirection(pdf, block.Translation)
+		pdf.SetXY(x, y)
+		pdf.MultiCell(w, lineHeight, blockText, "", align, false)
+		pdf.LTR()
+	}
+}
+
+// Font sizes, in points, tried for block translations.
+const (
+	pdfBlockMaxFont = 11.0
+	pdfBlockMinFont = 5.0
+)
+
+// fitPDFBlock sets the largest font size at which text fits a w by h block
+// and returns its line height. Text that does not fit at the smallest size
+// overflows the block.
+func (s *TaskService) fitPDFBlock(pdf *gofpdf.Fpdf, fontFamily, text string, w, h float64) float64 {
+	size := pdfBlockMaxFont
+	for ; ; size -= 0.5 {
+		s.setFont(pdf, fontFamily, size)
+		lineHeight := size * 1.2 * 25.4 / 72
+		if size <= pdfBlockMinFont || float64(pdfBlockLines(pdf, text, w))*lineHeight <= h {
+			return lineHeight
+		}
+	}
+}
+
+// pdfBlockLines estimates the lines text wraps to in a cell w wide, leaving
+// some room for the words wrapping leaves at line ends.
+func pdfBlockLines(pdf *gofpdf.Fpdf, text string, w float64) int {
+	width := max(w-2, 1) // cells are padded by 1 mm on each side
+	lines := 0
+	for _, line := range strings.Split(text, "\n") {
+		lines += max(1, int(math.Ceil(pdf.GetStringWidth(line)*1.1/width)))
+	}
+	return lines
+}
+
+// drawPDFImage fits the page image into the box and returns the drawn height.
+// Images registered ahead under the page's image path (see
+// prefetchPDFImages) are used as they are.
+func (s *TaskService) drawPDFImage(pdf *gofpdf.Fpdf, task *model.Task, page *model.PageResult, x, y, maxW, maxH float64) float64 {
+	opt := gofpdf.ImageOptions{
+		ImageType: pdfImageType(page.ImagePath),
+		ReadDpi:   true,
+	}
+	imagePath := page.ImagePath
+	if pdf.GetImageInfo(imagePath) == nil {
+		plain, cleanup, err := s.plainFile(page.ImagePath)
+		defer cleanup()
+		if err != nil {
+			log.Printf("embed image failed (page %d): %v", page.PageNumber, err)
+			pdf.MultiCell(0, 6, exportStrings(task).ImageUnavailable, "", "L", false)
+			return 6
+		}
+		imagePath = plain
+		if strings.EqualFold(filepath.Ext(page.ImagePath), ".webp") {
+			registerTranscodedImage(pdf, imagePath, opt)
+		}
+	}
+	displayW, displayH := fitImage(page, maxW, maxH)
+	if displayW == 0 || displayH == 0 {
+		displayW = maxW
+		displayH = maxH
+	}
+	pdf.ImageOptions(imagePath, x, y, displayW, displayH, false, opt, 0, "")
+	if err := pdf.Error(); err != nil {
+		log.Printf("embed image failed (page %d): %v", page.PageNumber, err)
+		pdf.ClearError()
+		pdf.MultiCell(0, 6, exportStrings(task).ImageUnavailable, "", "L", false)
+		return 6
+	}
+	return displayH
+}
@@ -0,0 +1,133 @@
+package service
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jung-kurt/gofpdf"
+
+	"pdftool/internal/model"
+)
+
+// pdfPrefetchWindow bounds the page images loaded ahead of the PDF writer,
+// which is also the number of images loaded at once.
+const pdfPrefetchWindow = 8
+
+// preparedImage is a page image ready to be registered with gofpdf.
+type preparedImage struct {
+	data          []byte
+	imageType     string
+	width, height int
+	err           error
+}
+
+// pdfImagePrefetch loads the page images of a merged PDF on several
+// goroutines while the document is assembled. Reading, decrypting and
+// transcoding the images is most of the work of a large export, and gofpdf
+// itself cannot be used concurrently, so only the loading runs in parallel;
+// images are handed to the writer in output order.
+type pdfImagePrefetch struct {
+	results []chan preparedImage
+	window  chan struct{}
+	done    chan struct{}
+	next    int
+}
+
+// prefetchPDFImages starts loading the images of the entries that embed one.
+// Callers must call stop when done.
+func (s *TaskService) prefetchPDFImages(entries []pdfEntry) *pdfImagePrefetch {
+	var pages []*model.PageResult
+	for _, entry := range entries {
+		if entry.needsImage() {
+			pages = append(pages, entry.page)
+		}
+	}
+	p := &pdfImagePrefetch{
+		results: make([]chan preparedImage, len(pages)),
+		window:  make(chan struct{}, pdfPrefetchWindow),
+		done:    make(chan struct{}),
+	}
+	for i := range p.results {
+		p.results[i] = make(chan preparedImage, 1)
+	}
+	go func() {
+		for i, page := range pages {
+			select {
+			case p.window <- struct{}{}:
+			case <-p.done:
+				return
+			}
+			go func(i int, path string) {
+				p.results[i] <- s.preparePDFImage(path)
+			}(i, page.ImagePath)
+		}
+	}()
+	return p
+}
+
+// register waits for the next prefetched image, which belongs to page, and
+// registers it under the page's image path. Images that failed to load are
+// left to drawPDFImage, which reports them.
+func (p *pdfImagePrefetch) register(pdf *gofpdf.Fpdf, page *model.PageResult) {
+	if p.next >= len(p.results) {
+		return
+	}
+	img := <-p.results[p.next]
+	p.next++
+	<-p.window
+	if img.err != nil {
+		return
+	}
+	if page.ImageWidth <= 0 || page.ImageHeight <= 0 {
+		page.ImageWidth, page.ImageHeight = img.width, img.height
+	}
+	opt := gofpdf.ImageOptions{ImageType: img.imageType, ReadDpi: true}
+	pdf.RegisterImageOptionsReader(page.ImagePath, opt, bytes.NewReader(img.data))
+	if err := pdf.Error(); err != nil {
+		log.Printf("embed image failed (page %d): %v", page.PageNumber, err)
+		pdf.ClearError()
+	}
+}
+
+// stop abandons the images not yet loaded.
+func (p *pdfImagePrefetch) stop() {
+	close(p.done)
+}
+
+// preparePDFImage reads and, if needed, decrypts the image at path. WebP
+// images are transcoded to PNG, which gofpdf can embed.
+func (s *TaskService) preparePDFImage(path string) preparedImage {
+	plain, cleanup, err := s.plainFile(path)
+	defer cleanup()
+	if err != nil {
+		return preparedImage{err: err}
+	}
+	data, err := os.ReadFile(plain)
+	if err != nil {
+		return preparedImage{err: err}
+	}
+	img := preparedImage{data: data, imageType: pdfImageType(path)}
+	if strings.EqualFold(filepath.Ext(path), ".webp") {
+		decoded, _, err := image.Decode(bytes.NewReader(data))
+		if err != nil {
+			return preparedImage{err: err}
+		}
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, decoded); err != nil {
+			return preparedImage{err: err}
+		}
+		bounds := decoded.Bounds()
+		img.data, img.imageType = buf.Bytes(), "PNG"
+		img.width, img.height = bounds.Dx(), bounds.Dy()
+		return img
+	}
+	if cfg, _, err := image.DecodeConfig(bytes.NewReader(data)); err == nil {
+		img.width, img.height = cfg.Width, cfg.Height
+	}
+	return img
+}
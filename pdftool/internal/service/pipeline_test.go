@@ -0,0 +1,173 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gen2brain/go-fitz"
+
+	"pdftool/internal/model"
+	"pdftool/internal/translator"
+)
+
+// samplePDF is a three-page A6 document with one heading and one sentence per
+// page.
+const samplePDF = "testdata/sample.pdf"
+
+// TestPipeline runs the whole flow against a temp storage dir: render the
+// sample PDF, translate it with the mock provider, merge TXT and PDF, run AI
+// layout and the consistency pass and retranslate a page, checking the task
+// state after each step.
+func TestPipeline(t *testing.T) {
+	ctx := context.Background()
+	provider := translator.ProviderConfig{Type: translator.ProviderTypeMock}
+	s, err := NewTaskService(t.TempDir(), "/files", "", provider, 2, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	file, err := os.Open(samplePDF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	created, err := s.CreateTask(ctx, file, "sample.pdf", provider, TranslationSettings{})
+	if err != nil {
+		t.Fatalf("create task: %v", err)
+	}
+	// The returned task is shared with the translation workers; inspect a
+	// stored copy instead.
+	task, err := s.GetTask(created.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if task.TotalPages != 3 || len(task.Pages) != 3 {
+		t.Fatalf("rendered %d pages (%d results), want 3", task.TotalPages, len(task.Pages))
+	}
+	for _, page := range task.Pages {
+		if _, err := os.Stat(page.ImagePath); err != nil {
+			t.Errorf("page %d image: %v", page.PageNumber, err)
+		}
+		if page.ImageWidth == 0 || page.ImageHeight == 0 {
+			t.Errorf("page %d has no image size", page.PageNumber)
+		}
+	}
+
+	task = waitForState(t, s, task.ID, model.TaskStateCompleted)
+	assertStatePath(t, task, model.TaskStateRendering, model.TaskStateQueued, model.TaskStateTranslating, model.TaskStateCompleted)
+	for _, page := range task.Pages {
+		want := fmt.Sprintf("【模拟译文 第 %d 页】", page.PageNumber)
+		if page.Status != model.PageStatusCompleted || !strings.HasPrefix(page.Translation, want) {
+			t.Errorf("page %d: status %s, translation %q", page.PageNumber, page.Status, page.Translation)
+		}
+	}
+
+	task, _, err = s.MergeText(ctx, task.ID)
+	if err != nil {
+		t.Fatalf("merge text: %v", err)
+	}
+	combined := readFile(t, task.CombinedTxtPath)
+	for n := 1; n <= 3; n++ {
+		if !strings.Contains(combined, fmt.Sprintf("【模拟译文 第 %d 页】", n)) {
+			t.Errorf("combined TXT misses page %d:\n%s", n, combined)
+		}
+	}
+
+	task, _, err = s.MergePDF(ctx, task.ID, PDFLayoutFacing)
+	if err != nil {
+		t.Fatalf("merge pdf: %v", err)
+	}
+	doc, err := fitz.New(task.CombinedPDFPath)
+	if err != nil {
+		t.Fatalf("open merged pdf: %v", err)
+	}
+	pages := doc.NumPage()
+	doc.Close()
+	if pages != 6 {
+		t.Errorf("facing PDF has %d pages, want 6", pages)
+	}
+
+	task, _, err = s.FormatTaskLayout(ctx, task.ID, provider)
+	if err != nil {
+		t.Fatalf("format: %v", err)
+	}
+	if task.FormattingInProgress || taskState(task) != model.TaskStateCompleted {
+		t.Errorf("after format: state %s, in progress %v", taskState(task), task.FormattingInProgress)
+	}
+	if formatted := readFile(t, task.FormattedTxtPath); !strings.Contains(formatted, "【模拟译文 第 2 页】") {
+		t.Errorf("formatted TXT misses page 2:\n%s", formatted)
+	}
+	assertStatePath(t, task, model.TaskStateCompleted, model.TaskStateFormatting, model.TaskStateCompleted)
+
+	task, _, err = s.ConsistencyPass(ctx, task.ID, provider)
+	if err != nil {
+		t.Fatalf("consistency pass: %v", err)
+	}
+	if consistent := readFile(t, task.ConsistentTxtPath); !strings.Contains(consistent, "【模拟译文 第 3 页】") {
+		t.Errorf("consistent TXT misses page 3:\n%s", consistent)
+	}
+
+	if _, _, err := s.RetranslatePage(ctx, task.ID, 2, provider); err != nil {
+		t.Fatalf("retranslate: %v", err)
+	}
+	task = waitForState(t, s, task.ID, model.TaskStateCompleted)
+	if page := task.Pages[1]; page.Status != model.PageStatusCompleted || page.Translation == "" {
+		t.Errorf("retranslated page: status %s, translation %q", page.Status, page.Translation)
+	}
+}
+
+// waitForState polls the task until it reaches want, failing on another
+// settled state or after ten seconds.
+func waitForState(t *testing.T, s *TaskService, taskID string, want model.TaskState) *model.Task {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		task, err := s.GetTask(taskID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		state := taskState(task)
+		switch state {
+		case want:
+			return task
+		case model.TaskStateFailed, model.TaskStateCanceled, model.TaskStatePaused:
+			t.Fatalf("task settled in %s, want %s: %+v", state, want, task.StateHistory)
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("task still %s after 10s, want %s", state, want)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// assertStatePath checks that the recorded state history contains the given
+// states in order, and that every recorded transition was an allowed one.
+func assertStatePath(t *testing.T, task *model.Task, path ...model.TaskState) {
+	t.Helper()
+	next := 0
+	for i, entry := range task.StateHistory {
+		if i > 0 && !transitionAllowed(entry.From, entry.To) {
+			t.Errorf("history has invalid transition %s -> %s", entry.From, entry.To)
+		}
+		if next < len(path) && entry.To == path[next] {
+			next++
+		}
+	}
+	if next < len(path) {
+		t.Errorf("state history %+v does not pass through %v", task.StateHistory, path)
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
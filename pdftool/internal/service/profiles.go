@@ -0,0 +1,201 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"pdftool/internal/model"
+	"pdftool/internal/translator"
+)
+
+const (
+	profilesFile      = "profiles.json"
+	maxProfileNameLen = 64
+)
+
+func (s *TaskService) profilesPath() string {
+	return filepath.Join(s.storageDir, profilesFile)
+}
+
+func (s *TaskService) loadProfilesLocked() (map[string]*model.SettingsProfile, error) {
+	profiles := make(map[string]*model.SettingsProfile)
+	data, err := os.ReadFile(s.profilesPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return profiles, nil
+		}
+		return nil, fmt.Errorf("读取设置方案失败: %w", err)
+	}
+	if err := json.Unmarshal(data, &profiles); err != nil {
+		return nil, fmt.Errorf("解析设置方案失败: %w", err)
+	}
+	return profiles, nil
+}
+
+func (s *TaskService) saveProfilesLocked(profiles map[string]*model.SettingsProfile) error {
+	data, err := json.MarshalIndent(profiles, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.profilesPath() + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("写入设置方案失败: %w", err)
+	}
+	return os.Rename(tmp, s.profilesPath())
+}
+
+// ListProfiles returns the saved settings profiles sorted by name.
+func (s *TaskService) ListProfiles() ([]*model.SettingsProfile, error) {
+	s.mu.Lock()
+	profiles, err := s.loadProfilesLocked()
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	list := make([]*model.SettingsProfile, 0, len(profiles))
+	for _, profile := range profiles {
+		list = append(list, profile)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list, nil
+}
+
+// GetProfile loads a settings profile by name.
+func (s *TaskService) GetProfile(name string) (*model.SettingsProfile, error) {
+	s.mu.Lock()
+	profiles, err := s.loadProfilesLocked()
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	profile, ok := profiles[strings.TrimSpace(name)]
+	if !ok {
+		return nil, fmt.Errorf("设置方案不存在: %s", name)
+	}
+	return profile, nil
+}
+
+// SaveProfile creates or replaces a named settings profile.
+func (s *TaskService) SaveProfile(profile model.SettingsProfile) (*model.SettingsProfile, error) {
+	profile.Name = strings.TrimSpace(profile.Name)
+	if profile.Name == "" {
+		return nil, fmt.Errorf("设置方案名称不能为空")
+	}
+	if utf8.RuneCountInString(profile.Name) > maxProfileNameLen {
+		return nil, fmt.Errorf("设置方案名称过长（最多 %d 个字符）", maxProfileNameLen)
+	}
+	if profile.ProviderType != "" {
+		profile.ProviderType = string(translator.NormalizeProviderType(profile.ProviderType))
+	}
+	profile.OutputDestination = strings.TrimSpace(profile.OutputDestination)
+	if profile.OutputDestination != "" {
+		if err := s.publisher.Validate(profile.OutputDestination); err != nil {
+			return nil, err
+		}
+	}
+	var err error
+	if profile.LayoutMode, err = validateLayoutMode(profile.LayoutMode); err != nil {
+		return nil, err
+	}
+	if profile.WritingMode, err = validateWritingMode(profile.WritingMode); err != nil {
+		return nil, err
+	}
+	if profile.TargetLanguage, err = validateTargetLanguage(profile.TargetLanguage); err != nil {
+		return nil, err
+	}
+	if profile.BatchLimit < 0 {
+		profile.BatchLimit = 0
+	}
+	if settings := profile.ExportSettings; settings != nil {
+		if err := s.validateExportSettings(settings); err != nil {
+			return nil, err
+		}
+	}
+	profile.UpdatedAt = time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	profiles, err := s.loadProfilesLocked()
+	if err != nil {
+		return nil, err
+	}
+	profiles[profile.Name] = &profile
+	if err := s.saveProfilesLocked(profiles); err != nil {
+		return nil, err
+	}
+	return &profile, nil
+}
+
+// DeleteProfile removes a settings profile. Tasks created from it keep their settings.
+func (s *TaskService) DeleteProfile(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	profiles, err := s.loadProfilesLocked()
+	if err != nil {
+		return err
+	}
+	name = strings.TrimSpace(name)
+	if _, ok := profiles[name]; !ok {
+		return fmt.Errorf("设置方案不存在: %s", name)
+	}
+	delete(profiles, name)
+	return s.saveProfilesLocked(profiles)
+}
+
+// applyProfile fills provider and task settings the request left empty.
+func applyProfile(profile *model.SettingsProfile, provider translator.ProviderConfig, settings TranslationSettings) (translator.ProviderConfig, TranslationSettings) {
+	if strings.TrimSpace(string(provider.Type)) == "" && profile.ProviderType != "" {
+		provider.Type = translator.ProviderType(profile.ProviderType)
+	}
+	if strings.TrimSpace(provider.BaseURL) == "" {
+		provider.BaseURL = profile.ProviderBase
+	}
+	if strings.TrimSpace(provider.Model) == "" {
+		provider.Model = profile.ProviderModel
+	}
+	if provider.MaxTokens <= 0 {
+		provider.MaxTokens = profile.ProviderMaxTokens
+	}
+	if strings.TrimSpace(settings.RangeMode) == "" {
+		settings.RangeMode = profile.RangeMode
+		if settings.RangeCustom <= 0 {
+			settings.RangeCustom = profile.RangeCustom
+		}
+		if strings.TrimSpace(settings.RangePages) == "" {
+			settings.RangePages = profile.RangePages
+		}
+	}
+	if strings.TrimSpace(settings.RangeExclude) == "" {
+		settings.RangeExclude = profile.RangeExclude
+	}
+	if settings.BatchLimit <= 0 {
+		settings.BatchLimit = profile.BatchLimit
+	}
+	if strings.TrimSpace(settings.OutputDestination) == "" {
+		settings.OutputDestination = profile.OutputDestination
+	}
+	if strings.TrimSpace(settings.LayoutMode) == "" {
+		settings.LayoutMode = profile.LayoutMode
+	}
+	if strings.TrimSpace(settings.WritingMode) == "" {
+		settings.WritingMode = profile.WritingMode
+	}
+	if strings.TrimSpace(settings.TargetLanguage) == "" && strings.TrimSpace(provider.TargetLanguage) == "" {
+		settings.TargetLanguage = profile.TargetLanguage
+	}
+	settings.PreferTextLayer = settings.PreferTextLayer || profile.PreferTextLayer
+	settings.ExtractFigures = settings.ExtractFigures || profile.ExtractFigures
+	if settings.ExportSettings == nil && profile.ExportSettings != nil {
+		exportSettings := *profile.ExportSettings
+		exportSettings.Formats = append([]string(nil), exportSettings.Formats...)
+		settings.ExportSettings = &exportSettings
+	}
+	settings.Profile = profile.Name
+	return provider, settings
+}
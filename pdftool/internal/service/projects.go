@@ -0,0 +1,384 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+
+	"pdftool/internal/model"
+	"pdftool/internal/translator"
+)
+
+const (
+	projectsFile         = "projects.json"
+	projectsDir          = ".projects"
+	maxProjectNameLen    = 128
+	maxGlossaryEntries   = 500
+	maxGlossaryHintTerms = 200
+)
+
+func (s *TaskService) projectsPath() string {
+	return filepath.Join(s.storageDir, projectsFile)
+}
+
+func (s *TaskService) projectDir(projectID string) string {
+	return filepath.Join(s.storageDir, projectsDir, projectID)
+}
+
+func (s *TaskService) loadProjectsLocked() (map[string]*model.Project, error) {
+	projects := make(map[string]*model.Project)
+	data, err := os.ReadFile(s.projectsPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return projects, nil
+		}
+		return nil, fmt.Errorf("读取项目失败: %w", err)
+	}
+	if err := json.Unmarshal(data, &projects); err != nil {
+		return nil, fmt.Errorf("解析项目失败: %w", err)
+	}
+	return projects, nil
+}
+
+func (s *TaskService) saveProjectsLocked(projects map[string]*model.Project) error {
+	data, err := json.MarshalIndent(projects, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.projectsPath() + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("写入项目失败: %w", err)
+	}
+	return os.Rename(tmp, s.projectsPath())
+}
+
+func (s *TaskService) loadProject(projectID string) (*model.Project, error) {
+	s.mu.Lock()
+	projects, err := s.loadProjectsLocked()
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	project, ok := projects[strings.TrimSpace(projectID)]
+	if !ok {
+		return nil, fmt.Errorf("项目不存在: %s", projectID)
+	}
+	return project, nil
+}
+
+// ListProjects returns all projects with their progress, newest first.
+func (s *TaskService) ListProjects() ([]*model.ProjectResponse, error) {
+	s.mu.Lock()
+	projects, err := s.loadProjectsLocked()
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	summaries, err := s.taskSummaries()
+	if err != nil {
+		return nil, err
+	}
+	list := make([]*model.ProjectResponse, 0, len(projects))
+	for _, project := range projects {
+		list = append(list, s.projectResponse(project, summaries))
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.After(list[j].CreatedAt) })
+	return list, nil
+}
+
+// GetProject returns a project with the progress of its tasks.
+func (s *TaskService) GetProject(projectID string) (*model.ProjectResponse, error) {
+	project, err := s.loadProject(projectID)
+	if err != nil {
+		return nil, err
+	}
+	summaries, err := s.taskSummaries()
+	if err != nil {
+		return nil, err
+	}
+	return s.projectResponse(project, summaries), nil
+}
+
+// CreateProject stores a new project. Listed tasks join it in the given order.
+func (s *TaskService) CreateProject(project model.Project) (*model.ProjectResponse, error) {
+	project.ID = uuid.NewString()
+	project.CreatedAt = time.Now()
+	return s.storeProject(project)
+}
+
+// UpdateProject replaces the name, description, profile, task order and
+// glossary of a project. Tasks dropped from the list leave the project but
+// are not deleted.
+func (s *TaskService) UpdateProject(projectID string, project model.Project) (*model.ProjectResponse, error) {
+	existing, err := s.loadProject(projectID)
+	if err != nil {
+		return nil, err
+	}
+	project.ID = existing.ID
+	project.CreatedAt = existing.CreatedAt
+	return s.storeProject(project)
+}
+
+func (s *TaskService) storeProject(project model.Project) (*model.ProjectResponse, error) {
+	if err := s.validateProject(&project); err != nil {
+		return nil, err
+	}
+	project.UpdatedAt = time.Now()
+
+	s.mu.Lock()
+	projects, err := s.loadProjectsLocked()
+	if err != nil {
+		s.mu.Unlock()
+		return nil, err
+	}
+	for _, other := range projects {
+		if other.ID == project.ID {
+			continue
+		}
+		for _, taskID := range project.TaskIDs {
+			if slices.Contains(other.TaskIDs, taskID) {
+				s.mu.Unlock()
+				return nil, fmt.Errorf("任务 %s 已属于项目 %s", taskID, other.Name)
+			}
+		}
+	}
+	var previous []string
+	if old := projects[project.ID]; old != nil {
+		previous = old.TaskIDs
+	}
+	projects[project.ID] = &project
+	err = s.saveProjectsLocked(projects)
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, taskID := range previous {
+		if !slices.Contains(project.TaskIDs, taskID) {
+			s.setTaskProject(taskID, "")
+		}
+	}
+	for _, taskID := range project.TaskIDs {
+		s.setTaskProject(taskID, project.ID)
+	}
+	return s.GetProject(project.ID)
+}
+
+// DeleteProject removes a project and its exports. Its tasks are kept.
+func (s *TaskService) DeleteProject(projectID string) error {
+	s.mu.Lock()
+	projects, err := s.loadProjectsLocked()
+	if err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	projectID = strings.TrimSpace(projectID)
+	project, ok := projects[projectID]
+	if !ok {
+		s.mu.Unlock()
+		return fmt.Errorf("项目不存在: %s", projectID)
+	}
+	delete(projects, projectID)
+	err = s.saveProjectsLocked(projects)
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	for _, taskID := range project.TaskIDs {
+		s.setTaskProject(taskID, "")
+	}
+	return os.RemoveAll(s.projectDir(projectID))
+}
+
+// ExportProject merges the translations of the project's tasks, in project
+// order, into one TXT, Markdown, PDF or EPUB file and returns its file name.
+func (s *TaskService) ExportProject(ctx context.Context, projectID, format string) (*model.ProjectResponse, string, error) {
+	project, err := s.loadProject(projectID)
+	if err != nil {
+		return nil, "", err
+	}
+	tasks := make([]*model.Task, 0, len(project.TaskIDs))
+	for _, taskID := range project.TaskIDs {
+		if task, err := s.loadTask(taskID); err == nil {
+			tasks = append(tasks, task)
+		}
+	}
+	if len(tasks) == 0 {
+		return nil, "", fmt.Errorf("项目中没有任务")
+	}
+	dir := s.projectDir(project.ID)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, "", fmt.Errorf("创建项目目录失败: %w", err)
+	}
+	name, err := s.writeTasksExport(ctx, dir, project.Name, format, tasks)
+	if err != nil {
+		return nil, "", err
+	}
+	resp, err := s.GetProject(project.ID)
+	if err != nil {
+		return nil, "", err
+	}
+	return resp, name, nil
+}
+
+// ProjectExportFile returns the path of a generated project export.
+func (s *TaskService) ProjectExportFile(projectID, name string) (string, error) {
+	project, err := s.loadProject(projectID)
+	if err != nil {
+		return "", err
+	}
+	return existingCombinedExport(s.projectDir(project.ID), name)
+}
+
+// withGlossaryHint adds the glossary of the task's project to the prompt.
+func (s *TaskService) withGlossaryHint(ctx context.Context, task *model.Task) context.Context {
+	if task.Project == "" {
+		return ctx
+	}
+	project, err := s.loadProject(task.Project)
+	if err != nil || len(project.Glossary) == 0 {
+		return ctx
+	}
+	entries := project.Glossary
+	if len(entries) > maxGlossaryHintTerms {
+		entries = entries[:maxGlossaryHintTerms]
+	}
+	terms := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		term := entry.Term + " → " + entry.Translation
+		if entry.Note != "" {
+			term += "（" + entry.Note + "）"
+		}
+		terms = append(terms, term)
+	}
+	return translator.WithPromptHint(ctx, "术语表：以下术语请统一按给定译法翻译："+strings.Join(terms, "；")+"。")
+}
+
+func (s *TaskService) validateProject(project *model.Project) error {
+	project.Name = strings.TrimSpace(project.Name)
+	if project.Name == "" {
+		return fmt.Errorf("项目名称不能为空")
+	}
+	if utf8.RuneCountInString(project.Name) > maxProjectNameLen {
+		return fmt.Errorf("项目名称过长（最多 %d 个字符）", maxProjectNameLen)
+	}
+	project.Description = strings.TrimSpace(project.Description)
+	project.Profile = strings.TrimSpace(project.Profile)
+	if project.Profile != "" {
+		if _, err := s.GetProfile(project.Profile); err != nil {
+			return err
+		}
+	}
+
+	taskIDs := make([]string, 0, len(project.TaskIDs))
+	for _, taskID := range project.TaskIDs {
+		taskID = strings.TrimSpace(taskID)
+		if taskID == "" || slices.Contains(taskIDs, taskID) {
+			continue
+		}
+		if _, err := s.loadTask(taskID); err != nil {
+			return fmt.Errorf("任务不存在: %s", taskID)
+		}
+		taskIDs = append(taskIDs, taskID)
+	}
+	project.TaskIDs = taskIDs
+
+	glossary := make([]model.GlossaryEntry, 0, len(project.Glossary))
+	seen := make(map[string]bool)
+	for _, entry := range project.Glossary {
+		entry.Term = strings.TrimSpace(entry.Term)
+		entry.Translation = strings.TrimSpace(entry.Translation)
+		entry.Note = strings.TrimSpace(entry.Note)
+		if entry.Term == "" {
+			continue
+		}
+		if entry.Translation == "" {
+			return fmt.Errorf("术语 %q 缺少译法", entry.Term)
+		}
+		if seen[entry.Term] {
+			return fmt.Errorf("术语 %q 重复", entry.Term)
+		}
+		seen[entry.Term] = true
+		glossary = append(glossary, entry)
+	}
+	if len(glossary) > maxGlossaryEntries {
+		return fmt.Errorf("术语表过长（最多 %d 条）", maxGlossaryEntries)
+	}
+	project.Glossary = glossary
+	return nil
+}
+
+// addTaskToProject appends a newly created task to the end of a project.
+func (s *TaskService) addTaskToProject(projectID, taskID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	projects, err := s.loadProjectsLocked()
+	if err != nil {
+		return err
+	}
+	project, ok := projects[projectID]
+	if !ok {
+		return fmt.Errorf("项目不存在: %s", projectID)
+	}
+	project.TaskIDs = append(project.TaskIDs, taskID)
+	project.UpdatedAt = time.Now()
+	return s.saveProjectsLocked(projects)
+}
+
+func (s *TaskService) setTaskProject(taskID, projectID string) {
+	_, _ = s.updateTask(taskID, func(task *model.Task) error {
+		task.Project = projectID
+		return nil
+	})
+}
+
+// taskSummaries returns the task index keyed by task ID.
+func (s *TaskService) taskSummaries() (map[string]*model.TaskSummary, error) {
+	list, err := s.ListTasks()
+	if err != nil {
+		return nil, err
+	}
+	summaries := make(map[string]*model.TaskSummary, len(list))
+	for _, summary := range list {
+		summaries[summary.ID] = summary
+	}
+	return summaries, nil
+}
+
+func (s *TaskService) projectResponse(project *model.Project, summaries map[string]*model.TaskSummary) *model.ProjectResponse {
+	resp := &model.ProjectResponse{Project: project, Tasks: make([]*model.TaskSummary, 0, len(project.TaskIDs))}
+	for _, taskID := range project.TaskIDs {
+		summary, ok := summaries[taskID]
+		if !ok {
+			resp.MissingTasks = append(resp.MissingTasks, taskID)
+			continue
+		}
+		resp.Tasks = append(resp.Tasks, summary)
+		resp.TotalPages += summary.TotalPages
+		resp.CompletedPages += summary.CompletedPages
+		resp.PendingPages += summary.PendingPages
+		resp.ErrorPages += summary.ErrorPages
+	}
+	if resp.TotalPages > 0 {
+		resp.Progress = float64(resp.CompletedPages) / float64(resp.TotalPages)
+	}
+	for format, ext := range combinedExportExts {
+		name := combinedExportBaseName + ext
+		if _, err := os.Stat(filepath.Join(s.projectDir(project.ID), name)); err == nil {
+			if resp.Exports == nil {
+				resp.Exports = make(map[string]string)
+			}
+			resp.Exports[format] = name
+		}
+	}
+	return resp
+}
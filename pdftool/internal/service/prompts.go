@@ -0,0 +1,194 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode"
+
+	"pdftool/internal/model"
+	"pdftool/internal/translator"
+)
+
+const (
+	promptsFile    = "prompts.json"
+	maxPromptRunes = 20000
+	// maxLanguageRunes bounds a target language name, which is pasted into
+	// every prompt.
+	maxLanguageRunes = 40
+)
+
+// promptSettings holds the prompt overrides from the config file and the
+// admin API.
+type promptSettings struct {
+	mu    sync.RWMutex
+	file  model.PromptSettings
+	admin model.PromptSettings
+}
+
+func (s *TaskService) promptsPath() string {
+	return filepath.Join(s.storageDir, promptsFile)
+}
+
+// loadPrompts reads the configured prompts file and the overrides saved via
+// the admin API.
+func (s *TaskService) loadPrompts(path string) error {
+	if path = strings.TrimSpace(path); path != "" {
+		settings, err := readPromptSettings(path)
+		if err != nil {
+			return fmt.Errorf("读取提示词配置失败: %w", err)
+		}
+		s.prompts.file = settings
+	}
+	settings, err := readPromptSettings(s.promptsPath())
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("读取提示词设置失败: %w", err)
+	}
+	s.prompts.admin = settings
+	return nil
+}
+
+func readPromptSettings(path string) (model.PromptSettings, error) {
+	var settings model.PromptSettings
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return settings, err
+	}
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return settings, err
+	}
+	return settings, validatePromptSettings(&settings)
+}
+
+func validatePromptSettings(settings *model.PromptSettings) error {
+	for _, field := range promptFields(settings) {
+		*field.value = strings.TrimSpace(*field.value)
+		if len([]rune(*field.value)) > maxPromptRunes {
+			return fmt.Errorf("提示词 %s 不能超过 %d 个字符", field.name, maxPromptRunes)
+		}
+	}
+	return nil
+}
+
+// validateTargetLanguage trims a target language name. Empty keeps the
+// default (Simplified Chinese).
+func validateTargetLanguage(language string) (string, error) {
+	language = strings.TrimSpace(language)
+	if len([]rune(language)) > maxLanguageRunes {
+		return "", fmt.Errorf("目标语言不能超过 %d 个字符", maxLanguageRunes)
+	}
+	if strings.IndexFunc(language, unicode.IsControl) >= 0 {
+		return "", errors.New("目标语言不能包含控制字符")
+	}
+	return language, nil
+}
+
+type promptField struct {
+	name  string
+	value *string
+}
+
+func promptFields(settings *model.PromptSettings) []promptField {
+	return []promptField{
+		{"ocrSystem", &settings.OCRSystem},
+		{"ocrUser", &settings.OCRUser},
+		{"textSystem", &settings.TextSystem},
+		{"formatterSystem", &settings.FormatterSystem},
+		{"extra", &settings.Extra},
+	}
+}
+
+// mergedPrompts layers the admin overrides over the config file field by field.
+func (s *TaskService) mergedPrompts() model.PromptSettings {
+	s.prompts.mu.RLock()
+	defer s.prompts.mu.RUnlock()
+	merged := s.prompts.file
+	admin := s.prompts.admin
+	mergedFields, adminFields := promptFields(&merged), promptFields(&admin)
+	for i, field := range adminFields {
+		if *field.value != "" {
+			*mergedFields[i].value = *field.value
+		}
+	}
+	return merged
+}
+
+// providerPrompts returns the prompt overrides for new provider clients.
+func (s *TaskService) providerPrompts() translator.Prompts {
+	merged := s.mergedPrompts()
+	return translator.Prompts{
+		OCRSystem:       merged.OCRSystem,
+		OCRUser:         merged.OCRUser,
+		TextSystem:      merged.TextSystem,
+		FormatterSystem: merged.FormatterSystem,
+		Extra:           merged.Extra,
+	}
+}
+
+// PromptSettings reports the saved overrides, the config file and the prompts
+// currently in effect.
+func (s *TaskService) PromptSettings() *model.PromptSettingsResponse {
+	effective := s.mergedPrompts()
+	for _, field := range []struct {
+		value    *string
+		fallback string
+	}{
+		{&effective.OCRSystem, translator.DefaultOCRSystemPrompt},
+		{&effective.OCRUser, translator.DefaultOCRUserPrompt},
+		{&effective.TextSystem, translator.DefaultTextSystemPrompt},
+		{&effective.FormatterSystem, translator.DefaultFormatterSystemPrompt},
+	} {
+		if *field.value == "" {
+			*field.value = field.fallback
+		}
+	}
+	s.prompts.mu.RLock()
+	defer s.prompts.mu.RUnlock()
+	return &model.PromptSettingsResponse{
+		Overrides: s.prompts.admin,
+		File:      s.prompts.file,
+		Effective: effective,
+	}
+}
+
+// SavePrompts replaces the admin prompt overrides. They apply to provider
+// clients created afterwards; running tasks keep their prompts.
+func (s *TaskService) SavePrompts(settings model.PromptSettings) (*model.PromptSettingsResponse, error) {
+	if err := validatePromptSettings(&settings); err != nil {
+		return nil, err
+	}
+	data, err := json.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	s.prompts.mu.Lock()
+	tmp := s.promptsPath() + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		s.prompts.mu.Unlock()
+		return nil, err
+	}
+	if err := os.Rename(tmp, s.promptsPath()); err != nil {
+		s.prompts.mu.Unlock()
+		return nil, err
+	}
+	s.prompts.admin = settings
+	s.prompts.mu.Unlock()
+	return s.PromptSettings(), nil
+}
+
+// ResetPrompts drops the admin overrides, falling back to the config file and
+// the built-in prompts.
+func (s *TaskService) ResetPrompts() (*model.PromptSettingsResponse, error) {
+	s.prompts.mu.Lock()
+	if err := os.Remove(s.promptsPath()); err != nil && !errors.Is(err, os.ErrNotExist) {
+		s.prompts.mu.Unlock()
+		return nil, err
+	}
+	s.prompts.admin = model.PromptSettings{}
+	s.prompts.mu.Unlock()
+	return s.PromptSettings(), nil
+}
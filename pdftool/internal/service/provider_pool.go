@@ -0,0 +1,133 @@
+package service
+
+import (
+	"context"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"pdftool/internal/metrics"
+	"pdftool/internal/model"
+	"pdftool/internal/translator"
+)
+
+// maxProviderHold caps how long a provider's reset time holds back new
+// requests. Longer waits (an exhausted daily quota) are left to the retry
+// queue, so tasks keep failing fast instead of sitting in "translating".
+const maxProviderHold = time.Minute
+
+// providerPools caps concurrent page requests per provider type across all
+// tasks. Types without a configured limit are only bounded by each task's
+// worker pool. A provider that answered with a rate limit and a reset time is
+// held: new requests to it wait until the reset instead of failing as well.
+type providerPools struct {
+	slots map[translator.ProviderType]chan struct{}
+	mu    sync.Mutex
+	holds map[translator.ProviderType]time.Time
+}
+
+func newProviderPools(limits map[string]int) *providerPools {
+	pools := &providerPools{
+		slots: make(map[translator.ProviderType]chan struct{}),
+		holds: make(map[translator.ProviderType]time.Time),
+	}
+	for name, limit := range limits {
+		if !translator.Registered(name) {
+			log.Printf("ignore worker limit of unregistered provider %q", name)
+			continue
+		}
+		if limit > 0 {
+			pools.slots[translator.NormalizeProviderType(name)] = make(chan struct{}, limit)
+		}
+	}
+	return pools
+}
+
+// acquire blocks until providerType is not held and a slot for it is free,
+// and returns the slot's release func.
+func (p *providerPools) acquire(ctx context.Context, providerType string) (func(), error) {
+	name := translator.NormalizeProviderType(providerType)
+	if err := p.waitHold(ctx, name); err != nil {
+		return nil, err
+	}
+	slot, ok := p.slots[name]
+	if !ok {
+		return func() {}, nil
+	}
+	select {
+	case slot <- struct{}{}:
+		return func() { <-slot }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// holdFor holds providerType until the reset time err carries, if any.
+func (p *providerPools) holdFor(providerType string, err error) {
+	wait := min(translator.RetryAfter(err), maxProviderHold)
+	if wait <= 0 {
+		return
+	}
+	name := translator.NormalizeProviderType(providerType)
+	until := time.Now().Add(wait)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if until.After(p.holds[name]) {
+		p.holds[name] = until
+		log.Printf("provider %s rate limited, holding requests for %s", name, wait.Round(time.Second))
+	}
+}
+
+func (p *providerPools) waitHold(ctx context.Context, name translator.ProviderType) error {
+	for {
+		p.mu.Lock()
+		until, ok := p.holds[name]
+		if ok && !time.Now().Before(until) {
+			delete(p.holds, name)
+			ok = false
+		}
+		p.mu.Unlock()
+		if !ok {
+			return nil
+		}
+		timer := time.NewTimer(time.Until(until))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// metrics reports busy slots and limits per provider type.
+func (p *providerPools) metrics() []metrics.Sample {
+	names := make([]string, 0, len(p.slots))
+	for name := range p.slots {
+		names = append(names, string(name))
+	}
+	sort.Strings(names)
+	var busy, limits []metrics.Sample
+	for i, name := range names {
+		slot := p.slots[translator.ProviderType(name)]
+		labels := map[string]string{"provider": name}
+		b := metrics.Sample{Name: "pdftool_provider_workers_busy", Labels: labels, Value: float64(len(slot))}
+		l := metrics.Sample{Name: "pdftool_provider_workers_limit", Labels: labels, Value: float64(cap(slot))}
+		if i == 0 {
+			b.Help = "Page requests in flight per provider type."
+			l.Help = "Configured concurrency limit per provider type."
+		}
+		busy = append(busy, b)
+		limits = append(limits, l)
+	}
+	return append(busy, limits...)
+}
+
+// pageProviderType is the provider type used for the page's next request.
+func pageProviderType(task *model.Task, page *model.PageResult) string {
+	if page.Provider != nil && page.Provider.Type != "" {
+		return page.Provider.Type
+	}
+	return task.Provider.Type
+}
@@ -0,0 +1,154 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"pdftool/internal/model"
+	"pdftool/internal/translator"
+)
+
+const providerStatsFile = "provider_stats.json"
+
+// providerCounters is the persisted form of one provider/model's history.
+type providerCounters struct {
+	Type           string    `json:"type"`
+	Model          string    `json:"model"`
+	Requests       int64     `json:"requests"`
+	Errors         int64     `json:"errors"`
+	InputTokens    int64     `json:"input_tokens"`
+	OutputTokens   int64     `json:"output_tokens"`
+	TotalLatencyMs int64     `json:"total_latency_ms"`
+	LastUsedAt     time.Time `json:"last_used_at"`
+}
+
+func (s *TaskService) providerStatsPath() string {
+	return filepath.Join(s.storageDir, providerStatsFile)
+}
+
+func providerStatsKey(info model.ProviderInfo) string {
+	return info.Type + "|" + info.Model
+}
+
+// loadProviderStatsLocked reads the counters; callers hold s.statsMu.
+func (s *TaskService) loadProviderStatsLocked() map[string]*providerCounters {
+	stats := make(map[string]*providerCounters)
+	if data, err := os.ReadFile(s.providerStatsPath()); err == nil {
+		if err := json.Unmarshal(data, &stats); err != nil {
+			log.Printf("解析模型统计失败，将重新计数: %v", err)
+		}
+	}
+	return stats
+}
+
+func (s *TaskService) saveProviderStatsLocked(stats map[string]*providerCounters) error {
+	data, err := json.MarshalIndent(stats, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.providerStatsPath() + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.providerStatsPath())
+}
+
+// withProviderStats attributes token usage reported on the returned context to
+// the provider; finish records the call outcome once the request is done.
+func (s *TaskService) withProviderStats(ctx context.Context, info model.ProviderInfo) (context.Context, func(error)) {
+	var (
+		mu    sync.Mutex
+		usage translator.Usage
+	)
+	start := time.Now()
+	owner := PrincipalFrom(ctx)
+	ctx = translator.WithUsageRecorder(ctx, func(u translator.Usage) {
+		s.recordUsage(u)
+		s.addQuotaUsage(owner, 0, int64(u.Total()))
+		mu.Lock()
+		usage.InputTokens += u.InputTokens
+		usage.OutputTokens += u.OutputTokens
+		mu.Unlock()
+	})
+	finish := func(err error) {
+		mu.Lock()
+		total := usage
+		mu.Unlock()
+		s.recordProviderCall(info, time.Since(start), total, err)
+	}
+	return ctx, finish
+}
+
+func (s *TaskService) recordProviderCall(info model.ProviderInfo, elapsed time.Duration, usage translator.Usage, err error) {
+	if info.Type == "" {
+		return
+	}
+	s.statsMu.Lock()
+	defer s.statsMu.Unlock()
+	stats := s.loadProviderStatsLocked()
+	key := providerStatsKey(info)
+	entry := stats[key]
+	if entry == nil {
+		entry = &providerCounters{Type: info.Type, Model: info.Model}
+		stats[key] = entry
+	}
+	entry.Requests++
+	if err != nil {
+		entry.Errors++
+	}
+	entry.InputTokens += int64(usage.InputTokens)
+	entry.OutputTokens += int64(usage.OutputTokens)
+	entry.TotalLatencyMs += elapsed.Milliseconds()
+	entry.LastUsedAt = time.Now()
+	if err := s.saveProviderStatsLocked(stats); err != nil {
+		log.Printf("写入模型统计失败: %v", err)
+	}
+}
+
+// ProviderStats lists historical usage per provider type and model, busiest first.
+func (s *TaskService) ProviderStats() []*model.ProviderStats {
+	s.statsMu.Lock()
+	stats := s.loadProviderStatsLocked()
+	s.statsMu.Unlock()
+	list := make([]*model.ProviderStats, 0, len(stats))
+	for _, entry := range stats {
+		item := &model.ProviderStats{
+			Type:          entry.Type,
+			Model:         entry.Model,
+			Requests:      entry.Requests,
+			Errors:        entry.Errors,
+			InputTokens:   entry.InputTokens,
+			OutputTokens:  entry.OutputTokens,
+			TotalTokens:   entry.InputTokens + entry.OutputTokens,
+			EstimatedCost: s.tokenCost(entry.InputTokens + entry.OutputTokens),
+			LastUsedAt:    entry.LastUsedAt,
+		}
+		if entry.Requests > 0 {
+			item.ErrorRate = float64(entry.Errors) / float64(entry.Requests)
+			item.MeanLatencyMs = entry.TotalLatencyMs / entry.Requests
+		}
+		list = append(list, item)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Requests != list[j].Requests {
+			return list[i].Requests > list[j].Requests
+		}
+		return providerStatsKey(model.ProviderInfo{Type: list[i].Type, Model: list[i].Model}) <
+			providerStatsKey(model.ProviderInfo{Type: list[j].Type, Model: list[j].Model})
+	})
+	return list
+}
+
+// pageProviderInfo is the provider that produces the page's next translation.
+func pageProviderInfo(task *model.Task, page *model.PageResult) model.ProviderInfo {
+	if page.Provider != nil && page.Provider.Type != "" {
+		return *page.Provider
+	}
+	return task.Provider
+}
@@ -0,0 +1,281 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"pdftool/internal/model"
+)
+
+const (
+	quotaLedgerFile = "quota_usage.json"
+	// AnonymousUser owns tasks created without a known API key while quotas
+	// are configured; all such requests share the default quota.
+	AnonymousUser = "anonymous"
+)
+
+// ErrQuotaExceeded is returned when a user's page or token quota is used up.
+var ErrQuotaExceeded = errors.New("已超出用户配额")
+
+// QuotaLimits caps one user's usage; zero values are unlimited.
+type QuotaLimits struct {
+	PagesPerDay    int   `json:"pagesPerDay,omitempty"`
+	TokensPerMonth int64 `json:"tokensPerMonth,omitempty"`
+}
+
+// QuotaUser is a quotas file entry: a user, the API keys identifying them
+// and their limits.
+type QuotaUser struct {
+	Name string   `json:"name"`
+	Keys []string `json:"keys"`
+	QuotaLimits
+}
+
+// quotaFile is the layout of the quotas file. Default applies to requests
+// without a known API key.
+type quotaFile struct {
+	Default QuotaLimits `json:"default"`
+	Users   []QuotaUser `json:"users"`
+}
+
+// quotaSettings holds the loaded quotas; enabled is false without a quotas
+// file, in which case nothing is attributed or limited.
+type quotaSettings struct {
+	enabled  bool
+	fallback QuotaLimits
+	users    map[string]QuotaLimits
+	keys     map[string]string
+	mu       sync.Mutex
+}
+
+// quotaCounters is one user's entry in the usage ledger.
+type quotaCounters struct {
+	Day         string `json:"day"`
+	DayPages    int    `json:"day_pages"`
+	Month       string `json:"month"`
+	MonthTokens int64  `json:"month_tokens"`
+}
+
+type principalKey struct{}
+
+// WithPrincipal attaches the user on whose behalf a request runs; tasks it
+// creates and provider usage it causes count toward that user's quota.
+func WithPrincipal(ctx context.Context, user string) context.Context {
+	return context.WithValue(ctx, principalKey{}, user)
+}
+
+// PrincipalFrom returns the user attached with WithPrincipal, or "".
+func PrincipalFrom(ctx context.Context) string {
+	user, _ := ctx.Value(principalKey{}).(string)
+	return user
+}
+
+// loadQuotas reads the quotas file; an empty path disables quotas.
+func (s *TaskService) loadQuotas(path string) error {
+	s.quotas.users = make(map[string]QuotaLimits)
+	s.quotas.keys = make(map[string]string)
+	if path = strings.TrimSpace(path); path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("读取配额配置失败: %w", err)
+	}
+	var file quotaFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("解析配额配置失败: %w", err)
+	}
+	for _, user := range file.Users {
+		name := strings.TrimSpace(user.Name)
+		if name == "" || name == AnonymousUser {
+			return fmt.Errorf("配额配置中的用户名无效: %q", user.Name)
+		}
+		if _, dup := s.quotas.users[name]; dup {
+			return fmt.Errorf("配额配置中的用户重复: %s", name)
+		}
+		s.quotas.users[name] = user.QuotaLimits
+		for _, key := range user.Keys {
+			key = strings.TrimSpace(key)
+			if key == "" {
+				continue
+			}
+			if owner, dup := s.quotas.keys[key]; dup {
+				return fmt.Errorf("API key 同时分配给了 %s 和 %s", owner, name)
+			}
+			s.quotas.keys[key] = name
+		}
+	}
+	s.quotas.enabled = true
+	s.quotas.fallback = file.Default
+	return nil
+}
+
+// ResolveAPIKey maps an API key to the user owning it. Unknown or missing
+// keys resolve to AnonymousUser; without a quotas file it returns "".
+func (s *TaskService) ResolveAPIKey(key string) string {
+	if !s.quotas.enabled {
+		return ""
+	}
+	if user, ok := s.quotas.keys[strings.TrimSpace(key)]; ok {
+		return user
+	}
+	return AnonymousUser
+}
+
+func (s *TaskService) quotaLimits(user string) QuotaLimits {
+	if limits, ok := s.quotas.users[user]; ok {
+		return limits
+	}
+	return s.quotas.fallback
+}
+
+func (s *TaskService) quotaLedgerPath() string {
+	return filepath.Join(s.storageDir, quotaLedgerFile)
+}
+
+// loadQuotaLedgerLocked reads the usage ledger; callers hold s.quotas.mu.
+func (s *TaskService) loadQuotaLedgerLocked() map[string]*quotaCounters {
+	ledger := make(map[string]*quotaCounters)
+	if data, err := os.ReadFile(s.quotaLedgerPath()); err == nil {
+		if err := json.Unmarshal(data, &ledger); err != nil {
+			log.Printf("解析配额用量记录失败，将重新计数: %v", err)
+		}
+	}
+	return ledger
+}
+
+func (s *TaskService) saveQuotaLedgerLocked(ledger map[string]*quotaCounters) error {
+	data, err := json.MarshalIndent(ledger, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.quotaLedgerPath() + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.quotaLedgerPath())
+}
+
+// rollQuota resets a user's day/month counters when the period changed.
+func rollQuota(counters *quotaCounters, now time.Time) {
+	day, month := now.Format("2006-01-02"), now.Format("2006-01")
+	if counters.Day != day {
+		counters.Day = day
+		counters.DayPages = 0
+	}
+	if counters.Month != month {
+		counters.Month = month
+		counters.MonthTokens = 0
+	}
+}
+
+// addQuotaUsage adds pages and tokens to a user's counters.
+func (s *TaskService) addQuotaUsage(user string, pages int, tokens int64) {
+	if !s.quotas.enabled || user == "" || (pages == 0 && tokens == 0) {
+		return
+	}
+	s.quotas.mu.Lock()
+	defer s.quotas.mu.Unlock()
+	ledger := s.loadQuotaLedgerLocked()
+	counters := ledger[user]
+	if counters == nil {
+		counters = &quotaCounters{}
+		ledger[user] = counters
+	}
+	rollQuota(counters, time.Now())
+	counters.DayPages += pages
+	counters.MonthTokens += tokens
+	if err := s.saveQuotaLedgerLocked(ledger); err != nil {
+		log.Printf("写入配额用量记录失败: %v", err)
+	}
+}
+
+// QuotaStatus reports a user's usage against their quota; nil when quotas
+// are not configured.
+func (s *TaskService) QuotaStatus(user string) *model.QuotaStatus {
+	if !s.quotas.enabled || user == "" {
+		return nil
+	}
+	s.quotas.mu.Lock()
+	counters := s.loadQuotaLedgerLocked()[user]
+	s.quotas.mu.Unlock()
+	if counters == nil {
+		counters = &quotaCounters{}
+	}
+	rollQuota(counters, time.Now())
+	limits := s.quotaLimits(user)
+	status := &model.QuotaStatus{
+		User:           user,
+		Day:            counters.Day,
+		DayPages:       counters.DayPages,
+		PagesPerDay:    limits.PagesPerDay,
+		Month:          counters.Month,
+		MonthTokens:    counters.MonthTokens,
+		TokensPerMonth: limits.TokensPerMonth,
+	}
+	status.Exceeded = quotaExceededReason(status) != ""
+	return status
+}
+
+// QuotaStatuses reports every configured user, the anonymous allowance and
+// any other user with recorded usage.
+func (s *TaskService) QuotaStatuses() []*model.QuotaStatus {
+	if !s.quotas.enabled {
+		return []*model.QuotaStatus{}
+	}
+	names := map[string]bool{AnonymousUser: true}
+	for name := range s.quotas.users {
+		names[name] = true
+	}
+	s.quotas.mu.Lock()
+	for name := range s.loadQuotaLedgerLocked() {
+		names[name] = true
+	}
+	s.quotas.mu.Unlock()
+	list := make([]*model.QuotaStatus, 0, len(names))
+	for name := range names {
+		list = append(list, s.QuotaStatus(name))
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].User < list[j].User })
+	return list
+}
+
+// checkQuota rejects new work for a user whose quota is used up.
+func (s *TaskService) checkQuota(user string) error {
+	status := s.QuotaStatus(user)
+	if status == nil {
+		return nil
+	}
+	if reason := quotaExceededReason(status); reason != "" {
+		return fmt.Errorf("%w: 用户 %s 已达到%s", ErrQuotaExceeded, user, reason)
+	}
+	return nil
+}
+
+func quotaExceededReason(status *model.QuotaStatus) string {
+	switch {
+	case status.PagesPerDay > 0 && status.DayPages >= status.PagesPerDay:
+		return fmt.Sprintf("每日 %d 页的上限", status.PagesPerDay)
+	case status.TokensPerMonth > 0 && status.MonthTokens >= status.TokensPerMonth:
+		return fmt.Sprintf("每月 %d token 的上限", status.TokensPerMonth)
+	}
+	return ""
+}
+
+// admitPage checks the spend caps and the task owner's quota before a page
+// is dispatched to the provider.
+func (s *TaskService) admitPage(task *model.Task) error {
+	if err := s.checkBudget(); err != nil {
+		return err
+	}
+	return s.checkQuota(task.Owner)
+}
@@ -0,0 +1,37 @@
+package service
+
+import (
+	"context"
+	"log"
+
+	"pdftool/internal/model"
+	"pdftool/internal/translator"
+)
+
+// refusalFallback retries a page the provider refused on the configured
+// fallback provider. The original result and error are returned unchanged
+// when err is not a refusal, no fallback is configured, the fallback is the
+// provider that refused, or the fallback fails as well.
+func (s *TaskService) refusalFallback(ctx context.Context, task *model.Task, page *model.PageResult, result translator.Result, err error, run func(context.Context, translator.ProviderConfig) (translator.Result, error)) (translator.Result, error) {
+	if s.fallbackProvider == nil || !translator.IsRefusal(err) {
+		return result, err
+	}
+	cfg := *s.fallbackProvider
+	s.applyTimeouts(&cfg, false)
+	s.applyImageTypes(&cfg)
+	cfg.Prompts = s.providerPrompts()
+	info := providerInfo(cfg)
+	if info == pageProviderInfo(task, page) {
+		return result, err
+	}
+	log.Printf("page %d of task %s was refused (%v), retrying with %s/%s", page.PageNumber, task.ID, err, info.Type, info.Model)
+	ctx, finish := s.withProviderStats(ctx, info)
+	fallbackResult, fallbackErr := run(ctx, cfg)
+	finish(fallbackErr)
+	if fallbackErr != nil {
+		log.Printf("fallback for page %d of task %s failed: %v", page.PageNumber, task.ID, fallbackErr)
+		return result, err
+	}
+	page.Provider = pageProvider(task, cfg)
+	return fallbackResult, nil
+}
@@ -0,0 +1,138 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"pdftool/internal/model"
+	"pdftool/internal/ocrimport"
+	"pdftool/internal/translator"
+)
+
+// Region sources.
+const (
+	RegionSourceLayout = "layout"
+)
+
+var regionMarker = regexp.MustCompile(`\[\[(\d+)\]\]`)
+
+// PageRegions returns the page's recognized blocks with bounding boxes in the
+// coordinate space of the rendered page image.
+func (s *TaskService) PageRegions(taskID string, pageNumber int) (*model.PageRegionsResponse, error) {
+	task, err := s.loadTask(taskID)
+	if err != nil {
+		return nil, err
+	}
+	for _, page := range task.Pages {
+		if page.PageNumber != pageNumber {
+			continue
+		}
+		regions := page.Regions
+		if regions == nil {
+			regions = []model.Region{}
+		}
+		return &model.PageRegionsResponse{
+			PageNumber:  page.PageNumber,
+			ImageURL:    page.ImageURL,
+			ImageWidth:  page.ImageWidth,
+			ImageHeight: page.ImageHeight,
+			Regions:     regions,
+		}, nil
+	}
+	return nil, fmt.Errorf("页码 %d 不存在", pageNumber)
+}
+
+// ocrRegions scales imported OCR blocks from the OCR page size to the rendered image.
+func ocrRegions(imported ocrimport.PageText, page *model.PageResult) []model.Region {
+	if len(imported.Blocks) == 0 {
+		return nil
+	}
+	scaleX, scaleY := 1.0, 1.0
+	if imported.Width > 0 && imported.Height > 0 && page.ImageWidth > 0 && page.ImageHeight > 0 {
+		scaleX = float64(page.ImageWidth) / float64(imported.Width)
+		scaleY = float64(page.ImageHeight) / float64(imported.Height)
+	}
+	regions := make([]model.Region, 0, len(imported.Blocks))
+	for _, block := range imported.Blocks {
+		text := rewrapText(normalizeText(block.Text))
+		if text == "" || block.X1 <= block.X0 || block.Y1 <= block.Y0 {
+			continue
+		}
+		x0, y0 := int(float64(block.X0)*scaleX), int(float64(block.Y0)*scaleY)
+		x1, y1 := int(float64(block.X1)*scaleX), int(float64(block.Y1)*scaleY)
+		regions = append(regions, model.Region{
+			X:          x0,
+			Y:          y0,
+			Width:      x1 - x0,
+			Height:     y1 - y0,
+			Source:     string(imported.Format),
+			SourceText: text,
+		})
+	}
+	return regions
+}
+
+func regionsHaveText(regions []model.Region) bool {
+	if len(regions) == 0 {
+		return false
+	}
+	for _, r := range regions {
+		if r.SourceText == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// translateRegionText sends all region texts in one request, each prefixed with
+// a [[n]] marker, and splits the translation back onto the regions. When the
+// markers do not survive, the page translation is kept and regions stay untranslated.
+func translateRegionText(ctx context.Context, page *model.PageResult, textClient translator.TextTranslator) (translator.Result, error) {
+	var b strings.Builder
+	for i, r := range page.Regions {
+		fmt.Fprintf(&b, "[[%d]]\n%s\n\n", i+1, r.SourceText)
+	}
+	result, err := textClient.TranslateText(ctx, strings.TrimSpace(b.String()))
+	if err != nil {
+		return result, err
+	}
+	result.SourceText = page.SourceText
+	parts, ok := splitRegionTranslations(result.TranslatedText, len(page.Regions))
+	if !ok {
+		result.TranslatedText = strings.TrimSpace(regionMarker.ReplaceAllString(result.TranslatedText, ""))
+		return result, nil
+	}
+	var translations []string
+	for i, part := range parts {
+		part = normalizeText(part)
+		page.Regions[i].Translation = part
+		if part != "" {
+			translations = append(translations, part)
+		}
+	}
+	result.TranslatedText = strings.Join(translations, "\n\n")
+	return result, nil
+}
+
+func splitRegionTranslations(text string, count int) ([]string, bool) {
+	matches := regionMarker.FindAllStringSubmatchIndex(text, -1)
+	if len(matches) != count {
+		return nil, false
+	}
+	parts := make([]string, count)
+	for i, m := range matches {
+		n, _ := strconv.Atoi(text[m[2]:m[3]])
+		if n != i+1 {
+			return nil, false
+		}
+		end := len(text)
+		if i+1 < len(matches) {
+			end = matches[i+1][0]
+		}
+		parts[i] = strings.TrimSpace(text[m[1]:end])
+	}
+	return parts, true
+}
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+
+	"pdftool/internal/metrics"
+	"pdftool/internal/model"
+	"pdftool/internal/pdfutil"
+	"pdftool/internal/sysmem"
+)
+
+// renderFailureStreak marks the renderer degraded after this many crashes,
+// timeouts or memory failures in a row.
+const renderFailureStreak = 3
+
+// RenderHealth reports render counters and memory use. It is degraded after
+// repeated worker failures, or when in-process rendering has grown the
+// server beyond the render memory limit; a container orchestrator can then
+// restart the instance.
+func (s *TaskService) RenderHealth() *model.RenderHealth {
+	stats := pdfutil.Stats()
+	health := &model.RenderHealth{
+		Status:               "ok",
+		Isolated:             s.renderer.Isolated,
+		WorkerMemoryLimit:    s.renderer.MaxMemory,
+		ContainerMemoryLimit: sysmem.ContainerLimit(),
+		LastWorkerPeak:       stats.LastWorkerPeak,
+		MaxWorkerPeak:        stats.MaxWorkerPeak,
+		Completed:            stats.Completed,
+		Failures:             stats.Failures,
+		Recycles:             stats.Recycles,
+		ConsecutiveFailures:  stats.ConsecutiveFailures,
+		LastFailure:          stats.LastFailure,
+		LastFailureAt:        stats.LastFailureAt,
+	}
+	health.ProcessMemory, _ = sysmem.ResidentBytes(0)
+	switch {
+	case stats.ConsecutiveFailures >= renderFailureStreak:
+		health.Status = "degraded"
+		health.Reason = fmt.Sprintf("连续 %d 次渲染失败", stats.ConsecutiveFailures)
+	case !s.renderer.Isolated && s.renderer.MaxMemory > 0 && health.ProcessMemory > s.renderer.MaxMemory:
+		health.Status = "degraded"
+		health.Reason = fmt.Sprintf("进程内存 %d MB 超出渲染内存上限 %d MB", health.ProcessMemory>>20, s.renderer.MaxMemory>>20)
+	}
+	return health
+}
+
+func (s *TaskService) renderMetrics() []metrics.Sample {
+	health := s.RenderHealth()
+	var samples []metrics.Sample
+	for i, op := range sortedKeys(health.Completed) {
+		sample := metrics.Sample{Name: "pdftool_render_completed_total", Type: "counter", Labels: map[string]string{"op": op}, Value: float64(health.Completed[op])}
+		if i == 0 {
+			sample.Help = "Successful render and metadata operations."
+		}
+		samples = append(samples, sample)
+	}
+	for i, reason := range sortedKeys(health.Failures) {
+		sample := metrics.Sample{Name: "pdftool_render_failures_total", Type: "counter", Labels: map[string]string{"reason": reason}, Value: float64(health.Failures[reason])}
+		if i == 0 {
+			sample.Help = "Failed render operations by reason (error, crash, timeout, memory)."
+		}
+		samples = append(samples, sample)
+	}
+	degraded := 0.0
+	if health.Status != "ok" {
+		degraded = 1
+	}
+	return append(samples,
+		metrics.Sample{Name: "pdftool_render_worker_recycles_total", Help: "Render workers restarted for exceeding the memory limit.", Type: "counter", Value: float64(health.Recycles)},
+		metrics.Sample{Name: "pdftool_render_worker_peak_bytes", Help: "Peak resident memory of render workers.", Labels: map[string]string{"worker": "last"}, Value: float64(health.LastWorkerPeak)},
+		metrics.Sample{Name: "pdftool_render_worker_peak_bytes", Labels: map[string]string{"worker": "max"}, Value: float64(health.MaxWorkerPeak)},
+		metrics.Sample{Name: "pdftool_render_worker_memory_limit_bytes", Help: "Render worker memory limit (0 = unlimited).", Value: float64(health.WorkerMemoryLimit)},
+		metrics.Sample{Name: "pdftool_process_resident_bytes", Help: "Resident memory of the server process.", Value: float64(health.ProcessMemory)},
+		metrics.Sample{Name: "pdftool_container_memory_limit_bytes", Help: "Memory limit of the container (0 = none detected).", Value: float64(health.ContainerMemoryLimit)},
+		metrics.Sample{Name: "pdftool_render_degraded", Help: "1 when the render subsystem reports degraded health.", Value: degraded},
+	)
+}
+
+func sortedKeys(m map[string]int64) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
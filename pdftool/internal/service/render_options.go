@@ -0,0 +1,47 @@
+package service
+
+import (
+	"pdftool/internal/model"
+	"pdftool/internal/pdfutil"
+)
+
+// renderOptions fills the fields a task leaves unset from the server's
+// render defaults.
+func (s *TaskService) renderOptions(override pdfutil.RenderOptions) (pdfutil.RenderOptions, error) {
+	opts := s.renderDefaults
+	if override.DPI != 0 {
+		opts.DPI = override.DPI
+	}
+	if override.Format != "" {
+		opts.Format = override.Format
+	}
+	if override.JPEGQuality != 0 {
+		opts.JPEGQuality = override.JPEGQuality
+	}
+	if override.MaxDimension != 0 {
+		opts.MaxDimension = override.MaxDimension
+	}
+	return opts.Normalize()
+}
+
+// taskRenderOptions returns the options the task's pages were rendered
+// with. Tasks from before render options were recorded used the defaults.
+func taskRenderOptions(task *model.Task) pdfutil.RenderOptions {
+	if task.Render == nil {
+		return pdfutil.RenderOptions{}
+	}
+	return pdfutil.RenderOptions{
+		DPI:          task.Render.DPI,
+		Format:       task.Render.Format,
+		JPEGQuality:  task.Render.JPEGQuality,
+		MaxDimension: task.Render.MaxDimension,
+	}
+}
+
+func renderSettings(opts pdfutil.RenderOptions) *model.RenderSettings {
+	settings := &model.RenderSettings{DPI: opts.DPI, Format: opts.Format, MaxDimension: opts.MaxDimension}
+	if opts.Format == pdfutil.FormatJPEG {
+		settings.JPEGQuality = opts.JPEGQuality
+	}
+	return settings
+}
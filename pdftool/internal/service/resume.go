@@ -0,0 +1,127 @@
+package service
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"pdftool/internal/model"
+	"pdftool/internal/translator"
+)
+
+// RecoverTasks settles the tasks a previous process left in flight; it runs
+// once at startup. Interrupted AI layout runs are ended, and pages left pending
+// are re-dispatched when autoResume is set and the task can be resumed
+// unattended. API keys are never persisted, so only tasks whose stored
+// provider matches the server default provider (and therefore its key) qualify;
+// the others are paused so they show as waiting for POST /tasks/:id/resume
+// instead of translating forever. Tasks holding live leases may be worked on
+// by another instance; they are checked again once the leases a crashed
+// process left behind have expired.
+func (s *TaskService) RecoverTasks(autoResume bool) {
+	if leased := s.recoverTasks(autoResume, nil); len(leased) > 0 {
+		time.AfterFunc(leaseTTL, func() { s.recoverTasks(autoResume, leased) })
+	}
+}
+
+// recoverTasks settles the tasks in only, or all tasks when only is nil, and
+// returns the IDs of those skipped because of a live lease.
+func (s *TaskService) recoverTasks(autoResume bool, only map[string]bool) map[string]bool {
+	summaries, err := s.ListTasks()
+	if err != nil {
+		log.Printf("task recovery: list tasks failed: %v", err)
+		return nil
+	}
+	leased := make(map[string]bool)
+	for _, summary := range summaries {
+		if only != nil && !only[summary.ID] {
+			continue
+		}
+		if summary.State != model.TaskStateFormatting && (summary.PendingPages == 0 || summary.Paused || summary.DryRun || summary.State == model.TaskStateCanceled) {
+			continue
+		}
+		if s.leasedElsewhere(summary.ID) {
+			leased[summary.ID] = true
+			continue
+		}
+		task, err := s.loadTask(summary.ID)
+		if err != nil {
+			log.Printf("task recovery: load task %s failed: %v", summary.ID, err)
+			continue
+		}
+		if task.FormattingInProgress {
+			if task, err = s.updateTask(task.ID, func(t *model.Task) error {
+				t.FormattingInProgress = false
+				changeTaskState(t, settledState(t), "服务重启，AI 排版已中断")
+				return nil
+			}); err != nil {
+				log.Printf("task recovery: task %s: %v", summary.ID, err)
+				continue
+			}
+		}
+		if summary.PendingPages == 0 || task.Paused || task.DryRun || task.State == model.TaskStateCanceled {
+			continue
+		}
+		reason := "服务重启，等待手动恢复"
+		if autoResume {
+			var resumed bool
+			if resumed, reason = s.autoResume(task, summary.PendingPages); resumed {
+				continue
+			}
+		}
+		if err := s.pauseInterrupted(task.ID, reason); err != nil {
+			log.Printf("task recovery: pause task %s failed: %v", task.ID, err)
+		}
+	}
+	return leased
+}
+
+// autoResume re-dispatches the pending pages of task with the server default
+// provider. When it cannot, it returns the reason.
+func (s *TaskService) autoResume(task *model.Task, pending int) (bool, string) {
+	if !s.usesDefaultProvider(task) {
+		log.Printf("auto resume: task %s uses provider %s/%s without a stored key, waiting for manual resume", task.ID, task.Provider.Type, task.Provider.Model)
+		return false, "服务重启，任务使用的模型密钥未保存，等待手动恢复"
+	}
+	if err := s.checkBudget(); err != nil {
+		log.Printf("auto resume: task %s: %v", task.ID, err)
+		return false, err.Error()
+	}
+	providerCfg, err := s.mergeProviderConfig(translator.ProviderConfig{}, task)
+	if err == nil {
+		log.Printf("auto resume: task %s, %d pending pages", task.ID, pending)
+		_, err = s.resumeWithProvider(task.ID, providerCfg, false)
+	}
+	if err != nil {
+		log.Printf("auto resume: task %s: %v", task.ID, err)
+		return false, err.Error()
+	}
+	return true, ""
+}
+
+// pauseInterrupted pauses a task whose pending pages were not resumed.
+func (s *TaskService) pauseInterrupted(taskID, reason string) error {
+	_, err := s.updateTask(taskID, func(task *model.Task) error {
+		task.Paused = true
+		task.PausedAt = time.Now()
+		task.PauseReason = reason
+		changeTaskState(task, model.TaskStatePaused, reason)
+		return nil
+	})
+	if err == nil {
+		log.Printf("task %s paused: %s", taskID, reason)
+	}
+	return err
+}
+
+func (s *TaskService) usesDefaultProvider(task *model.Task) bool {
+	def := s.defaultProvider
+	if strings.TrimSpace(def.APIKey) == "" && translator.RequiresCredentials(translator.NormalizeProviderType(string(def.Type))) {
+		return false
+	}
+	if task.Provider.Type != "" && translator.NormalizeProviderType(task.Provider.Type) != translator.NormalizeProviderType(string(def.Type)) {
+		return false
+	}
+	base := strings.TrimRight(strings.TrimSpace(task.Provider.BaseURL), "/")
+	return base == "" || base == strings.TrimRight(strings.TrimSpace(def.BaseURL), "/")
+}
@@ -0,0 +1,101 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"pdftool/internal/model"
+	"pdftool/internal/translator"
+)
+
+// RetranslatePages re-queues the pages selected by expr ("1-3,7,20-25") in the
+// background, optionally with a different provider. Pages imported from OCR
+// files are re-translated from their text.
+func (s *TaskService) RetranslatePages(ctx context.Context, taskID, expr string, provider translator.ProviderConfig) (*model.Task, error) {
+	task, err := s.loadTask(taskID)
+	if err != nil {
+		return nil, err
+	}
+	selected, err := parsePageList(expr, task.TotalPages)
+	if err != nil {
+		return nil, err
+	}
+	return s.requeuePages(task, selected, provider)
+}
+
+// RetranslateFailed re-queues every page whose translation failed, like
+// RetranslatePages, so they run through the worker pool in one go.
+func (s *TaskService) RetranslateFailed(ctx context.Context, taskID string, provider translator.ProviderConfig) (*model.Task, error) {
+	task, err := s.loadTask(taskID)
+	if err != nil {
+		return nil, err
+	}
+	selected := make(map[int]bool)
+	for _, page := range task.Pages {
+		if page.Status == model.PageStatusError {
+			selected[page.PageNumber] = true
+		}
+	}
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("没有翻译失败的页面")
+	}
+	return s.requeuePages(task, selected, provider)
+}
+
+// requeuePages resets the selected pages of task to pending and translates
+// them in the background.
+func (s *TaskService) requeuePages(task *model.Task, selected map[int]bool, provider translator.ProviderConfig) (*model.Task, error) {
+	if err := s.checkBudget(); err != nil {
+		return nil, err
+	}
+	providerCfg, err := s.mergeProviderConfig(provider, task)
+	if err != nil {
+		return nil, err
+	}
+	translatorClient, err := translator.NewTranslator(providerCfg)
+	if err != nil {
+		return nil, err
+	}
+	var textClient translator.TextTranslator
+	var imagePages, textPages []*model.PageResult
+	now := time.Now()
+	for _, page := range task.Pages {
+		if !selected[page.PageNumber] {
+			continue
+		}
+		page.Provider = pageProvider(task, providerCfg)
+		page.Status = model.PageStatusPending
+		page.Error = ""
+		page.BlockReason = ""
+		page.RetryAttempts = 0
+		page.RetryAt = time.Time{}
+		page.UpdatedAt = now
+		if isTextPage(page) {
+			textPages = append(textPages, page)
+		} else {
+			imagePages = append(imagePages, page)
+		}
+	}
+	if len(textPages) > 0 {
+		if textClient, err = translator.NewTextTranslator(providerCfg); err != nil {
+			return nil, err
+		}
+	}
+	s.clearCanceled(task.ID)
+	changeTaskState(task, settledState(task), fmt.Sprintf("重新翻译 %d 页", len(imagePages)+len(textPages)))
+	if err := s.saveTask(task); err != nil {
+		return nil, err
+	}
+	s.publishPagesPending(task, imagePages)
+	s.publishPagesPending(task, textPages)
+	go func() {
+		s.translateTaskPages(context.Background(), task, imagePages, translatorClient, 0)
+		if len(textPages) > 0 {
+			s.runPageJobs(task, textPages, 0, func(page *model.PageResult) error {
+				return s.translateTextPage(context.Background(), task, page, textClient)
+			})
+		}
+	}()
+	return task, nil
+}
@@ -0,0 +1,265 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"pdftool/internal/metrics"
+	"pdftool/internal/model"
+	"pdftool/internal/translator"
+)
+
+const retrySchedulerInterval = 5 * time.Second
+
+// RetryPolicy controls automatic retries of pages that failed with a transient
+// provider error (429, 5xx, timeouts) or a storage error. MaxAttempts 0
+// disables the queue.
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// delay returns the exponential backoff before the given (1-based) attempt.
+func (p RetryPolicy) delay(attempt int) time.Duration {
+	d := p.BaseDelay
+	for i := 1; i < attempt && d < p.MaxDelay; i++ {
+		d *= 2
+	}
+	if d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	return d
+}
+
+type retryJob struct {
+	taskID     string
+	pageNumber int
+	due        time.Time
+	run        func(*model.Task, *model.PageResult) error
+}
+
+// retryQueue holds scheduled retries in memory. The schedule is also stored on
+// the page (RetryAt) so it can be rebuilt after a restart.
+type retryQueue struct {
+	policy RetryPolicy
+	mu     sync.Mutex
+	jobs   map[string]*retryJob
+	// wake tells the scheduler a job was added, which may be due sooner
+	// than its next check.
+	wake chan struct{}
+}
+
+func newRetryQueue(policy RetryPolicy) *retryQueue {
+	if policy.BaseDelay <= 0 {
+		policy.BaseDelay = 30 * time.Second
+	}
+	if policy.MaxDelay < policy.BaseDelay {
+		policy.MaxDelay = 30 * time.Minute
+	}
+	return &retryQueue{policy: policy, jobs: make(map[string]*retryJob), wake: make(chan struct{}, 1)}
+}
+
+func retryKey(taskID string, pageNumber int) string {
+	return fmt.Sprintf("%s/%d", taskID, pageNumber)
+}
+
+func (q *retryQueue) add(job *retryJob) {
+	q.mu.Lock()
+	q.jobs[retryKey(job.taskID, job.pageNumber)] = job
+	q.mu.Unlock()
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (q *retryQueue) remove(taskID string, pageNumber int) {
+	q.mu.Lock()
+	delete(q.jobs, retryKey(taskID, pageNumber))
+	q.mu.Unlock()
+}
+
+// due removes and returns the jobs whose time has come, grouped by task.
+func (q *retryQueue) due(now time.Time) map[string][]*retryJob {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	byTask := make(map[string][]*retryJob)
+	for key, job := range q.jobs {
+		if job.due.After(now) {
+			continue
+		}
+		delete(q.jobs, key)
+		byTask[job.taskID] = append(byTask[job.taskID], job)
+	}
+	return byTask
+}
+
+// next returns the earliest due time of the queued jobs.
+func (q *retryQueue) next() (time.Time, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var earliest time.Time
+	for _, job := range q.jobs {
+		if earliest.IsZero() || job.due.Before(earliest) {
+			earliest = job.due
+		}
+	}
+	return earliest, !earliest.IsZero()
+}
+
+func (q *retryQueue) metrics() []metrics.Sample {
+	q.mu.Lock()
+	queued := len(q.jobs)
+	q.mu.Unlock()
+	return []metrics.Sample{
+		{Name: "pdftool_retry_queue_pages", Help: "Pages waiting for an automatic retry.", Value: float64(queued)},
+	}
+}
+
+// scheduleRetry records the outcome of a page attempt: success clears the retry
+// state, a transient failure below the attempt limit queues run after a backoff.
+// When the provider said when to come back (Retry-After or its rate-limit
+// reset headers), that time replaces the backoff, capped at MaxDelay.
+func (s *TaskService) scheduleRetry(task *model.Task, page *model.PageResult, err error, run func(*model.Task, *model.PageResult) error) {
+	page.RetryAt = time.Time{}
+	if err == nil {
+		page.RetryAttempts = 0
+		s.retries.remove(task.ID, page.PageNumber)
+		return
+	}
+	policy := s.retries.policy
+	transient := translator.IsTransient(err) || IsStorageError(err)
+	if policy.MaxAttempts <= 0 || !transient || page.RetryAttempts >= policy.MaxAttempts {
+		return
+	}
+	delay := policy.delay(page.RetryAttempts + 1)
+	if wait := translator.RetryAfter(err); wait > 0 {
+		delay = min(wait, policy.MaxDelay)
+	}
+	retryAt := time.Now().Add(delay)
+	if !s.retryWithinBudget(task, page, retryAt) {
+		log.Printf("page %d of task %s would exceed its time budget, no further retries", page.PageNumber, task.ID)
+		return
+	}
+	page.RetryAttempts++
+	page.RetryAt = retryAt
+	s.retries.add(&retryJob{taskID: task.ID, pageNumber: page.PageNumber, due: page.RetryAt, run: run})
+	log.Printf("page %d of task %s failed with a transient error, retry %d/%d at %s", page.PageNumber, task.ID, page.RetryAttempts, policy.MaxAttempts, page.RetryAt.Format(time.RFC3339))
+}
+
+// RunRetryScheduler re-queues retries stored on pages and then dispatches due
+// retries until ctx is cancelled. Jobs are dispatched when they fall due,
+// checking at least every retrySchedulerInterval.
+func (s *TaskService) RunRetryScheduler(ctx context.Context) {
+	if s.retries.policy.MaxAttempts <= 0 {
+		return
+	}
+	s.restoreRetries()
+	timer := time.NewTimer(retrySchedulerInterval)
+	defer timer.Stop()
+	for {
+		wait := retrySchedulerInterval
+		if due, ok := s.retries.next(); ok {
+			wait = max(min(wait, time.Until(due)), 0)
+		}
+		timer.Reset(wait)
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.retries.wake:
+			timer.Stop()
+		case now := <-timer.C:
+			for taskID, jobs := range s.retries.due(now) {
+				s.dispatchRetries(taskID, jobs)
+			}
+		}
+	}
+}
+
+func (s *TaskService) dispatchRetries(taskID string, jobs []*retryJob) {
+	task, err := s.loadTask(taskID)
+	if err != nil {
+		return
+	}
+	// Paused tasks retry their failed pages on resume instead.
+	if task.Paused || s.isCanceled(task.ID) {
+		return
+	}
+	runs := make(map[int]func(*model.Task, *model.PageResult) error, len(jobs))
+	for _, job := range jobs {
+		runs[job.pageNumber] = job.run
+	}
+	var pages []*model.PageResult
+	for _, page := range task.Pages {
+		if _, ok := runs[page.PageNumber]; ok && page.Status == model.PageStatusError {
+			pages = append(pages, page)
+		}
+	}
+	if len(pages) == 0 {
+		return
+	}
+	log.Printf("retrying %d pages of task %s", len(pages), task.ID)
+	go s.runPageJobs(task, pages, 0, func(page *model.PageResult) error {
+		return runs[page.PageNumber](task, page)
+	})
+}
+
+// restoreRetries rebuilds the queue from pages with a stored RetryAt. As with
+// auto resume, only tasks on the server default provider have a usable key.
+func (s *TaskService) restoreRetries() {
+	summaries, err := s.ListTasks()
+	if err != nil {
+		return
+	}
+	for _, summary := range summaries {
+		if summary.ErrorPages == 0 || summary.Paused || summary.State == model.TaskStateCanceled {
+			continue
+		}
+		task, err := s.loadTask(summary.ID)
+		if err != nil || !s.usesDefaultProvider(task) {
+			continue
+		}
+		providerCfg, err := s.mergeProviderConfig(translator.ProviderConfig{}, task)
+		if err != nil {
+			continue
+		}
+		var imageClient translator.Translator
+		var textClient translator.TextTranslator
+		for _, page := range task.Pages {
+			if page.Status != model.PageStatusError || page.RetryAt.IsZero() {
+				continue
+			}
+			job := &retryJob{taskID: task.ID, pageNumber: page.PageNumber, due: page.RetryAt}
+			if isTextPage(page) {
+				if textClient == nil {
+					if textClient, err = translator.NewTextTranslator(providerCfg); err != nil {
+						break
+					}
+				}
+				client := textClient
+				job.run = func(task *model.Task, page *model.PageResult) error {
+					return s.translateTextPage(context.Background(), task, page, client)
+				}
+			} else {
+				if imageClient == nil {
+					if imageClient, err = translator.NewTranslator(providerCfg); err != nil {
+						break
+					}
+				}
+				client := imageClient
+				job.run = func(task *model.Task, page *model.PageResult) error {
+					return s.translateSinglePage(context.Background(), task, page, client)
+				}
+			}
+			s.retries.add(job)
+		}
+	}
+}
+
+func retryAtPtr(page *model.PageResult) *time.Time {
+	return timePtr(page.RetryAt)
+}
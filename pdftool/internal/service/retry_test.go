@@ -0,0 +1,42 @@
+package service
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"pdftool/internal/model"
+	"pdftool/internal/translator"
+)
+
+// TestScheduleRetryHonorsRetryAfter checks that a provider's Retry-After
+// replaces the exponential backoff, capped at the policy's MaxDelay.
+func TestScheduleRetryHonorsRetryAfter(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3, BaseDelay: 30 * time.Second, MaxDelay: time.Minute}
+	s := &TaskService{retries: newRetryQueue(policy)}
+	task := &model.Task{ID: "t", Provider: model.ProviderInfo{Type: "mock"}}
+	run := func(*model.Task, *model.PageResult) error { return nil }
+	cases := []struct {
+		name string
+		err  error
+		want time.Duration
+	}{
+		{name: "backoff", err: &translator.HTTPError{Provider: "OpenAI", StatusCode: 429}, want: 30 * time.Second},
+		{name: "retry after", err: &translator.HTTPError{Provider: "OpenAI", StatusCode: 429, RetryAfter: 3 * time.Second}, want: 3 * time.Second},
+		{name: "capped", err: fmt.Errorf("page: %w", &translator.HTTPError{Provider: "OpenAI", StatusCode: 429, RetryAfter: time.Hour}), want: time.Minute},
+	}
+	for i, tc := range cases {
+		page := &model.PageResult{PageNumber: i + 1}
+		before := time.Now()
+		s.scheduleRetry(task, page, tc.err, run)
+		if page.RetryAttempts != 1 {
+			t.Fatalf("%s: retry not scheduled", tc.name)
+		}
+		if got := page.RetryAt.Sub(before); got < tc.want || got > tc.want+time.Second {
+			t.Errorf("%s: retry in %s, want %s", tc.name, got, tc.want)
+		}
+	}
+	if due, ok := s.retries.next(); !ok || time.Until(due) > 4*time.Second {
+		t.Errorf("next due %v (%v), want the Retry-After job", due, ok)
+	}
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"pdftool/internal/model"
+)
+
+// reviewFilterNone selects pages without a review flag.
+const reviewFilterNone = "none"
+
+// maxReviewNoteRunes caps the proofreader's note on a page.
+const maxReviewNoteRunes = 2000
+
+// ParseReviewFlag validates a review flag; the empty string clears the flag.
+func ParseReviewFlag(raw string) (model.ReviewFlag, error) {
+	flag := model.ReviewFlag(strings.ToLower(strings.TrimSpace(strings.ReplaceAll(raw, "-", "_"))))
+	switch flag {
+	case "", model.ReviewNeedsReview, model.ReviewApproved:
+		return flag, nil
+	}
+	return "", fmt.Errorf("不支持的审校标记: %s", raw)
+}
+
+// SetPageReview flags a page for proofreading or approves it. An empty flag
+// clears the flag and the note. The page's UpdatedAt is left alone so an
+// in-flight retranslation of the page is not dropped as stale.
+func (s *TaskService) SetPageReview(taskID string, pageNumber int, flag model.ReviewFlag, note string) (*model.Task, error) {
+	note = strings.TrimSpace(note)
+	if len([]rune(note)) > maxReviewNoteRunes {
+		return nil, fmt.Errorf("审校备注不能超过 %d 个字符", maxReviewNoteRunes)
+	}
+	return s.updateTask(taskID, func(task *model.Task) error {
+		for _, page := range task.Pages {
+			if page.PageNumber != pageNumber {
+				continue
+			}
+			page.Review = flag
+			page.ReviewNote = note
+			if flag == "" {
+				page.ReviewNote = ""
+			}
+			page.ReviewedAt = time.Now()
+			return nil
+		}
+		return fmt.Errorf("页码 %d 不存在", pageNumber)
+	})
+}
+
+// FilterPagesByReview keeps only the pages of resp carrying the review flag
+// named by filter; "none" keeps unflagged pages.
+func FilterPagesByReview(resp *model.TaskResponse, filter string) error {
+	filter = strings.TrimSpace(filter)
+	if filter == "" {
+		return nil
+	}
+	var want model.ReviewFlag
+	if !strings.EqualFold(filter, reviewFilterNone) {
+		flag, err := ParseReviewFlag(filter)
+		if err != nil {
+			return err
+		}
+		want = flag
+	}
+	pages := resp.Pages[:0]
+	for _, page := range resp.Pages {
+		if page.Review == want {
+			pages = append(pages, page)
+		}
+	}
+	resp.Pages = pages
+	return nil
+}
+
+// carryReview keeps the review flag stored on current when a worker commits
+// its copy of the page, since flags may be set while a page is translating.
+// A changed translation sends an approved page back to review.
+func carryReview(current, next *model.PageResult) {
+	next.Review = current.Review
+	next.ReviewNote = current.ReviewNote
+	next.ReviewedAt = current.ReviewedAt
+	if next.Review == model.ReviewApproved && next.Translation != current.Translation {
+		next.Review = model.ReviewNeedsReview
+	}
+}
@@ -0,0 +1,247 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"pdftool/internal/model"
+)
+
+// Diff granularities.
+const (
+	DiffByLine = "line"
+	DiffByChar = "char"
+)
+
+// maxDiffCells bounds the LCS table of a page diff.
+const maxDiffCells = 4_000_000
+
+// ErrNoPreviousTranslation is returned when a page was never retranslated.
+var ErrNoPreviousTranslation = errors.New("该页面没有可对比的历史译文")
+
+// keepRevision records the stored translation as the page's previous revision
+// when a worker commits a different one, and otherwise carries the existing
+// revision over the worker's copy of the page.
+func keepRevision(task *model.Task, current, next *model.PageResult) {
+	next.Previous = current.Previous
+	if next.Status != model.PageStatusCompleted || current.Translation == "" || next.Translation == current.Translation {
+		return
+	}
+	next.Previous = &model.PageRevision{
+		HasText:     current.HasText,
+		SourceText:  current.SourceText,
+		Translation: current.Translation,
+		Footnotes:   current.Footnotes,
+		Elements:    current.Elements,
+		Blocks:      current.Blocks,
+		Provider:    pageProviderInfo(task, current),
+		ReplacedAt:  time.Now(),
+	}
+}
+
+// PageDiff compares a page's translation with the one its last retranslation
+// replaced, by line or by character.
+func (s *TaskService) PageDiff(taskID string, pageNumber int, granularity string) (*model.PageDiffResponse, error) {
+	granularity = strings.ToLower(strings.TrimSpace(granularity))
+	if granularity == "" {
+		granularity = DiffByLine
+	}
+	if granularity != DiffByLine && granularity != DiffByChar {
+		return nil, fmt.Errorf("不支持的对比粒度: %s", granularity)
+	}
+	task, err := s.loadTask(taskID)
+	if err != nil {
+		return nil, err
+	}
+	page := findPage(task, pageNumber)
+	if page == nil {
+		return nil, fmt.Errorf("页码 %d 不存在", pageNumber)
+	}
+	if page.Previous == nil {
+		return nil, ErrNoPreviousTranslation
+	}
+	ops, err := diffText(page.Previous.Translation, page.Translation, granularity)
+	if err != nil {
+		return nil, err
+	}
+	resp := &model.PageDiffResponse{
+		TaskID:      task.ID,
+		PageNumber:  pageNumber,
+		Granularity: granularity,
+		Previous: &model.RevisionInfo{
+			Provider:    page.Previous.Provider,
+			HasText:     page.Previous.HasText,
+			Translation: page.Previous.Translation,
+			At:          page.Previous.ReplacedAt,
+		},
+		Current: &model.RevisionInfo{
+			Provider:    pageProviderInfo(task, page),
+			HasText:     page.HasText,
+			Translation: page.Translation,
+			At:          page.UpdatedAt,
+		},
+		Ops: ops,
+	}
+	for _, op := range ops {
+		switch op.Op {
+		case "insert":
+			resp.Inserted += countUnits(op.Text, granularity)
+		case "delete":
+			resp.Deleted += countUnits(op.Text, granularity)
+		}
+	}
+	return resp, nil
+}
+
+// RevertPage restores the translation a retranslation replaced. The replaced
+// translation becomes the previous revision in turn, so a revert can itself be
+// undone.
+func (s *TaskService) RevertPage(taskID string, pageNumber int) (*model.Task, error) {
+	return s.updateTask(taskID, func(task *model.Task) error {
+		page := findPage(task, pageNumber)
+		if page == nil {
+			return fmt.Errorf("页码 %d 不存在", pageNumber)
+		}
+		if page.Previous == nil {
+			return ErrNoPreviousTranslation
+		}
+		if page.Status == model.PageStatusPending {
+			return fmt.Errorf("第%d页正在翻译，无法回退", pageNumber)
+		}
+		restored := *page
+		restored.HasText = page.Previous.HasText
+		restored.SourceText = page.Previous.SourceText
+		restored.Translation = page.Previous.Translation
+		restored.Footnotes = page.Previous.Footnotes
+		restored.Elements = page.Previous.Elements
+		restored.Blocks = page.Previous.Blocks
+		restored.Provider = nil
+		if page.Previous.Provider != task.Provider {
+			info := page.Previous.Provider
+			restored.Provider = &info
+		}
+		restored.Status = model.PageStatusCompleted
+		restored.Error = ""
+		restored.BlockReason = ""
+		restored.RetryAttempts = 0
+		restored.RetryAt = time.Time{}
+		if err := s.writePageText(task, &restored); err != nil {
+			return err
+		}
+		keepRevision(task, page, &restored)
+		carryReview(page, &restored)
+		restored.UpdatedAt = time.Now()
+		*page = restored
+		s.refreshCombinedText(task)
+		return nil
+	})
+}
+
+func findPage(task *model.Task, pageNumber int) *model.PageResult {
+	for _, page := range task.Pages {
+		if page.PageNumber == pageNumber {
+			return page
+		}
+	}
+	return nil
+}
+
+// diffText splits both texts into lines (keeping line breaks) or characters
+// and diffs the token sequences.
+func diffText(before, after, granularity string) ([]model.DiffOp, error) {
+	split := func(text string) []string {
+		if granularity == DiffByLine {
+			return strings.SplitAfter(text, "\n")
+		}
+		return strings.Split(text, "")
+	}
+	a, b := split(before), split(after)
+	if before == "" {
+		a = nil
+	}
+	if after == "" {
+		b = nil
+	}
+	return diffTokens(a, b)
+}
+
+// diffTokens returns the edit script turning a into b from a longest common
+// subsequence, merging adjacent tokens of the same kind.
+func diffTokens(a, b []string) ([]model.DiffOp, error) {
+	var ops []model.DiffOp
+	emit := func(op, text string) {
+		if n := len(ops); n > 0 && ops[n-1].Op == op {
+			ops[n-1].Text += text
+			return
+		}
+		ops = append(ops, model.DiffOp{Op: op, Text: text})
+	}
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	midA, midB := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	if (len(midA)+1)*(len(midB)+1) > maxDiffCells {
+		return nil, fmt.Errorf("译文过长，无法按当前粒度对比")
+	}
+	for _, token := range a[:prefix] {
+		emit("equal", token)
+	}
+	// lcs[i][j] is the LCS length of midA[i:] and midB[j:].
+	cols := len(midB) + 1
+	lcs := make([]int32, (len(midA)+1)*cols)
+	for i := len(midA) - 1; i >= 0; i-- {
+		for j := len(midB) - 1; j >= 0; j-- {
+			switch {
+			case midA[i] == midB[j]:
+				lcs[i*cols+j] = lcs[(i+1)*cols+j+1] + 1
+			case lcs[(i+1)*cols+j] >= lcs[i*cols+j+1]:
+				lcs[i*cols+j] = lcs[(i+1)*cols+j]
+			default:
+				lcs[i*cols+j] = lcs[i*cols+j+1]
+			}
+		}
+	}
+	i, j := 0, 0
+	for i < len(midA) && j < len(midB) {
+		switch {
+		case midA[i] == midB[j]:
+			emit("equal", midA[i])
+			i++
+			j++
+		case lcs[(i+1)*cols+j] >= lcs[i*cols+j+1]:
+			emit("delete", midA[i])
+			i++
+		default:
+			emit("insert", midB[j])
+			j++
+		}
+	}
+	for ; i < len(midA); i++ {
+		emit("delete", midA[i])
+	}
+	for ; j < len(midB); j++ {
+		emit("insert", midB[j])
+	}
+	for _, token := range a[len(a)-suffix:] {
+		emit("equal", token)
+	}
+	if ops == nil {
+		ops = []model.DiffOp{}
+	}
+	return ops, nil
+}
+
+// countUnits counts the lines or characters of a diff run.
+func countUnits(text, granularity string) int {
+	if granularity == DiffByLine {
+		return len(strings.SplitAfter(strings.TrimSuffix(text, "\n"), "\n"))
+	}
+	return len([]rune(text))
+}
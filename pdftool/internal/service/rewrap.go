@@ -0,0 +1,113 @@
+package service
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"pdftool/internal/model"
+)
+
+// structuralLine matches lines that start a block of their own and are never
+// joined to the previous line: Markdown headings, list items, quotes, table
+// rows, footnote definitions and code fences.
+var structuralLine = regexp.MustCompile(`^(#{1,6}\s|[-*+•·]\s|\d{1,3}[.)、]\s|[(（]\d{1,3}[)）]|\[\^?[^\]]+\]:?\s|>|\||` + "```" + `)`)
+
+// rewrapText joins the hard line breaks OCR leaves inside paragraphs. Lines
+// are joined with a space, or without one when either side is Chinese or
+// Japanese; a word split by a hyphen at the end of a line is rejoined when the
+// next line continues in lower case. Blank lines, Markdown structure, short
+// lines ending a sentence (a paragraph end) and short lines without final
+// punctuation (headings, verse) keep their line breaks. The result only
+// depends on the text, so exports stay reproducible.
+func rewrapText(text string) string {
+	if strings.Contains(text, "```") {
+		return text
+	}
+	paragraphs := strings.Split(text, "\n\n")
+	out := make([]string, 0, len(paragraphs))
+	for _, paragraph := range paragraphs {
+		if paragraph = strings.Trim(paragraph, "\n"); paragraph != "" {
+			out = append(out, rewrapParagraph(paragraph))
+		}
+	}
+	return strings.Join(out, "\n\n")
+}
+
+func rewrapParagraph(paragraph string) string {
+	lines := strings.Split(paragraph, "\n")
+	width := 0
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(line)
+		width = max(width, utf8.RuneCountInString(lines[i]))
+	}
+	var b strings.Builder
+	current, prev := lines[0], lines[0]
+	for _, next := range lines[1:] {
+		if next == "" {
+			continue
+		}
+		prevLen := utf8.RuneCountInString(prev)
+		prev = next
+		switch {
+		case structuralLine.MatchString(next):
+			b.WriteString(current + "\n")
+			current = next
+		case endsSentence(current) && prevLen*4 < width*3:
+			b.WriteString(current + "\n\n")
+			current = next
+		case !endsSentence(current) && prevLen*2 < width:
+			b.WriteString(current + "\n")
+			current = next
+		default:
+			current = joinLines(current, next)
+		}
+	}
+	b.WriteString(current)
+	return b.String()
+}
+
+// joinLines appends next to prev as one line.
+func joinLines(prev, next string) string {
+	last, size := utf8.DecodeLastRuneInString(prev)
+	first, _ := utf8.DecodeRuneInString(next)
+	if last == '-' || last == '\u00ad' || last == '\u2010' {
+		before, _ := utf8.DecodeLastRuneInString(prev[:len(prev)-size])
+		if unicode.IsLetter(before) && unicode.IsLetter(first) {
+			if last == '\u00ad' || unicode.IsLower(first) {
+				return prev[:len(prev)-size] + next
+			}
+			return prev + next
+		}
+	}
+	if unspacedScript(last) || unspacedScript(first) {
+		return prev + next
+	}
+	return prev + " " + next
+}
+
+// unspacedScript reports characters of scripts written without spaces
+// between words, and full-width punctuation. Korean uses spaces.
+func unspacedScript(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Thai, unicode.Lao, unicode.Khmer, unicode.Myanmar) ||
+		(r >= 0x3000 && r <= 0x303f) || (r >= 0xff00 && r <= 0xffef)
+}
+
+// endsSentence reports whether line ends with sentence-final punctuation,
+// possibly followed by closing quotes or brackets.
+func endsSentence(line string) bool {
+	line = strings.TrimRight(line, "\"'”’」』)）]】")
+	last, _ := utf8.DecodeLastRuneInString(line)
+	return strings.ContainsRune(".!?。！？…:：;；", last)
+}
+
+// pageExportText returns the translation of page as it is exported,
+// rewrapped when the task's export settings ask for it.
+func pageExportText(task *model.Task, page *model.PageResult) string {
+	text := normalizeText(page.Translation)
+	if task.ExportSettings != nil && task.ExportSettings.Rewrap {
+		text = rewrapText(text)
+	}
+	return text
+}
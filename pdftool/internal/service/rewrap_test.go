@@ -0,0 +1,49 @@
+package service
+
+import "testing"
+
+func TestRewrapText(t *testing.T) {
+	cases := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			name: "english paragraph",
+			in:   "It was a bright cold day in April, and the\nclocks were striking thirteen. Winston Smith,\nhis chin nuzzled into his breast.",
+			want: "It was a bright cold day in April, and the clocks were striking thirteen. Winston Smith, his chin nuzzled into his breast.",
+		},
+		{
+			name: "hyphenation",
+			in:   "slipped quickly through the glass doors of Vic-\ntory Mansions, though not quickly enough to\nprevent a swirl of gritty dust from North-\nAmerica entering along with him.",
+			want: "slipped quickly through the glass doors of Victory Mansions, though not quickly enough to prevent a swirl of gritty dust from North-America entering along with him.",
+		},
+		{
+			name: "chinese",
+			in:   "那是四月里一个晴朗寒冷的日子，时钟敲了十三\n下。温斯顿·史密斯为了躲避寒风，紧缩着脖子，\n很快地溜进了胜利大厦的玻璃门。",
+			want: "那是四月里一个晴朗寒冷的日子，时钟敲了十三下。温斯顿·史密斯为了躲避寒风，紧缩着脖子，很快地溜进了胜利大厦的玻璃门。",
+		},
+		{
+			name: "short line ends paragraph",
+			in:   "It was a bright cold day in April, and the\nclocks were striking thirteen.\nWinston Smith, his chin nuzzled into his\nbreast in an effort to escape the vile wind.",
+			want: "It was a bright cold day in April, and the clocks were striking thirteen.\n\nWinston Smith, his chin nuzzled into his breast in an effort to escape the vile wind.",
+		},
+		{
+			name: "heading and list",
+			in:   "Chapter 1\nThe hallway smelt of boiled cabbage and old rag\nmats. At one end of it a coloured poster.\n- first item\n- second item",
+			want: "Chapter 1\nThe hallway smelt of boiled cabbage and old rag mats. At one end of it a coloured poster.\n- first item\n- second item",
+		},
+		{
+			name: "paragraphs kept",
+			in:   "First paragraph.\n\n\nSecond paragraph.",
+			want: "First paragraph.\n\nSecond paragraph.",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := rewrapText(tc.in); got != tc.want {
+				t.Errorf("rewrapText() =\n%q\nwant\n%q", got, tc.want)
+			}
+		})
+	}
+}
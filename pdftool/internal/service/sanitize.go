@@ -0,0 +1,67 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"pdftool/internal/pdfutil"
+)
+
+// Upload sanitization modes.
+const (
+	SanitizeDisarm = "disarm"
+	SanitizeReject = "reject"
+	SanitizeOff    = "off"
+)
+
+// ErrUnsafePDF rejects uploads carrying active content.
+var ErrUnsafePDF = errors.New("PDF 包含不安全内容")
+
+var activeContentLabels = map[string]string{
+	pdfutil.FeatureJavaScript:   "JavaScript 脚本",
+	pdfutil.FeatureEmbeddedFile: "嵌入文件",
+	pdfutil.FeatureLaunch:       "启动外部程序的动作",
+	pdfutil.FeatureUndecodable:  "无法解码的对象流",
+}
+
+// sanitizeSource strips JavaScript, embedded files and launch actions from
+// the stored upload before it is rendered or served back, and returns the
+// features it removed. In reject mode, or when the content is hidden in
+// compressed object streams or an object stream cannot be decoded, the upload
+// is refused instead.
+func (s *TaskService) sanitizeSource(path string) ([]string, error) {
+	if s.sanitizeMode == SanitizeOff {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read source file: %w", err)
+	}
+	found := pdfutil.FindActiveContent(data)
+	if len(found.Features) == 0 {
+		return nil, nil
+	}
+	if s.sanitizeMode == SanitizeReject || found.Hidden {
+		labels := make([]string, 0, len(found.Features))
+		for _, feature := range found.Features {
+			labels = append(labels, activeContentLabels[feature])
+		}
+		return nil, fmt.Errorf("%w（%s），请移除后重新上传", ErrUnsafePDF, strings.Join(labels, "、"))
+	}
+	found.Disarm(data)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return nil, fmt.Errorf("write source file: %w", err)
+	}
+	return found.Features, nil
+}
+
+// normalizeSanitizeMode defaults unknown modes to disarming.
+func normalizeSanitizeMode(mode string) string {
+	switch mode = strings.ToLower(strings.TrimSpace(mode)); mode {
+	case SanitizeReject, SanitizeOff:
+		return mode
+	}
+	return SanitizeDisarm
+}
@@ -0,0 +1,253 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"pdftool/internal/model"
+)
+
+const shareIndexFile = "shares.json"
+
+// shareIndex maps share tokens to task IDs.
+type shareIndex map[string]string
+
+func (s *TaskService) shareIndexPath() string {
+	return filepath.Join(s.storageDir, shareIndexFile)
+}
+
+func (s *TaskService) loadSharesLocked() (shareIndex, error) {
+	shares := make(shareIndex)
+	data, err := os.ReadFile(s.shareIndexPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return shares, nil
+		}
+		return nil, fmt.Errorf("读取分享记录失败: %w", err)
+	}
+	if err := json.Unmarshal(data, &shares); err != nil {
+		return nil, fmt.Errorf("解析分享记录失败: %w", err)
+	}
+	return shares, nil
+}
+
+func (s *TaskService) saveSharesLocked(shares shareIndex) error {
+	data, err := json.MarshalIndent(shares, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.shareIndexPath() + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("写入分享记录失败: %w", err)
+	}
+	return os.Rename(tmp, s.shareIndexPath())
+}
+
+// CreateShare issues (or returns the existing) read-only share token for a completed task.
+func (s *TaskService) CreateShare(taskID string) (*model.Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	task, err := s.loadTask(taskID)
+	if err != nil {
+		return nil, err
+	}
+	if task.ShareToken != "" {
+		return task, nil
+	}
+	if summary := summarizeTask(task); summary.PendingPages > 0 {
+		return nil, fmt.Errorf("任务尚未完成，暂不能分享")
+	}
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, fmt.Errorf("生成分享链接失败: %w", err)
+	}
+	token := hex.EncodeToString(buf)
+	shares, err := s.loadSharesLocked()
+	if err != nil {
+		return nil, err
+	}
+	shares[token] = task.ID
+	if err := s.saveSharesLocked(shares); err != nil {
+		return nil, err
+	}
+	task.ShareToken = token
+	task.SharedAt = time.Now()
+	if err := s.saveTaskLocked(task); err != nil {
+		return nil, err
+	}
+	return task, nil
+}
+
+// RevokeShare invalidates the task's share token.
+func (s *TaskService) RevokeShare(taskID string) (*model.Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	task, err := s.loadTask(taskID)
+	if err != nil {
+		return nil, err
+	}
+	if task.ShareToken == "" {
+		return task, nil
+	}
+	shares, err := s.loadSharesLocked()
+	if err != nil {
+		return nil, err
+	}
+	delete(shares, task.ShareToken)
+	if err := s.saveSharesLocked(shares); err != nil {
+		return nil, err
+	}
+	task.ShareToken = ""
+	task.SharedAt = time.Time{}
+	if err := s.saveTaskLocked(task); err != nil {
+		return nil, err
+	}
+	return task, nil
+}
+
+// GetSharedTask resolves a share token to its task.
+func (s *TaskService) GetSharedTask(token string) (*model.Task, error) {
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return nil, fmt.Errorf("分享链接无效")
+	}
+	s.mu.Lock()
+	shares, err := s.loadSharesLocked()
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	taskID, ok := shares[token]
+	if !ok {
+		return nil, fmt.Errorf("分享链接无效或已撤销")
+	}
+	task, err := s.loadTask(taskID)
+	if err != nil {
+		return nil, err
+	}
+	if task.ShareToken != token {
+		return nil, fmt.Errorf("分享链接无效或已撤销")
+	}
+	return task, nil
+}
+
+// ToSharedResponse builds the read-only payload of a share link from an
+// explicit list of fields; provider, owner, source and destination details
+// never reach anonymous viewers. File URLs carry the share token, which opens
+// exactly these files on the static route.
+func (s *TaskService) ToSharedResponse(task *model.Task) *model.SharedTaskResponse {
+	resp := sharedResponse(task)
+	query := "?" + url.Values{"token": {task.ShareToken}}.Encode()
+	mapSharedFileURLs(resp, func(fileURL string) string { return fileURL + query })
+	return resp
+}
+
+// sharedResponse is ToSharedResponse without the share token in the URLs.
+func sharedResponse(task *model.Task) *model.SharedTaskResponse {
+	resp := &model.SharedTaskResponse{
+		FileName:            task.FileName,
+		TotalPages:          task.TotalPages,
+		CreatedAt:           task.CreatedAt,
+		UpdatedAt:           task.UpdatedAt,
+		CombinedTxtURL:      task.CombinedTxtURL,
+		CombinedPDFURL:      task.CombinedPDFURL,
+		FormattedTxtURL:     task.FormattedTxtURL,
+		ConsistentTxtURL:    task.ConsistentTxtURL,
+		CombinedMarkdownURL: task.CombinedMarkdownURL,
+		CombinedDocxURL:     task.CombinedDocxURL,
+		CombinedEpubURL:     task.CombinedEpubURL,
+		PandocExports:       maps.Clone(task.PandocExports),
+		Metadata:            task.Metadata,
+		LayoutMode:          task.LayoutMode,
+		WritingMode:         task.WritingMode,
+		TargetLanguage:      task.TargetLanguage,
+		Outline:             task.Outline,
+		Pages:               make([]*model.SharedPageResponse, 0, len(task.Pages)),
+	}
+	bookmarks := outlineByPage(task)
+	for _, page := range task.Pages {
+		resp.Pages = append(resp.Pages, &model.SharedPageResponse{
+			PageNumber:  page.PageNumber,
+			ImageURL:    page.ImageURL,
+			TextURL:     page.TextURL,
+			SourceText:  page.SourceText,
+			Translation: page.Translation,
+			Footnotes:   page.Footnotes,
+			Elements:    page.Elements,
+			Outline:     bookmarks[page.PageNumber],
+			Blocks:      page.Blocks,
+			Figures:     figureResponses(page.Figures),
+			Status:      page.Status,
+		})
+	}
+	return resp
+}
+
+// mapSharedFileURLs replaces every non-empty file URL of resp with fn(url).
+func mapSharedFileURLs(resp *model.SharedTaskResponse, fn func(string) string) {
+	apply := func(u *string) {
+		if *u != "" {
+			*u = fn(*u)
+		}
+	}
+	for _, u := range []*string{&resp.CombinedTxtURL, &resp.CombinedPDFURL, &resp.FormattedTxtURL,
+		&resp.ConsistentTxtURL, &resp.CombinedMarkdownURL, &resp.CombinedDocxURL, &resp.CombinedEpubURL} {
+		apply(u)
+	}
+	for format, u := range resp.PandocExports {
+		apply(&u)
+		resp.PandocExports[format] = u
+	}
+	for _, page := range resp.Pages {
+		apply(&page.ImageURL)
+		apply(&page.TextURL)
+		for i := range page.Figures {
+			apply(&page.Figures[i].URL)
+		}
+	}
+}
+
+// CheckShareFile reports whether token is the task's share token and rel,
+// the file path below the static prefix, is one of the files its share link
+// shows.
+func (s *TaskService) CheckShareFile(taskID, token, rel string) bool {
+	token = strings.TrimSpace(token)
+	if token == "" || taskID == "" {
+		return false
+	}
+	task, err := s.loadTask(taskID)
+	if err != nil || task.ShareToken == "" ||
+		subtle.ConstantTimeCompare([]byte(task.ShareToken), []byte(token)) != 1 {
+		return false
+	}
+	want := path.Join(s.staticPrefix, strings.TrimPrefix(rel, "/"))
+	shown := false
+	mapSharedFileURLs(sharedResponse(task), func(fileURL string) string {
+		shown = shown || fileURL == want
+		return fileURL
+	})
+	return shown
+}
+
+// removeShareLocked drops the share mapping of a deleted task.
+func (s *TaskService) removeShareLocked(token string) {
+	if token == "" {
+		return
+	}
+	shares, err := s.loadSharesLocked()
+	if err != nil {
+		return
+	}
+	delete(shares, token)
+	s.saveSharesLocked(shares)
+}
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"pdftool/internal/model"
+)
+
+// TestSharedResponseOmitsPrivateFields checks that share links only expose
+// the whitelisted fields, whatever else the task carries.
+func TestSharedResponseOmitsPrivateFields(t *testing.T) {
+	s := newDeterministicService(t)
+	task := writeGoldenTask(t, s, false)
+	task.Provider = model.ProviderInfo{Type: "openai", BaseURL: "https://secret-provider.example", Model: "secret-model"}
+	task.Pages[0].Provider = &model.ProviderInfo{Type: "gemini", BaseURL: "https://secret-page-provider.example"}
+	task.Owner = "secret-owner"
+	task.Source = &model.SourceInfo{Type: "webdav", Path: "/secret/source.pdf"}
+	task.OutputDestination = "s3://secret-bucket/exports"
+	task.RemoteExports = []*model.RemoteExport{{URL: "https://secret-bucket.example/out.pdf"}}
+
+	data, err := json.Marshal(s.ToSharedResponse(task))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "secret") {
+		t.Fatalf("shared response leaks private fields: %s", data)
+	}
+	if !strings.Contains(string(data), "钟敲了十三下") {
+		t.Fatalf("shared response misses the translation: %s", data)
+	}
+}
+
+// TestShareTokenOpensSharedFiles checks that a share token opens the files
+// its share link shows, and only those.
+func TestShareTokenOpensSharedFiles(t *testing.T) {
+	s := newDeterministicService(t)
+	task := writeGoldenTask(t, s, false)
+	task.Pages[0].ImageURL = s.buildFileURL(task.ID, "pages", "page-001.png")
+	task.ShareToken = "share-token"
+	if err := s.saveTask(task); err != nil {
+		t.Fatal(err)
+	}
+	resp := s.ToSharedResponse(task)
+	if want := task.Pages[0].ImageURL + "?token=share-token"; resp.Pages[0].ImageURL != want {
+		t.Fatalf("shared image URL = %q, want %q", resp.Pages[0].ImageURL, want)
+	}
+	rel := "/" + task.ID + "/pages/page-001.png"
+	if !s.CheckShareFile(task.ID, "share-token", rel) {
+		t.Error("share token does not open a shared page image")
+	}
+	if s.CheckShareFile(task.ID, "other-token", rel) {
+		t.Error("wrong share token opens a shared page image")
+	}
+	if s.CheckShareFile(task.ID, "share-token", "/"+task.ID+"/original.pdf") {
+		t.Error("share token opens a file the share link does not show")
+	}
+}
+
+// TestStaticTaskFile checks that the static route serves only files of live
+// tasks, never storage-level files, dot directories or a task's private
+// files.
+func TestStaticTaskFile(t *testing.T) {
+	s := newDeterministicService(t)
+	task := writeGoldenTask(t, s, false)
+	if taskID, ok := s.StaticTaskFile("/" + task.ID + "/pages/page-001.png"); !ok || taskID != task.ID {
+		t.Fatalf("StaticTaskFile(page image) = %q, %v", taskID, ok)
+	}
+	for _, rel := range []string{
+		"/" + AuditLogFile,
+		"/idempotency.json",
+		"/" + QuarantineDirName + "/upload.pdf",
+		"/.trash/" + task.ID + "/original.pdf",
+		"/" + task.ID,
+		"/" + task.ID + "/" + metaFileName,
+		"/" + task.ID + "/" + taskKeyFile,
+		"/" + task.ID + "/" + AccessTokensFile,
+		"/" + task.ID + "/.hidden/file.txt",
+		"/00000000-0000-0000-0000-000000000000/original.pdf",
+	} {
+		if _, ok := s.StaticTaskFile(rel); ok {
+			t.Errorf("StaticTaskFile(%q) is served", rel)
+		}
+	}
+	if err := s.DeleteTask(task.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.StaticTaskFile("/" + task.ID + "/pages/page-001.png"); ok {
+		t.Error("a deleted task's files are still served")
+	}
+}
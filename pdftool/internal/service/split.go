@@ -0,0 +1,287 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"pdftool/internal/model"
+	"pdftool/internal/pdfutil"
+	"pdftool/internal/translator"
+)
+
+// Very large documents can be split into one task per group of chapters.
+// Each part renders and translates only its own pages, so parts run in
+// parallel and a failure or restart only affects one of them; the split
+// report lists them in order for a stitched export.
+const (
+	splitDirName         = ".splits"
+	defaultSplitMinPages = 1000
+	// minSplitPartPages merges short chapters into one part.
+	minSplitPartPages = 50
+	// fallbackSplitPages sizes the parts of a document without an outline.
+	fallbackSplitPages   = 200
+	maxSplitOutlineDepth = 3
+)
+
+// CreateSplitTasks stores the upload and, when it has at least the
+// configured number of pages, plans one task per group of chapters found in
+// its outline, or per fixed page range without one. Smaller documents become
+// a single part. The report is returned at once; the tasks are created one
+// after the other in the background.
+func (s *TaskService) CreateSplitTasks(ctx context.Context, reader io.Reader, fileName string, provider translator.ProviderConfig, settings TranslationSettings) (*model.SplitReport, error) {
+	if reader == nil {
+		return nil, fmt.Errorf("missing file reader")
+	}
+	if strings.TrimSpace(settings.RangeMode) != "" || strings.TrimSpace(settings.RangePages) != "" ||
+		strings.TrimSpace(settings.RangeExclude) != "" || settings.Sample != 0 {
+		return nil, fmt.Errorf("按章节拆分时不支持指定初始页码范围或抽样")
+	}
+	if err := s.checkBudget(); err != nil {
+		return nil, err
+	}
+	if err := s.checkQuota(PrincipalFrom(ctx)); err != nil {
+		return nil, err
+	}
+	if _, err := s.mergeProviderConfig(provider, nil); err != nil && !settings.DryRun {
+		return nil, err
+	}
+
+	report := &model.SplitReport{
+		ID:        uuid.NewString(),
+		FileName:  sanitizeName(fileName),
+		Status:    model.BatchStatusRunning,
+		CreatedAt: time.Now(),
+	}
+	if report.FileName == "" {
+		report.FileName = "document.pdf"
+	}
+	sourcePath := s.splitSourcePath(report.ID)
+	if err := os.MkdirAll(filepath.Dir(sourcePath), 0o755); err != nil {
+		return nil, fmt.Errorf("create split dir: %w", err)
+	}
+	out, err := os.Create(sourcePath)
+	if err != nil {
+		return nil, fmt.Errorf("create source file: %w", err)
+	}
+	if _, err := io.Copy(out, reader); err != nil {
+		out.Close()
+		os.Remove(sourcePath)
+		return nil, fmt.Errorf("write source file: %w", err)
+	}
+	out.Close()
+	outline, err := s.renderer.ReadOutline(ctx, sourcePath)
+	if err != nil {
+		os.Remove(sourcePath)
+		return nil, fmt.Errorf("读取PDF目录失败: %w", err)
+	}
+	report.TotalPages = outline.Pages
+	report.Title = strings.TrimSuffix(report.FileName, filepath.Ext(report.FileName))
+	if info, err := s.renderer.ReadMetadata(ctx, sourcePath); err == nil && info.Title != "" {
+		report.Title = info.Title
+	}
+	if outline.Pages >= s.splitMinPages {
+		report.Parts = planSplit(outline)
+	} else {
+		report.Parts = []*model.SplitPart{{Title: report.Title, FirstPage: 1, LastPage: outline.Pages}}
+	}
+	for _, part := range report.Parts {
+		part.Status = model.BatchStatusPending
+	}
+	if err := s.saveSplit(report); err != nil {
+		os.Remove(sourcePath)
+		return nil, err
+	}
+	log.Printf("split %s (%d pages) into %d parts", report.FileName, report.TotalPages, len(report.Parts))
+	// Parts outlive the request but keep its principal and client.
+	go s.runSplit(context.WithoutCancel(ctx), report, sourcePath, provider, settings)
+	return report, nil
+}
+
+// GetSplit loads a split report.
+func (s *TaskService) GetSplit(splitID string) (*model.SplitReport, error) {
+	data, err := os.ReadFile(s.splitPath(splitID))
+	if err != nil {
+		return nil, fmt.Errorf("读取拆分任务失败: %w", err)
+	}
+	var report model.SplitReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("解析拆分任务失败: %w", err)
+	}
+	return &report, nil
+}
+
+// ExportSplit stitches the translations of the parts created so far into
+// one file, as ExportCombined does, under the document title. The format
+// defaults to TXT.
+func (s *TaskService) ExportSplit(ctx context.Context, splitID, format string) (string, string, error) {
+	report, err := s.GetSplit(splitID)
+	if err != nil {
+		return "", "", err
+	}
+	if strings.TrimSpace(format) == "" {
+		format = ExportTxt
+	}
+	var taskIDs []string
+	for _, part := range report.Parts {
+		if part.TaskID != "" {
+			taskIDs = append(taskIDs, part.TaskID)
+		}
+	}
+	if len(taskIDs) == 0 {
+		return "", "", fmt.Errorf("拆分任务尚未创建任何子任务")
+	}
+	return s.ExportCombined(ctx, taskIDs, format, report.Title)
+}
+
+// runSplit creates the part tasks in order. Each renders only its pages,
+// then starts translating while the next part renders.
+func (s *TaskService) runSplit(ctx context.Context, report *model.SplitReport, sourcePath string, provider translator.ProviderConfig, settings TranslationSettings) {
+	defer os.Remove(sourcePath)
+	for _, part := range report.Parts {
+		task, err := s.createSplitPart(ctx, report, part, sourcePath, provider, settings)
+		if err != nil {
+			part.Status = model.BatchStatusFailed
+			part.Error = err.Error()
+		} else {
+			part.Status = model.BatchStatusCompleted
+			part.TaskID = task.ID
+		}
+		if err := s.saveSplit(report); err != nil {
+			log.Printf("save split %s failed: %v", report.ID, err)
+		}
+	}
+	report.Status = model.BatchStatusCompleted
+	report.FinishedAt = time.Now()
+	if err := s.saveSplit(report); err != nil {
+		log.Printf("save split %s failed: %v", report.ID, err)
+	}
+}
+
+func (s *TaskService) createSplitPart(ctx context.Context, report *model.SplitReport, part *model.SplitPart, sourcePath string, provider translator.ProviderConfig, settings TranslationSettings) (*model.Task, error) {
+	file, err := os.Open(sourcePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	settings.part = &model.TaskPart{
+		Split:       report.ID,
+		Title:       part.Title,
+		FirstPage:   part.FirstPage,
+		LastPage:    part.LastPage,
+		SourcePages: report.TotalPages,
+	}
+	return s.createTask(ctx, file, report.FileName, provider, settings, nil)
+}
+
+// planSplit cuts the document at the entries of the shallowest outline level
+// with at least two chapters, merging chapters shorter than
+// minSplitPartPages into the next. Pages before the first chapter belong to
+// it. Without a usable outline the document is cut every
+// fallbackSplitPages pages.
+func planSplit(outline pdfutil.Outline) []*model.SplitPart {
+	type chapter struct {
+		title string
+		page  int
+	}
+	var chapters []chapter
+	for depth := 1; depth <= maxSplitOutlineDepth && len(chapters) < 2; depth++ {
+		chapters = chapters[:0]
+		for _, entry := range outline.Entries {
+			if entry.Level == depth {
+				chapters = append(chapters, chapter{title: entry.Title, page: entry.Page})
+			}
+		}
+		sort.SliceStable(chapters, func(i, j int) bool { return chapters[i].page < chapters[j].page })
+	}
+	if len(chapters) < 2 {
+		var parts []*model.SplitPart
+		for first := 1; first <= outline.Pages; first += fallbackSplitPages {
+			last := min(first+fallbackSplitPages-1, outline.Pages)
+			parts = append(parts, &model.SplitPart{Title: fmt.Sprintf("第 %d–%d 页", first, last), FirstPage: first, LastPage: last})
+		}
+		return parts
+	}
+	chapters[0].page = 1
+
+	var parts []*model.SplitPart
+	var partChapters [][]string
+	var titles []string
+	first := 1
+	for i, ch := range chapters {
+		titles = append(titles, ch.title)
+		last := outline.Pages
+		if i+1 < len(chapters) {
+			last = chapters[i+1].page - 1
+		}
+		// Chapters starting on the same page share it with the next.
+		if last < first || (last-first+1 < minSplitPartPages && i+1 < len(chapters)) {
+			continue
+		}
+		parts = append(parts, &model.SplitPart{FirstPage: first, LastPage: last})
+		partChapters = append(partChapters, titles)
+		titles = nil
+		first = last + 1
+	}
+	// A short last chapter joins the part before it.
+	if n := len(parts); n > 1 && parts[n-1].LastPage-parts[n-1].FirstPage+1 < minSplitPartPages {
+		parts[n-2].LastPage = parts[n-1].LastPage
+		partChapters[n-2] = append(partChapters[n-2], partChapters[n-1]...)
+		parts = parts[:n-1]
+	}
+	for i, part := range parts {
+		part.Title = partTitle(partChapters[i])
+	}
+	return parts
+}
+
+// partTitle names a part after its first and last chapter.
+func partTitle(titles []string) string {
+	if len(titles) == 1 {
+		return titles[0]
+	}
+	return titles[0] + " – " + titles[len(titles)-1]
+}
+
+func (s *TaskService) splitPath(splitID string) string {
+	return filepath.Join(s.storageDir, splitDirName, filepath.Base(splitID)+".json")
+}
+
+// splitSourcePath holds the upload until every part has copied it.
+func (s *TaskService) splitSourcePath(splitID string) string {
+	return filepath.Join(s.storageDir, splitDirName, filepath.Base(splitID)+".pdf")
+}
+
+func (s *TaskService) saveSplit(report *model.SplitReport) error {
+	dest := s.splitPath(report.ID)
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := dest + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, dest)
+}
+
+// sourcePageIndex returns the 0-based index in the task's source PDF of
+// its page pageNumber.
+func sourcePageIndex(task *model.Task, pageNumber int) int {
+	if task.Part != nil {
+		return task.Part.FirstPage + pageNumber - 2
+	}
+	return pageNumber - 1
+}
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"pdftool/internal/model"
+	"pdftool/internal/pdfutil"
+	"pdftool/internal/translator"
+)
+
+func TestPlanSplit(t *testing.T) {
+	outline := pdfutil.Outline{Pages: 1200, Entries: []pdfutil.OutlineEntry{
+		{Level: 1, Title: "Part One", Page: 5},
+		{Level: 2, Title: "Chapter 1", Page: 6},
+		{Level: 1, Title: "Part Two", Page: 400},
+		{Level: 1, Title: "Interlude", Page: 420},
+		{Level: 1, Title: "Part Three", Page: 800},
+		{Level: 1, Title: "Index", Page: 1190},
+	}}
+	got := planSplit(outline)
+	want := []*model.SplitPart{
+		{Title: "Part One", FirstPage: 1, LastPage: 399},
+		{Title: "Part Two – Interlude", FirstPage: 400, LastPage: 799},
+		{Title: "Part Three – Index", FirstPage: 800, LastPage: 1200},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("planSplit with outline:")
+		for _, part := range got {
+			t.Errorf("  %+v", *part)
+		}
+	}
+
+	got = planSplit(pdfutil.Outline{Pages: 450})
+	want = []*model.SplitPart{
+		{Title: "第 1–200 页", FirstPage: 1, LastPage: 200},
+		{Title: "第 201–400 页", FirstPage: 201, LastPage: 400},
+		{Title: "第 401–450 页", FirstPage: 401, LastPage: 450},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("planSplit without outline:")
+		for _, part := range got {
+			t.Errorf("  %+v", *part)
+		}
+	}
+}
+
+// TestSplitPartPages checks that a part renders only its pages and numbers
+// its export headers as in the whole document.
+func TestSplitPartPages(t *testing.T) {
+	ctx := context.Background()
+	provider := translator.ProviderConfig{Type: translator.ProviderTypeMock}
+	s, err := NewTaskService(t.TempDir(), "/files", "", provider, 2, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	file, err := os.Open(samplePDF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	part := &model.TaskPart{Split: "s", Title: "Chapter 2", FirstPage: 2, LastPage: 3, SourcePages: 3}
+	created, err := s.createTask(ctx, file, "sample.pdf", provider, TranslationSettings{part: part}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	task := waitForState(t, s, created.ID, model.TaskStateCompleted)
+	if task.TotalPages != 2 || len(task.Pages) != 2 {
+		t.Fatalf("part rendered %d pages, want 2", task.TotalPages)
+	}
+	if name := filepath.Base(task.Pages[0].ImagePath); name != "page-002.png" {
+		t.Errorf("first page image %s, want page-002.png", name)
+	}
+	task.ExportSettings = &model.ExportSettings{HeaderTemplate: "{pdf_page}/{total}"}
+	if header, _ := pageHeader(task, task.Pages[1]); header != "3/3" {
+		t.Errorf("header of the last page = %q, want 3/3", header)
+	}
+	if title := documentTitle(task); title != "Chapter 2" {
+		t.Errorf("documentTitle = %q, want the part title", title)
+	}
+}
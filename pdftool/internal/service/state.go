@@ -0,0 +1,159 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"pdftool/internal/model"
+)
+
+const maxStateHistory = 50
+
+// taskTransitions lists the states each state may move to.
+var taskTransitions = map[model.TaskState][]model.TaskState{
+	model.TaskStateRendering:   {model.TaskStateQueued, model.TaskStateFailed, model.TaskStateCanceled},
+	model.TaskStateQueued:      {model.TaskStateTranslating, model.TaskStatePaused, model.TaskStateCompleted, model.TaskStateFailed, model.TaskStateCanceled},
+	model.TaskStateTranslating: {model.TaskStateQueued, model.TaskStatePaused, model.TaskStateCompleted, model.TaskStateFailed, model.TaskStateCanceled},
+	model.TaskStatePaused:      {model.TaskStateQueued, model.TaskStateTranslating, model.TaskStateCanceled},
+	model.TaskStateFormatting:  {model.TaskStateTranslating, model.TaskStateQueued, model.TaskStateCompleted, model.TaskStateFailed, model.TaskStateCanceled},
+	model.TaskStateCompleted:   {model.TaskStateQueued, model.TaskStateTranslating, model.TaskStateFormatting, model.TaskStateFailed},
+	model.TaskStateFailed:      {model.TaskStateQueued, model.TaskStateTranslating, model.TaskStateFormatting, model.TaskStateCompleted, model.TaskStateCanceled},
+	model.TaskStateCanceled:    {model.TaskStateQueued, model.TaskStateTranslating},
+}
+
+// taskState returns the stored state, deriving one for tasks created before
+// states were persisted.
+func taskState(task *model.Task) model.TaskState {
+	if task.State != "" {
+		return task.State
+	}
+	if task.FormattingInProgress {
+		return model.TaskStateFormatting
+	}
+	return settledState(task)
+}
+
+// settledState is the state a task rests in once no work is running on it.
+func settledState(task *model.Task) model.TaskState {
+	if task.Paused {
+		return model.TaskStatePaused
+	}
+	var pending, failed, retrying int
+	for _, page := range task.Pages {
+		switch page.Status {
+		case model.PageStatusPending:
+			pending++
+		case model.PageStatusError:
+			if page.RetryAt.IsZero() {
+				failed++
+			} else {
+				retrying++
+			}
+		case model.PageStatusBlocked:
+			failed++
+		}
+	}
+	switch {
+	case retrying > 0:
+		return model.TaskStateTranslating
+	case pending > 0:
+		return model.TaskStateQueued
+	case failed > 0:
+		return model.TaskStateFailed
+	default:
+		return model.TaskStateCompleted
+	}
+}
+
+// changeTaskState moves the task to next and records the transition. Invalid
+// transitions are logged and ignored. Callers serialize access to the task.
+func changeTaskState(task *model.Task, next model.TaskState, reason string) bool {
+	current := taskState(task)
+	if current == next {
+		task.State = next
+		return false
+	}
+	if !transitionAllowed(current, next) {
+		log.Printf("task %s: ignore state change %s -> %s", task.ID, current, next)
+		return false
+	}
+	task.State = next
+	task.StateHistory = append(task.StateHistory, model.StateTransition{From: current, To: next, At: time.Now(), Reason: reason})
+	if extra := len(task.StateHistory) - maxStateHistory; extra > 0 {
+		task.StateHistory = append([]model.StateTransition(nil), task.StateHistory[extra:]...)
+	}
+	return true
+}
+
+func transitionAllowed(from, to model.TaskState) bool {
+	for _, allowed := range taskTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// toState adapts a fixed state to the callbacks taken by updateTaskState.
+func toState(state model.TaskState) func(*model.Task) model.TaskState {
+	return func(*model.Task) model.TaskState { return state }
+}
+
+// transition changes the stored state and mirrors it onto the in-memory copy
+// held by page workers.
+func (s *TaskService) transition(task *model.Task, next func(*model.Task) model.TaskState, reason string) {
+	_, err := s.updateTask(task.ID, func(current *model.Task) error {
+		changeTaskState(current, next(current), reason)
+		task.State = current.State
+		task.StateHistory = current.StateHistory
+		return nil
+	})
+	if err != nil {
+		log.Printf("save task %s failed: %v", task.ID, err)
+	}
+}
+
+// updateTaskState reloads the task, changes its state and persists it.
+func (s *TaskService) updateTaskState(taskID string, next func(*model.Task) model.TaskState, reason string) error {
+	_, err := s.updateTask(taskID, func(task *model.Task) error {
+		changeTaskState(task, next(task), reason)
+		return nil
+	})
+	return err
+}
+
+// CancelTask stops dispatching the task's remaining pages. Pages already sent
+// to the provider finish, but their results no longer change the state.
+func (s *TaskService) CancelTask(taskID string) (*model.Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	task, err := s.loadTask(taskID)
+	if err != nil {
+		return nil, err
+	}
+	state := taskState(task)
+	if state == model.TaskStateCanceled {
+		return task, nil
+	}
+	if !transitionAllowed(state, model.TaskStateCanceled) {
+		return nil, fmt.Errorf("任务处于 %s 状态，无法取消", state)
+	}
+	s.canceled.Store(taskID, struct{}{})
+	changeTaskState(task, model.TaskStateCanceled, "用户取消")
+	if err := s.saveTaskLocked(task); err != nil {
+		return nil, err
+	}
+	return task, nil
+}
+
+func (s *TaskService) isCanceled(taskID string) bool {
+	_, ok := s.canceled.Load(taskID)
+	return ok
+}
+
+// clearCanceled lets an explicit user action (resume, retranslate, OCR import)
+// bring a canceled task back to work.
+func (s *TaskService) clearCanceled(taskID string) {
+	s.canceled.Delete(taskID)
+}
@@ -0,0 +1,69 @@
+package service
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"pdftool/internal/model"
+)
+
+const (
+	storageWriteAttempts = 3
+	storageRetryDelay    = 100 * time.Millisecond
+)
+
+// StorageError marks a failure to read or write task files, as opposed to a
+// provider failure. It does not count against the provider and the page's
+// translation, if any, is kept.
+type StorageError struct {
+	Op  string
+	Err error
+}
+
+func (e *StorageError) Error() string {
+	return e.Op + ": " + e.Err.Error()
+}
+
+func (e *StorageError) Unwrap() error {
+	return e.Err
+}
+
+// IsStorageError reports whether err is a task file read or write failure.
+func IsStorageError(err error) bool {
+	var storageErr *StorageError
+	return errors.As(err, &storageErr)
+}
+
+// retryStorage runs write up to storageWriteAttempts times with a short
+// backoff, for transient disk errors such as a briefly full or busy volume.
+func retryStorage(op string, write func() error) error {
+	delay := storageRetryDelay
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = write(); err == nil {
+			return nil
+		}
+		if attempt == storageWriteAttempts {
+			return &StorageError{Op: op, Err: err}
+		}
+		log.Printf("%s (attempt %d/%d): %v", op, attempt, storageWriteAttempts, err)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// flushPageTexts writes the TXT of completed pages whose earlier write
+// failed. Callers hold the task for update.
+func (s *TaskService) flushPageTexts(task *model.Task) {
+	for _, page := range task.Pages {
+		if page.StorageError == "" || page.Status != model.PageStatusCompleted {
+			continue
+		}
+		if err := s.writePageText(task, page); err != nil {
+			page.StorageError = err.Error()
+			continue
+		}
+		page.StorageError = ""
+	}
+}
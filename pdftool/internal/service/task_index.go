@@ -0,0 +1,106 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"pdftool/internal/cryptfile"
+	"pdftool/internal/model"
+)
+
+const taskIndexFile = "index.json"
+
+// taskIndex keeps summary records so listings do not parse every meta.json.
+type taskIndex struct {
+	Tasks map[string]*model.TaskSummary `json:"tasks"`
+}
+
+func (s *TaskService) indexPath() string {
+	return filepath.Join(s.storageDir, taskIndexFile)
+}
+
+// loadIndexLocked reads the index file; callers must hold s.mu.
+func (s *TaskService) loadIndexLocked() (*taskIndex, error) {
+	data, err := cryptfile.ReadFile(s.vault.storageKey(), s.indexPath())
+	if err != nil {
+		return nil, err
+	}
+	var idx taskIndex
+	if err := json.Unmarshal(data, &idx); err != nil {
+		return nil, fmt.Errorf("解析任务索引失败: %w", err)
+	}
+	if idx.Tasks == nil {
+		idx.Tasks = make(map[string]*model.TaskSummary)
+	}
+	return &idx, nil
+}
+
+func (s *TaskService) writeIndexLocked(idx *taskIndex) error {
+	data, err := json.MarshalIndent(idx, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.indexPath() + ".tmp"
+	if err := cryptfile.WriteFile(s.vault.storageKey(), tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.indexPath())
+}
+
+// rebuildIndexLocked scans every task directory and rewrites the index.
+func (s *TaskService) rebuildIndexLocked() (*taskIndex, error) {
+	entries, err := os.ReadDir(s.storageDir)
+	if err != nil {
+		return nil, fmt.Errorf("读取任务目录失败: %w", err)
+	}
+	idx := &taskIndex{Tasks: make(map[string]*model.TaskSummary, len(entries))}
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		taskID := entry.Name()
+		task, err := s.loadTask(taskID)
+		if err != nil {
+			log.Printf("skip task %s: %v", taskID, err)
+			continue
+		}
+		idx.Tasks[taskID] = summarizeTask(task)
+	}
+	if err := s.writeIndexLocked(idx); err != nil {
+		return nil, fmt.Errorf("写入任务索引失败: %w", err)
+	}
+	return idx, nil
+}
+
+// updateIndexLocked upserts (summary != nil) or removes a task record.
+func (s *TaskService) updateIndexLocked(taskID string, summary *model.TaskSummary) {
+	idx, err := s.loadIndexLocked()
+	if err != nil {
+		if idx, err = s.rebuildIndexLocked(); err != nil {
+			log.Printf("update task index failed (%s): %v", taskID, err)
+			return
+		}
+	}
+	if summary == nil {
+		delete(idx.Tasks, taskID)
+	} else {
+		idx.Tasks[taskID] = summary
+	}
+	if err := s.writeIndexLocked(idx); err != nil {
+		log.Printf("update task index failed (%s): %v", taskID, err)
+	}
+}
+
+func sortSummaries(summaries []*model.TaskSummary) {
+	sort.Slice(summaries, func(i, j int) bool {
+		if summaries[i].UpdatedAt.Equal(summaries[j].UpdatedAt) {
+			return summaries[i].CreatedAt.After(summaries[j].CreatedAt)
+		}
+		return summaries[i].UpdatedAt.After(summaries[j].UpdatedAt)
+	})
+}
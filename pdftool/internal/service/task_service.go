@@ -1,9 +1,13 @@
 package service
 
 import (
+	"bytes"
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
 	"image/png"
 	"io"
 	"log"
@@ -11,7 +15,6 @@ import (
 	"os"
 	"path"
 	"path/filepath"
-	"sort"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -20,22 +23,174 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/jung-kurt/gofpdf"
+	_ "golang.org/x/image/webp"
 	"golang.org/x/text/encoding/simplifiedchinese"
 
-	"pdftool/internal/assets"
+	"pdftool/internal/avscan"
+	"pdftool/internal/cryptfile"
+	"pdftool/internal/eventbus"
+	"pdftool/internal/hooks"
 	"pdftool/internal/model"
+	"pdftool/internal/notify"
 	"pdftool/internal/pdfutil"
+	"pdftool/internal/publish"
+	"pdftool/internal/respcache"
+	"pdftool/internal/source"
 	"pdftool/internal/translator"
 )
 
 // TaskService coordinates PDF processing and persistence.
 type TaskService struct {
-	storageDir      string
-	staticPrefix    string
-	fontPath        string
-	maxWorkers      int
-	defaultProvider translator.ProviderConfig
-	mu              sync.Mutex
+	storageDir   string
+	staticPrefix string
+	fontPath     string
+	maxWorkers   int
+
+	failureAlertThreshold int
+	storageAlertBytes     int64
+
+	providerImageTypes map[translator.ProviderType][]string
+	pdfFonts           map[string]string
+
+	defaultProvider  translator.ProviderConfig
+	fallbackProvider *translator.ProviderConfig
+	autoExport       bool
+	timeouts         ProviderTimeouts
+	providerTimeouts map[translator.ProviderType]ProviderTimeouts
+	instanceID       string
+	leaseProcess     string
+	budget           BudgetLimits
+	notifier         *notify.Dispatcher
+	sources          *source.Registry
+	publisher        *publish.Publisher
+	pandocPath       string
+	events           *eventbus.Bus
+	batchLimits      BatchLimits
+	splitMinPages    int
+	autoPauseStreak  int
+	pools            *providerPools
+	retries          *retryQueue
+	responseCache    *respcache.Cache
+	trashRetention   time.Duration
+	prompts          promptSettings
+	quotas           quotaSettings
+	hooks            *hooks.Chain
+	renderer         pdfutil.Renderer
+	renderDefaults   pdfutil.RenderOptions
+	sanitizeMode     string
+	scanner          *avscan.Scanner
+	scanFailOpen     bool
+	vault            *vault
+	store            TaskStore
+	deterministic    bool
+	alerts           alertState
+	mu               sync.Mutex
+	budgetMu         sync.Mutex
+	auditMu          sync.Mutex
+	statsMu          sync.Mutex
+	canceled         sync.Map
+	combinedWriters  sync.Map
+	idemMu           sync.Mutex
+	idemInFlight     map[string]bool
+	jobsMu           sync.Mutex
+	feedMu           sync.Mutex
+	clientSlots      *clientSlots
+}
+
+// Options carries optional service settings.
+type Options struct {
+	// InstanceID identifies this replica when claiming storage leases.
+	InstanceID string
+	// Budget caps provider token/currency spend.
+	Budget BudgetLimits
+	// Notifier receives completion and alert events; nil disables notifications.
+	Notifier *notify.Dispatcher
+	// FailureAlertThreshold is the number of consecutive page failures before alerting.
+	FailureAlertThreshold int
+	// StorageAlertBytes triggers an alert once the storage dir grows beyond it.
+	StorageAlertBytes int64
+	// Sources holds cloud drive connectors for imports and write-back.
+	Sources *source.Registry
+	// Publisher uploads exports to per-task output destinations.
+	Publisher *publish.Publisher
+	// PandocPath enables odt/rtf/latex exports when set to a pandoc binary.
+	PandocPath string
+	// Events publishes task lifecycle events to MQTT/NATS and to
+	// SubscribeEvents; nil keeps them in process.
+	Events *eventbus.Bus
+	// BatchLimits bounds URL-list ingestion.
+	BatchLimits BatchLimits
+	// SplitMinPages is the page count from which CreateSplitTasks splits a
+	// document by chapter; zero uses 1000.
+	SplitMinPages int
+	// AutoPauseStreak pauses a task after this many consecutive failed pages;
+	// zero disables auto-pause.
+	AutoPauseStreak int
+	// ProviderWorkers caps concurrent page requests per provider type across tasks.
+	ProviderWorkers map[string]int
+	// Retry schedules automatic retries of pages failing with transient errors.
+	Retry RetryPolicy
+	// ResponseCache replays formatter responses for identical inputs; nil disables it.
+	ResponseCache *respcache.Cache
+	// TrashRetention keeps deleted tasks restorable for this long; zero deletes immediately.
+	TrashRetention time.Duration
+	// AutoExport generates every task's preferred exports when its last page
+	// is translated; tasks can also opt in through their export settings.
+	AutoExport bool
+	// Timeouts are the default connect/request/page limits of provider calls.
+	Timeouts ProviderTimeouts
+	// ProviderTimeouts override Timeouts per provider type; zero fields inherit.
+	ProviderTimeouts map[string]ProviderTimeouts
+	// ProviderImageTypes lists the image MIME types each provider type
+	// accepts; other page images are converted to PNG. Missing types use the
+	// provider's defaults.
+	ProviderImageTypes map[string][]string
+	// RefusalFallback retries pages refused by the provider's content policy
+	// once on this provider; nil marks them blocked right away.
+	RefusalFallback *translator.ProviderConfig
+	// PromptsFile is a JSON file of model.PromptSettings overriding the
+	// built-in provider prompts; overrides saved via the admin API win.
+	PromptsFile string
+	// QuotasFile is a JSON file assigning API keys to users with daily page
+	// and monthly token quotas; empty disables per-user quotas.
+	QuotasFile string
+	// MaxTasksPerClient caps how many tasks of one API key user or IP process
+	// pages at once; further tasks stay queued. Zero is unlimited.
+	MaxTasksPerClient int
+	// Hooks transform the PDF, page images and page text around translation.
+	Hooks *hooks.Chain
+	// Renderer rasterizes uploads; set Isolated to keep MuPDF crashes out of
+	// the server process (the binary must call pdfutil.RunWorker).
+	Renderer pdfutil.Renderer
+	// SanitizeMode handles uploads with JavaScript, embedded files or launch
+	// actions: "disarm" (default) neutralizes them, "reject" refuses the
+	// upload and "off" keeps the file as is.
+	SanitizeMode string
+	// VirusScanner scans uploads with ClamAV before processing; infected
+	// files are quarantined. Nil disables scanning.
+	VirusScanner *avscan.Scanner
+	// VirusScanFailOpen accepts uploads when the scan itself fails instead
+	// of rejecting them.
+	VirusScanFailOpen bool
+	// StorageKey encrypts task metadata, uploaded PDFs, page images and page
+	// texts at rest with AES-GCM; nil stores them in plaintext.
+	StorageKey *cryptfile.Key
+	// StorageKeyMode is "server" to encrypt with StorageKey directly or
+	// "task" for per-task keys wrapped by it.
+	StorageKeyMode string
+	// Deterministic derives task and page IDs from sequence numbers and
+	// fixes export timestamps so identical inputs give identical files;
+	// meant for tests and reproducible builds.
+	Deterministic bool
+	// PDFFonts maps export languages (zh, ja, ko, ar, latin) to TTF fonts
+	// used instead of the font path and the embedded fonts.
+	PDFFonts map[string]string
+	// TaskStore selects where task metadata is kept: TaskStoreSQLite (the
+	// default) or TaskStoreJSON.
+	TaskStore string
+	// Render is the default resolution, format and size limit of page
+	// images; the zero value renders PNGs at 300 DPI.
+	Render pdfutil.RenderOptions
 }
 
 // TranslationSettings controls initial translation behavior.
@@ -44,11 +199,48 @@ type TranslationSettings struct {
 	RangeCustom int
 	RangeStart  int
 	RangeEnd    int
-	BatchLimit  int
+	// RangePages is the page list used by RangeModePages, e.g. "1-3,7,20-25".
+	RangePages string
+	// RangeExclude removes pages from any mode, e.g. covers and appendices "1-4,300-310".
+	RangeExclude string
+	BatchLimit   int
+	// OutputDestination optionally uploads exports to s3://bucket/prefix or a WebDAV URL.
+	OutputDestination string
+	// LayoutMode enables column/region segmentation: "", "crop" or "hint";
+	// "blocks" has the model return text blocks with their positions.
+	LayoutMode string
+	// WritingMode describes the source script direction: "", "vertical" or "rtl".
+	WritingMode string
+	// TargetLanguage names the language to translate into, e.g. "English";
+	// empty keeps the provider's (Simplified Chinese by default).
+	TargetLanguage string
+	// DryRun renders pages and returns a quote without calling the provider.
+	DryRun bool
+	// PreferTextLayer translates pages with a usable embedded text layer
+	// from that text, skipping image recognition.
+	PreferTextLayer bool
+	// ExtractFigures keeps the images embedded in each page, at their native
+	// resolution, for the DOCX and EPUB exports.
+	ExtractFigures bool
+	// Render overrides the server's render options for this task; zero
+	// fields keep the defaults.
+	Render pdfutil.RenderOptions
+	// Sample translates only this many of the selected pages, spread over the
+	// document, to check quality and cost before translating the rest.
+	Sample int
+	// Profile names a saved settings profile; explicit values above take precedence.
+	Profile string
+	// ExportSettings are copied onto the task (usually from a profile).
+	ExportSettings *model.ExportSettings
+	// Project adds the task to a project; its profile applies when Profile is empty.
+	Project string
+	// part limits the task to a page range of the upload; set by
+	// CreateSplitTasks.
+	part *model.TaskPart
 }
 
 // NewTaskService constructs the coordinator.
-func NewTaskService(storageDir, staticPrefix, fontPath string, defaultProvider translator.ProviderConfig, maxWorkers int) (*TaskService, error) {
+func NewTaskService(storageDir, staticPrefix, fontPath string, defaultProvider translator.ProviderConfig, maxWorkers int, opts Options) (*TaskService, error) {
 	if maxWorkers <= 0 {
 		maxWorkers = 1
 	}
@@ -59,34 +251,192 @@ func NewTaskService(storageDir, staticPrefix, fontPath string, defaultProvider t
 		defaultProvider.Timeout = 90 * time.Second
 	}
 	defaultProvider.MaxTokens = translator.SanitizeMaxTokens(defaultProvider.MaxTokens)
-	return &TaskService{
-		storageDir:      storageDir,
-		staticPrefix:    staticPrefix,
-		fontPath:        fontPath,
-		maxWorkers:      maxWorkers,
-		defaultProvider: defaultProvider,
-	}, nil
+	if strings.TrimSpace(opts.InstanceID) == "" {
+		opts.InstanceID = uuid.NewString()
+	}
+	if opts.Publisher == nil {
+		opts.Publisher = publish.New(publish.Config{})
+	}
+	if opts.Events == nil {
+		opts.Events = eventbus.New(eventbus.Config{})
+	}
+	if opts.BatchLimits.MaxURLs <= 0 {
+		opts.BatchLimits.MaxURLs = defaultBatchMaxURLs
+	}
+	if opts.SplitMinPages <= 0 {
+		opts.SplitMinPages = defaultSplitMinPages
+	}
+	if opts.BatchLimits.MaxDownloadBytes <= 0 {
+		opts.BatchLimits.MaxDownloadBytes = defaultDownloadMaxMB << 20
+	}
+	if opts.FailureAlertThreshold <= 0 {
+		opts.FailureAlertThreshold = defaultFailureAlertThreshold
+	}
+	if fallback := opts.RefusalFallback; fallback != nil {
+		cfg := *fallback
+		cfg.Type = translator.NormalizeProviderType(string(cfg.Type))
+		if cfg.Timeout == 0 {
+			cfg.Timeout = defaultProvider.Timeout
+		}
+		cfg.MaxTokens = translator.SanitizeMaxTokens(cfg.MaxTokens)
+		opts.RefusalFallback = &cfg
+	}
+	renderDefaults, err := opts.Render.Normalize()
+	if err != nil {
+		return nil, err
+	}
+	svc := &TaskService{
+		storageDir:       storageDir,
+		staticPrefix:     staticPrefix,
+		fontPath:         fontPath,
+		maxWorkers:       maxWorkers,
+		defaultProvider:  defaultProvider,
+		instanceID:       strings.TrimSpace(opts.InstanceID),
+		leaseProcess:     uuid.NewString(),
+		budget:           opts.Budget,
+		notifier:         opts.Notifier,
+		sources:          opts.Sources,
+		publisher:        opts.Publisher,
+		pandocPath:       strings.TrimSpace(opts.PandocPath),
+		events:           opts.Events,
+		batchLimits:      opts.BatchLimits,
+		splitMinPages:    opts.SplitMinPages,
+		autoPauseStreak:  opts.AutoPauseStreak,
+		pools:            newProviderPools(opts.ProviderWorkers),
+		retries:          newRetryQueue(opts.Retry),
+		responseCache:    opts.ResponseCache,
+		trashRetention:   opts.TrashRetention,
+		fallbackProvider: opts.RefusalFallback,
+		autoExport:       opts.AutoExport,
+		timeouts:         opts.Timeouts,
+		providerTimeouts: normalizeProviderTimeouts(opts.ProviderTimeouts),
+		hooks:            opts.Hooks,
+		renderer:         opts.Renderer,
+		renderDefaults:   renderDefaults,
+		sanitizeMode:     normalizeSanitizeMode(opts.SanitizeMode),
+		scanner:          opts.VirusScanner,
+		scanFailOpen:     opts.VirusScanFailOpen,
+		vault:            newVault(opts.StorageKey, opts.StorageKeyMode),
+		deterministic:    opts.Deterministic,
+
+		failureAlertThreshold: opts.FailureAlertThreshold,
+		storageAlertBytes:     opts.StorageAlertBytes,
+
+		providerImageTypes: normalizeProviderImageTypes(opts.ProviderImageTypes),
+		pdfFonts:           opts.PDFFonts,
+	}
+	store, err := svc.openTaskStore(opts.TaskStore)
+	if err != nil {
+		return nil, err
+	}
+	svc.store = store
+	if err := svc.loadPrompts(opts.PromptsFile); err != nil {
+		store.Close()
+		return nil, err
+	}
+	if opts.MaxTasksPerClient > 0 {
+		svc.clientSlots = newClientSlots(opts.MaxTasksPerClient)
+	}
+	if err := svc.loadQuotas(opts.QuotasFile); err != nil {
+		store.Close()
+		return nil, err
+	}
+	return svc, nil
+}
+
+// Close releases the task store.
+func (s *TaskService) Close() error {
+	return s.store.Close()
 }
 
 // CreateTask reads the uploaded PDF, extracts the pages, and translates them.
 func (s *TaskService) CreateTask(ctx context.Context, reader io.Reader, fileName string, provider translator.ProviderConfig, settings TranslationSettings) (*model.Task, error) {
+	return s.createTask(ctx, reader, fileName, provider, settings, nil)
+}
+
+func (s *TaskService) createTask(ctx context.Context, reader io.Reader, fileName string, provider translator.ProviderConfig, settings TranslationSettings, src *model.SourceInfo) (*model.Task, error) {
 	if reader == nil {
 		return nil, fmt.Errorf("missing file reader")
 	}
-	providerCfg, err := s.mergeProviderConfig(provider, nil)
+	if err := s.checkBudget(); err != nil {
+		return nil, err
+	}
+	owner := PrincipalFrom(ctx)
+	if err := s.checkQuota(owner); err != nil {
+		return nil, err
+	}
+	if settings.Project != "" {
+		project, err := s.loadProject(settings.Project)
+		if err != nil {
+			return nil, err
+		}
+		settings.Project = project.ID
+		if settings.Profile == "" {
+			settings.Profile = project.Profile
+		}
+	}
+	if settings.Profile != "" {
+		profile, err := s.GetProfile(settings.Profile)
+		if err != nil {
+			return nil, err
+		}
+		provider, settings = applyProfile(profile, provider, settings)
+	}
+	destination := strings.TrimSpace(settings.OutputDestination)
+	if destination != "" {
+		if err := s.publisher.Validate(destination); err != nil {
+			return nil, err
+		}
+	}
+	if strings.EqualFold(strings.TrimSpace(settings.RangeMode), RangeModePages) {
+		if _, err := parsePageSpans(settings.RangePages); err != nil {
+			return nil, err
+		}
+	}
+	if strings.TrimSpace(settings.RangeExclude) != "" {
+		if _, err := parsePageSpans(settings.RangeExclude); err != nil {
+			return nil, fmt.Errorf("排除页码: %w", err)
+		}
+	}
+	if settings.Sample < 0 {
+		return nil, fmt.Errorf("抽样页数必须为正整数")
+	}
+	layoutMode, err := validateLayoutMode(settings.LayoutMode)
 	if err != nil {
 		return nil, err
 	}
-	providerCfg.OptimizeLayout = true
-	translatorClient, err := translator.NewTranslator(providerCfg)
+	writingMode, err := validateWritingMode(settings.WritingMode)
 	if err != nil {
 		return nil, err
 	}
-	taskID := uuid.NewString()
-	taskDir := s.taskDir(taskID)
-	if err := os.MkdirAll(taskDir, 0o755); err != nil {
+	if settings.TargetLanguage != "" {
+		provider.TargetLanguage = settings.TargetLanguage
+	}
+	if provider.TargetLanguage, err = validateTargetLanguage(provider.TargetLanguage); err != nil {
+		return nil, err
+	}
+	renderOpts, err := s.renderOptions(settings.Render)
+	if err != nil {
+		return nil, err
+	}
+	// A dry run only needs the provider identity for the quote; the key is
+	// supplied when the task is started.
+	providerCfg, err := s.mergeProviderConfig(provider, nil)
+	if err != nil && !settings.DryRun {
+		return nil, err
+	}
+	providerCfg.OptimizeLayout = true
+	var translatorClient translator.Translator
+	if !settings.DryRun {
+		if translatorClient, err = translator.NewTranslator(providerCfg); err != nil {
+			return nil, err
+		}
+	}
+	taskID, err := s.newTaskDir()
+	if err != nil {
 		return nil, fmt.Errorf("create task dir: %w", err)
 	}
+	taskDir := s.taskDir(taskID)
 
 	safeName := sanitizeName(fileName)
 	if safeName == "" {
@@ -100,50 +450,108 @@ func (s *TaskService) CreateTask(ctx context.Context, reader io.Reader, fileName
 	}
 	if _, err := io.Copy(outFile, reader); err != nil {
 		outFile.Close()
+		os.RemoveAll(taskDir)
+		if errors.Is(err, errDownloadTooLarge) || errors.Is(err, errImportTooLarge) {
+			return nil, err
+		}
 		return nil, fmt.Errorf("write source file: %w", err)
 	}
 	outFile.Close()
-
-	pagesDir := filepath.Join(taskDir, "pages")
-	imagePaths, err := pdfutil.RenderPages(sourcePath, pagesDir)
+	scan, err := s.scanUpload(ctx, sourcePath)
 	if err != nil {
+		os.RemoveAll(taskDir)
 		return nil, err
 	}
+	infected := scan != nil && scan.Status == model.ScanStatusInfected
+	var sanitized []string
+	if !infected {
+		if sanitized, err = s.sanitizeSource(sourcePath); err != nil {
+			os.RemoveAll(taskDir)
+			return nil, err
+		}
+	}
 
 	now := time.Now()
 	task := &model.Task{
-		ID:           taskID,
-		FileName:     safeName,
-		OriginalPath: sourcePath,
-		TotalPages:   len(imagePaths),
-		Pages:        make([]*model.PageResult, 0, len(imagePaths)),
-		CreatedAt:    now,
-		UpdatedAt:    now,
-		Provider: model.ProviderInfo{
-			Type:      string(providerCfg.Type),
-			BaseURL:   providerCfg.BaseURL,
-			Model:     providerCfg.Model,
-			MaxTokens: providerCfg.MaxTokens,
-		},
+		ID:                  taskID,
+		FileName:            safeName,
+		OriginalPath:        sourcePath,
+		CreatedAt:           now,
+		UpdatedAt:           now,
+		Provider:            providerInfo(providerCfg),
 		FormattingOptimized: true,
+		Source:              src,
+		OutputDestination:   destination,
+		SanitizedFeatures:   sanitized,
+		VirusScan:           scan,
+		LayoutMode:          layoutMode,
+		WritingMode:         writingMode,
+		TargetLanguage:      providerCfg.TargetLanguage,
+		Render:              renderSettings(renderOpts),
+		ExportSettings:      settings.ExportSettings,
+		Profile:             settings.Profile,
+		Project:             settings.Project,
+		Part:                settings.part,
+		Owner:               owner,
+		Client:              clientFrom(ctx),
+		State:               model.TaskStateRendering,
+		StateHistory:        []model.StateTransition{{To: model.TaskStateRendering, At: now}},
+	}
+	if infected {
+		return s.quarantineTask(task, sourcePath)
+	}
+	task.Metadata = s.readDocumentMetadata(ctx, sourcePath)
+	task.Outline = s.readDocumentOutline(ctx, sourcePath, task.Part)
+	if err := s.saveTask(task); err != nil {
+		return nil, err
+	}
+	if task.Project != "" {
+		if err := s.addTaskToProject(task.Project, task.ID); err != nil {
+			log.Printf("add task %s to project %s failed: %v", task.ID, task.Project, err)
+		}
 	}
 
-	for idx, imgPath := range imagePaths {
-		base := filepath.Base(imgPath)
+	if err := s.hooks.PreRender(ctx, task.ID, sourcePath); err != nil {
+		s.transition(task, toState(model.TaskStateFailed), err.Error())
+		return nil, fmt.Errorf("PDF 预处理钩子失败: %w", err)
+	}
+	pagesDir := filepath.Join(taskDir, "pages")
+	start, end := 0, 0
+	if task.Part != nil {
+		start, end = task.Part.FirstPage-1, task.Part.LastPage
+	}
+	rendered, err := s.renderer.RenderPageRange(ctx, sourcePath, pagesDir, start, end, renderOpts)
+	if err != nil {
+		s.transition(task, toState(model.TaskStateFailed), err.Error())
+		return nil, err
+	}
+	task.TotalPages = len(rendered)
+	task.Pages = make([]*model.PageResult, 0, len(rendered))
+	for idx, img := range rendered {
+		base := filepath.Base(img.Path)
 		textFile := replaceExt(base, ".txt")
 		page := &model.PageResult{
-			ID:         uuid.NewString(),
-			PageNumber: idx + 1,
-			ImagePath:  imgPath,
-			ImageURL:   s.buildFileURL(task.ID, "pages", base),
-			TextPath:   filepath.Join(pagesDir, textFile),
-			Status:     model.PageStatusPending,
-			UpdatedAt:  now,
+			ID:          s.newPageID(task.ID, idx+1),
+			PageNumber:  idx + 1,
+			ImagePath:   img.Path,
+			ImageURL:    s.buildFileURL(task.ID, "pages", base),
+			ImageWidth:  img.Width,
+			ImageHeight: img.Height,
+			TextPath:    filepath.Join(pagesDir, textFile),
+			Status:      model.PageStatusPending,
+			UpdatedAt:   now,
 		}
 		task.Pages = append(task.Pages, page)
 	}
 
 	selectedMap := determineInitialPageSet(len(task.Pages), settings)
+	if settings.Sample > 0 {
+		task.SamplePages = samplePages(selectedMap, settings.Sample, task.ID)
+		selectedMap = make(map[int]bool, len(task.SamplePages))
+		for _, page := range task.SamplePages {
+			selectedMap[page] = true
+		}
+	}
 	var selectedPages []*model.PageResult
 	now = time.Now()
 	for _, page := range task.Pages {
@@ -156,13 +564,60 @@ func (s *TaskService) CreateTask(ctx context.Context, reader io.Reader, fileName
 		page.SourceText = ""
 		page.Translation = ""
 		page.Error = ""
+		page.BlockReason = ""
 		page.UpdatedAt = now
 	}
+	if settings.PreferTextLayer && len(selectedPages) > 0 {
+		if found := s.applyTextLayer(ctx, task, selectedPages); found > 0 {
+			log.Printf("task %s: %d of %d pages use the text layer", task.ID, found, len(selectedPages))
+		}
+	}
+	if settings.ExtractFigures {
+		s.extractFigures(ctx, task)
+	}
+	var imagePages, textPages []*model.PageResult
+	for _, page := range selectedPages {
+		if isTextPage(page) {
+			textPages = append(textPages, page)
+		} else {
+			imagePages = append(imagePages, page)
+		}
+	}
+	var textClient translator.TextTranslator
+	if len(textPages) > 0 && !settings.DryRun {
+		if textClient, err = translator.NewTextTranslator(providerCfg); err != nil {
+			s.transition(task, toState(model.TaskStateFailed), err.Error())
+			return nil, err
+		}
+	}
+	if settings.DryRun {
+		task.DryRun = true
+		task.Quote = s.quoteTask(task, selectedPages)
+	}
+	changeTaskState(task, settledState(task), "")
+	if err := s.sealRendered(task); err != nil {
+		s.transition(task, toState(model.TaskStateFailed), err.Error())
+		return nil, err
+	}
 
 	if err := s.saveTask(task); err != nil {
 		return nil, err
 	}
-	go s.translateTaskPages(context.Background(), task, selectedPages, translatorClient, settings.BatchLimit)
+	s.publishEvent(eventbus.Event{Kind: eventbus.KindCreated, TaskID: task.ID, FileName: task.FileName, Total: task.TotalPages})
+	go s.checkStorageUsage()
+	if task.DryRun {
+		return task, nil
+	}
+	go func() {
+		if len(imagePages) > 0 {
+			s.translateTaskPages(context.Background(), task, imagePages, translatorClient, settings.BatchLimit)
+		}
+		if len(textPages) > 0 {
+			s.runPageJobs(task, textPages, settings.BatchLimit, func(page *model.PageResult) error {
+				return s.translateTextPage(context.Background(), task, page, textClient)
+			})
+		}
+	}()
 	return task, nil
 }
 
@@ -177,6 +632,9 @@ func (s *TaskService) RetranslatePage(ctx context.Context, taskID string, pageNu
 	if err != nil {
 		return nil, nil, err
 	}
+	if err := s.checkBudget(); err != nil {
+		return nil, nil, err
+	}
 	providerCfg, err := s.mergeProviderConfig(provider, task)
 	if err != nil {
 		return nil, nil, err
@@ -185,15 +643,6 @@ func (s *TaskService) RetranslatePage(ctx context.Context, taskID string, pageNu
 	if err != nil {
 		return nil, nil, err
 	}
-	task.Provider = model.ProviderInfo{
-		Type:      string(providerCfg.Type),
-		BaseURL:   providerCfg.BaseURL,
-		Model:     providerCfg.Model,
-		MaxTokens: providerCfg.MaxTokens,
-	}
-	if err := s.saveTask(task); err != nil {
-		return nil, nil, err
-	}
 	var target *model.PageResult
 	for _, page := range task.Pages {
 		if page.PageNumber == pageNumber {
@@ -204,7 +653,25 @@ func (s *TaskService) RetranslatePage(ctx context.Context, taskID string, pageNu
 	if target == nil {
 		return nil, nil, fmt.Errorf("page %d not found", pageNumber)
 	}
-	if err := s.translateSinglePage(ctx, task, target, translatorClient, true); err != nil {
+	// A retranslation only changes the provider of this page, not the task default.
+	target.Provider = pageProvider(task, providerCfg)
+	claim, err := s.claimLease(s.pageLeasePath(task.ID, pageNumber))
+	if err != nil {
+		return nil, nil, err
+	}
+	if claim == nil {
+		return nil, nil, fmt.Errorf("第%d页正在由其他实例处理", pageNumber)
+	}
+	defer claim.Release()
+	s.clearCanceled(task.ID)
+	if err := s.updateTaskState(task.ID, func(*model.Task) model.TaskState { return model.TaskStateTranslating }, fmt.Sprintf("重新翻译第%d页", pageNumber)); err != nil {
+		return nil, nil, err
+	}
+	err = s.translateSinglePage(ctx, task, target, translatorClient)
+	if stateErr := s.updateTaskState(task.ID, settledState, ""); stateErr != nil {
+		log.Printf("update state of task %s failed: %v", task.ID, stateErr)
+	}
+	if err != nil {
 		return nil, nil, err
 	}
 	updatedTask, err := s.loadTask(taskID)
@@ -222,41 +689,46 @@ func (s *TaskService) RetranslatePage(ctx context.Context, taskID string, pageNu
 }
 
 // MergeText generates a concatenated TXT document from translated pages.
-func (s *TaskService) MergeText(taskID string) (*model.Task, string, error) {
+func (s *TaskService) MergeText(ctx context.Context, taskID string) (*model.Task, string, error) {
 	task, err := s.loadTask(taskID)
 	if err != nil {
 		return nil, "", err
 	}
 
-	combinedText, err := s.buildCombinedText(task)
-	if err != nil {
+	if err := s.writeCombinedText(task); err != nil {
 		return nil, "", err
 	}
-	combinedPath := filepath.Join(s.taskDir(task.ID), "combined.txt")
-	if err := os.WriteFile(combinedPath, []byte(combinedText), 0o644); err != nil {
-		return nil, "", fmt.Errorf("写入TXT失败: %w", err)
-	}
-
-	task.CombinedTxtPath = combinedPath
-	task.CombinedTxtURL = s.buildFileURL(task.ID, "combined.txt")
+	recordExportProgress(task, ExportTxt, "")
+	s.publishExport(ctx, task, task.CombinedTxtPath)
 	if err := s.saveTask(task); err != nil {
 		return nil, "", err
 	}
+	s.publishExportEvent(task, ExportTxt, task.CombinedTxtURL)
 	return task, task.CombinedTxtURL, nil
 }
 
 func (s *TaskService) buildCombinedText(task *model.Task) (string, error) {
+	notesLabel := exportStrings(task).Notes
+	bookmarks := outlineByPage(task)
+	var pending []model.OutlineEntry
 	var builder strings.Builder
 	for _, page := range task.Pages {
+		pending = append(pending, bookmarks[page.PageNumber]...)
 		if !page.HasText {
 			continue
 		}
-		text := strings.TrimSpace(page.Translation)
+		text := pageExportText(task, page)
 		if text == "" {
 			continue
 		}
-		builder.WriteString(fmt.Sprintf("第%d页\n", page.PageNumber))
-		builder.WriteString(text)
+		if len(pending) > 0 {
+			builder.WriteString(outlineText(pending) + "\n")
+			pending = nil
+		}
+		if header, ok := pageHeader(task, page); ok {
+			builder.WriteString(header + "\n")
+		}
+		builder.WriteString(pageTextWithNotes(page, text, notesLabel))
 		builder.WriteString("\n\n")
 	}
 	if builder.Len() == 0 {
@@ -265,64 +737,71 @@ func (s *TaskService) buildCombinedText(task *model.Task) (string, error) {
 	return builder.String(), nil
 }
 
-// MergePDF generates a single PDF that contains translated text or original images.
-func (s *TaskService) MergePDF(taskID string) (*model.Task, string, error) {
+// MergePDF generates a single PDF that contains translated text and/or original
+// images, arranged according to layout (see PDFLayout*). An empty layout uses
+// the task's preferred layout.
+func (s *TaskService) MergePDF(ctx context.Context, taskID, layout string) (*model.Task, string, error) {
+	return s.mergePDF(ctx, taskID, layout, nil)
+}
+
+// mergePDF is MergePDF reporting the number of pages embedded so far to
+// progress, when it is not nil.
+func (s *TaskService) mergePDF(ctx context.Context, taskID, layout string, progress func(completed, total int)) (*model.Task, string, error) {
 	task, err := s.loadTask(taskID)
 	if err != nil {
 		return nil, "", err
 	}
+	layout, err = validatePDFLayout(preferredPDFLayout(task, layout))
+	if err != nil {
+		return nil, "", err
+	}
 
-	pdf := gofpdf.New("P", "mm", "A4", "")
-	fontFamily := s.prepareFont(pdf)
-	for _, page := range task.Pages {
+	pdf := s.newPDF(task)
+	fontFamily := s.prepareFont(pdf, exportLanguage(task))
+	if notice, ok := partialNotice(task); ok {
 		pdf.AddPage()
 		s.setFont(pdf, fontFamily, 12)
-		header := s.encodeText(pdf, fontFamily, fmt.Sprintf("第%d页", page.PageNumber))
-		pdf.MultiCell(0, 6, header, "", "L", false)
-		pdf.Ln(2)
-
-		text := strings.TrimSpace(page.Translation)
-		if page.HasText && text != "" {
-			s.setFont(pdf, fontFamily, 11)
-			pdf.MultiCell(0, 6, s.encodeText(pdf, fontFamily, text), "", "L", false)
-			continue
-		}
-
-		ext := strings.TrimPrefix(strings.ToUpper(filepath.Ext(page.ImagePath)), ".")
-		if ext == "" {
-			ext = "PNG"
+		pdf.MultiCell(0, 6, s.encodeText(pdf, fontFamily, notice), "1", "L", false)
+	}
+	entries := pdfEntries(task, layout)
+	images := s.prefetchPDFImages(entries)
+	defer images.stop()
+	bookmarks := outlineByPage(task)
+	outline := newPDFOutline()
+	for i, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return nil, "", err
 		}
-		opt := gofpdf.ImageOptions{
-			ImageType: ext,
-			ReadDpi:   true,
+		if entry.needsImage() {
+			images.register(pdf, entry.page)
 		}
-		pageWidth, pageHeight := pdf.GetPageSize()
-		margin := 10.0
-		availW := pageWidth - margin*2
-		availH := pageHeight - margin*2
-		displayW, displayH := fitImage(page.ImagePath, availW, availH)
-		if displayW == 0 || displayH == 0 {
-			displayW = availW
-			displayH = availH
+		if entry.prefix == "" {
+			outline.onNextPage(pdf, bookmarks[entry.page.PageNumber])
 		}
-		pdf.ImageOptions(page.ImagePath, margin, margin, displayW, displayH, false, opt, 0, "")
-		if err := pdf.Error(); err != nil {
-			log.Printf("embed image failed (page %d): %v", page.PageNumber, err)
-			pdf.ClearError()
-			pdf.MultiCell(0, 6, "【无法插入原图】", "", "L", false)
+		s.writePDFEntry(pdf, fontFamily, task, entry)
+		if progress != nil {
+			progress(i+1, len(entries))
 		}
 	}
 
 	combinedPath := filepath.Join(s.taskDir(task.ID), "combined.pdf")
-	if err := pdf.OutputFileAndClose(combinedPath); err != nil {
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, "", fmt.Errorf("生成PDF失败: %w", err)
+	}
+	if err := s.writeTaskFileAtomic(task.ID, combinedPath, buf.Bytes()); err != nil {
 		return nil, "", fmt.Errorf("生成PDF失败: %w", err)
 	}
 
 	task.CombinedPDFPath = combinedPath
 	task.CombinedPDFURL = s.buildFileURL(task.ID, "combined.pdf")
+	clearStaleExport(task, ExportPDF)
+	recordExportProgress(task, ExportPDF, layout)
+	s.publishExport(ctx, task, combinedPath)
 	if err := s.saveTask(task); err != nil {
 		return nil, "", err
 	}
+	s.publishExportEvent(task, ExportPDF, task.CombinedPDFURL)
 	return task, task.CombinedPDFURL, nil
 }
 
@@ -338,6 +817,9 @@ func (s *TaskService) FormatTaskLayout(ctx context.Context, taskID string, provi
 		return nil, "", err
 	}
 	log.Printf("start AI layout task=%s model=%s", task.ID, provider.Model)
+	if err := s.checkBudget(); err != nil {
+		return nil, "", err
+	}
 	providerCfg, err := s.mergeProviderConfig(provider, task)
 	if err != nil {
 		return nil, "", err
@@ -346,6 +828,15 @@ func (s *TaskService) FormatTaskLayout(ctx context.Context, taskID string, provi
 	if err != nil {
 		return nil, "", err
 	}
+	formatter = translator.WithFormatterCache(formatter, s.responseCache, providerCfg)
+	claim, err := s.claimLease(s.taskLeasePath(task.ID, "layout"))
+	if err != nil {
+		return nil, "", err
+	}
+	if claim == nil {
+		return nil, "", fmt.Errorf("AI 排版正在由其他实例处理")
+	}
+	defer claim.Release()
 	baseText, err := s.buildCombinedText(task)
 	if err != nil {
 		return nil, "", err
@@ -360,38 +851,96 @@ func (s *TaskService) FormatTaskLayout(ctx context.Context, taskID string, provi
 		t.FormattingInProgress = true
 		t.FormattingTotalChunks = totalChunks
 		t.FormattingCompletedChunks = 0
+		t.FormattingStartedAt = time.Now()
+		changeTaskState(t, model.TaskStateFormatting, "")
 	}); err != nil {
 		return nil, "", err
 	}
+	s.publishFormatting(task, "running", 0, totalChunks, nil)
+	chunkCtx := translator.WithUsageRecorder(ctx, func(u translator.Usage) {
+		s.recordUsage(u)
+		s.addQuotaUsage(task.Owner, 0, int64(u.Total()))
+	})
+	successful := false
+	var completedChunks int
+	var firstErr error
+	defer func() {
+		if successful || totalChunks == 0 {
+			return
+		}
+		s.publishFormatting(task, "error", completedChunks, totalChunks, firstErr)
+		if err := s.updateFormattingState(task.ID, func(t *model.Task) {
+			t.FormattingInProgress = false
+			if t.FormattingTotalChunks == 0 {
+				t.FormattingTotalChunks = totalChunks
+			}
+			t.FormattingCompletedChunks = completedChunks
+			changeTaskState(t, settledState(t), "AI 排版失败")
+		}); err != nil {
+			log.Printf("failed to finalize AI 排版进度(%s): %v", task.ID, err)
+		}
+	}()
+	results, completedChunks, firstErr := s.formatChunks(chunkCtx, "formatter "+task.ID, "AI 排版", formatter, chunks, func(completed int) {
+		if err := s.updateFormattingState(task.ID, func(t *model.Task) {
+			t.FormattingInProgress = true
+			if t.FormattingTotalChunks == 0 {
+				t.FormattingTotalChunks = totalChunks
+			}
+			t.FormattingCompletedChunks = completed
+		}); err != nil {
+			log.Printf("failed to update AI 排版进度(%s): %v", task.ID, err)
+		}
+		s.publishFormatting(task, "running", completed, totalChunks, nil)
+	})
+	if firstErr != nil {
+		return nil, "", firstErr
+	}
+
+	formatted := strings.TrimSpace(strings.Join(results, "\n\n"))
+	if formatted == "" {
+		return nil, "", fmt.Errorf("AI 排版失败，返回内容为空")
+	}
+	formattedPath := filepath.Join(s.taskDir(task.ID), "formatted.txt")
+	if err := s.writeTaskFileAtomic(task.ID, formattedPath, []byte(formatted)); err != nil {
+		return nil, "", fmt.Errorf("写入AI排版TXT失败: %w", err)
+	}
+	if task, err = s.loadTask(task.ID); err != nil {
+		return nil, "", err
+	}
+	task.FormattedByAI = true
+	task.FormattedTxtPath = formattedPath
+	task.FormattedTxtURL = s.buildFileURL(task.ID, "formatted.txt")
+	clearStaleExport(task, ExportFormatted)
+	task.FormattingInProgress = false
+	task.FormattingTotalChunks = totalChunks
+	task.FormattingCompletedChunks = totalChunks
+	changeTaskState(task, settledState(task), "")
+	s.publishExport(ctx, task, formattedPath)
+	if err := s.saveTask(task); err != nil {
+		return nil, "", err
+	}
+	s.publishExportEvent(task, ExportFormatted, task.FormattedTxtURL)
+	successful = true
+	s.publishFormatting(task, "completed", totalChunks, totalChunks, nil)
+	log.Printf("AI layout finished task=%s formattedTxt=%s", task.ID, task.FormattedTxtURL)
+	return task, task.FormattedTxtURL, nil
+}
+
+// formatChunks sends chunks to formatter with adaptive concurrency, retrying
+// rate-limited chunks, and returns the normalized results in order. progress
+// is called with the number of chunks completed so far. A result shorter than
+// half its chunk is taken as truncated; label names the pass in that error.
+// On failure the count of chunks completed before it is returned.
+func (s *TaskService) formatChunks(ctx context.Context, name, label string, formatter translator.TextFormatter, chunks []translator.FormatterChunk, progress func(completed int)) ([]string, int, error) {
 	results := make([]string, len(chunks))
 	chunkCtx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
-	workerLimit := 3
+	workerLimit := formatterMaxWorkers
 	if len(chunks) < workerLimit {
 		workerLimit = len(chunks)
 	}
-	var currentLimit int32 = int32(workerLimit)
-	var activeSlots int32
-	acquireSlot := func() bool {
-		for {
-			if chunkCtx.Err() != nil {
-				return false
-			}
-			curLimit := atomic.LoadInt32(&currentLimit)
-			curActive := atomic.LoadInt32(&activeSlots)
-			if curActive < curLimit {
-				if atomic.CompareAndSwapInt32(&activeSlots, curActive, curActive+1) {
-					return true
-				}
-			} else {
-				time.Sleep(50 * time.Millisecond)
-			}
-		}
-	}
-	releaseSlot := func() {
-		atomic.AddInt32(&activeSlots, -1)
-	}
+	limiter := newAIMDLimiter(name, formatterInitialWorkers, workerLimit)
 
 	var mu sync.Mutex
 	var firstErr error
@@ -407,22 +956,6 @@ func (s *TaskService) FormatTaskLayout(ctx context.Context, taskID string, provi
 	}
 	var wg sync.WaitGroup
 	var completedChunks int32
-	successful := false
-	defer func() {
-		if successful || totalChunks == 0 {
-			return
-		}
-		progress := int(atomic.LoadInt32(&completedChunks))
-		if err := s.updateFormattingState(task.ID, func(t *model.Task) {
-			t.FormattingInProgress = false
-			if t.FormattingTotalChunks == 0 {
-				t.FormattingTotalChunks = totalChunks
-			}
-			t.FormattingCompletedChunks = progress
-		}); err != nil {
-			log.Printf("failed to finalize AI 排版进度(%s): %v", task.ID, err)
-		}
-	}()
 
 	processChunk := func(idx int, chunk translator.FormatterChunk) {
 		defer wg.Done()
@@ -433,42 +966,38 @@ func (s *TaskService) FormatTaskLayout(ctx context.Context, taskID string, provi
 				return
 			default:
 			}
-			if !acquireSlot() {
+			if limiter.acquire(chunkCtx) != nil {
 				return
 			}
 			log.Printf("format chunk %d/%d file=%s size=%d bytes", idx+1, len(chunks), chunk.FileName, len(chunk.Data))
+			start := time.Now()
 			result, err := formatter.Format(chunkCtx, chunk, idx+1)
-			releaseSlot()
+			limiter.release(time.Since(start), err)
 			if err != nil {
-				if formatterIsRateLimit(err) && retries < 3 {
-					if atomic.LoadInt32(&currentLimit) > 1 {
-						log.Printf("chunk %d hit rate limit, lowering concurrency to 1", idx+1)
-						atomic.StoreInt32(&currentLimit, 1)
-					}
+				if isRateLimitError(err) && retries < 3 {
 					retries++
-					time.Sleep(time.Duration(retries) * time.Second)
+					wait := time.Duration(retries) * time.Second
+					if hint := translator.RetryAfter(err); hint > 0 {
+						wait = min(hint, maxProviderHold)
+					}
+					select {
+					case <-time.After(wait):
+					case <-chunkCtx.Done():
+						return
+					}
 					continue
 				}
 				setError(err)
 				return
 			}
-			clean := strings.TrimSpace(result)
+			clean := normalizeText(result)
 			srcLen := len([]rune(string(chunk.Data)))
 			if srcLen > 0 && len([]rune(clean)) < srcLen/2 {
-				setError(fmt.Errorf("AI 排版 chunk %d 返回内容过短，可能被截断", idx+1))
+				setError(fmt.Errorf("%s chunk %d 返回内容过短，可能被截断", label, idx+1))
 				return
 			}
 			results[idx] = clean
-			completed := int(atomic.AddInt32(&completedChunks, 1))
-			if err := s.updateFormattingState(task.ID, func(t *model.Task) {
-				t.FormattingInProgress = true
-				if t.FormattingTotalChunks == 0 {
-					t.FormattingTotalChunks = totalChunks
-				}
-				t.FormattingCompletedChunks = completed
-			}); err != nil {
-				log.Printf("failed to update AI 排版进度(%s): %v", task.ID, err)
-			}
+			progress(int(atomic.AddInt32(&completedChunks, 1)))
 			log.Printf("chunk %d completed, output %d chars", idx+1, len([]rune(clean)))
 			return
 		}
@@ -480,47 +1009,20 @@ func (s *TaskService) FormatTaskLayout(ctx context.Context, taskID string, provi
 	}
 	wg.Wait()
 	if firstErr != nil {
-		return nil, "", firstErr
-	}
-
-	formatted := strings.TrimSpace(strings.Join(results, "\n\n"))
-	if formatted == "" {
-		return nil, "", fmt.Errorf("AI 排版失败，返回内容为空")
+		return nil, int(atomic.LoadInt32(&completedChunks)), firstErr
 	}
-	formattedPath := filepath.Join(s.taskDir(task.ID), "formatted.txt")
-	if err := os.WriteFile(formattedPath, []byte(formatted), 0o644); err != nil {
-		return nil, "", fmt.Errorf("写入AI排版TXT失败: %w", err)
-	}
-	if task, err = s.loadTask(task.ID); err != nil {
-		return nil, "", err
-	}
-	task.FormattedByAI = true
-	task.FormattedTxtPath = formattedPath
-	task.FormattedTxtURL = s.buildFileURL(task.ID, "formatted.txt")
-	task.FormattingInProgress = false
-	task.FormattingTotalChunks = totalChunks
-	task.FormattingCompletedChunks = totalChunks
-	if err := s.saveTask(task); err != nil {
-		return nil, "", err
-	}
-	atomic.StoreInt32(&completedChunks, int32(totalChunks))
-	successful = true
-	log.Printf("AI layout finished task=%s formattedTxt=%s", task.ID, task.FormattedTxtURL)
-	return task, task.FormattedTxtURL, nil
+	return results, len(chunks), nil
 }
 
 func (s *TaskService) updateFormattingState(taskID string, mutate func(*model.Task)) error {
 	if mutate == nil {
 		return nil
 	}
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	task, err := s.loadTask(taskID)
-	if err != nil {
-		return err
-	}
-	mutate(task)
-	return s.saveTaskLocked(task)
+	_, err := s.updateTask(taskID, func(task *model.Task) error {
+		mutate(task)
+		return nil
+	})
+	return err
 }
 
 func (s *TaskService) prepareFormatterChunks(task *model.Task, text string, chunkSize int) ([]translator.FormatterChunk, error) {
@@ -538,7 +1040,7 @@ func (s *TaskService) prepareFormatterChunks(task *model.Task, text string, chun
 		fileName := fmt.Sprintf("chunk-%03d.txt", idx+1)
 		data := []byte(content)
 		path := filepath.Join(chunkDir, fileName)
-		if err := os.WriteFile(path, data, 0o644); err != nil {
+		if err := s.writeTaskFile(s.taskDir(task.ID), path, data); err != nil {
 			return nil, fmt.Errorf("写入排版临时文件失败: %w", err)
 		}
 		log.Printf("prepared formatter chunk %s size=%d bytes", path, len(data))
@@ -569,19 +1071,74 @@ func (s *TaskService) ToResponse(task *model.Task) *model.TaskResponse {
 		FormattingInProgress:      task.FormattingInProgress,
 		FormattingTotalChunks:     task.FormattingTotalChunks,
 		FormattingCompletedChunks: task.FormattingCompletedChunks,
-	}
+		ConsistentTxtURL:          task.ConsistentTxtURL,
+		ConsistencyGlossary:       task.ConsistencyGlossary,
+		ShareToken:                task.ShareToken,
+		Source:                    task.Source,
+		CombinedMarkdownURL:       task.CombinedMarkdownURL,
+		CombinedDocxURL:           task.CombinedDocxURL,
+		CombinedEpubURL:           task.CombinedEpubURL,
+		PandocExports:             task.PandocExports,
+		ContactSheetURL:           task.ContactSheetURL,
+		StaleExports:              task.StaleExports,
+		Exports:                   task.Exports,
+		OutputDestination:         task.OutputDestination,
+		RemoteExports:             task.RemoteExports,
+		FailureStreak:             task.FailureStreak,
+		Paused:                    task.Paused,
+		PauseReason:               task.PauseReason,
+		Metadata:                  task.Metadata,
+		SanitizedFeatures:         task.SanitizedFeatures,
+		Owner:                     task.Owner,
+		VirusScan:                 task.VirusScan,
+		LayoutMode:                task.LayoutMode,
+		WritingMode:               task.WritingMode,
+		TargetLanguage:            task.TargetLanguage,
+		ExportSettings:            task.ExportSettings,
+		ETA:                       s.estimateTask(task),
+		Timing:                    summarizeTiming(task),
+		DryRun:                    task.DryRun,
+		Quote:                     task.Quote,
+		SamplePages:               task.SamplePages,
+		Profile:                   task.Profile,
+		Project:                   task.Project,
+		Part:                      task.Part,
+		Outline:                   task.Outline,
+		State:                     taskState(task),
+		StateHistory:              task.StateHistory,
+	}
+	bookmarks := outlineByPage(task)
 	for _, page := range task.Pages {
 		resp.Pages = append(resp.Pages, &model.PageResponse{
-			ID:          page.ID,
-			PageNumber:  page.PageNumber,
-			ImageURL:    page.ImageURL,
-			TextURL:     page.TextURL,
-			HasText:     page.HasText,
-			SourceText:  page.SourceText,
-			Translation: page.Translation,
-			Status:      page.Status,
-			Error:       page.Error,
-			UpdatedAt:   page.UpdatedAt,
+			ID:            page.ID,
+			PageNumber:    page.PageNumber,
+			ImageURL:      page.ImageURL,
+			TextURL:       page.TextURL,
+			HasText:       page.HasText,
+			SourceText:    page.SourceText,
+			OCRSource:     page.OCRSource,
+			Provider:      page.Provider,
+			Regions:       page.Regions,
+			Footnotes:     page.Footnotes,
+			Elements:      page.Elements,
+			Outline:       bookmarks[page.PageNumber],
+			Blocks:        page.Blocks,
+			Figures:       figureResponses(page.Figures),
+			Translation:   page.Translation,
+			Status:        page.Status,
+			Error:         page.Error,
+			BlockReason:   page.BlockReason,
+			StorageError:  page.StorageError,
+			RetryAttempts: page.RetryAttempts,
+			RetryAt:       retryAtPtr(page),
+			DurationMs:    page.DurationMs,
+			StartedAt:     timePtr(page.StartedAt),
+			FinishedAt:    timePtr(page.FinishedAt),
+			Review:        page.Review,
+			ReviewNote:    page.ReviewNote,
+			ReviewedAt:    timePtr(page.ReviewedAt),
+			HasPrevious:   page.Previous != nil,
+			UpdatedAt:     page.UpdatedAt,
 		})
 	}
 	return resp
@@ -592,6 +1149,14 @@ func (s *TaskService) translateTaskPages(ctx context.Context, task *model.Task,
 		log.Printf("translator is nil, skip translation task %s", task.ID)
 		return
 	}
+	s.runPageJobs(task, pages, batchLimit, func(page *model.PageResult) error {
+		return s.translateSinglePage(ctx, task, page, translatorClient)
+	})
+}
+
+// runPageJobs processes pages on a bounded worker pool, skipping pages that
+// are over budget or claimed by another instance.
+func (s *TaskService) runPageJobs(task *model.Task, pages []*model.PageResult, batchLimit int, process func(*model.PageResult) error) {
 	workerCount := s.maxWorkers
 	if batchLimit > 0 && workerCount > batchLimit {
 		workerCount = batchLimit
@@ -599,9 +1164,17 @@ func (s *TaskService) translateTaskPages(ctx context.Context, task *model.Task,
 	if workerCount > len(pages) {
 		workerCount = len(pages)
 	}
-	if workerCount == 0 {
+	if workerCount == 0 || s.isCanceled(task.ID) {
+		return
+	}
+	releaseSlot, ok := s.acquireClientSlot(task)
+	if !ok {
 		return
 	}
+	s.transition(task, toState(model.TaskStateTranslating), "")
+	// maxWorkers is the ceiling; the limiter starts at half of it and adapts
+	// to how the provider copes with the load.
+	limiter := newAIMDLimiter("task "+task.ID, (workerCount+1)/2, workerCount)
 	jobs := make(chan *model.PageResult)
 	var wg sync.WaitGroup
 	for i := 0; i < workerCount; i++ {
@@ -609,9 +1182,40 @@ func (s *TaskService) translateTaskPages(ctx context.Context, task *model.Task,
 		go func() {
 			defer wg.Done()
 			for page := range jobs {
-				if err := s.translateSinglePage(ctx, task, page, translatorClient, false); err != nil {
+				if s.isPaused(task) || s.isCanceled(task.ID) {
+					continue
+				}
+				if err := s.admitPage(task); err != nil {
+					base := page.UpdatedAt
+					page.Status = model.PageStatusError
+					page.Error = err.Error()
+					page.UpdatedAt = time.Now()
+					s.publishPageEvent(task, page)
+					if err := s.commitPage(task, page, base, false); err != nil {
+						log.Printf("save page %d of task %s failed: %v", page.PageNumber, task.ID, err)
+					}
+					continue
+				}
+				claim, err := s.claimLease(s.pageLeasePath(task.ID, page.PageNumber))
+				if err != nil {
+					log.Printf("claim page %d failed: %v", page.PageNumber, err)
+					continue
+				}
+				if claim == nil {
+					log.Printf("page %d of task %s is claimed by another instance, skip", page.PageNumber, task.ID)
+					continue
+				}
+				s.addQuotaUsage(task.Owner, 1, 0)
+				if err := limiter.acquire(context.Background()); err != nil {
+					claim.Release()
+					continue
+				}
+				start := time.Now()
+				if err := process(page); err != nil {
 					log.Printf("translate page %d failed: %v", page.PageNumber, err)
 				}
+				limiter.release(time.Since(start), pageFailure(page))
+				claim.Release()
 			}
 		}()
 	}
@@ -620,74 +1224,160 @@ func (s *TaskService) translateTaskPages(ctx context.Context, task *model.Task,
 	}
 	close(jobs)
 	wg.Wait()
+	releaseSlot()
+	if !s.isCanceled(task.ID) {
+		s.transition(task, settledState, "")
+	}
+	if _, err := s.updateTask(task.ID, func(current *model.Task) error {
+		inferTitleFromPages(current)
+		s.flushPageTexts(current)
+		return nil
+	}); err != nil {
+		log.Printf("save task %s failed: %v", task.ID, err)
+	}
+	s.regeneratePartialExports(task.ID)
+	s.runAutoExport(task.ID)
+	s.notifyTaskCompleted(task)
 }
 
-func (s *TaskService) translateSinglePage(ctx context.Context, task *model.Task, page *model.PageResult, translatorClient translator.Translator, mergeOnSave bool) error {
-	ctxWithPage := translator.WithPageNumber(ctx, page.PageNumber)
-	result, err := translatorClient.Translate(ctxWithPage, page.ImagePath)
+func (s *TaskService) translateSinglePage(ctx context.Context, task *model.Task, page *model.PageResult, translatorClient translator.Translator) error {
+	base := page.UpdatedAt
+	ctx = WithPrincipal(ctx, task.Owner)
+	release, err := s.pools.acquire(ctx, pageProviderType(task, page))
+	if err != nil {
+		return s.applyPageResult(task, page, base, translator.Result{}, err)
+	}
+	defer release()
+	start := time.Now()
+	ctx, budgetDone, err := s.withPageBudget(ctx, task, page, start)
+	if err != nil {
+		s.scheduleRetry(task, page, err, nil)
+		return s.applyPageResult(task, page, base, translator.Result{}, err)
+	}
+	retry := func(task *model.Task, page *model.PageResult) error {
+		return s.translateSinglePage(context.Background(), task, page, translatorClient)
+	}
+	imagePath, dropPlain, err := s.plainFile(page.ImagePath)
+	defer dropPlain()
+	if err != nil {
+		err = budgetDone(&StorageError{Op: "读取页面图片失败", Err: err})
+		markPageTiming(page, start)
+		s.scheduleRetry(task, page, err, retry)
+		return s.applyPageResult(task, page, base, translator.Result{}, err)
+	}
+	// A damaged image would only waste a provider call; retrying cannot fix
+	// it, re-rendering the page can.
+	if err := checkImageFile(imagePath); err != nil {
+		err = budgetDone(&StorageError{Op: "读取页面图片失败", Err: fmt.Errorf("%w，请重新渲染该页后重试", err)})
+		markPageTiming(page, start)
+		return s.applyPageResult(task, page, base, translator.Result{}, err)
+	}
+	imagePath, cleanup, err := s.hooks.PreTranslate(ctx, task.ID, page.PageNumber, imagePath)
+	defer cleanup()
+	if err != nil {
+		err = budgetDone(fmt.Errorf("图片预处理钩子失败: %w", err))
+		markPageTiming(page, start)
+		return s.applyPageResult(task, page, base, translator.Result{}, err)
+	}
+	// The provider sees the hooked image; the stored page keeps the original.
+	storedImage := page.ImagePath
+	page.ImagePath = imagePath
+	ctxWithPage, finish := s.withProviderStats(translator.WithPageNumber(ctx, page.PageNumber), pageProviderInfo(task, page))
+	ctxWithPage = withWritingModeHint(ctxWithPage, task)
+	ctxWithPage = s.withGlossaryHint(ctxWithPage, task)
+	var result translator.Result
+	if task.LayoutMode != LayoutModeNone {
+		result, err = s.translateWithLayout(ctxWithPage, task, page, translatorClient)
+	} else {
+		result, err = translatorClient.Translate(ctxWithPage, page.ImagePath)
+	}
+	finish(err)
+	s.pools.holdFor(pageProviderType(task, page), err)
+	result, err = s.refusalFallback(ctxWithPage, task, page, result, err, func(ctx context.Context, cfg translator.ProviderConfig) (translator.Result, error) {
+		client, err := translator.NewTranslator(cfg)
+		if err != nil {
+			return translator.Result{}, err
+		}
+		if task.LayoutMode != LayoutModeNone {
+			return s.translateWithLayout(ctx, task, page, client)
+		}
+		return client.Translate(ctx, page.ImagePath)
+	})
+	page.ImagePath = storedImage
+	result, err = s.postTranslate(ctx, task, page, result, err)
+	err = budgetDone(err)
+	markPageTiming(page, start)
+	s.scheduleRetry(task, page, err, retry)
+	return s.applyPageResult(task, page, base, result, err)
+}
+
+// applyPageResult stores a provider result (or error) on the page and commits
+// it; base is the page's UpdatedAt before the request was sent.
+func (s *TaskService) applyPageResult(task *model.Task, page *model.PageResult, base time.Time, result translator.Result, err error) error {
+	// Refusals and storage failures say nothing about provider health, so
+	// they do not count toward failure alerts or auto-pause.
+	outcome := err
+	if translator.IsRefusal(err) || IsStorageError(err) {
+		outcome = nil
+	}
+	s.recordPageOutcome(task, outcome)
+	paused := s.trackTaskFailures(task, outcome)
 	if err != nil {
 		page.Status = model.PageStatusError
 		page.Error = err.Error()
+		page.BlockReason = ""
+		if translator.IsRefusal(err) {
+			page.Status = model.PageStatusBlocked
+			page.BlockReason = translator.RefusalReason(err)
+		}
 		page.UpdatedAt = time.Now()
-		return s.saveTask(task)
+		s.publishPageEvent(task, page)
+		return s.commitPage(task, page, base, paused)
 	}
 
 	page.HasText = result.HasText
-	page.SourceText = strings.TrimSpace(result.SourceText)
-	page.Translation = strings.TrimSpace(result.TranslatedText)
+	page.SourceText = normalizeText(result.SourceText)
+	page.Translation = normalizeText(result.TranslatedText)
+	page.Footnotes = convertFootnotes(result.Footnotes)
+	page.Elements = convertElements(result.Elements)
+	page.Blocks = convertBlocks(result.Blocks)
 	page.Error = ""
+	page.BlockReason = ""
+	page.StorageError = ""
 
-	if page.HasText && page.Translation != "" {
-		if err := os.WriteFile(page.TextPath, []byte(page.Translation), 0o644); err != nil {
-			page.Status = model.PageStatusError
-			page.Error = fmt.Sprintf("写入TXT失败: %v", err)
-			page.UpdatedAt = time.Now()
-			return s.saveTask(task)
-		}
-		page.TextURL = s.buildFileURL(task.ID, "pages", filepath.Base(page.TextPath))
-	} else {
-		os.Remove(page.TextPath)
-		page.TextURL = ""
+	// The translation is saved with the task either way; a page TXT that
+	// cannot be written is retried when the task finishes.
+	if err := s.writePageText(task, page); err != nil {
+		log.Printf("page %d of task %s: %v", page.PageNumber, task.ID, err)
+		page.StorageError = err.Error()
 	}
 
 	page.Status = model.PageStatusCompleted
 	page.UpdatedAt = time.Now()
-	return s.persistPageUpdate(task, page, mergeOnSave)
+	s.publishPageEvent(task, page)
+	return s.commitPage(task, page, base, paused)
 }
 
-func (s *TaskService) persistPageUpdate(task *model.Task, page *model.PageResult, merge bool) error {
-	if !merge {
-		return s.saveTask(task)
+// writePageText stores the page translation as its TXT file, removing the
+// file for pages without text.
+func (s *TaskService) writePageText(task *model.Task, page *model.PageResult) error {
+	if !page.HasText || page.Translation == "" {
+		os.Remove(page.TextPath)
+		page.TextURL = ""
+		return nil
 	}
-	current, err := s.loadTask(task.ID)
+	err := retryStorage("写入TXT失败", func() error {
+		return s.writeTaskFile(s.taskDir(task.ID), page.TextPath, []byte(page.Translation))
+	})
 	if err != nil {
 		return err
 	}
-	replaced := false
-	for idx, existing := range current.Pages {
-		if existing.ID == page.ID {
-			current.Pages[idx] = page
-			replaced = true
-			break
-		}
-	}
-	if !replaced {
-		current.Pages = append(current.Pages, page)
-	}
-	return s.saveTask(current)
+	page.TextURL = s.buildFileURL(task.ID, "pages", filepath.Base(page.TextPath))
+	return nil
 }
 
 func (s *TaskService) loadTask(taskID string) (*model.Task, error) {
-	metaPath := filepath.Join(s.taskDir(taskID), "meta.json")
-	data, err := os.ReadFile(metaPath)
-	if err != nil {
-		return nil, fmt.Errorf("读取任务失败: %w", err)
-	}
-	var task model.Task
-	if err := json.Unmarshal(data, &task); err != nil {
-		return nil, fmt.Errorf("解析任务失败: %w", err)
-	}
-	return &task, nil
+	return s.store.Load(taskID)
 }
 
 func (s *TaskService) saveTask(task *model.Task) error {
@@ -697,23 +1387,53 @@ func (s *TaskService) saveTask(task *model.Task) error {
 }
 
 func (s *TaskService) saveTaskLocked(task *model.Task) error {
-	task.UpdatedAt = time.Now()
-	metaPath := filepath.Join(s.taskDir(task.ID), "meta.json")
-	data, err := json.MarshalIndent(task, "", "  ")
-	if err != nil {
-		return err
-	}
-	tmp := metaPath + ".tmp"
-	if err := os.WriteFile(tmp, data, 0o644); err != nil {
-		return err
+	if s.isCanceled(task.ID) && task.State != model.TaskStateCanceled {
+		// Workers may still hold a copy loaded before the task was canceled.
+		changeTaskState(task, model.TaskStateCanceled, "用户取消")
 	}
-	return os.Rename(tmp, metaPath)
+	task.UpdatedAt = time.Now()
+	_, err := s.store.Save(task)
+	return err
 }
 
 func (s *TaskService) taskDir(taskID string) string {
 	return filepath.Join(s.storageDir, taskID)
 }
 
+// taskPrivateFiles are the files in a task directory that the static route
+// never serves: its metadata, key, token list, event feed and page leases.
+var taskPrivateFiles = map[string]bool{
+	metaFileName:     true,
+	taskKeyFile:      true,
+	AccessTokensFile: true,
+	eventFeedFile:    true,
+	leaseDirName:     true,
+}
+
+// StaticTaskFile reports the task owning rel, a cleaned slash path below
+// the storage dir, if the static route may serve it: a file of a live task
+// outside its private files. Root-level files, dot directories such as the
+// trash and paths of unknown or deleted tasks are refused.
+func (s *TaskService) StaticTaskFile(rel string) (string, bool) {
+	parts := strings.Split(strings.TrimPrefix(rel, "/"), "/")
+	if len(parts) < 2 || taskPrivateFiles[parts[1]] {
+		return "", false
+	}
+	for _, part := range parts {
+		if part == "" || strings.HasPrefix(part, ".") {
+			return "", false
+		}
+	}
+	taskID := parts[0]
+	if _, err := uuid.Parse(taskID); err != nil {
+		return "", false
+	}
+	if _, err := s.loadTask(taskID); err != nil {
+		return "", false
+	}
+	return taskID, true
+}
+
 func (s *TaskService) buildFileURL(taskID string, parts ...string) string {
 	segments := []string{taskID}
 	for _, p := range parts {
@@ -725,29 +1445,11 @@ func (s *TaskService) buildFileURL(taskID string, parts ...string) string {
 
 // ListTasks returns lightweight summaries for all stored tasks.
 func (s *TaskService) ListTasks() ([]*model.TaskSummary, error) {
-	entries, err := os.ReadDir(s.storageDir)
+	summaries, err := s.store.List()
 	if err != nil {
-		return nil, fmt.Errorf("读取任务目录失败: %w", err)
-	}
-	summaries := make([]*model.TaskSummary, 0, len(entries))
-	for _, entry := range entries {
-		if !entry.IsDir() {
-			continue
-		}
-		taskID := entry.Name()
-		task, err := s.loadTask(taskID)
-		if err != nil {
-			log.Printf("skip task %s: %v", taskID, err)
-			continue
-		}
-		summaries = append(summaries, summarizeTask(task))
+		return nil, err
 	}
-	sort.Slice(summaries, func(i, j int) bool {
-		if summaries[i].UpdatedAt.Equal(summaries[j].UpdatedAt) {
-			return summaries[i].CreatedAt.After(summaries[j].CreatedAt)
-		}
-		return summaries[i].UpdatedAt.After(summaries[j].UpdatedAt)
-	})
+	sortSummaries(summaries)
 	return summaries, nil
 }
 
@@ -766,33 +1468,69 @@ func (s *TaskService) DeleteTask(taskID string) error {
 		}
 		return fmt.Errorf("删除任务失败: %w", err)
 	}
-	if err := os.RemoveAll(taskDir); err != nil {
-		return fmt.Errorf("删除任务失败: %w", err)
+	var shareToken string
+	task, err := s.loadTask(taskID)
+	if err == nil {
+		shareToken = task.ShareToken
+	}
+	// Stop dispatching pages of a task that is still running.
+	if err == nil && transitionAllowed(taskState(task), model.TaskStateCanceled) {
+		s.canceled.Store(taskID, struct{}{})
+	}
+	s.combinedWriters.Delete(taskID)
+	if err == nil && s.trashRetention > 0 {
+		if err := s.moveToTrashLocked(task); err != nil {
+			return fmt.Errorf("删除任务失败: %w", err)
+		}
+	} else {
+		if err := s.store.Delete(taskID); err != nil {
+			return err
+		}
+		if err := os.RemoveAll(taskDir); err != nil {
+			return fmt.Errorf("删除任务失败: %w", err)
+		}
 	}
+	s.removeShareLocked(shareToken)
 	return nil
 }
 
 func summarizeTask(task *model.Task) *model.TaskSummary {
-	var completed, pending, failed int
+	var completed, pending, failed, blocked, needsReview, approved int
 	for _, page := range task.Pages {
+		switch page.Review {
+		case model.ReviewNeedsReview:
+			needsReview++
+		case model.ReviewApproved:
+			approved++
+		}
 		switch page.Status {
 		case model.PageStatusCompleted:
 			completed++
 		case model.PageStatusError:
 			failed++
+		case model.PageStatusBlocked:
+			blocked++
 		default:
 			pending++
 		}
 	}
 	return &model.TaskSummary{
-		ID:             task.ID,
-		FileName:       task.FileName,
-		TotalPages:     task.TotalPages,
-		CompletedPages: completed,
-		PendingPages:   pending,
-		ErrorPages:     failed,
-		CreatedAt:      task.CreatedAt,
-		UpdatedAt:      task.UpdatedAt,
+		ID:               task.ID,
+		FileName:         task.FileName,
+		TotalPages:       task.TotalPages,
+		CompletedPages:   completed,
+		PendingPages:     pending,
+		ErrorPages:       failed,
+		BlockedPages:     blocked,
+		NeedsReviewPages: needsReview,
+		ApprovedPages:    approved,
+		State:            taskState(task),
+		FailureStreak:    task.FailureStreak,
+		Paused:           task.Paused,
+		DryRun:           task.DryRun,
+		Project:          task.Project,
+		CreatedAt:        task.CreatedAt,
+		UpdatedAt:        task.UpdatedAt,
 	}
 }
 
@@ -808,21 +1546,83 @@ func sanitizeName(name string) string {
 	return filepath.Base(name)
 }
 
-func fitImage(path string, maxW, maxH float64) (float64, float64) {
+func fitImage(page *model.PageResult, maxW, maxH float64) (float64, float64) {
+	width, height := page.ImageWidth, page.ImageHeight
+	if width <= 0 || height <= 0 {
+		width, height = imageDimensions(page.ImagePath)
+		if width == 0 || height == 0 {
+			return 0, 0
+		}
+		page.ImageWidth, page.ImageHeight = width, height
+	}
+	scale := math.Min(maxW/float64(width), maxH/float64(height))
+	if !math.IsInf(scale, 0) && !math.IsNaN(scale) && scale > 0 {
+		return float64(width) * scale, float64(height) * scale
+	}
+	return maxW, maxH
+}
+
+// imageDimensions reads only the image header using the registered decoders.
+func imageDimensions(path string) (int, int) {
 	file, err := os.Open(path)
 	if err != nil {
 		return 0, 0
 	}
 	defer file.Close()
-	cfg, err := png.DecodeConfig(file)
-	if err != nil || cfg.Width == 0 || cfg.Height == 0 {
+	cfg, _, err := image.DecodeConfig(file)
+	if err != nil {
 		return 0, 0
 	}
-	scale := math.Min(maxW/float64(cfg.Width), maxH/float64(cfg.Height))
-	if !math.IsInf(scale, 0) && !math.IsNaN(scale) && scale > 0 {
-		return float64(cfg.Width) * scale, float64(cfg.Height) * scale
+	return cfg.Width, cfg.Height
+}
+
+// registerTranscodedImage re-encodes formats gofpdf cannot embed (e.g. WebP) as PNG.
+func registerTranscodedImage(pdf *gofpdf.Fpdf, path string, opt gofpdf.ImageOptions) {
+	file, err := os.Open(path)
+	if err != nil {
+		return
 	}
-	return maxW, maxH
+	defer file.Close()
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return
+	}
+	pdf.RegisterImageOptionsReader(path, opt, &buf)
+}
+
+func pdfImageType(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jpg", ".jpeg":
+		return "JPG"
+	case ".gif":
+		return "GIF"
+	default:
+		return "PNG"
+	}
+}
+
+// providerInfo strips credentials from a provider config for persistence.
+func providerInfo(cfg translator.ProviderConfig) model.ProviderInfo {
+	return model.ProviderInfo{
+		Type:      string(cfg.Type),
+		BaseURL:   cfg.BaseURL,
+		Model:     cfg.Model,
+		MaxTokens: cfg.MaxTokens,
+	}
+}
+
+// pageProvider returns the page-level override for cfg, or nil when it matches
+// the task-wide provider.
+func pageProvider(task *model.Task, cfg translator.ProviderConfig) *model.ProviderInfo {
+	info := providerInfo(cfg)
+	if info == task.Provider {
+		return nil
+	}
+	return &info
 }
 
 func (s *TaskService) mergeProviderConfig(input translator.ProviderConfig, task *model.Task) (translator.ProviderConfig, error) {
@@ -840,6 +1640,12 @@ func (s *TaskService) mergeProviderConfig(input translator.ProviderConfig, task
 		if task.Provider.MaxTokens > 0 {
 			cfg.MaxTokens = task.Provider.MaxTokens
 		}
+		if task.TargetLanguage != "" {
+			cfg.TargetLanguage = task.TargetLanguage
+		}
+	}
+	if strings.TrimSpace(input.TargetLanguage) != "" {
+		cfg.TargetLanguage = strings.TrimSpace(input.TargetLanguage)
 	}
 	if strings.TrimSpace(string(input.Type)) != "" {
 		cfg.Type = translator.NormalizeProviderType(string(input.Type))
@@ -865,6 +1671,18 @@ func (s *TaskService) mergeProviderConfig(input translator.ProviderConfig, task
 	}
 	cfg.Type = translator.NormalizeProviderType(string(cfg.Type))
 	cfg.MaxTokens = translator.SanitizeMaxTokens(cfg.MaxTokens)
+	s.applyTimeouts(&cfg, input.Timeout > 0)
+	// Image types set on the default provider only describe that provider.
+	if len(input.ImageTypes) > 0 {
+		cfg.ImageTypes = input.ImageTypes
+	} else if cfg.Type != s.defaultProvider.Type {
+		cfg.ImageTypes = nil
+	}
+	s.applyImageTypes(&cfg)
+	cfg.Prompts = s.providerPrompts()
+	if !translator.RequiresCredentials(cfg.Type) {
+		return cfg, nil
+	}
 	if strings.TrimSpace(cfg.APIKey) == "" {
 		return cfg, fmt.Errorf("缺少 API Key")
 	}
@@ -930,19 +1748,12 @@ func estimateFormatterChunkSize(provider translator.ProviderType, maxTokens int)
 	return size
 }
 
-func formatterIsRateLimit(err error) bool {
-	if err == nil {
-		return false
-	}
-	msg := strings.ToLower(err.Error())
-	return strings.Contains(msg, "429") || strings.Contains(msg, "503") ||
-		strings.Contains(msg, "rate limit") || strings.Contains(msg, "concurrency") ||
-		strings.Contains(msg, "provider_error")
-}
-
 func determineInitialPageSet(total int, settings TranslationSettings) map[int]bool {
 	result := make(map[int]bool)
 	mode := strings.ToLower(strings.TrimSpace(settings.RangeMode))
+	if mode == "none" {
+		return result
+	}
 	switch mode {
 	case "custom":
 		limit := settings.RangeCustom
@@ -955,6 +1766,23 @@ func determineInitialPageSet(total int, settings TranslationSettings) map[int]bo
 		for i := 1; i <= limit; i++ {
 			result[i] = true
 		}
+	case RangeModePages:
+		// An explicit list never falls back to translating everything.
+		spans, err := parsePageSpans(settings.RangePages)
+		if err != nil {
+			return result
+		}
+		result = pageSet(spans, total)
+		excludePages(result, settings.RangeExclude, total)
+		return result
+	case RangeModeOdd, RangeModeEven:
+		first := 1
+		if mode == RangeModeEven {
+			first = 2
+		}
+		for i := first; i <= total; i += 2 {
+			result[i] = true
+		}
 	case "range":
 		start := settings.RangeStart
 		end := settings.RangeEnd
@@ -986,44 +1814,10 @@ func determineInitialPageSet(total int, settings TranslationSettings) map[int]bo
 			result[i] = true
 		}
 	}
+	excludePages(result, settings.RangeExclude, total)
 	return result
 }
 
-func (s *TaskService) prepareFont(pdf *gofpdf.Fpdf) string {
-	fontPath := strings.TrimSpace(s.fontPath)
-	if fontPath == "" {
-		if data := assets.DefaultChineseFont(); len(data) > 0 {
-			fontName := "embedded_cn"
-			pdf.AddUTF8FontFromBytes(fontName, "", data)
-			if err := pdf.Error(); err != nil {
-				log.Printf("加载内置字体失败，将退回默认字体: %v", err)
-				pdf.ClearError()
-				return ""
-			}
-			return fontName
-		}
-		return ""
-	}
-	fontName := "custom_cn"
-	pdf.AddUTF8Font(fontName, "", fontPath)
-	if err := pdf.Error(); err != nil {
-		log.Printf("加载 PDF 字体失败，将退回默认字体: %v", err)
-		pdf.ClearError()
-		if data := assets.DefaultChineseFont(); len(data) > 0 {
-			fallbackName := "embedded_cn"
-			pdf.AddUTF8FontFromBytes(fallbackName, "", data)
-			if err := pdf.Error(); err != nil {
-				log.Printf("加载内置字体失败，将退回默认字体: %v", err)
-				pdf.ClearError()
-				return ""
-			}
-			return fallbackName
-		}
-		return ""
-	}
-	return fontName
-}
-
 func (s *TaskService) setFont(pdf *gofpdf.Fpdf, family string, size float64) {
 	if family != "" {
 		pdf.SetFont(family, "", size)
@@ -1033,6 +1827,7 @@ func (s *TaskService) setFont(pdf *gofpdf.Fpdf, family string, size float64) {
 }
 
 func (s *TaskService) encodeText(pdf *gofpdf.Fpdf, fontFamily, text string) string {
+	text = normalizeText(text)
 	if fontFamily != "" {
 		return text
 	}
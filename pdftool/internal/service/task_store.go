@@ -0,0 +1,147 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"pdftool/internal/model"
+)
+
+// Task store backends.
+const (
+	// TaskStoreSQLite keeps tasks in storageDir/tasks.db, one row per page.
+	TaskStoreSQLite = "sqlite"
+	// TaskStoreJSON keeps each task in its directory's meta.json, with
+	// summaries in index.json.
+	TaskStoreJSON = "json"
+)
+
+const metaFileName = "meta.json"
+
+// TaskStore persists task metadata. Save, Detach, Attach and Delete are
+// called with the service lock held; Load and List are not.
+type TaskStore interface {
+	// Load returns the stored task.
+	Load(taskID string) (*model.Task, error)
+	// Save stores task and returns it with the pages another writer stored
+	// more recently, so a stale copy never rolls a page back.
+	Save(task *model.Task) (*model.Task, error)
+	// Delete drops the task from the store; its files are the caller's.
+	Delete(taskID string) error
+	// Detach writes the task to meta.json in its directory and drops it
+	// from the store, before the directory moves to the trash.
+	Detach(task *model.Task) error
+	// Attach adopts a task directory holding meta.json: a task restored
+	// from the trash or written by the JSON store.
+	Attach(taskID string) (*model.Task, error)
+	// List returns the summaries of all stored tasks.
+	List() ([]*model.TaskSummary, error)
+	Close() error
+}
+
+func (s *TaskService) openTaskStore(kind string) (TaskStore, error) {
+	switch strings.ToLower(strings.TrimSpace(kind)) {
+	case "", TaskStoreSQLite:
+		return openSQLiteTaskStore(s)
+	case TaskStoreJSON:
+		if err := exportSQLiteTasks(s); err != nil {
+			return nil, err
+		}
+		return jsonTaskStore{s: s}, nil
+	}
+	return nil, fmt.Errorf("unknown task store %q", kind)
+}
+
+func (s *TaskService) metaPath(taskID string) string {
+	return filepath.Join(s.taskDir(taskID), metaFileName)
+}
+
+func (s *TaskService) loadTaskFile(metaPath string) (*model.Task, error) {
+	data, err := s.readStored(metaPath)
+	if err != nil {
+		return nil, fmt.Errorf("读取任务失败: %w", err)
+	}
+	var task model.Task
+	if err := json.Unmarshal(data, &task); err != nil {
+		return nil, fmt.Errorf("解析任务失败: %w", err)
+	}
+	return &task, nil
+}
+
+// writeTaskMeta writes task to meta.json in its directory.
+func (s *TaskService) writeTaskMeta(task *model.Task) error {
+	data, err := json.MarshalIndent(task, "", "  ")
+	if err != nil {
+		return err
+	}
+	metaPath := s.metaPath(task.ID)
+	tmp := metaPath + ".tmp"
+	return retryStorage("保存任务失败", func() error {
+		if err := s.writeTaskFile(s.taskDir(task.ID), tmp, data); err != nil {
+			return err
+		}
+		return os.Rename(tmp, metaPath)
+	})
+}
+
+// jsonTaskStore is the original layout: the whole task is reread and
+// rewritten as meta.json on every save.
+type jsonTaskStore struct {
+	s *TaskService
+}
+
+func (j jsonTaskStore) Load(taskID string) (*model.Task, error) {
+	return j.s.loadTaskFile(j.s.metaPath(taskID))
+}
+
+func (j jsonTaskStore) Save(task *model.Task) (*model.Task, error) {
+	if stored, err := j.Load(task.ID); err == nil {
+		task = withNewerPages(task, stored)
+	}
+	if err := j.s.writeTaskMeta(task); err != nil {
+		return nil, err
+	}
+	j.s.updateIndexLocked(task.ID, summarizeTask(task))
+	return task, nil
+}
+
+func (j jsonTaskStore) Delete(taskID string) error {
+	j.s.updateIndexLocked(taskID, nil)
+	return nil
+}
+
+func (j jsonTaskStore) Detach(task *model.Task) error {
+	if _, err := j.Save(task); err != nil {
+		return err
+	}
+	return j.Delete(task.ID)
+}
+
+func (j jsonTaskStore) Attach(taskID string) (*model.Task, error) {
+	return j.Load(taskID)
+}
+
+func (j jsonTaskStore) List() ([]*model.TaskSummary, error) {
+	s := j.s
+	s.mu.Lock()
+	idx, err := s.loadIndexLocked()
+	if err != nil {
+		idx, err = s.rebuildIndexLocked()
+	}
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	summaries := make([]*model.TaskSummary, 0, len(idx.Tasks))
+	for _, summary := range idx.Tasks {
+		summaries = append(summaries, summary)
+	}
+	return summaries, nil
+}
+
+func (j jsonTaskStore) Close() error {
+	return nil
+}
@@ -0,0 +1,419 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+
+	"pdftool/internal/cryptfile"
+	"pdftool/internal/model"
+)
+
+// TaskDBFile is the SQLite task store in the storage dir.
+const TaskDBFile = "tasks.db"
+
+// taskSchema stores the task without its pages in tasks.data and each page
+// in its own row. updated_at, reviewed_at and digest let a save find the
+// pages it has to merge or rewrite without decoding the stored ones.
+const taskSchema = `
+CREATE TABLE IF NOT EXISTS tasks (
+	id      TEXT PRIMARY KEY,
+	data    BLOB NOT NULL,
+	summary BLOB NOT NULL
+);
+CREATE TABLE IF NOT EXISTS pages (
+	task_id     TEXT NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+	page_id     TEXT NOT NULL,
+	position    INTEGER NOT NULL,
+	updated_at  INTEGER NOT NULL,
+	reviewed_at INTEGER NOT NULL,
+	digest      BLOB NOT NULL,
+	data        BLOB NOT NULL,
+	PRIMARY KEY (task_id, page_id)
+);`
+
+// sqliteTaskStore keeps tasks in storageDir/tasks.db. A page update
+// rewrites that page's row instead of the whole task, and writers, including
+// other instances sharing the storage dir, are serialized by SQLite
+// transactions. With encryption at rest, task and page rows are sealed with
+// the task key and summaries with the server key.
+type sqliteTaskStore struct {
+	s  *TaskService
+	db *sql.DB
+}
+
+func openSQLiteTaskStore(s *TaskService) (*sqliteTaskStore, error) {
+	store, err := openTaskDB(s)
+	if err != nil {
+		return nil, err
+	}
+	if err := store.migrate(); err != nil {
+		store.Close()
+		return nil, err
+	}
+	return store, nil
+}
+
+func openTaskDB(s *TaskService) (*sqliteTaskStore, error) {
+	dsn := filepath.Join(s.storageDir, TaskDBFile) + "?_pragma=busy_timeout(10000)&_pragma=foreign_keys(1)&_txlock=immediate"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("打开任务数据库失败: %w", err)
+	}
+	if _, err := db.Exec(taskSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("初始化任务数据库失败: %w", err)
+	}
+	return &sqliteTaskStore{s: s, db: db}, nil
+}
+
+// exportSQLiteTasks moves the tasks of a tasks.db left by the SQLite store
+// back to meta.json files, the reverse of migrate, so switching the store
+// back to json keeps every task.
+func exportSQLiteTasks(s *TaskService) error {
+	if _, err := os.Stat(filepath.Join(s.storageDir, TaskDBFile)); err != nil {
+		return nil
+	}
+	st, err := openTaskDB(s)
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+	rows, err := st.db.Query(`SELECT id FROM tasks`)
+	if err != nil {
+		return fmt.Errorf("读取任务列表失败: %w", err)
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return fmt.Errorf("读取任务列表失败: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("读取任务列表失败: %w", err)
+	}
+	store := jsonTaskStore{s: s}
+	exported := 0
+	for _, id := range ids {
+		task, err := st.Load(id)
+		if err != nil {
+			log.Printf("export task %s failed: %v", id, err)
+			continue
+		}
+		if _, err := os.Stat(s.taskDir(id)); err == nil {
+			if _, err := store.Save(task); err != nil {
+				log.Printf("export task %s failed: %v", id, err)
+				continue
+			}
+			exported++
+		}
+		if err := st.Delete(id); err != nil {
+			return err
+		}
+	}
+	if exported > 0 {
+		log.Printf("exported %d tasks from %s to meta.json", exported, TaskDBFile)
+	}
+	return nil
+}
+
+// migrate imports the task directories still holding a meta.json, written
+// by the JSON store or an older version.
+func (st *sqliteTaskStore) migrate() error {
+	entries, err := os.ReadDir(st.s.storageDir)
+	if err != nil {
+		return fmt.Errorf("读取任务目录失败: %w", err)
+	}
+	migrated := 0
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		if _, err := os.Stat(st.s.metaPath(entry.Name())); err != nil {
+			continue
+		}
+		if _, err := st.Attach(entry.Name()); err != nil {
+			log.Printf("migrate task %s failed: %v", entry.Name(), err)
+			continue
+		}
+		migrated++
+	}
+	if migrated > 0 {
+		log.Printf("migrated %d tasks from meta.json to %s", migrated, TaskDBFile)
+	}
+	return nil
+}
+
+// storedPage is what a save needs to know about a stored page row.
+type storedPage struct {
+	position   int
+	updatedAt  int64
+	reviewedAt int64
+	digest     []byte
+}
+
+func (st *sqliteTaskStore) Load(taskID string) (*model.Task, error) {
+	tx, err := st.db.BeginTx(context.Background(), &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("读取任务失败: %w", err)
+	}
+	defer tx.Rollback()
+	var data []byte
+	err = tx.QueryRow(`SELECT data FROM tasks WHERE id = ?`, taskID).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("读取任务失败: %w", os.ErrNotExist)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取任务失败: %w", err)
+	}
+	key, err := st.s.vault.taskKey(st.s.taskDir(taskID))
+	if err != nil {
+		return nil, fmt.Errorf("读取任务失败: %w", err)
+	}
+	var task model.Task
+	if err := unsealJSON(key, data, &task); err != nil {
+		return nil, fmt.Errorf("解析任务失败: %w", err)
+	}
+	rows, err := tx.Query(`SELECT data FROM pages WHERE task_id = ? ORDER BY position`, taskID)
+	if err != nil {
+		return nil, fmt.Errorf("读取任务失败: %w", err)
+	}
+	defer rows.Close()
+	task.Pages = []*model.PageResult{}
+	for rows.Next() {
+		if err := rows.Scan(&data); err != nil {
+			return nil, fmt.Errorf("读取任务失败: %w", err)
+		}
+		page := new(model.PageResult)
+		if err := unsealJSON(key, data, page); err != nil {
+			return nil, fmt.Errorf("解析任务失败: %w", err)
+		}
+		task.Pages = append(task.Pages, page)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("读取任务失败: %w", err)
+	}
+	return &task, nil
+}
+
+func (st *sqliteTaskStore) Save(task *model.Task) (*model.Task, error) {
+	var saved *model.Task
+	err := retryStorage("保存任务失败", func() error {
+		var err error
+		saved, err = st.save(task)
+		return err
+	})
+	return saved, err
+}
+
+func (st *sqliteTaskStore) save(task *model.Task) (*model.Task, error) {
+	key, err := st.s.vault.taskKey(st.s.taskDir(task.ID))
+	if err != nil {
+		return nil, err
+	}
+	tx, err := st.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	stored, err := storedPages(tx, task.ID)
+	if err != nil {
+		return nil, err
+	}
+	// Merge the pages another writer stored more recently, as withNewerPages
+	// does for meta.json; only those rows are decoded.
+	var newer []*model.PageResult
+	for _, page := range task.Pages {
+		state, ok := stored[page.ID]
+		if !ok || (state.updatedAt <= unixNano(page.UpdatedAt) && state.reviewedAt <= unixNano(page.ReviewedAt)) {
+			continue
+		}
+		var data []byte
+		if err := tx.QueryRow(`SELECT data FROM pages WHERE task_id = ? AND page_id = ?`, task.ID, page.ID).Scan(&data); err != nil {
+			return nil, err
+		}
+		other := new(model.PageResult)
+		if err := unsealJSON(key, data, other); err != nil {
+			return nil, err
+		}
+		newer = append(newer, other)
+	}
+	if len(newer) > 0 {
+		task = withNewerPages(task, &model.Task{Pages: newer})
+	}
+
+	head := *task
+	head.Pages = nil
+	data, err := sealJSON(key, head)
+	if err != nil {
+		return nil, err
+	}
+	summary, err := sealJSON(st.s.vault.storageKey(), summarizeTask(task))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(`INSERT INTO tasks (id, data, summary) VALUES (?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET data = excluded.data, summary = excluded.summary`, task.ID, data, summary); err != nil {
+		return nil, err
+	}
+	upsert, err := tx.Prepare(`INSERT INTO pages (task_id, page_id, position, updated_at, reviewed_at, digest, data) VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (task_id, page_id) DO UPDATE SET position = excluded.position, updated_at = excluded.updated_at,
+		reviewed_at = excluded.reviewed_at, digest = excluded.digest, data = excluded.data`)
+	if err != nil {
+		return nil, err
+	}
+	defer upsert.Close()
+	for i, page := range task.Pages {
+		plain, err := json.Marshal(page)
+		if err != nil {
+			return nil, err
+		}
+		digest := sha256.Sum256(plain)
+		state, ok := stored[page.ID]
+		delete(stored, page.ID)
+		if ok && state.position == i && bytes.Equal(state.digest, digest[:]) {
+			continue
+		}
+		sealed, err := cryptfile.Seal(key, plain)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := upsert.Exec(task.ID, page.ID, i, unixNano(page.UpdatedAt), unixNano(page.ReviewedAt), digest[:], sealed); err != nil {
+			return nil, err
+		}
+	}
+	// Pages left in stored were removed from the task.
+	for pageID := range stored {
+		if _, err := tx.Exec(`DELETE FROM pages WHERE task_id = ? AND page_id = ?`, task.ID, pageID); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return task, nil
+}
+
+func storedPages(tx *sql.Tx, taskID string) (map[string]storedPage, error) {
+	rows, err := tx.Query(`SELECT page_id, position, updated_at, reviewed_at, digest FROM pages WHERE task_id = ?`, taskID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	stored := make(map[string]storedPage)
+	for rows.Next() {
+		var pageID string
+		var state storedPage
+		if err := rows.Scan(&pageID, &state.position, &state.updatedAt, &state.reviewedAt, &state.digest); err != nil {
+			return nil, err
+		}
+		stored[pageID] = state
+	}
+	return stored, rows.Err()
+}
+
+func (st *sqliteTaskStore) Delete(taskID string) error {
+	if _, err := st.db.Exec(`DELETE FROM tasks WHERE id = ?`, taskID); err != nil {
+		return fmt.Errorf("删除任务记录失败: %w", err)
+	}
+	return nil
+}
+
+func (st *sqliteTaskStore) Detach(task *model.Task) error {
+	saved, err := st.Save(task)
+	if err != nil {
+		return err
+	}
+	if err := st.s.writeTaskMeta(saved); err != nil {
+		return err
+	}
+	return st.Delete(task.ID)
+}
+
+func (st *sqliteTaskStore) Attach(taskID string) (*model.Task, error) {
+	metaPath := st.s.metaPath(taskID)
+	task, err := st.s.loadTaskFile(metaPath)
+	if err != nil {
+		return nil, err
+	}
+	if task.ID != taskID {
+		return nil, fmt.Errorf("任务 ID 与目录不一致: %s", task.ID)
+	}
+	saved, err := st.Save(task)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Remove(metaPath); err != nil {
+		log.Printf("remove %s after import failed: %v", metaPath, err)
+	}
+	return saved, nil
+}
+
+func (st *sqliteTaskStore) List() ([]*model.TaskSummary, error) {
+	rows, err := st.db.Query(`SELECT summary FROM tasks`)
+	if err != nil {
+		return nil, fmt.Errorf("读取任务列表失败: %w", err)
+	}
+	defer rows.Close()
+	key := st.s.vault.storageKey()
+	var summaries []*model.TaskSummary
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return nil, fmt.Errorf("读取任务列表失败: %w", err)
+		}
+		summary := new(model.TaskSummary)
+		if err := unsealJSON(key, data, summary); err != nil {
+			log.Printf("skip unreadable task summary: %v", err)
+			continue
+		}
+		summaries = append(summaries, summary)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("读取任务列表失败: %w", err)
+	}
+	return summaries, nil
+}
+
+func (st *sqliteTaskStore) Close() error {
+	return st.db.Close()
+}
+
+func sealJSON(key *cryptfile.Key, v any) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return cryptfile.Seal(key, data)
+}
+
+func unsealJSON(key *cryptfile.Key, data []byte, v any) error {
+	plain, err := cryptfile.Unseal(key, data)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(plain, v)
+}
+
+// unixNano orders page timestamps in SQL; the zero time sorts first.
+func unixNano(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
@@ -0,0 +1,120 @@
+package service
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"pdftool/internal/cryptfile"
+	"pdftool/internal/model"
+	"pdftool/internal/translator"
+)
+
+// TestSQLiteTaskStore migrates an encrypted meta.json task into the SQLite
+// store, then checks that a stale save cannot roll back a page, that the
+// task survives a trip through the trash, and that switching back to the
+// JSON store keeps it.
+func TestSQLiteTaskStore(t *testing.T) {
+	dir := t.TempDir()
+	key, err := cryptfile.NewKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	open := func(store string) *TaskService {
+		s, err := NewTaskService(dir, "/files", "", translator.ProviderConfig{Type: translator.ProviderTypeMock}, 1, Options{
+			TaskStore:      store,
+			StorageKey:     key,
+			StorageKeyMode: StorageKeyTask,
+			TrashRetention: time.Hour,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+
+	legacy := open(TaskStoreJSON)
+	taskID, err := legacy.newTaskDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	task := &model.Task{ID: taskID, FileName: "book.pdf", TotalPages: 3, CreatedAt: time.Now(), State: model.TaskStateCompleted}
+	for number := 1; number <= 3; number++ {
+		task.Pages = append(task.Pages, &model.PageResult{
+			ID:          fmt.Sprintf("p%d", number),
+			PageNumber:  number,
+			Translation: fmt.Sprintf("第%d页", number),
+			Status:      model.PageStatusCompleted,
+			UpdatedAt:   time.Now(),
+		})
+	}
+	if err := legacy.saveTask(task); err != nil {
+		t.Fatal(err)
+	}
+	legacy.Close()
+
+	s := open(TaskStoreSQLite)
+	t.Cleanup(func() { s.Close() })
+	if _, err := os.Stat(s.metaPath(taskID)); !os.IsNotExist(err) {
+		t.Errorf("meta.json still present after migration: %v", err)
+	}
+	summaries, err := s.ListTasks()
+	if err != nil || len(summaries) != 1 || summaries[0].CompletedPages != 3 {
+		t.Fatalf("summaries %v (%v), want one task with 3 completed pages", summaries, err)
+	}
+
+	stale, err := s.loadTask(taskID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stale.Pages) != 3 || stale.Pages[1].Translation != "第2页" {
+		t.Fatalf("migrated pages %+v", stale.Pages)
+	}
+	if _, err := s.updateTask(taskID, func(current *model.Task) error {
+		current.Pages[1].Translation = "新译文"
+		current.Pages[1].UpdatedAt = time.Now()
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	stale.FileName = "renamed.pdf"
+	if err := s.saveTask(stale); err != nil {
+		t.Fatal(err)
+	}
+	stored, err := s.loadTask(taskID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.FileName != "renamed.pdf" || stored.Pages[1].Translation != "新译文" {
+		t.Errorf("after stale save: file %q, page 2 %q", stored.FileName, stored.Pages[1].Translation)
+	}
+
+	if err := s.DeleteTask(taskID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.loadTask(taskID); err == nil {
+		t.Error("trashed task still loads")
+	}
+	restored, err := s.RestoreTask(taskID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(restored.Pages) != 3 || restored.Pages[1].Translation != "新译文" || !restored.DeletedAt.IsZero() {
+		t.Errorf("restored task %+v", restored)
+	}
+	if _, err := os.Stat(s.metaPath(taskID)); !os.IsNotExist(err) {
+		t.Errorf("meta.json left behind after restore: %v", err)
+	}
+
+	s.Close()
+	rollback := open(TaskStoreJSON)
+	summaries, err = rollback.ListTasks()
+	if err != nil || len(summaries) != 1 {
+		t.Fatalf("summaries after switching back to json: %v (%v)", summaries, err)
+	}
+	exported, err := rollback.loadTask(taskID)
+	if err != nil || exported.Pages[1].Translation != "新译文" {
+		t.Fatalf("exported task %+v (%v)", exported, err)
+	}
+}
@@ -0,0 +1,66 @@
+package service
+
+import (
+	"context"
+	"log"
+	"unicode"
+
+	"pdftool/internal/model"
+)
+
+const (
+	// textLayerSource marks pages whose source text came from the PDF's own
+	// text layer rather than from image recognition.
+	textLayerSource = "text-layer"
+	// minTextLayerChars is the letter and digit count below which a text
+	// layer is treated as absent: scans often carry a stray page number or
+	// a watermark and nothing else.
+	minTextLayerChars = 20
+)
+
+// applyTextLayer gives those of pages with a usable embedded text layer their
+// text as source, so they are translated as text and skip image OCR. It
+// reads the source PDF and must run before it is sealed.
+func (s *TaskService) applyTextLayer(ctx context.Context, task *model.Task, pages []*model.PageResult) int {
+	texts, err := s.renderer.ExtractText(ctx, task.OriginalPath)
+	if err != nil {
+		log.Printf("extract text layer of task %s failed: %v", task.ID, err)
+		return 0
+	}
+	found := 0
+	for _, page := range pages {
+		index := sourcePageIndex(task, page.PageNumber)
+		if index >= len(texts) || !usableTextLayer(texts[index]) {
+			continue
+		}
+		page.OCRSource = textLayerSource
+		page.SourceText = rewrapText(normalizeText(texts[index]))
+		page.HasText = true
+		found++
+	}
+	return found
+}
+
+// usableTextLayer reports whether text looks like real page content: enough
+// letters and digits, and few replacement, private-use or control
+// characters, which PDFs with broken font encodings produce instead of text.
+func usableTextLayer(text string) bool {
+	chars, junk := 0, 0
+	for _, r := range text {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			chars++
+		case r == unicode.ReplacementChar, unicode.Is(unicode.Co, r):
+			junk++
+		case unicode.IsControl(r) && r != '\n' && r != '\r' && r != '\t':
+			junk++
+		}
+	}
+	return chars >= minTextLayerChars && junk*20 < chars
+}
+
+// isTextPage reports whether page is translated from its text instead of
+// its image: imported OCR or the PDF's text layer.
+func isTextPage(page *model.PageResult) bool {
+	return page.OCRSource != "" && page.SourceText != ""
+}
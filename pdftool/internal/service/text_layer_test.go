@@ -0,0 +1,40 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"pdftool/internal/model"
+)
+
+// TestPreferTextLayer translates the sample PDF from its embedded text, so
+// every page is a text page and none goes through image recognition.
+func TestPreferTextLayer(t *testing.T) {
+	s := newDeterministicService(t)
+	created := createSampleTask(t, s, TranslationSettings{PreferTextLayer: true})
+	task := waitForState(t, s, created.ID, model.TaskStateCompleted)
+	for _, page := range task.Pages {
+		if page.OCRSource != textLayerSource || page.SourceText == "" || page.Status != model.PageStatusCompleted {
+			t.Errorf("page %d: source %q, status %s, text %q", page.PageNumber, page.OCRSource, page.Status, page.SourceText)
+		}
+	}
+}
+
+func TestUsableTextLayer(t *testing.T) {
+	cases := []struct {
+		text string
+		want bool
+	}{
+		{"", false},
+		{"12", false},
+		{"Chapter One\nIt was a bright cold day in April.", true},
+		{"第一章　四月里一个晴朗寒冷的日子，时钟正敲了十三下。", true},
+		{strings.Repeat("\ufffd", 10) + "Chapter One of a book", false},
+		{strings.Repeat("\ue000a", 30), false},
+	}
+	for _, c := range cases {
+		if got := usableTextLayer(c.text); got != c.want {
+			t.Errorf("usableTextLayer(%q) = %v, want %v", c.text, got, c.want)
+		}
+	}
+}
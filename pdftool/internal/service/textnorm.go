@@ -0,0 +1,52 @@
+package service
+
+import (
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// lineBreaks maps the line and paragraph separators models emit to \n.
+var lineBreaks = strings.NewReplacer(
+	"\r\n", "\n",
+	"\r", "\n",
+	"\u0085", "\n",
+	"\u2028", "\n",
+	"\u2029", "\n\n",
+)
+
+// normalizeText cleans model output before it is stored or exported:
+// invalid UTF-8 is dropped, line breaks become \n, C0/C1 control characters
+// (other than tab and newline), byte-order marks and bidi formatting marks
+// are removed, and the text is NFC-normalized and trimmed. gofpdf renders
+// these characters as garbage or fails on them, and they break RTF and
+// Markdown tooling downstream.
+func normalizeText(text string) string {
+	text = lineBreaks.Replace(strings.ToValidUTF8(text, ""))
+	text = strings.Map(func(r rune) rune {
+		switch {
+		case r == '\n' || r == '\t':
+			return r
+		case r < 0x20, r >= 0x7f && r <= 0x9f:
+			return -1
+		case r == '\ufeff', isBidiControl(r):
+			return -1
+		}
+		return r
+	}, text)
+	return strings.TrimSpace(norm.NFC.String(text))
+}
+
+// isBidiControl reports the implicit marks (LRM, RLM, ALM) and the explicit
+// embedding, override and isolate controls.
+func isBidiControl(r rune) bool {
+	switch {
+	case r == '\u200e', r == '\u200f', r == '\u061c':
+		return true
+	case r >= '\u202a' && r <= '\u202e':
+		return true
+	case r >= '\u2066' && r <= '\u2069':
+		return true
+	}
+	return false
+}
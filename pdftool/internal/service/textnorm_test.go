@@ -0,0 +1,28 @@
+package service
+
+import "testing"
+
+func TestNormalizeText(t *testing.T) {
+	cases := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{name: "clean", in: "第一章\n\n四月里一个晴朗寒冷的日子。", want: "第一章\n\n四月里一个晴朗寒冷的日子。"},
+		{name: "bom and crlf", in: "\ufeffLine one\r\nLine two\rLine three", want: "Line one\nLine two\nLine three"},
+		{name: "separators", in: "a\u2028b\u2029c\u0085d", want: "a\nb\n\nc\nd"},
+		{name: "controls", in: "tab\tkept\x00\x07\x1b[0m\x7f\u0080\u009f", want: "tab\tkept[0m"},
+		{name: "bidi marks", in: "\u202bمرحبا\u202c \u200fworld\u200e\u2067x\u2069", want: "مرحبا worldx"},
+		{name: "nfc", in: "Cafe\u0301 n\u0303", want: "Caf\u00e9 \u00f1"},
+		{name: "invalid utf8", in: "ok\xff\xfeok", want: "okok"},
+		{name: "joiners kept", in: "می\u200cخواهم \U0001F469\u200d\U0001F4BB", want: "می\u200cخواهم \U0001F469\u200d\U0001F4BB"},
+		{name: "trim", in: "\x0c  text \n\n", want: "text"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := normalizeText(tc.in); got != tc.want {
+				t.Errorf("normalizeText(%q) = %q, want %q", tc.in, got, tc.want)
+			}
+		})
+	}
+}
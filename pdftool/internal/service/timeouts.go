@@ -0,0 +1,99 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"pdftool/internal/model"
+	"pdftool/internal/translator"
+)
+
+// ErrPageBudgetExceeded is returned once a page has used up its total time
+// budget across attempts.
+var ErrPageBudgetExceeded = errors.New("页面处理超出总时限")
+
+// ProviderTimeouts splits the time limits of provider calls, since slow
+// first-token models need long responses but connect errors should fail fast.
+type ProviderTimeouts struct {
+	// Connect bounds dialing and the TLS handshake.
+	Connect time.Duration
+	// Request bounds a single provider request.
+	Request time.Duration
+	// PageBudget bounds all attempts on one page, automatic retries and the
+	// refusal fallback included; zero means unlimited.
+	PageBudget time.Duration
+}
+
+// timeoutsFor merges the overrides of providerType over the defaults.
+func (s *TaskService) timeoutsFor(providerType string) ProviderTimeouts {
+	timeouts := s.timeouts
+	override, ok := s.providerTimeouts[translator.NormalizeProviderType(providerType)]
+	if !ok {
+		return timeouts
+	}
+	if override.Connect > 0 {
+		timeouts.Connect = override.Connect
+	}
+	if override.Request > 0 {
+		timeouts.Request = override.Request
+	}
+	if override.PageBudget > 0 {
+		timeouts.PageBudget = override.PageBudget
+	}
+	return timeouts
+}
+
+// applyTimeouts sets the configured connect and request timeouts on cfg;
+// keepRequest preserves a request timeout chosen by the caller.
+func (s *TaskService) applyTimeouts(cfg *translator.ProviderConfig, keepRequest bool) {
+	timeouts := s.timeoutsFor(string(cfg.Type))
+	cfg.ConnectTimeout = timeouts.Connect
+	if timeouts.Request > 0 && !keepRequest {
+		cfg.Timeout = timeouts.Request
+	}
+}
+
+// withPageBudget bounds an attempt by what is left of the page's budget. The
+// budget runs from the first attempt; only scheduled retries (RetryAt set)
+// continue an earlier run. The returned func releases the context and labels
+// errors caused by the budget running out.
+func (s *TaskService) withPageBudget(ctx context.Context, task *model.Task, page *model.PageResult, start time.Time) (context.Context, func(error) error, error) {
+	if page.RetryAt.IsZero() || page.FirstAttemptAt.IsZero() {
+		page.FirstAttemptAt = start
+	}
+	budget := s.timeoutsFor(pageProviderType(task, page)).PageBudget
+	if budget <= 0 {
+		return ctx, func(err error) error { return err }, nil
+	}
+	remaining := budget - start.Sub(page.FirstAttemptAt)
+	if remaining <= 0 {
+		return ctx, nil, fmt.Errorf("%w（%s）", ErrPageBudgetExceeded, budget)
+	}
+	ctx, cancel := context.WithTimeout(ctx, remaining)
+	done := func(err error) error {
+		expired := errors.Is(ctx.Err(), context.DeadlineExceeded)
+		cancel()
+		if err != nil && expired {
+			return fmt.Errorf("%w（%s）: %v", ErrPageBudgetExceeded, budget, err)
+		}
+		return err
+	}
+	return ctx, done, nil
+}
+
+// retryWithinBudget reports whether a retry at the given time still fits the
+// page's budget.
+func (s *TaskService) retryWithinBudget(task *model.Task, page *model.PageResult, at time.Time) bool {
+	budget := s.timeoutsFor(pageProviderType(task, page)).PageBudget
+	return budget <= 0 || page.FirstAttemptAt.IsZero() || at.Sub(page.FirstAttemptAt) < budget
+}
+
+func normalizeProviderTimeouts(overrides map[string]ProviderTimeouts) map[translator.ProviderType]ProviderTimeouts {
+	normalized := make(map[translator.ProviderType]ProviderTimeouts, len(overrides))
+	for name, timeouts := range overrides {
+		normalized[translator.NormalizeProviderType(name)] = timeouts
+	}
+	return normalized
+}
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"sort"
+	"time"
+
+	"pdftool/internal/model"
+)
+
+// slowPageFactor flags pages that took this many times the median duration.
+const slowPageFactor = 3
+
+// markPageTiming records the wall-clock span of a translation attempt.
+func markPageTiming(page *model.PageResult, start time.Time) {
+	page.StartedAt = start
+	page.FinishedAt = time.Now()
+	page.DurationMs = page.FinishedAt.Sub(start).Milliseconds()
+}
+
+// summarizeTiming aggregates page durations so slow pages and provider speed
+// differences stand out. It returns nil until a page has been timed.
+func summarizeTiming(task *model.Task) *model.TaskTiming {
+	timing := &model.TaskTiming{}
+	var durations []int64
+	var first, last time.Time
+	byProvider := make(map[string]*model.ProviderTiming)
+	var order []string
+	for _, page := range task.Pages {
+		if page.DurationMs <= 0 {
+			continue
+		}
+		durations = append(durations, page.DurationMs)
+		timing.TotalPageMs += page.DurationMs
+		if page.DurationMs > timing.MaxPageMs {
+			timing.MaxPageMs = page.DurationMs
+			timing.SlowestPage = page.PageNumber
+		}
+		if !page.StartedAt.IsZero() && (first.IsZero() || page.StartedAt.Before(first)) {
+			first = page.StartedAt
+		}
+		if page.FinishedAt.After(last) {
+			last = page.FinishedAt
+		}
+		info := pageProviderInfo(task, page)
+		key := providerStatsKey(info)
+		entry, ok := byProvider[key]
+		if !ok {
+			entry = &model.ProviderTiming{Provider: info}
+			byProvider[key] = entry
+			order = append(order, key)
+		}
+		entry.Pages++
+		entry.AvgPageMs += page.DurationMs
+	}
+	if len(durations) == 0 {
+		return nil
+	}
+	timing.TimedPages = len(durations)
+	timing.AvgPageMs = timing.TotalPageMs / int64(len(durations))
+	sorted := append([]int64(nil), durations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	timing.MedianPageMs = sorted[len(sorted)/2]
+	for _, page := range task.Pages {
+		if page.DurationMs > 0 && page.DurationMs > timing.MedianPageMs*slowPageFactor {
+			timing.SlowPages = append(timing.SlowPages, page.PageNumber)
+		}
+	}
+	if !first.IsZero() && last.After(first) {
+		timing.WallMs = last.Sub(first).Milliseconds()
+	}
+	for _, key := range order {
+		entry := byProvider[key]
+		entry.AvgPageMs /= int64(entry.Pages)
+		timing.Providers = append(timing.Providers, entry)
+	}
+	return timing
+}
+
+// timePtr omits zero times from API responses.
+func timePtr(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
@@ -0,0 +1,180 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"pdftool/internal/model"
+)
+
+const (
+	trashDirName       = ".trash"
+	trashPurgeInterval = time.Hour
+)
+
+// ErrTaskNotInTrash is returned when restoring or purging an unknown task.
+var ErrTaskNotInTrash = errors.New("回收站中不存在该任务")
+
+func (s *TaskService) trashDir(taskID string) string {
+	return filepath.Join(s.storageDir, trashDirName, taskID)
+}
+
+// moveToTrashLocked stamps the deletion time and moves the task directory into
+// the trash; callers hold s.mu. Trashed tasks keep their metadata in
+// meta.json whichever store is in use.
+func (s *TaskService) moveToTrashLocked(task *model.Task) error {
+	task.DeletedAt = time.Now()
+	// The share link was revoked with the deletion; a restored task starts unshared.
+	task.ShareToken = ""
+	task.SharedAt = time.Time{}
+	if err := s.store.Detach(task); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Join(s.storageDir, trashDirName), 0o755); err != nil {
+		return err
+	}
+	target := s.trashDir(task.ID)
+	if err := os.RemoveAll(target); err != nil {
+		return err
+	}
+	return os.Rename(s.taskDir(task.ID), target)
+}
+
+// ListTrash returns restorable tasks, most recently deleted first.
+func (s *TaskService) ListTrash() ([]*model.TrashEntry, error) {
+	s.purgeExpiredTrash()
+	entries, err := os.ReadDir(filepath.Join(s.storageDir, trashDirName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []*model.TrashEntry{}, nil
+		}
+		return nil, fmt.Errorf("读取回收站失败: %w", err)
+	}
+	list := make([]*model.TrashEntry, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		task, err := s.loadTrashedTask(entry.Name())
+		if err != nil {
+			log.Printf("skip trashed task %s: %v", entry.Name(), err)
+			continue
+		}
+		list = append(list, &model.TrashEntry{
+			ID:         task.ID,
+			FileName:   task.FileName,
+			TotalPages: task.TotalPages,
+			DeletedAt:  task.DeletedAt,
+			ExpiresAt:  task.DeletedAt.Add(s.trashRetention),
+		})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].DeletedAt.After(list[j].DeletedAt) })
+	return list, nil
+}
+
+// RestoreTask moves a trashed task back into the task list.
+func (s *TaskService) RestoreTask(taskID string) (*model.Task, error) {
+	taskID = strings.TrimSpace(taskID)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	task, err := s.loadTrashedTask(taskID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(s.taskDir(taskID)); err == nil {
+		return nil, fmt.Errorf("同名任务已存在，无法恢复")
+	}
+	if err := os.Rename(s.trashDir(taskID), s.taskDir(taskID)); err != nil {
+		return nil, fmt.Errorf("恢复任务失败: %w", err)
+	}
+	s.clearCanceled(taskID)
+	if task, err = s.store.Attach(taskID); err != nil {
+		return nil, err
+	}
+	task.DeletedAt = time.Time{}
+	if err := s.saveTaskLocked(task); err != nil {
+		return nil, err
+	}
+	return task, nil
+}
+
+// PurgeTrash permanently deletes one trashed task, or the whole trash when
+// taskID is empty.
+func (s *TaskService) PurgeTrash(taskID string) error {
+	taskID = strings.TrimSpace(taskID)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if taskID == "" {
+		if err := os.RemoveAll(filepath.Join(s.storageDir, trashDirName)); err != nil {
+			return fmt.Errorf("清空回收站失败: %w", err)
+		}
+		return nil
+	}
+	if _, err := s.loadTrashedTask(taskID); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(s.trashDir(taskID)); err != nil {
+		return fmt.Errorf("彻底删除任务失败: %w", err)
+	}
+	return nil
+}
+
+// RunTrashPurger removes trashed tasks past the retention window until ctx is cancelled.
+func (s *TaskService) RunTrashPurger(ctx context.Context) {
+	if s.trashRetention <= 0 {
+		return
+	}
+	s.purgeExpiredTrash()
+	ticker := time.NewTicker(trashPurgeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.purgeExpiredTrash()
+		}
+	}
+}
+
+func (s *TaskService) purgeExpiredTrash() {
+	entries, err := os.ReadDir(filepath.Join(s.storageDir, trashDirName))
+	if err != nil {
+		return
+	}
+	cutoff := time.Now().Add(-s.trashRetention)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		task, err := s.loadTrashedTask(entry.Name())
+		if err != nil || task.DeletedAt.After(cutoff) {
+			continue
+		}
+		if err := os.RemoveAll(s.trashDir(entry.Name())); err != nil {
+			log.Printf("purge trashed task %s failed: %v", entry.Name(), err)
+			continue
+		}
+		log.Printf("purged trashed task %s deleted at %s", entry.Name(), task.DeletedAt.Format(time.RFC3339))
+	}
+}
+
+func (s *TaskService) loadTrashedTask(taskID string) (*model.Task, error) {
+	if taskID == "" || strings.ContainsAny(taskID, `/\`) || strings.HasPrefix(taskID, ".") {
+		return nil, ErrTaskNotInTrash
+	}
+	metaPath := filepath.Join(s.trashDir(taskID), metaFileName)
+	if _, err := os.Stat(metaPath); err != nil {
+		return nil, ErrTaskNotInTrash
+	}
+	return s.loadTaskFile(metaPath)
+}
@@ -0,0 +1,116 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"pdftool/internal/model"
+)
+
+const maxTxtTemplateLen = 16 << 10
+
+// A template's output may be txtOutputFactor times the text it is given, plus
+// txtOutputSlack bytes of headers and separators.
+const (
+	txtOutputFactor = 4
+	txtOutputSlack  = 1 << 20
+)
+
+// txtTemplateData is exposed to custom TXT export templates.
+type txtTemplateData struct {
+	FileName   string
+	Title      string
+	Author     string
+	TotalPages int
+	Pages      []txtTemplatePage
+}
+
+type txtTemplatePage struct {
+	Number      int    // PDF page index
+	Header      string // rendered page header, empty when headers are hidden
+	SourceText  string
+	Translation string // footnote references in [n] form
+	Footnotes   []model.Footnote
+	Elements    *model.PageElements  // titles, headers, captions; nil when the model listed none
+	Outline     []model.OutlineEntry // bookmarks of the source PDF pointing to the page
+	First       bool
+	Last        bool
+}
+
+func parseTxtTemplate(text string) (*template.Template, error) {
+	if len(text) > maxTxtTemplateLen {
+		return nil, fmt.Errorf("TXT 模板过长（最多 %d 字节）", maxTxtTemplateLen)
+	}
+	tmpl, err := template.New("txt").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("TXT 模板解析失败: %w", err)
+	}
+	return tmpl, nil
+}
+
+// renderCombinedText builds the TXT export, using the task's template when set.
+func (s *TaskService) renderCombinedText(task *model.Task) (string, error) {
+	if task.ExportSettings == nil || strings.TrimSpace(task.ExportSettings.TxtTemplate) == "" {
+		return s.buildCombinedText(task)
+	}
+	tmpl, err := parseTxtTemplate(task.ExportSettings.TxtTemplate)
+	if err != nil {
+		return "", err
+	}
+	data := txtTemplateData{
+		FileName:   task.FileName,
+		Title:      documentTitle(task),
+		Author:     documentAuthor(task),
+		TotalPages: task.TotalPages,
+	}
+	bookmarks := outlineByPage(task)
+	for _, page := range task.Pages {
+		text := pageExportText(task, page)
+		if !page.HasText || text == "" {
+			continue
+		}
+		header, _ := pageHeader(task, page)
+		data.Pages = append(data.Pages, txtTemplatePage{
+			Number:      page.PageNumber,
+			Header:      header,
+			SourceText:  strings.TrimSpace(page.SourceText),
+			Translation: plainFootnoteRefs(text),
+			Footnotes:   page.Footnotes,
+			Elements:    page.Elements,
+			Outline:     bookmarks[page.PageNumber],
+		})
+	}
+	if len(data.Pages) == 0 {
+		return "", fmt.Errorf("没有可用的翻译文本")
+	}
+	data.Pages[0].First = true
+	data.Pages[len(data.Pages)-1].Last = true
+	size := len(data.FileName) + len(data.Title) + len(data.Author)
+	for _, page := range data.Pages {
+		size += len(page.Header) + len(page.SourceText) + len(page.Translation)
+		for _, note := range page.Footnotes {
+			size += len(note.Marker) + len(note.SourceText) + len(note.Translation)
+		}
+	}
+	out := &cappedBuffer{limit: size*txtOutputFactor + txtOutputSlack}
+	if err := tmpl.Execute(out, data); err != nil {
+		return "", fmt.Errorf("TXT 模板渲染失败: %w", err)
+	}
+	return out.buf.String(), nil
+}
+
+// cappedBuffer fails writes that would grow it beyond limit, stopping a
+// template that repeats the pages over and over.
+type cappedBuffer struct {
+	buf   bytes.Buffer
+	limit int
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if b.buf.Len()+len(p) > b.limit {
+		return 0, fmt.Errorf("输出超过 %d 字节", b.limit)
+	}
+	return b.buf.Write(p)
+}
@@ -0,0 +1,25 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"pdftool/internal/model"
+)
+
+// TestTxtTemplateOutputCapped checks that a template repeating the pages
+// without bound fails instead of rendering an unbounded export.
+func TestTxtTemplateOutputCapped(t *testing.T) {
+	s := newDeterministicService(t)
+	task := writeGoldenTask(t, s, false)
+	nested := strings.Repeat("{{range $.Pages}}", 10) + "{{.Translation}}" + strings.Repeat("{{end}}", 10)
+	task.ExportSettings = &model.ExportSettings{TxtTemplate: nested}
+	if _, err := s.renderCombinedText(task); err == nil || !strings.Contains(err.Error(), "输出超过") {
+		t.Fatalf("unbounded template rendered, err = %v", err)
+	}
+
+	task.ExportSettings.TxtTemplate = "{{range .Pages}}{{.Translation}}\n{{end}}"
+	if _, err := s.renderCombinedText(task); err != nil {
+		t.Fatalf("plain template failed: %v", err)
+	}
+}
@@ -0,0 +1,74 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"pdftool/internal/model"
+)
+
+// QuarantineDirName is the storage subdirectory holding infected uploads.
+// It is never served by the static file route.
+const QuarantineDirName = "quarantine"
+
+var (
+	// ErrInfectedUpload reports an upload ClamAV flagged as malware.
+	ErrInfectedUpload = errors.New("上传文件未通过病毒扫描")
+	// ErrVirusScanFailed rejects uploads that could not be scanned.
+	ErrVirusScanFailed = errors.New("病毒扫描失败")
+)
+
+// scanUpload runs the stored upload through ClamAV; it returns nil when
+// scanning is disabled. A failed scan rejects the upload unless the service
+// fails open, in which case the error is recorded on the task.
+func (s *TaskService) scanUpload(ctx context.Context, path string) (*model.VirusScan, error) {
+	if !s.scanner.Enabled() {
+		return nil, nil
+	}
+	result, err := s.scanner.ScanFile(ctx, path)
+	scan := &model.VirusScan{Status: model.ScanStatusClean, ScannedAt: time.Now()}
+	if err != nil {
+		if !s.scanFailOpen {
+			return nil, fmt.Errorf("%w: %v", ErrVirusScanFailed, err)
+		}
+		log.Printf("virus scan of %s failed, accepting upload: %v", path, err)
+		scan.Status = model.ScanStatusError
+		scan.Error = err.Error()
+		return scan, nil
+	}
+	if result.Infected {
+		scan.Status = model.ScanStatusInfected
+		scan.Signature = result.Signature
+	}
+	return scan, nil
+}
+
+// quarantineTask moves an infected upload out of the task dir and records a
+// failed task for it, so the file is kept for inspection but never rendered
+// or served.
+func (s *TaskService) quarantineTask(task *model.Task, sourcePath string) (*model.Task, error) {
+	dir := filepath.Join(s.storageDir, QuarantineDirName)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("create quarantine dir: %w", err)
+	}
+	dest := filepath.Join(dir, task.ID+".pdf")
+	if err := os.Rename(sourcePath, dest); err != nil {
+		os.Remove(sourcePath)
+		return nil, fmt.Errorf("quarantine upload: %w", err)
+	}
+	os.Chmod(dest, 0o600)
+	task.OriginalPath = ""
+	task.QuarantinePath = dest
+	if err := s.saveTask(task); err != nil {
+		return nil, err
+	}
+	err := fmt.Errorf("%w: %s", ErrInfectedUpload, task.VirusScan.Signature)
+	log.Printf("task %s: upload %s quarantined (%s)", task.ID, task.FileName, task.VirusScan.Signature)
+	s.transition(task, toState(model.TaskStateFailed), err.Error())
+	return task, err
+}
@@ -0,0 +1,63 @@
+package source
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+)
+
+const dropboxContentAPI = "https://content.dropboxapi.com/2/files"
+
+type dropboxConnector struct {
+	client *http.Client
+	token  string
+}
+
+func (c *dropboxConnector) Name() string { return "dropbox" }
+
+func (c *dropboxConnector) call(ctx context.Context, endpoint string, arg interface{}, body io.Reader) (*http.Response, error) {
+	argJSON, err := json.Marshal(arg)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, dropboxContentAPI+endpoint, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Dropbox-API-Arg", string(argJSON))
+	if body != nil {
+		req.Header.Set("Content-Type", "application/octet-stream")
+	}
+	return c.client.Do(req)
+}
+
+func (c *dropboxConnector) Fetch(ctx context.Context, remotePath string) (io.ReadCloser, string, error) {
+	resp, err := c.call(ctx, "/download", map[string]string{"path": path.Clean("/" + remotePath)}, nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("下载 Dropbox 文件失败: %w", err)
+	}
+	if err := checkResponse(resp, "下载 Dropbox 文件"); err != nil {
+		resp.Body.Close()
+		return nil, "", err
+	}
+	return resp.Body, path.Base(remotePath), nil
+}
+
+func (c *dropboxConnector) UploadSibling(ctx context.Context, remotePath, name string, data []byte) (string, error) {
+	target := path.Join(path.Dir(path.Clean("/"+remotePath)), name)
+	arg := map[string]interface{}{"path": target, "mode": "overwrite", "mute": true}
+	resp, err := c.call(ctx, "/upload", arg, bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("上传 Dropbox 文件失败: %w", err)
+	}
+	defer resp.Body.Close()
+	if err := checkResponse(resp, "上传 Dropbox 文件"); err != nil {
+		return "", err
+	}
+	return "dropbox:" + target, nil
+}
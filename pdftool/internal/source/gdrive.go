@@ -0,0 +1,124 @@
+package source
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strings"
+)
+
+const (
+	gdriveAPI       = "https://www.googleapis.com/drive/v3/files"
+	gdriveUploadAPI = "https://www.googleapis.com/upload/drive/v3/files"
+)
+
+// gdriveConnector addresses documents by Drive file ID using an OAuth access token.
+type gdriveConnector struct {
+	client *http.Client
+	token  string
+}
+
+func (c *gdriveConnector) Name() string { return "gdrive" }
+
+func (c *gdriveConnector) do(req *http.Request) (*http.Response, error) {
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	return c.client.Do(req)
+}
+
+type gdriveFile struct {
+	ID      string   `json:"id"`
+	Name    string   `json:"name"`
+	Parents []string `json:"parents"`
+}
+
+func (c *gdriveConnector) metadata(ctx context.Context, fileID string) (gdriveFile, error) {
+	var meta gdriveFile
+	endpoint := fmt.Sprintf("%s/%s?fields=id,name,parents&supportsAllDrives=true", gdriveAPI, url.PathEscape(fileID))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return meta, err
+	}
+	resp, err := c.do(req)
+	if err != nil {
+		return meta, fmt.Errorf("读取 Google Drive 文件信息失败: %w", err)
+	}
+	defer resp.Body.Close()
+	if err := checkResponse(resp, "读取 Google Drive 文件信息"); err != nil {
+		return meta, err
+	}
+	err = json.NewDecoder(resp.Body).Decode(&meta)
+	return meta, err
+}
+
+func (c *gdriveConnector) Fetch(ctx context.Context, fileID string) (io.ReadCloser, string, error) {
+	fileID = strings.TrimSpace(fileID)
+	meta, err := c.metadata(ctx, fileID)
+	if err != nil {
+		return nil, "", err
+	}
+	endpoint := fmt.Sprintf("%s/%s?alt=media&supportsAllDrives=true", gdriveAPI, url.PathEscape(fileID))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("下载 Google Drive 文件失败: %w", err)
+	}
+	if err := checkResponse(resp, "下载 Google Drive 文件"); err != nil {
+		resp.Body.Close()
+		return nil, "", err
+	}
+	return resp.Body, meta.Name, nil
+}
+
+func (c *gdriveConnector) UploadSibling(ctx context.Context, fileID, name string, data []byte) (string, error) {
+	meta, err := c.metadata(ctx, strings.TrimSpace(fileID))
+	if err != nil {
+		return "", err
+	}
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	metaHeader := textproto.MIMEHeader{"Content-Type": {"application/json; charset=UTF-8"}}
+	part, err := writer.CreatePart(metaHeader)
+	if err != nil {
+		return "", err
+	}
+	if err := json.NewEncoder(part).Encode(map[string]interface{}{"name": name, "parents": meta.Parents}); err != nil {
+		return "", err
+	}
+	part, err = writer.CreatePart(textproto.MIMEHeader{"Content-Type": {"application/octet-stream"}})
+	if err != nil {
+		return "", err
+	}
+	if _, err := part.Write(data); err != nil {
+		return "", err
+	}
+	writer.Close()
+
+	endpoint := gdriveUploadAPI + "?uploadType=multipart&supportsAllDrives=true&fields=id"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, &body)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "multipart/related; boundary="+writer.Boundary())
+	resp, err := c.do(req)
+	if err != nil {
+		return "", fmt.Errorf("上传 Google Drive 文件失败: %w", err)
+	}
+	defer resp.Body.Close()
+	if err := checkResponse(resp, "上传 Google Drive 文件"); err != nil {
+		return "", err
+	}
+	var created gdriveFile
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		return "", fmt.Errorf("解析 Google Drive 上传结果失败: %w", err)
+	}
+	return "gdrive:" + created.ID, nil
+}
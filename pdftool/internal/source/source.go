@@ -0,0 +1,91 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Connector fetches documents from a cloud drive and writes results back.
+type Connector interface {
+	Name() string
+	// Fetch opens the remote document and returns its display file name.
+	Fetch(ctx context.Context, remotePath string) (io.ReadCloser, string, error)
+	// UploadSibling stores data next to remotePath under name and returns the remote location.
+	UploadSibling(ctx context.Context, remotePath, name string, data []byte) (string, error)
+}
+
+// Config holds credentials for the supported connectors; empty values disable one.
+type Config struct {
+	WebDAVURL      string
+	WebDAVUser     string
+	WebDAVPassword string
+	DropboxToken   string
+	GDriveToken    string
+	Timeout        time.Duration
+}
+
+// Registry looks connectors up by name.
+type Registry struct {
+	connectors map[string]Connector
+}
+
+// NewRegistry builds connectors for every configured source.
+func NewRegistry(cfg Config) *Registry {
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Minute
+	}
+	client := &http.Client{Timeout: timeout}
+	r := &Registry{connectors: make(map[string]Connector)}
+	if base := strings.TrimRight(strings.TrimSpace(cfg.WebDAVURL), "/"); base != "" {
+		r.Register(&webDAVConnector{client: client, baseURL: base, user: cfg.WebDAVUser, password: cfg.WebDAVPassword})
+	}
+	if token := strings.TrimSpace(cfg.DropboxToken); token != "" {
+		r.Register(&dropboxConnector{client: client, token: token})
+	}
+	if token := strings.TrimSpace(cfg.GDriveToken); token != "" {
+		r.Register(&gdriveConnector{client: client, token: token})
+	}
+	return r
+}
+
+// Register adds or replaces a connector.
+func (r *Registry) Register(c Connector) {
+	r.connectors[strings.ToLower(c.Name())] = c
+}
+
+// Get returns the named connector.
+func (r *Registry) Get(name string) (Connector, error) {
+	if r != nil {
+		if c, ok := r.connectors[strings.ToLower(strings.TrimSpace(name))]; ok {
+			return c, nil
+		}
+	}
+	return nil, fmt.Errorf("未配置的云端来源: %s", name)
+}
+
+// Names lists configured connectors.
+func (r *Registry) Names() []string {
+	if r == nil {
+		return nil
+	}
+	names := make([]string, 0, len(r.connectors))
+	for name := range r.connectors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func checkResponse(resp *http.Response, action string) error {
+	if resp.StatusCode < 400 {
+		return nil
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	return fmt.Errorf("%s失败: %s %s", action, resp.Status, strings.TrimSpace(string(body)))
+}
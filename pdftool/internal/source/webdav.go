@@ -0,0 +1,73 @@
+package source
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+)
+
+type webDAVConnector struct {
+	client   *http.Client
+	baseURL  string
+	user     string
+	password string
+}
+
+func (c *webDAVConnector) Name() string { return "webdav" }
+
+func (c *webDAVConnector) url(remotePath string) string {
+	return c.baseURL + "/" + strings.TrimLeft(path.Clean("/"+remotePath), "/")
+}
+
+func (c *webDAVConnector) newRequest(ctx context.Context, method, remotePath string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.url(remotePath), body)
+	if err != nil {
+		return nil, err
+	}
+	if c.user != "" || c.password != "" {
+		req.SetBasicAuth(c.user, c.password)
+	}
+	return req, nil
+}
+
+func (c *webDAVConnector) Fetch(ctx context.Context, remotePath string) (io.ReadCloser, string, error) {
+	req, err := c.newRequest(ctx, http.MethodGet, remotePath, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("下载 WebDAV 文件失败: %w", err)
+	}
+	if err := checkResponse(resp, "下载 WebDAV 文件"); err != nil {
+		resp.Body.Close()
+		return nil, "", err
+	}
+	return resp.Body, path.Base(remotePath), nil
+}
+
+func (c *webDAVConnector) UploadSibling(ctx context.Context, remotePath, name string, data []byte) (string, error) {
+	target := path.Join(path.Dir(path.Clean("/"+remotePath)), name)
+	return c.Upload(ctx, target, data)
+}
+
+// Upload PUTs data to an absolute WebDAV path.
+func (c *webDAVConnector) Upload(ctx context.Context, remotePath string, data []byte) (string, error) {
+	req, err := c.newRequest(ctx, http.MethodPut, remotePath, bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("上传 WebDAV 文件失败: %w", err)
+	}
+	defer resp.Body.Close()
+	if err := checkResponse(resp, "上传 WebDAV 文件"); err != nil {
+		return "", err
+	}
+	return c.url(remotePath), nil
+}
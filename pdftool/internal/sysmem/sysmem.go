@@ -0,0 +1,76 @@
+// Package sysmem reads process memory usage and the container memory limit
+// from procfs and cgroups. On systems without them every value is zero.
+package sysmem
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// cgroupLimitFiles are the cgroup v2 and v1 memory limit files.
+var cgroupLimitFiles = []string{
+	"/sys/fs/cgroup/memory.max",
+	"/sys/fs/cgroup/memory/memory.limit_in_bytes",
+}
+
+// unlimited is the smallest value treated as "no limit"; cgroup v1 reports
+// a page-aligned near-MaxInt64 value instead of "max".
+const unlimited = 1 << 60
+
+// ContainerLimit returns the memory limit of the container the process
+// runs in, or 0 when there is none.
+func ContainerLimit() int64 {
+	for _, path := range cgroupLimitFiles {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		raw := strings.TrimSpace(string(data))
+		if raw == "max" {
+			return 0
+		}
+		v, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || v <= 0 || v >= unlimited {
+			return 0
+		}
+		return v
+	}
+	return 0
+}
+
+// ResidentBytes returns the resident set size of the process pid (0 for the
+// current process).
+func ResidentBytes(pid int) (int64, error) {
+	path := "/proc/self/status"
+	if pid > 0 {
+		path = fmt.Sprintf("/proc/%d/status", pid)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "VmRSS:") {
+			continue
+		}
+		fields := strings.Fields(strings.TrimPrefix(line, "VmRSS:"))
+		if len(fields) == 0 {
+			break
+		}
+		kb, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		return kb << 10, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	return 0, fmt.Errorf("no VmRSS in %s", path)
+}
@@ -1,150 +1,169 @@
 package translator
 
 import (
-	"bytes"
 	"context"
 	"encoding/base64"
-	"encoding/json"
 	"fmt"
-	"log"
 	"net/http"
-	"os"
 	"strings"
 	"time"
 )
 
-const defaultAnthropicBase = "https://api.anthropic.com/v1"
+const (
+	defaultAnthropicBase = "https://api.anthropic.com/v1"
+	anthropicVersion     = "2023-06-01"
+)
 
-type anthropicTranslator struct {
-	baseURL        string
-	apiKey         string
-	model          string
-	timeout        time.Duration
-	httpClient     *http.Client
-	systemPrompt   string
-	userPrompt     string
-	maxTokens      int
-	optimizeLayout bool
+// anthropicClient talks to the Anthropic Messages API.
+type anthropicClient struct {
+	httpClient *http.Client
+	endpoint   string
+	apiKey     string
+	model      string
+	maxTokens  int
 }
 
-func newAnthropicTranslator(cfg ProviderConfig) (Translator, error) {
+func newAnthropicClient(cfg ProviderConfig) (anthropicClient, error) {
 	if strings.TrimSpace(cfg.APIKey) == "" {
-		return nil, fmt.Errorf("Anthropic API Key 未配置")
+		return anthropicClient{}, fmt.Errorf("Anthropic API Key 未配置")
 	}
 	if strings.TrimSpace(cfg.Model) == "" {
-		return nil, fmt.Errorf("Anthropic 模型未配置")
+		return anthropicClient{}, fmt.Errorf("Anthropic 模型未配置")
 	}
 	if cfg.Timeout == 0 {
 		cfg.Timeout = 300 * time.Second
 	}
-	baseURL := strings.TrimRight(cfg.BaseURL, "/")
-	if baseURL == "" {
-		baseURL = defaultAnthropicBase
-	}
-	if !strings.HasSuffix(baseURL, "/v1/messages") {
-		baseURL = baseURL + "/v1/messages"
-	}
+	return anthropicClient{
+		httpClient: newHTTPClient(cfg),
+		endpoint:   anthropicEndpoint(cfg.BaseURL),
+		apiKey:     strings.TrimSpace(cfg.APIKey),
+		model:      cfg.Model,
+		maxTokens:  SanitizeMaxTokens(cfg.MaxTokens),
+	}, nil
+}
+
+// message sends a system prompt and one user message and returns the answer.
+func (c anthropicClient) message(ctx context.Context, provider, label, system string, temperature float64, content ...anthropicContent) (string, error) {
+	payload := anthropicRequest{
+		Model:       c.model,
+		System:      system,
+		MaxTokens:   c.maxTokens,
+		Temperature: temperature,
+		Messages:    []anthropicMessage{{Role: "user", Content: content}},
+	}
+	return send(ctx, c.httpClient, apiCall{
+		provider: provider,
+		label:    label,
+		url:      c.endpoint,
+		header:   http.Header{"X-Api-Key": {c.apiKey}, "Anthropic-Version": {anthropicVersion}},
+		body:     payload,
+		masked:   maskAnthropicPayload(payload),
+	}, &anthropicResponse{})
+}
+
+type anthropicTranslator struct {
+	anthropicClient
+	prompts    pagePrompts
+	imageTypes []string
+}
 
+func newAnthropicTranslator(cfg ProviderConfig) (Translator, error) {
+	client, err := newAnthropicClient(cfg)
+	if err != nil {
+		return nil, err
+	}
 	return &anthropicTranslator{
-		baseURL:   baseURL,
-		apiKey:    cfg.APIKey,
-		model:     cfg.Model,
-		timeout:   cfg.Timeout,
-		maxTokens: SanitizeMaxTokens(cfg.MaxTokens),
-		httpClient: &http.Client{
-			Timeout: cfg.Timeout,
-		},
-		systemPrompt:   "你是一个专业的OCR与翻译助手。阅读用户提供的图片，先识别出存在的文本，再将其翻译为简体中文。必须输出严格的JSON对象，格式为 {\"hasText\":bool,\"sourceText\":\"原始文本\",\"translatedText\":\"翻译后的文本\"} 。如果图片中没有文本，设置 hasText 为 false，另外两个字段留空字符串。",
-		userPrompt:     "请识别这页图像中的所有可见文本并翻译成简体中文。保持原本的段落顺序，返回JSON字符串。",
-		optimizeLayout: cfg.OptimizeLayout,
+		anthropicClient: client,
+		prompts:         newPagePrompts(cfg),
+		imageTypes:      imageTypes(cfg, anthropicImageTypes),
 	}, nil
 }
 
 func (t *anthropicTranslator) Translate(ctx context.Context, imagePath string) (Result, error) {
-	pageNumber := pageNumberFromContext(ctx)
-	data, err := os.ReadFile(imagePath)
+	img, err := loadPageImage(imagePath, t.imageTypes)
 	if err != nil {
-		return Result{}, fmt.Errorf("读取图片失败: %w", err)
-	}
-	mimeType := detectImageMIME(data)
-
-	userPrompt := t.userPrompt
-	if t.optimizeLayout {
-		userPrompt = userPrompt + " 请在返回的 sourceText 与 translatedText 中保持良好的排版结构，保留标题、列表和空行。"
+		return Result{}, err
 	}
-
-	reqBody := anthropicRequest{
-		Model:       t.model,
-		MaxTokens:   t.maxTokens,
-		System:      t.systemPrompt,
-		Temperature: 0.1,
-		Messages: []anthropicMessage{
-			{
-				Role: "user",
-				Content: []anthropicContent{
-					{Type: "text", Text: userPrompt},
-					{
-						Type: "image",
-						Source: &anthropicImageSource{
-							Type:      "base64",
-							MediaType: mimeType,
-							Data:      base64.StdEncoding.EncodeToString(data),
-						},
-					},
-				},
+	text, err := t.message(ctx, "Anthropic", pageLabel(ctx), t.prompts.system, 0.1,
+		anthropicContent{Type: "text", Text: t.prompts.page(ctx)},
+		anthropicContent{
+			Type: "image",
+			Source: &anthropicImageSource{
+				Type:      "base64",
+				MediaType: img.MIME,
+				Data:      base64.StdEncoding.EncodeToString(img.Data),
 			},
 		},
+	)
+	if err != nil {
+		return Result{}, err
 	}
+	return pageResult("Anthropic", text)
+}
 
-	body, _ := json.Marshal(reqBody)
-	logAnthropicRequest(t.baseURL, reqBody, pageNumber)
-
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.baseURL, bytes.NewReader(body))
+func (t *anthropicTranslator) TranslateText(ctx context.Context, sourceText string) (Result, error) {
+	text, err := t.message(ctx, "Anthropic", pageLabel(ctx), t.prompts.text, 0.1,
+		anthropicContent{Type: "text", Text: sourceText})
 	if err != nil {
 		return Result{}, err
 	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("x-api-key", t.apiKey)
-	req.Header.Set("anthropic-version", "2023-06-01")
+	return textResult(sourceText, text)
+}
 
-	resp, err := t.httpClient.Do(req)
+// anthropicFormatter attaches the chunk as an image or plain-text document.
+type anthropicFormatter struct {
+	anthropicClient
+	systemPrompt string
+}
+
+func newAnthropicFormatter(cfg ProviderConfig) (TextFormatter, error) {
+	client, err := newAnthropicClient(cfg)
 	if err != nil {
-		logAnthropicError(err, pageNumber)
-		return Result{}, fmt.Errorf("调用 Anthropic 失败: %w", err)
+		return nil, err
 	}
-	defer resp.Body.Close()
+	return &anthropicFormatter{anthropicClient: client, systemPrompt: cfg.prompts().formatterSystem()}, nil
+}
 
-	if resp.StatusCode >= 400 {
-		data, _ := readAllLimited(resp.Body, 1<<20)
-		logAnthropicHTTPError(resp.StatusCode, data, pageNumber)
-		return Result{}, fmt.Errorf("Anthropic 响应错误: %s", resp.Status)
-	}
+func (f *anthropicFormatter) Format(ctx context.Context, chunk FormatterChunk, chunkIndex int) (string, error) {
+	return f.message(ctx, "Anthropic Formatter", chunkLabel(chunkIndex), f.systemPrompt, 0.2,
+		anthropicContent{Type: "text", Text: formatterPrompt(chunk, false)},
+		anthropicAttachment(chunk),
+	)
+}
 
-	var parsed anthropicResponse
-	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
-		return Result{}, fmt.Errorf("解析 Anthropic 响应失败: %w", err)
+// anthropicAttachment sends text chunks as a plain-text document; image
+// blocks only accept image media types.
+func anthropicAttachment(chunk FormatterChunk) anthropicContent {
+	if strings.HasPrefix(chunk.MimeType, "text/") {
+		return anthropicContent{
+			Type:   "document",
+			Source: &anthropicImageSource{Type: "text", MediaType: "text/plain", Data: string(chunk.Data)},
+		}
 	}
-	logAnthropicResponse(parsed, pageNumber)
-
-	text := parsed.FirstText()
-	if strings.TrimSpace(text) == "" {
-		return Result{}, fmt.Errorf("Anthropic 返回空内容")
+	return anthropicContent{
+		Type: "image",
+		Source: &anthropicImageSource{
+			Type:      "base64",
+			MediaType: chunk.MimeType,
+			Data:      base64.StdEncoding.EncodeToString(chunk.Data),
+		},
 	}
+}
 
-	clean := cleanJSON(text)
-	var payload struct {
-		HasText        bool   `json:"hasText"`
-		SourceText     string `json:"sourceText"`
-		TranslatedText string `json:"translatedText"`
+// anthropicEndpoint accepts the API root with or without /v1, or the full
+// messages endpoint.
+func anthropicEndpoint(base string) string {
+	base = strings.TrimRight(strings.TrimSpace(base), "/")
+	if base == "" {
+		base = defaultAnthropicBase
 	}
-	if err := json.Unmarshal([]byte(clean), &payload); err != nil {
-		return Result{}, fmt.Errorf("解析 Anthropic JSON 失败: %w", err)
+	switch {
+	case strings.HasSuffix(base, "/messages"):
+		return base
+	case strings.HasSuffix(base, "/v1"):
+		return base + "/messages"
 	}
-	return Result{
-		HasText:        payload.HasText,
-		SourceText:     payload.SourceText,
-		TranslatedText: payload.TranslatedText,
-	}, nil
+	return base + "/v1/messages"
 }
 
 type anthropicRequest struct {
@@ -178,9 +197,17 @@ type anthropicResponse struct {
 		Text string `json:"text"`
 	} `json:"content"`
 	StopReason string `json:"stop_reason"`
+	Usage      struct {
+		InputTokens  int `json:"input_tokens"`
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage"`
 }
 
-func (r anthropicResponse) FirstText() string {
+func (r anthropicResponse) usage() Usage {
+	return Usage{InputTokens: r.Usage.InputTokens, OutputTokens: r.Usage.OutputTokens}
+}
+
+func (r anthropicResponse) text() string {
 	for _, item := range r.Content {
 		if strings.TrimSpace(item.Text) != "" {
 			return item.Text
@@ -189,33 +216,25 @@ func (r anthropicResponse) FirstText() string {
 	return ""
 }
 
-func logAnthropicRequest(endpoint string, payload anthropicRequest, pageNumber int) {
-	body, _ := json.MarshalIndent(maskAnthropicPayload(payload), "", "  ")
-	log.Printf("[Anthropic] %s请求信息:\n  URL: %s\n  Headers: Content-Type=application/json, x-api-key=***\n  Body:\n%s", formatPagePrefix(pageNumber), endpoint, string(body))
-}
-
-func logAnthropicResponse(resp anthropicResponse, pageNumber int) {
-	data, _ := json.MarshalIndent(resp, "", "  ")
-	log.Printf("[Anthropic] %s响应信息:\n%s", formatPagePrefix(pageNumber), string(data))
-}
-
-func logAnthropicError(err error, pageNumber int) {
-	log.Printf("[Anthropic] %s请求失败: %v", formatPagePrefix(pageNumber), err)
-}
-
-func logAnthropicHTTPError(status int, body []byte, pageNumber int) {
-	log.Printf("[Anthropic] %sHTTP %d: %s", formatPagePrefix(pageNumber), status, string(body))
+func (r anthropicResponse) refusal() error {
+	return detectRefusal("Anthropic", r.StopReason, r.text())
 }
 
 func maskAnthropicPayload(payload anthropicRequest) anthropicRequest {
 	masked := payload
-	for mi := range masked.Messages {
-		for ci := range masked.Messages[mi].Content {
-			part := &masked.Messages[mi].Content[ci]
+	masked.Messages = make([]anthropicMessage, len(payload.Messages))
+	for i, msg := range payload.Messages {
+		content := make([]anthropicContent, len(msg.Content))
+		for j, part := range msg.Content {
 			if part.Source != nil {
-				part.Source.Data = "<image base64 omitted>"
+				source := *part.Source
+				source.Data = maskBase64(source.Data)
+				part.Source = &source
 			}
+			content[j] = part
 		}
+		msg.Content = content
+		masked.Messages[i] = msg
 	}
 	return masked
 }
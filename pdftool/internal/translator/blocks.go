@@ -0,0 +1,68 @@
+package translator
+
+import (
+	"context"
+	"strings"
+)
+
+// BlockScale is the range block coordinates are normalized to, on both
+// axes, so they do not depend on the size the model saw the image at.
+const BlockScale = 1000
+
+// blocksPrompt asks for the text blocks of the page with their positions,
+// in addition to the flat text.
+const blocksPrompt = "另在 JSON 中返回 blocks 数组，按阅读顺序列出页面上的每个文本块（段落、标题、图注等）：[{\"text\":\"原文\",\"translation\":\"译文\",\"bbox\":[x0,y0,x1,y1]}]，bbox 为文本块在页面图像中的外接矩形，以左上角为原点，横纵坐标分别按图像宽高归一化到 0-1000；各文本块的译文合起来应与 translatedText 一致。"
+
+// Block is a text block of a page and where it sits: BBox is x0, y0, x1, y1
+// scaled to BlockScale.
+type Block struct {
+	Text        string     `json:"text"`
+	Translation string     `json:"translation"`
+	BBox        [4]float64 `json:"bbox"`
+}
+
+const blocksKey contextKey = "pdftool_translator_blocks"
+
+// WithBlocks asks the next page request for the page's text blocks with
+// their bounding boxes.
+func WithBlocks(ctx context.Context) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithValue(ctx, blocksKey, true)
+}
+
+func blocksRequested(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	requested, _ := ctx.Value(blocksKey).(bool)
+	return requested
+}
+
+// cleanBlocks trims the blocks, orders and clamps their corners, and drops
+// blocks without a translation or an area.
+func cleanBlocks(blocks []Block) []Block {
+	var out []Block
+	for _, block := range blocks {
+		block.Text = strings.TrimSpace(block.Text)
+		block.Translation = strings.TrimSpace(block.Translation)
+		if block.Translation == "" {
+			continue
+		}
+		for i, v := range block.BBox {
+			block.BBox[i] = min(max(v, 0), BlockScale)
+		}
+		if block.BBox[0] > block.BBox[2] {
+			block.BBox[0], block.BBox[2] = block.BBox[2], block.BBox[0]
+		}
+		if block.BBox[1] > block.BBox[3] {
+			block.BBox[1], block.BBox[3] = block.BBox[3], block.BBox[1]
+		}
+		if block.BBox[0] == block.BBox[2] || block.BBox[1] == block.BBox[3] {
+			continue
+		}
+		out = append(out, block)
+	}
+	return out
+}
@@ -3,6 +3,7 @@ package translator
 import (
 	"context"
 	"fmt"
+	"strings"
 )
 
 type contextKey string
@@ -36,3 +37,36 @@ func formatPagePrefix(pageNumber int) string {
 	}
 	return fmt.Sprintf("[Page %d] ", pageNumber)
 }
+
+const promptHintKey contextKey = "pdftool_translator_prompt_hint"
+
+// WithPromptHint attaches extra instructions (e.g. reading order) to the next page request.
+func WithPromptHint(ctx context.Context, hint string) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	hint = strings.TrimSpace(hint)
+	if hint == "" {
+		return ctx
+	}
+	if existing := promptHintFromContext(ctx); existing != "" {
+		hint = existing + " " + hint
+	}
+	return context.WithValue(ctx, promptHintKey, hint)
+}
+
+func promptHintFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	hint, _ := ctx.Value(promptHintKey).(string)
+	return hint
+}
+
+// withPromptHint appends the context hint to a user prompt.
+func withPromptHint(ctx context.Context, prompt string) string {
+	if hint := promptHintFromContext(ctx); hint != "" {
+		return prompt + " " + hint
+	}
+	return prompt
+}
@@ -0,0 +1,280 @@
+package translator
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// The provider clients are thin adapters over this file: each builds its
+// request payload and decodes its reply type, while the prompts, the HTTP
+// exchange with its logging and quick retries, and the parsing of the page
+// JSON are shared.
+
+const layoutPrompt = "请在 sourceText 与 translatedText 字段中保持原文的结构与排版，保留标题、列表和空行，使译文更整洁易读。"
+
+// pagePrompts are the instructions a translator sends with every request.
+type pagePrompts struct {
+	system         string
+	user           string
+	text           string
+	optimizeLayout bool
+}
+
+func newPagePrompts(cfg ProviderConfig) pagePrompts {
+	return pagePrompts{
+		system:         cfg.prompts().ocrSystem(),
+		user:           cfg.prompts().ocrUser(),
+		text:           cfg.prompts().textSystem(),
+		optimizeLayout: cfg.OptimizeLayout,
+	}
+}
+
+// page is the user prompt sent with a page image: the configured prompt,
+// the layout hint, the footnote and element instructions, the block
+// instruction when ctx asks for blocks, and the hint attached to ctx.
+func (p pagePrompts) page(ctx context.Context) string {
+	prompt := p.user
+	if p.optimizeLayout {
+		prompt += " " + layoutPrompt
+	}
+	prompt += " " + footnotePrompt + " " + elementsPrompt
+	if blocksRequested(ctx) {
+		prompt += " " + blocksPrompt
+	}
+	return withPromptHint(ctx, prompt)
+}
+
+// formatterPrompt is the instruction sent with a formatter chunk. Providers
+// that cannot attach text documents pass inline to get text chunks appended
+// to the prompt.
+func formatterPrompt(chunk FormatterChunk, inline bool) string {
+	prompt := chunk.instruction()
+	if inline && !strings.HasPrefix(chunk.MimeType, "image/") {
+		prompt += "\n\n文本内容：\n" + string(chunk.Data)
+	}
+	return prompt
+}
+
+// chatReply is a decoded provider response.
+type chatReply interface {
+	// text returns the first non-empty answer, or "".
+	text() string
+	usage() Usage
+	// refusal reports an answer the provider refused or filtered.
+	refusal() error
+}
+
+// apiCall is one JSON POST to a provider endpoint.
+type apiCall struct {
+	// provider names the client in errors and logs, e.g. "Gemini" or
+	// "Gemini Formatter".
+	provider string
+	// label locates the call in logs: the page or chunk prefix.
+	label  string
+	url    string
+	header http.Header
+	body   any
+	// masked is logged in place of body, with image data elided.
+	masked any
+}
+
+func pageLabel(ctx context.Context) string {
+	return formatPagePrefix(pageNumberFromContext(ctx))
+}
+
+func chunkLabel(chunkIndex int) string {
+	return fmt.Sprintf("[Chunk %d] ", chunkIndex)
+}
+
+// A dropped connection or a gateway error is retried once in place. Rate
+// limits and longer outages are returned, so the service's retry scheduler
+// can wait as long as the provider asks.
+const (
+	requestAttempts   = 2
+	requestRetryDelay = time.Second
+	maxQuickRetryWait = 5 * time.Second
+)
+
+// send posts call, decodes the response into reply, reports its usage and
+// returns the trimmed answer. Refusals and empty answers are errors.
+func send(ctx context.Context, client *http.Client, call apiCall, reply chatReply) (string, error) {
+	body, err := json.Marshal(call.body)
+	if err != nil {
+		return "", err
+	}
+	logCallRequest(call)
+	var resp *http.Response
+	for attempt := 1; ; attempt++ {
+		resp, err = call.post(ctx, client, body)
+		if err == nil {
+			break
+		}
+		wait, ok := quickRetryDelay(err)
+		if !ok || attempt >= requestAttempts {
+			return "", err
+		}
+		log.Printf("[%s] %s%v，%s 后重试", call.provider, call.label, err, wait)
+		select {
+		case <-ctx.Done():
+			return "", err
+		case <-time.After(wait):
+		}
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(reply); err != nil {
+		return "", fmt.Errorf("解析 %s 响应失败: %w", call.provider, err)
+	}
+	data, _ := json.MarshalIndent(reply, "", "  ")
+	log.Printf("[%s] %s响应信息:\n%s", call.provider, call.label, string(data))
+	reportUsage(ctx, reply.usage())
+	if err := reply.refusal(); err != nil {
+		return "", err
+	}
+	text := strings.TrimSpace(reply.text())
+	if text == "" {
+		return "", fmt.Errorf("%s 返回空内容", call.provider)
+	}
+	return text, nil
+}
+
+// post makes one attempt. An error status is logged and returned as an
+// *HTTPError.
+func (call apiCall) post(ctx context.Context, client *http.Client, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, call.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range call.header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		log.Printf("[%s] %s请求失败: %v", call.provider, call.label, err)
+		return nil, fmt.Errorf("调用 %s 失败: %w", call.provider, err)
+	}
+	if resp.StatusCode >= 400 {
+		defer resp.Body.Close()
+		data, _ := readAllLimited(resp.Body, 1<<20)
+		if pretty := formatJSON(data); pretty != "" {
+			log.Printf("[%s] %sHTTP %d:\n%s", call.provider, call.label, resp.StatusCode, pretty)
+		} else {
+			log.Printf("[%s] %sHTTP %d: %s", call.provider, call.label, resp.StatusCode, string(data))
+		}
+		return nil, newHTTPError(call.provider, resp)
+	}
+	return resp, nil
+}
+
+// quickRetryDelay reports whether a failed attempt is repeated in place and
+// after how long: a reset or refused connection, or a 502, 503 or 504 that
+// asks for no more than maxQuickRetryWait.
+func quickRetryDelay(err error) (time.Duration, bool) {
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		switch httpErr.StatusCode {
+		case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			if httpErr.RetryAfter > maxQuickRetryWait {
+				return 0, false
+			}
+			return max(httpErr.RetryAfter, requestRetryDelay), true
+		}
+		return 0, false
+	}
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) {
+		return requestRetryDelay, true
+	}
+	return 0, false
+}
+
+// logCallRequest logs the masked body and the header names; header values
+// carry credentials and are never logged.
+func logCallRequest(call apiCall) {
+	names := make([]string, 0, len(call.header)+1)
+	names = append(names, "Content-Type")
+	for key := range call.header {
+		names = append(names, key)
+	}
+	sort.Strings(names)
+	body, _ := json.MarshalIndent(call.masked, "", "  ")
+	log.Printf("[%s] %s请求信息:\n  URL: %s\n  Headers: %s\n  Body:\n%s", call.provider, call.label, call.url, strings.Join(names, ", "), string(body))
+}
+
+// pageResult parses the page JSON the OCR prompts ask for.
+func pageResult(provider, text string) (Result, error) {
+	var payload struct {
+		HasText        bool       `json:"hasText"`
+		SourceText     string     `json:"sourceText"`
+		TranslatedText string     `json:"translatedText"`
+		Footnotes      []Footnote `json:"footnotes"`
+		Elements       *Elements  `json:"elements"`
+		Blocks         []Block    `json:"blocks"`
+	}
+	if err := json.Unmarshal([]byte(cleanJSON(text)), &payload); err != nil {
+		return Result{}, fmt.Errorf("解析 %s JSON 失败: %w", provider, err)
+	}
+	return Result{
+		HasText:        payload.HasText,
+		SourceText:     payload.SourceText,
+		TranslatedText: payload.TranslatedText,
+		Footnotes:      cleanFootnotes(payload.Footnotes),
+		Elements:       cleanElements(payload.Elements),
+		Blocks:         cleanBlocks(payload.Blocks),
+	}, nil
+}
+
+// maskBase64 stands in for inline image or document data in logs.
+func maskBase64(data string) string {
+	return fmt.Sprintf("<base64 length=%d>", len(data))
+}
+
+// readAllLimited prevents log bloat for large error bodies.
+func readAllLimited(r io.Reader, limit int64) ([]byte, error) {
+	var buf bytes.Buffer
+	if limit <= 0 {
+		limit = 1 << 20
+	}
+	_, err := buf.ReadFrom(io.LimitReader(r, limit))
+	return buf.Bytes(), err
+}
+
+func formatJSON(body []byte) string {
+	var raw interface{}
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return ""
+	}
+	pretty, err := json.MarshalIndent(raw, "", "  ")
+	if err != nil {
+		return ""
+	}
+	return string(pretty)
+}
+
+func cleanJSON(input string) string {
+	input = strings.TrimSpace(input)
+	if strings.HasPrefix(input, "```") {
+		lines := strings.Split(input, "\n")
+		var body []string
+		for _, line := range lines {
+			if strings.HasPrefix(strings.TrimSpace(line), "```") {
+				continue
+			}
+			body = append(body, line)
+		}
+		input = strings.Join(body, "\n")
+	}
+	return strings.TrimSpace(input)
+}
@@ -0,0 +1,78 @@
+package translator
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestSendRetriesGatewayError checks that a 503 is retried in place while a
+// 429 is left to the caller's retry scheduler.
+func TestSendRetriesGatewayError(t *testing.T) {
+	body, err := os.ReadFile(filepath.Join("testdata", "openai", "text.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		status   int
+		wantErr  bool
+		attempts int
+	}{
+		{status: http.StatusServiceUnavailable, attempts: 2},
+		{status: http.StatusTooManyRequests, wantErr: true, attempts: 1},
+	} {
+		attempts := 0
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			attempts++
+			if attempts == 1 {
+				w.WriteHeader(tc.status)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(body)
+		}))
+		client, err := NewTextTranslator(ProviderConfig{Type: ProviderTypeOpenAI, BaseURL: srv.URL, APIKey: testAPIKey, Model: "gpt-4o-mini"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		result, err := client.TranslateText(context.Background(), "Chapter 1")
+		srv.Close()
+		if (err != nil) != tc.wantErr || attempts != tc.attempts {
+			t.Errorf("HTTP %d: err = %v after %d attempts, want error %v after %d", tc.status, err, attempts, tc.wantErr, tc.attempts)
+		}
+		if !tc.wantErr && result.TranslatedText != testChunkText {
+			t.Errorf("HTTP %d: result = %+v", tc.status, result)
+		}
+	}
+}
+
+func TestPageResultBlocks(t *testing.T) {
+	prompts := newPagePrompts(ProviderConfig{})
+	if strings.Contains(prompts.page(context.Background()), blocksPrompt) {
+		t.Errorf("page prompt asks for blocks without WithBlocks")
+	}
+	if !strings.Contains(prompts.page(WithBlocks(context.Background())), blocksPrompt) {
+		t.Errorf("page prompt lacks the block instruction with WithBlocks")
+	}
+
+	result, err := pageResult("Mock", `{"hasText":true,"sourceText":"Title\n\nBody","translatedText":"标题\n\n正文","blocks":[
+		{"text":"Title","translation":"标题","bbox":[900,120,100,40]},
+		{"text":"Body","translation":" 正文 ","bbox":[-5,200,1200,800]},
+		{"text":"Folio","translation":"","bbox":[480,950,520,980]},
+		{"text":"Rule","translation":"—","bbox":[100,500,900,500]}]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Block{
+		{Text: "Title", Translation: "标题", BBox: [4]float64{100, 40, 900, 120}},
+		{Text: "Body", Translation: "正文", BBox: [4]float64{0, 200, 1000, 800}},
+	}
+	if !reflect.DeepEqual(result.Blocks, want) {
+		t.Errorf("blocks = %+v, want %+v", result.Blocks, want)
+	}
+}
@@ -0,0 +1,55 @@
+package translator
+
+import "strings"
+
+// elementsPrompt asks the model to list the page's headings and captions
+// apart from the flat text. Body paragraphs are already in translatedText,
+// so the model may leave body out to save output tokens.
+const elementsPrompt = "可选：另在 JSON 中返回 elements 对象，按页面中的出现顺序列出结构化元素：title（页面上的章节或文章标题）、headers（小标题）、captions（图片、图表、表格的说明文字）、body（正文段落，已包含在 translatedText 中时可省略），各为 [{\"sourceText\":\"原文\",\"translatedText\":\"译文\"}] 数组；脚注仍放在 footnotes 中；页面没有的类别省略即可。"
+
+// Element is one structured part of a page: a title, header, body
+// paragraph or caption.
+type Element struct {
+	SourceText     string `json:"sourceText"`
+	TranslatedText string `json:"translatedText"`
+}
+
+// Elements are the structured parts the model listed next to the flat
+// page text. Every list is optional.
+type Elements struct {
+	Title    []Element `json:"title"`
+	Headers  []Element `json:"headers"`
+	Body     []Element `json:"body"`
+	Captions []Element `json:"captions"`
+}
+
+// cleanElements trims the elements and drops empty ones; it returns nil
+// when nothing is left.
+func cleanElements(elements *Elements) *Elements {
+	if elements == nil {
+		return nil
+	}
+	out := &Elements{
+		Title:    cleanElementList(elements.Title),
+		Headers:  cleanElementList(elements.Headers),
+		Body:     cleanElementList(elements.Body),
+		Captions: cleanElementList(elements.Captions),
+	}
+	if len(out.Title)+len(out.Headers)+len(out.Body)+len(out.Captions) == 0 {
+		return nil
+	}
+	return out
+}
+
+func cleanElementList(list []Element) []Element {
+	var out []Element
+	for _, element := range list {
+		element.SourceText = strings.TrimSpace(element.SourceText)
+		element.TranslatedText = strings.TrimSpace(element.TranslatedText)
+		if element.SourceText == "" && element.TranslatedText == "" {
+			continue
+		}
+		out = append(out, element)
+	}
+	return out
+}
@@ -0,0 +1,232 @@
+package translator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// HTTPError is returned when a provider answers with an HTTP error status.
+type HTTPError struct {
+	Provider   string
+	StatusCode int
+	Status     string
+	// RetryAfter is how long the provider asked to wait before the next
+	// request, from its Retry-After or rate-limit reset headers; zero when
+	// it gave no hint.
+	RetryAfter time.Duration
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("%s 响应错误: %s", e.Provider, e.Status)
+}
+
+func newHTTPError(provider string, resp *http.Response) *HTTPError {
+	return &HTTPError{
+		Provider:   provider,
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		RetryAfter: retryAfter(resp.Header, time.Now()),
+	}
+}
+
+// RetryAfter returns the wait the provider asked for with a failed request,
+// or zero.
+func RetryAfter(err error) time.Duration {
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.RetryAfter
+	}
+	return 0
+}
+
+// maxRetryAfter bounds the wait taken from response headers, which would
+// otherwise let a misbehaving gateway stall a task indefinitely.
+const maxRetryAfter = time.Hour
+
+// rateLimitHeaders pairs the remaining-quota and reset headers of each
+// limit: OpenAI sends reset durations ("6m0s", "20ms"), Anthropic reset
+// times in RFC 3339.
+var rateLimitHeaders = [][2]string{
+	{"x-ratelimit-remaining-requests", "x-ratelimit-reset-requests"},
+	{"x-ratelimit-remaining-tokens", "x-ratelimit-reset-tokens"},
+	{"anthropic-ratelimit-requests-remaining", "anthropic-ratelimit-requests-reset"},
+	{"anthropic-ratelimit-tokens-remaining", "anthropic-ratelimit-tokens-reset"},
+	{"anthropic-ratelimit-input-tokens-remaining", "anthropic-ratelimit-input-tokens-reset"},
+	{"anthropic-ratelimit-output-tokens-remaining", "anthropic-ratelimit-output-tokens-reset"},
+}
+
+// retryAfter reads the wait requested by a rate-limited or overloaded
+// response. Retry-After (seconds or an HTTP date) and OpenAI's
+// retry-after-ms win; otherwise the latest reset of an exhausted limit is
+// used, or of any limit when none is reported exhausted.
+func retryAfter(h http.Header, now time.Time) time.Duration {
+	if ms, err := strconv.ParseFloat(strings.TrimSpace(h.Get("retry-after-ms")), 64); err == nil && ms > 0 {
+		return clampRetryAfter(time.Duration(ms * float64(time.Millisecond)))
+	}
+	if value := strings.TrimSpace(h.Get("Retry-After")); value != "" {
+		if secs, err := strconv.ParseFloat(value, 64); err == nil {
+			return clampRetryAfter(time.Duration(secs * float64(time.Second)))
+		}
+		if at, err := http.ParseTime(value); err == nil {
+			return clampRetryAfter(at.Sub(now))
+		}
+	}
+	var exhausted, latest time.Duration
+	for _, pair := range rateLimitHeaders {
+		reset := parseReset(h.Get(pair[1]), now)
+		if reset <= 0 {
+			continue
+		}
+		latest = max(latest, reset)
+		if strings.TrimSpace(h.Get(pair[0])) == "0" {
+			exhausted = max(exhausted, reset)
+		}
+	}
+	if exhausted > 0 {
+		return clampRetryAfter(exhausted)
+	}
+	return clampRetryAfter(latest)
+}
+
+// parseReset reads a reset header as a duration or an RFC 3339 time.
+func parseReset(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return d
+	}
+	if at, err := time.Parse(time.RFC3339, value); err == nil {
+		return at.Sub(now)
+	}
+	return 0
+}
+
+func clampRetryAfter(d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+	return min(d, maxRetryAfter)
+}
+
+// IsTransient reports whether err is worth retrying later: rate limits,
+// provider-side 5xx errors and network timeouts.
+func IsTransient(err error) bool {
+	if err == nil {
+		return false
+	}
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.StatusCode == http.StatusTooManyRequests ||
+			httpErr.StatusCode == http.StatusRequestTimeout ||
+			httpErr.StatusCode >= 500
+	}
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// RefusalError is returned when the provider declines to process a page,
+// typically because of safety filters on medical or violent content.
+type RefusalError struct {
+	Provider string
+	Reason   string
+}
+
+func (e *RefusalError) Error() string {
+	return fmt.Sprintf("%s 拒绝处理该页: %s", e.Provider, e.Reason)
+}
+
+// IsRefusal reports whether err is a content-policy refusal. Refusals are not
+// transient: retrying the same provider gives the same answer.
+func IsRefusal(err error) bool {
+	var refusal *RefusalError
+	return errors.As(err, &refusal)
+}
+
+// RefusalReason returns the reason recorded on a refusal, or "".
+func RefusalReason(err error) string {
+	var refusal *RefusalError
+	if errors.As(err, &refusal) {
+		return refusal.Reason
+	}
+	return ""
+}
+
+// refusalFinishReasons are the finish/stop reasons providers use when a
+// response was withheld by their safety systems.
+var refusalFinishReasons = map[string]bool{
+	"content_filter":     true,
+	"refusal":            true,
+	"safety":             true,
+	"prohibited_content": true,
+	"blocklist":          true,
+	"spii":               true,
+	"image_safety":       true,
+}
+
+// refusalPhrases match the opening of a model answering with an apology
+// instead of the requested JSON.
+var refusalPhrases = []string{
+	"i'm sorry, but i can",
+	"i’m sorry, but i can",
+	"i am sorry, but i can",
+	"sorry, i can't",
+	"i can't assist",
+	"i cannot assist",
+	"i can't help with",
+	"i cannot help with",
+	"i'm unable to",
+	"i am unable to",
+	"i won't be able to",
+	"抱歉，我无法",
+	"抱歉，我不能",
+	"对不起，我无法",
+	"对不起，我不能",
+	"我无法协助",
+	"我不能协助",
+}
+
+// maxRefusalTextRunes keeps the phrase check from flagging translations that
+// merely contain an apology somewhere in the page text.
+const maxRefusalTextRunes = 400
+
+// detectRefusal returns a RefusalError when the finish reason or the reply
+// text shows the provider declined the request, otherwise nil.
+func detectRefusal(provider, finishReason, text string) error {
+	if reason := strings.ToLower(strings.TrimSpace(finishReason)); refusalFinishReasons[reason] {
+		return &RefusalError{Provider: provider, Reason: reason}
+	}
+	trimmed := strings.TrimSpace(text)
+	if trimmed == "" || strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "```") {
+		return nil
+	}
+	if utf8.RuneCountInString(trimmed) > maxRefusalTextRunes {
+		return nil
+	}
+	lower := strings.ToLower(trimmed)
+	for _, phrase := range refusalPhrases {
+		if strings.HasPrefix(lower, phrase) {
+			return &RefusalError{Provider: provider, Reason: firstLine(trimmed)}
+		}
+	}
+	return nil
+}
+
+func firstLine(text string) string {
+	if idx := strings.IndexByte(text, '\n'); idx >= 0 {
+		text = text[:idx]
+	}
+	return strings.TrimSpace(text)
+}
@@ -0,0 +1,51 @@
+package translator
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	cases := []struct {
+		name    string
+		headers map[string]string
+		want    time.Duration
+	}{
+		{name: "none", want: 0},
+		{name: "seconds", headers: map[string]string{"Retry-After": "7"}, want: 7 * time.Second},
+		{name: "http date", headers: map[string]string{"Retry-After": "Wed, 01 May 2024 12:00:30 GMT"}, want: 30 * time.Second},
+		{name: "past date", headers: map[string]string{"Retry-After": "Wed, 01 May 2024 11:59:00 GMT"}, want: 0},
+		{name: "milliseconds win", headers: map[string]string{"retry-after-ms": "1500", "Retry-After": "2"}, want: 1500 * time.Millisecond},
+		{name: "openai exhausted tokens", headers: map[string]string{
+			"x-ratelimit-remaining-requests": "12",
+			"x-ratelimit-reset-requests":     "6m0s",
+			"x-ratelimit-remaining-tokens":   "0",
+			"x-ratelimit-reset-tokens":       "850ms",
+		}, want: 850 * time.Millisecond},
+		{name: "openai none exhausted", headers: map[string]string{
+			"x-ratelimit-reset-requests": "2s",
+			"x-ratelimit-reset-tokens":   "1m0.5s",
+		}, want: time.Minute + 500*time.Millisecond},
+		{name: "anthropic reset time", headers: map[string]string{
+			"anthropic-ratelimit-requests-remaining": "0",
+			"anthropic-ratelimit-requests-reset":     "2024-05-01T12:00:20Z",
+			"anthropic-ratelimit-tokens-remaining":   "5000",
+			"anthropic-ratelimit-tokens-reset":       "2024-05-01T12:01:00Z",
+		}, want: 20 * time.Second},
+		{name: "capped", headers: map[string]string{"Retry-After": "86400"}, want: maxRetryAfter},
+		{name: "garbage", headers: map[string]string{"Retry-After": "soon", "x-ratelimit-reset-tokens": "later"}, want: 0},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			h := http.Header{}
+			for k, v := range tc.headers {
+				h.Set(k, v)
+			}
+			if got := retryAfter(h, now); got != tc.want {
+				t.Errorf("retryAfter = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,542 @@
+package translator
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"image"
+	"image/png"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The provider clients are exercised against an httptest server that
+// replays the recorded responses under testdata/<provider>/ and captures the
+// request for inspection. Refresh a fixture by saving the body of a real
+// response under the same name.
+
+const (
+	testAPIKey     = "test-key"
+	testChunkText  = "第一章\n\n四月里一个晴朗寒冷的日子。"
+	wantSource     = "Chapter 1\n\nIt was a bright cold day in April.[^1]"
+	wantTranslated = "第一章\n\n四月里一个晴朗寒冷的日子。[^1]"
+	wantFormatted  = "第一章\n\n四月里一个晴朗寒冷的日子，时钟敲了十三下。"
+)
+
+// recordedRequest is what the fixture server saw.
+type recordedRequest struct {
+	Method string
+	Path   string
+	Header http.Header
+	Body   map[string]interface{}
+}
+
+// replay serves testdata/<provider>/<fixture>.json with the given status to
+// every request and records the last one.
+func replay(t *testing.T, provider, fixture string, status int) (*httptest.Server, *recordedRequest) {
+	t.Helper()
+	body, err := os.ReadFile(filepath.Join("testdata", provider, fixture+".json"))
+	if err != nil {
+		t.Fatalf("read fixture: %v", err)
+	}
+	rec := &recordedRequest{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		rec.Method = r.Method
+		rec.Path = r.URL.Path
+		rec.Header = r.Header.Clone()
+		rec.Body = nil
+		if err := json.Unmarshal(data, &rec.Body); err != nil {
+			t.Errorf("request body is not JSON: %v", err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write(body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, rec
+}
+
+// lookup walks a decoded JSON document by object keys and array indexes.
+func lookup(t *testing.T, doc interface{}, path ...interface{}) interface{} {
+	t.Helper()
+	cur := doc
+	for _, step := range path {
+		switch key := step.(type) {
+		case string:
+			obj, ok := cur.(map[string]interface{})
+			if !ok {
+				t.Fatalf("%v: expected object at %q", path, key)
+			}
+			cur = obj[key]
+		case int:
+			arr, ok := cur.([]interface{})
+			if !ok || key >= len(arr) {
+				t.Fatalf("%v: expected array with index %d", path, key)
+			}
+			cur = arr[key]
+		}
+	}
+	return cur
+}
+
+func lookupString(t *testing.T, doc interface{}, path ...interface{}) string {
+	t.Helper()
+	value, ok := lookup(t, doc, path...).(string)
+	if !ok {
+		t.Fatalf("%v: expected string", path)
+	}
+	return value
+}
+
+func writeTestPNG(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "page-001.png")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if err := png.Encode(file, image.NewGray(image.Rect(0, 0, 4, 4))); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// fixtureProvider describes how one provider is pointed at the fixture
+// server and what its requests must look like.
+type fixtureProvider struct {
+	name      string
+	typ       ProviderType
+	model     string
+	base      func(srvURL string) string
+	path      string
+	usage     Usage
+	checkAuth func(t *testing.T, h http.Header)
+	// systemPrompt and userText extract the instructions from a request.
+	systemPrompt func(t *testing.T, body map[string]interface{}) string
+	userText     func(t *testing.T, body map[string]interface{}) string
+	// attachment returns the media type and decoded data of the image or
+	// text chunk sent with a request.
+	attachment func(t *testing.T, body map[string]interface{}) (string, []byte)
+	// inlineChunk is set when formatter text chunks go into the prompt
+	// instead of an attachment.
+	inlineChunk bool
+}
+
+var fixtureProviders = []fixtureProvider{
+	{
+		name:        "openai",
+		typ:         ProviderTypeOpenAI,
+		model:       "gpt-4o-mini",
+		base:        func(u string) string { return u + "/v1" },
+		path:        "/v1/chat/completions",
+		usage:       Usage{InputTokens: 1105, OutputTokens: 87},
+		inlineChunk: true,
+		checkAuth: func(t *testing.T, h http.Header) {
+			if got := h.Get("Authorization"); got != "Bearer "+testAPIKey {
+				t.Errorf("Authorization = %q", got)
+			}
+		},
+		systemPrompt: func(t *testing.T, body map[string]interface{}) string {
+			if text, ok := lookup(t, body, "messages", 0, "content").(string); ok {
+				return text
+			}
+			return lookupString(t, body, "messages", 0, "content", 0, "text")
+		},
+		userText: func(t *testing.T, body map[string]interface{}) string {
+			if text, ok := lookup(t, body, "messages", 1, "content").(string); ok {
+				return text
+			}
+			return lookupString(t, body, "messages", 1, "content", 0, "text")
+		},
+		attachment: func(t *testing.T, body map[string]interface{}) (string, []byte) {
+			uri := lookupString(t, body, "messages", 1, "content", 1, "image_url", "url")
+			header, payload, _ := strings.Cut(strings.TrimPrefix(uri, "data:"), ",")
+			data, err := base64.StdEncoding.DecodeString(payload)
+			if err != nil {
+				t.Fatalf("image data: %v", err)
+			}
+			return strings.TrimSuffix(header, ";base64"), data
+		},
+	},
+	{
+		name:  "gemini",
+		typ:   ProviderTypeGemini,
+		model: "gemini-1.5-flash",
+		base:  func(u string) string { return u },
+		path:  "/v1beta/models/gemini-1.5-flash:generateContent",
+		usage: Usage{InputTokens: 1290, OutputTokens: 91},
+		checkAuth: func(t *testing.T, h http.Header) {
+			if got := h.Get("x-goog-api-key"); got != testAPIKey {
+				t.Errorf("x-goog-api-key = %q", got)
+			}
+		},
+		systemPrompt: func(t *testing.T, body map[string]interface{}) string {
+			return lookupString(t, body, "system_instruction", "parts", 0, "text")
+		},
+		userText: func(t *testing.T, body map[string]interface{}) string {
+			return lookupString(t, body, "contents", 0, "parts", 0, "text")
+		},
+		attachment: func(t *testing.T, body map[string]interface{}) (string, []byte) {
+			data, err := base64.StdEncoding.DecodeString(lookupString(t, body, "contents", 0, "parts", 1, "inline_data", "data"))
+			if err != nil {
+				t.Fatalf("inline data: %v", err)
+			}
+			return lookupString(t, body, "contents", 0, "parts", 1, "inline_data", "mime_type"), data
+		},
+	},
+	{
+		name:  "anthropic",
+		typ:   ProviderTypeAnthropic,
+		model: "claude-3-5-sonnet",
+		base:  func(u string) string { return u + "/v1" },
+		path:  "/v1/messages",
+		usage: Usage{InputTokens: 1420, OutputTokens: 95},
+		checkAuth: func(t *testing.T, h http.Header) {
+			if got := h.Get("x-api-key"); got != testAPIKey {
+				t.Errorf("x-api-key = %q", got)
+			}
+			if got := h.Get("anthropic-version"); got != "2023-06-01" {
+				t.Errorf("anthropic-version = %q", got)
+			}
+		},
+		systemPrompt: func(t *testing.T, body map[string]interface{}) string {
+			return lookupString(t, body, "system")
+		},
+		userText: func(t *testing.T, body map[string]interface{}) string {
+			return lookupString(t, body, "messages", 0, "content", 0, "text")
+		},
+		attachment: func(t *testing.T, body map[string]interface{}) (string, []byte) {
+			source := lookup(t, body, "messages", 0, "content", 1, "source")
+			mediaType := lookupString(t, source, "media_type")
+			data := lookupString(t, source, "data")
+			if lookupString(t, source, "type") == "text" {
+				return mediaType, []byte(data)
+			}
+			decoded, err := base64.StdEncoding.DecodeString(data)
+			if err != nil {
+				t.Fatalf("image data: %v", err)
+			}
+			return mediaType, decoded
+		},
+	},
+	{
+		name:        "ollama",
+		typ:         ProviderTypeOllama,
+		model:       "qwen2.5vl:7b",
+		base:        func(u string) string { return u },
+		path:        "/api/chat",
+		usage:       Usage{InputTokens: 1376, OutputTokens: 102},
+		inlineChunk: true,
+		checkAuth: func(t *testing.T, h http.Header) {
+			if got := h.Get("Authorization"); got != "Bearer "+testAPIKey {
+				t.Errorf("Authorization = %q", got)
+			}
+		},
+		systemPrompt: func(t *testing.T, body map[string]interface{}) string {
+			return lookupString(t, body, "messages", 0, "content")
+		},
+		userText: func(t *testing.T, body map[string]interface{}) string {
+			return lookupString(t, body, "messages", 1, "content")
+		},
+		attachment: func(t *testing.T, body map[string]interface{}) (string, []byte) {
+			data, err := base64.StdEncoding.DecodeString(lookupString(t, body, "messages", 1, "images", 0))
+			if err != nil {
+				t.Fatalf("image data: %v", err)
+			}
+			return sniffImageMIME(data), data
+		},
+	},
+}
+
+func (p fixtureProvider) config(srvURL string) ProviderConfig {
+	return ProviderConfig{
+		Type:           p.typ,
+		BaseURL:        p.base(srvURL),
+		APIKey:         testAPIKey,
+		Model:          p.model,
+		Timeout:        5 * time.Second,
+		MaxTokens:      2048,
+		OptimizeLayout: true,
+	}
+}
+
+// usageContext collects the usage the client reports.
+func usageContext() (context.Context, *Usage) {
+	var total Usage
+	ctx := WithUsageRecorder(context.Background(), func(u Usage) {
+		total.InputTokens += u.InputTokens
+		total.OutputTokens += u.OutputTokens
+	})
+	return ctx, &total
+}
+
+func TestTranslateFixtures(t *testing.T) {
+	imagePath := writeTestPNG(t)
+	imageData, err := os.ReadFile(imagePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range fixtureProviders {
+		p := p
+		t.Run(p.name+"/success", func(t *testing.T) {
+			srv, rec := replay(t, p.name, "success", http.StatusOK)
+			client, err := NewTranslator(p.config(srv.URL))
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx, usage := usageContext()
+			ctx = WithPromptHint(WithPageNumber(ctx, 3), "术语：Big Brother 译为“老大哥”")
+			result, err := client.Translate(ctx, imagePath)
+			if err != nil {
+				t.Fatalf("Translate: %v", err)
+			}
+
+			if rec.Method != http.MethodPost || rec.Path != p.path {
+				t.Errorf("request = %s %s, want POST %s", rec.Method, rec.Path, p.path)
+			}
+			p.checkAuth(t, rec.Header)
+			if got := rec.Header.Get("Content-Type"); got != "application/json" {
+				t.Errorf("Content-Type = %q", got)
+			}
+			if p.typ != ProviderTypeGemini {
+				if got := lookupString(t, rec.Body, "model"); got != p.model {
+					t.Errorf("model = %q", got)
+				}
+			}
+			if got := p.systemPrompt(t, rec.Body); got != (Prompts{}).ocrSystem() {
+				t.Errorf("system prompt = %q", got)
+			}
+			user := p.userText(t, rec.Body)
+			for _, want := range []string{(Prompts{}).ocrUser(), footnotePrompt, elementsPrompt, "老大哥"} {
+				if !strings.Contains(user, want) {
+					t.Errorf("user prompt lacks %q:\n%s", want, user)
+				}
+			}
+			mediaType, data := p.attachment(t, rec.Body)
+			if mediaType != "image/png" || string(data) != string(imageData) {
+				t.Errorf("attachment = %s (%d bytes), want the page PNG", mediaType, len(data))
+			}
+
+			if !result.HasText || result.SourceText != wantSource || result.TranslatedText != wantTranslated {
+				t.Errorf("result = %+v", result)
+			}
+			wantNotes := []Footnote{{Marker: "1", SourceText: "First published 1949.", TranslatedText: "初版于 1949 年。"}}
+			if len(result.Footnotes) != 1 || result.Footnotes[0] != wantNotes[0] {
+				t.Errorf("footnotes = %+v, want %+v", result.Footnotes, wantNotes)
+			}
+			wantTitle := Element{SourceText: "Chapter 1", TranslatedText: "第一章"}
+			if e := result.Elements; e == nil || len(e.Title) != 1 || e.Title[0] != wantTitle || e.Captions != nil {
+				t.Errorf("elements = %+v, want title %+v only", e, wantTitle)
+			}
+			if *usage != p.usage {
+				t.Errorf("usage = %+v, want %+v", *usage, p.usage)
+			}
+		})
+
+		t.Run(p.name+"/rate_limited", func(t *testing.T) {
+			srv, _ := replay(t, p.name, "rate_limited", http.StatusTooManyRequests)
+			client, err := NewTranslator(p.config(srv.URL))
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx, usage := usageContext()
+			_, err = client.Translate(ctx, imagePath)
+			var httpErr *HTTPError
+			if !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusTooManyRequests {
+				t.Fatalf("err = %v, want HTTP 429", err)
+			}
+			if !IsTransient(err) || IsRefusal(err) {
+				t.Errorf("429 should be transient and not a refusal")
+			}
+			if usage.Total() != 0 {
+				t.Errorf("usage reported for a failed request: %+v", *usage)
+			}
+		})
+
+		t.Run(p.name+"/truncated", func(t *testing.T) {
+			srv, _ := replay(t, p.name, "truncated", http.StatusOK)
+			client, err := NewTranslator(p.config(srv.URL))
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx, usage := usageContext()
+			_, err = client.Translate(ctx, imagePath)
+			if err == nil || !strings.Contains(err.Error(), "解析") {
+				t.Fatalf("err = %v, want a parse error", err)
+			}
+			if IsTransient(err) || IsRefusal(err) {
+				t.Errorf("truncated output classified as transient or refusal: %v", err)
+			}
+			if *usage != p.usage {
+				t.Errorf("tokens spent on a truncated answer must still count: usage = %+v", *usage)
+			}
+		})
+
+		t.Run(p.name+"/refusal", func(t *testing.T) {
+			srv, _ := replay(t, p.name, "refusal", http.StatusOK)
+			client, err := NewTranslator(p.config(srv.URL))
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = client.Translate(context.Background(), imagePath)
+			if !IsRefusal(err) {
+				t.Fatalf("err = %v, want a refusal", err)
+			}
+			if IsTransient(err) || RefusalReason(err) == "" {
+				t.Errorf("refusal = %v, reason %q", err, RefusalReason(err))
+			}
+		})
+	}
+}
+
+func TestTranslateTextFixtures(t *testing.T) {
+	for _, p := range fixtureProviders {
+		p := p
+		t.Run(p.name, func(t *testing.T) {
+			srv, rec := replay(t, p.name, "text", http.StatusOK)
+			client, err := NewTextTranslator(p.config(srv.URL))
+			if err != nil {
+				t.Fatal(err)
+			}
+			source := "Chapter 1\n\nIt was a bright cold day in April."
+			ctx, usage := usageContext()
+			result, err := client.TranslateText(ctx, source)
+			if err != nil {
+				t.Fatalf("TranslateText: %v", err)
+			}
+			if rec.Path != p.path {
+				t.Errorf("path = %s, want %s", rec.Path, p.path)
+			}
+			p.checkAuth(t, rec.Header)
+			if got := p.systemPrompt(t, rec.Body); got != (Prompts{}).textSystem() {
+				t.Errorf("system prompt = %q", got)
+			}
+			if got := p.userText(t, rec.Body); got != source {
+				t.Errorf("user text = %q, want the source text", got)
+			}
+			if !result.HasText || result.SourceText != source || result.TranslatedText != testChunkText {
+				t.Errorf("result = %+v", result)
+			}
+			if *usage != p.usage {
+				t.Errorf("usage = %+v, want %+v", *usage, p.usage)
+			}
+		})
+	}
+}
+
+func TestTargetLanguagePrompts(t *testing.T) {
+	for _, p := range fixtureProviders {
+		p := p
+		t.Run(p.name, func(t *testing.T) {
+			srv, rec := replay(t, p.name, "text", http.StatusOK)
+			cfg := p.config(srv.URL)
+			cfg.TargetLanguage = "English"
+			cfg.Prompts.Extra = "Keep {language} punctuation."
+			client, err := NewTextTranslator(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := client.TranslateText(context.Background(), "第一章"); err != nil {
+				t.Fatalf("TranslateText: %v", err)
+			}
+			got := p.systemPrompt(t, rec.Body)
+			if !strings.Contains(got, "翻译为English") || !strings.Contains(got, "Keep English punctuation.") || strings.Contains(got, DefaultTargetLanguage) {
+				t.Errorf("system prompt = %q, want English as the target", got)
+			}
+		})
+	}
+}
+
+func TestFormatterFixtures(t *testing.T) {
+	chunk := FormatterChunk{FileName: "chunk-001.txt", MimeType: "text/plain", Data: []byte(testChunkText)}
+	for _, p := range fixtureProviders {
+		p := p
+		t.Run(p.name+"/success", func(t *testing.T) {
+			srv, rec := replay(t, p.name, "formatter", http.StatusOK)
+			formatter, err := NewFormatter(p.config(srv.URL))
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx, usage := usageContext()
+			got, err := formatter.Format(ctx, chunk, 1)
+			if err != nil {
+				t.Fatalf("Format: %v", err)
+			}
+			if got != wantFormatted {
+				t.Errorf("formatted = %q", got)
+			}
+			if rec.Path != p.path {
+				t.Errorf("path = %s, want %s", rec.Path, p.path)
+			}
+			p.checkAuth(t, rec.Header)
+			if got := p.systemPrompt(t, rec.Body); got != (Prompts{}).formatterSystem() {
+				t.Errorf("system prompt = %q", got)
+			}
+			user := p.userText(t, rec.Body)
+			if !strings.Contains(user, formatterGuideline) || !strings.Contains(user, chunk.FileName) {
+				t.Errorf("instruction lacks the guideline or file name:\n%s", user)
+			}
+			if p.inlineChunk {
+				if !strings.Contains(user, testChunkText) {
+					t.Errorf("chunk text not inlined in the prompt")
+				}
+			} else {
+				mediaType, data := p.attachment(t, rec.Body)
+				if mediaType != "text/plain" || string(data) != testChunkText {
+					t.Errorf("attachment = %s %q, want the text chunk", mediaType, data)
+				}
+			}
+			if *usage != p.usage {
+				t.Errorf("usage = %+v, want %+v", *usage, p.usage)
+			}
+		})
+
+		t.Run(p.name+"/rate_limited", func(t *testing.T) {
+			srv, _ := replay(t, p.name, "rate_limited", http.StatusTooManyRequests)
+			formatter, err := NewFormatter(p.config(srv.URL))
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = formatter.Format(context.Background(), chunk, 1)
+			var httpErr *HTTPError
+			if !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusTooManyRequests || !IsTransient(err) {
+				t.Fatalf("err = %v, want transient HTTP 429", err)
+			}
+		})
+	}
+}
+
+func TestAnthropicEndpoint(t *testing.T) {
+	for base, want := range map[string]string{
+		"":                              "https://api.anthropic.com/v1/messages",
+		"https://api.anthropic.com":     "https://api.anthropic.com/v1/messages",
+		"https://api.anthropic.com/v1/": "https://api.anthropic.com/v1/messages",
+		"https://gateway.example/anthropic/v1/messages": "https://gateway.example/anthropic/v1/messages",
+	} {
+		if got := anthropicEndpoint(base); got != want {
+			t.Errorf("anthropicEndpoint(%q) = %q, want %q", base, got, want)
+		}
+	}
+}
+
+func TestOllamaEndpoint(t *testing.T) {
+	for base, want := range map[string]string{
+		"":                                      "http://localhost:11434/api/chat",
+		"http://gpu-box:11434/":                 "http://gpu-box:11434/api/chat",
+		"http://gpu-box:11434/api":              "http://gpu-box:11434/api/chat",
+		"https://proxy.example/ollama/api/chat": "https://proxy.example/ollama/api/chat",
+	} {
+		if got := ollamaEndpoint(base); got != want {
+			t.Errorf("ollamaEndpoint(%q) = %q, want %q", base, got, want)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package translator
+
+import "strings"
+
+// footnotePrompt asks the model to keep footnotes out of the body text.
+const footnotePrompt = "如页面包含脚注或尾注（通常位于页面底部，以数字或符号标记），不要把注释内容混入正文：正文中在引用处保留标记并写成 [^标记] 的形式，另在 JSON 中返回 footnotes 数组，格式为 [{\"marker\":\"1\",\"sourceText\":\"原文注释\",\"translatedText\":\"译文注释\"}]；没有脚注时返回空数组。"
+
+// Footnote is a note the model separated from the page body.
+type Footnote struct {
+	Marker         string `json:"marker"`
+	SourceText     string `json:"sourceText"`
+	TranslatedText string `json:"translatedText"`
+}
+
+func cleanFootnotes(notes []Footnote) []Footnote {
+	var out []Footnote
+	for _, note := range notes {
+		note.Marker = strings.Trim(strings.TrimSpace(note.Marker), "[]^")
+		note.SourceText = strings.TrimSpace(note.SourceText)
+		note.TranslatedText = strings.TrimSpace(note.TranslatedText)
+		if note.Marker == "" || (note.SourceText == "" && note.TranslatedText == "") {
+			continue
+		}
+		out = append(out, note)
+	}
+	return out
+}
@@ -0,0 +1,52 @@
+package translator
+
+import (
+	"context"
+	"log"
+
+	"pdftool/internal/respcache"
+)
+
+// cachedFormatter answers repeated chunks from the response cache.
+type cachedFormatter struct {
+	inner  TextFormatter
+	cache  *respcache.Cache
+	prefix [][]byte
+}
+
+// WithFormatterCache wraps formatter so identical chunks for the same
+// provider, model and prompts are served from cache. A nil cache returns
+// formatter unchanged.
+func WithFormatterCache(formatter TextFormatter, cache *respcache.Cache, cfg ProviderConfig) TextFormatter {
+	if cache == nil {
+		return formatter
+	}
+	return &cachedFormatter{
+		inner: formatter,
+		cache: cache,
+		prefix: [][]byte{
+			[]byte("formatter"),
+			[]byte(NormalizeProviderType(string(cfg.Type))),
+			[]byte(cfg.BaseURL),
+			[]byte(cfg.Model),
+			[]byte(cfg.prompts().formatterSystem()),
+		},
+	}
+}
+
+func (f *cachedFormatter) Format(ctx context.Context, chunk FormatterChunk, chunkIndex int) (string, error) {
+	parts := append([][]byte{}, f.prefix...)
+	key := respcache.Key(append(parts, []byte(chunk.instruction()), chunk.Data)...)
+	if data, ok := f.cache.Get(key); ok {
+		log.Printf("[Formatter] chunk %d 命中响应缓存", chunkIndex)
+		return string(data), nil
+	}
+	result, err := f.inner.Format(ctx, chunk, chunkIndex)
+	if err != nil {
+		return "", err
+	}
+	if err := f.cache.Put(key, []byte(result)); err != nil {
+		log.Printf("[Formatter] 写入响应缓存失败: %v", err)
+	}
+	return result, nil
+}
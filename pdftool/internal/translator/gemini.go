@@ -1,170 +1,145 @@
 package translator
 
 import (
-	"bytes"
 	"context"
 	"encoding/base64"
-	"encoding/json"
 	"fmt"
-	"io"
-	"log"
 	"net/http"
 	"net/url"
-	"os"
 	"strings"
 	"time"
 )
 
-type geminiTranslator struct {
-	baseURL        string
-	apiKey         string
-	model          string
-	timeout        time.Duration
-	httpClient     *http.Client
-	systemPrompt   string
-	userPrompt     string
-	maxTokens      int
-	optimizeLayout bool
-}
-
 const defaultGeminiBase = "https://generativelanguage.googleapis.com/v1beta"
 
-func newGeminiTranslator(cfg ProviderConfig) (Translator, error) {
+// geminiClient talks to the Gemini generateContent endpoint.
+type geminiClient struct {
+	httpClient *http.Client
+	endpoint   string
+	apiKey     string
+	maxTokens  int
+}
+
+func newGeminiClient(cfg ProviderConfig) (geminiClient, error) {
 	if strings.TrimSpace(cfg.APIKey) == "" {
-		return nil, fmt.Errorf("Gemini API Key 未配置")
+		return geminiClient{}, fmt.Errorf("Gemini API Key 未配置")
 	}
 	if strings.TrimSpace(cfg.Model) == "" {
-		return nil, fmt.Errorf("Gemini 模型未配置")
+		return geminiClient{}, fmt.Errorf("Gemini 模型未配置")
 	}
 	if cfg.Timeout == 0 {
 		cfg.Timeout = 300 * time.Second
 	}
-	baseURL := strings.TrimRight(cfg.BaseURL, "/")
-	if baseURL == "" {
-		baseURL = defaultGeminiBase
-	}
-
-	return &geminiTranslator{
-		baseURL:   baseURL,
-		apiKey:    cfg.APIKey,
-		model:     cfg.Model,
-		timeout:   cfg.Timeout,
-		maxTokens: SanitizeMaxTokens(cfg.MaxTokens),
-		httpClient: &http.Client{
-			Timeout: cfg.Timeout,
-		},
-		systemPrompt:   "你是一个专业的OCR与翻译助手。阅读用户提供的图片，先识别出存在的文本，再将其翻译为简体中文。必须输出严格的JSON对象，格式为 {\"hasText\":bool,\"sourceText\":\"原始文本\",\"translatedText\":\"翻译后的文本\"} 。如果图片中没有文本，设置 hasText 为 false，另外两个字段留空字符串。",
-		userPrompt:     "请识别这页图像中的所有可见文本并翻译成简体中文。保持原本的段落顺序，返回JSON字符串。",
-		optimizeLayout: cfg.OptimizeLayout,
+	return geminiClient{
+		httpClient: newHTTPClient(cfg),
+		endpoint:   geminiEndpoint(cfg.BaseURL, cfg.Model),
+		apiKey:     strings.TrimSpace(cfg.APIKey),
+		maxTokens:  SanitizeMaxTokens(cfg.MaxTokens),
 	}, nil
 }
 
-func (t *geminiTranslator) Translate(ctx context.Context, imagePath string) (Result, error) {
-	pageNumber := pageNumberFromContext(ctx)
-	data, err := os.ReadFile(imagePath)
-	if err != nil {
-		return Result{}, fmt.Errorf("读取图片失败: %w", err)
-	}
-	mimeType := detectImageMIME(data)
-
-	inline := geminiInlineData{
-		MIME: mimeType,
-		Data: base64.StdEncoding.EncodeToString(data),
-	}
-	userPrompt := t.userPrompt
-	if t.optimizeLayout {
-		userPrompt = userPrompt + " 请确保 sourceText 与 translatedText 字段在排版上保持清晰的段落、标题和列表结构。"
-	}
-
-	reqBody := geminiRequest{
+// generate sends a system instruction and one user turn and returns the
+// answer.
+func (c geminiClient) generate(ctx context.Context, provider, label, system string, temperature float64, parts ...geminiPart) (string, error) {
+	payload := geminiRequest{
 		GenerationConfig: geminiGeneration{
-			Temperature:    0.1,
-			MaxOutputToken: t.maxTokens,
-		},
-		Contents: []geminiContent{
-			{
-				Role: "user",
-				Parts: []geminiPart{
-					{Text: userPrompt},
-					{InlineData: &inline},
-				},
-			},
+			Temperature:    temperature,
+			MaxOutputToken: c.maxTokens,
 		},
+		Contents: []geminiContent{{Role: "user", Parts: parts}},
 	}
-	if prompt := strings.TrimSpace(t.systemPrompt); prompt != "" {
-		reqBody.SystemInstruction = &geminiContent{
-			Parts: []geminiPart{{Text: prompt}},
-		}
+	if system = strings.TrimSpace(system); system != "" {
+		payload.SystemInstruction = &geminiContent{Parts: []geminiPart{{Text: system}}}
 	}
+	return send(ctx, c.httpClient, apiCall{
+		provider: provider,
+		label:    label,
+		url:      c.endpoint,
+		header:   http.Header{"X-Goog-Api-Key": {c.apiKey}},
+		body:     payload,
+		masked:   maskGeminiPayload(payload),
+	}, &geminiResponse{})
+}
 
-	fullURL := t.buildEndpoint()
-	bodyBytes, _ := json.Marshal(reqBody)
-	logGeminiRequest(fullURL, reqBody, pageNumber)
+type geminiTranslator struct {
+	geminiClient
+	prompts    pagePrompts
+	imageTypes []string
+}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, fullURL, bytes.NewReader(bodyBytes))
+func newGeminiTranslator(cfg ProviderConfig) (Translator, error) {
+	client, err := newGeminiClient(cfg)
 	if err != nil {
-		return Result{}, err
+		return nil, err
 	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("x-goog-api-key", t.apiKey)
+	return &geminiTranslator{
+		geminiClient: client,
+		prompts:      newPagePrompts(cfg),
+		imageTypes:   imageTypes(cfg, geminiImageTypes),
+	}, nil
+}
 
-	resp, err := t.httpClient.Do(req)
+func (t *geminiTranslator) Translate(ctx context.Context, imagePath string) (Result, error) {
+	img, err := loadPageImage(imagePath, t.imageTypes)
 	if err != nil {
-		logGeminiError(err, pageNumber)
-		return Result{}, fmt.Errorf("调用 Gemini 失败: %w", err)
+		return Result{}, err
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode >= 400 {
-		data, _ := readAllLimited(resp.Body, 1<<20)
-		logGeminiHTTPError(resp.StatusCode, data, pageNumber)
-		return Result{}, fmt.Errorf("Gemini 响应错误: %s", resp.Status)
+	text, err := t.generate(ctx, "Gemini", pageLabel(ctx), t.prompts.system, 0.1,
+		geminiPart{Text: t.prompts.page(ctx)},
+		geminiPart{InlineData: &geminiInlineData{MIME: img.MIME, Data: base64.StdEncoding.EncodeToString(img.Data)}},
+	)
+	if err != nil {
+		return Result{}, err
 	}
+	return pageResult("Gemini", text)
+}
 
-	var parsed geminiResponse
-	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
-		return Result{}, fmt.Errorf("解析 Gemini 响应失败: %w", err)
+func (t *geminiTranslator) TranslateText(ctx context.Context, sourceText string) (Result, error) {
+	text, err := t.generate(ctx, "Gemini", pageLabel(ctx), t.prompts.text, 0.1, geminiPart{Text: sourceText})
+	if err != nil {
+		return Result{}, err
 	}
-	logGeminiResponse(parsed, pageNumber)
+	return textResult(sourceText, text)
+}
 
-	text := parsed.FirstText()
-	if strings.TrimSpace(text) == "" {
-		return Result{}, fmt.Errorf("Gemini 返回空内容")
-	}
+// geminiFormatter attaches the chunk as inline data of its own MIME type.
+type geminiFormatter struct {
+	geminiClient
+	systemPrompt string
+}
 
-	clean := cleanJSON(text)
-	var payload struct {
-		HasText        bool   `json:"hasText"`
-		SourceText     string `json:"sourceText"`
-		TranslatedText string `json:"translatedText"`
-	}
-	if err := json.Unmarshal([]byte(clean), &payload); err != nil {
-		return Result{}, fmt.Errorf("解析 Gemini JSON 失败: %w", err)
+func newGeminiFormatter(cfg ProviderConfig) (TextFormatter, error) {
+	client, err := newGeminiClient(cfg)
+	if err != nil {
+		return nil, err
 	}
-	return Result{
-		HasText:        payload.HasText,
-		SourceText:     payload.SourceText,
-		TranslatedText: payload.TranslatedText,
-	}, nil
+	return &geminiFormatter{geminiClient: client, systemPrompt: cfg.prompts().formatterSystem()}, nil
+}
+
+func (f *geminiFormatter) Format(ctx context.Context, chunk FormatterChunk, chunkIndex int) (string, error) {
+	return f.generate(ctx, "Gemini Formatter", chunkLabel(chunkIndex), f.systemPrompt, 0.2,
+		geminiPart{Text: formatterPrompt(chunk, false)},
+		geminiPart{InlineData: &geminiInlineData{MIME: chunk.MimeType, Data: base64.StdEncoding.EncodeToString(chunk.Data)}},
+	)
 }
 
-func (t *geminiTranslator) buildEndpoint() string {
-	base := t.baseURL
-	if strings.Contains(base, "/models/") && strings.Contains(base, ":generateContent") {
-		return base
+// geminiEndpoint accepts the API root, with or without /v1beta, a model
+// URL, or the full generateContent endpoint.
+func geminiEndpoint(base, model string) string {
+	base = strings.TrimRight(strings.TrimSpace(base), "/")
+	if base == "" {
+		base = defaultGeminiBase
 	}
-	base = strings.TrimRight(base, "/")
-	if !strings.Contains(base, "/models/") {
-		if !strings.HasSuffix(base, "/v1beta") {
-			base = base + "/v1beta"
+	if strings.Contains(base, "/models/") {
+		if strings.Contains(base, ":") {
+			return base
 		}
-		return fmt.Sprintf("%s/models/%s:generateContent", base, url.PathEscape(t.model))
-	}
-	// base includes /models/ but maybe missing action
-	if !strings.Contains(base, ":") {
 		return base + ":generateContent"
 	}
-	return base
+	if !strings.HasSuffix(base, "/v1beta") {
+		base += "/v1beta"
+	}
+	return fmt.Sprintf("%s/models/%s:generateContent", base, url.PathEscape(model))
 }
 
 type geminiRequest struct {
@@ -202,9 +177,31 @@ type geminiResponse struct {
 		} `json:"content"`
 		FinishReason string `json:"finishReason"`
 	} `json:"candidates"`
+	PromptFeedback struct {
+		BlockReason string `json:"blockReason"`
+	} `json:"promptFeedback"`
+	UsageMetadata struct {
+		PromptTokenCount     int `json:"promptTokenCount"`
+		CandidatesTokenCount int `json:"candidatesTokenCount"`
+	} `json:"usageMetadata"`
 }
 
-func (r geminiResponse) FirstText() string {
+func (r geminiResponse) usage() Usage {
+	return Usage{InputTokens: r.UsageMetadata.PromptTokenCount, OutputTokens: r.UsageMetadata.CandidatesTokenCount}
+}
+
+// refusal reports a prompt or candidate blocked by Gemini's safety filters.
+func (r geminiResponse) refusal() error {
+	if reason := strings.TrimSpace(r.PromptFeedback.BlockReason); reason != "" {
+		return &RefusalError{Provider: "Gemini", Reason: strings.ToLower(reason)}
+	}
+	if len(r.Candidates) == 0 {
+		return nil
+	}
+	return detectRefusal("Gemini", r.Candidates[0].FinishReason, r.text())
+}
+
+func (r geminiResponse) text() string {
 	for _, cand := range r.Candidates {
 		for _, part := range cand.Content.Parts {
 			if strings.TrimSpace(part.Text) != "" {
@@ -215,77 +212,21 @@ func (r geminiResponse) FirstText() string {
 	return ""
 }
 
-func logGeminiRequest(endpoint string, payload geminiRequest, pageNumber int) {
-	body, _ := json.MarshalIndent(maskGeminiPayload(payload), "", "  ")
-	log.Printf("[Gemini] %s请求信息:\n  URL: %s\n  Headers: Content-Type=application/json, x-goog-api-key=***\n  Body:\n%s", formatPagePrefix(pageNumber), endpoint, string(body))
-}
-
-func logGeminiResponse(resp geminiResponse, pageNumber int) {
-	data, _ := json.MarshalIndent(resp, "", "  ")
-	log.Printf("[Gemini] %s响应信息:\n%s", formatPagePrefix(pageNumber), string(data))
-}
-
-func logGeminiError(err error, pageNumber int) {
-	log.Printf("[Gemini] %s请求失败: %v", formatPagePrefix(pageNumber), err)
-}
-
-func logGeminiHTTPError(status int, body []byte, pageNumber int) {
-	if pretty := formatJSONBody(body); pretty != "" {
-		log.Printf("[Gemini] %sHTTP %d:\n%s", formatPagePrefix(pageNumber), status, pretty)
-	} else {
-		log.Printf("[Gemini] %sHTTP %d: %s", formatPagePrefix(pageNumber), status, string(body))
-	}
-}
-
-func detectImageMIME(data []byte) string {
-	if len(data) == 0 {
-		return "image/png"
-	}
-	mime := http.DetectContentType(data)
-	if strings.HasPrefix(mime, "image/") {
-		return mime
-	}
-	return "image/png"
-}
-
-// readAllLimited prevents log bloat for large error bodies.
-func readAllLimited(r io.Reader, limit int64) ([]byte, error) {
-	var buf bytes.Buffer
-	if limit <= 0 {
-		limit = 1 << 20
-	}
-	limited := io.LimitReader(r, limit)
-	_, err := buf.ReadFrom(limited)
-	return buf.Bytes(), err
-}
-
-func formatJSONBody(body []byte) string {
-	var raw interface{}
-	if err := json.Unmarshal(body, &raw); err != nil {
-		return ""
-	}
-	pretty, err := json.MarshalIndent(raw, "", "  ")
-	if err != nil {
-		return ""
-	}
-	return string(pretty)
-}
-
 func maskGeminiPayload(payload geminiRequest) geminiRequest {
 	masked := payload
-	for i := range masked.Contents {
-		for j := range masked.Contents[i].Parts {
-			if masked.Contents[i].Parts[j].InlineData != nil {
-				masked.Contents[i].Parts[j].InlineData.Data = "<image base64 omitted>"
-			}
-		}
-	}
-	if masked.SystemInstruction != nil {
-		for j := range masked.SystemInstruction.Parts {
-			if masked.SystemInstruction.Parts[j].InlineData != nil {
-				masked.SystemInstruction.Parts[j].InlineData.Data = "<image base64 omitted>"
+	masked.Contents = make([]geminiContent, len(payload.Contents))
+	for i, content := range payload.Contents {
+		parts := make([]geminiPart, len(content.Parts))
+		for j, part := range content.Parts {
+			if part.InlineData != nil {
+				inline := *part.InlineData
+				inline.Data = maskBase64(inline.Data)
+				part.InlineData = &inline
 			}
+			parts[j] = part
 		}
+		content.Parts = parts
+		masked.Contents[i] = content
 	}
 	return masked
 }
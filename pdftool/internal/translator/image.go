@@ -0,0 +1,113 @@
+package translator
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	_ "golang.org/x/image/bmp"
+	_ "golang.org/x/image/tiff"
+	_ "golang.org/x/image/webp"
+)
+
+// Image types each provider accepts by default. Page images in any other
+// format are converted to PNG before they are sent.
+var (
+	openAIImageTypes    = []string{"image/png", "image/jpeg", "image/gif", "image/webp"}
+	geminiImageTypes    = []string{"image/png", "image/jpeg", "image/webp", "image/heic", "image/heif"}
+	anthropicImageTypes = []string{"image/png", "image/jpeg", "image/gif"}
+	ollamaImageTypes    = []string{"image/png", "image/jpeg"}
+)
+
+// pageImage is a page image ready to be attached to a request.
+type pageImage struct {
+	Data []byte
+	MIME string
+}
+
+// loadPageImage reads the image at path and sniffs its type. Types missing
+// from accepted are re-encoded as PNG; files that are not a recognizable
+// image are rejected instead of being sent under a guessed type.
+func loadPageImage(path string, accepted []string) (pageImage, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return pageImage{}, fmt.Errorf("读取图片失败: %w", err)
+	}
+	mime := sniffImageMIME(data)
+	if mime == "" {
+		return pageImage{}, fmt.Errorf("无法识别的图片格式: %s", filepath.Base(path))
+	}
+	for _, typ := range accepted {
+		if typ == mime {
+			return pageImage{Data: data, MIME: mime}, nil
+		}
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return pageImage{}, fmt.Errorf("图片格式 %s 不受支持且无法转换: %w", mime, err)
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return pageImage{}, fmt.Errorf("转换图片失败: %w", err)
+	}
+	log.Printf("[图片] %s 为 %s，已转换为 image/png 后发送", filepath.Base(path), mime)
+	return pageImage{Data: buf.Bytes(), MIME: "image/png"}, nil
+}
+
+// sniffImageMIME returns the image type of data, or "" if it is not an image.
+// http.DetectContentType covers the common formats; TIFF and HEIF are
+// recognized from their headers.
+func sniffImageMIME(data []byte) string {
+	if len(data) == 0 {
+		return ""
+	}
+	if mime := http.DetectContentType(data); strings.HasPrefix(mime, "image/") {
+		return mime
+	}
+	switch {
+	case bytes.HasPrefix(data, []byte("II*\x00")), bytes.HasPrefix(data, []byte("MM\x00*")):
+		return "image/tiff"
+	case len(data) >= 12 && string(data[4:8]) == "ftyp":
+		switch string(data[8:12]) {
+		case "heic", "heix", "heim", "heis":
+			return "image/heic"
+		case "mif1", "msf1":
+			return "image/heif"
+		case "avif":
+			return "image/avif"
+		}
+	}
+	return ""
+}
+
+// imageTypes returns the configured accepted types, or the provider defaults.
+func imageTypes(cfg ProviderConfig, defaults []string) []string {
+	if len(cfg.ImageTypes) == 0 {
+		return defaults
+	}
+	return NormalizeImageTypes(cfg.ImageTypes)
+}
+
+// NormalizeImageTypes lower-cases MIME types, maps image/jpg to image/jpeg
+// and drops empty entries.
+func NormalizeImageTypes(types []string) []string {
+	normalized := make([]string, 0, len(types))
+	for _, typ := range types {
+		typ = strings.ToLower(strings.TrimSpace(typ))
+		if typ == "image/jpg" {
+			typ = "image/jpeg"
+		}
+		if typ != "" {
+			normalized = append(normalized, typ)
+		}
+	}
+	return normalized
+}
@@ -0,0 +1,71 @@
+package translator
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/image/bmp"
+)
+
+func TestLoadPageImage(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 4, 4))
+	img.SetGray(1, 1, color.Gray{Y: 200})
+	dir := t.TempDir()
+	write := func(name string, encode func(*bytes.Buffer) error) string {
+		var buf bytes.Buffer
+		if err := encode(&buf); err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	pngPath := write("page.png", func(b *bytes.Buffer) error { return png.Encode(b, img) })
+	gifPath := write("page.gif", func(b *bytes.Buffer) error { return gif.Encode(b, img, nil) })
+	bmpPath := write("page.bmp", func(b *bytes.Buffer) error { return bmp.Encode(b, img) })
+	textPath := write("page.png.txt", func(b *bytes.Buffer) error { _, err := b.WriteString("not an image"); return err })
+
+	cases := []struct {
+		name     string
+		path     string
+		accepted []string
+		wantMIME string
+		wantErr  bool
+	}{
+		{name: "png passes through", path: pngPath, accepted: anthropicImageTypes, wantMIME: "image/png"},
+		{name: "gif passes through", path: gifPath, accepted: openAIImageTypes, wantMIME: "image/gif"},
+		{name: "gif converted when not accepted", path: gifPath, accepted: geminiImageTypes, wantMIME: "image/png"},
+		{name: "bmp converted", path: bmpPath, accepted: anthropicImageTypes, wantMIME: "image/png"},
+		{name: "configured types", path: gifPath, accepted: NormalizeImageTypes([]string{" Image/GIF "}), wantMIME: "image/gif"},
+		{name: "not an image", path: textPath, accepted: openAIImageTypes, wantErr: true},
+		{name: "missing file", path: filepath.Join(dir, "missing.png"), accepted: openAIImageTypes, wantErr: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := loadPageImage(tc.path, tc.accepted)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("got %s, want error", got.MIME)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.MIME != tc.wantMIME {
+				t.Errorf("MIME = %s, want %s", got.MIME, tc.wantMIME)
+			}
+			if mime := sniffImageMIME(got.Data); mime != got.MIME {
+				t.Errorf("data is %s but sent as %s", mime, got.MIME)
+			}
+		})
+	}
+}
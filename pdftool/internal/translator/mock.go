@@ -0,0 +1,114 @@
+package translator
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// ProviderTypeMock answers without network access or API keys: page text and
+// translations are derived from the page number, or read from fixture files
+// when ProviderConfig.FixtureDir is set. It lets demos, integration tests and
+// offline installs run the whole pipeline.
+const ProviderTypeMock ProviderType = "mock"
+
+func init() {
+	Register(string(ProviderTypeMock), Factory{Translator: newMockTranslator, Formatter: newMockFormatter})
+}
+
+// mockTranslator looks for page-<n>.txt (recognized text) and
+// page-<n>.translated.txt (translation) in fixtureDir; a missing file falls
+// back to the generated text.
+type mockTranslator struct {
+	fixtureDir string
+}
+
+func newMockTranslator(cfg ProviderConfig) (Translator, error) {
+	return &mockTranslator{fixtureDir: strings.TrimSpace(cfg.FixtureDir)}, nil
+}
+
+func (t *mockTranslator) Translate(ctx context.Context, imagePath string) (Result, error) {
+	pageNumber := pageNumberFromContext(ctx)
+	source, ok := t.fixture(fmt.Sprintf("page-%d.txt", pageNumber))
+	if !ok {
+		source = fmt.Sprintf("Mock text of page %d (%s).", pageNumber, filepath.Base(imagePath))
+	}
+	if strings.TrimSpace(source) == "" {
+		return Result{HasText: false}, nil
+	}
+	result, err := t.TranslateText(ctx, source)
+	if err == nil && blocksRequested(ctx) {
+		result.Blocks = mockBlocks(result.SourceText, result.TranslatedText)
+	}
+	return result, err
+}
+
+// mockBlocks stacks one block per translated paragraph down the page.
+func mockBlocks(source, translated string) []Block {
+	sources := strings.Split(strings.TrimSpace(source), "\n\n")
+	paragraphs := strings.Split(translated, "\n\n")
+	height := float64(BlockScale-200) / float64(len(paragraphs))
+	blocks := make([]Block, 0, len(paragraphs))
+	for i, paragraph := range paragraphs {
+		block := Block{Translation: strings.TrimSpace(paragraph)}
+		if len(sources) == len(paragraphs) {
+			block.Text = strings.TrimSpace(sources[i])
+		}
+		top := 100 + height*float64(i)
+		block.BBox = [4]float64{100, top, BlockScale - 100, top + height*0.8}
+		blocks = append(blocks, block)
+	}
+	return cleanBlocks(blocks)
+}
+
+func (t *mockTranslator) TranslateText(ctx context.Context, sourceText string) (Result, error) {
+	pageNumber := pageNumberFromContext(ctx)
+	translated, ok := t.fixture(fmt.Sprintf("page-%d.translated.txt", pageNumber))
+	if !ok {
+		translated = fmt.Sprintf("【模拟译文 第 %d 页】%s", pageNumber, strings.TrimSpace(sourceText))
+	}
+	reportUsage(ctx, mockUsage(sourceText, translated))
+	return textResult(sourceText, translated)
+}
+
+func (t *mockTranslator) fixture(name string) (string, bool) {
+	if t.fixtureDir == "" {
+		return "", false
+	}
+	data, err := os.ReadFile(filepath.Join(t.fixtureDir, name))
+	if err != nil {
+		return "", false
+	}
+	return string(data), true
+}
+
+// mockFormatter returns each chunk unchanged, or format-<n>.txt from the
+// fixture directory for the n-th chunk.
+type mockFormatter struct {
+	mockTranslator
+}
+
+func newMockFormatter(cfg ProviderConfig) (TextFormatter, error) {
+	return &mockFormatter{mockTranslator{fixtureDir: strings.TrimSpace(cfg.FixtureDir)}}, nil
+}
+
+func (f *mockFormatter) Format(ctx context.Context, chunk FormatterChunk, chunkIndex int) (string, error) {
+	formatted, ok := f.fixture(fmt.Sprintf("format-%d.txt", chunkIndex))
+	if !ok {
+		formatted = string(chunk.Data)
+	}
+	reportUsage(ctx, mockUsage(string(chunk.Data), formatted))
+	return strings.TrimSpace(formatted), nil
+}
+
+// mockUsage reports roughly one token per four characters so budgets and
+// quotas move the same way they would with a real provider.
+func mockUsage(input, output string) Usage {
+	return Usage{
+		InputTokens:  utf8.RuneCountInString(input)/4 + 1,
+		OutputTokens: utf8.RuneCountInString(output)/4 + 1,
+	}
+}
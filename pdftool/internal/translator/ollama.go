@@ -0,0 +1,207 @@
+package translator
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Ollama serves local models, so there is no API key by default and
+// generation is much slower than with hosted providers.
+const (
+	defaultOllamaBase    = "http://localhost:11434"
+	defaultOllamaTimeout = 600 * time.Second
+)
+
+// ollamaClient talks to the Ollama /api/chat endpoint.
+type ollamaClient struct {
+	httpClient *http.Client
+	endpoint   string
+	apiKey     string
+	model      string
+	maxTokens  int
+}
+
+func newOllamaClient(cfg ProviderConfig) (ollamaClient, error) {
+	if strings.TrimSpace(cfg.Model) == "" {
+		return ollamaClient{}, fmt.Errorf("Ollama 模型未配置")
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = defaultOllamaTimeout
+	}
+	return ollamaClient{
+		httpClient: newHTTPClient(cfg),
+		endpoint:   ollamaEndpoint(cfg.BaseURL),
+		apiKey:     strings.TrimSpace(cfg.APIKey),
+		model:      cfg.Model,
+		maxTokens:  SanitizeMaxTokens(cfg.MaxTokens),
+	}, nil
+}
+
+// chat sends one non-streaming request with a system prompt and one user
+// message and returns the answer.
+func (c ollamaClient) chat(ctx context.Context, provider, label, system string, user ollamaMessage) (string, error) {
+	payload := ollamaRequest{
+		Model: c.model,
+		Messages: []ollamaMessage{
+			{Role: "system", Content: system},
+			user,
+		},
+		Options: ollamaOptions{Temperature: 0.1, NumPredict: c.maxTokens},
+	}
+	var header http.Header
+	if c.apiKey != "" {
+		header = http.Header{"Authorization": {"Bearer " + c.apiKey}}
+	}
+	return send(ctx, c.httpClient, apiCall{
+		provider: provider,
+		label:    label,
+		url:      c.endpoint,
+		header:   header,
+		body:     payload,
+		masked:   maskOllamaPayload(payload),
+	}, &ollamaResponse{})
+}
+
+type ollamaTranslator struct {
+	ollamaClient
+	prompts    pagePrompts
+	imageTypes []string
+}
+
+func newOllamaTranslator(cfg ProviderConfig) (Translator, error) {
+	client, err := newOllamaClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &ollamaTranslator{
+		ollamaClient: client,
+		prompts:      newPagePrompts(cfg),
+		imageTypes:   imageTypes(cfg, ollamaImageTypes),
+	}, nil
+}
+
+func (t *ollamaTranslator) Translate(ctx context.Context, imagePath string) (Result, error) {
+	img, err := loadPageImage(imagePath, t.imageTypes)
+	if err != nil {
+		return Result{}, err
+	}
+	text, err := t.chat(ctx, "Ollama", pageLabel(ctx), t.prompts.system, ollamaMessage{
+		Role:    "user",
+		Content: t.prompts.page(ctx),
+		Images:  []string{base64.StdEncoding.EncodeToString(img.Data)},
+	})
+	if err != nil {
+		return Result{}, err
+	}
+	return pageResult("Ollama", text)
+}
+
+func (t *ollamaTranslator) TranslateText(ctx context.Context, sourceText string) (Result, error) {
+	text, err := t.chat(ctx, "Ollama", pageLabel(ctx), t.prompts.text, ollamaMessage{Role: "user", Content: sourceText})
+	if err != nil {
+		return Result{}, err
+	}
+	return textResult(sourceText, text)
+}
+
+// ollamaFormatter sends text chunks inline in the prompt, since /api/chat
+// only takes image attachments.
+type ollamaFormatter struct {
+	ollamaClient
+	systemPrompt string
+}
+
+func newOllamaFormatter(cfg ProviderConfig) (TextFormatter, error) {
+	client, err := newOllamaClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &ollamaFormatter{ollamaClient: client, systemPrompt: cfg.prompts().formatterSystem()}, nil
+}
+
+func (f *ollamaFormatter) Format(ctx context.Context, chunk FormatterChunk, chunkIndex int) (string, error) {
+	user := ollamaMessage{Role: "user", Content: formatterPrompt(chunk, true)}
+	if strings.HasPrefix(chunk.MimeType, "image/") {
+		user.Images = []string{base64.StdEncoding.EncodeToString(chunk.Data)}
+	}
+	return f.chat(ctx, "Ollama Formatter", chunkLabel(chunkIndex), f.systemPrompt, user)
+}
+
+// ollamaEndpoint accepts the server root, the /api prefix, or the full chat
+// endpoint.
+func ollamaEndpoint(base string) string {
+	base = strings.TrimRight(strings.TrimSpace(base), "/")
+	if base == "" {
+		base = defaultOllamaBase
+	}
+	switch {
+	case strings.HasSuffix(base, "/api/chat"):
+		return base
+	case strings.HasSuffix(base, "/api"):
+		return base + "/chat"
+	}
+	return base + "/api/chat"
+}
+
+type ollamaRequest struct {
+	Model    string          `json:"model"`
+	Messages []ollamaMessage `json:"messages"`
+	Stream   bool            `json:"stream"`
+	Options  ollamaOptions   `json:"options"`
+}
+
+type ollamaMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+	// Images holds base64-encoded images without a data: prefix.
+	Images []string `json:"images,omitempty"`
+}
+
+type ollamaOptions struct {
+	Temperature float64 `json:"temperature"`
+	NumPredict  int     `json:"num_predict,omitempty"`
+}
+
+type ollamaResponse struct {
+	Model   string `json:"model"`
+	Message struct {
+		Role    string `json:"role"`
+		Content string `json:"content"`
+	} `json:"message"`
+	Done            bool   `json:"done"`
+	DoneReason      string `json:"done_reason"`
+	PromptEvalCount int    `json:"prompt_eval_count"`
+	EvalCount       int    `json:"eval_count"`
+}
+
+func (r ollamaResponse) usage() Usage {
+	return Usage{InputTokens: r.PromptEvalCount, OutputTokens: r.EvalCount}
+}
+
+func (r ollamaResponse) text() string {
+	return r.Message.Content
+}
+
+func (r ollamaResponse) refusal() error {
+	return detectRefusal("Ollama", r.DoneReason, r.Message.Content)
+}
+
+func maskOllamaPayload(payload ollamaRequest) ollamaRequest {
+	masked := payload
+	masked.Messages = make([]ollamaMessage, len(payload.Messages))
+	for i, msg := range payload.Messages {
+		if len(msg.Images) > 0 {
+			images := make([]string, len(msg.Images))
+			for j, data := range msg.Images {
+				images[j] = maskBase64(data)
+			}
+			msg.Images = images
+		}
+		masked.Messages[i] = msg
+	}
+	return masked
+}
@@ -1,17 +1,10 @@
 package translator
 
 import (
-	"bytes"
 	"context"
 	"encoding/base64"
-	"encoding/json"
-	"errors"
 	"fmt"
-	"io"
-	"log"
 	"net/http"
-	"net/url"
-	"os"
 	"strings"
 	"time"
 )
@@ -21,6 +14,13 @@ type Result struct {
 	HasText        bool
 	SourceText     string
 	TranslatedText string
+	Footnotes      []Footnote
+	// Elements are the titles, headers and captions the model listed
+	// separately; nil when it returned none.
+	Elements *Elements
+	// Blocks are the page's text blocks with their positions, when the
+	// request asked for them (see WithBlocks).
+	Blocks []Block
 }
 
 // Translator describes the behavior needed by the service layer.
@@ -28,146 +28,132 @@ type Translator interface {
 	Translate(ctx context.Context, imagePath string) (Result, error)
 }
 
-type openAITranslator struct {
-	httpClient     *http.Client
-	baseURL        string
-	apiKey         string
-	model          string
-	timeout        time.Duration
-	systemPrompt   string
-	userPrompt     string
-	maxTokens      int
-	optimizeLayout bool
-}
-
 const defaultOpenAIBase = "https://api.openai.com/v1"
 
-func newOpenAITranslator(cfg ProviderConfig) (Translator, error) {
+// openAIClient talks to an OpenAI-compatible chat completions endpoint.
+type openAIClient struct {
+	httpClient *http.Client
+	endpoint   string
+	apiKey     string
+	model      string
+	maxTokens  int
+}
+
+func newOpenAIClient(cfg ProviderConfig, timeout time.Duration) (openAIClient, error) {
 	if strings.TrimSpace(cfg.APIKey) == "" {
-		return nil, fmt.Errorf("OPENAI_API_KEY 未配置")
+		return openAIClient{}, fmt.Errorf("OPENAI_API_KEY 未配置")
 	}
 	if strings.TrimSpace(cfg.Model) == "" {
-		return nil, fmt.Errorf("OPENAI_MODEL 未配置")
+		return openAIClient{}, fmt.Errorf("OPENAI_MODEL 未配置")
 	}
 	if cfg.Timeout == 0 {
-		cfg.Timeout = 90 * time.Second
-	}
-	baseURL := strings.TrimRight(cfg.BaseURL, "/")
-	if baseURL == "" {
-		baseURL = defaultOpenAIBase
+		cfg.Timeout = timeout
 	}
-
-	return &openAITranslator{
-		httpClient:     &http.Client{Timeout: cfg.Timeout},
-		baseURL:        baseURL,
-		apiKey:         strings.TrimSpace(cfg.APIKey),
-		model:          cfg.Model,
-		timeout:        cfg.Timeout,
-		maxTokens:      SanitizeMaxTokens(cfg.MaxTokens),
-		systemPrompt:   "你是一个专业的OCR与翻译助手。阅读用户提供的图片，先识别出存在的文本，再将其翻译为简体中文。必须输出严格的JSON对象，格式为 {\"hasText\":bool,\"sourceText\":\"原始文本\",\"translatedText\":\"翻译后的文本\"} 。如果图片中没有文本，设置 hasText 为 false，另外两个字段留空字符串。",
-		userPrompt:     "请识别这页图像中的所有可见文本并翻译成简体中文。保持原本的段落顺序，返回JSON字符串。",
-		optimizeLayout: cfg.OptimizeLayout,
+	return openAIClient{
+		httpClient: newHTTPClient(cfg),
+		endpoint:   openAIEndpoint(cfg.BaseURL),
+		apiKey:     strings.TrimSpace(cfg.APIKey),
+		model:      cfg.Model,
+		maxTokens:  SanitizeMaxTokens(cfg.MaxTokens),
 	}, nil
 }
 
-func (t *openAITranslator) Translate(ctx context.Context, imagePath string) (Result, error) {
-	pageNumber := pageNumberFromContext(ctx)
-	data, err := os.ReadFile(imagePath)
-	if err != nil {
-		return Result{}, fmt.Errorf("读取图片失败: %w", err)
-	}
-
-	content := fmt.Sprintf("data:%s;base64,%s", detectImageMIME(data), base64.StdEncoding.EncodeToString(data))
-	userPrompt := t.userPrompt
-	if t.optimizeLayout {
-		userPrompt = userPrompt + " 请在 sourceText 与 translatedText 字段中保持原文的结构与排版，保留标题、列表和空行，使译文更整洁易读。"
-	}
-
+// chat sends a system prompt and one user message and returns the answer.
+func (c openAIClient) chat(ctx context.Context, provider, label, system string, user interface{}) (string, error) {
 	payload := openAIChatRequest{
-		Model:       t.model,
-		MaxTokens:   t.maxTokens,
+		Model:       c.model,
+		MaxTokens:   c.maxTokens,
 		Temperature: 0.1,
 		TopP:        0.95,
 		Messages: []openAIMessage{
-			{
-				Role:    "system",
-				Content: t.systemPrompt,
-			},
-			{
-				Role: "user",
-				Content: []openAIMessagePart{
-					{Type: "text", Text: userPrompt},
-					{
-						Type: "image_url",
-						ImageURL: &openAIImageURL{
-							URL: content,
-						},
-					},
-				},
-			},
+			{Role: "system", Content: system},
+			{Role: "user", Content: user},
 		},
 	}
+	return send(ctx, c.httpClient, apiCall{
+		provider: provider,
+		label:    label,
+		url:      c.endpoint,
+		header:   http.Header{"Authorization": {"Bearer " + c.apiKey}},
+		body:     payload,
+		masked:   maskOpenAIPayload(payload),
+	}, &openAIChatResponse{})
+}
 
-	logOpenAIRequest(t.baseURL, payload, pageNumber)
+type openAITranslator struct {
+	openAIClient
+	prompts    pagePrompts
+	imageTypes []string
+}
 
-	reqCtx, cancel := context.WithTimeout(ctx, t.timeout)
-	defer cancel()
+func newOpenAITranslator(cfg ProviderConfig) (Translator, error) {
+	client, err := newOpenAIClient(cfg, 90*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	return &openAITranslator{
+		openAIClient: client,
+		prompts:      newPagePrompts(cfg),
+		imageTypes:   imageTypes(cfg, openAIImageTypes),
+	}, nil
+}
 
-	body, _ := json.Marshal(payload)
-	req, err := http.NewRequestWithContext(reqCtx, http.MethodPost, t.chatEndpoint(), bytes.NewReader(body))
+func (t *openAITranslator) Translate(ctx context.Context, imagePath string) (Result, error) {
+	img, err := loadPageImage(imagePath, t.imageTypes)
 	if err != nil {
 		return Result{}, err
 	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+t.apiKey)
-
-	resp, err := t.httpClient.Do(req)
+	text, err := t.chat(ctx, "OpenAI", pageLabel(ctx), t.prompts.system, []openAIMessagePart{
+		{Type: "text", Text: t.prompts.page(ctx)},
+		{
+			Type: "image_url",
+			ImageURL: &openAIImageURL{
+				URL: fmt.Sprintf("data:%s;base64,%s", img.MIME, base64.StdEncoding.EncodeToString(img.Data)),
+			},
+		},
+	})
 	if err != nil {
-		logOpenAIError(err, pageNumber)
-		return Result{}, fmt.Errorf("调用OpenAI失败: %w", err)
+		return Result{}, err
 	}
-	defer resp.Body.Close()
+	return pageResult("OpenAI", text)
+}
 
-	if resp.StatusCode >= 400 {
-		data, _ := readAllLimitedBytes(resp.Body, 1<<20)
-		logOpenAIHTTPError(resp.StatusCode, data, pageNumber)
-		return Result{}, fmt.Errorf("OpenAI 响应错误: %s", resp.Status)
+func (t *openAITranslator) TranslateText(ctx context.Context, sourceText string) (Result, error) {
+	text, err := t.chat(ctx, "OpenAI", pageLabel(ctx), t.prompts.text, sourceText)
+	if err != nil {
+		return Result{}, err
 	}
+	return textResult(sourceText, text)
+}
 
-	var parsed openAIChatResponse
-	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
-		return Result{}, fmt.Errorf("解析OpenAI响应失败: %w", err)
-	}
+// openAIFormatter sends text chunks inline in the prompt.
+type openAIFormatter struct {
+	openAIClient
+	systemPrompt string
+}
 
-	if len(parsed.Choices) == 0 {
-		return Result{}, fmt.Errorf("OpenAI 返回为空")
+func newOpenAIFormatter(cfg ProviderConfig) (TextFormatter, error) {
+	client, err := newOpenAIClient(cfg, 300*time.Second)
+	if err != nil {
+		return nil, err
 	}
+	return &openAIFormatter{openAIClient: client, systemPrompt: cfg.prompts().formatterSystem()}, nil
+}
 
-	logOpenAIResponse(parsed, pageNumber)
-
-	raw := strings.TrimSpace(parsed.Choices[0].Message.Content)
-	clean := cleanJSON(raw)
-
-	var resultPayload struct {
-		HasText        bool   `json:"hasText"`
-		SourceText     string `json:"sourceText"`
-		TranslatedText string `json:"translatedText"`
-	}
-	if err := json.Unmarshal([]byte(clean), &resultPayload); err != nil {
-		return Result{}, fmt.Errorf("解析OpenAI响应失败: %w", err)
-	}
-	return Result{
-		HasText:        resultPayload.HasText,
-		SourceText:     resultPayload.SourceText,
-		TranslatedText: resultPayload.TranslatedText,
-	}, nil
+func (f *openAIFormatter) Format(ctx context.Context, chunk FormatterChunk, chunkIndex int) (string, error) {
+	return f.chat(ctx, "OpenAI Formatter", chunkLabel(chunkIndex), f.systemPrompt, formatterPrompt(chunk, true))
 }
 
-func (t *openAITranslator) chatEndpoint() string {
-	if strings.HasSuffix(t.baseURL, "/chat/completions") {
-		return t.baseURL
+// openAIEndpoint accepts the API root or the full chat completions endpoint.
+func openAIEndpoint(base string) string {
+	base = strings.TrimRight(strings.TrimSpace(base), "/")
+	if base == "" {
+		base = defaultOpenAIBase
+	}
+	if strings.HasSuffix(base, "/chat/completions") {
+		return base
 	}
-	return strings.TrimRight(t.baseURL, "/") + "/chat/completions"
+	return base + "/chat/completions"
 }
 
 type openAIChatRequest struct {
@@ -202,60 +188,36 @@ type openAIChatResponse struct {
 		FinishReason string `json:"finish_reason"`
 		Message      struct {
 			Content string `json:"content"`
+			Refusal string `json:"refusal"`
 		} `json:"message"`
 	} `json:"choices"`
+	Usage struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+	} `json:"usage"`
 }
 
-func logOpenAIRequest(baseURL string, payload openAIChatRequest, pageNumber int) {
-	body, _ := json.MarshalIndent(maskOpenAIPayload(payload), "", "  ")
-	log.Printf("[OpenAI] %s请求信息:\n  URL: %s/chat/completions\n  Headers: Content-Type=application/json, Authorization=Bearer ***\n  Body:\n%s", formatPagePrefix(pageNumber), baseURL, string(body))
-}
-
-func logOpenAIResponse(resp openAIChatResponse, pageNumber int) {
-	info := struct {
-		ID      string `json:"id"`
-		Model   string `json:"model"`
-		Choices []struct {
-			Index   int    `json:"index"`
-			Status  string `json:"status"`
-			Content string `json:"content"`
-		} `json:"choices"`
-	}{
-		ID:    resp.ID,
-		Model: resp.Model,
-	}
-	for _, choice := range resp.Choices {
-		info.Choices = append(info.Choices, struct {
-			Index   int    `json:"index"`
-			Status  string `json:"status"`
-			Content string `json:"content"`
-		}{
-			Index:   choice.Index,
-			Status:  choice.FinishReason,
-			Content: strings.TrimSpace(choice.Message.Content),
-		})
-	}
-	data, _ := json.MarshalIndent(info, "", "  ")
-	log.Printf("[OpenAI] %s响应信息:\n%s", formatPagePrefix(pageNumber), string(data))
+func (r openAIChatResponse) usage() Usage {
+	return Usage{InputTokens: r.Usage.PromptTokens, OutputTokens: r.Usage.CompletionTokens}
 }
 
-func logOpenAIHTTPError(status int, body []byte, pageNumber int) {
-	if pretty := formatJSON(body); pretty != "" {
-		log.Printf("[OpenAI] %sHTTP %d:\n%s", formatPagePrefix(pageNumber), status, pretty)
-		return
+func (r openAIChatResponse) text() string {
+	if len(r.Choices) == 0 {
+		return ""
 	}
-	log.Printf("[OpenAI] %sHTTP %d: %s", formatPagePrefix(pageNumber), status, string(body))
+	return r.Choices[0].Message.Content
 }
 
-func logOpenAIError(err error, pageNumber int) {
-	if err == nil {
-		return
+// refusal reports a content-filtered or refused first choice.
+func (r openAIChatResponse) refusal() error {
+	if len(r.Choices) == 0 {
+		return nil
 	}
-	var urlErr *url.Error
-	if errors.As(err, &urlErr) {
-		log.Printf("[OpenAI] %s底层网络错误: %v", formatPagePrefix(pageNumber), urlErr)
+	choice := r.Choices[0]
+	if reason := strings.TrimSpace(choice.Message.Refusal); reason != "" {
+		return &RefusalError{Provider: "OpenAI", Reason: reason}
 	}
-	log.Printf("[OpenAI] %s请求失败: %v", formatPagePrefix(pageNumber), err)
+	return detectRefusal("OpenAI", choice.FinishReason, choice.Message.Content)
 }
 
 func maskOpenAIPayload(payload openAIChatRequest) openAIChatRequest {
@@ -304,42 +266,3 @@ func maskDataURI(raw string) string {
 	}
 	return fmt.Sprintf("%s,%s***%s", header, head, tail)
 }
-
-func readAllLimitedBytes(r io.Reader, limit int64) ([]byte, error) {
-	var buf bytes.Buffer
-	if limit <= 0 {
-		limit = 1 << 20
-	}
-	limited := io.LimitReader(r, limit)
-	_, err := buf.ReadFrom(limited)
-	return buf.Bytes(), err
-}
-
-func formatJSON(body []byte) string {
-	var raw interface{}
-	if err := json.Unmarshal(body, &raw); err != nil {
-		return ""
-	}
-	pretty, err := json.MarshalIndent(raw, "", "  ")
-	if err != nil {
-		return ""
-	}
-	return string(pretty)
-}
-
-func cleanJSON(input string) string {
-	input = strings.TrimSpace(input)
-	if strings.HasPrefix(input, "```") {
-		lines := strings.Split(input, "\n")
-		var body []string
-		for _, line := range lines {
-			lineTrim := strings.TrimSpace(line)
-			if strings.HasPrefix(lineTrim, "```") {
-				continue
-			}
-			body = append(body, line)
-		}
-		input = strings.Join(body, "\n")
-	}
-	return strings.TrimSpace(input)
-}
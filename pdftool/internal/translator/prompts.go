@@ -0,0 +1,76 @@
+package translator
+
+import "strings"
+
+// DefaultTargetLanguage is the translation target when none is configured.
+const DefaultTargetLanguage = "简体中文"
+
+// languagePlaceholder in a prompt, built-in or configured, is replaced with
+// the target language.
+const languagePlaceholder = "{language}"
+
+// Built-in prompts, used when no override is configured.
+const (
+	DefaultOCRSystemPrompt       = "你是一个专业的OCR与翻译助手。阅读用户提供的图片，先识别出存在的文本，再将其翻译为{language}。必须输出严格的JSON对象，格式为 {\"hasText\":bool,\"sourceText\":\"原始文本\",\"translatedText\":\"翻译后的文本\"} 。如果图片中没有文本，设置 hasText 为 false，另外两个字段留空字符串。"
+	DefaultOCRUserPrompt         = "请识别这页图像中的所有可见文本并翻译成{language}。保持原本的段落顺序，返回JSON字符串。"
+	DefaultTextSystemPrompt      = "你是一名专业翻译。将用户提供的文本翻译为{language}，保持原文的段落顺序、标题与列表结构，不要遗漏内容，也不要添加解释。只输出译文本身。"
+	DefaultFormatterSystemPrompt = "你是一名专业的{language}文字编辑，擅长将长篇文本排版得整洁易读。请保持原文语义并优化段落、标题与列表的结构，不得遗漏或删除任何内容，也不要加入原文没有的信息。"
+)
+
+// Prompts overrides the instructions sent to providers. Empty fields keep the
+// built-in prompts; Extra is appended to every system prompt. {language} is
+// replaced with the provider's target language.
+type Prompts struct {
+	OCRSystem       string
+	OCRUser         string
+	TextSystem      string
+	FormatterSystem string
+	Extra           string
+
+	language string
+}
+
+// prompts returns the configured prompts for the target language.
+func (c ProviderConfig) prompts() Prompts {
+	p := c.Prompts
+	p.language = c.TargetLanguage
+	return p
+}
+
+func (p Prompts) ocrSystem() string {
+	return p.system(p.OCRSystem, DefaultOCRSystemPrompt)
+}
+
+func (p Prompts) ocrUser() string {
+	if prompt := strings.TrimSpace(p.OCRUser); prompt != "" {
+		return p.expand(prompt)
+	}
+	return p.expand(DefaultOCRUserPrompt)
+}
+
+func (p Prompts) textSystem() string {
+	return p.system(p.TextSystem, DefaultTextSystemPrompt)
+}
+
+func (p Prompts) formatterSystem() string {
+	return p.system(p.FormatterSystem, DefaultFormatterSystemPrompt)
+}
+
+func (p Prompts) system(override, fallback string) string {
+	prompt := strings.TrimSpace(override)
+	if prompt == "" {
+		prompt = fallback
+	}
+	if extra := strings.TrimSpace(p.Extra); extra != "" {
+		prompt += "\n" + extra
+	}
+	return p.expand(prompt)
+}
+
+func (p Prompts) expand(prompt string) string {
+	language := strings.TrimSpace(p.language)
+	if language == "" {
+		language = DefaultTargetLanguage
+	}
+	return strings.ReplaceAll(prompt, languagePlaceholder, language)
+}
@@ -1,6 +1,8 @@
 package translator
 
 import (
+	"net"
+	"net/http"
 	"strings"
 	"time"
 )
@@ -12,46 +14,76 @@ const (
 	ProviderTypeOpenAI    ProviderType = "openai"
 	ProviderTypeGemini    ProviderType = "gemini"
 	ProviderTypeAnthropic ProviderType = "anthropic"
+	ProviderTypeOllama    ProviderType = "ollama"
 )
 
 // ProviderConfig describes runtime translator configuration.
 type ProviderConfig struct {
-	Type           ProviderType
-	BaseURL        string
-	APIKey         string
-	Model          string
-	Timeout        time.Duration
+	Type    ProviderType
+	BaseURL string
+	APIKey  string
+	Model   string
+	// Timeout bounds a single request, including waiting for the response.
+	Timeout time.Duration
+	// ConnectTimeout bounds dialing and the TLS handshake; zero uses Go's defaults.
+	ConnectTimeout time.Duration
 	MaxTokens      int
 	OptimizeLayout bool
+	// Prompts overrides the built-in prompts; the zero value keeps them all.
+	Prompts Prompts
+	// TargetLanguage is the language pages are translated into, written as
+	// the prompts should name it ("English", "日本語"); empty uses
+	// DefaultTargetLanguage.
+	TargetLanguage string
+	// FixtureDir is where the mock provider reads canned responses. It is
+	// server configuration only and never taken from API requests.
+	FixtureDir string
+	// ImageTypes lists the image MIME types the endpoint accepts; page
+	// images of other types are converted to PNG. Empty uses the provider's
+	// defaults.
+	ImageTypes []string
+}
+
+// RequiresCredentials reports whether the provider type needs an API key and
+// model ID to be usable. Local providers (mock and Ollama) work without a key;
+// Ollama still checks for a model when the client is built.
+func RequiresCredentials(provider ProviderType) bool {
+	return provider != ProviderTypeMock && provider != ProviderTypeOllama
+}
+
+// newHTTPClient applies the request timeout to the whole exchange and the
+// connect timeout to dialing only, so unreachable endpoints fail fast while
+// slow first-token models still get the full request timeout.
+func newHTTPClient(cfg ProviderConfig) *http.Client {
+	client := &http.Client{Timeout: cfg.Timeout}
+	if cfg.ConnectTimeout > 0 {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.DialContext = (&net.Dialer{Timeout: cfg.ConnectTimeout, KeepAlive: 30 * time.Second}).DialContext
+		transport.TLSHandshakeTimeout = cfg.ConnectTimeout
+		client.Transport = transport
+	}
+	return client
 }
 
 // OpenAIConfig is kept for backwards compatibility.
 type OpenAIConfig = ProviderConfig
 
-// NormalizeProviderType coerces user inputs to known types.
+// NormalizeProviderType coerces user inputs to registered types; unknown
+// values fall back to OpenAI-compatible.
 func NormalizeProviderType(value string) ProviderType {
-	switch strings.ToLower(strings.TrimSpace(value)) {
-	case "gemini":
-		return ProviderTypeGemini
-	case "anthropic":
-		return ProviderTypeAnthropic
-	default:
-		return ProviderTypeOpenAI
+	name := ProviderType(strings.ToLower(strings.TrimSpace(value)))
+	if _, ok := lookupFactory(name); ok {
+		return name
 	}
+	return ProviderTypeOpenAI
 }
 
 // NewTranslator builds a translator according to provider type.
 func NewTranslator(cfg ProviderConfig) (Translator, error) {
 	cfg.Type = NormalizeProviderType(string(cfg.Type))
 	cfg.MaxTokens = SanitizeMaxTokens(cfg.MaxTokens)
-	switch cfg.Type {
-	case ProviderTypeGemini:
-		return newGeminiTranslator(cfg)
-	case ProviderTypeAnthropic:
-		return newAnthropicTranslator(cfg)
-	default:
-		return newOpenAITranslator(cfg)
-	}
+	factory, _ := lookupFactory(cfg.Type)
+	return factory.Translator(cfg)
 }
 
 // NewOpenAITranslator keeps the old API available.
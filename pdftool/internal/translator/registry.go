@@ -0,0 +1,74 @@
+package translator
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Factory builds the clients of one provider type. Translators that also
+// implement TextTranslator can translate imported OCR text; a nil Formatter
+// means the provider does not support AI layout formatting.
+type Factory struct {
+	Translator func(cfg ProviderConfig) (Translator, error)
+	Formatter  func(cfg ProviderConfig) (TextFormatter, error)
+}
+
+var (
+	registryMu sync.RWMutex
+	factories  = make(map[ProviderType]Factory)
+)
+
+func init() {
+	Register(string(ProviderTypeOpenAI), Factory{Translator: newOpenAITranslator, Formatter: newOpenAIFormatter})
+	Register(string(ProviderTypeGemini), Factory{Translator: newGeminiTranslator, Formatter: newGeminiFormatter})
+	Register(string(ProviderTypeAnthropic), Factory{Translator: newAnthropicTranslator, Formatter: newAnthropicFormatter})
+	Register(string(ProviderTypeOllama), Factory{Translator: newOllamaTranslator, Formatter: newOllamaFormatter})
+}
+
+// Register makes a provider type available to NewTranslator and NewFormatter
+// under name (case-insensitive), so programs embedding this package can add
+// their own gateways. Like database/sql.Register it is meant to be called
+// from init and panics if name is empty, already registered, or factory has
+// no Translator.
+func Register(name string, factory Factory) {
+	key := ProviderType(strings.ToLower(strings.TrimSpace(name)))
+	if key == "" {
+		panic("translator: Register with empty provider name")
+	}
+	if factory.Translator == nil {
+		panic(fmt.Sprintf("translator: Register provider %q without Translator", key))
+	}
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, dup := factories[key]; dup {
+		panic(fmt.Sprintf("translator: Register called twice for provider %q", key))
+	}
+	factories[key] = factory
+}
+
+// Providers lists the registered provider types in sorted order.
+func Providers() []ProviderType {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]ProviderType, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+	return names
+}
+
+// Registered reports whether name (case-insensitive) is a registered provider type.
+func Registered(name string) bool {
+	_, ok := lookupFactory(ProviderType(strings.ToLower(strings.TrimSpace(name))))
+	return ok
+}
+
+func lookupFactory(name ProviderType) (Factory, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	factory, ok := factories[name]
+	return factory, ok
+}
@@ -0,0 +1,33 @@
+package translator
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// TextTranslator translates text that was recognized elsewhere (e.g. imported OCR).
+type TextTranslator interface {
+	TranslateText(ctx context.Context, sourceText string) (Result, error)
+}
+
+// NewTextTranslator builds a text-only translator for the provider type.
+func NewTextTranslator(cfg ProviderConfig) (TextTranslator, error) {
+	client, err := NewTranslator(cfg)
+	if err != nil {
+		return nil, err
+	}
+	textClient, ok := client.(TextTranslator)
+	if !ok {
+		return nil, fmt.Errorf("提供商 %s 不支持纯文本翻译", cfg.Type)
+	}
+	return textClient, nil
+}
+
+func textResult(sourceText, translated string) (Result, error) {
+	translated = strings.TrimSpace(translated)
+	if translated == "" {
+		return Result{}, fmt.Errorf("翻译结果为空")
+	}
+	return Result{HasText: true, SourceText: sourceText, TranslatedText: translated}, nil
+}
@@ -0,0 +1,39 @@
+package translator
+
+import "context"
+
+// Usage reports token consumption of a single provider call.
+type Usage struct {
+	InputTokens  int
+	OutputTokens int
+}
+
+// Total returns the sum of input and output tokens.
+func (u Usage) Total() int {
+	return u.InputTokens + u.OutputTokens
+}
+
+// UsageRecorder receives token usage after every successful provider call.
+type UsageRecorder func(Usage)
+
+const usageRecorderKey contextKey = "pdftool_translator_usage_recorder"
+
+// WithUsageRecorder attaches a recorder that providers notify about token usage.
+func WithUsageRecorder(ctx context.Context, recorder UsageRecorder) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if recorder == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, usageRecorderKey, recorder)
+}
+
+func reportUsage(ctx context.Context, usage Usage) {
+	if ctx == nil || usage.Total() <= 0 {
+		return
+	}
+	if recorder, ok := ctx.Value(usageRecorderKey).(UsageRecorder); ok {
+		recorder(usage)
+	}
+}
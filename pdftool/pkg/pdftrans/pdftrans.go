@@ -0,0 +1,315 @@
+// Package pdftrans exposes the PDF translation pipeline — rendering pages,
+// translating them with a vision model, merging the translations into TXT or
+// PDF and AI formatting — to Go programs that embed it without the HTTP
+// server. Every call takes a context; canceling it stops the work.
+package pdftrans
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"pdftool/internal/hooks"
+	"pdftool/internal/model"
+	"pdftool/internal/pdfutil"
+	"pdftool/internal/service"
+	"pdftool/internal/translator"
+)
+
+// Provider building blocks, re-exported so embedders can plug in their own
+// providers with Register.
+type (
+	ProviderConfig = translator.ProviderConfig
+	ProviderType   = translator.ProviderType
+	Factory        = translator.Factory
+	Translator     = translator.Translator
+	TextTranslator = translator.TextTranslator
+	TextFormatter  = translator.TextFormatter
+	Result         = translator.Result
+	RenderedPage   = pdfutil.RenderedPage
+	RenderOptions  = pdfutil.RenderOptions
+	Hooks          = hooks.Chain
+	HookInfo       = hooks.Info
+	HookText       = hooks.Text
+)
+
+// Built-in provider types.
+const (
+	ProviderOpenAI    = translator.ProviderTypeOpenAI
+	ProviderGemini    = translator.ProviderTypeGemini
+	ProviderAnthropic = translator.ProviderTypeAnthropic
+	// ProviderOllama talks to a local Ollama server; no API key is needed.
+	ProviderOllama = translator.ProviderTypeOllama
+	// ProviderMock returns canned text without network access; see
+	// translator.ProviderTypeMock.
+	ProviderMock = translator.ProviderTypeMock
+)
+
+// PDF layouts accepted by MergePDF.
+const (
+	LayoutText     = service.PDFLayoutText
+	LayoutFacing   = service.PDFLayoutFacing
+	LayoutStacked  = service.PDFLayoutStacked
+	LayoutAppendix = service.PDFLayoutAppendix
+	LayoutBlocks   = service.PDFLayoutBlocks
+)
+
+const defaultPollInterval = time.Second
+
+// Register adds a provider type; see translator.Register.
+func Register(name string, factory Factory) {
+	translator.Register(name, factory)
+}
+
+// NewHooks returns an empty hook chain for Config.Hooks.
+func NewHooks() *Hooks {
+	return hooks.New()
+}
+
+// RunWorker serves render requests of an isolated Engine; see Config.IsolateRendering.
+func RunWorker() {
+	pdfutil.RunWorker()
+}
+
+// Render converts every page of the PDF at pdfPath into a PNG in destDir.
+// Panics while rendering are returned as errors.
+func Render(ctx context.Context, pdfPath, destDir string) ([]RenderedPage, error) {
+	return pdfutil.Renderer{}.RenderPages(ctx, pdfPath, destDir, RenderOptions{})
+}
+
+// TranslateImage recognizes and translates the text of one page image.
+func TranslateImage(ctx context.Context, provider ProviderConfig, imagePath string) (Result, error) {
+	client, err := translator.NewTranslator(provider)
+	if err != nil {
+		return Result{}, err
+	}
+	return client.Translate(ctx, imagePath)
+}
+
+// Config configures an Engine.
+type Config struct {
+	// StorageDir holds each document's source, page images and exports.
+	StorageDir string
+	// Provider is the default provider; documents may override it.
+	Provider ProviderConfig
+	// MaxWorkers caps concurrent page requests per document.
+	MaxWorkers int
+	// FontPath is a TTF font for PDF exports; empty uses the bundled font.
+	FontPath string
+	// PollInterval is how often Wait checks progress; zero means one second.
+	PollInterval time.Duration
+	// Hooks transform the PDF, page images and page text around translation.
+	Hooks *Hooks
+	// IsolateRendering rasterizes PDFs in a worker subprocess so a file
+	// crashing MuPDF cannot take the host process down. The binary must
+	// call RunWorker first thing in main.
+	IsolateRendering bool
+	// Render sets the resolution, image format and size limit of page
+	// images; the zero value renders PNGs at 300 DPI.
+	Render RenderOptions
+	// Deterministic fixes export timestamps and derives document IDs from a
+	// sequence, so the same inputs give byte-identical exports.
+	Deterministic bool
+}
+
+// Engine runs whole documents through the pipeline. Documents are stored
+// under Config.StorageDir and can be reopened by ID.
+type Engine struct {
+	svc  *service.TaskService
+	poll time.Duration
+	stop context.CancelFunc
+}
+
+// New creates an Engine. Close it to stop its background retry scheduler.
+func New(cfg Config) (*Engine, error) {
+	if cfg.StorageDir == "" {
+		return nil, fmt.Errorf("pdftrans: StorageDir is required")
+	}
+	svc, err := service.NewTaskService(cfg.StorageDir, "", cfg.FontPath, cfg.Provider, cfg.MaxWorkers, service.Options{
+		Hooks:         cfg.Hooks,
+		Renderer:      pdfutil.Renderer{Isolated: cfg.IsolateRendering},
+		Render:        cfg.Render,
+		Deterministic: cfg.Deterministic,
+	})
+	if err != nil {
+		return nil, err
+	}
+	poll := cfg.PollInterval
+	if poll <= 0 {
+		poll = defaultPollInterval
+	}
+	ctx, stop := context.WithCancel(context.Background())
+	go svc.RunRetryScheduler(ctx)
+	return &Engine{svc: svc, poll: poll, stop: stop}, nil
+}
+
+// Close stops the engine's background work and closes its task store.
+// Documents keep their state on disk; close the engine only after the ones
+// still translating are done or canceled.
+func (e *Engine) Close() {
+	e.stop()
+	e.svc.Close()
+}
+
+// Options selects what to translate.
+type Options struct {
+	// Provider overrides fields of the engine's default provider.
+	Provider ProviderConfig
+	// Pages limits translation to a page list such as "1-3,7,20-"; empty
+	// translates every page.
+	Pages string
+	// LayoutMode is "", "crop", "hint" or "blocks"; WritingMode is "", "vertical" or "rtl".
+	LayoutMode  string
+	WritingMode string
+}
+
+// Document is the state of a document in the pipeline.
+type Document struct {
+	ID         string
+	FileName   string
+	State      string
+	TotalPages int
+	Pages      []Page
+}
+
+// Page is one page's translation outcome.
+type Page struct {
+	Number      int
+	Status      string
+	HasText     bool
+	SourceText  string
+	Translation string
+	Error       string
+}
+
+// Done reports whether translation has stopped: all pages finished, or the
+// document was paused or canceled.
+func (d *Document) Done() bool {
+	switch model.TaskState(d.State) {
+	case model.TaskStateCompleted, model.TaskStateFailed, model.TaskStatePaused, model.TaskStateCanceled:
+		return true
+	}
+	return false
+}
+
+// Start renders the PDF read from r and starts translating it in the
+// background; use Wait to block until it is done.
+func (e *Engine) Start(ctx context.Context, r io.Reader, fileName string, opts Options) (*Document, error) {
+	settings := service.TranslationSettings{
+		LayoutMode:  opts.LayoutMode,
+		WritingMode: opts.WritingMode,
+	}
+	if opts.Pages != "" {
+		settings.RangeMode = service.RangeModePages
+		settings.RangePages = opts.Pages
+	}
+	task, err := e.svc.CreateTask(ctx, r, fileName, opts.Provider, settings)
+	if err != nil {
+		return nil, err
+	}
+	return e.document(task), nil
+}
+
+// Translate is Start followed by Wait.
+func (e *Engine) Translate(ctx context.Context, r io.Reader, fileName string, opts Options) (*Document, error) {
+	doc, err := e.Start(ctx, r, fileName, opts)
+	if err != nil {
+		return nil, err
+	}
+	return e.Wait(ctx, doc.ID)
+}
+
+// Wait blocks until the document is done. If ctx ends first, the document is
+// canceled and ctx's error returned.
+func (e *Engine) Wait(ctx context.Context, id string) (*Document, error) {
+	ticker := time.NewTicker(e.poll)
+	defer ticker.Stop()
+	for {
+		doc, err := e.Document(id)
+		if err != nil {
+			return nil, err
+		}
+		if doc.Done() {
+			return doc, nil
+		}
+		select {
+		case <-ctx.Done():
+			if _, err := e.svc.CancelTask(id); err != nil {
+				return nil, fmt.Errorf("%w (cancel: %v)", ctx.Err(), err)
+			}
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Document returns the current state of a document.
+func (e *Engine) Document(id string) (*Document, error) {
+	task, err := e.svc.GetTask(id)
+	if err != nil {
+		return nil, err
+	}
+	return e.document(task), nil
+}
+
+// MergeText writes the combined translation TXT and returns its path.
+func (e *Engine) MergeText(ctx context.Context, id string) (string, error) {
+	task, _, err := e.svc.MergeText(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	return task.CombinedTxtPath, nil
+}
+
+// MergePDF writes the combined PDF in the given layout (empty uses the
+// document's preferred layout) and returns its path.
+func (e *Engine) MergePDF(ctx context.Context, id, layout string) (string, error) {
+	task, _, err := e.svc.MergePDF(ctx, id, layout)
+	if err != nil {
+		return "", err
+	}
+	return task.CombinedPDFPath, nil
+}
+
+// Format runs AI formatting over the combined translation and returns the
+// path of the formatted TXT. provider overrides fields of the default provider.
+func (e *Engine) Format(ctx context.Context, id string, provider ProviderConfig) (string, error) {
+	task, _, err := e.svc.FormatTaskLayout(ctx, id, provider)
+	if err != nil {
+		return "", err
+	}
+	return task.FormattedTxtPath, nil
+}
+
+// Harmonize runs the consistency pass, which unifies the renderings of names
+// and terms across the translation, and returns the path of the resulting TXT.
+func (e *Engine) Harmonize(ctx context.Context, id string, provider ProviderConfig) (string, error) {
+	task, _, err := e.svc.ConsistencyPass(ctx, id, provider)
+	if err != nil {
+		return "", err
+	}
+	return task.ConsistentTxtPath, nil
+}
+
+func (e *Engine) document(task *model.Task) *Document {
+	resp := e.svc.ToResponse(task)
+	doc := &Document{
+		ID:         resp.ID,
+		FileName:   resp.FileName,
+		State:      string(resp.State),
+		TotalPages: resp.TotalPages,
+		Pages:      make([]Page, 0, len(resp.Pages)),
+	}
+	for _, page := range resp.Pages {
+		doc.Pages = append(doc.Pages, Page{
+			Number:      page.PageNumber,
+			Status:      string(page.Status),
+			HasText:     page.HasText,
+			SourceText:  page.SourceText,
+			Translation: page.Translation,
+			Error:       page.Error,
+		})
+	}
+	return doc
+}